@@ -1,55 +1,250 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"maps"
+	"math"
+	"net"
+	"net/http"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	gosync "sync"
 	"time"
+	"unicode/utf8"
 
+	"github.com/hsk-coder/clawbrain/internal/audit"
+	"github.com/hsk-coder/clawbrain/internal/backoff"
+	"github.com/hsk-coder/clawbrain/internal/backup"
+	"github.com/hsk-coder/clawbrain/internal/cache"
+	"github.com/hsk-coder/clawbrain/internal/config"
+	"github.com/hsk-coder/clawbrain/internal/decision"
+	"github.com/hsk-coder/clawbrain/internal/embedder"
+	"github.com/hsk-coder/clawbrain/internal/expansion"
+	"github.com/hsk-coder/clawbrain/internal/hygiene"
 	"github.com/hsk-coder/clawbrain/internal/ollama"
+	"github.com/hsk-coder/clawbrain/internal/plugin"
+	"github.com/hsk-coder/clawbrain/internal/policy"
+	"github.com/hsk-coder/clawbrain/internal/purge"
+	"github.com/hsk-coder/clawbrain/internal/ranking"
 	"github.com/hsk-coder/clawbrain/internal/redis"
+	"github.com/hsk-coder/clawbrain/internal/reqid"
+	"github.com/hsk-coder/clawbrain/internal/reranker"
+	"github.com/hsk-coder/clawbrain/internal/resource"
+	"github.com/hsk-coder/clawbrain/internal/retention"
+	"github.com/hsk-coder/clawbrain/internal/router"
+	"github.com/hsk-coder/clawbrain/internal/schedule"
+	"github.com/hsk-coder/clawbrain/internal/seed"
+	"github.com/hsk-coder/clawbrain/internal/selector"
+	"github.com/hsk-coder/clawbrain/internal/server"
 	"github.com/hsk-coder/clawbrain/internal/store"
 	"github.com/hsk-coder/clawbrain/internal/sync"
+	"github.com/hsk-coder/clawbrain/internal/textnorm"
+	"github.com/hsk-coder/clawbrain/internal/vision"
+	"golang.org/x/net/websocket"
 )
 
+// version is the clawbrain release, recorded in the collection metadata.
+// Release builds set it with -ldflags "-X main.version=...".
+var version = "dev"
+
+// features are the capabilities of this build that a script or client may
+// want to check for before relying on them, as reported by version
+// --verbose. Add one when adding a capability worth negotiating; never
+// rename one.
+var features = []string{
+	"agents",
+	"archive",
+	"cold-tier",
+	"decisions",
+	"frontmatter",
+	"heading-chunks",
+	"hybrid-search",
+	"images",
+	"locks",
+	"must-contain",
+	"namespaces",
+	"optimize",
+	"orient",
+	"query-expansion",
+	"ranking-profiles",
+	"recent",
+	"reminders",
+	"rerank",
+	"resources",
+	"search-cache",
+	"selftest",
+	"serve",
+	"sync-extractors",
+	"sync-state-backends",
+	"sync-state-export",
+	"time-range",
+	"unsync",
+}
+
 // Global connection settings, set by parseGlobals.
 var (
-	globalHost      = "localhost"
-	globalPort      = 6334
-	globalOllamaURL = "http://localhost:11434"
-	globalModel     = "all-minilm"
-	globalRedisHost = "localhost"
-	globalRedisPort = 6379
+	globalBackend     = store.BackendQdrant
+	globalPath        = "~/.clawbrain"
+	globalHost        = "localhost"
+	globalPort        = 6334
+	globalReplicas    = ""
+	globalWrites      = store.WritesPrimary
+	globalOllamaURL   = "http://localhost:11434"
+	globalEmbedder    = embedder.Ollama
+	globalEmbedderURL = embedder.DefaultOpenAIURL
+	globalAPIKey      = ""
+	globalFallbacks   = ""
+	globalModel       = "all-minilm"
+	globalLLMModel    = "llama3.2"
+	globalVisionModel = "llava"
+	globalRedisHost   = "localhost"
+	globalRedisPort   = 6379
+	globalState       = sync.StateRedis
+	globalAuditLog    = ""
+	globalConfig      = ""
+	globalShared      = false
+	globalAgent       = ""
+	globalNamespace   = ""
+	globalRequestID   = ""
+	globalSandbox     = ""
+	globalTiming      = false
 )
 
 func init() {
 	// Environment variables override defaults (before flags override both).
+	if v := os.Getenv("CLAWBRAIN_BACKEND"); v != "" {
+		globalBackend = v
+	}
+	if v := os.Getenv("CLAWBRAIN_PATH"); v != "" {
+		globalPath = v
+	}
 	if v := os.Getenv("CLAWBRAIN_HOST"); v != "" {
 		globalHost = v
 	}
+	if v := os.Getenv("CLAWBRAIN_STATE_BACKEND"); v != "" {
+		globalState = v
+	}
 	if v := os.Getenv("CLAWBRAIN_PORT"); v != "" {
 		fmt.Sscanf(v, "%d", &globalPort)
 	}
+	if v := os.Getenv("CLAWBRAIN_REPLICAS"); v != "" {
+		globalReplicas = v
+	}
+	if v := os.Getenv("CLAWBRAIN_REPLICA_WRITES"); v != "" {
+		globalWrites = v
+	}
 	if v := os.Getenv("CLAWBRAIN_OLLAMA_URL"); v != "" {
 		globalOllamaURL = v
 	}
+	if v := os.Getenv("CLAWBRAIN_EMBEDDER"); v != "" {
+		globalEmbedder = v
+	}
+	if v := os.Getenv("CLAWBRAIN_EMBEDDER_URL"); v != "" {
+		globalEmbedderURL = v
+	}
+	if v := os.Getenv("CLAWBRAIN_API_KEY"); v != "" {
+		globalAPIKey = v
+	}
+	if v := os.Getenv("CLAWBRAIN_EMBEDDER_FALLBACKS"); v != "" {
+		globalFallbacks = v
+	}
 	if v := os.Getenv("CLAWBRAIN_MODEL"); v != "" {
 		globalModel = v
 	}
+	if v := os.Getenv("CLAWBRAIN_LLM_MODEL"); v != "" {
+		globalLLMModel = v
+	}
+	if v := os.Getenv("CLAWBRAIN_VISION_MODEL"); v != "" {
+		globalVisionModel = v
+	}
 	if v := os.Getenv("CLAWBRAIN_REDIS_HOST"); v != "" {
 		globalRedisHost = v
 	}
 	if v := os.Getenv("CLAWBRAIN_REDIS_PORT"); v != "" {
 		fmt.Sscanf(v, "%d", &globalRedisPort)
 	}
+	if v := os.Getenv("CLAWBRAIN_AUDIT_LOG"); v != "" {
+		globalAuditLog = v
+	}
+	if v := os.Getenv("CLAWBRAIN_CONFIG"); v != "" {
+		globalConfig = v
+	}
+	if v := os.Getenv("CLAWBRAIN_SHARED"); v != "" {
+		globalShared, _ = strconv.ParseBool(v)
+	}
+	if v := os.Getenv("CLAWBRAIN_AGENT"); v != "" {
+		globalAgent = v
+	}
+	if v := os.Getenv("CLAWBRAIN_NAMESPACE"); v != "" {
+		globalNamespace = v
+	}
+	if v := os.Getenv("CLAWBRAIN_SANDBOX"); v != "" {
+		globalSandbox = v
+	}
+	if v := os.Getenv("CLAWBRAIN_REQUEST_ID"); v != "" {
+		globalRequestID = v
+	}
+	if v := os.Getenv("CLAWBRAIN_TIMING"); v != "" {
+		globalTiming, _ = strconv.ParseBool(v)
+	}
 }
 
 func main() {
 	args := parseGlobals(os.Args[1:])
+	startRequest()
+	if globalAgent != "" {
+		if err := store.ValidateAgent(globalAgent); err != nil {
+			exitError(err)
+		}
+	}
+	if globalNamespace != "" {
+		if err := store.ValidateNamespace(globalNamespace); err != nil {
+			exitError(err)
+		}
+	}
+	if globalSandbox != "" {
+		if err := store.ValidateSandbox(globalSandbox); err != nil {
+			exitError(err)
+		}
+		// Sync's record of the files it ingested lives in Redis, which a
+		// sandbox doesn't copy.
+		if len(args) > 0 && slices.Contains([]string{"sync", "unsync"}, args[0]) {
+			exitJSON("error", fmt.Sprintf("%s can't run in a sandbox: sync state isn't sandboxed", args[0]))
+		}
+		if len(args) > 1 && args[0] == "resource" && args[1] == "move" {
+			exitJSON("error", "resource move can't run in a sandbox: sync state isn't sandboxed")
+		}
+	}
+	if _, err := embedder.New(globalEmbedder, globalEmbedderURL, globalAPIKey); err != nil {
+		exitError(err)
+	}
+	if _, err := embedder.ParseProviders(globalFallbacks, globalAPIKey); err != nil {
+		exitError(err)
+	}
+	switch globalBackend {
+	case store.BackendQdrant, store.BackendFile, store.BackendSQLite:
+	default:
+		exitJSON("error", fmt.Sprintf("unknown backend %q: use %s, %s or %s", globalBackend, store.BackendQdrant, store.BackendFile, store.BackendSQLite))
+	}
+	switch globalState {
+	case sync.StateRedis, sync.StateFile, sync.StateQdrant:
+	default:
+		exitJSON("error", fmt.Sprintf("unknown state backend %q: use %s, %s or %s", globalState, sync.StateRedis, sync.StateFile, sync.StateQdrant))
+	}
 
 	if len(args) == 0 {
 		printUsage()
@@ -61,29 +256,199 @@ func main() {
 	switch command {
 	case "add":
 		runAdd(args[1:])
+	case "update":
+		runUpdate(args[1:])
+	case "embed":
+		runEmbed(args[1:])
+	case "similarity":
+		runSimilarity(args[1:])
+	case "provenance":
+		runProvenance(args[1:])
+	case "refresh":
+		runRefresh(args[1:])
 	case "get":
 		runGet(args[1:])
 	case "search":
 		runSearch(args[1:])
+	case "score-histogram":
+		runScoreHistogram(args[1:])
 	case "delete":
 		runDelete(args[1:])
+	case "forget":
+		runForget(args[1:])
+	case "tier":
+		runTier(args[1:])
+	case "sandbox":
+		runSandbox(args[1:])
+	case "purge":
+		runPurge(args[1:])
+	case "hygiene":
+		runHygiene(args[1:])
+	case "retention-report":
+		runRetentionReport(args[1:])
+	case "tag":
+		runTag(args[1:])
+	case "tags":
+		runTags(args[1:])
+	case "orient":
+		runOrient(args[1:])
+	case "decisions":
+		runDecisions(args[1:])
+	case "recent":
+		runRecent(args[1:])
+	case "ranking":
+		runRanking(args[1:])
+	case "namespaces":
+		runNamespaces(args[1:])
+	case "resource":
+		runResource(args[1:])
+	case "export":
+		runExport(args[1:])
+	case "import":
+		runImport(args[1:])
+	case "optimize":
+		runOptimize(args[1:])
+	case "seed":
+		runSeed(args[1:])
+	case "lock", "unlock":
+		runLock(command, args[1:])
 	case "check":
 		runCheck()
+	case "selftest":
+		runSelftest(args[1:])
+	case "warmup":
+		runWarmup(args[1:])
+	case "due":
+		runDue(args[1:])
 	case "sync":
 		runSync(args[1:])
+	case "unsync":
+		runUnsync(args[1:])
+	case "migrate":
+		runMigrate(args[1:])
+	case "migrate-embeddings":
+		runMigrateEmbeddings(args[1:])
+	case "serve":
+		runServe(args[1:])
+	case "version":
+		runVersion(args[1:])
+	case "plugins":
+		runPlugins()
 	default:
+		if path, err := plugin.Find(command); err == nil {
+			runPlugin(path, args[1:])
+		}
 		fmt.Fprintf(os.Stderr, "unknown command: %s\n", command)
 		printUsage()
 		os.Exit(1)
 	}
 }
 
-// parseGlobals extracts --host, --port, --ollama-url, and --model from the
+// startRequest gives the command its request ID, the one passed with
+// --request-id or a new one. Qdrant and Ollama calls carry it, log lines
+// start with it, and outputJSON adds it to the response, so a failed
+// command can be found in every service's logs.
+func startRequest() {
+	if globalRequestID == "" {
+		globalRequestID = reqid.New()
+	} else if !reqid.Valid(globalRequestID) {
+		id := globalRequestID
+		globalRequestID = ""
+		exitJSON("error", fmt.Sprintf("invalid request ID %q: use up to 128 letters, digits, '.', '_', ':' or '-'", id))
+	}
+	reqid.SetDefault(globalRequestID)
+	log.SetFlags(log.Flags() | log.Lmsgprefix)
+	log.SetPrefix(reqid.Field + "=" + globalRequestID + " ")
+}
+
+// runPlugins lists the external commands found on PATH.
+func runPlugins() {
+	plugins := plugin.List()
+	if plugins == nil {
+		plugins = []plugin.Plugin{}
+	}
+	outputJSON(map[string]any{
+		"status":  "ok",
+		"plugins": plugins,
+	})
+}
+
+// runPlugin runs an external command with the remaining arguments and
+// exits with its exit code. It gets the resolved global settings as the
+// CLAWBRAIN_* variables, plus CLAWBRAIN_BIN to call back into this binary.
+func runPlugin(path string, args []string) {
+	vars := globalEnv()
+	if self, err := os.Executable(); err == nil {
+		vars["CLAWBRAIN_BIN"] = self
+	}
+
+	cmd := exec.Command(path, args...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.Env = plugin.Env(vars)
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.ExitCode())
+		}
+		exitError(fmt.Errorf("run %s: %w", path, err))
+	}
+	os.Exit(0)
+}
+
+// globalEnv returns the resolved global settings as the CLAWBRAIN_*
+// variables, which a child process reads back as its own.
+func globalEnv() map[string]string {
+	return map[string]string{
+		"CLAWBRAIN_BACKEND":            globalBackend,
+		"CLAWBRAIN_PATH":               globalPath,
+		"CLAWBRAIN_HOST":               globalHost,
+		"CLAWBRAIN_PORT":               strconv.Itoa(globalPort),
+		"CLAWBRAIN_REPLICAS":           globalReplicas,
+		"CLAWBRAIN_REPLICA_WRITES":     globalWrites,
+		"CLAWBRAIN_OLLAMA_URL":         globalOllamaURL,
+		"CLAWBRAIN_EMBEDDER":           globalEmbedder,
+		"CLAWBRAIN_EMBEDDER_URL":       globalEmbedderURL,
+		"CLAWBRAIN_API_KEY":            globalAPIKey,
+		"CLAWBRAIN_EMBEDDER_FALLBACKS": globalFallbacks,
+		"CLAWBRAIN_MODEL":              globalModel,
+		"CLAWBRAIN_LLM_MODEL":          globalLLMModel,
+		"CLAWBRAIN_VISION_MODEL":       globalVisionModel,
+		"CLAWBRAIN_REDIS_HOST":         globalRedisHost,
+		"CLAWBRAIN_REDIS_PORT":         strconv.Itoa(globalRedisPort),
+		"CLAWBRAIN_STATE_BACKEND":      globalState,
+		"CLAWBRAIN_AUDIT_LOG":          globalAuditLog,
+		"CLAWBRAIN_CONFIG":             globalConfig,
+		"CLAWBRAIN_SHARED":             strconv.FormatBool(globalShared),
+		"CLAWBRAIN_AGENT":              globalAgent,
+		"CLAWBRAIN_NAMESPACE":          globalNamespace,
+		"CLAWBRAIN_SANDBOX":            globalSandbox,
+		"CLAWBRAIN_REQUEST_ID":         globalRequestID,
+		"CLAWBRAIN_TIMING":             strconv.FormatBool(globalTiming),
+		"CLAWBRAIN_VERSION":            version,
+	}
+}
+
+// parseGlobals extracts the global flags (--host, --port, --model, ...) from the
 // argument list and returns the remaining arguments (command + subcommand flags).
 func parseGlobals(args []string) []string {
 	var remaining []string
 	for i := 0; i < len(args); i++ {
 		switch args[i] {
+		case "--backend":
+			if i+1 < len(args) {
+				globalBackend = args[i+1]
+				i++
+			}
+		case "--path":
+			if i+1 < len(args) {
+				globalPath = args[i+1]
+				i++
+			}
+		case "--state-backend":
+			if i+1 < len(args) {
+				globalState = args[i+1]
+				i++
+			}
 		case "--host":
 			if i+1 < len(args) {
 				globalHost = args[i+1]
@@ -94,16 +459,56 @@ func parseGlobals(args []string) []string {
 				fmt.Sscanf(args[i+1], "%d", &globalPort)
 				i++
 			}
+		case "--replicas":
+			if i+1 < len(args) {
+				globalReplicas = args[i+1]
+				i++
+			}
+		case "--replica-writes":
+			if i+1 < len(args) {
+				globalWrites = args[i+1]
+				i++
+			}
 		case "--ollama-url":
 			if i+1 < len(args) {
 				globalOllamaURL = args[i+1]
 				i++
 			}
+		case "--embedder":
+			if i+1 < len(args) {
+				globalEmbedder = args[i+1]
+				i++
+			}
+		case "--embedder-url":
+			if i+1 < len(args) {
+				globalEmbedderURL = args[i+1]
+				i++
+			}
+		case "--api-key":
+			if i+1 < len(args) {
+				globalAPIKey = args[i+1]
+				i++
+			}
+		case "--embedder-fallbacks":
+			if i+1 < len(args) {
+				globalFallbacks = args[i+1]
+				i++
+			}
 		case "--model":
 			if i+1 < len(args) {
 				globalModel = args[i+1]
 				i++
 			}
+		case "--llm-model":
+			if i+1 < len(args) {
+				globalLLMModel = args[i+1]
+				i++
+			}
+		case "--vision-model":
+			if i+1 < len(args) {
+				globalVisionModel = args[i+1]
+				i++
+			}
 		case "--redis-host":
 			if i+1 < len(args) {
 				globalRedisHost = args[i+1]
@@ -114,6 +519,40 @@ func parseGlobals(args []string) []string {
 				fmt.Sscanf(args[i+1], "%d", &globalRedisPort)
 				i++
 			}
+		case "--audit-log":
+			if i+1 < len(args) {
+				globalAuditLog = args[i+1]
+				i++
+			}
+		case "--config":
+			if i+1 < len(args) {
+				globalConfig = args[i+1]
+				i++
+			}
+		case "--shared":
+			globalShared = true
+		case "--agent":
+			if i+1 < len(args) {
+				globalAgent = args[i+1]
+				i++
+			}
+		case "--namespace":
+			if i+1 < len(args) {
+				globalNamespace = args[i+1]
+				i++
+			}
+		case "--sandbox":
+			if i+1 < len(args) {
+				globalSandbox = args[i+1]
+				i++
+			}
+		case "--request-id":
+			if i+1 < len(args) {
+				globalRequestID = args[i+1]
+				i++
+			}
+		case "--timing":
+			globalTiming = true
 		default:
 			remaining = append(remaining, args[i])
 		}
@@ -125,564 +564,8247 @@ func printUsage() {
 	fmt.Fprintln(os.Stderr, "Usage: clawbrain [--host HOST] [--port PORT] [--ollama-url URL] [--model MODEL] <command> [flags]")
 	fmt.Fprintln(os.Stderr, "")
 	fmt.Fprintln(os.Stderr, "Global flags:")
+	fmt.Fprintln(os.Stderr, "  --backend      Vector store: qdrant, file or sqlite (default: qdrant, env: CLAWBRAIN_BACKEND)")
+	fmt.Fprintln(os.Stderr, "  --path         Directory of the file and sqlite backends (default: ~/.clawbrain, env: CLAWBRAIN_PATH)")
 	fmt.Fprintln(os.Stderr, "  --host         Qdrant host (default: localhost, env: CLAWBRAIN_HOST)")
 	fmt.Fprintln(os.Stderr, "  --port         Qdrant gRPC port (default: 6334, env: CLAWBRAIN_PORT)")
+	fmt.Fprintln(os.Stderr, "  --replicas     Qdrant replicas HOST[:PORT],... to fail over to when --host is down (default: none, env: CLAWBRAIN_REPLICAS)")
+	fmt.Fprintln(os.Stderr, "  --replica-writes")
+	fmt.Fprintln(os.Stderr, "                 Where writes go with --replicas: primary (only --host) or failover (like reads) (default: primary, env: CLAWBRAIN_REPLICA_WRITES)")
 	fmt.Fprintln(os.Stderr, "  --ollama-url   Ollama base URL (default: http://localhost:11434, env: CLAWBRAIN_OLLAMA_URL)")
+	fmt.Fprintln(os.Stderr, "  --embedder     Embedding backend: ollama or openai (default: ollama, env: CLAWBRAIN_EMBEDDER)")
+	fmt.Fprintln(os.Stderr, "  --embedder-url OpenAI-compatible server for --embedder openai (default: https://api.openai.com, env: CLAWBRAIN_EMBEDDER_URL)")
+	fmt.Fprintln(os.Stderr, "  --api-key      API key for --embedder openai (default: none, env: CLAWBRAIN_API_KEY)")
+	fmt.Fprintln(os.Stderr, "  --embedder-fallbacks")
+	fmt.Fprintln(os.Stderr, "                 Embedders KIND=URL,... to fail over to, in order, when --embedder is down (default: none, env: CLAWBRAIN_EMBEDDER_FALLBACKS)")
 	fmt.Fprintln(os.Stderr, "  --model        Embedding model (default: all-minilm, env: CLAWBRAIN_MODEL)")
+	fmt.Fprintln(os.Stderr, "  --llm-model    Ollama generation model for summaries (default: llama3.2, env: CLAWBRAIN_LLM_MODEL)")
+	fmt.Fprintln(os.Stderr, "  --vision-model Ollama vision model for image captions (default: llava, env: CLAWBRAIN_VISION_MODEL)")
 	fmt.Fprintln(os.Stderr, "  --redis-host   Redis host (default: localhost, env: CLAWBRAIN_REDIS_HOST)")
 	fmt.Fprintln(os.Stderr, "  --redis-port   Redis port (default: 6379, env: CLAWBRAIN_REDIS_PORT)")
+	fmt.Fprintln(os.Stderr, "  --state-backend")
+	fmt.Fprintln(os.Stderr, "                 Where sync remembers synced files: redis, file (in --path) or qdrant (the vector store) (default: redis, env: CLAWBRAIN_STATE_BACKEND)")
+	fmt.Fprintln(os.Stderr, "  --audit-log    JSONL file recording deletions (default: disabled, env: CLAWBRAIN_AUDIT_LOG)")
+	fmt.Fprintln(os.Stderr, "  --config       JSON config file with write policies (default: none, env: CLAWBRAIN_CONFIG)")
+	fmt.Fprintln(os.Stderr, "  --shared       Running in a shared context: hide personal memories from get and search (env: CLAWBRAIN_SHARED)")
+	fmt.Fprintln(os.Stderr, "  --agent        Scope every command to one agent's memories (default: none, env: CLAWBRAIN_AGENT)")
+	fmt.Fprintln(os.Stderr, "  --namespace    Keep memories in a separate collection for this namespace (default: none, env: CLAWBRAIN_NAMESPACE)")
+	fmt.Fprintln(os.Stderr, "  --sandbox      Run the command in a sandbox made with sandbox create (default: none, env: CLAWBRAIN_SANDBOX)")
+	fmt.Fprintln(os.Stderr, "  --request-id   ID to correlate this command across logs, services and its response (default: random, env: CLAWBRAIN_REQUEST_ID)")
+	fmt.Fprintln(os.Stderr, "  --timing       Add what the command spent embedding and in the store to its response (env: CLAWBRAIN_TIMING)")
 	fmt.Fprintln(os.Stderr, "")
 	fmt.Fprintln(os.Stderr, "Commands:")
-	fmt.Fprintln(os.Stderr, "  add            Store a memory (--text 'your text here')")
-	fmt.Fprintln(os.Stderr, "  get            Fetch a memory by ID (--id <uuid>)")
-	fmt.Fprintln(os.Stderr, "  search         Search memories (--query 'search text')")
-	fmt.Fprintln(os.Stderr, "  delete         Delete old memories (-d <days>)")
+	fmt.Fprintln(os.Stderr, "  add            Store a memory (--text 'your text here' | --image PATH, --dry-run to preview)")
+	fmt.Fprintln(os.Stderr, "  update         Edit a memory in place (--id <uuid> | --alias NAME, --text and/or --payload)")
+	fmt.Fprintln(os.Stderr, "  embed          Print the embedding of a text without storing it (--text, repeatable)")
+	fmt.Fprintln(os.Stderr, "  similarity     Cosine similarity of two memories or texts, to tune --dedup-threshold (--a ID|TEXT --b ID|TEXT)")
+	fmt.Fprintln(os.Stderr, "  get            Fetch a memory by ID or alias (--id <uuid> | --alias NAME)")
+	fmt.Fprintln(os.Stderr, "  search         Search memories (--query 'search text' | --queries-file FILE)")
+	fmt.Fprintln(os.Stderr, "  score-histogram  Show how every memory scores against a query, to pick a --min-score (--query 'search text')")
+	fmt.Fprintln(os.Stderr, "  delete         Archive old memories (-d <days>, --hard to delete) or delete specific ones (--id, --ids, --filter); --dry-run to preview")
+	fmt.Fprintln(os.Stderr, "  forget         Forget memories not accessed within a TTL (--ttl 720h, --simulate to preview, --compress to summarize)")
+	fmt.Fprintln(os.Stderr, "  tier           Move memories not accessed in a long time to the cold tier (--older-than 90d, --dry-run to preview)")
+	fmt.Fprintln(os.Stderr, "  sandbox        Copy the collection to try destructive commands on (create, list, diff, drop)")
+	fmt.Fprintln(os.Stderr, "  purge          Remove every memory about a person or topic (--entity NAME, --dry-run to preview)")
+	fmt.Fprintln(os.Stderr, "  hygiene        List unhealthy memories worst first, with a recommended action (--action delete|refresh|confirm)")
+	fmt.Fprintln(os.Stderr, "  retention-report  Summarize data retention and deletion history (--format json|markdown)")
+	fmt.Fprintln(os.Stderr, "  tag            Bulk add/remove tags (tag add|remove --tag TAG --filter KEY=VALUE, --dry-run to preview)")
+	fmt.Fprintln(os.Stderr, "  tags           List every tag with how many memories carry it (--prefix project:)")
+	fmt.Fprintln(os.Stderr, "  decisions list List decisions (add --type decision), newest first, with their cards (--limit N, --tag TAG)")
+	fmt.Fprintln(os.Stderr, "  recent         List the newest memories without a query or embedding (--limit 20, --type todo, --since 24h)")
+	fmt.Fprintln(os.Stderr, "  orient         Summarize the store for a fresh session: counts by type, recent, pinned, open todos, last sync (--recent N)")
+	fmt.Fprintln(os.Stderr, "  ranking        Show or set the ranking profile every search of the collection or --agent uses (show|set|clear)")
+	fmt.Fprintln(os.Stderr, "  resource move  Rewrite source paths after moving notes (--from PATH --to PATH)")
+	fmt.Fprintln(os.Stderr, "  resource list  List browsable memory URIs (clawbrain://recent, clawbrain://pinned, clawbrain://memory/ID)")
+	fmt.Fprintln(os.Stderr, "  resource read  Read a memory URI without searching (resource read clawbrain://pinned)")
+	fmt.Fprintln(os.Stderr, "  export         Back up every memory with its vector to a JSONL file (--out FILE)")
+	fmt.Fprintln(os.Stderr, "  import         Restore memories from an export (--in FILE, --reembed to switch models)")
+	fmt.Fprintln(os.Stderr, "  optimize       Build the collection's vector index and wait until searches use it (--status to only report)")
+	fmt.Fprintln(os.Stderr, "  seed           Load deterministic synthetic memories for demos and benchmarks (--scenario orientation --n 500)")
+	fmt.Fprintln(os.Stderr, "  due            List memories whose reminder is due (--ack to reschedule, --watch 1m to poll)")
+	fmt.Fprintln(os.Stderr, "  lock           Protect a memory from update, merge and deletion (--id <uuid> | --alias NAME)")
+	fmt.Fprintln(os.Stderr, "  unlock         Remove a lock (--id <uuid> | --alias NAME)")
 	fmt.Fprintln(os.Stderr, "  sync           Ingest markdown files into memory")
+	fmt.Fprintln(os.Stderr, "  unsync         Remove the memories of deleted or renamed files (--file PATH | --missing, --archive, --dry-run)")
+	fmt.Fprintln(os.Stderr, "  provenance     Check a synced memory against its source file (--id ID)")
+	fmt.Fprintln(os.Stderr, "  refresh        Re-read a synced memory from its source file and update it in place (--id ID)")
+	fmt.Fprintln(os.Stderr, "  serve          Answer searches and listings over HTTP with ETags (--addr 127.0.0.1:7411)")
+	fmt.Fprintln(os.Stderr, "  namespaces     List namespaces with how many memories each holds")
+	fmt.Fprintln(os.Stderr, "  migrate        Bring the collection up to this build's schema (--dry-run to list pending migrations)")
+	fmt.Fprintln(os.Stderr, "  migrate-embeddings  Re-embed every memory with the current --model, after a backup (--backup FILE)")
+	fmt.Fprintln(os.Stderr, "  plugins        List external commands: executables named clawbrain-<name> on PATH")
 	fmt.Fprintln(os.Stderr, "  check          Verify Qdrant and Ollama connectivity")
+	fmt.Fprintln(os.Stderr, "  selftest       Run add, search, dedup, pin and forget end to end in a scratch namespace ([--keep])")
+	fmt.Fprintln(os.Stderr, "  version        Print the version (--verbose for build info, service versions, features and schema)")
+	fmt.Fprintln(os.Stderr, "  warmup         Open connections and load the embedding model (for container entrypoints)")
+}
+
+// runEmbed embeds texts with the current --model and prints the vectors
+// without storing anything: the other half of add --vector, for callers
+// that precompute, cache or inspect embeddings.
+func runEmbed(args []string) {
+	fs := flag.NewFlagSet("embed", flag.ExitOnError)
+	var texts multiFlag
+	fs.Var(&texts, "text", "Text to embed (repeatable; several are embedded in batches)")
+	fs.Parse(args)
+
+	if len(texts) == 0 {
+		fmt.Fprintln(os.Stderr, "Error: --text is required")
+		fs.Usage()
+		os.Exit(1)
+	}
+	for _, t := range texts {
+		if strings.TrimSpace(t) == "" {
+			exitJSON("error", "--text must not be empty")
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), batchSearchTimeout)
+	defer cancel()
+	emb := newEmbedder()
+	var vectors [][]float32
+	for start := 0; start < len(texts); start += batchEmbedSize {
+		batch, err := emb.EmbedBatch(ctx, globalModel, texts[start:min(start+batchEmbedSize, len(texts))])
+		if err != nil {
+			exitError(fmt.Errorf("embedding failed: %w", err))
+		}
+		vectors = append(vectors, batch...)
+	}
+
+	result := map[string]any{
+		"status":     "ok",
+		"model":      globalModel,
+		"dimensions": len(vectors[0]),
+	}
+	if len(texts) == 1 {
+		result["vector"] = vectors[0]
+	} else {
+		// Each entry is also a valid add --batch-file line.
+		embeddings := make([]map[string]any, len(texts))
+		for i, t := range texts {
+			embeddings[i] = map[string]any{"text": t, "vector": vectors[i]}
+		}
+		result["embeddings"] = embeddings
+	}
+	outputJSON(result)
+}
+
+// runSimilarity scores two memories or texts against each other, the way
+// dedup and search would: a memory ID uses the stored vector, anything else
+// is embedded fresh.
+func runSimilarity(args []string) {
+	fs := flag.NewFlagSet("similarity", flag.ExitOnError)
+	a := fs.String("a", "", "Memory ID or text")
+	b := fs.String("b", "", "Memory ID or text to compare with --a")
+	threshold := fs.Float64("dedup-threshold", float64(defaultDedupThreshold), "Threshold to judge would_merge against (env: CLAWBRAIN_DEDUP_THRESHOLD)")
+	includePersonal := fs.Bool("include-personal", false, "Allow comparing a personal memory in a shared context (--shared)")
+	fs.Parse(args)
+
+	if *a == "" || *b == "" {
+		fmt.Fprintln(os.Stderr, "Error: --a and --b are required")
+		fs.Usage()
+		os.Exit(1)
+	}
+	setDedupThreshold(fs, *threshold)
+
+	specs := []string{*a, *b}
+	isMemory := make([]bool, len(specs))
+	for i, spec := range specs {
+		isMemory[i] = store.ValidateID(spec) == nil
+	}
+
+	// Comparing two texts only needs Ollama.
+	var s *store.Store
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	if isMemory[0] || isMemory[1] {
+		cancel()
+		s, ctx, cancel = connect()
+		defer s.Close()
+	}
+	defer cancel()
+
+	vectors := make([][]float32, len(specs))
+	operands := make([]map[string]any, len(specs))
+	var texts []string
+	for i, spec := range specs {
+		if !isMemory[i] {
+			texts = append(texts, spec)
+			operands[i] = map[string]any{"text": spec}
+			continue
+		}
+		p, err := peekPointTiers(ctx, s, spec)
+		if err != nil {
+			exitError(err)
+		}
+		if p == nil {
+			exitJSON("error", fmt.Sprintf("memory %s not found", spec))
+		}
+		if globalShared && !*includePersonal && store.IsPersonal(p.Payload) {
+			exitJSON("error", fmt.Sprintf("memory %s is personal; pass --include-personal to compare it in a shared context", spec))
+		}
+		vectors[i] = p.Vector
+		operands[i] = map[string]any{"id": spec, "text": p.Payload["text"]}
+	}
+	if len(texts) > 0 {
+		embedded, err := newEmbedder().EmbedBatch(ctx, globalModel, texts)
+		if err != nil {
+			exitError(fmt.Errorf("embedding failed: %w", err))
+		}
+		for i := range specs {
+			if !isMemory[i] {
+				vectors[i], embedded = embedded[0], embedded[1:]
+				if s != nil {
+					guardEmbedding(ctx, s, vectors[i], true)
+				}
+			}
+		}
+	}
+
+	score, err := store.Cosine(vectors[0], vectors[1])
+	if err != nil {
+		exitError(err)
+	}
+	// Dedup also checks the texts say the same thing (see sameFact).
+	textA, _ := operands[0]["text"].(string)
+	textB, _ := operands[1]["text"].(string)
+	outputJSON(map[string]any{
+		"status":          "ok",
+		"similarity":      score,
+		"text_overlap":    textnorm.Overlap(textA, textB),
+		"dedup_threshold": dedupThreshold,
+		"would_merge":     score >= dedupThreshold && sameFact(textA, store.Result{Payload: map[string]any{"text": textB}}),
+		"model":           globalModel,
+		"a":               operands[0],
+		"b":               operands[1],
+	})
 }
 
 func runGet(args []string) {
 	fs := flag.NewFlagSet("get", flag.ExitOnError)
-	id := fs.String("id", "", "UUID of the memory to fetch (required)")
+	id := fs.String("id", "", "UUID of the memory to fetch")
+	idList := fs.String("ids", "", "Fetch these memories in one call (comma-separated UUIDs), e.g. the related IDs of search --related")
+	alias := fs.String("alias", "", "Alias of the memory to fetch (alternative to --id)")
+	includePersonal := fs.Bool("include-personal", false, "Allow fetching a personal memory in a shared context (--shared)")
+	selectSpec := fs.String("select", "", "Only output these fields of the memory, e.g. id,payload.text")
+	summarize := fs.Bool("summarize", false, fmt.Sprintf("Return a summary by --llm-model in place of text over %d characters, with a reference to the full text", summarizeMinLength))
 	fs.Parse(args)
 
-	if *id == "" {
-		fmt.Fprintln(os.Stderr, "Error: --id is required")
+	sel, err := selector.Parse(*selectSpec)
+	if err != nil {
+		exitError(err)
+	}
+	if *summarize && sel != nil {
+		exitJSON("error", "--summarize can't be combined with --select")
+	}
+	if *idList != "" {
+		if *id != "" || *alias != "" {
+			exitJSON("error", "--ids can't be combined with --id or --alias")
+		}
+		runGetMany(*idList, *includePersonal, sel, *summarize)
+		return
+	}
+	if *id == "" && *alias == "" {
+		fmt.Fprintln(os.Stderr, "Error: --id, --ids or --alias is required")
 		fs.Usage()
 		os.Exit(1)
 	}
+	if *id != "" && *alias != "" {
+		exitJSON("error", "--id and --alias are mutually exclusive")
+	}
 
 	s, ctx, cancel := connect()
 	defer cancel()
 	defer s.Close()
 
-	result, err := s.Get(ctx, *id)
+	if *alias != "" {
+		*id = resolveAlias(ctx, s, *alias)
+	}
+
+	// Peek first: a personal memory refused in a shared context must not
+	// count as recalled.
+	result, cold, err := peekTiers(ctx, s, *id)
 	if err != nil {
-		exitJSON("error", err.Error())
+		exitError(err)
 	}
 
 	if result == nil {
 		exitJSON("error", fmt.Sprintf("memory %s not found", *id))
 	}
+	if globalShared && !*includePersonal && store.IsPersonal(result.Payload) {
+		exitJSON("error", fmt.Sprintf("memory %s is personal; pass --include-personal to fetch it in a shared context", *id))
+	}
+	if cold {
+		thaw(ctx, s, []string{result.ID})
+	}
+	s.Touch(ctx, []store.Result{*result})
 
-	outputJSON(map[string]any{
-		"status":  "ok",
+	memory, err := sel.Apply(map[string]any{
 		"id":      result.ID,
 		"payload": result.Payload,
 	})
+	if err != nil {
+		exitError(err)
+	}
+	memory["status"] = "ok"
+	if sel == nil && decision.Is(result.Payload) {
+		memory[decision.CardField] = decision.Card(result.Payload)
+	}
+	if *summarize {
+		summarizeMemory(memory)
+	}
+	outputJSON(limitResponse(memory))
 }
 
-// dedupThreshold is the minimum similarity score at which an existing memory
-// is considered a duplicate of the incoming text. When a match is found at or
-// above this threshold, the old memory is deleted and its created_at is
-// preserved on the new one — effectively "merging" by letting the newer text
-// replace the older version while keeping its origin timestamp.
-const dedupThreshold float32 = 0.92
+// summarizeMinLength is how many characters a memory's text must exceed
+// before get --summarize condenses it; shorter ones cost less to read
+// than to summarize.
+const summarizeMinLength = 1000
 
-func runAdd(args []string) {
-	fs := flag.NewFlagSet("add", flag.ExitOnError)
-	text := fs.String("text", "", "Text to store as a memory (default mode)")
-	payloadJSON := fs.String("payload", "", "Additional metadata as JSON object")
-	vectorJSON := fs.String("vector", "", "Embedding vector as JSON array (advanced, overrides text mode)")
-	id := fs.String("id", "", "UUID for the point (auto-generated if omitted)")
-	pinned := fs.Bool("pinned", false, "Pin this memory to prevent deletion")
-	noMerge := fs.Bool("no-merge", false, "Skip deduplication — store without checking for similar memories")
-	fs.Parse(args)
+// summarizeTimeout bounds summarizing one memory: like captioning, text
+// generation is much slower than embedding.
+const summarizeTimeout = 2 * time.Minute
 
-	// Parse optional payload
-	var payload map[string]any
-	if *payloadJSON != "" {
-		if err := json.Unmarshal([]byte(*payloadJSON), &payload); err != nil {
-			exitJSON("error", fmt.Sprintf("invalid payload JSON: %v", err))
-		}
-	} else {
-		payload = make(map[string]any)
+// summarizeMemory is get --summarize for one memory as get outputs it:
+// text over summarizeMinLength characters is dropped from its payload for
+// a summary by the LLM, and full_text says where the text is and how long
+// it is, for get --select payload.text to fetch it. summarized says which
+// happened. It exits if the model fails.
+func summarizeMemory(memory map[string]any) {
+	payload, _ := memory["payload"].(map[string]any)
+	text := payloadText(payload)
+	length := utf8.RuneCountInString(text)
+	if length <= summarizeMinLength {
+		memory["summarized"] = false
+		return
+	}
+	source, _ := payload["source"].(string)
+	ctx, cancel := context.WithTimeout(context.Background(), summarizeTimeout)
+	defer cancel()
+	summary, err := ollama.New(globalOllamaURL).Generate(ctx, globalLLMModel, retention.CondensePrompt(text, source))
+	if err != nil {
+		exitJSON("error", fmt.Sprintf("summarizing memory %v failed: %v", memory["id"], err))
 	}
+	if summary = strings.TrimSpace(summary); summary == "" {
+		exitJSON("error", fmt.Sprintf("summarizing memory %v failed: the model returned an empty summary", memory["id"]))
+	}
+	payload = maps.Clone(payload)
+	delete(payload, "text")
+	memory["payload"] = payload
+	memory["summarized"] = true
+	memory["summary"] = summary
+	memory["full_text"] = map[string]any{"id": memory["id"], "field": "payload.text", "length": length}
+}
 
-	if *pinned {
-		payload["pinned"] = true
+// runGetMany is get --ids: every listed memory in one response, in the
+// order given, each summarized with summarize (see summarizeMemory). IDs that name no memory, or a personal one under --shared
+// without includePersonal, are listed in missing rather than failing the
+// rest.
+func runGetMany(idList string, includePersonal bool, sel selector.Selector, summarize bool) {
+	var ids []string
+	for _, part := range strings.Split(idList, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" || slices.Contains(ids, part) {
+			continue
+		}
+		if err := store.ValidateID(part); err != nil {
+			exitError(err)
+		}
+		ids = append(ids, part)
+	}
+	if len(ids) == 0 {
+		exitJSON("error", "--ids lists no memory IDs")
 	}
 
 	s, ctx, cancel := connect()
 	defer cancel()
 	defer s.Close()
 
-	if *vectorJSON != "" {
-		// Advanced vector mode: user provides their own embedding
-		var vector []float32
-		if err := json.Unmarshal([]byte(*vectorJSON), &vector); err != nil {
-			exitJSON("error", fmt.Sprintf("invalid vector JSON: %v", err))
-		}
-
-		// Require text field in payload — a memory without text is a ghost
-		// that pollutes retrieval results with no displayable content.
-		t, ok := payload["text"]
-		if !ok || t == nil {
-			exitJSON("error", "payload must contain a non-empty \"text\" field")
-		}
-		if s, isStr := t.(string); !isStr || s == "" {
-			exitJSON("error", "payload must contain a non-empty \"text\" field")
+	found := []store.Result{}
+	missing := []string{}
+	var frozen []string
+	for _, id := range ids {
+		result, cold, err := peekTiers(ctx, s, id)
+		if err != nil {
+			exitError(err)
 		}
-
-		// Dedup: search for similar memories and merge if found
-		var merged []store.Result
-		if !*noMerge {
-			merged = dedupAndDelete(ctx, s, vector)
+		if result == nil || (globalShared && !includePersonal && store.IsPersonal(result.Payload)) {
+			missing = append(missing, id)
+			continue
 		}
-		if len(merged) > 0 {
-			if ca := oldestCreatedAt(merged); ca != "" {
-				payload["created_at"] = ca
-			}
+		if cold {
+			frozen = append(frozen, id)
 		}
+		found = append(found, *result)
+	}
+	thaw(ctx, s, frozen)
+	s.Touch(ctx, found)
 
-		pointID, err := s.Add(ctx, *id, vector, payload)
+	memories := make([]map[string]any, len(found))
+	for i, r := range found {
+		m, err := sel.Apply(map[string]any{"id": r.ID, "payload": r.Payload})
 		if err != nil {
-			exitJSON("error", err.Error())
-		}
-
-		result := map[string]any{
-			"status": "ok",
-			"id":     pointID,
-		}
-		if len(merged) > 0 {
-			result["merged_ids"] = mergedIDs(merged)
-			// Backward compat: merged_id is the first (most similar) duplicate
-			result["merged_id"] = merged[0].ID
+			exitError(err)
 		}
-		outputJSON(result)
-	} else if *text != "" {
-		// Default text mode: embed via Ollama, then store
-		oc := ollama.New(globalOllamaURL)
-		vector, err := oc.Embed(ctx, globalModel, *text)
-		if err != nil {
-			exitJSON("error", fmt.Sprintf("embedding failed: %v", err))
+		if summarize {
+			summarizeMemory(m)
 		}
+		memories[i] = m
+	}
+	outputJSON(limitResponse(map[string]any{
+		"status":   "ok",
+		"memories": memories,
+		"returned": len(memories),
+		"missing":  missing,
+	}, "memories"))
+}
 
-		// Store the original text in payload so it can be returned on retrieval
-		payload["text"] = *text
-
-		// Dedup: search for similar memories and merge if found
-		var merged []store.Result
-		if !*noMerge {
-			merged = dedupAndDelete(ctx, s, vector)
-		}
-		if len(merged) > 0 {
-			if ca := oldestCreatedAt(merged); ca != "" {
-				payload["created_at"] = ca
-			}
+// peekTiers is Peek over the collection and then its cold tier, reporting
+// whether the memory was found cold.
+func peekTiers(ctx context.Context, s *store.Store, id string) (*store.Result, bool, error) {
+	for _, t := range s.Tiers() {
+		r, err := t.Peek(ctx, id)
+		if err != nil || r != nil {
+			return r, t.IsCold(), err
 		}
+	}
+	return nil, false, nil
+}
 
-		pointID, err := s.Add(ctx, *id, vector, payload)
-		if err != nil {
-			exitJSON("error", err.Error())
-		}
+// thaw moves memories fetched from the cold tier back to the collection:
+// fetching one is recalling it. A failure only logs, as the fetch itself
+// succeeded.
+func thaw(ctx context.Context, s *store.Store, ids []string) {
+	if err := s.Thaw(ctx, ids); err != nil {
+		log.Printf("warning: failed to move memories out of the cold tier: %v", err)
+	}
+}
 
-		result := map[string]any{
-			"status": "ok",
-			"id":     pointID,
+// peekPointTiers is peekTiers with the memory's vector.
+func peekPointTiers(ctx context.Context, s *store.Store, id string) (*store.Point, error) {
+	for _, t := range s.Tiers() {
+		p, err := t.PeekPoint(ctx, id)
+		if err != nil || p != nil {
+			return p, err
 		}
-		if len(merged) > 0 {
-			result["merged_ids"] = mergedIDs(merged)
-			// Backward compat: merged_id is the first (most similar) duplicate
-			result["merged_id"] = merged[0].ID
-		}
-		outputJSON(result)
-	} else {
-		fmt.Fprintln(os.Stderr, "Error: --text is required (or --vector for advanced mode)")
-		fs.Usage()
-		os.Exit(1)
 	}
+	return nil, nil
 }
 
-// dedupAndDelete looks for all existing memories above the dedup threshold.
-// It deletes every duplicate found and returns the full list so the caller can
-// preserve the oldest created_at. Returns nil when no duplicates are found.
-func dedupAndDelete(ctx context.Context, s *store.Store, vector []float32) []store.Result {
-	similar, err := s.FindSimilar(ctx, vector, dedupThreshold, 64)
+// warm moves a memory about to be written to back from the cold tier, so
+// the write finds it in the collection.
+func warm(ctx context.Context, s *store.Store, id string) {
+	if _, err := s.Warm(ctx, id); err != nil {
+		exitError(err)
+	}
+}
+
+// resolveAlias returns the ID of the memory holding alias, exiting with an
+// error if no memory has it.
+func resolveAlias(ctx context.Context, s *store.Store, alias string) string {
+	id, err := s.ResolveAlias(ctx, alias)
 	if err != nil {
-		// Non-fatal: if dedup search fails, just proceed with a normal add.
-		return nil
+		exitError(err)
 	}
-	if len(similar) == 0 {
-		return nil
+	if id == "" {
+		exitJSON("error", fmt.Sprintf("alias %s not found", alias))
 	}
+	return id
+}
 
-	// Delete all old duplicates, but never delete pinned memories.
-	var deleted []store.Result
-	for _, old := range similar {
-		if pinned, ok := old.Payload["pinned"].(bool); ok && pinned {
-			// Pinned memories are immune to automatic deletion, including dedup.
+// runLock handles both lock and unlock. A locked memory can't be overwritten
+// by add --id, merged away by dedup, retagged, or deleted by forget/delete
+// until it is unlocked — pinning only protects against forgetting.
+func runLock(command string, args []string) {
+	fs := flag.NewFlagSet(command, flag.ExitOnError)
+	id := fs.String("id", "", "UUID of the memory to "+command)
+	alias := fs.String("alias", "", "Alias of the memory to "+command+" (alternative to --id)")
+	fs.Parse(args)
+
+	if *id == "" && *alias == "" {
+		fmt.Fprintln(os.Stderr, "Error: --id or --alias is required")
+		fs.Usage()
+		os.Exit(1)
+	}
+	if *id != "" && *alias != "" {
+		exitJSON("error", "--id and --alias are mutually exclusive")
+	}
+
+	s, ctx, cancel := connect()
+	defer cancel()
+	defer s.Close()
+
+	if *alias != "" {
+		*id = resolveAlias(ctx, s, *alias)
+	}
+
+	warm(ctx, s, *id)
+	existing, err := s.Peek(ctx, *id)
+	if err != nil {
+		exitError(err)
+	}
+	if existing == nil {
+		exitJSON("error", fmt.Sprintf("memory %s not found", *id))
+	}
+
+	locked := command == "lock"
+	if err := s.SetPayloads(ctx, map[string]map[string]any{*id: {"locked": locked}}); err != nil {
+		exitError(err)
+	}
+
+	outputJSON(map[string]any{
+		"status": "ok",
+		"id":     *id,
+		"locked": locked,
+	})
+}
+
+// refuseLocked exits with an error if the memory with the given ID exists and
+// is locked, so callers can't overwrite it.
+func refuseLocked(ctx context.Context, s *store.Store, id string) {
+	existing, _, err := peekTiers(ctx, s, id)
+	if err != nil {
+		exitError(err)
+	}
+	if existing != nil && store.IsLocked(existing.Payload) {
+		exitJSON("error", fmt.Sprintf("memory %s is locked; unlock it first", id))
+	}
+}
+
+// defaultDedupThreshold is the minimum similarity score at which an existing
+// memory is considered a duplicate of the incoming text. When a match is
+// found at or above the threshold, the old memory is deleted and its
+// created_at is preserved on the new one — effectively "merging" by letting
+// the newer text replace the older version while keeping its origin
+// timestamp. It suits all-minilm; other embedding models spread their scores
+// differently, hence --dedup-threshold and CLAWBRAIN_DEDUP_THRESHOLD.
+const defaultDedupThreshold float32 = 0.92
+
+// dedupThreshold is the threshold in effect, set by setDedupThreshold.
+var dedupThreshold = defaultDedupThreshold
+
+// setDedupThreshold applies --dedup-threshold, or CLAWBRAIN_DEDUP_THRESHOLD
+// when the flag isn't given, exiting if the value is out of range.
+func setDedupThreshold(fs *flag.FlagSet, value float64) {
+	source := "--dedup-threshold"
+	if !flagSet(fs, "dedup-threshold") {
+		env := os.Getenv("CLAWBRAIN_DEDUP_THRESHOLD")
+		if env == "" {
+			return
+		}
+		source = "CLAWBRAIN_DEDUP_THRESHOLD"
+		var err error
+		if value, err = strconv.ParseFloat(env, 32); err != nil {
+			exitJSON("error", fmt.Sprintf("invalid %s %q", source, env))
+		}
+	}
+	if value <= 0 || value > 1 {
+		exitJSON("error", fmt.Sprintf("%s must be in (0, 1]", source))
+	}
+	dedupThreshold = float32(value)
+}
+
+// mergedScores lists merged duplicates with their similarity to the new
+// memory, so a caller can audit each merge against the threshold.
+func mergedScores(merged []store.Result) []map[string]any {
+	out := make([]map[string]any, len(merged))
+	for i, r := range merged {
+		out[i] = map[string]any{"id": r.ID, "score": r.Score}
+	}
+	return out
+}
+
+func runAdd(args []string) {
+	fs := flag.NewFlagSet("add", flag.ExitOnError)
+	text := fs.String("text", "", "Text to store as a memory (default mode)")
+	payloadJSON := fs.String("payload", "", "Additional metadata as JSON object")
+	vectorJSON := fs.String("vector", "", "Embedding vector as JSON array, e.g. from the embed command (advanced, skips Ollama; --text becomes the stored text)")
+	id := fs.String("id", "", "UUID for the point (auto-generated if omitted)")
+	pinned := fs.Bool("pinned", false, "Pin this memory to prevent deletion")
+	noMerge := fs.Bool("no-merge", false, "Skip deduplication — store without checking for similar memories")
+	threshold := fs.Float64("dedup-threshold", float64(defaultDedupThreshold), "Similarity at or above which an existing memory is merged as a duplicate (env: CLAWBRAIN_DEDUP_THRESHOLD)")
+	alias := fs.String("alias", "", "Stable name for this memory (moves the alias if another memory holds it)")
+	remind := fs.String("remind", "", "Reminder schedule, e.g. \"every friday 09:00\" or \"in 2h\" (surfaced by the due command)")
+	imagePath := fs.String("image", "", "Image to remember; a vision model captions it and the caption is embedded")
+	caption := fs.String("caption", "", "Caption for --image, skipping the vision model")
+	author := fs.String("author", "", "Who wrote this memory (stored lowercase, filterable with search --author)")
+	speaker := fs.String("speaker", "", "Who said this, e.g. a quoted person (stored lowercase, filterable with search --speaker)")
+	sensitivity := fs.String("sensitivity", "", "Privacy level: public, internal (the default) or personal")
+	memType := fs.String("type", "", "Memory type, e.g. lesson, todo, fact, preference or decision (see \"types\" in the config)")
+	ifVersion := fs.Int64("if-version", 0, "With --id: only rewrite the memory if it is still at this revision")
+	ifLastAccessedBefore := fs.String("if-last-accessed-before", "", "With --id: only rewrite the memory if nobody has touched it since this RFC 3339 time")
+	var relate, supersedes, tags, options multiFlag
+	fs.Var(&relate, "relate", "Relate the new memory to an existing one, as ID or ID:KIND (repeatable)")
+	fs.Var(&supersedes, "supersedes", "Mark an existing memory as superseded by the new one (repeatable)")
+	fs.Var(&tags, "tag", "Tag the new memory (repeatable)")
+	decisionContext := fs.String("context", "", "Decision: the situation that called for it (sets --type decision)")
+	fs.Var(&options, "option", "Decision: an option that was weighed (repeatable; sets --type decision)")
+	choice := fs.String("choice", "", "Decision: the option chosen (sets --type decision)")
+	consequences := fs.String("consequences", "", "Decision: what follows from the choice (sets --type decision)")
+	batchFile := fs.String("batch-file", "", "Store every memory in this JSONL file (- for stdin) with one upsert; --pinned, --no-merge, --tag, --type, --author, --speaker and --sensitivity apply to all")
+	dryRun := fs.Bool("dry-run", false, "Report the dedup decision and the payload that would be stored without writing anything")
+	verbose := fs.Bool("verbose", false, "Explain the add: the similar memories found and why each was or wasn't merged, inherited fields, and the stored payload")
+	fs.Parse(args)
+
+	setDedupThreshold(fs, *threshold)
+	if *noMerge && flagSet(fs, "dedup-threshold") {
+		exitJSON("error", "--dedup-threshold and --no-merge are mutually exclusive")
+	}
+
+	if *batchFile != "" {
+		for _, name := range []string{"text", "payload", "vector", "id", "alias", "remind", "image", "caption",
+			"if-version", "if-last-accessed-before", "relate", "supersedes", "verbose",
+			"context", "option", "choice", "consequences"} {
+			if flagSet(fs, name) {
+				exitJSON("error", fmt.Sprintf("--%s can't be combined with --batch-file", name))
+			}
+		}
+		runAddBatch(*batchFile, batchDefaults{
+			pinned:      *pinned,
+			noMerge:     *noMerge,
+			tags:        tags,
+			memType:     *memType,
+			author:      *author,
+			speaker:     *speaker,
+			sensitivity: *sensitivity,
+			dryRun:      *dryRun,
+		})
+		return
+	}
+
+	// A precondition guards the memory --id rewrites, or with --supersedes
+	// the memories being replaced.
+	pre := parsePrecondition(fs, *ifVersion, *ifLastAccessedBefore)
+	links := parseLinks(relate, supersedes)
+	if !pre.Empty() && *id == "" && len(links.Supersedes) == 0 {
+		exitJSON("error", "--if-version and --if-last-accessed-before require --id or --supersedes")
+	}
+	if !pre.Empty() && len(links.Relations) > 0 && len(links.Supersedes) == 0 {
+		exitJSON("error", "--relate links a new memory; it can't be combined with --if-version or --if-last-accessed-before")
+	}
+
+	if *imagePath != "" && (*text != "" || *vectorJSON != "") {
+		exitJSON("error", "--image can't be combined with --text or --vector")
+	}
+	if *caption != "" && *imagePath == "" {
+		exitJSON("error", "--caption requires --image")
+	}
+
+	if *alias != "" {
+		if err := store.ValidateAlias(*alias); err != nil {
+			exitError(err)
+		}
+	}
+	var reminder *schedule.Schedule
+	if *remind != "" {
+		sched, err := schedule.Parse(*remind, time.Now())
+		if err != nil {
+			exitError(err)
+		}
+		reminder = &sched
+	}
+
+	// Parse optional payload
+	var payload map[string]any
+	if *payloadJSON != "" {
+		if err := json.Unmarshal([]byte(*payloadJSON), &payload); err != nil {
+			exitJSON("error", fmt.Sprintf("invalid payload JSON: %v", err))
+		}
+	} else {
+		payload = make(map[string]any)
+	}
+
+	if *pinned {
+		payload["pinned"] = true
+	}
+	if len(tags) > 0 {
+		payload["tags"] = store.TagsValue(store.WithTags(store.Tags(payload), tags...))
+	}
+	if *alias != "" {
+		payload["alias"] = *alias
+	}
+	if *author != "" {
+		payload[store.AuthorField] = *author
+	}
+	if *speaker != "" {
+		payload[store.SpeakerField] = *speaker
+	}
+	if err := store.NormalizeAttribution(payload); err != nil {
+		exitError(err)
+	}
+	if *sensitivity != "" {
+		payload[store.SensitivityField] = *sensitivity
+	}
+	if err := store.NormalizeSensitivity(payload); err != nil {
+		exitError(err)
+	}
+	if *memType != "" {
+		payload[store.TypeField] = *memType
+	}
+	if reminder != nil {
+		payload["remind"] = reminder.String()
+		payload[store.RemindNextField] = formatDue(reminder.First(time.Now()))
+	}
+	if *decisionContext != "" || len(options) > 0 || *choice != "" || *consequences != "" {
+		if t, _ := payload[store.TypeField].(string); t == "" {
+			payload[store.TypeField] = decision.Type
+		} else if !decision.Is(payload) {
+			exitJSON("error", fmt.Sprintf("--context, --option, --choice and --consequences describe a decision, not a %s", t))
+		}
+		for field, value := range map[string]string{
+			decision.ContextField:      *decisionContext,
+			decision.ChoiceField:       *choice,
+			decision.ConsequencesField: *consequences,
+		} {
+			if value != "" {
+				payload[field] = value
+			}
+		}
+		if len(options) > 0 {
+			payload[decision.OptionsField] = []string(options)
+		}
+	}
+	if decision.Is(payload) {
+		if err := decision.Validate(payload); err != nil {
+			exitError(err)
+		}
+		// Without text of its own, a decision is embedded as its card.
+		if *text == "" && *imagePath == "" && *vectorJSON == "" {
+			*text = decision.Card(payload)
+		}
+	}
+	if *imagePath != "" {
+		img, err := vision.Load(*imagePath)
+		if err != nil {
+			exitError(err)
+		}
+		img.Payload(payload)
+		if *caption == "" {
+			*caption = describeImage(img)
+		}
+		// The caption is the memory's text: it is what gets embedded and
+		// what a text query matches.
+		*text = *caption
+		// Screenshots of the same screen get near-identical captions;
+		// merging them would throw away all but the latest.
+		*noMerge = true
+	}
+	if *text != "" {
+		// Store the original text in payload so it can be returned on retrieval
+		payload["text"] = *text
+	}
+
+	var vector []float32
+	if *vectorJSON != "" {
+		// Advanced vector mode: the caller provides their own embedding,
+		// e.g. one precomputed with the embed command.
+		if err := json.Unmarshal([]byte(*vectorJSON), &vector); err != nil {
+			exitJSON("error", fmt.Sprintf("invalid vector JSON: %v", err))
+		}
+		if len(vector) == 0 {
+			exitJSON("error", "--vector must not be empty")
+		}
+
+		// Require text — a memory without text is a ghost that pollutes
+		// retrieval results with no displayable content.
+		if t, isStr := payload["text"].(string); !isStr || t == "" {
+			exitJSON("error", "payload must contain a non-empty \"text\" field")
+		}
+	} else if *text == "" {
+		fmt.Fprintln(os.Stderr, "Error: --text is required (or --image, or --vector for advanced mode)")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	// Coerce typed fields and enforce write policies before touching Qdrant
+	// or Ollama.
+	cfg := loadConfig()
+	if err := store.NormalizeType(payload, cfg.MemoryTypes()); err != nil {
+		exitError(err)
+	}
+	if err := store.CoerceFields(cfg.Fields, payload); err != nil {
+		exitError(err)
+	}
+	enforcePolicy(cfg.Policy, payload)
+
+	s, ctx, cancel := connect()
+	defer cancel()
+	defer s.Close()
+
+	if *id != "" {
+		refuseLocked(ctx, s, *id)
+	}
+	// Linking writes to the linked memories too.
+	for _, target := range links.Targets() {
+		refuseLocked(ctx, s, target)
+	}
+	// AddLinked checks the precondition again when writing; checking it now
+	// keeps a conflict from merging duplicates away first.
+	checkSuperseded(ctx, s, links, pre)
+
+	if vector == nil {
+		// Default text mode: embed via Ollama, then store
+		vec, source, err := embedder.EmbedSource(ctx, newEmbedder(), globalModel, *text)
+		if err != nil {
+			exitError(fmt.Errorf("embedding failed: %w", err))
+		}
+		vector = vec
+		setEmbeddedBy(payload, source)
+	}
+	guardEmbedding(ctx, s, vector, *vectorJSON == "")
+
+	var similar []store.Result
+	if *verbose {
+		similar = similarMemories(ctx, s, vector)
+	}
+	if *dryRun {
+		result, merged := previewAdd(ctx, s, *id, vector, payload, pre, links, !*noMerge)
+		if *verbose {
+			result["dedup"] = explainDedup(similar, merged, payload, *id, links, *noMerge)
+		}
+		outputJSON(result)
+		return
+	}
+
+	// Dedup: search for similar memories and merge if found
+	var merged []store.Result
+	if !*noMerge {
+		merged = dedupAndDelete(ctx, s, vector, payload, append(links.Targets(), *id)...)
+	}
+	inherited := inheritFromMerged(payload, merged)
+	released := releaseAlias(ctx, s, payload, *id)
+
+	pointID := writeMemory(ctx, s, *id, vector, payload, pre, links)
+
+	ensureFieldIndexes(ctx, s, cfg.Fields)
+	invalidateCache(payload)
+
+	result := map[string]any{
+		"status":            "ok",
+		"id":                pointID,
+		store.RevisionField: payload[store.RevisionField],
+	}
+	addAliasResult(result, payload, released)
+	addLinksResult(result, links)
+	if next, ok := payload[store.RemindNextField]; ok {
+		result[store.RemindNextField] = next
+	}
+	if *imagePath != "" {
+		result["image"] = payload["image"]
+		result["caption"] = *text
+	}
+	if len(merged) > 0 {
+		result["merged_ids"] = mergedIDs(merged)
+		// Backward compat: merged_id is the first (most similar) duplicate
+		result["merged_id"] = merged[0].ID
+		result["merged"] = mergedScores(merged)
+		result["dedup_threshold"] = dedupThreshold
+	}
+	if *verbose {
+		result["dedup"] = explainDedup(similar, merged, payload, *id, links, *noMerge)
+		if len(inherited) > 0 {
+			result["inherited"] = inherited
+		}
+		// The payload as stored, timestamps included.
+		result["payload"] = payload
+	}
+	outputJSON(result)
+}
+
+// captionTimeout bounds captioning an image: vision models are much slower
+// than embedding, especially on CPU.
+const captionTimeout = 2 * time.Minute
+
+// batchDefaults are the add flags that apply to every memory in a
+// --batch-file. A memory's own payload wins over them, except that tags are
+// combined.
+type batchDefaults struct {
+	pinned, noMerge, dryRun               bool
+	tags                                  []string
+	memType, author, speaker, sensitivity string
+}
+
+// batchMemory is one line of an add --batch-file: the text (or a payload
+// with one), and optionally a pre-computed vector and a new memory's ID.
+type batchMemory struct {
+	Text    string         `json:"text"`
+	Vector  []float32      `json:"vector"`
+	ID      string         `json:"id"`
+	Payload map[string]any `json:"payload"`
+}
+
+// addBatchSize is how many memories go to Qdrant per upsert.
+const addBatchSize = 256
+
+// readBatchMemories parses an add --batch-file into payloads ready to store,
+// exiting on the first bad line. Every payload is normalized and checked
+// against the write policies here, so a bad line fails the batch before
+// anything is written.
+func readBatchMemories(path string, d batchDefaults, cfg *config.Config) []batchMemory {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			exitError(err)
+		}
+		defer f.Close()
+		r = f
+	}
+	var memories []batchMemory
+	ids := make(map[string]bool)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		fail := func(msg string) {
+			exitJSON("error", fmt.Sprintf("%s line %d: %s", path, line, msg))
+		}
+		var m batchMemory
+		if err := json.Unmarshal([]byte(text), &m); err != nil {
+			fail(err.Error())
+		}
+		if m.Payload == nil {
+			m.Payload = make(map[string]any)
+		}
+		p := m.Payload
+		if m.Text != "" {
+			p["text"] = m.Text
+		}
+		if m.ID != "" {
+			if err := store.ValidateID(m.ID); err != nil {
+				fail(err.Error())
+			}
+			if ids[m.ID] {
+				fail(fmt.Sprintf("memory %s appears twice", m.ID))
+			}
+			ids[m.ID] = true
+		}
+		if _, ok := p["alias"]; ok {
+			fail("aliases aren't supported in a batch; use add --alias")
+		}
+
+		if d.pinned {
+			p["pinned"] = true
+		}
+		if len(d.tags) > 0 {
+			p["tags"] = store.TagsValue(store.WithTags(store.Tags(p), d.tags...))
+		}
+		for field, value := range map[string]string{
+			store.TypeField:        d.memType,
+			store.AuthorField:      d.author,
+			store.SpeakerField:     d.speaker,
+			store.SensitivityField: d.sensitivity,
+		} {
+			if _, ok := p[field]; !ok && value != "" {
+				p[field] = value
+			}
+		}
+		if err := store.NormalizeAttribution(p); err != nil {
+			fail(err.Error())
+		}
+		if err := store.NormalizeSensitivity(p); err != nil {
+			fail(err.Error())
+		}
+		if err := store.NormalizeType(p, cfg.MemoryTypes()); err != nil {
+			fail(err.Error())
+		}
+		if decision.Is(p) {
+			if err := decision.Validate(p); err != nil {
+				fail(err.Error())
+			}
+			if t, _ := p["text"].(string); strings.TrimSpace(t) == "" {
+				p["text"] = decision.Card(p)
+			}
+		}
+		if t, _ := p["text"].(string); strings.TrimSpace(t) == "" {
+			fail("a non-empty \"text\" is required")
+		}
+		if err := store.CoerceFields(cfg.Fields, p); err != nil {
+			fail(err.Error())
+		}
+		if violations := cfg.Policy.Evaluate(p); len(violations) > 0 {
+			outputJSON(map[string]any{
+				"status":     "rejected",
+				"message":    fmt.Sprintf("%s line %d: write rejected by policy: %d violation(s)", path, line, len(violations)),
+				"line":       line,
+				"violations": violations,
+			})
+			os.Exit(1)
+		}
+		memories = append(memories, m)
+	}
+	if err := scanner.Err(); err != nil {
+		exitError(err)
+	}
+	if len(memories) == 0 {
+		exitJSON("error", fmt.Sprintf("%s has no memories", path))
+	}
+	return memories
+}
+
+// runAddBatch stores every memory in an add --batch-file. Texts are embedded
+// batchEmbedSize per Ollama request and the memories are written
+// addBatchSize per upsert, instead of one round trip each. Each memory is
+// still deduplicated against the stored ones unless --no-merge is given,
+// but not against the rest of the batch. IDs given in the file must be new.
+func runAddBatch(path string, d batchDefaults) {
+	cfg := loadConfig()
+	memories := readBatchMemories(path, d, cfg)
+
+	s, err := openStore()
+	if err != nil {
+		exitError(err)
+	}
+	defer s.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), batchSearchTimeout)
+	defer cancel()
+
+	for _, m := range memories {
+		if m.ID == "" {
 			continue
 		}
+		existing, _, err := peekTiers(ctx, s, m.ID)
+		if err != nil {
+			exitError(err)
+		}
+		if existing != nil {
+			exitJSON("error", fmt.Sprintf("memory %s already exists; a batch only adds new memories (rewrite it with add --id)", m.ID))
+		}
+	}
+
+	var pending []int
+	for i, m := range memories {
+		if len(m.Vector) == 0 {
+			pending = append(pending, i)
+		}
+	}
+	emb := newEmbedder()
+	for start := 0; start < len(pending); start += batchEmbedSize {
+		chunk := pending[start:min(start+batchEmbedSize, len(pending))]
+		texts := make([]string, len(chunk))
+		for j, i := range chunk {
+			texts[j] = memories[i].Payload["text"].(string)
+		}
+		vectors, source, err := embedder.EmbedBatchSource(ctx, emb, globalModel, texts)
+		if err != nil {
+			exitError(fmt.Errorf("embedding failed: %w", err))
+		}
+		for j, i := range chunk {
+			memories[i].Vector = vectors[j]
+			setEmbeddedBy(memories[i].Payload, source)
+		}
+	}
+	for i, m := range memories {
+		guardEmbedding(ctx, s, m.Vector, slices.Contains(pending, i))
+	}
+
+	if d.dryRun {
+		listed := make([]map[string]any, len(memories))
+		for i, m := range memories {
+			entry := map[string]any{"payload": m.Payload}
+			if m.ID != "" {
+				entry["id"] = m.ID
+			}
+			if !d.noMerge {
+				dups := findDuplicates(ctx, s, m.Vector, m.Payload)
+				inheritFromMerged(m.Payload, dups)
+				if len(dups) > 0 {
+					entry["would_merge"] = mergedIDs(dups)
+				}
+			}
+			listed[i] = entry
+		}
+		outputJSON(map[string]any{
+			"status":    "ok",
+			"dry_run":   true,
+			"would_add": len(memories),
+			"memories":  listed,
+		})
+		return
+	}
+
+	var merged []store.Result
+	points := make([]store.Point, len(memories))
+	payloads := make([]map[string]any, len(memories))
+	for i, m := range memories {
+		if !d.noMerge {
+			dups := dedupAndDelete(ctx, s, m.Vector, m.Payload)
+			inheritFromMerged(m.Payload, dups)
+			merged = append(merged, dups...)
+		}
+		points[i] = store.Point{ID: m.ID, Vector: m.Vector, Payload: m.Payload}
+		payloads[i] = m.Payload
+	}
+
+	ids := make([]string, 0, len(points))
+	for start := 0; start < len(points); start += addBatchSize {
+		added, err := s.AddBatch(ctx, points[start:min(start+addBatchSize, len(points))])
+		if err != nil {
+			exitJSON("error", fmt.Sprintf("%v (%d of %d memories stored)", err, len(ids), len(points)))
+		}
+		ids = append(ids, added...)
+	}
+
+	ensureFieldIndexes(ctx, s, cfg.Fields)
+	invalidateCache(payloads...)
+
+	result := map[string]any{
+		"status": "ok",
+		"added":  len(ids),
+		"ids":    ids,
+	}
+	if len(merged) > 0 {
+		result["merged_ids"] = mergedIDs(merged)
+		result["merged"] = mergedScores(merged)
+		result["dedup_threshold"] = dedupThreshold
+	}
+	outputJSON(result)
+}
+
+// describeImage captions an image with the vision model, exiting on failure.
+func describeImage(img *vision.Image) string {
+	ctx, cancel := context.WithTimeout(context.Background(), captionTimeout)
+	defer cancel()
+	caption, err := ollama.New(globalOllamaURL).Describe(ctx, globalVisionModel, vision.CaptionPrompt, img.Data)
+	if err != nil {
+		exitJSON("error", fmt.Sprintf("captioning image failed: %v", err))
+	}
+	caption = strings.TrimSpace(caption)
+	if caption == "" {
+		exitJSON("error", "captioning image failed: the vision model returned an empty caption")
+	}
+	return caption
+}
+
+// dedupAndDelete looks for all existing memories above the dedup threshold
+// of the same type whose text says the same as payload's (see sameFact). It
+// deletes every
+// duplicate found and returns the full list so the caller can preserve the
+// oldest created_at. Returns nil when no duplicates are found. Memories
+// listed in keep -- the one being rewritten, and any the new memory links
+// to -- are never deleted as duplicates.
+func dedupAndDelete(ctx context.Context, s *store.Store, vector []float32, payload map[string]any, keep ...string) []store.Result {
+	return deleteDuplicates(ctx, s, findDuplicates(ctx, s, vector, payload, keep...))
+}
+
+// deleteDuplicates deletes the duplicates found by findDuplicates and
+// returns those actually deleted.
+func deleteDuplicates(ctx context.Context, s *store.Store, dups []store.Result) []store.Result {
+	var deleted []store.Result
+	for _, old := range dups {
 		if err := s.Delete(ctx, old.ID); err != nil {
 			// Non-fatal: skip this one, keep trying the rest.
 			continue
 		}
 		deleted = append(deleted, old)
 	}
-	if len(deleted) == 0 {
-		return nil
+	if len(deleted) == 0 {
+		return nil
+	}
+
+	return deleted
+}
+
+// findDuplicates returns the memories dedupAndDelete would delete, without
+// deleting them: those above the dedup threshold of the same type whose
+// text says the same as payload's, except pinned and locked ones and those
+// listed in keep.
+func findDuplicates(ctx context.Context, s *store.Store, vector []float32, payload map[string]any, keep ...string) []store.Result {
+	similar, err := s.FindSimilar(ctx, vector, dedupThreshold, 64)
+	if err != nil {
+		// Non-fatal: if dedup search fails, just proceed with a normal add.
+		return nil
+	}
+
+	var dups []store.Result
+	for _, old := range similar {
+		if slices.Contains(keep, old.ID) {
+			continue
+		}
+		if pinned, ok := old.Payload["pinned"].(bool); ok && pinned {
+			// Pinned memories are immune to automatic deletion, including dedup.
+			continue
+		}
+		if store.IsLocked(old.Payload) {
+			continue
+		}
+		if !sameType(payload, old) || !sameFact(payloadText(payload), old) {
+			continue
+		}
+		dups = append(dups, old)
+	}
+	return dups
+}
+
+// minDedupOverlap is the least share of their words (see textnorm.Overlap)
+// a memory and its duplicate must have in common. Short sentences about
+// different things can embed 0.92 apart; rewordings of one fact share
+// most of their words.
+const minDedupOverlap = 0.5
+
+// sameFact double-checks at the text level that old, close enough to text
+// to merge, says the same thing: the two must share minDedupOverlap of
+// their words and hold the same numbers, so "the api listens on port 8080"
+// doesn't replace "the admin ui listens on port 9090". A memory without
+// text, e.g. one added by vector alone, has only its vector to go on and
+// always passes.
+func sameFact(text string, old store.Result) bool {
+	oldText := payloadText(old.Payload)
+	if strings.TrimSpace(text) == "" || strings.TrimSpace(oldText) == "" {
+		return true
+	}
+	return slices.Equal(textnorm.Numbers(text), textnorm.Numbers(oldText)) &&
+		textnorm.Overlap(text, oldText) >= minDedupOverlap
+}
+
+// sameType reports whether old has the type of the memory with payload, so
+// a todo isn't merged into a fact that reads the same. Untyped memories
+// only merge with untyped ones.
+func sameType(payload map[string]any, old store.Result) bool {
+	t, _ := payload[store.TypeField].(string)
+	oldType, _ := old.Payload[store.TypeField].(string)
+	return t == oldType
+}
+
+// payloadText returns the text of a memory's payload, or "" if it has none.
+func payloadText(payload map[string]any) string {
+	text, _ := payload["text"].(string)
+	return text
+}
+
+// previewAdd reports what add would do with the memory, without writing
+// anything: whether it creates a memory or rewrites one, the duplicates it
+// would merge and the fields it would inherit from them, the memories the
+// alias would move from, and the payload it would store. It fails the same
+// way the add would, so a clean preview means the write is expected to
+// succeed. The duplicates are returned too.
+func previewAdd(ctx context.Context, s *store.Store, id string, vector []float32, payload map[string]any, pre store.Precondition, links store.Links, merge bool) (map[string]any, []store.Result) {
+	var merged []store.Result
+	if merge {
+		merged = findDuplicates(ctx, s, vector, payload, append(links.Targets(), id)...)
+	}
+	inherited := inheritFromMerged(payload, merged)
+
+	result := map[string]any{
+		"status":  "ok",
+		"dry_run": true,
+		"action":  "create",
+		"payload": payload,
+	}
+	if id != "" {
+		result["id"] = id
+		existing, _, err := peekTiers(ctx, s, id)
+		if err != nil {
+			exitError(err)
+		}
+		switch {
+		case existing != nil && !links.Empty():
+			exitJSON("error", fmt.Sprintf("memory %s already exists; links can only be added with a new memory", id))
+		case existing != nil || (!pre.Empty() && links.Empty()):
+			var current map[string]any
+			if existing != nil {
+				current = existing.Payload
+			}
+			if err := pre.Check(id, current); err != nil {
+				exitWriteError(err)
+			}
+			if existing == nil {
+				exitJSON("error", fmt.Sprintf("memory %s not found", id))
+			}
+			result["action"] = "update"
+			result["before"] = current
+			result[store.RevisionField] = store.Revision(current) + 1
+		}
+	}
+	checkSuperseded(ctx, s, links, pre)
+	for _, target := range links.Targets() {
+		existing, _, err := peekTiers(ctx, s, target)
+		if err != nil {
+			exitError(err)
+		}
+		if existing == nil {
+			exitJSON("error", fmt.Sprintf("linked memory %s not found", target))
+		}
+	}
+	addLinksResult(result, links)
+
+	if alias, ok := payload["alias"].(string); ok && alias != "" {
+		result["alias"] = alias
+		holder, err := s.ResolveAlias(ctx, alias)
+		if err != nil {
+			exitError(err)
+		}
+		if holder != "" && holder != id {
+			result["alias_moved_from"] = []string{holder}
+		}
+	}
+	if len(merged) > 0 {
+		would := make([]map[string]any, len(merged))
+		for i, r := range merged {
+			would[i] = map[string]any{"id": r.ID, "score": r.Score, "text": r.Payload["text"]}
+		}
+		result["would_merge"] = would
+	}
+	if len(inherited) > 0 {
+		result["inherited"] = inherited
+	}
+	return result, merged
+}
+
+// explainCandidates is how many of the most similar memories add --verbose
+// reports, whether or not they were close enough to merge.
+const explainCandidates = 5
+
+// dedupDecision is what add did with one similar memory, for add --verbose.
+type dedupDecision struct {
+	ID    string  `json:"id"`
+	Score float32 `json:"score"`
+	Text  any     `json:"text"`
+	// Decision is "merged", or why not: "below_threshold", "no_merge",
+	// "rewritten" (the memory add --id replaces), "linked", "pinned",
+	// "locked", "different_type", "text_differs" (see sameFact) or
+	// "delete_failed".
+	Decision string `json:"decision"`
+}
+
+// similarMemories returns the memories most similar to vector, however
+// similar, for explaining deduplication.
+func similarMemories(ctx context.Context, s *store.Store, vector []float32) []store.Result {
+	similar, err := s.FindSimilar(ctx, vector, 0, explainCandidates)
+	if err != nil {
+		// Like dedup itself, the explanation is best effort.
+		return nil
+	}
+	return similar
+}
+
+// explainDedup reports the dedup decision for each similar memory, plus any
+// merged one outside the most similar few, along with the threshold used.
+func explainDedup(similar, merged []store.Result, payload map[string]any, id string, links store.Links, noMerge bool) map[string]any {
+	isMerged := make(map[string]bool, len(merged))
+	for _, r := range merged {
+		isMerged[r.ID] = true
+	}
+	candidates := []dedupDecision{}
+	seen := make(map[string]bool)
+	for _, r := range append(slices.Clone(similar), merged...) {
+		if seen[r.ID] {
+			continue
+		}
+		seen[r.ID] = true
+		d := dedupDecision{ID: r.ID, Score: r.Score, Text: r.Payload["text"]}
+		switch {
+		case isMerged[r.ID]:
+			d.Decision = "merged"
+		case noMerge:
+			d.Decision = "no_merge"
+		case r.Score < dedupThreshold:
+			d.Decision = "below_threshold"
+		case r.ID == id:
+			d.Decision = "rewritten"
+		case slices.Contains(links.Targets(), r.ID):
+			d.Decision = "linked"
+		case r.Payload["pinned"] == true:
+			d.Decision = "pinned"
+		case store.IsLocked(r.Payload):
+			d.Decision = "locked"
+		case !sameType(payload, r):
+			d.Decision = "different_type"
+		case !sameFact(payloadText(payload), r):
+			d.Decision = "text_differs"
+		default:
+			d.Decision = "delete_failed"
+		}
+		candidates = append(candidates, d)
+	}
+	return map[string]any{
+		"threshold":  dedupThreshold,
+		"candidates": candidates,
+	}
+}
+
+// parsePrecondition builds the write precondition from the --if-version and
+// --if-last-accessed-before flags, exiting on a malformed time.
+func parsePrecondition(fs *flag.FlagSet, ifVersion int64, ifLastAccessedBefore string) store.Precondition {
+	var pre store.Precondition
+	if flagSet(fs, "if-version") {
+		if ifVersion < 0 {
+			exitJSON("error", "--if-version must be non-negative")
+		}
+		pre.Revision = &ifVersion
+	}
+	if ifLastAccessedBefore != "" {
+		t, err := time.Parse(time.RFC3339Nano, ifLastAccessedBefore)
+		if err != nil {
+			exitJSON("error", fmt.Sprintf("invalid --if-last-accessed-before %q: want an RFC 3339 time", ifLastAccessedBefore))
+		}
+		pre.LastAccessedBefore = t
+	}
+	return pre
+}
+
+// parseLinks parses --relate and --supersedes, exiting on an invalid one.
+func parseLinks(relate, supersedes []string) store.Links {
+	var links store.Links
+	for _, spec := range relate {
+		r, err := store.ParseRelation(spec)
+		if err != nil {
+			exitError(err)
+		}
+		links.Relations = append(links.Relations, r)
+	}
+	for _, id := range supersedes {
+		if err := store.ValidateID(id); err != nil {
+			exitJSON("error", fmt.Sprintf("--supersedes: %v", err))
+		}
+		links.Supersedes = append(links.Supersedes, id)
+	}
+	return links
+}
+
+// addLinksResult reports the links made on an add response.
+func addLinksResult(result map[string]any, links store.Links) {
+	if len(links.Relations) > 0 {
+		result["relations"] = links.Relations
+	}
+	if len(links.Supersedes) > 0 {
+		result["supersedes"] = links.Supersedes
+	}
+}
+
+// writeMemory stores the memory and returns its ID. When id names an
+// existing memory -- or a precondition is given, which only makes sense for
+// one -- the memory is rewritten in place under the precondition instead.
+// Links are written together with a new memory, or not at all.
+func writeMemory(ctx context.Context, s *store.Store, id string, vector []float32, payload map[string]any, pre store.Precondition, links store.Links) string {
+	if id != "" && links.Empty() {
+		warm(ctx, s, id)
+		existing, err := s.Peek(ctx, id)
+		if err != nil {
+			exitError(err)
+		}
+		if existing != nil || !pre.Empty() {
+			if err := s.Update(ctx, id, vector, payload, pre); err != nil {
+				exitWriteError(err)
+			}
+			return id
+		}
+	}
+	pointID, err := s.AddLinked(ctx, id, vector, payload, links, pre)
+	if err != nil {
+		exitWriteError(err)
+	}
+	return pointID
+}
+
+// checkSuperseded exits with a conflict if a memory the add supersedes
+// doesn't satisfy the precondition.
+func checkSuperseded(ctx context.Context, s *store.Store, links store.Links, pre store.Precondition) {
+	if pre.Empty() {
+		return
+	}
+	for _, target := range links.Supersedes {
+		existing, _, err := peekTiers(ctx, s, target)
+		if err != nil {
+			exitError(err)
+		}
+		var current map[string]any
+		if existing != nil {
+			current = existing.Payload
+		}
+		if err := pre.Check(target, current); err != nil {
+			exitWriteError(err)
+		}
+	}
+}
+
+// updateReserved lists payload fields update manages itself, or that only
+// their own commands change (locked, by lock and unlock); a --payload patch
+// can't set them.
+var updateReserved = []string{"created_at", "last_accessed", store.RevisionField, store.AccessCountField, store.AgentField, "locked"}
+
+// runUpdate edits a memory in place: new text is re-embedded, and --payload
+// is merged into the existing payload, a null removing a field. Everything
+// else -- created_at, pinned, tags, alias -- is kept, and the revision is
+// bumped.
+func runUpdate(args []string) {
+	fs := flag.NewFlagSet("update", flag.ExitOnError)
+	id := fs.String("id", "", "UUID of the memory to update")
+	alias := fs.String("alias", "", "Alias of the memory to update (alternative to --id)")
+	text := fs.String("text", "", "New text for the memory; it is re-embedded")
+	payloadJSON := fs.String("payload", "", "JSON object merged into the payload; a null value removes that field")
+	ifVersion := fs.Int64("if-version", 0, "Only update the memory if it is still at this revision")
+	ifLastAccessedBefore := fs.String("if-last-accessed-before", "", "Only update the memory if nobody has touched it since this RFC 3339 time")
+	dryRun := fs.Bool("dry-run", false, "Report the payload that would be stored without writing anything")
+	fs.Parse(args)
+
+	if *id == "" && *alias == "" {
+		fmt.Fprintln(os.Stderr, "Error: --id or --alias is required")
+		fs.Usage()
+		os.Exit(1)
+	}
+	if *id != "" && *alias != "" {
+		exitJSON("error", "--id and --alias are mutually exclusive")
+	}
+	if *id != "" {
+		if err := store.ValidateID(*id); err != nil {
+			exitError(err)
+		}
+	}
+	if flagSet(fs, "text") && strings.TrimSpace(*text) == "" {
+		exitJSON("error", "--text must not be empty")
+	}
+	var patch map[string]any
+	if *payloadJSON != "" {
+		if err := json.Unmarshal([]byte(*payloadJSON), &patch); err != nil {
+			exitJSON("error", fmt.Sprintf("invalid payload JSON: %v", err))
+		}
+		for _, key := range updateReserved {
+			if _, ok := patch[key]; ok && key == "locked" {
+				exitJSON("error", "--payload can't set \"locked\"; use lock or unlock")
+			} else if ok {
+				exitJSON("error", fmt.Sprintf("--payload can't set %q; update manages it", key))
+			}
+		}
+		if v, ok := patch["text"]; ok {
+			if t, isStr := v.(string); !isStr || strings.TrimSpace(t) == "" {
+				exitJSON("error", "payload \"text\" must be a non-empty string; use --text to change it")
+			}
+		}
+	}
+	if *text == "" && len(patch) == 0 {
+		exitJSON("error", "nothing to update: give --text and/or --payload")
+	}
+	pre := parsePrecondition(fs, *ifVersion, *ifLastAccessedBefore)
+
+	s, ctx, cancel := connect()
+	defer cancel()
+	defer s.Close()
+
+	if *alias != "" {
+		*id = resolveAlias(ctx, s, *alias)
+	}
+	if !*dryRun {
+		warm(ctx, s, *id)
+	}
+	existing, err := peekPointTiers(ctx, s, *id)
+	if err != nil {
+		exitError(err)
+	}
+	if existing == nil {
+		exitJSON("error", fmt.Sprintf("memory %s not found", *id))
+	}
+	if store.IsLocked(existing.Payload) {
+		exitJSON("error", fmt.Sprintf("memory %s is locked; unlock it first", *id))
+	}
+
+	oldText, _ := existing.Payload["text"].(string)
+	revision := store.Revision(existing.Payload)
+	if err := pre.Check(*id, existing.Payload); err != nil {
+		exitWriteError(err)
+	}
+	payload := existing.Payload
+	for key, value := range patch {
+		if value == nil {
+			delete(payload, key)
+		} else {
+			payload[key] = value
+		}
+	}
+	if *text != "" {
+		payload["text"] = *text
+	}
+	if t, _ := payload["text"].(string); t == "" {
+		exitJSON("error", "payload must keep a non-empty \"text\" field")
+	}
+	cfg := loadConfig()
+	if err := store.NormalizeAttribution(payload); err != nil {
+		exitError(err)
+	}
+	if err := store.NormalizeSensitivity(payload); err != nil {
+		exitError(err)
+	}
+	if err := store.NormalizeType(payload, cfg.MemoryTypes()); err != nil {
+		exitError(err)
+	}
+	if decision.Is(payload) {
+		if err := decision.Validate(payload); err != nil {
+			exitError(err)
+		}
+	}
+	if err := store.CoerceFields(cfg.Fields, payload); err != nil {
+		exitError(err)
+	}
+	enforcePolicy(cfg.Policy, payload)
+
+	// Only changed text needs a new embedding; a payload edit keeps the
+	// stored vector.
+	vector := existing.Vector
+	reembedded := payload["text"] != oldText
+	if reembedded {
+		var source string
+		vector, source, err = embedder.EmbedSource(ctx, newEmbedder(), globalModel, payload["text"].(string))
+		if err != nil {
+			exitError(fmt.Errorf("embedding failed: %w", err))
+		}
+		guardEmbedding(ctx, s, vector, true)
+		setEmbeddedBy(payload, source)
+	}
+
+	if *dryRun {
+		outputJSON(map[string]any{
+			"status":            "ok",
+			"dry_run":           true,
+			"id":                *id,
+			store.RevisionField: revision + 1,
+			"reembedded":        reembedded,
+			"payload":           payload,
+		})
+		return
+	}
+
+	released := releaseAlias(ctx, s, payload, *id)
+	if err := s.Update(ctx, *id, vector, payload, pre); err != nil {
+		exitWriteError(err)
+	}
+	ensureFieldIndexes(ctx, s, cfg.Fields)
+	invalidateCache(payload)
+
+	result := map[string]any{
+		"status":            "ok",
+		"id":                *id,
+		store.RevisionField: payload[store.RevisionField],
+		"reembedded":        reembedded,
+	}
+	addAliasResult(result, payload, released)
+	outputJSON(result)
+}
+
+// exitWriteError reports a failed write. A failed precondition gets status
+// "conflict" and the memory's current revision, so the caller can re-read it
+// and retry rather than parse the message.
+func exitWriteError(err error) {
+	var conflict *store.ConflictError
+	if !errors.As(err, &conflict) {
+		exitError(err)
+	}
+	outputJSON(map[string]any{
+		"status":   "conflict",
+		"message":  err.Error(),
+		"conflict": conflict,
+	})
+	os.Exit(1)
+}
+
+// enforcePolicy rejects the write with structured reasons if the payload
+// violates any configured write policy.
+func enforcePolicy(p policy.Policy, payload map[string]any) {
+	violations := p.Evaluate(payload)
+	if len(violations) == 0 {
+		return
+	}
+	outputJSON(map[string]any{
+		"status":     "rejected",
+		"message":    fmt.Sprintf("write rejected by policy: %d violation(s)", len(violations)),
+		"violations": violations,
+	})
+	os.Exit(1)
+}
+
+// inheritFromMerged carries identity from merged duplicates onto the payload
+// replacing them: the oldest created_at, their recalls added up into
+// access_count, and an alias or reminder if the new memory doesn't set its
+// own — otherwise merging would silently drop them. Returns the fields it
+// set.
+func inheritFromMerged(payload map[string]any, merged []store.Result) []string {
+	if len(merged) == 0 {
+		return nil
+	}
+	var inherited []string
+	if ca := oldestCreatedAt(merged); ca != "" {
+		payload["created_at"] = ca
+		inherited = append(inherited, "created_at")
+	}
+	if _, ok := payload[store.AccessCountField]; !ok {
+		var recalls int64
+		for _, r := range merged {
+			recalls += store.AccessCount(r.Payload)
+		}
+		if recalls > 0 {
+			payload[store.AccessCountField] = recalls
+			inherited = append(inherited, store.AccessCountField)
+		}
+	}
+	if _, ok := payload["alias"]; !ok {
+		for _, r := range merged {
+			if a, ok := r.Payload["alias"].(string); ok && a != "" {
+				payload["alias"] = a
+				inherited = append(inherited, "alias")
+				break
+			}
+		}
+	}
+	if _, ok := payload["remind"]; !ok {
+		for _, r := range merged {
+			if next, ok := r.Payload[store.RemindNextField].(string); ok && next != "" {
+				payload["remind"] = r.Payload["remind"]
+				payload[store.RemindNextField] = next
+				inherited = append(inherited, "remind", store.RemindNextField)
+				break
+			}
+		}
+	}
+	return inherited
+}
+
+// releaseAlias frees the payload's alias (if any) from whichever memories
+// currently hold it, so the alias points only at the memory about to be
+// stored. Returns the IDs the alias moved away from, excluding id itself.
+func releaseAlias(ctx context.Context, s *store.Store, payload map[string]any, id string) []string {
+	alias, ok := payload["alias"].(string)
+	if !ok || alias == "" {
+		return nil
+	}
+	if err := store.ValidateAlias(alias); err != nil {
+		exitError(err)
+	}
+	// Taking the alias away from a locked memory would mutate it.
+	holder, err := s.ResolveAlias(ctx, alias)
+	if err != nil {
+		exitError(err)
+	}
+	if holder != "" && holder != id {
+		existing, err := s.Peek(ctx, holder)
+		if err != nil {
+			exitError(err)
+		}
+		if existing != nil && store.IsLocked(existing.Payload) {
+			exitJSON("error", fmt.Sprintf("alias %s is held by locked memory %s", alias, holder))
+		}
+	}
+	ids, err := s.ReleaseAlias(ctx, alias)
+	if err != nil {
+		exitError(err)
+	}
+	var moved []string
+	for _, old := range ids {
+		if old != id {
+			moved = append(moved, old)
+		}
+	}
+	return moved
+}
+
+// addAliasResult reports the stored alias, and where it moved from, on an
+// add response.
+func addAliasResult(result map[string]any, payload map[string]any, moved []string) {
+	alias, ok := payload["alias"].(string)
+	if !ok || alias == "" {
+		return
+	}
+	result["alias"] = alias
+	if len(moved) > 0 {
+		result["alias_moved_from"] = moved
+	}
+}
+
+// oldestCreatedAt returns the earliest created_at timestamp from a set of
+// merged results. Returns "" if no valid created_at is found.
+func oldestCreatedAt(results []store.Result) string {
+	oldest := ""
+	for _, r := range results {
+		if ca, ok := r.Payload["created_at"].(string); ok {
+			if oldest == "" || ca < oldest {
+				oldest = ca
+			}
+		}
+	}
+	return oldest
+}
+
+// mergedIDs extracts the point IDs from a set of merged results.
+func mergedIDs(results []store.Result) []string {
+	ids := make([]string, len(results))
+	for i, r := range results {
+		ids[i] = r.ID
+	}
+	return ids
+}
+
+// multiFlag implements flag.Value to allow repeated flags (e.g. --file a --file b).
+type multiFlag []string
+
+func (f *multiFlag) String() string { return strings.Join(*f, ", ") }
+func (f *multiFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// driftField is the payload field provenance sets on a synced memory whose
+// text has moved or changed in its source file (or whose file is gone),
+// holding the drift status. It is removed once the source matches again.
+const driftField = "drift"
+
+// driftMissing is the drift status of a memory whose source file is gone.
+const driftMissing = "missing"
+
+// payloadLine returns a line number sync recorded in a payload, or 0.
+func payloadLine(payload map[string]any, field string) int {
+	switch v := payload[field].(type) {
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	}
+	return 0
+}
+
+func runProvenance(args []string) {
+	fs := flag.NewFlagSet("provenance", flag.ExitOnError)
+	id := fs.String("id", "", "UUID of the synced memory to check")
+	fs.Parse(args)
+
+	if *id == "" {
+		fmt.Fprintln(os.Stderr, "Error: --id is required")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	s, ctx, cancel := connect()
+	defer cancel()
+	defer s.Close()
+
+	// Checking where a memory came from isn't recalling it.
+	memory, _, err := peekTiers(ctx, s, *id)
+	if err != nil {
+		exitError(err)
+	}
+	if memory == nil {
+		exitJSON("error", fmt.Sprintf("memory %s not found", *id))
+	}
+	source, _ := memory.Payload["source"].(string)
+	if source == "" {
+		exitJSON("error", fmt.Sprintf("memory %s has no source file; only synced memories can be checked", *id))
+	}
+	text := chunkText(memory.Payload)
+	start, end := payloadLine(memory.Payload, "start_line"), payloadLine(memory.Payload, "end_line")
+
+	result := map[string]any{
+		"status": "ok",
+		"id":     *id,
+		"source": source,
+	}
+	if start > 0 {
+		result["recorded_lines"] = []int{start, end}
+	}
+	drift := driftMissing
+	content, err := readSource(ctx, source)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		exitError(err)
+	default:
+		p := sync.Verify(content, text, start, end)
+		drift = p.Status
+		if p.StartLine > 0 {
+			result["current_lines"] = []int{p.StartLine, p.EndLine}
+		}
+		if p.Current != "" {
+			result["current_text"] = p.Current
+		}
+	}
+	result["provenance"] = drift
+	result["drifted"] = drift != sync.DriftUnchanged
+
+	// Flag drifted memories so they can be found and refreshed; clear the
+	// flag once the source matches again.
+	if drift == sync.DriftUnchanged {
+		if _, flagged := memory.Payload[driftField]; flagged {
+			err = s.DeletePayloadKeys(ctx, []string{*id}, driftField)
+		}
+	} else if memory.Payload[driftField] != drift {
+		err = s.SetPayloads(ctx, map[string]map[string]any{*id: {driftField: drift}})
+	}
+	if err != nil {
+		exitError(err)
+	}
+	outputJSON(result)
+}
+
+func runRefresh(args []string) {
+	fs := flag.NewFlagSet("refresh", flag.ExitOnError)
+	id := fs.String("id", "", "UUID of the synced memory to refresh")
+	dryRun := fs.Bool("dry-run", false, "Report what would change without updating the memory")
+	fs.Parse(args)
+
+	if *id == "" {
+		fmt.Fprintln(os.Stderr, "Error: --id is required")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	s, ctx, cancel := connect()
+	defer cancel()
+	defer s.Close()
+
+	if !*dryRun {
+		warm(ctx, s, *id)
+	}
+	memory, err := peekPointTiers(ctx, s, *id)
+	if err != nil {
+		exitError(err)
+	}
+	if memory == nil {
+		exitJSON("error", fmt.Sprintf("memory %s not found", *id))
+	}
+	if store.IsLocked(memory.Payload) {
+		exitJSON("error", fmt.Sprintf("memory %s is locked; unlock it first", *id))
+	}
+	source, _ := memory.Payload["source"].(string)
+	if source == "" {
+		exitJSON("error", fmt.Sprintf("memory %s has no source file; only synced memories can be refreshed", *id))
+	}
+	content, err := readSource(ctx, source)
+	if errors.Is(err, os.ErrNotExist) {
+		exitJSON("error", fmt.Sprintf("source file %s is gone; delete the memory, or point it at the file's new place with resource move", source))
+	}
+	if err != nil {
+		exitError(err)
+	}
+	text := chunkText(memory.Payload)
+	start, end := payloadLine(memory.Payload, "start_line"), payloadLine(memory.Payload, "end_line")
+	p := sync.Verify(content, text, start, end)
+	oldPath, _ := memory.Payload[headingPathField].(string)
+	path := ""
+	if sync.FormatFor(source).Markdown {
+		path = sync.HeadingPathAt(content, p.StartLine)
+	}
+
+	result := map[string]any{
+		"status":     "ok",
+		"id":         *id,
+		"source":     source,
+		"provenance": p.Status,
+	}
+	_, flagged := memory.Payload[driftField]
+	if p.Status == sync.DriftUnchanged && start > 0 && path == oldPath {
+		// Nothing to rewrite; just drop a stale drift flag.
+		if flagged && !*dryRun {
+			if err := s.DeletePayloadKeys(ctx, []string{*id}, driftField); err != nil {
+				exitError(err)
+			}
+		}
+		result["refreshed"] = false
+		outputJSON(result)
+		return
+	}
+
+	payload := memory.Payload
+	vector := memory.Vector
+	reembedded := false
+	// Text that changed, or moved under other headings, is embedded again.
+	if p.Status == sync.DriftChanged || path != oldPath {
+		if p.Status == sync.DriftChanged {
+			if start == 0 {
+				exitJSON("error", fmt.Sprintf("memory %s was synced before line numbers were recorded and its text is gone from %s; sync the file again instead", *id, source))
+			}
+			if p.Current == "" {
+				exitJSON("error", fmt.Sprintf("lines %d-%d of %s are empty now; delete the memory, or sync the file again", start, end, source))
+			}
+			text = p.Current
+		}
+		chunk := sync.Turn{Path: path, Text: text}
+		payload["text"] = sync.NormalizeText(chunk.WithPath())
+		delete(payload, headingPathField)
+		if path != "" {
+			payload[headingPathField] = path
+		}
+		var source string
+		vector, source, err = embedder.EmbedSource(ctx, newEmbedder(), globalModel, payload["text"].(string))
+		if err != nil {
+			exitError(fmt.Errorf("embedding failed: %w", err))
+		}
+		guardEmbedding(ctx, s, vector, true)
+		setEmbeddedBy(payload, source)
+		reembedded = true
+	}
+	payload["start_line"] = p.StartLine
+	payload["end_line"] = p.EndLine
+	delete(payload, "heading")
+	delete(payload, "anchor")
+	if heading, anchor := sync.HeadingAt(content, p.StartLine); heading != "" {
+		payload["heading"] = heading
+		payload["anchor"] = anchor
+	}
+	delete(payload, driftField)
+
+	result["lines"] = []int{p.StartLine, p.EndLine}
+	result["reembedded"] = reembedded
+	if reembedded {
+		result["text"] = payload["text"]
+	}
+	if *dryRun {
+		result["dry_run"] = true
+		outputJSON(result)
+		return
+	}
+
+	// Refuse to clobber an edit made since the memory was read.
+	revision := store.Revision(memory.Payload)
+	if err := s.Update(ctx, *id, vector, payload, store.Precondition{Revision: &revision}); err != nil {
+		exitWriteError(err)
+	}
+	invalidateCache(payload)
+	result["refreshed"] = true
+	result[store.RevisionField] = payload[store.RevisionField]
+	outputJSON(result)
+}
+
+func runSync(args []string) {
+	fs := flag.NewFlagSet("sync", flag.ExitOnError)
+	var files multiFlag
+	var dirs multiFlag
+	var excludes multiFlag
+	fs.Var(&files, "file", "Path to a file to ingest (repeatable)")
+	fs.Var(&dirs, "dir", "Path to a directory of files to ingest, of every readable format (repeatable)")
+	fs.Var(&excludes, "exclude", "Glob pattern to exclude from sync (repeatable)")
+	basePath := fs.String("base", ".", "Base path for default file discovery (env: CLAWBRAIN_WORKSPACE)")
+	author := fs.String("author", "", "Author recorded on every synced chunk (e.g. the agent whose notes these are)")
+	threshold := fs.Float64("dedup-threshold", float64(defaultDedupThreshold), "Similarity at or above which an existing memory is merged as a duplicate (env: CLAWBRAIN_DEDUP_THRESHOLD)")
+	dedupScope := fs.String("dedup-scope", dedupScopeGlobal, "Which memories a chunk may merge: global (any) or file (only chunks of the same file)")
+	prune := fs.Bool("prune", false, "Delete the memories of synced files that no longer exist (see unsync)")
+	concurrency := fs.Int("concurrency", sync.DefaultConcurrency, "How many chunks to embed at once")
+	progressFlag := fs.Bool("progress", false, "Write a JSON line per file and chunk as the sync goes, ahead of the result")
+	resume := fs.Bool("resume", false, "Continue the last interrupted sync with its files, skipping those it finished")
+	rate := fs.Float64("rate", 0, "Most chunks to embed per second, across all workers (0 = no limit)")
+	fs.Parse(args)
+
+	setDedupThreshold(fs, *threshold)
+	if *concurrency < 1 {
+		exitJSON("error", "--concurrency must be at least 1")
+	}
+	if *rate < 0 {
+		exitJSON("error", "--rate can't be negative")
+	}
+	if *dedupScope != dedupScopeGlobal && *dedupScope != dedupScopeFile {
+		exitJSON("error", fmt.Sprintf("invalid --dedup-scope %q (want %s or %s)", *dedupScope, dedupScopeGlobal, dedupScopeFile))
+	}
+
+	// Environment variable override for base path
+	if v := os.Getenv("CLAWBRAIN_WORKSPACE"); v != "" && *basePath == "." {
+		*basePath = v
+	}
+
+	// Connect to services. Sync is a batch operation that may process many
+	// files and chunks, so use a much longer timeout than the default 30s.
+	s, err := openStore()
+	if err != nil {
+		exitError(err)
+	}
+	defer s.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	emb := newEmbedder()
+
+	st, err := openSyncState(ctx, s)
+	if err != nil {
+		exitJSON("error", fmt.Sprintf("sync state: %v", err))
+	}
+	defer st.Close()
+
+	// Discover files, of the built-in formats and those extractor plugins
+	// on PATH add
+	sync.RegisterCommands()
+	discovered, err := sync.DiscoverFiles(*basePath, files, dirs)
+	if err != nil {
+		exitJSON("error", fmt.Sprintf("discover files: %v", err))
+	}
+
+	// The run is checkpointed in the sync state as it goes. --resume picks up the
+	// files of the last run that didn't finish instead, less those it
+	// did; with none to resume, the run is a fresh one.
+	runKey, doneKey := sync.CheckpointKeys(globalNamespace)
+	var finishedBefore map[string]bool
+	if *resume {
+		checkpoint, done, err := loadSyncCheckpoint(st, runKey, doneKey)
+		if err != nil {
+			log.Printf("sync: %v", err)
+		} else if checkpoint != nil {
+			discovered = checkpoint.Files
+			finishedBefore = done
+		}
+	}
+	if finishedBefore == nil {
+		saveSyncCheckpoint(st, runKey, doneKey, discovered)
+	}
+	progress := syncProgress(*progressFlag)
+	progress.emit("start", map[string]any{"files": len(discovered), "resumed": finishedBefore != nil})
+
+	// Load ignore patterns: .clawbrain-ignore file + --exclude flags
+	ignorePatterns := sync.LoadIgnorePatterns(*basePath)
+	ignorePatterns = append(ignorePatterns, excludes...)
+
+	// Files synced before and deleted or renamed since are reported, and
+	// with --prune their memories go.
+	gone := pruneGoneFiles(ctx, s, st, *prune)
+
+	if len(discovered) == 0 {
+		st.Del(runKey, doneKey)
+		result := map[string]any{
+			"status":  "ok",
+			"files":   0,
+			"added":   0,
+			"skipped": 0,
+			"results": []any{},
+		}
+		maps.Copy(result, gone)
+		outputJSON(result)
+		return
+	}
+
+	totalAdded := 0
+	totalUnchanged := 0
+	totalDeleted := 0
+	totalSkipped := 0
+	results := make([]sync.FileResult, len(discovered))
+	crossFile := []map[string]any{}
+
+	// Files are read and chunked first, each chunk that needs a vector
+	// queued on the pool as soon as its file is. Chunks are then stored
+	// file by file, in the order discovered, as their vectors come in:
+	// dedup and writes keep that order, so the outcome doesn't depend on
+	// which embed finishes first.
+	// The provider of an embedder chain that embedded each chunk, by its
+	// text, for the chunk's payload.
+	var sources gosync.Map
+	pool := sync.NewPool(ctx, *concurrency, *rate, func(ctx context.Context, text string) ([]float32, error) {
+		vector, source, err := embedder.EmbedSource(ctx, emb, globalModel, text)
+		sources.Store(text, source)
+		return vector, err
+	})
+	pool.Backoff = embedBackoff
+	defer pool.Close()
+	prepared := make([]*syncFile, len(discovered))
+
+	for n, filePath := range discovered {
+		if finishedBefore[filePath] {
+			results[n] = sync.FileResult{
+				File:    filePath,
+				Skipped: 1,
+				Reason:  "finished before the interrupt",
+			}
+			totalSkipped++
+			continue
+		}
+
+		// Check ignore patterns
+		if sync.IsIgnored(filePath, ignorePatterns) {
+			fr := sync.FileResult{
+				File:    filePath,
+				Skipped: 1,
+				Reason:  "excluded by ignore pattern",
+			}
+			results[n] = fr
+			totalSkipped++
+			continue
+		}
+
+		// Skip today's daily file — it's still being written
+		if sync.IsTodayDailyFile(filePath) {
+			fr := sync.FileResult{
+				File:    filePath,
+				Skipped: 1,
+				Reason:  "today's daily file, still growing",
+			}
+			results[n] = fr
+			totalSkipped++
+			continue
+		}
+
+		redisKey := sync.RedisKey(globalNamespace, filePath)
+		isMemoryMD := sync.IsMemoryMD(filePath)
+
+		// For non-MEMORY.md files, check the sync state first (cheap) before
+		// reading the file. These files are immutable — a simple existence
+		// check suffices.
+		if !isMemoryMD {
+			exists, err := st.Exists(redisKey)
+			if err != nil {
+				exists = false
+			}
+			if exists {
+				fr := sync.FileResult{
+					File:    filePath,
+					Skipped: 1,
+					Reason:  "already synced",
+				}
+				results[n] = fr
+				totalSkipped++
+				continue
+			}
+		}
+
+		// Read file content, and the text in it: formats other than
+		// markdown and plain text go through their extractor.
+		content, err := os.ReadFile(filePath)
+		if err != nil {
+			fr := sync.FileResult{
+				File:   filePath,
+				Reason: fmt.Sprintf("read error: %v", err),
+			}
+			results[n] = fr
+			continue
+		}
+		format := sync.FormatFor(filePath)
+		text, err := format.Extractor.Extract(ctx, filePath, content)
+		if err == nil && !utf8.ValidString(text) {
+			err = fmt.Errorf("not a text file; install an extractor for %s files", filepath.Ext(filePath))
+		}
+		if err != nil {
+			fr := sync.FileResult{
+				File:   filePath,
+				Reason: fmt.Sprintf("extract error: %v", err),
+			}
+			results[n] = fr
+			continue
+		}
+
+		// Frontmatter sets fields on every chunk of the file, or keeps the
+		// file out of sync altogether.
+		var fm sync.Frontmatter
+		body := text
+		if format.Markdown {
+			fm, body, err = sync.ParseFrontmatter(text)
+			if err != nil {
+				log.Printf("sync: %s: %v", filePath, err)
+			}
+		}
+		if fm.Ignore {
+			fr := sync.FileResult{
+				File:    filePath,
+				Skipped: 1,
+				Reason:  "clawbrain: ignore in frontmatter",
+			}
+			results[n] = fr
+			totalSkipped++
+			continue
+		}
+		if strings.TrimSpace(body) == "" {
+			fr := sync.FileResult{
+				File:    filePath,
+				Skipped: 1,
+				Reason:  "empty file",
+			}
+			results[n] = fr
+			totalSkipped++
+			continue
+		}
+
+		// For MEMORY.md: compare content hash — re-sync only if file changed.
+		var contentHash string
+		if isMemoryMD {
+			contentHash = sync.ContentHash(content)
+			storedHash, found, err := st.Get(redisKey)
+			if err == nil && found && storedHash == contentHash {
+				fr := sync.FileResult{
+					File:    filePath,
+					Skipped: 1,
+					Reason:  "already synced (unchanged)",
+				}
+				results[n] = fr
+				totalSkipped++
+				continue
+			}
+		}
+
+		// Chunk the file. Markdown is chunked per section, each chunk led by
+		// its heading path; transcripts are chunked per speaker turn so each
+		// chunk can be attributed to whoever said it.
+		chunks := sync.ChunkTurns(body, sync.DefaultChunkSize, sync.DefaultChunkOverlap, format.Markdown)
+		locs := sync.Locate(text, chunks)
+		normalized := make([]string, len(chunks))
+		hashes := make([]string, len(chunks))
+		for i, chunk := range chunks {
+			normalized[i] = sync.NormalizeText(chunk.WithPath())
+			hashes[i] = sync.ChunkHash(normalized[i])
+		}
+
+		previous, err := syncedChunks(ctx, s, filePath)
+		if err != nil {
+			log.Printf("sync: list chunks of %s: %v", filePath, err)
+		}
+		kept, _ := sync.MatchChunks(hashes, chunkHashes(previous))
+		vectors := make([]*sync.Embedding, len(chunks))
+		for i := range chunks {
+			if _, ok := kept[i]; !ok && normalized[i] != "" {
+				vectors[i] = pool.Submit(normalized[i])
+			}
+		}
+		prepared[n] = &syncFile{
+			path:        filePath,
+			redisKey:    redisKey,
+			isMemoryMD:  isMemoryMD,
+			contentHash: contentHash,
+			fm:          fm,
+			chunks:      chunks,
+			locs:        locs,
+			normalized:  normalized,
+			hashes:      hashes,
+			vectors:     vectors,
+		}
+	}
+
+	// A file is finished once every chunk of it is stored, or it was
+	// skipped; one that failed is left for --resume to retry.
+	finished := 0
+	finish := func(n int, ok bool) {
+		if ok {
+			err := st.SAdd(doneKey, discovered[n])
+			if err == nil && finished == 0 {
+				err = st.Expire(doneKey, sync.CheckpointTTLSeconds())
+			}
+			if err != nil {
+				log.Printf("sync: checkpoint %s: %v", discovered[n], err)
+			}
+			finished++
+		}
+		progress.emit("file", map[string]any{"index": n + 1, "files": len(discovered), "result": results[n]})
+	}
+
+	for n, f := range prepared {
+		if f == nil {
+			finish(n, results[n].Skipped > 0)
+			continue
+		}
+		filePath, fm, normalized := f.path, f.fm, f.normalized
+
+		// Chunks stored by an earlier sync of this file stay as they are;
+		// only new and changed ones are embedded. They are listed again
+		// now, as storing the files before may have merged some away.
+		previous, err := syncedChunks(ctx, s, filePath)
+		if err != nil {
+			log.Printf("sync: list chunks of %s: %v", filePath, err)
+		}
+		kept, stale := sync.MatchChunks(f.hashes, chunkHashes(previous))
+		keptIDs := slices.Collect(maps.Values(kept))
+		moved := map[string]map[string]any{}
+		added, unchanged, failed := 0, 0, 0
+
+		for i, chunk := range f.chunks {
+			if normalized[i] == "" {
+				continue
+			}
+			location := chunkLocation(i, f.locs[i])
+			if id, ok := kept[i]; ok {
+				// Edits above the chunk shift its lines without changing it,
+				// and the frontmatter may have changed.
+				want := maps.Clone(location)
+				maps.Copy(want, frontmatterFields(fm, previous[id].Payload))
+				if changed := changedFields(previous[id], want); len(changed) > 0 {
+					moved[id] = changed
+				}
+				unchanged++
+				progress.chunk(filePath, i, len(f.chunks), "unchanged")
+				continue
+			}
+
+			// The chunk's vector from the pool; a chunk kept when its file
+			// was read and merged away since is embedded now.
+			var vector []float32
+			var source string
+			if f.vectors[i] != nil {
+				vector, err = f.vectors[i].Wait()
+				if v, ok := sources.Load(normalized[i]); ok {
+					source = v.(string)
+				}
+			} else {
+				vector, source, err = embedder.EmbedSource(ctx, emb, globalModel, normalized[i])
+			}
+			if err != nil {
+				// Non-fatal per chunk: log and continue
+				log.Printf("sync: embed failed for %s chunk %d: %v", filePath, i, err)
+				failed++
+				progress.chunk(filePath, i, len(f.chunks), "failed")
+				continue
+			}
+			guardEmbedding(ctx, s, vector, true)
+
+			// Add to store with source metadata
+			payload := map[string]any{
+				"text":              normalized[i],
+				"source":            filePath,
+				sync.ChunkHashField: f.hashes[i],
+			}
+			maps.Copy(payload, location)
+			if name := store.NormalizeName(*author); name != "" {
+				payload[store.AuthorField] = name
+			}
+			if name := store.NormalizeName(chunk.Speaker); name != "" {
+				payload[store.SpeakerField] = name
+			}
+			if chunk.Path != "" {
+				payload[headingPathField] = chunk.Path
+			}
+			setEmbeddedBy(payload, source)
+
+			// Run dedup before adding (same as regular add), sparing the
+			// chunks kept above. Chunks of other files are reported either
+			// way: merging one moves it here.
+			dups := findDuplicates(ctx, s, vector, payload, keptIDs...)
+			var other []store.Result
+			if *dedupScope == dedupScopeFile {
+				dups, other = splitBySource(dups, filePath)
+			}
+			merged := deleteDuplicates(ctx, s, dups)
+			for _, d := range merged {
+				delete(previous, d.ID)
+			}
+			if *dedupScope == dedupScopeGlobal {
+				_, other = splitBySource(merged, filePath)
+			}
+			for _, d := range other {
+				crossFile = append(crossFile, crossFileDuplicate(filePath, i, d, *dedupScope == dedupScopeGlobal))
+			}
+			if len(merged) > 0 {
+				if ca := oldestCreatedAt(merged); ca != "" {
+					payload["created_at"] = ca
+				}
+			}
+			maps.Copy(payload, frontmatterFields(fm, payload))
+
+			_, err = s.Add(ctx, "", vector, payload)
+			if err != nil {
+				log.Printf("sync: store failed for %s chunk %d: %v", filePath, i, err)
+				failed++
+				progress.chunk(filePath, i, len(f.chunks), "failed")
+				continue
+			}
+			added++
+			progress.chunk(filePath, i, len(f.chunks), "added")
+		}
+
+		if err := s.SetPayloads(ctx, moved); err != nil {
+			log.Printf("sync: update chunk locations of %s: %v", filePath, err)
+		}
+		// Chunks no longer in the file go only once every chunk that is
+		// stored, so a failed embed never leaves the file half-missing.
+		deleted := 0
+		if failed == 0 {
+			deleted = deleteStaleChunks(ctx, s, previous, stale, filePath)
+		}
+
+		// Only mark file as processed in the sync state if at least one chunk
+		// is stored. If all chunks failed (e.g. Ollama was down), leave
+		// the file unmarked so it gets retried next run.
+		if added > 0 || unchanged > 0 {
+			if f.isMemoryMD {
+				// Store the content hash so we can detect changes next run.
+				// Use a 7-day TTL as a safety net — even if the file hasn't
+				// changed, it will be re-synced after a week. This catches
+				// edge cases like hash collisions or corrupted state.
+				st.SetWithTTL(f.redisKey, f.contentHash, sync.MemoryMDTTLSeconds())
+			} else {
+				st.Set(f.redisKey, "1")
+			}
+		}
+
+		fr := sync.FileResult{
+			File:      filePath,
+			Added:     added,
+			Unchanged: unchanged,
+			Deleted:   deleted,
+		}
+		results[n] = fr
+		finish(n, failed == 0)
+		totalAdded += added
+		totalUnchanged += unchanged
+		totalDeleted += deleted
+	}
+
+	result := map[string]any{
+		"status":                "ok",
+		"files":                 len(discovered),
+		"added":                 totalAdded,
+		"unchanged":             totalUnchanged,
+		"deleted":               totalDeleted,
+		"skipped":               totalSkipped,
+		"results":               results,
+		"dedup_scope":           *dedupScope,
+		"cross_file_duplicates": crossFile,
+	}
+	if finished == len(discovered) {
+		st.Del(runKey, doneKey)
+	} else {
+		result["resumable"] = len(discovered) - finished
+	}
+	if finishedBefore != nil {
+		result["resumed"] = true
+	}
+	maps.Copy(result, gone)
+	outputJSON(result)
+}
+
+// openSyncState opens the sync state --state-backend names. The qdrant
+// state lives in the backend of s and runs under ctx.
+func openSyncState(ctx context.Context, s *store.Store) (sync.State, error) {
+	switch globalState {
+	case sync.StateFile:
+		return sync.NewFileState(filepath.Join(expandHome(globalPath), sync.StateFileName)), nil
+	case sync.StateQdrant:
+		return s.SyncState(ctx), nil
+	}
+	return redis.New(globalRedisHost, globalRedisPort)
+}
+
+// syncProgress writes the events of sync --progress: one JSON line each,
+// ahead of the result, which is always the last line.
+type syncProgress bool
+
+func (p syncProgress) emit(event string, fields map[string]any) {
+	if !p {
+		return
+	}
+	fields["event"] = event
+	outputJSON(fields)
+}
+
+// chunk reports what became of chunk i of a file's n chunks.
+func (p syncProgress) chunk(filePath string, i, n int, status string) {
+	p.emit("chunk", map[string]any{"file": filePath, "chunk": i, "chunks": n, "status": status})
+}
+
+// saveSyncCheckpoint records the start of a sync run of files, replacing
+// the checkpoint of any run before it.
+func saveSyncCheckpoint(st sync.State, runKey, doneKey string, files []string) {
+	data, err := json.Marshal(sync.Checkpoint{StartedAt: time.Now().UTC().Format(time.RFC3339), Files: files})
+	if err == nil {
+		_, err = st.Del(doneKey)
+	}
+	if err == nil {
+		err = st.SetWithTTL(runKey, string(data), sync.CheckpointTTLSeconds())
+	}
+	if err != nil {
+		log.Printf("sync: save checkpoint: %v", err)
+	}
+}
+
+// loadSyncCheckpoint returns the checkpoint of the last run that didn't
+// finish and the files it did, or nil if every run finished.
+func loadSyncCheckpoint(st sync.State, runKey, doneKey string) (*sync.Checkpoint, map[string]bool, error) {
+	data, found, err := st.Get(runKey)
+	if err != nil || !found {
+		return nil, nil, err
+	}
+	var checkpoint sync.Checkpoint
+	if err := json.Unmarshal([]byte(data), &checkpoint); err != nil {
+		return nil, nil, fmt.Errorf("read checkpoint: %w", err)
+	}
+	members, err := st.SMembers(doneKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	done := make(map[string]bool, len(members))
+	for _, m := range members {
+		done[m] = true
+	}
+	return &checkpoint, done, nil
+}
+
+// pruneGoneFiles finds the files sync tracks in its state that no longer exist
+// and, with prune, unsyncs them. It returns the fields to add to the sync
+// result: missing_files, and pruned with how many memories went.
+func pruneGoneFiles(ctx context.Context, s *store.Store, st sync.State, prune bool) map[string]any {
+	keys, err := st.Scan(sync.RedisKeyPattern(globalNamespace, ""))
+	if err != nil {
+		log.Printf("sync: list synced files: %v", err)
+		return nil
+	}
+	var gone []string
+	for _, key := range keys {
+		if path := sync.PathFromRedisKey(globalNamespace, key); sync.IsGone(path) {
+			gone = append(gone, path)
+		}
+	}
+	if len(gone) == 0 {
+		return nil
+	}
+	sort.Strings(gone)
+	fields := map[string]any{"missing_files": gone}
+	if !prune {
+		return fields
+	}
+	memories, err := tierMemories(ctx, s)
+	if err != nil {
+		log.Printf("sync: prune missing files: %v", err)
+		return fields
+	}
+	_, ids, _, err := unsync(ctx, s, st, memories, gone, false, false)
+	if err != nil {
+		log.Printf("sync: prune missing files: %v", err)
+		return fields
+	}
+	fields["pruned"] = len(ids)
+	return fields
+}
+
+// runUnsync removes the memories synced from files that were deleted or
+// renamed, which sync would otherwise keep forever, and forgets the files'
+// sync state so they are ingested afresh if they come back.
+func runUnsync(args []string) {
+	fs := flag.NewFlagSet("unsync", flag.ExitOnError)
+	var files multiFlag
+	fs.Var(&files, "file", "Synced file whose memories to remove (repeatable)")
+	missing := fs.Bool("missing", false, "Remove the memories of every source file that no longer exists")
+	archive := fs.Bool("archive", false, "Archive the memories (hidden from search) instead of deleting them")
+	dryRun := fs.Bool("dry-run", false, "List what would be removed without changing anything")
+	fs.Parse(args)
+
+	if len(files) == 0 && !*missing {
+		exitJSON("error", "--file or --missing is required")
+	}
+	sources := make([]string, 0, len(files))
+	for _, f := range files {
+		if strings.TrimSpace(f) == "" {
+			exitJSON("error", "--file must not be empty")
+		}
+		// sync records absolute paths.
+		abs, err := filepath.Abs(f)
+		if err != nil {
+			exitError(err)
+		}
+		sources = append(sources, abs)
+	}
+
+	s, ctx, cancel := connect()
+	defer cancel()
+	defer s.Close()
+
+	st, err := openSyncState(ctx, s)
+	if err != nil {
+		exitJSON("error", fmt.Sprintf("sync state: %v", err))
+	}
+	defer st.Close()
+
+	memories, err := tierMemories(ctx, s)
+	if err != nil {
+		exitError(err)
+	}
+	if *missing {
+		// Files tracked in the sync state may have no memories left, and
+		// memories may outlive their tracking key; either kind of leftover
+		// goes.
+		keys, err := st.Scan(sync.RedisKeyPattern(globalNamespace, ""))
+		if err != nil {
+			exitJSON("error", fmt.Sprintf("sync state scan: %v", err))
+		}
+		var known []string
+		for _, key := range keys {
+			known = append(known, sync.PathFromRedisKey(globalNamespace, key))
+		}
+		for _, m := range slices.Concat(memories...) {
+			// Only paths sync wrote; anything else can't be checked.
+			if src, _ := m.Payload["source"].(string); filepath.IsAbs(src) {
+				known = append(known, src)
+			}
+		}
+		sources = append(sources, slices.DeleteFunc(known, func(src string) bool { return !sync.IsGone(src) })...)
+	}
+	sort.Strings(sources)
+	sources = slices.Compact(sources)
+
+	unsynced, ids, kept, err := unsync(ctx, s, st, memories, sources, *archive, *dryRun)
+	if err != nil {
+		exitError(err)
+	}
+	result := map[string]any{
+		"status": "ok",
+		"files":  unsynced,
+	}
+	if len(kept) > 0 {
+		result["kept"] = kept
+	}
+	if *dryRun {
+		result["dry_run"] = true
+		result["would_remove"] = len(ids)
+		outputJSON(result)
+		return
+	}
+	if *archive {
+		result["archived"] = len(ids)
+	} else {
+		result["deleted"] = len(ids)
+	}
+	outputJSON(result)
+}
+
+// unsyncedFile is one source file unsync handled, with how many of its
+// memories it removed.
+type unsyncedFile struct {
+	Source   string `json:"source"`
+	Memories int    `json:"memories"`
+}
+
+// tierMemories returns every memory of each of s's tiers, in the order of
+// s.Tiers().
+func tierMemories(ctx context.Context, s *store.Store) ([][]store.Result, error) {
+	tiers := s.Tiers()
+	memories := make([][]store.Result, len(tiers))
+	for i, t := range tiers {
+		all, err := t.All(ctx)
+		if err != nil {
+			return nil, err
+		}
+		memories[i] = all
+	}
+	return memories, nil
+}
+
+// unsync removes the memories whose source is one of sources, sparing
+// pinned and locked ones, and drops the files' sync state. memories holds
+// those of each tier, as tierMemories returns them, so that memories tier
+// moved to the cold one go too. With archive the memories are archived
+// rather than deleted; with dryRun nothing changes. It returns each file
+// with its count, the IDs removed and the IDs spared.
+func unsync(ctx context.Context, s *store.Store, st sync.State, memories [][]store.Result, sources []string, archive, dryRun bool) ([]unsyncedFile, []string, []string, error) {
+	counts := make(map[string]int, len(sources))
+	for _, src := range sources {
+		counts[src] = 0
+	}
+	tiers := s.Tiers()
+	tierIDs := make([][]string, len(tiers))
+	var ids, kept []string
+	var removed []store.Result
+	for i := range tiers {
+		for _, m := range memories[i] {
+			src, _ := m.Payload["source"].(string)
+			if _, ok := counts[src]; !ok || (archive && store.IsArchived(m.Payload)) {
+				continue
+			}
+			if pinned, _ := m.Payload["pinned"].(bool); pinned || store.IsLocked(m.Payload) {
+				kept = append(kept, m.ID)
+				continue
+			}
+			ids = append(ids, m.ID)
+			tierIDs[i] = append(tierIDs[i], m.ID)
+			removed = append(removed, m)
+			counts[src]++
+		}
+	}
+	files := make([]unsyncedFile, 0, len(sources))
+	for _, src := range sources {
+		files = append(files, unsyncedFile{Source: src, Memories: counts[src]})
+	}
+	if dryRun || len(sources) == 0 {
+		return files, ids, kept, nil
+	}
+
+	if len(ids) > 0 {
+		detail := map[string]any{"sources": sources}
+		now := time.Now().UTC().Format(time.RFC3339Nano)
+		for i, t := range tiers {
+			if len(tierIDs[i]) == 0 {
+				continue
+			}
+			if !archive {
+				if err := t.DeleteIDs(ctx, tierIDs[i]); err != nil {
+					return nil, nil, nil, err
+				}
+				continue
+			}
+			updates := make(map[string]map[string]any, len(tierIDs[i]))
+			for _, id := range tierIDs[i] {
+				updates[id] = store.ArchivePayload(now, store.ArchiveReasonUnsync)
+			}
+			if err := t.SetPayloads(ctx, updates); err != nil {
+				return nil, nil, nil, err
+			}
+		}
+		if archive {
+			recordAudit(ctx, "archive", len(ids), ids, detail)
+		} else {
+			recordAudit(ctx, "unsync", len(ids), ids, detail)
+		}
+		// Cached searches may still list the removed memories.
+		payloads := make([]map[string]any, len(removed))
+		for i, m := range removed {
+			payloads[i] = m.Payload
+		}
+		invalidateCache(payloads...)
+	}
+
+	keys := make([]string, len(sources))
+	for i, src := range sources {
+		keys[i] = sync.RedisKey(globalNamespace, src)
+	}
+	if _, err := st.Del(keys...); err != nil {
+		return nil, nil, nil, fmt.Errorf("sync state: %w", err)
+	}
+	return files, ids, kept, nil
+}
+
+// syncFile is a file sync has read and chunked, waiting for its chunks to
+// be stored. vectors holds the pending embedding of each chunk that wasn't
+// already stored when the file was read.
+type syncFile struct {
+	path        string
+	redisKey    string
+	isMemoryMD  bool
+	contentHash string
+	fm          sync.Frontmatter
+	chunks      []sync.Turn
+	locs        []sync.Location
+	normalized  []string
+	hashes      []string
+	vectors     []*sync.Embedding
+}
+
+// embedBackoff is how long sync's workers pause after a failed embed: the
+// wait the embedder asked for if it is overloaded, and none for errors
+// that retrying won't fix.
+func embedBackoff(err error) time.Duration {
+	if be := backoff.Classify(err); be != nil && be.Backend != "qdrant" {
+		return be.RetryAfter
+	}
+	return 0
+}
+
+// syncedChunks returns the memories an earlier sync stored from the file,
+// by ID: those with its source and a chunk_index. Archived chunks, such as
+// those of a file unsynced with --archive, don't count.
+func syncedChunks(ctx context.Context, s *store.Store, filePath string) (map[string]store.Result, error) {
+	found, err := s.Find(ctx, []store.Condition{{Key: "source", Op: "=", Value: filePath}})
+	if err != nil {
+		return nil, err
+	}
+	chunks := make(map[string]store.Result, len(found))
+	for _, r := range found {
+		if _, ok := r.Payload["chunk_index"]; ok && !store.IsArchived(r.Payload) {
+			chunks[r.ID] = r
+		}
+	}
+	return chunks, nil
+}
+
+// chunkHashes maps each chunk's ID to its chunk_hash. Chunks synced before
+// hashes were stored have none, so they match nothing and are replaced.
+func chunkHashes(chunks map[string]store.Result) map[string]string {
+	hashes := make(map[string]string, len(chunks))
+	for id, r := range chunks {
+		hashes[id], _ = r.Payload[sync.ChunkHashField].(string)
+	}
+	return hashes
+}
+
+// chunkLocation is the payload saying where chunk i came from, so a result
+// can be cited as file:line#anchor.
+func chunkLocation(i int, loc sync.Location) map[string]any {
+	location := map[string]any{"chunk_index": i}
+	if loc.StartLine > 0 {
+		location["start_line"] = loc.StartLine
+		location["end_line"] = loc.EndLine
+		if loc.Heading != "" {
+			location["heading"] = loc.Heading
+			location["anchor"] = loc.Anchor
+		}
+	}
+	return location
+}
+
+// readSource reads a synced memory's source file as sync read it: through
+// the extractor of its format, so that a chunk is checked against the text
+// it was cut from.
+func readSource(ctx context.Context, path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	sync.RegisterCommands()
+	return sync.FormatFor(path).Extractor.Extract(ctx, path, content)
+}
+
+// headingPathField holds the heading path of a synced markdown chunk, which
+// its text starts with (see sync.ChunkMarkdown).
+const headingPathField = "heading_path"
+
+// chunkText returns the text of a synced chunk as it appears in its file,
+// without the heading path sync put in front.
+func chunkText(payload map[string]any) string {
+	text, _ := payload["text"].(string)
+	path, _ := payload[headingPathField].(string)
+	return sync.StripPath(text, path)
+}
+
+// frontmatterFields returns the fields a file's frontmatter sets on one of
+// its chunks, whose payload is currently payload. Frontmatter tags are added
+// to the chunk's own; the date becomes created_at.
+func frontmatterFields(fm sync.Frontmatter, payload map[string]any) map[string]any {
+	fields := map[string]any{}
+	if fm.Title != "" {
+		fields["title"] = fm.Title
+	}
+	if len(fm.Tags) > 0 {
+		fields["tags"] = store.TagsValue(store.WithTags(store.Tags(payload), fm.Tags...))
+	}
+	if !fm.Date.IsZero() {
+		fields["created_at"] = fm.Date.UTC().Format(time.RFC3339Nano)
+	}
+	if fm.Pinned {
+		fields["pinned"] = true
+	}
+	return fields
+}
+
+// changedFields returns the fields of want that the memory's payload
+// doesn't already hold.
+func changedFields(r store.Result, want map[string]any) map[string]any {
+	changed := map[string]any{}
+	for k, v := range want {
+		if fmt.Sprint(r.Payload[k]) != fmt.Sprint(v) {
+			changed[k] = v
+		}
+	}
+	return changed
+}
+
+// deleteStaleChunks deletes the chunks of a file that no longer appear in
+// it, sparing pinned and locked ones, and returns how many it deleted.
+func deleteStaleChunks(ctx context.Context, s *store.Store, chunks map[string]store.Result, stale []string, filePath string) int {
+	var ids []string
+	for _, id := range stale {
+		// Chunks merged into a new one are gone already.
+		r, ok := chunks[id]
+		if !ok {
+			continue
+		}
+		payload := r.Payload
+		if pinned, _ := payload["pinned"].(bool); pinned || store.IsLocked(payload) {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return 0
+	}
+	if err := s.DeleteIDs(ctx, ids); err != nil {
+		log.Printf("sync: delete removed chunks of %s: %v", filePath, err)
+		return 0
+	}
+	return len(ids)
+}
+
+// Sync dedup scopes: a chunk merges near-duplicates anywhere in the store,
+// or only earlier chunks of its own file, so boilerplate shared by several
+// files isn't collapsed into one chunk with a single file's source.
+const (
+	dedupScopeGlobal = "global"
+	dedupScopeFile   = "file"
+)
+
+// splitBySource separates the memories whose source is source from the
+// rest.
+func splitBySource(memories []store.Result, source string) (same, other []store.Result) {
+	for _, m := range memories {
+		if src, _ := m.Payload["source"].(string); src == source {
+			same = append(same, m)
+		} else {
+			other = append(other, m)
+		}
+	}
+	return same, other
+}
+
+// crossFileDuplicate describes a memory from elsewhere that chunk i of file
+// duplicates, and whether it was merged into the chunk.
+func crossFileDuplicate(file string, i int, dup store.Result, merged bool) map[string]any {
+	entry := map[string]any{
+		"file":         file,
+		"chunk_index":  i,
+		"duplicate_id": dup.ID,
+		"score":        dup.Score,
+		"merged":       merged,
+	}
+	if src, _ := dup.Payload["source"].(string); src != "" {
+		entry["duplicate_source"] = src
+	}
+	return entry
+}
+
+func runTag(args []string) {
+	if len(args) == 0 || (args[0] != "add" && args[0] != "remove") {
+		fmt.Fprintln(os.Stderr, "Usage: clawbrain tag add|remove --tag TAG [--tag TAG]... (--filter KEY=VALUE | --id UUID)...")
+		os.Exit(1)
+	}
+	action := args[0]
+
+	fs := flag.NewFlagSet("tag "+action, flag.ExitOnError)
+	var tags, filters, ids multiFlag
+	fs.Var(&tags, "tag", "Tag to add or remove (repeatable)")
+	fs.Var(&filters, "filter", "Payload filter KEY=VALUE, KEY>=N, KEY<N or KEY~LAT,LON,RADIUS; a value ending in / matches as a path prefix (repeatable, ANDed)")
+	fs.Var(&ids, "id", "UUID of a memory to tag (repeatable)")
+	dryRun := fs.Bool("dry-run", false, "Report each memory's tags before and after without changing anything")
+	fs.Parse(args[1:])
+
+	if len(tags) == 0 {
+		fmt.Fprintln(os.Stderr, "Error: at least one --tag is required")
+		fs.Usage()
+		os.Exit(1)
+	}
+	// Refuse to retag the whole collection by accident.
+	if len(filters) == 0 && len(ids) == 0 {
+		fmt.Fprintln(os.Stderr, "Error: --filter or --id is required")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	conds := parseConditions(filters)
+
+	s, ctx, cancel := connect()
+	defer cancel()
+	defer s.Close()
+
+	matched, err := s.Find(ctx, conds)
+	if err != nil {
+		exitError(err)
+	}
+	matched = restrictToIDs(matched, ids)
+
+	updates := make(map[string]map[string]any)
+	changes := []map[string]any{}
+	skippedLocked := 0
+	for _, r := range matched {
+		if store.IsLocked(r.Payload) {
+			skippedLocked++
+			continue
+		}
+		existing := store.Tags(r.Payload)
+		var next []string
+		if action == "add" {
+			next = store.WithTags(existing, tags...)
+		} else {
+			next = store.WithoutTags(existing, tags...)
+		}
+		// Adding only grows and removing only shrinks the set, so an
+		// unchanged length means there is nothing to write.
+		if len(next) == len(existing) {
+			continue
+		}
+		updates[r.ID] = map[string]any{"tags": store.TagsValue(next)}
+		changes = append(changes, map[string]any{"id": r.ID, "before": existing, "after": next})
+	}
+
+	result := map[string]any{
+		"status":         "ok",
+		"action":         action,
+		"tags":           []string(tags),
+		"matched":        len(matched),
+		"skipped_locked": skippedLocked,
+	}
+	if *dryRun {
+		result["dry_run"] = true
+		result["would_update"] = len(updates)
+		result["changes"] = changes
+		outputJSON(result)
+		return
+	}
+
+	if err := s.SetPayloads(ctx, updates); err != nil {
+		exitError(err)
+	}
+	result["updated"] = len(updates)
+	outputJSON(result)
+}
+
+func runTags(args []string) {
+	fs := flag.NewFlagSet("tags", flag.ExitOnError)
+	prefix := fs.String("prefix", "", "Only tags starting with this, e.g. project:")
+	limit := fs.Int("limit", 0, "Maximum number of tags to list, most used first (0 for all)")
+	fs.Parse(args)
+
+	if *limit < 0 {
+		exitJSON("error", "limit must be non-negative")
+	}
+
+	s, ctx, cancel := connect()
+	defer cancel()
+	defer s.Close()
+
+	counts, err := s.TagCounts(ctx, store.Filter{ExcludePersonal: globalShared})
+	if err != nil {
+		exitError(err)
+	}
+	listed := []store.TagCount{}
+	for _, c := range counts {
+		if strings.HasPrefix(c.Tag, *prefix) {
+			listed = append(listed, c)
+		}
+	}
+	total := len(listed)
+	if *limit > 0 && len(listed) > *limit {
+		listed = listed[:*limit]
+	}
+
+	outputJSON(map[string]any{
+		"status": "ok",
+		"total":  total,
+		"tags":   listed,
+	})
+}
+
+// maxOrientList bounds the pinned memories and todos orient returns, so
+// a store with hundreds of either still gives a short answer.
+const maxOrientList = 20
+
+// runOrient answers what a fresh session needs before it knows what to
+// search for: how many memories there are of each type, the newest ones,
+// the pinned ones, the todos not yet superseded, and when sync last added
+// anything. Archived and superseded memories are left out, and personal
+// ones under --shared.
+func runOrient(args []string) {
+	fs := flag.NewFlagSet("orient", flag.ExitOnError)
+	recent := fs.Int("recent", 5, "Number of most recent memories to return")
+	limit := fs.Int("limit", maxOrientList, "Maximum number of pinned memories and of todos to return")
+	fs.Parse(args)
+
+	if *recent < 0 || *limit < 0 {
+		exitJSON("error", "recent and limit must be non-negative")
+	}
+
+	s, ctx, cancel := connect()
+	defer cancel()
+	defer s.Close()
+
+	memories, err := s.All(ctx)
+	if err != nil {
+		exitError(err)
+	}
+
+	var lastSynced time.Time
+	for _, m := range memories {
+		if _, synced := m.Payload[sync.ChunkHashField]; synced {
+			if created, ok := retention.CreatedAt(m.Payload); ok && created.After(lastSynced) {
+				lastSynced = created
+			}
+		}
+	}
+	visible := browsable(memories)
+	types := map[string]int{}
+	for _, m := range visible {
+		types[retention.TypeOf(m.Payload)]++
+	}
+
+	todos := []store.Result{}
+	for _, m := range visible {
+		if m.Payload["type"] == "todo" && len(todos) < *limit {
+			todos = append(todos, m)
+		}
+	}
+
+	response := map[string]any{
+		"status": "ok",
+		"total":  len(visible),
+		"types":  types,
+		"recent": visible[:min(*recent, len(visible))],
+		"pinned": pinnedOf(visible, *limit),
+		"todos":  todos,
+	}
+	if !lastSynced.IsZero() {
+		response["last_synced_at"] = lastSynced.UTC().Format(time.RFC3339Nano)
+	}
+	outputJSON(limitResponse(response, "recent", "pinned", "todos"))
+}
+
+// defaultDecisionLimit is how many decisions decisions list returns.
+const defaultDecisionLimit = 20
+
+// runDecisions lists the decisions recorded with add --type decision,
+// newest first, with their fields and cards. Superseded decisions are left
+// out, so a decision revisited with add --supersedes shows only as it
+// stands now.
+func runDecisions(args []string) {
+	if len(args) == 0 || args[0] != "list" {
+		fmt.Fprintln(os.Stderr, "Usage: clawbrain decisions list [--limit N] [--tag TAG]")
+		os.Exit(1)
+	}
+	fs := flag.NewFlagSet("decisions list", flag.ExitOnError)
+	limit := fs.Int("limit", defaultDecisionLimit, "Maximum number of decisions to return (0 for all)")
+	var tags multiFlag
+	fs.Var(&tags, "tag", "Only decisions carrying this tag (repeatable)")
+	fs.Parse(args[1:])
+
+	if *limit < 0 {
+		exitJSON("error", "limit must be non-negative")
+	}
+
+	s, ctx, cancel := connect()
+	defer cancel()
+	defer s.Close()
+
+	memories, err := s.All(ctx)
+	if err != nil {
+		exitError(err)
+	}
+	decisions := []map[string]any{}
+	total := 0
+	for _, m := range browsable(memories) {
+		have := store.Tags(m.Payload)
+		if !decision.Is(m.Payload) || slices.ContainsFunc(tags, func(tag string) bool { return !slices.Contains(have, tag) }) {
+			continue
+		}
+		total++
+		if *limit > 0 && len(decisions) == *limit {
+			continue
+		}
+		d := map[string]any{"id": m.ID, decision.CardField: decision.Card(m.Payload)}
+		for _, field := range append(slices.Clone(decision.Fields), "created_at", "tags") {
+			if v, ok := m.Payload[field]; ok {
+				d[field] = v
+			}
+		}
+		decisions = append(decisions, d)
+	}
+	outputJSON(limitResponse(map[string]any{
+		"status":    "ok",
+		"decisions": decisions,
+		"returned":  len(decisions),
+		"total":     total,
+	}, "decisions"))
+}
+
+// defaultRecentLimit is how many memories recent lists.
+const defaultRecentLimit = 20
+
+// runRecent lists the newest memories by created_at, scrolling the
+// collection in that order rather than searching it, so it needs no query
+// and no embedder. Like the other listings, it leaves out archived and
+// superseded memories, and personal ones under --shared.
+func runRecent(args []string) {
+	fs := flag.NewFlagSet("recent", flag.ExitOnError)
+	limit := fs.Int("limit", defaultRecentLimit, "Maximum number of memories to return")
+	var types, tags multiFlag
+	fs.Var(&types, "type", "Only memories of this type, e.g. todo; \"untyped\" matches memories without one (repeatable, ORed)")
+	fs.Var(&tags, "tag", "Only memories with this tag, e.g. project:billing (repeatable, ANDed)")
+	since := fs.String("since", "", "Only memories stored at or after this time: a duration ago (24h, 7d), an RFC 3339 timestamp or a date")
+	selectSpec := fs.String("select", "", "Only output these fields of each memory, e.g. id,payload.text")
+	fs.Parse(args)
+
+	if *limit <= 0 {
+		exitJSON("error", "limit must be positive")
+	}
+	sel, err := selector.Parse(*selectSpec)
+	if err != nil {
+		exitError(err)
+	}
+	filter := store.Filter{
+		ExcludePersonal:   globalShared,
+		ExcludeArchived:   true,
+		ExcludeSuperseded: true,
+		Conditions:        tagConditions(tags),
+	}
+	if len(types) > 0 {
+		if filter.Types, err = store.TypeFilter(types, loadConfig().MemoryTypes()); err != nil {
+			exitError(err)
+		}
+	}
+	if err := setTimeRange(&filter, *since, "", store.CreatedAtField, time.Now()); err != nil {
+		exitError(err)
+	}
+
+	s, ctx, cancel := connect()
+	defer cancel()
+	defer s.Close()
+
+	memories, err := s.Recent(ctx, uint32(*limit), filter)
+	if err != nil {
+		exitError(err)
+	}
+	listed := make([]map[string]any, len(memories))
+	for i, m := range memories {
+		listed[i] = map[string]any{"id": m.ID, "payload": m.Payload}
+	}
+	if sel != nil {
+		if listed, err = sel.ApplyAll(listed); err != nil {
+			exitError(err)
+		}
+	}
+	outputJSON(limitResponse(map[string]any{
+		"status":   "ok",
+		"memories": listed,
+		"returned": len(listed),
+	}, "memories"))
+}
+
+// browsable returns the memories a client browsing the store sees, newest
+// first: neither archived nor superseded, nor personal under --shared.
+func browsable(memories []store.Result) []store.Result {
+	visible := make([]store.Result, 0, len(memories))
+	for _, m := range memories {
+		if store.IsArchived(m.Payload) || (globalShared && store.IsPersonal(m.Payload)) {
+			continue
+		}
+		if superseded, _ := m.Payload[store.SupersededByField].(string); superseded != "" {
+			continue
+		}
+		visible = append(visible, m)
+	}
+	sort.SliceStable(visible, func(i, j int) bool {
+		a, _ := retention.CreatedAt(visible[i].Payload)
+		b, _ := retention.CreatedAt(visible[j].Payload)
+		return a.After(b)
+	})
+	return visible
+}
+
+// defaultResourceLimit is how many memories clawbrain://recent and
+// clawbrain://pinned hold, and how many of each resource list names.
+const defaultResourceLimit = 50
+
+// resourceInfo describes a resource in a listing, in the shape MCP's
+// resources/list uses.
+type resourceInfo struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType"`
+}
+
+// resourceName is a memory's name in a listing: the start of its text.
+func resourceName(m store.Result) string {
+	text, _ := m.Payload["text"].(string)
+	if text = strings.Join(strings.Fields(text), " "); text == "" {
+		return m.ID
+	}
+	if runes := []rune(text); len(runes) > 60 {
+		return string(runes[:60]) + "…"
+	}
+	return text
+}
+
+// resourceTemplates describes the URIs of single memories, in the shape
+// MCP's resources/templates/list uses.
+var resourceTemplates = []map[string]string{{"uriTemplate": resource.MemoryTemplate, "name": "Memory", "mimeType": "application/json"}}
+
+// listResources names the lists and the memories in them, up to limit of
+// each: pinned memories first, then the most recent.
+func listResources(memories []store.Result, limit int) []resourceInfo {
+	list := []resourceInfo{
+		{URI: resource.Recent, Name: "Recent memories", Description: "The newest memories, newest first", MimeType: "application/json"},
+		{URI: resource.Pinned, Name: "Pinned memories", Description: "Memories pinned against deletion, newest first", MimeType: "application/json"},
+	}
+	visible := browsable(memories)
+	listed := map[string]bool{}
+	add := func(m store.Result) {
+		if !listed[m.ID] {
+			listed[m.ID] = true
+			list = append(list, resourceInfo{URI: resource.Memory(m.ID), Name: resourceName(m), Description: retention.TypeOf(m.Payload), MimeType: "application/json"})
+		}
+	}
+	for _, m := range pinnedOf(visible, limit) {
+		add(m)
+	}
+	for _, m := range visible[:min(limit, len(visible))] {
+		add(m)
+	}
+	return list
+}
+
+// pinnedOf returns the first limit pinned memories of memories.
+func pinnedOf(memories []store.Result, limit int) []store.Result {
+	pinned := []store.Result{}
+	for _, m := range memories {
+		if retention.IsPinned(m.Payload) && len(pinned) < limit {
+			pinned = append(pinned, m)
+		}
+	}
+	return pinned
+}
+
+// errResourceNotFound is returned for a memory URI naming no memory.
+var errResourceNotFound = errors.New("not found")
+
+// readResource returns the contents of a resource URI: {"memory": ...}
+// for a memory, {"memories": [...]} for a list of at most limit. Reading
+// is browsing, not recalling, so last_accessed is left alone. A memory
+// that is personal under --shared reads as not found.
+func readResource(ctx context.Context, s *store.Store, uri string, limit int) (map[string]any, error) {
+	kind, id, err := resource.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	response := map[string]any{"status": "ok", "uri": uri}
+	if kind == resource.KindMemory {
+		m, _, err := peekTiers(ctx, s, id)
+		if err != nil {
+			return nil, err
+		}
+		if m == nil || (globalShared && store.IsPersonal(m.Payload)) {
+			return nil, fmt.Errorf("memory %s %w", id, errResourceNotFound)
+		}
+		response["memory"] = m
+		return response, nil
+	}
+	all, err := s.All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	memories := browsable(all)
+	if kind == resource.KindPinned {
+		memories = pinnedOf(memories, limit)
+	}
+	memories = memories[:min(limit, len(memories))]
+	response["memories"] = memories
+	response["returned"] = len(memories)
+	return response, nil
+}
+
+// runResourceList lists the resources a client can read.
+func runResourceList(args []string) {
+	fs := flag.NewFlagSet("resource list", flag.ExitOnError)
+	limit := fs.Int("limit", defaultResourceLimit, "Maximum number of pinned and of recent memories to list")
+	fs.Parse(args)
+
+	if *limit < 0 {
+		exitJSON("error", "limit must be non-negative")
+	}
+
+	s, ctx, cancel := connect()
+	defer cancel()
+	defer s.Close()
+
+	memories, err := s.All(ctx)
+	if err != nil {
+		exitError(err)
+	}
+	outputJSON(map[string]any{
+		"status":             "ok",
+		"resources":          listResources(memories, *limit),
+		"resource_templates": resourceTemplates,
+	})
+}
+
+// runResourceRead reads one resource.
+func runResourceRead(args []string) {
+	fs := flag.NewFlagSet("resource read", flag.ExitOnError)
+	limit := fs.Int("limit", defaultResourceLimit, "Maximum number of memories a list returns")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: clawbrain resource read [--limit N] URI")
+		os.Exit(1)
+	}
+	if *limit < 0 {
+		exitJSON("error", "limit must be non-negative")
+	}
+
+	s, ctx, cancel := connect()
+	defer cancel()
+	defer s.Close()
+
+	response, err := readResource(ctx, s, fs.Arg(0), *limit)
+	if err != nil {
+		exitError(err)
+	}
+	outputJSON(limitResponse(response, "memory", "memories"))
+}
+
+// runRanking shows, sets or clears the ranking profile of --agent, or of
+// the whole collection without one. Every search of the collection applies
+// it: the CLI, the plugin and serve alike.
+func runRanking(args []string) {
+	if len(args) == 0 || (args[0] != "show" && args[0] != "set" && args[0] != "clear") {
+		fmt.Fprintln(os.Stderr, "Usage: clawbrain [--agent NAME] ranking show|set|clear [--recency-boost F] [--recency-scale D] [--frequency-weight F] [--type-boost TYPE=F]... [--pinned-bonus F]")
+		os.Exit(1)
+	}
+	action := args[0]
+
+	fs := flag.NewFlagSet("ranking "+action, flag.ExitOnError)
+	var p ranking.Profile
+	fs.Float64Var(&p.RecencyBoost, "recency-boost", 0, "Add up to this much to the score of recently accessed memories")
+	fs.StringVar(&p.RecencyScale, "recency-scale", "", "Half-life of the recency boost, e.g. 7d (default 7d)")
+	fs.Float64Var(&p.FrequencyWeight, "frequency-weight", 0, "Add up to this much to the score of often recalled memories (half of it at 5 recalls)")
+	var typeBoosts multiFlag
+	fs.Var(&typeBoosts, "type-boost", "Add this much to the score of memories of a type, e.g. todo=0.05 (repeatable)")
+	fs.Float64Var(&p.PinnedBonus, "pinned-bonus", 0, "Add this much to the score of pinned memories")
+	fs.Parse(args[1:])
+
+	if action == "set" {
+		for _, spec := range typeBoosts {
+			typ, boost, err := ranking.ParseTypeBoost(spec)
+			if err != nil {
+				exitError(err)
+			}
+			if p.TypeBoosts == nil {
+				p.TypeBoosts = map[string]float64{}
+			}
+			p.TypeBoosts[typ] = boost
+		}
+		if err := p.Validate(); err != nil {
+			exitError(err)
+		}
+		if p.Empty() {
+			exitJSON("error", "nothing to set: give at least one weight, or use ranking clear")
+		}
+	} else if fs.NFlag() > 0 {
+		exitJSON("error", "weights can only be given to ranking set")
+	}
+
+	s, ctx, cancel := connect()
+	defer cancel()
+	defer s.Close()
+
+	result := map[string]any{"status": "ok", "agent": globalAgent, "namespace": globalNamespace}
+	switch action {
+	case "show":
+		profiles, err := rankingProfiles(ctx, s)
+		if err != nil {
+			exitError(err)
+		}
+		if p, key, ok := ranking.Resolve(profiles, globalAgent); ok {
+			if key == "" {
+				key = collectionProfile
+			}
+			result["applies"] = key
+			result["profile"] = p
+		} else {
+			result["applies"] = "none"
+		}
+		result["profiles"] = profiles
+	case "set":
+		data, err := json.Marshal(p)
+		if err != nil {
+			exitError(err)
+		}
+		if err := s.SetRankingProfile(ctx, globalAgent, data); err != nil {
+			exitError(err)
+		}
+		result["profile"] = p
+	case "clear":
+		profiles, err := rankingProfiles(ctx, s)
+		if err != nil {
+			exitError(err)
+		}
+		_, had := profiles[globalAgent]
+		if had {
+			if err := s.SetRankingProfile(ctx, globalAgent, nil); err != nil {
+				exitError(err)
+			}
+		}
+		result["cleared"] = had
+	}
+	outputJSON(result)
+}
+
+func runNamespaces(args []string) {
+	fs := flag.NewFlagSet("namespaces", flag.ExitOnError)
+	fs.Parse(args)
+
+	s, ctx, cancel := connect()
+	defer cancel()
+	defer s.Close()
+
+	namespaces, err := s.Namespaces(ctx)
+	if err != nil {
+		exitError(err)
+	}
+	if namespaces == nil {
+		namespaces = []store.NamespaceInfo{}
+	}
+	outputJSON(map[string]any{
+		"status":     "ok",
+		"current":    globalNamespace,
+		"namespaces": namespaces,
+	})
+}
+
+// parseConditions parses --filter expressions, exiting on the first invalid one.
+func parseConditions(filters []string) []store.Condition {
+	conds := make([]store.Condition, 0, len(filters))
+	for _, f := range filters {
+		c, err := store.ParseCondition(f)
+		if err != nil {
+			exitError(err)
+		}
+		conds = append(conds, c)
+	}
+	return conds
+}
+
+// restrictToIDs keeps only results whose ID is listed. An empty list keeps all.
+func restrictToIDs(results []store.Result, ids []string) []store.Result {
+	if len(ids) == 0 {
+		return results
+	}
+	want := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		want[id] = true
+	}
+	out := []store.Result{}
+	for _, r := range results {
+		if want[r.ID] {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+func runResource(args []string) {
+	if len(args) == 0 {
+		args = []string{""}
+	}
+	switch args[0] {
+	case "move":
+		runResourceMove(args[1:])
+	case "list":
+		runResourceList(args[1:])
+	case "read":
+		runResourceRead(args[1:])
+	default:
+		fmt.Fprintln(os.Stderr, "Usage: clawbrain resource move --from PATH --to PATH | list [--limit N] | read URI")
+		os.Exit(1)
+	}
+}
+
+// runResourceMove rewrites the source paths of synced memories, and their
+// sync state, after the files were moved.
+func runResourceMove(args []string) {
+	fs := flag.NewFlagSet("resource move", flag.ExitOnError)
+	from := fs.String("from", "", "Current source path — a file or a directory (required)")
+	to := fs.String("to", "", "New source path (required)")
+	fs.Parse(args)
+
+	if *from == "" || *to == "" {
+		fmt.Fprintln(os.Stderr, "Error: --from and --to are required")
+		fs.Usage()
+		os.Exit(1)
+	}
+	*from = filepath.Clean(*from)
+	*to = filepath.Clean(*to)
+
+	s, ctx, cancel := connect()
+	defer cancel()
+	defer s.Close()
+
+	// Open the sync state up front: moving payloads without moving the
+	// sync state would make the next sync re-ingest the files under their
+	// new paths and duplicate every chunk.
+	st, err := openSyncState(ctx, s)
+	if err != nil {
+		exitJSON("error", fmt.Sprintf("sync state: %v", err))
+	}
+	defer st.Close()
+
+	// Both tiers: a cold memory left on the old path would come back
+	// pointing at a file that isn't there.
+	updated := 0
+	for _, t := range s.Tiers() {
+		memories, err := t.All(ctx)
+		if err != nil {
+			exitError(err)
+		}
+		updates := make(map[string]map[string]any)
+		for _, r := range memories {
+			src, ok := r.Payload["source"].(string)
+			if !ok {
+				continue
+			}
+			if moved, ok := sync.MovePath(src, *from, *to); ok {
+				updates[r.ID] = map[string]any{"source": moved}
+			}
+		}
+		if err := t.SetPayloads(ctx, updates); err != nil {
+			exitError(err)
+		}
+		updated += len(updates)
+	}
+
+	keys, err := st.Scan(sync.RedisKeyPattern(globalNamespace, *from))
+	if err != nil {
+		exitJSON("error", fmt.Sprintf("sync state scan: %v", err))
+	}
+	keysMoved := 0
+	for _, key := range keys {
+		moved, ok := sync.MovePath(sync.PathFromRedisKey(globalNamespace, key), *from, *to)
+		if !ok {
+			continue
+		}
+		if err := st.Rename(key, sync.RedisKey(globalNamespace, moved)); err != nil {
+			exitJSON("error", fmt.Sprintf("sync state rename %s: %v", key, err))
+		}
+		keysMoved++
+	}
+
+	outputJSON(map[string]any{
+		"status":          "ok",
+		"from":            *from,
+		"to":              *to,
+		"updated":         updated,
+		"sync_keys_moved": keysMoved,
+	})
+}
+
+func runSearch(args []string) {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	query := fs.String("query", "", "Text to search for (default mode)")
+	vectorJSON := fs.String("vector", "", "Query embedding as JSON array (advanced, overrides text mode)")
+	minScore := fs.Float64("min-score", 0.0, "Minimum similarity score threshold")
+	limit := fs.Uint64("limit", 1, "Maximum number of results (without it, a low-confidence search is retried wider)")
+	noWiden := fs.Bool("no-widen", false, "Don't retry a low-confidence search with a higher limit, lower --min-score and keyword matching")
+	offset := fs.Uint64("offset", 0, "Skip this many of the best matches, to page through results")
+	cursor := fs.String("cursor", "", "Resume from the next_cursor of a previous search (instead of --offset)")
+	var halfLife durationFlag
+	fs.Var(&halfLife, "half-life", "Decay scores by memory age with this half-life (e.g. 30d); off by default")
+	recencyBoost := fs.Float64("recency-boost", 0, "Add up to this much to the score of recently accessed memories (e.g. 0.05); off by default")
+	recencyScale := durationFlag(ranking.DefaultRecencyScale)
+	fs.Var(&recencyScale, "recency-scale", "Half-life of the recency boost, measured from last_accessed (needs --recency-boost)")
+	perTypeSpec := fs.String("per-type-limit", "", "Balance results across types, e.g. todo=2,lesson=2,*=1 (unlisted types are excluded unless * is given)")
+	route := fs.Bool("route", false, "Classify the query's intent and pick a retrieval strategy automatically (text mode only)")
+	hybrid := fs.Bool("hybrid", false, "Fuse vector similarity with keyword matches on the query's words (needs --query)")
+	keywordWeight := fs.Float64("keyword-weight", ranking.DefaultKeywordWeight, "Weight of keyword matches relative to similarity in a hybrid search (implies --hybrid)")
+	noProfile := fs.Bool("no-profile", false, "Ignore the ranking profile stored with the collection (see the ranking command)")
+	var boosts multiFlag
+	fs.Var(&boosts, "boost", "Add this much to the score of memories of a type, e.g. type:todo=0.05 (repeatable; over the config's boosts)")
+	useCache := fs.Bool("cache", false, "Serve repeated queries from the Redis search cache (text mode only)")
+	cacheTTL := durationFlag(cache.DefaultTTL)
+	fs.Var(&cacheTTL, "cache-ttl", "How long cached results live (implies --cache)")
+	var filters, types, tags, mustContain multiFlag
+	fs.Var(&filters, "filter", "Payload filter KEY=VALUE, KEY>=N, KEY<N or KEY~LAT,LON,RADIUS (repeatable, ANDed)")
+	fs.Var(&types, "type", "Only memories of this type, e.g. todo; \"untyped\" matches memories without one (repeatable, ORed)")
+	fs.Var(&tags, "tag", "Only memories with this tag, e.g. project:billing (repeatable, ANDed)")
+	fs.Var(&mustContain, "must-contain", "Only memories whose text contains this exact phrase, ignoring case (repeatable, ANDed)")
+	author := fs.String("author", "", "Only memories written by this author (case-insensitive)")
+	speaker := fs.String("speaker", "", "Only memories said by this speaker (case-insensitive)")
+	since := fs.String("since", "", "Only memories stored at or after this time: a duration ago (24h, 7d), an RFC 3339 timestamp or a date")
+	until := fs.String("until", "", "Only memories stored before this time (same forms as --since)")
+	timeField := fs.String("time-field", store.CreatedAtField, "Timestamp --since and --until compare: created_at or last_accessed")
+	includePersonal := fs.Bool("include-personal", false, "Include personal memories in a shared context (--shared)")
+	includeSuperseded := fs.Bool("include-superseded", false, "Include memories superseded by a newer one (add --supersedes)")
+	includeArchived := fs.Bool("include-archived", false, "Include archived memories; those archived by forget are restored when found")
+	includeCold := fs.Bool("include-cold", false, "Also search the cold tier (see tier); cold memories found move back")
+	queriesFile := fs.String("queries-file", "", "Run every query in this JSONL file (- for stdin) in one process; other flags apply to all of them")
+	selectSpec := fs.String("select", "", "Only output these fields of each result, e.g. id,score,payload.text")
+	related := fs.Bool("related", false, "Add the IDs of each result's 2 nearest neighbors and linked memories, for a follow-up get --ids")
+	rerankResults := fs.Bool("rerank", false, "Grade the best vector matches against the query with a generative model and return the best graded (needs --query)")
+	rerankModel := fs.String("rerank-model", "", "Model that grades matches for --rerank (default: --llm-model)")
+	rerankCandidates := fs.Uint64("rerank-candidates", reranker.DefaultCandidates, "How many vector matches --rerank grades")
+	expand := fs.Bool("expand", false, "Also search for paraphrases of the query from a generative model and fuse the rankings (needs --query)")
+	expandModel := fs.String("expand-model", "", "Model that paraphrases the query for --expand (default: --llm-model)")
+	fs.Parse(args)
+
+	sel, err := selector.Parse(*selectSpec)
+	if err != nil {
+		exitError(err)
+	}
+
+	if *queriesFile != "" && (*query != "" || *vectorJSON != "") {
+		exitJSON("error", "--queries-file can't be combined with --query or --vector")
+	}
+	if *queriesFile != "" && (*useCache || flagSet(fs, "cache-ttl")) {
+		exitJSON("error", "--cache is not supported with --queries-file")
+	}
+	if flagSet(fs, "cache-ttl") {
+		*useCache = true
+	}
+	if *useCache && (*query == "" || *vectorJSON != "") {
+		exitJSON("error", "--cache requires --query (text mode)")
+	}
+	if *useCache && cacheTTL <= 0 {
+		exitJSON("error", "cache-ttl must be positive")
+	}
+
+	if halfLife < 0 {
+		exitJSON("error", "half-life must be non-negative")
+	}
+	if *recencyBoost < 0 {
+		exitJSON("error", "recency-boost must be non-negative")
+	}
+	if flagSet(fs, "recency-scale") && *recencyBoost == 0 {
+		exitJSON("error", "--recency-scale requires --recency-boost")
+	}
+	if recencyScale <= 0 {
+		exitJSON("error", "recency-scale must be positive")
+	}
+	if *route && *perTypeSpec != "" {
+		exitJSON("error", "--route and --per-type-limit are mutually exclusive")
+	}
+	if *route && *query == "" && *queriesFile == "" {
+		exitJSON("error", "--route requires --query")
+	}
+	if len(types) > 0 && (*route || *perTypeSpec != "") {
+		exitJSON("error", "--type can't be combined with --route or --per-type-limit")
+	}
+	if flagSet(fs, "keyword-weight") {
+		*hybrid = true
+	}
+	if *hybrid && *keywordWeight < 0 {
+		exitJSON("error", "keyword-weight must be non-negative")
+	}
+	if *hybrid && *query == "" && *queriesFile == "" {
+		exitJSON("error", "--hybrid requires --query")
+	}
+	if *hybrid && *perTypeSpec != "" {
+		exitJSON("error", "--hybrid and --per-type-limit are mutually exclusive")
+	}
+	if *includeCold && *perTypeSpec != "" {
+		exitJSON("error", "--include-cold and --per-type-limit are mutually exclusive")
+	}
+	if *cursor != "" && flagSet(fs, "offset") {
+		exitJSON("error", "--offset and --cursor are mutually exclusive")
+	}
+	if *cursor != "" {
+		var err error
+		if *offset, err = store.DecodeCursor(*cursor); err != nil {
+			exitError(err)
+		}
+	}
+	if *offset > 0 && *perTypeSpec != "" {
+		exitJSON("error", "--offset and --cursor can't be combined with --per-type-limit")
+	}
+	if flagSet(fs, "rerank-model") || flagSet(fs, "rerank-candidates") {
+		*rerankResults = true
+	}
+	if *rerankResults {
+		switch {
+		case *query == "" && *queriesFile == "":
+			exitJSON("error", "--rerank requires --query")
+		case *perTypeSpec != "":
+			exitJSON("error", "--rerank and --per-type-limit are mutually exclusive")
+		case *offset > 0:
+			exitJSON("error", "--rerank can't be combined with --offset or --cursor")
+		case *rerankCandidates == 0:
+			exitJSON("error", "rerank-candidates must be positive")
+		}
+	}
+	if flagSet(fs, "expand-model") {
+		*expand = true
+	}
+	if *expand {
+		switch {
+		case *query == "" && *queriesFile == "":
+			exitJSON("error", "--expand requires --query")
+		case *perTypeSpec != "":
+			exitJSON("error", "--expand and --per-type-limit are mutually exclusive")
+		case *offset > 0:
+			exitJSON("error", "--expand can't be combined with --offset or --cursor")
+		}
+	}
+
+	opts := searchOptions{
+		minScore: float32(*minScore),
+		limit:    *limit,
+		offset:   *offset,
+		halfLife: time.Duration(halfLife),
+		hybrid:   *hybrid,
+		// Cold memories are searched only when asked for: keeping them out
+		// of the way is what the cold tier is for.
+		includeCold: *includeCold,
+		related:     *related,
+		// Only the default limit widens: an explicit one, a page past the
+		// first or a per-type mix is what the caller asked for.
+		widen: !*noWiden && !flagSet(fs, "limit") && *offset == 0 && *perTypeSpec == "",
+	}
+	if *recencyBoost > 0 {
+		opts.recencyBoost = *recencyBoost
+		opts.recencyScale = time.Duration(recencyScale)
+	}
+	if *hybrid {
+		opts.keywordWeight = *keywordWeight
+	}
+	opts.filter.ExcludePersonal = globalShared && !*includePersonal
+	opts.filter.ExcludeArchived = !*includeArchived
+	opts.filter.ExcludeSuperseded = !*includeSuperseded
+	for _, f := range filters {
+		c, err := store.ParseCondition(f)
+		if err != nil {
+			exitError(err)
+		}
+		if err := c.CheckSearchable(); err != nil {
+			exitError(err)
+		}
+		opts.filter.Conditions = append(opts.filter.Conditions, c)
+	}
+	opts.filter.Conditions = append(opts.filter.Conditions, tagConditions(tags)...)
+	if opts.filter.MustContain, err = mustContainPhrases(mustContain); err != nil {
+		exitError(err)
+	}
+	for _, attr := range []struct{ field, name string }{
+		{store.AuthorField, *author},
+		{store.SpeakerField, *speaker},
+	} {
+		if name := store.NormalizeName(attr.name); name != "" {
+			opts.filter.Conditions = append(opts.filter.Conditions, store.Condition{Key: attr.field, Op: "=", Value: name})
+		}
+	}
+	if err := setTimeRange(&opts.filter, *since, *until, *timeField, time.Now()); err != nil {
+		exitError(err)
+	}
+	if len(types) > 0 {
+		only, err := store.TypeFilter(types, loadConfig().MemoryTypes())
+		if err != nil {
+			exitError(err)
+		}
+		opts.filter.Types = only
+	}
+	if *perTypeSpec != "" {
+		perType, err := ranking.ParseTypeLimits(*perTypeSpec)
+		if err != nil {
+			exitError(err)
+		}
+		opts.perType = perType
+		// Without an explicit --limit, return the full mix.
+		if !flagSet(fs, "limit") {
+			opts.limit = ranking.Total(perType)
+		}
+	}
+
+	if *rerankResults {
+		opts.rerankModel = *rerankModel
+		if opts.rerankModel == "" {
+			opts.rerankModel = globalLLMModel
+		}
+		opts.rerankCandidates = *rerankCandidates
+	}
+	if *expand {
+		opts.expandModel = *expandModel
+		if opts.expandModel == "" {
+			opts.expandModel = globalLLMModel
+		}
+	}
+
+	opts.noProfile = *noProfile
+	if opts.typeBoosts, err = typeBoosts(boosts); err != nil {
+		exitError(err)
+	}
+
+	if *queriesFile != "" {
+		runBatchSearch(*queriesFile, opts, sel, *route, flagSet(fs, "half-life"))
+		return
+	}
+
+	s, ctx, cancel := connect()
+	defer cancel()
+	defer s.Close()
+	if err := applyProfile(ctx, s, &opts); err != nil {
+		exitError(err)
+	}
+
+	var c *cache.Cache
+	var cacheKey string
+	if *useCache {
+		c = openCache(time.Duration(cacheTTL))
+		if c != nil {
+			cacheKey = cache.Key(*query, cacheScope(opts, *route))
+			if serveCached(ctx, s, c, cacheKey, sel) {
+				return
+			}
+		}
+	}
+
+	var vector []float32
+	if *vectorJSON != "" {
+		// Advanced vector mode
+		if err := json.Unmarshal([]byte(*vectorJSON), &vector); err != nil {
+			exitJSON("error", fmt.Sprintf("invalid vector JSON: %v", err))
+		}
+	} else if *query != "" {
+		// Default text mode: embed query via Ollama, then search
+		emb := newEmbedder()
+		var err error
+		vector, err = emb.Embed(ctx, globalModel, *query)
+		if err != nil {
+			exitError(fmt.Errorf("embedding failed: %w", err))
+		}
+	} else {
+		fmt.Fprintln(os.Stderr, "Error: --query is required (or --vector for advanced mode)")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	guardEmbedding(ctx, s, vector, *vectorJSON == "")
+	response, results, err := runQuery(ctx, s, vector, *query, opts, *route, flagSet(fs, "half-life"))
+	if err != nil {
+		exitError(err)
+	}
+	if c != nil {
+		storeCached(c, cacheKey, *query, results, response)
+		response["cached"] = false
+	}
+	if err := selectResults(response, sel); err != nil {
+		exitError(err)
+	}
+	outputJSON(limitResponse(response, "results"))
+}
+
+// selectResults projects each of the response's results down to the
+// selected fields. The rest of the response is left alone.
+func selectResults(response map[string]any, sel selector.Selector) error {
+	if sel == nil {
+		return nil
+	}
+	projected, err := sel.ApplyAll(response["results"])
+	if err != nil {
+		return err
+	}
+	response["results"] = projected
+	return nil
+}
+
+// runQuery runs one search for vector and builds its response. With route,
+// the query text picks the retrieval strategy; halfLifeSet tells it not to
+// override an explicit --half-life.
+func runQuery(ctx context.Context, s *store.Store, vector []float32, query string, opts searchOptions, route, halfLifeSet bool) (map[string]any, []store.Result, error) {
+	var routed *router.Route
+	baseFilter := opts.filter
+	narrowed := false
+	if route {
+		r := router.Classify(query)
+		routed = &r
+		narrowed = applyRoute(r, &opts, halfLifeSet)
+	}
+	if opts.hybrid {
+		opts.keywords = ranking.Terms(query)
+	}
+	// A rerank grades more matches than it returns.
+	limit := opts.limit
+	if opts.rerankModel != "" {
+		opts.limit = max(opts.limit, opts.rerankCandidates)
+	}
+
+	results, err := retrieve(ctx, s, vector, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	response := map[string]any{
+		"status":     "ok",
+		"results":    results,
+		"returned":   len(results),
+		"confidence": confidence(results),
+	}
+	if routed != nil {
+		// A filtered route can miss memories that don't literally match
+		// (e.g. an untyped todo); fall back to plain similarity rather than
+		// answer with nothing.
+		if len(results) == 0 && narrowed {
+			opts.filter = baseFilter
+			results, err = retrieve(ctx, s, vector, opts)
+			if err != nil {
+				return nil, nil, err
+			}
+			response["results"] = results
+			response["returned"] = len(results)
+			response["confidence"] = confidence(results)
+			response["route_fallback"] = true
+		}
+		response["route"] = routed
+	}
+	if opts.widen && (len(results) == 0 || results[0].Score < mediumConfidence) {
+		wider, widened := widenOptions(opts, query)
+		results, err = retrieve(ctx, s, vector, wider)
+		if err != nil {
+			return nil, nil, err
+		}
+		widened["from_confidence"] = response["confidence"]
+		opts = wider
+		response["results"] = results
+		response["returned"] = len(results)
+		response["confidence"] = confidence(results)
+		response["widened"] = widened
+	}
+	if opts.expandModel != "" {
+		// Confidence stays that of the query as asked.
+		results, err = expandQuery(ctx, s, query, results, opts, response)
+		if err != nil {
+			return nil, nil, err
+		}
+		response["results"] = results
+		response["returned"] = len(results)
+	}
+	if opts.rerankModel != "" {
+		// Confidence stays that of the best similarity: grades aren't
+		// scores.
+		results = rerankQuery(query, results, opts, response)
+		if uint64(len(results)) > limit {
+			results = results[:limit]
+		}
+		opts.limit = limit
+		response["results"] = results
+		response["returned"] = len(results)
+	}
+	if opts.hybrid {
+		response["keywords"] = opts.keywords
+	}
+	if opts.rankingProfile != "" {
+		response["ranking_profile"] = opts.rankingProfile
+	}
+	if len(opts.perType) > 0 {
+		byType := map[string]int{}
+		for _, r := range results {
+			byType[retention.TypeOf(r.Payload)]++
+		}
+		response["by_type"] = byType
+	}
+	if opts.offset > 0 {
+		response["offset"] = opts.offset
+	}
+	if opts.related {
+		related, err := relatedHints(ctx, s, results, opts.filter.ExcludePersonal)
+		if err != nil {
+			return nil, nil, err
+		}
+		response["related"] = related
+	}
+	if cards := decisionCards(results); cards != nil {
+		response["cards"] = cards
+	}
+	// A full page may have more behind it; a short one is the last. A
+	// reranked or expanded page has no next: the grades and the fusion
+	// covered the candidates only.
+	if len(opts.perType) == 0 && opts.rerankModel == "" && opts.expandModel == "" && opts.limit > 0 && uint64(len(results)) == opts.limit {
+		response["next_cursor"] = store.EncodeCursor(opts.offset + opts.limit)
+	}
+	return response, results, nil
+}
+
+// rerankTimeout bounds grading a search's candidates: one generation per
+// candidate, on models that may run on CPU.
+const rerankTimeout = 2 * time.Minute
+
+// rerankQuery reorders results by opts.rerankModel's grades for query (see
+// reranker.Rerank) and reports the rerank in response: the model, how many
+// candidates it graded and their grades by ID. Reranking is best effort:
+// if the model fails, results come back in similarity order and
+// rerank_error says why.
+func rerankQuery(query string, results []store.Result, opts searchOptions, response map[string]any) []store.Result {
+	ctx, cancel := context.WithTimeout(context.Background(), rerankTimeout)
+	defer cancel()
+	reranked, grades, err := reranker.Rerank(ctx, ollama.New(globalOllamaURL), opts.rerankModel, query, results)
+	if err != nil {
+		response["rerank_error"] = err.Error()
+		return results
+	}
+	response["reranked"] = map[string]any{
+		"model":      opts.rerankModel,
+		"candidates": len(results),
+		"grades":     grades,
+	}
+	return reranked
+}
+
+// expandTimeout bounds paraphrasing a query and embedding the paraphrases.
+const expandTimeout = 2 * time.Minute
+
+// expandQuery searches for paraphrases of query from opts.expandModel (see
+// expansion.Expand) and fuses their matches with results, the query's own,
+// by reciprocal rank (see ranking.FuseQueries). The response reports the
+// model and the paraphrases. Expansion is best effort: if the model or the
+// embedding fails, results come back as they were and expand_error says
+// why. Paraphrases search the hot collection only.
+func expandQuery(ctx context.Context, s *store.Store, query string, results []store.Result, opts searchOptions, response map[string]any) ([]store.Result, error) {
+	gctx, cancel := context.WithTimeout(context.Background(), expandTimeout)
+	defer cancel()
+	queries, err := expansion.Expand(gctx, ollama.New(globalOllamaURL), opts.expandModel, query, expansion.DefaultQueries)
+	if err == nil {
+		var vectors [][]float32
+		if vectors, err = newEmbedder().EmbedBatch(gctx, globalModel, queries); err == nil {
+			lists := [][]store.Result{results}
+			for _, vector := range vectors {
+				found, err := candidates(ctx, s, vector, opts, opts.filter, opts.limit)
+				if err != nil {
+					return nil, err
+				}
+				lists = append(lists, found)
+			}
+			return fuseExpanded(ctx, s, results, lists, queries, opts, response), nil
+		}
+		err = fmt.Errorf("embed paraphrases: %w", err)
+	}
+	response["expand_error"] = err.Error()
+	return results, nil
+}
+
+// fuseExpanded fuses the rankings of an expanded search, keeps the best
+// opts.limit and marks those the query alone didn't find as recalled, as
+// retrieve did the rest.
+func fuseExpanded(ctx context.Context, s *store.Store, results []store.Result, lists [][]store.Result, queries []string, opts searchOptions, response map[string]any) []store.Result {
+	fused := ranking.FuseQueries(lists...)
+	if uint64(len(fused)) > opts.limit {
+		fused = fused[:opts.limit]
+	}
+	var found []store.Result
+	for _, r := range fused {
+		if !slices.ContainsFunc(results, func(o store.Result) bool { return o.ID == r.ID }) {
+			found = append(found, r)
+		}
+	}
+	s.Touch(ctx, found)
+	if !opts.filter.ExcludeArchived {
+		restoreArchived(ctx, s, found)
+	}
+	response["expanded"] = map[string]any{
+		"model":   opts.expandModel,
+		"queries": queries,
+		"found":   len(found),
+	}
+	return fused
+}
+
+// decisionCards renders the decisions among results as cards, keyed by
+// ID, or returns nil if there are none.
+func decisionCards(results []store.Result) map[string]string {
+	var cards map[string]string
+	for _, r := range results {
+		if !decision.Is(r.Payload) {
+			continue
+		}
+		if cards == nil {
+			cards = map[string]string{}
+		}
+		cards[r.ID] = decision.Card(r.Payload)
+	}
+	return cards
+}
+
+// batchQuery is one line of a --queries-file. ID is echoed back so callers
+// can match answers to questions; Limit and MinScore override the flags.
+type batchQuery struct {
+	ID       string    `json:"id,omitempty"`
+	Query    string    `json:"query,omitempty"`
+	Vector   []float32 `json:"vector,omitempty"`
+	Limit    *uint64   `json:"limit,omitempty"`
+	MinScore *float32  `json:"min_score,omitempty"`
+}
+
+// Batch search tuning: texts per Ollama embed request, searches in flight
+// against Qdrant, and the time budget for the whole batch.
+const (
+	batchEmbedSize     = 64
+	batchConcurrency   = 8
+	batchSearchTimeout = 10 * time.Minute
+)
+
+// readBatchQueries parses a --queries-file, exiting on the first bad line.
+func readBatchQueries(path string) []batchQuery {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			exitError(err)
+		}
+		defer f.Close()
+		r = f
+	}
+	var queries []batchQuery
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var q batchQuery
+		if err := json.Unmarshal([]byte(text), &q); err != nil {
+			exitJSON("error", fmt.Sprintf("%s line %d: %v", path, line, err))
+		}
+		if (q.Query == "") == (len(q.Vector) == 0) {
+			exitJSON("error", fmt.Sprintf("%s line %d: need exactly one of \"query\" or \"vector\"", path, line))
+		}
+		queries = append(queries, q)
+	}
+	if err := scanner.Err(); err != nil {
+		exitError(err)
+	}
+	if len(queries) == 0 {
+		exitJSON("error", fmt.Sprintf("%s has no queries", path))
+	}
+	return queries
+}
+
+// runBatchSearch runs every query in a --queries-file with the shared
+// options. Query texts are embedded in batches, and the searches run
+// concurrently. A failed search is reported in its own entry rather than
+// failing the batch; results keep the order of the file.
+func runBatchSearch(path string, opts searchOptions, sel selector.Selector, route, halfLifeSet bool) {
+	queries := readBatchQueries(path)
+	for i, q := range queries {
+		if route && q.Query == "" {
+			exitJSON("error", fmt.Sprintf("query %d: --route requires a query text", i+1))
+		}
+	}
+
+	s, err := openStore()
+	if err != nil {
+		exitError(err)
+	}
+	defer s.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), batchSearchTimeout)
+	defer cancel()
+	if err := applyProfile(ctx, s, &opts); err != nil {
+		exitError(err)
+	}
+
+	// Embed the texts, batchEmbedSize per request.
+	var texts []int
+	for i, q := range queries {
+		if q.Query != "" {
+			texts = append(texts, i)
+		}
+	}
+	emb := newEmbedder()
+	for start := 0; start < len(texts); start += batchEmbedSize {
+		chunk := texts[start:min(start+batchEmbedSize, len(texts))]
+		inputs := make([]string, len(chunk))
+		for j, i := range chunk {
+			inputs[j] = queries[i].Query
+		}
+		vectors, err := emb.EmbedBatch(ctx, globalModel, inputs)
+		if err != nil {
+			exitError(fmt.Errorf("embedding failed: %w", err))
+		}
+		for j, i := range chunk {
+			queries[i].Vector = vectors[j]
+		}
+	}
+
+	responses := make([]map[string]any, len(queries))
+	jobs := make(chan int)
+	var wg gosync.WaitGroup
+	for range min(batchConcurrency, len(queries)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				q := queries[i]
+				qopts := opts
+				if q.Limit != nil {
+					qopts.limit = *q.Limit
+					qopts.widen = false
+				}
+				if q.MinScore != nil {
+					qopts.minScore = *q.MinScore
+				}
+				var response map[string]any
+				err := checkEmbedding(ctx, s, q.Vector, q.Query != "")
+				if err == nil {
+					response, _, err = runQuery(ctx, s, q.Vector, q.Query, qopts, route, halfLifeSet)
+				}
+				if err == nil {
+					err = selectResults(response, sel)
+				}
+				if err == nil {
+					response, err = loadConfig().Limits.Apply(response, "results")
+				}
+				if err != nil {
+					response = map[string]any{"status": "error", "message": err.Error()}
+				}
+				if q.ID != "" {
+					response["id"] = q.ID
+				}
+				if q.Query != "" {
+					response["query"] = q.Query
+				}
+				responses[i] = response
+			}
+		}()
+	}
+	for i := range queries {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	failed := 0
+	for _, r := range responses {
+		if r["status"] != "ok" {
+			failed++
+		}
+	}
+	outputJSON(map[string]any{
+		"status":  "ok",
+		"queries": len(queries),
+		"failed":  failed,
+		"results": responses,
+	})
+}
+
+// openCache returns the search cache, or nil if it is off or Redis is
+// unreachable; the search then runs uncached, with a warning.
+func openCache(ttl time.Duration) *cache.Cache {
+	if !cacheEnabled() {
+		log.Printf("warning: search cache unavailable: it needs --state-backend redis")
+		return nil
+	}
+	rc, err := cacheRedis()
+	if err != nil {
+		log.Printf("warning: search cache unavailable: %v", err)
+		return nil
+	}
+	return cache.New(rc, ttl)
+}
+
+// tagConditions turns --tag values into filter conditions; for the tags
+// array a condition matches if any element does, so each one requires that
+// tag. Exits on an empty tag.
+func tagConditions(tags []string) []store.Condition {
+	conds := make([]store.Condition, 0, len(tags))
+	for _, tag := range tags {
+		if strings.TrimSpace(tag) == "" {
+			exitJSON("error", "--tag must not be empty")
+		}
+		conds = append(conds, store.Condition{Key: store.TagsField, Op: "=", Value: tag})
+	}
+	return conds
+}
+
+// setTimeRange sets the time range of a search filter from --since, --until
+// and --time-field; empty bounds leave that side open.
+func setTimeRange(f *store.Filter, since, until, field string, now time.Time) error {
+	if field != store.CreatedAtField && field != store.LastAccessedField {
+		return fmt.Errorf("invalid time field %q: want %s or %s", field, store.CreatedAtField, store.LastAccessedField)
+	}
+	var err error
+	if since != "" {
+		if f.Since, err = retention.ParseTime(since, now); err != nil {
+			return err
+		}
+	}
+	if until != "" {
+		if f.Until, err = retention.ParseTime(until, now); err != nil {
+			return err
+		}
+	}
+	if !f.Since.IsZero() && !f.Until.IsZero() && !f.Since.Before(f.Until) {
+		return fmt.Errorf("--since %s is not before --until %s", f.Since.Format(time.RFC3339), f.Until.Format(time.RFC3339))
+	}
+	f.TimeField = field
+	return nil
+}
+
+// mustContainPhrases checks the phrases of --must-contain, returning an
+// error for an empty one.
+func mustContainPhrases(phrases []string) ([]string, error) {
+	for _, phrase := range phrases {
+		if strings.TrimSpace(phrase) == "" {
+			return nil, fmt.Errorf("must-contain phrase must not be empty")
+		}
+	}
+	return phrases, nil
+}
+
+// cacheScope captures every setting besides the query text that changes what
+// a search returns, so differently configured searches don't share entries.
+func cacheScope(opts searchOptions, route bool) string {
+	return fmt.Sprintf("model=%s namespace=%s sandbox=%s agent=%s limit=%d widen=%t offset=%d min=%g half=%s recency=%g/%s types=%v only=%v route=%t hybrid=%t/%g cold=%t related=%t rerank=%s/%d expand=%s filters=%v time=%s/%s/%s must_contain=%q no_personal=%t no_superseded=%t no_archived=%t profile=%s/%g/%v/%g",
+		globalModel, globalNamespace, globalSandbox, globalAgent, opts.limit, opts.widen, opts.offset, opts.minScore, opts.halfLife, opts.recencyBoost, opts.recencyScale, opts.perType, opts.filter.Types, route,
+		opts.hybrid, opts.keywordWeight, opts.includeCold, opts.related, opts.rerankModel, opts.rerankCandidates, opts.expandModel, opts.filter.Conditions, opts.filter.TimeField, opts.filter.Since.Format(time.RFC3339), opts.filter.Until.Format(time.RFC3339), opts.filter.MustContain, opts.filter.ExcludePersonal, opts.filter.ExcludeSuperseded, opts.filter.ExcludeArchived,
+		opts.rankingProfile, opts.frequencyWeight, opts.typeBoosts, opts.pinnedBonus)
+}
+
+// serveCached prints the cached response for key, if there is one, and
+// reports whether it did. The cached memories are marked as accessed, as if
+// the search had run.
+func serveCached(ctx context.Context, s *store.Store, c *cache.Cache, key string, sel selector.Selector) bool {
+	entry, ok, err := c.Get(key)
+	if err != nil {
+		log.Printf("warning: search cache read failed: %v", err)
+		return false
+	}
+	if !ok {
+		return false
+	}
+	var response map[string]any
+	if err := json.Unmarshal(entry.Response, &response); err != nil {
+		return false
+	}
+
+	var cached struct {
+		Results []store.Result `json:"results"`
+	}
+	json.Unmarshal(entry.Response, &cached)
+	s.Touch(ctx, cached.Results)
+
+	response["cached"] = true
+	response["cached_at"] = entry.CachedAt
+	if err := selectResults(response, sel); err != nil {
+		exitError(err)
+	}
+	outputJSON(limitResponse(response, "results"))
+	return true
+}
+
+// storeCached saves a search response, indexed by the query's entities and
+// the results' tags so a relevant add invalidates it.
+func storeCached(c *cache.Cache, key, query string, results []store.Result, response map[string]any) {
+	data, err := json.Marshal(response)
+	if err != nil {
+		return
+	}
+	var tags []string
+	for _, r := range results {
+		tags = store.WithTags(tags, store.Tags(r.Payload)...)
+	}
+	entry := cache.Entry{Query: query, Entities: router.Entities(query), Tags: tags, Response: data}
+	if err := c.Put(key, entry); err != nil {
+		log.Printf("warning: search cache write failed: %v", err)
+	}
+}
+
+// ensureFieldIndexes creates Qdrant indexes for the config's typed fields.
+// The memory is already stored, so a failure is only a warning: filters
+// still work without an index, just slower.
+func ensureFieldIndexes(ctx context.Context, s *store.Store, fields map[string]store.FieldKind) {
+	if err := s.EnsureIndexes(ctx, fields); err != nil {
+		log.Printf("warning: indexing typed fields: %v", err)
+	}
+}
+
+// The search cache's Redis connection, dialed once per process the first
+// time the cache is used; see cacheRedis. cacheMu serializes its use, as a
+// redis.Client isn't safe for concurrent calls.
+var (
+	cacheRedisOnce gosync.Once
+	cacheRedisConn *redis.Client
+	cacheRedisErr  error
+	cacheMu        gosync.Mutex
+)
+
+// cacheEnabled reports whether searches can be cached. The cache lives in
+// Redis, beside the sync state, so it is on only with --state-backend
+// redis; with another backend nothing needs Redis at all.
+func cacheEnabled() bool {
+	return globalState == sync.StateRedis
+}
+
+// cacheRedis returns the search cache's Redis connection, dialing it on
+// first use. A failed dial isn't retried within the process.
+func cacheRedis() (*redis.Client, error) {
+	cacheRedisOnce.Do(func() {
+		cacheRedisConn, cacheRedisErr = redis.New(globalRedisHost, globalRedisPort)
+	})
+	return cacheRedisConn, cacheRedisErr
+}
+
+// invalidateCache drops cached searches newly stored memories could change.
+// With the cache off there is nothing to invalidate, and without Redis no
+// cache either, so connection failures are silently ignored; other failures
+// are logged.
+func invalidateCache(payloads ...map[string]any) {
+	if !cacheEnabled() {
+		return
+	}
+	rc, err := cacheRedis()
+	if err != nil {
+		return
+	}
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	c := cache.New(rc, cache.DefaultTTL)
+	for _, payload := range payloads {
+		text, _ := payload["text"].(string)
+		if _, err := c.Invalidate(text, store.Tags(payload)); err != nil {
+			log.Printf("warning: search cache invalidation failed: %v", err)
+			return
+		}
+	}
+}
+
+// searchOptions carries the query-time ranking settings of a search.
+type searchOptions struct {
+	minScore float32
+	limit    uint64
+	// offset skips that many of the best matches, for paging.
+	offset   uint64
+	halfLife time.Duration
+	// recencyBoost is added to the scores of recently accessed memories,
+	// decaying at a half-life of recencyScale.
+	recencyBoost float64
+	recencyScale time.Duration
+	perType      []ranking.TypeLimit
+	filter       store.Filter
+	// hybrid fuses keyword matches on the query's words into the ranking;
+	// runQuery fills keywords from the query text.
+	hybrid        bool
+	keywordWeight float64
+	keywords      []string
+	// widen retries a search whose top score is below medium confidence
+	// with widenOptions, once.
+	widen bool
+	// Weights from the collection's ranking profile, filled in by
+	// applyProfile unless noProfile; rankingProfile names the profile used.
+	frequencyWeight float64
+	typeBoosts      map[string]float64
+	pinnedBonus     float64
+	noProfile       bool
+	rankingProfile  string
+	// includeCold also searches the cold tier; see runTier.
+	includeCold bool
+	// related adds each result's nearest neighbors and links to the
+	// response; see relatedHints.
+	related bool
+	// rerankModel, if set, grades the best rerankCandidates matches
+	// against the query text; see rerankQuery.
+	rerankModel      string
+	rerankCandidates uint64
+	// expandModel, if set, paraphrases the query text and fuses the
+	// paraphrases' matches into the results; see expandQuery.
+	expandModel string
+}
+
+// reranks reports whether the options adjust similarity scores, so more
+// candidates must be fetched than returned.
+func (o searchOptions) reranks() bool {
+	return o.halfLife > 0 || o.recencyBoost > 0 || o.frequencyWeight > 0 || len(o.typeBoosts) > 0 || o.pinnedBonus > 0
+}
+
+// applyProfile fills in opts from the ranking profile that applies to
+// --agent: its own, or the collection's. An explicit recency boost wins
+// over the profile's, as do explicit type boosts for their types. Without a
+// profile, or with noProfile, opts is left alone.
+func applyProfile(ctx context.Context, s *store.Store, opts *searchOptions) error {
+	if opts.noProfile {
+		return nil
+	}
+	p, source, ok, err := loadProfile(ctx, s)
+	if err != nil || !ok {
+		return err
+	}
+	if opts.recencyBoost == 0 && p.RecencyBoost > 0 {
+		opts.recencyBoost = p.RecencyBoost
+		opts.recencyScale = p.Scale()
+	}
+	opts.frequencyWeight = p.FrequencyWeight
+	boosts := maps.Clone(p.TypeBoosts)
+	if len(opts.typeBoosts) > 0 {
+		if boosts == nil {
+			boosts = make(map[string]float64, len(opts.typeBoosts))
+		}
+		maps.Copy(boosts, opts.typeBoosts)
+	}
+	opts.typeBoosts = boosts
+	opts.pinnedBonus = p.PinnedBonus
+	opts.rankingProfile = source
+	return nil
+}
+
+// typeBoosts returns the type boosts of the config file with specs like
+// "type:todo=0.05" (search --boost) over them, or nil if there are none.
+func typeBoosts(specs []string) (map[string]float64, error) {
+	cfg := loadConfig()
+	boosts := cfg.TypeBoosts()
+	for _, spec := range specs {
+		typ, boost, err := ranking.ParseBoost(spec)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := store.TypeFilter([]string{typ}, cfg.MemoryTypes()); err != nil {
+			return nil, fmt.Errorf("boost %q: %w", spec, err)
+		}
+		if boosts == nil {
+			boosts = map[string]float64{}
+		}
+		boosts[typ] = boost
+	}
+	return boosts, nil
+}
+
+// collectionProfile is what rankingProfile says of the collection's own
+// profile, which is stored under no agent.
+const collectionProfile = "collection"
+
+// loadProfile returns the ranking profile that applies to --agent and
+// whose it is: the agent's name, or collectionProfile. ok is false if
+// neither the agent nor the collection has one.
+func loadProfile(ctx context.Context, s *store.Store) (p ranking.Profile, source string, ok bool, err error) {
+	profiles, err := rankingProfiles(ctx, s)
+	if err != nil {
+		return ranking.Profile{}, "", false, err
+	}
+	p, key, ok := ranking.Resolve(profiles, globalAgent)
+	if key == "" {
+		key = collectionProfile
+	}
+	return p, key, ok, nil
+}
+
+// rankingProfiles decodes every ranking profile stored with the collection.
+func rankingProfiles(ctx context.Context, s *store.Store) (map[string]ranking.Profile, error) {
+	raw, err := s.RankingProfiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	profiles := make(map[string]ranking.Profile, len(raw))
+	for agent, data := range raw {
+		var p ranking.Profile
+		if err := json.Unmarshal(data, &p); err != nil {
+			return nil, fmt.Errorf("ranking profile %q: %w", agent, err)
+		}
+		profiles[agent] = p
+	}
+	return profiles, nil
+}
+
+// A low-confidence search with the default limit is retried once with
+// widenLimit results, or widenShortLimit for queries of shortQueryTerms
+// words or fewer: those are the vague ones, where the memory wanted is
+// least likely to rank first.
+const (
+	widenLimit      = 5
+	widenShortLimit = 10
+	shortQueryTerms = 2
+)
+
+// widenOptions returns opts widened for the retry of a low-confidence
+// search: a higher limit, half the min score, and keyword matches fused in
+// when there is a query text to take them from. It also returns what it
+// changed, for the response.
+func widenOptions(opts searchOptions, query string) (searchOptions, map[string]any) {
+	widened := map[string]any{}
+	terms := ranking.Terms(query)
+	limit := uint64(widenLimit)
+	if query != "" && len(terms) <= shortQueryTerms {
+		limit = widenShortLimit
+	}
+	if limit > opts.limit {
+		opts.limit = limit
+		widened["limit"] = limit
+	}
+	if opts.minScore > 0 {
+		opts.minScore /= 2
+		widened["min_score"] = opts.minScore
+	}
+	if !opts.hybrid && len(terms) > 0 {
+		opts.hybrid = true
+		opts.keywordWeight = ranking.DefaultKeywordWeight
+		opts.keywords = terms
+		widened["hybrid"] = true
+	}
+	opts.widen = false
+	return opts, widened
+}
+
+// routeHalfLife is the age decay used by the recency strategy: steep enough
+// that last week's memories clearly outrank last quarter's.
+const routeHalfLife = 7 * retention.Day
+
+// applyRoute adjusts search options for the routed strategy and reports
+// whether it narrowed the filter. An explicit --half-life is never
+// overridden.
+func applyRoute(r router.Route, opts *searchOptions, halfLifeSet bool) bool {
+	switch r.Strategy {
+	case router.StrategyKeyword:
+		opts.filter.TextContains = r.Entities
+		return true
+	case router.StrategyRecency:
+		if !halfLifeSet {
+			opts.halfLife = routeHalfLife
+		}
+	case router.StrategyFiltered:
+		opts.filter.Types = []string{"todo"}
+		return true
+	}
+	return false
+}
+
+// relatedNeighbors is how many nearest neighbors relatedHints names for
+// each result.
+const relatedNeighbors = 2
+
+// relatedHint points from a search result to memories likely to hold more
+// context: its nearest neighbors and the memories it links to. Only IDs
+// are given; get --ids fetches what's wanted in one call.
+type relatedHint struct {
+	Neighbors []string         `json:"neighbors"`
+	Links     []store.Relation `json:"links,omitempty"`
+}
+
+// relatedHints returns the relatedHint of each result, keyed by its ID.
+// Neighbors are searched the way a search sees memories: archived and
+// superseded ones left out, and personal ones if excludePersonal. Finding
+// them doesn't count as recalling them.
+func relatedHints(ctx context.Context, s *store.Store, results []store.Result, excludePersonal bool) (map[string]relatedHint, error) {
+	filter := store.Filter{ExcludePersonal: excludePersonal, ExcludeArchived: true, ExcludeSuperseded: true}
+	hints := make(map[string]relatedHint, len(results))
+	for _, r := range results {
+		hint := relatedHint{Neighbors: []string{}, Links: store.Relations(r.Payload)}
+		// A memory found in the cold tier isn't in the collection; it gets
+		// its links but no neighbors.
+		point, err := s.PeekPoint(ctx, r.ID)
+		if err != nil {
+			return nil, err
+		}
+		if point != nil && len(point.Vector) > 0 {
+			// One more than wanted: the memory is its own nearest neighbor.
+			neighbors, err := s.FindSimilarFiltered(ctx, point.Vector, -1, relatedNeighbors+1, filter)
+			if err != nil {
+				return nil, err
+			}
+			for _, n := range neighbors {
+				if n.ID != r.ID && len(hint.Neighbors) < relatedNeighbors {
+					hint.Neighbors = append(hint.Neighbors, n.ID)
+				}
+			}
+		}
+		hints[r.ID] = hint
+	}
+	return hints, nil
+}
+
+// flagSet reports whether the named flag was given on the command line.
+func flagSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// retrieve runs a similarity search and applies query-time ranking. Without
+// any re-ranking it is a plain Retrieve. Otherwise it fetches candidates
+// without touching them, re-ranks, and only marks the returned memories as
+// accessed — a candidate that didn't make the cut wasn't recalled.
+func retrieve(ctx context.Context, s *store.Store, vector []float32, opts searchOptions) ([]store.Result, error) {
+	if !opts.reranks() && len(opts.perType) == 0 && len(opts.keywords) == 0 && opts.filter.Empty() && !opts.includeCold {
+		return s.RetrievePage(ctx, vector, opts.minScore, opts.limit, opts.offset)
+	}
+
+	var results []store.Result
+	cold := map[string]bool{}
+	if len(opts.perType) == 0 {
+		// Re-ranking can reorder anything in the window, so page over the
+		// re-ranked offset+limit best rather than asking Qdrant to skip.
+		var err error
+		results, err = candidates(ctx, s, vector, opts, opts.filter, opts.offset+opts.limit)
+		if err != nil {
+			return nil, err
+		}
+		if opts.includeCold {
+			frozen, err := candidates(ctx, s.Cold(), vector, opts, opts.filter, opts.offset+opts.limit)
+			if err != nil {
+				return nil, err
+			}
+			for _, r := range frozen {
+				cold[r.ID] = true
+			}
+			results = ranking.MergeByScore(results, frozen)
+		}
+		results = results[min(uint64(len(results)), opts.offset):]
+	} else {
+		// One filtered search per type, so each type fills its quota even
+		// when another type dominates similarity.
+		sets := make([][]store.Result, 0, len(opts.perType))
+		for _, l := range opts.perType {
+			filter := ranking.FilterFor(l, opts.perType)
+			filter.Conditions = opts.filter.Conditions
+			filter.MustContain = opts.filter.MustContain
+			filter.ExcludePersonal = opts.filter.ExcludePersonal
+			filter.ExcludeArchived = opts.filter.ExcludeArchived
+			filter.ExcludeSuperseded = opts.filter.ExcludeSuperseded
+			filter.Since, filter.Until, filter.TimeField = opts.filter.Since, opts.filter.Until, opts.filter.TimeField
+			set, err := candidates(ctx, s, vector, opts, filter, l.Limit)
+			if err != nil {
+				return nil, err
+			}
+			sets = append(sets, set)
+		}
+		results = ranking.MergeByScore(sets...)
+	}
+
+	if uint64(len(results)) > opts.limit {
+		results = results[:opts.limit]
+	}
+	if results == nil {
+		// Missing collection: keep "results":[] consistent with Retrieve.
+		results = []store.Result{}
+	}
+	// Being found is being recalled: cold results go back to the hot
+	// collection, where Touch marks them.
+	var frozen []string
+	for _, r := range results {
+		if cold[r.ID] {
+			frozen = append(frozen, r.ID)
+		}
+	}
+	thaw(ctx, s, frozen)
+	s.Touch(ctx, results)
+	if !opts.filter.ExcludeArchived {
+		restoreArchived(ctx, s, results)
+	}
+	return results, nil
+}
+
+// restoreArchived brings back the results forget archived: being found is
+// being recalled, which is what forget's decay measures. Memories archived
+// by purge stay archived, since those were asked to be removed.
+func restoreArchived(ctx context.Context, s *store.Store, results []store.Result) {
+	var ids []string
+	for _, r := range results {
+		if store.IsArchived(r.Payload) && r.Payload[store.ArchiveReasonField] == store.ArchiveReasonForget {
+			ids = append(ids, r.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+	if err := s.Unarchive(ctx, ids); err != nil {
+		log.Printf("warning: restoring archived memories failed: %v", err)
+		return
+	}
+	for _, r := range results {
+		if slices.Contains(ids, r.ID) {
+			delete(r.Payload, store.ArchivedField)
+			delete(r.Payload, store.ArchivedAtField)
+			delete(r.Payload, store.ArchiveReasonField)
+		}
+	}
+}
+
+// candidates returns up to limit untouched matches for filter, re-ranked by
+// age when a half-life is set, boosted by recent access when a recency boost
+// is, and fused with keyword matches in a hybrid search. Either way it over-fetches so memories that climb after
+// re-ranking are in the pool to begin with.
+func candidates(ctx context.Context, s *store.Store, vector []float32, opts searchOptions, filter store.Filter, limit uint64) ([]store.Result, error) {
+	fetch := limit
+	// Results without the literal phrase are dropped after the search, so
+	// fetch extra to fill the page.
+	if opts.reranks() || len(opts.keywords) > 0 || len(filter.MustContain) > 0 {
+		fetch *= ranking.CandidateFactor
+	}
+	results, err := s.FindSimilarFiltered(ctx, vector, opts.minScore, fetch, filter)
+	if err != nil {
+		return nil, err
+	}
+	rerank(results, opts)
+	if len(opts.keywords) > 0 {
+		matches, err := s.FindKeyword(ctx, vector, opts.keywords, opts.minScore, fetch, filter)
+		if err != nil {
+			return nil, err
+		}
+		rerank(matches, opts)
+		results = ranking.FuseRRF(results, ranking.RankByKeywords(matches, opts.keywords), opts.keywordWeight)
+	}
+	if uint64(len(results)) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// rerank adjusts similarity scores by age, recent access, recall frequency,
+// type and pinning, as opts asks, and re-sorts.
+func rerank(results []store.Result, opts searchOptions) {
+	now := time.Now().UTC()
+	ranking.ApplyHalfLife(results, opts.halfLife, now)
+	ranking.ApplyRecencyBoost(results, opts.recencyBoost, opts.recencyScale, now)
+	ranking.ApplyFrequency(results, opts.frequencyWeight)
+	ranking.ApplyBoosts(results, opts.typeBoosts, opts.pinnedBonus)
+}
+
+// Serve defaults: where to listen, how long one request may take, how many
+// memories a listing returns without a limit, how often /ws checks for
+// changes, how many results a live search returns, when to start telling
+// clients to back off, and how many Qdrant connections and concurrent calls
+// to allow.
+const (
+	defaultServeAddr        = "127.0.0.1:7411"
+	serveRequestTimeout     = 30 * time.Second
+	defaultListLimit        = 50
+	defaultWatchInterval    = 2 * time.Second
+	defaultLiveLimit        = 5
+	defaultShedLatency      = 10 * time.Second
+	defaultShedErrorRate    = 0.5
+	defaultServeConnections = 8
+	defaultServeConcurrency = 64
+)
+
+// runServe answers searches and listings over HTTP from one long-lived
+// Qdrant connection, for dashboards and agents that poll. Responses carry a
+// weak ETag derived from the collection version and the request, and a
+// request whose If-None-Match still matches gets 304 Not Modified without
+// running the search again.
+func runServe(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", defaultServeAddr, "Address to listen on")
+	ui := fs.Bool("ui", false, "Also serve the admin dashboard at / and the endpoints it uses to pin and delete memories")
+	watch := durationFlag(defaultWatchInterval)
+	fs.Var(&watch, "watch-interval", "How often /ws checks for memory changes")
+	shedLatency := durationFlag(defaultShedLatency)
+	fs.Var(&shedLatency, "backoff-latency", "Answer 503 with Retry-After while the median request takes longer than this (0 disables)")
+	shedErrorRate := fs.Float64("backoff-error-rate", defaultShedErrorRate, "Answer 503 with Retry-After while more than this share of requests fail on Qdrant or Ollama (0 disables)")
+	connections := fs.Uint("qdrant-connections", defaultServeConnections, "gRPC connections to Qdrant that calls are spread across")
+	maxConcurrent := fs.Int("max-concurrent", defaultServeConcurrency, "Most Qdrant calls in flight at once; more wait for a slot (0 for no limit)")
+	fs.Parse(args)
+
+	if watch <= 0 {
+		exitJSON("error", "watch-interval must be positive")
+	}
+	if shedLatency < 0 {
+		exitJSON("error", "backoff-latency must not be negative")
+	}
+	if *shedErrorRate < 0 || *shedErrorRate > 1 {
+		exitJSON("error", "backoff-error-rate must be between 0 and 1")
+	}
+	if *connections < 1 {
+		exitJSON("error", "qdrant-connections must be at least 1")
+	}
+	if *maxConcurrent < 0 {
+		exitJSON("error", "max-concurrent must not be negative")
+	}
+	monitor := backoff.NewMonitor(time.Duration(shedLatency), *shedErrorRate)
+
+	s, err := openPooledStore(store.PoolOptions{Connections: *connections, MaxConcurrent: *maxConcurrent})
+	if err != nil {
+		exitError(err)
+	}
+	defer s.Close()
+	schemaCtx, cancelSchema := context.WithTimeout(context.Background(), serveRequestTimeout)
+	checkSchema(schemaCtx, s)
+	cancelSchema()
+	autoForget := loadConfig().AutoForget
+	if autoForget.Enabled() {
+		go runAutoForget(s, autoForget)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /search", shedLoad(monitor, func(w http.ResponseWriter, r *http.Request) { serveSearch(w, r, s) }))
+	mux.HandleFunc("GET /memories", shedLoad(monitor, func(w http.ResponseWriter, r *http.Request) { serveMemories(w, r, s) }))
+	mux.HandleFunc("GET /memories/{id}", shedLoad(monitor, func(w http.ResponseWriter, r *http.Request) { serveMemory(w, r, s) }))
+	mux.HandleFunc("GET /stats", shedLoad(monitor, func(w http.ResponseWriter, r *http.Request) { serveStats(w, r, s) }))
+	mux.HandleFunc("GET /sync", shedLoad(monitor, func(w http.ResponseWriter, r *http.Request) { serveSyncStatus(w, r, s) }))
+	mux.HandleFunc("GET /resources", shedLoad(monitor, func(w http.ResponseWriter, r *http.Request) { serveResources(w, r, s) }))
+	mux.HandleFunc("GET /resources/read", shedLoad(monitor, func(w http.ResponseWriter, r *http.Request) { serveResourceRead(w, r, s) }))
+	mux.HandleFunc("GET /pool", func(w http.ResponseWriter, r *http.Request) {
+		resp := map[string]any{"status": "ok", "pool": s.PoolStats()}
+		if endpoints := s.Endpoints(); endpoints != nil {
+			resp["endpoints"] = endpoints
+		}
+		server.WriteJSON(w, http.StatusOK, resp)
+	})
+	hub := server.NewHub()
+	mux.Handle("GET /ws", serveWS(s, hub))
+	go watchMemories(s, hub, time.Duration(watch))
+	if *ui {
+		mux.Handle("GET /", server.UI())
+		mux.HandleFunc("POST /memories/{id}/pin", shedLoad(monitor, func(w http.ResponseWriter, r *http.Request) { servePin(w, r, s, true) }))
+		mux.HandleFunc("POST /memories/{id}/unpin", shedLoad(monitor, func(w http.ResponseWriter, r *http.Request) { servePin(w, r, s, false) }))
+		mux.HandleFunc("DELETE /memories/{id}", shedLoad(monitor, func(w http.ResponseWriter, r *http.Request) { serveDelete(w, r, s) }))
+	}
+
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		exitError(err)
+	}
+	started := map[string]any{"status": "listening", "addr": ln.Addr().String()}
+	if *ui {
+		started["ui"] = "http://" + ln.Addr().String() + "/"
+	}
+	if autoForget.Enabled() {
+		started["auto_forget"] = autoForget
+	}
+	outputJSON(started)
+	if err := http.Serve(ln, server.RequestID(mux)); err != nil {
+		exitError(err)
+	}
+}
+
+const (
+	// autoForgetTimeout bounds an auto-forget pass.
+	autoForgetTimeout = 10 * time.Minute
+	// autoForgetLockTTL is how long the lock of a pass run only at start
+	// is held, so replicas started together run it once.
+	autoForgetLockTTL = 10 * time.Minute
+)
+
+// runAutoForget runs the forget passes the config schedules: one as the
+// server starts, with OnStart, and one every Interval. A pass first takes
+// a lock in the sync state, held for half the interval, so replicas
+// serving the same memories don't all run one: a replica skips its pass
+// while another ran one recently. If the sync state can't be reached the
+// pass runs anyway; forget twice over does no harm, only work.
+func runAutoForget(s *store.Store, af config.AutoForget) {
+	interval, ttl, personalTTL := af.Durations()
+	lockTTL := autoForgetLockTTL
+	if interval > 0 {
+		lockTTL = interval / 2
+	}
+	holder, _ := os.Hostname()
+	holder = fmt.Sprintf("%s:%d", holder, os.Getpid())
+
+	pass := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), autoForgetTimeout)
+		defer cancel()
+		if st, err := openSyncState(ctx, s); err != nil {
+			log.Printf("warning: auto-forget: can't lock, running anyway: %v", err)
+		} else {
+			taken, err := st.SetNX(forgetLockKey(), holder, int(lockTTL.Seconds()))
+			st.Close()
+			if err != nil {
+				log.Printf("warning: auto-forget: can't lock, running anyway: %v", err)
+			} else if !taken {
+				return
+			}
+		}
+		result, err := forgetStale(ctx, s, ttl, personalTTL, store.DefaultFrequencyWeight, af.Hard)
+		if err != nil {
+			log.Printf("warning: auto-forget: %v", err)
+			return
+		}
+		archived, _ := result["archived"].(int)
+		log.Printf("auto-forget: deleted %v, archived %d", result["deleted"], archived)
+	}
+
+	if af.OnStart {
+		pass()
+	}
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		pass()
+	}
+}
+
+// forgetLockKey is the sync state key auto-forget passes lock, one per
+// collection, so only servers sharing memories take turns.
+func forgetLockKey() string {
+	key := "forgetlock:" + store.CollectionFor(globalNamespace)
+	if globalSandbox != "" {
+		key += "@" + globalSandbox
+	}
+	return key
+}
+
+// shedLoad wraps a handler that calls Qdrant or Ollama. While the monitor
+// sees the backends struggling, requests get a 503 backoff answer right
+// away instead of piling up behind slow calls; otherwise the handler runs
+// and its latency and outcome are recorded.
+func shedLoad(m *backoff.Monitor, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if be := m.Check(); be != nil {
+			server.WriteBackoff(w, be)
+			return
+		}
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		h(rec, r)
+		failed := rec.status == http.StatusBadGateway || rec.status == http.StatusServiceUnavailable
+		m.Observe(time.Since(start), failed)
+	}
+}
+
+// statusRecorder remembers the status a handler answered with.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// writeBackendError answers a failed call to Qdrant, Ollama or Redis: 503
+// with Retry-After if the backend is overloaded, 502 otherwise.
+func writeBackendError(w http.ResponseWriter, err error) {
+	if be := backoff.Classify(err); be != nil {
+		server.WriteBackoff(w, be)
+		return
+	}
+	server.WriteError(w, http.StatusBadGateway, err.Error())
+}
+
+// serveConditional answers 304 if the client's copy of this response is
+// still current and reports whether it did. The request's path and query
+// identify the response, together with the settings that change what it
+// returns.
+func serveConditional(ctx context.Context, w http.ResponseWriter, r *http.Request, s *store.Store) bool {
+	version, err := s.Version(ctx)
+	if err != nil {
+		writeBackendError(w, err)
+		return true
+	}
+	key := fmt.Sprintf("%s?%s model=%s namespace=%s sandbox=%s agent=%s shared=%t",
+		r.URL.Path, r.URL.Query().Encode(), globalModel, globalNamespace, globalSandbox, globalAgent, globalShared)
+	return server.Conditional(w, r, version, key)
+}
+
+// serveSearch is GET /search: the search command's text mode, taking query,
+// limit, offset or cursor, min_score, type and tag (both repeatable), hybrid,
+// keyword_weight, recency_boost, recency_scale and select as URL parameters
+// and answering with the same JSON. A namespace parameter, if given, must
+// be the server's own: a client expecting another namespace's memories is
+// refused rather than answered from these.
+func serveSearch(w http.ResponseWriter, r *http.Request, s *store.Store) {
+	params := r.URL.Query()
+	if params.Has("namespace") && params.Get("namespace") != globalNamespace {
+		server.WriteError(w, http.StatusBadRequest, fmt.Sprintf("this server serves namespace %q, not %q; start it with the client's --namespace", globalNamespace, params.Get("namespace")))
+		return
+	}
+	query := params.Get("query")
+	if query == "" {
+		server.WriteError(w, http.StatusBadRequest, "query is required")
+		return
+	}
+	opts := searchOptions{limit: 1}
+	opts.widen = !params.Has("limit") && !params.Has("offset") && !params.Has("cursor")
+	if v := params.Get("widen"); v != "" {
+		widen, err := strconv.ParseBool(v)
+		if err != nil {
+			server.WriteError(w, http.StatusBadRequest, fmt.Sprintf("invalid widen %q", v))
+			return
+		}
+		opts.widen = opts.widen && widen
+	}
+	opts.filter.ExcludePersonal = globalShared
+	opts.filter.ExcludeArchived = true
+	opts.filter.ExcludeSuperseded = true
+	sel, err := selector.Parse(params.Get("select"))
+	if err != nil {
+		server.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if v := params.Get("limit"); v != "" {
+		if opts.limit, err = strconv.ParseUint(v, 10, 64); err != nil {
+			server.WriteError(w, http.StatusBadRequest, fmt.Sprintf("invalid limit %q", v))
+			return
+		}
+	}
+	if params.Has("offset") && params.Has("cursor") {
+		server.WriteError(w, http.StatusBadRequest, "offset and cursor are mutually exclusive")
+		return
+	}
+	if v := params.Get("offset"); v != "" {
+		if opts.offset, err = strconv.ParseUint(v, 10, 64); err != nil {
+			server.WriteError(w, http.StatusBadRequest, fmt.Sprintf("invalid offset %q", v))
+			return
+		}
+	}
+	if v := params.Get("cursor"); v != "" {
+		if opts.offset, err = store.DecodeCursor(v); err != nil {
+			server.WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if v := params.Get("min_score"); v != "" {
+		score, err := strconv.ParseFloat(v, 32)
+		if err != nil {
+			server.WriteError(w, http.StatusBadRequest, fmt.Sprintf("invalid min_score %q", v))
+			return
+		}
+		opts.minScore = float32(score)
+	}
+	if types := params["type"]; len(types) > 0 {
+		if opts.filter.Types, err = store.TypeFilter(types, loadConfig().MemoryTypes()); err != nil {
+			server.WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	for _, tag := range params["tag"] {
+		if strings.TrimSpace(tag) == "" {
+			server.WriteError(w, http.StatusBadRequest, "tag must not be empty")
+			return
+		}
+		opts.filter.Conditions = append(opts.filter.Conditions, store.Condition{Key: store.TagsField, Op: "=", Value: tag})
+	}
+	if opts.filter.MustContain, err = mustContainPhrases(params["must_contain"]); err != nil {
+		server.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	timeField := params.Get("time_field")
+	if timeField == "" {
+		timeField = store.CreatedAtField
+	}
+	if err := setTimeRange(&opts.filter, params.Get("since"), params.Get("until"), timeField, time.Now()); err != nil {
+		server.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	opts.hybrid, _ = strconv.ParseBool(params.Get("hybrid"))
+	opts.includeCold, _ = strconv.ParseBool(params.Get("include_cold"))
+	opts.related, _ = strconv.ParseBool(params.Get("related"))
+	if opts.hybrid || params.Has("keyword_weight") {
+		opts.hybrid = true
+		opts.keywordWeight = ranking.DefaultKeywordWeight
+		if v := params.Get("keyword_weight"); v != "" {
+			if opts.keywordWeight, err = strconv.ParseFloat(v, 64); err != nil || opts.keywordWeight < 0 {
+				server.WriteError(w, http.StatusBadRequest, fmt.Sprintf("invalid keyword_weight %q", v))
+				return
+			}
+		}
+	}
+	if v := params.Get("recency_boost"); v != "" {
+		if opts.recencyBoost, err = strconv.ParseFloat(v, 64); err != nil || opts.recencyBoost < 0 {
+			server.WriteError(w, http.StatusBadRequest, fmt.Sprintf("invalid recency_boost %q", v))
+			return
+		}
+		opts.recencyScale = ranking.DefaultRecencyScale
+		if v := params.Get("recency_scale"); v != "" {
+			if opts.recencyScale, err = retention.ParseDuration(v); err != nil || opts.recencyScale <= 0 {
+				server.WriteError(w, http.StatusBadRequest, fmt.Sprintf("invalid recency_scale %q", v))
+				return
+			}
+		}
+	}
+	if opts.typeBoosts, err = typeBoosts(params["boost"]); err != nil {
+		server.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), serveRequestTimeout)
+	defer cancel()
+	if serveConditional(ctx, w, r, s) {
+		return
+	}
+	if err := applyProfile(ctx, s, &opts); err != nil {
+		writeBackendError(w, err)
+		return
+	}
+	vector, err := newEmbedder().Embed(ctx, globalModel, query)
+	if err != nil {
+		writeBackendError(w, fmt.Errorf("embedding failed: %w", err))
+		return
+	}
+	if err := checkEmbedding(ctx, s, vector, true); err != nil {
+		server.WriteError(w, http.StatusConflict, err.Error())
+		return
+	}
+	response, _, err := runQuery(ctx, s, vector, query, opts, false, false)
+	if err != nil {
+		writeBackendError(w, err)
+		return
+	}
+	if err := selectResults(response, sel); err != nil {
+		server.WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if response, err = loadConfig().Limits.Apply(response, "results"); err != nil {
+		server.WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	server.WriteJSON(w, http.StatusOK, response)
+}
+
+// serveMemories is GET /memories: the newest memories first, up to limit
+// (default 50), optionally only those of one type. Listing doesn't count
+// as recalling, so last_accessed is left alone.
+func serveMemories(w http.ResponseWriter, r *http.Request, s *store.Store) {
+	params := r.URL.Query()
+	limit := uint64(defaultListLimit)
+	if v := params.Get("limit"); v != "" {
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			server.WriteError(w, http.StatusBadRequest, fmt.Sprintf("invalid limit %q", v))
+			return
+		}
+		limit = n
+	}
+	var only []string
+	if types := params["type"]; len(types) > 0 {
+		var err error
+		if only, err = store.TypeFilter(types, loadConfig().MemoryTypes()); err != nil {
+			server.WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), serveRequestTimeout)
+	defer cancel()
+	if serveConditional(ctx, w, r, s) {
+		return
+	}
+	all, err := s.All(ctx)
+	if err != nil {
+		writeBackendError(w, err)
+		return
+	}
+	memories := []store.Result{}
+	for _, m := range all {
+		if store.IsArchived(m.Payload) || globalShared && store.IsPersonal(m.Payload) {
+			continue
+		}
+		if len(only) > 0 && !slices.Contains(only, storedType(m.Payload)) {
+			continue
+		}
+		memories = append(memories, m)
+	}
+	sort.SliceStable(memories, func(i, j int) bool {
+		a, _ := retention.CreatedAt(memories[i].Payload)
+		b, _ := retention.CreatedAt(memories[j].Payload)
+		return a.After(b)
+	})
+	total := len(memories)
+	if uint64(total) > limit {
+		memories = memories[:limit]
+	}
+	server.WriteJSON(w, http.StatusOK, map[string]any{
+		"status":   "ok",
+		"memories": memories,
+		"returned": len(memories),
+		"total":    total,
+	})
+}
+
+// watchMemories polls the collection version while anyone is listening on
+// /ws and publishes what changed since the last poll. Writes may come from
+// any process, so polling the version is the one way to see them all. With
+// no listeners it stops reading memories and starts over from a fresh
+// snapshot when the next one connects.
+func watchMemories(s *store.Store, hub *server.Hub, interval time.Duration) {
+	var version string
+	var snap server.Snapshot
+	failing := false
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for ; ; <-ticker.C {
+		if hub.Subscribers() == 0 {
+			version, snap = "", nil
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), serveRequestTimeout)
+		v, next, err := pollMemories(ctx, s, version)
+		cancel()
+		if err != nil {
+			if !failing {
+				log.Printf("warning: watching memories: %v", err)
+			}
+			failing = true
+			continue
+		}
+		failing = false
+		if next == nil {
+			continue // version unchanged
+		}
+		if snap != nil {
+			if events := server.Diff(snap, next); len(events) > 0 {
+				hub.Publish(events)
+			}
+		}
+		version, snap = v, next
+	}
+}
+
+// pollMemories returns the collection version and, if it differs from
+// version, a snapshot of the memories this server may show.
+func pollMemories(ctx context.Context, s *store.Store, version string) (string, server.Snapshot, error) {
+	v, err := s.Version(ctx)
+	if err != nil || (v == version && version != "") {
+		return v, nil, err
+	}
+	all, err := s.All(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	visible := all[:0]
+	for _, m := range all {
+		if !globalShared || !store.IsPersonal(m.Payload) {
+			visible = append(visible, m)
+		}
+	}
+	return v, server.NewSnapshot(visible), nil
+}
+
+// wsRequest is a message from a /ws client: subscribe to a live search
+// under an ID of the client's choosing, or unsubscribe from one.
+type wsRequest struct {
+	Action   string   `json:"action"`
+	ID       string   `json:"id"`
+	Query    string   `json:"query"`
+	Limit    uint64   `json:"limit"`
+	MinScore float32  `json:"min_score"`
+	Types    []string `json:"types"`
+	Hybrid   bool     `json:"hybrid"`
+	Boosts   []string `json:"boosts"`
+}
+
+// liveSearch is a search a /ws client subscribed to, with the memories it
+// has already been told about.
+type liveSearch struct {
+	vector []float32
+	opts   searchOptions
+	seen   map[string]bool
+}
+
+// serveWS is GET /ws: a WebSocket streaming every memory change as an
+// added, updated or deleted event, plus live searches that send a match
+// event whenever new memories start matching them.
+func serveWS(s *store.Store, hub *server.Hub) http.Handler {
+	return websocket.Server{
+		Handshake: func(_ *websocket.Config, r *http.Request) error {
+			if !server.SameOrigin(r) {
+				return fmt.Errorf("origin %q not allowed", r.Header.Get("Origin"))
+			}
+			return nil
+		},
+		Handler: func(ws *websocket.Conn) { streamMemories(ws, s, hub) },
+	}
+}
+
+// streamMemories runs one /ws connection until the client goes away or
+// falls too far behind.
+func streamMemories(ws *websocket.Conn, s *store.Store, hub *server.Hub) {
+	events, unsubscribe := hub.Subscribe()
+	defer unsubscribe()
+
+	done := make(chan struct{})
+	defer close(done)
+	requests := make(chan wsRequest)
+	go func() {
+		defer close(requests)
+		for {
+			var req wsRequest
+			if err := websocket.JSON.Receive(ws, &req); err != nil {
+				return
+			}
+			select {
+			case requests <- req:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	send := func(v any) bool { return websocket.JSON.Send(ws, v) == nil }
+	live := make(map[string]*liveSearch)
+	for {
+		select {
+		case req, ok := <-requests:
+			if !ok {
+				return
+			}
+			if !send(handleWSRequest(req, s, live)) {
+				return
+			}
+		case batch, ok := <-events:
+			if !ok {
+				send(map[string]any{"event": "error", "message": "client fell behind; reconnect"})
+				return
+			}
+			changed := false
+			for _, e := range batch {
+				if !send(e) {
+					return
+				}
+				changed = changed || e.Type != server.EventDeleted
+			}
+			if !changed {
+				continue
+			}
+			for id, ls := range live {
+				fresh, err := runLiveSearch(s, ls)
+				if err != nil {
+					if !send(map[string]any{"event": "error", "subscription": id, "message": err.Error()}) {
+						return
+					}
+					continue
+				}
+				if len(fresh) > 0 && !send(map[string]any{"event": "match", "subscription": id, "results": fresh}) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// handleWSRequest applies a subscribe or unsubscribe request and returns
+// the reply. A new subscription replies with its current results.
+func handleWSRequest(req wsRequest, s *store.Store, live map[string]*liveSearch) map[string]any {
+	fail := func(msg string) map[string]any {
+		return map[string]any{"event": "error", "subscription": req.ID, "message": msg}
+	}
+	if req.ID == "" {
+		return fail("id is required")
+	}
+	switch req.Action {
+	case "unsubscribe":
+		delete(live, req.ID)
+		return map[string]any{"event": "unsubscribed", "subscription": req.ID}
+	case "subscribe":
+	default:
+		return fail(fmt.Sprintf("unknown action %q (want subscribe or unsubscribe)", req.Action))
+	}
+	if req.Query == "" {
+		return fail("query is required")
+	}
+
+	opts := searchOptions{limit: req.Limit, minScore: req.MinScore}
+	if opts.limit == 0 {
+		opts.limit = defaultLiveLimit
+	}
+	opts.filter.ExcludePersonal = globalShared
+	opts.filter.ExcludeArchived = true
+	opts.filter.ExcludeSuperseded = true
+	if len(req.Types) > 0 {
+		types, err := store.TypeFilter(req.Types, loadConfig().MemoryTypes())
+		if err != nil {
+			return fail(err.Error())
+		}
+		opts.filter.Types = types
+	}
+	if req.Hybrid {
+		opts.hybrid = true
+		opts.keywordWeight = ranking.DefaultKeywordWeight
+		opts.keywords = ranking.Terms(req.Query)
+	}
+	boosts, err := typeBoosts(req.Boosts)
+	if err != nil {
+		return fail(err.Error())
+	}
+	opts.typeBoosts = boosts
+
+	ctx, cancel := context.WithTimeout(context.Background(), serveRequestTimeout)
+	defer cancel()
+	if err := applyProfile(ctx, s, &opts); err != nil {
+		return fail(err.Error())
+	}
+	vector, err := newEmbedder().Embed(ctx, globalModel, req.Query)
+	if err != nil {
+		return fail(fmt.Sprintf("embedding failed: %v", err))
+	}
+	if err := checkEmbedding(ctx, s, vector, true); err != nil {
+		return fail(err.Error())
+	}
+	ls := &liveSearch{vector: vector, opts: opts, seen: make(map[string]bool)}
+	results, err := runLiveSearch(s, ls)
+	if err != nil {
+		return fail(err.Error())
+	}
+	live[req.ID] = ls
+	return map[string]any{"event": "subscribed", "subscription": req.ID, "results": results}
+}
+
+// runLiveSearch re-runs a live search and returns the results it hasn't
+// reported before. Like a listing, it doesn't touch last_accessed: being
+// notified isn't recalling.
+func runLiveSearch(s *store.Store, ls *liveSearch) ([]store.Result, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), serveRequestTimeout)
+	defer cancel()
+	results, err := candidates(ctx, s, ls.vector, ls.opts, ls.opts.filter, ls.opts.limit)
+	if err != nil {
+		return nil, err
+	}
+	fresh := []store.Result{}
+	for _, r := range results {
+		if !ls.seen[r.ID] {
+			ls.seen[r.ID] = true
+			fresh = append(fresh, r)
+		}
+	}
+	return fresh, nil
+}
+
+// serveMemory is GET /memories/{id}: one memory, for inspection. Like a
+// listing, it doesn't touch last_accessed. A personal memory is refused in
+// a shared context, as with get.
+func serveMemory(w http.ResponseWriter, r *http.Request, s *store.Store) {
+	ctx, cancel := context.WithTimeout(r.Context(), serveRequestTimeout)
+	defer cancel()
+	m, _, ok := visibleMemory(ctx, w, r, s)
+	if !ok {
+		return
+	}
+	response, err := loadConfig().Limits.Apply(map[string]any{"status": "ok", "memory": m}, "memory")
+	if err != nil {
+		server.WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	server.WriteJSON(w, http.StatusOK, response)
+}
+
+// visibleMemory looks up the memory named in the request path in either
+// tier, writing the error response and returning false if it doesn't exist
+// or may not be shown. The tier holding it is returned too.
+func visibleMemory(ctx context.Context, w http.ResponseWriter, r *http.Request, s *store.Store) (*store.Result, *store.Store, bool) {
+	id := r.PathValue("id")
+	if err := store.ValidateID(id); err != nil {
+		server.WriteError(w, http.StatusBadRequest, err.Error())
+		return nil, nil, false
+	}
+	m, cold, err := peekTiers(ctx, s, id)
+	if err != nil {
+		writeBackendError(w, err)
+		return nil, nil, false
+	}
+	if m == nil {
+		server.WriteError(w, http.StatusNotFound, fmt.Sprintf("memory %s not found", id))
+		return nil, nil, false
+	}
+	if globalShared && store.IsPersonal(m.Payload) {
+		server.WriteError(w, http.StatusForbidden, fmt.Sprintf("memory %s is personal", id))
+		return nil, nil, false
+	}
+	tier := s
+	if cold {
+		tier = s.Cold()
+	}
+	return m, tier, true
+}
+
+// resourceLimitParam parses the limit parameter of the resource endpoints,
+// writing a 400 if it is invalid.
+func resourceLimitParam(w http.ResponseWriter, r *http.Request) (int, bool) {
+	limit := defaultResourceLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			server.WriteError(w, http.StatusBadRequest, fmt.Sprintf("invalid limit %q", v))
+			return 0, false
+		}
+		limit = n
+	}
+	return limit, true
+}
+
+// serveResources is GET /resources: resource list's answer.
+func serveResources(w http.ResponseWriter, r *http.Request, s *store.Store) {
+	limit, ok := resourceLimitParam(w, r)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), serveRequestTimeout)
+	defer cancel()
+	if serveConditional(ctx, w, r, s) {
+		return
+	}
+	memories, err := s.All(ctx)
+	if err != nil {
+		writeBackendError(w, err)
+		return
+	}
+	server.WriteJSON(w, http.StatusOK, map[string]any{
+		"status":             "ok",
+		"resources":          listResources(memories, limit),
+		"resource_templates": resourceTemplates,
+	})
+}
+
+// serveResourceRead is GET /resources/read?uri=...: resource read's answer.
+func serveResourceRead(w http.ResponseWriter, r *http.Request, s *store.Store) {
+	uri := r.URL.Query().Get("uri")
+	if _, _, err := resource.Parse(uri); err != nil {
+		server.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	limit, ok := resourceLimitParam(w, r)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), serveRequestTimeout)
+	defer cancel()
+	if serveConditional(ctx, w, r, s) {
+		return
+	}
+	response, err := readResource(ctx, s, uri, limit)
+	if errors.Is(err, errResourceNotFound) {
+		server.WriteError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		writeBackendError(w, err)
+		return
+	}
+	if response, err = loadConfig().Limits.Apply(response, "memory", "memories"); err != nil {
+		server.WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	server.WriteJSON(w, http.StatusOK, response)
+}
+
+// serveStats is GET /stats: the retention report — totals, counts and ages
+// by type, and the audited deletion history — and the indexing status.
+func serveStats(w http.ResponseWriter, r *http.Request, s *store.Store) {
+	ctx, cancel := context.WithTimeout(r.Context(), serveRequestTimeout)
+	defer cancel()
+	if serveConditional(ctx, w, r, s) {
+		return
+	}
+	auditLog := audit.New(globalAuditLog)
+	events, err := auditLog.Events()
+	if err != nil {
+		server.WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	memories, err := s.All(ctx)
+	if err != nil {
+		writeBackendError(w, err)
+		return
+	}
+	index, err := s.IndexStatus(ctx)
+	if err != nil {
+		writeBackendError(w, err)
+		return
+	}
+	server.WriteJSON(w, http.StatusOK, map[string]any{
+		"status": "ok",
+		"report": retention.BuildReport(memories, events, auditLog.Enabled(), time.Now().UTC()),
+		"index":  index,
+	})
+}
+
+// syncedFile is one file in the sync status: where it lives and how many
+// memories came from it.
+type syncedFile struct {
+	Path     string `json:"path"`
+	Memories int    `json:"memories"`
+}
+
+// serveSyncStatus is GET /sync: the files sync has ingested, as tracked in
+// its state, with how many memories each one holds now.
+func serveSyncStatus(w http.ResponseWriter, r *http.Request, s *store.Store) {
+	ctx, cancel := context.WithTimeout(r.Context(), serveRequestTimeout)
+	defer cancel()
+
+	// The Redis client isn't safe for concurrent use; each request opens
+	// the sync state afresh.
+	st, err := openSyncState(ctx, s)
+	if err != nil {
+		writeBackendError(w, fmt.Errorf("sync state: %w", err))
+		return
+	}
+	defer st.Close()
+	keys, err := st.Scan(sync.RedisKeyPattern(globalNamespace, ""))
+	if err != nil {
+		writeBackendError(w, fmt.Errorf("sync state: %w", err))
+		return
+	}
+	memories, err := s.All(ctx)
+	if err != nil {
+		writeBackendError(w, err)
+		return
+	}
+	perSource := make(map[string]int)
+	for _, m := range memories {
+		perSource[retention.SourceOf(m.Payload)]++
+	}
+	files := make([]syncedFile, 0, len(keys))
+	for _, key := range keys {
+		path := sync.PathFromRedisKey(globalNamespace, key)
+		files = append(files, syncedFile{Path: path, Memories: perSource[path]})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	server.WriteJSON(w, http.StatusOK, map[string]any{"status": "ok", "files": files, "tracked": len(files)})
+}
+
+// servePin is POST /memories/{id}/pin and /unpin.
+func servePin(w http.ResponseWriter, r *http.Request, s *store.Store, pin bool) {
+	if !server.SameOrigin(r) {
+		server.WriteError(w, http.StatusForbidden, "cross-origin request refused")
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), serveRequestTimeout)
+	defer cancel()
+	m, tier, ok := visibleMemory(ctx, w, r, s)
+	if !ok {
+		return
+	}
+	if tier.IsCold() {
+		// Pinning is written to the collection, which tier moved it out of.
+		if err := s.Thaw(ctx, []string{m.ID}); err != nil {
+			writeBackendError(w, err)
+			return
+		}
+	}
+	if pin {
+		err := s.SetPayloads(ctx, map[string]map[string]any{m.ID: {"pinned": true}})
+		if err != nil {
+			writeBackendError(w, err)
+			return
+		}
+	} else if err := s.DeletePayloadKeys(ctx, []string{m.ID}, "pinned"); err != nil {
+		writeBackendError(w, err)
+		return
+	}
+	server.WriteJSON(w, http.StatusOK, map[string]any{"status": "ok", "id": m.ID, "pinned": pin})
+}
+
+// serveDelete is DELETE /memories/{id}. Pinned and locked memories are
+// refused, as they are by every other deletion path; the deletion is
+// audited.
+func serveDelete(w http.ResponseWriter, r *http.Request, s *store.Store) {
+	if !server.SameOrigin(r) {
+		server.WriteError(w, http.StatusForbidden, "cross-origin request refused")
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), serveRequestTimeout)
+	defer cancel()
+	m, tier, ok := visibleMemory(ctx, w, r, s)
+	if !ok {
+		return
+	}
+	if retention.IsPinned(m.Payload) || store.IsLocked(m.Payload) {
+		server.WriteError(w, http.StatusConflict, fmt.Sprintf("memory %s is pinned or locked; unpin or unlock it first", m.ID))
+		return
+	}
+	if err := tier.Delete(ctx, m.ID); err != nil {
+		writeBackendError(w, err)
+		return
+	}
+	recordAudit(ctx, "delete", 1, []string{m.ID}, map[string]any{"via": "ui"})
+	server.WriteJSON(w, http.StatusOK, map[string]any{"status": "ok", "deleted": m.ID})
+}
+
+// storedType returns a memory's type as Filter.Types names it: "" for an
+// untyped memory.
+func storedType(payload map[string]any) string {
+	t, _ := payload[store.TypeField].(string)
+	return t
+}
+
+// runDue lists memories whose --remind schedule has come due. With --ack,
+// recurring reminders move to their next occurrence and one-shot reminders
+// are cleared, so the same reminder isn't surfaced twice. --watch turns it
+// into a long-running notifier that prints one JSON line per reminder as it
+// comes due, acknowledging each.
+func runDue(args []string) {
+	fs := flag.NewFlagSet("due", flag.ExitOnError)
+	ack := fs.Bool("ack", false, "Acknowledge the returned reminders: reschedule recurring ones, clear one-shot ones")
+	limit := fs.Uint64("limit", 0, "Maximum number of reminders to return (0 = all)")
+	var watch durationFlag
+	fs.Var(&watch, "watch", "Keep running, checking for due reminders at this interval (e.g. 1m); implies --ack")
+	fs.Parse(args)
+
+	if watch < 0 {
+		exitJSON("error", "watch interval must be positive")
+	}
+
+	s, ctx, cancel := connect()
+	defer cancel()
+	defer s.Close()
+
+	if watch == 0 {
+		now := time.Now()
+		results, acked, err := checkDue(ctx, s, now, *limit, *ack)
+		if err != nil {
+			exitError(err)
+		}
+		response := map[string]any{
+			"status":  "ok",
+			"now":     formatDue(now),
+			"count":   len(results),
+			"results": results,
+		}
+		if *ack {
+			response["acked"] = acked
+		}
+		outputJSON(response)
+		return
+	}
+
+	for {
+		tickCtx, tickCancel := context.WithTimeout(context.Background(), 30*time.Second)
+		results, acked, err := checkDue(tickCtx, s, time.Now(), *limit, true)
+		tickCancel()
+		if err != nil {
+			log.Printf("warning: checking due reminders: %v", err)
+		}
+		for i, r := range results {
+			outputJSON(map[string]any{
+				"status":  "due",
+				"id":      r.ID,
+				"payload": r.Payload,
+				"next":    acked[i][store.RemindNextField],
+			})
+		}
+		time.Sleep(time.Duration(watch))
+	}
+}
+
+// checkDue returns the reminders due at now (at most limit, if non-zero) and,
+// when ack is set, acknowledges them. acked[i] describes results[i]: its ID
+// and, for recurring reminders, the new remind_next.
+func checkDue(ctx context.Context, s *store.Store, now time.Time, limit uint64, ack bool) ([]store.Result, []map[string]any, error) {
+	results, err := s.Due(ctx, now)
+	if err != nil {
+		return nil, nil, err
+	}
+	if limit > 0 && uint64(len(results)) > limit {
+		results = results[:limit]
+	}
+	if !ack {
+		return results, nil, nil
+	}
+
+	acked := make([]map[string]any, len(results))
+	updates := map[string]map[string]any{}
+	var finished []string
+	for i, r := range results {
+		acked[i] = map[string]any{"id": r.ID}
+		update := map[string]any{"reminded_at": formatDue(now)}
+		if next, ok := nextReminder(r.Payload, now); ok {
+			update[store.RemindNextField] = formatDue(next)
+			acked[i][store.RemindNextField] = update[store.RemindNextField]
+		} else {
+			finished = append(finished, r.ID)
+		}
+		updates[r.ID] = update
+	}
+	if err := s.SetPayloads(ctx, updates); err != nil {
+		return nil, nil, fmt.Errorf("acknowledge reminders: %w", err)
+	}
+	if err := s.DeletePayloadKeys(ctx, finished, store.RemindNextField); err != nil {
+		return nil, nil, fmt.Errorf("acknowledge reminders: %w", err)
+	}
+	return results, acked, nil
+}
+
+// nextReminder returns when a due reminder fires next, re-parsing its stored
+// schedule. One-shot reminders, and any whose schedule no longer parses, are
+// finished and return false.
+func nextReminder(payload map[string]any, now time.Time) (time.Time, bool) {
+	spec, _ := payload["remind"].(string)
+	sched, err := schedule.Parse(spec, now)
+	if err != nil || !sched.Recurring() {
+		return time.Time{}, false
+	}
+	due, err := time.Parse(time.RFC3339, fmt.Sprint(payload[store.RemindNextField]))
+	if err != nil {
+		due = now
+	}
+	return sched.Advance(due.In(now.Location()), now)
+}
+
+// formatDue renders a reminder time the way it is stored: RFC 3339 in UTC,
+// which also sorts chronologically as a string.
+func formatDue(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// durationFlag is a flag.Value for durations that also accepts a day suffix
+// ("30d") on top of Go duration syntax ("720h").
+type durationFlag time.Duration
+
+func (d *durationFlag) String() string { return time.Duration(*d).String() }
+func (d *durationFlag) Set(value string) error {
+	v, err := retention.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = durationFlag(v)
+	return nil
+}
+
+func runDelete(args []string) {
+	fs := flag.NewFlagSet("delete", flag.ExitOnError)
+	days := fs.Int("d", 30, "Delete memories not accessed in the last N days")
+	dryRun := fs.Bool("dry-run", false, "List the memories that would be deleted without deleting them")
+	id := fs.String("id", "", "Delete this memory instead of old ones")
+	idList := fs.String("ids", "", "Delete these memories instead of old ones (comma-separated UUIDs)")
+	var filters multiFlag
+	fs.Var(&filters, "filter", "Delete the memories matching this payload filter instead of old ones: KEY=VALUE, KEY>=N, KEY<N or KEY~LAT,LON,RADIUS (repeatable, ANDed)")
+	includePinned := fs.Bool("include-pinned", false, "With --filter: delete matching pinned memories too")
+	ifVersion := fs.Int64("if-version", 0, "With --id: only delete the memory if it is still at this revision")
+	ifLastAccessedBefore := fs.String("if-last-accessed-before", "", "With --id: only delete the memory if nobody has touched it since this RFC 3339 time")
+	frequencyWeight := fs.Float64("frequency-weight", store.DefaultFrequencyWeight, "Keep often-recalled memories longer: the age limit is stretched by 1 + weight*log2(1+access_count); 0 deletes on last_accessed alone")
+	hard := fs.Bool("hard", false, "Delete old memories outright instead of archiving them")
+	fs.Parse(args)
+
+	if *id != "" || *idList != "" || len(filters) > 0 {
+		if flagSet(fs, "d") {
+			exitJSON("error", "-d can't be combined with --id, --ids or --filter")
+		}
+		for _, name := range []string{"frequency-weight", "hard"} {
+			if flagSet(fs, name) {
+				exitJSON("error", fmt.Sprintf("--%s can't be combined with --id, --ids or --filter", name))
+			}
+		}
+		runDeleteTargeted(fs, *id, *idList, filters, *includePinned, *ifVersion, *ifLastAccessedBefore, *dryRun)
+		return
+	}
+	for _, name := range []string{"include-pinned", "if-version", "if-last-accessed-before"} {
+		if flagSet(fs, name) {
+			exitJSON("error", fmt.Sprintf("--%s requires --id, --ids or --filter", name))
+		}
+	}
+	if *days < 0 {
+		exitJSON("error", "days must be non-negative")
+	}
+	if *frequencyWeight < 0 {
+		exitJSON("error", "frequency-weight must be non-negative")
+	}
+
+	ttl := time.Duration(*days) * 24 * time.Hour
+
+	s, ctx, cancel := connect()
+	defer cancel()
+	defer s.Close()
+	s.SetFrequencyWeight(*frequencyWeight)
+
+	// Both tiers, as forget: moving a memory to the cold tier doesn't keep
+	// it any longer.
+	if *dryRun {
+		action := "would_archive"
+		if *hard {
+			action = "would_delete"
+		}
+		listed := []map[string]any{}
+		for _, t := range s.Tiers() {
+			stale, err := t.StaleUnarchived(ctx, ttl)
+			if *hard {
+				stale, err = t.Stale(ctx, ttl)
+			}
+			if err != nil {
+				exitError(err)
+			}
+			for _, r := range stale {
+				entry := map[string]any{
+					"id":            r.ID,
+					"text":          r.Payload["text"],
+					"last_accessed": r.Payload["last_accessed"],
+				}
+				if t.IsCold() {
+					entry["cold"] = true
+				}
+				listed = append(listed, entry)
+			}
+		}
+		outputJSON(map[string]any{
+			"status":   "ok",
+			"dry_run":  true,
+			action:     len(listed),
+			"days":     *days,
+			"memories": listed,
+		})
+		return
+	}
+
+	result := map[string]any{
+		"status":           "ok",
+		"days":             *days,
+		"frequency_weight": *frequencyWeight,
+	}
+	if *hard {
+		deleted := 0
+		for _, t := range s.Tiers() {
+			n, err := t.Forget(ctx, ttl)
+			if err != nil {
+				exitError(err)
+			}
+			deleted += n
+		}
+		recordAudit(ctx, "delete", deleted, nil, map[string]any{"days": *days, "frequency_weight": *frequencyWeight})
+		result["deleted"] = deleted
+		outputJSON(result)
+		return
+	}
+
+	// Old memories are archived as forget archives them, so a memory
+	// deleted by mistake can still be found with search --include-archived.
+	var ids []string
+	for _, t := range s.Tiers() {
+		archived, err := t.Archive(ctx, ttl)
+		if err != nil {
+			exitError(err)
+		}
+		for _, r := range archived {
+			ids = append(ids, r.ID)
+		}
+	}
+	if len(ids) > 0 {
+		recordAudit(ctx, "archive", len(ids), ids, map[string]any{"days": *days, "frequency_weight": *frequencyWeight, "reason": store.ArchiveReasonForget})
+	}
+	result["deleted"] = 0
+	result["archived"] = len(ids)
+	outputJSON(result)
+}
+
+// runDeleteTargeted deletes memories named by ID or matched by payload
+// filter, rather than by age. Locked memories are skipped; so are pinned
+// ones matched by a filter, unless includePinned -- naming a pinned memory
+// by ID is taken as meaning it. IDs that don't exist are reported, not
+// failed on.
+func runDeleteTargeted(fs *flag.FlagSet, id, idList string, filters []string, includePinned bool, ifVersion int64, ifLastAccessedBefore string, dryRun bool) {
+	var ids []string
+	if id != "" {
+		ids = append(ids, id)
+	}
+	for _, part := range strings.Split(idList, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			ids = append(ids, part)
+		}
+	}
+	for _, id := range ids {
+		if err := store.ValidateID(id); err != nil {
+			exitError(err)
+		}
+	}
+	if includePinned && len(filters) == 0 {
+		exitJSON("error", "--include-pinned requires --filter")
+	}
+	pre := parsePrecondition(fs, ifVersion, ifLastAccessedBefore)
+	if !pre.Empty() && (id == "" || idList != "" || len(filters) > 0) {
+		exitJSON("error", "--if-version and --if-last-accessed-before require a single --id")
+	}
+	conds := parseConditions(filters)
+
+	s, ctx, cancel := connect()
+	defer cancel()
+	defer s.Close()
+
+	// Both tiers: naming a memory tier moved to the cold one still
+	// deletes it.
+	var matched []store.Result
+	missing := []string{}
+	cold := map[string]bool{}
+	if len(conds) > 0 {
+		for _, t := range s.Tiers() {
+			found, err := t.Find(ctx, conds)
+			if err != nil {
+				exitError(err)
+			}
+			for _, r := range restrictToIDs(found, ids) {
+				cold[r.ID] = t.IsCold()
+				matched = append(matched, r)
+			}
+		}
+	} else {
+		for _, id := range ids {
+			r, isCold, err := peekTiers(ctx, s, id)
+			if err != nil {
+				exitError(err)
+			}
+			if r == nil {
+				missing = append(missing, id)
+				continue
+			}
+			cold[id] = isCold
+			matched = append(matched, *r)
+		}
+	}
+
+	targets := []store.Result{}
+	skippedLocked, skippedPinned := 0, 0
+	for _, r := range matched {
+		switch {
+		case store.IsLocked(r.Payload):
+			skippedLocked++
+		case len(conds) > 0 && !includePinned && retention.IsPinned(r.Payload):
+			skippedPinned++
+		default:
+			targets = append(targets, r)
+		}
+	}
+	if len(ids) == 1 && len(conds) == 0 && skippedLocked == 1 {
+		exitJSON("error", fmt.Sprintf("memory %s is locked; unlock it first", ids[0]))
+	}
+
+	result := map[string]any{
+		"status":         "ok",
+		"matched":        len(matched),
+		"skipped_locked": skippedLocked,
+		"missing":        missing,
+	}
+	if len(conds) > 0 {
+		result["skipped_pinned"] = skippedPinned
+	}
+	if dryRun {
+		listed := make([]map[string]any, len(targets))
+		for i, r := range targets {
+			listed[i] = map[string]any{
+				"id":            r.ID,
+				"text":          r.Payload["text"],
+				"last_accessed": r.Payload["last_accessed"],
+			}
+		}
+		result["dry_run"] = true
+		result["would_delete"] = len(targets)
+		result["memories"] = listed
+		outputJSON(result)
+		return
+	}
+
+	deleted := make([]string, len(targets))
+	payloads := make([]map[string]any, len(targets))
+	var hotIDs, coldIDs []string
+	for i, r := range targets {
+		deleted[i] = r.ID
+		payloads[i] = r.Payload
+		if cold[r.ID] {
+			coldIDs = append(coldIDs, r.ID)
+		} else {
+			hotIDs = append(hotIDs, r.ID)
+		}
+	}
+	if !pre.Empty() {
+		tier := s
+		if cold[id] {
+			tier = s.Cold()
+		}
+		if err := tier.DeleteIf(ctx, id, pre); err != nil {
+			exitWriteError(err)
+		}
+	} else {
+		if err := s.DeleteIDs(ctx, hotIDs); err != nil {
+			exitError(err)
+		}
+		if err := s.Cold().DeleteIDs(ctx, coldIDs); err != nil {
+			exitError(err)
+		}
+	}
+	detail := map[string]any{}
+	if len(filters) > 0 {
+		detail["filters"] = filters
+	}
+	recordAudit(ctx, "delete", len(deleted), deleted, detail)
+	invalidateCache(payloads...)
+
+	result["deleted"] = len(deleted)
+	result["ids"] = deleted
+	outputJSON(result)
+}
+
+func runForget(args []string) {
+	fs := flag.NewFlagSet("forget", flag.ExitOnError)
+	ttlFlag := durationFlag(30 * retention.Day)
+	fs.Var(&ttlFlag, "ttl", "Forget memories not accessed within this duration (e.g. 30d or 720h)")
+	ttl := (*time.Duration)(&ttlFlag)
+	personalTTLFlag := durationFlag(retention.DefaultPersonalTTL)
+	fs.Var(&personalTTLFlag, "personal-ttl", "Forget personal memories not accessed within this duration, if shorter than --ttl")
+	personalTTL := (*time.Duration)(&personalTTLFlag)
+	simulate := fs.Bool("simulate", false, "Preview how many memories would be forgotten at various TTLs, without deleting")
+	dryRun := fs.Bool("dry-run", false, "List the memories that would be forgotten (ID, text, last_accessed) without changing anything")
+	hard := fs.Bool("hard", false, "Delete stale memories outright instead of archiving them")
+	compress := fs.Bool("compress", false, "Summarize stale memories into archival memories (via Ollama) instead of just deleting them")
+	groupSize := fs.Int("group-size", retention.DefaultGroupSize, "Maximum memories summarized together by --compress")
+	frequencyWeight := fs.Float64("frequency-weight", store.DefaultFrequencyWeight, "Keep often-recalled memories longer: the TTL is stretched by 1 + weight*log2(1+access_count); 0 forgets on last_accessed alone")
+	fs.Parse(args)
+
+	if *ttl < 0 {
+		exitJSON("error", "ttl must be non-negative")
+	}
+	if *personalTTL < 0 {
+		exitJSON("error", "personal-ttl must be non-negative")
+	}
+	if *simulate && *compress {
+		exitJSON("error", "--simulate and --compress are mutually exclusive")
+	}
+	if *dryRun && (*simulate || *compress) {
+		exitJSON("error", "--dry-run can't be combined with --simulate or --compress")
+	}
+	if *hard && (*simulate || *compress) {
+		exitJSON("error", "--hard can't be combined with --simulate or --compress")
+	}
+	if *groupSize < 1 {
+		exitJSON("error", "group-size must be at least 1")
+	}
+	if *frequencyWeight < 0 {
+		exitJSON("error", "frequency-weight must be non-negative")
+	}
+
+	s, ctx, cancel := connect()
+	defer cancel()
+	defer s.Close()
+	s.SetFrequencyWeight(*frequencyWeight)
+
+	if *simulate {
+		memories, err := s.All(ctx)
+		if err != nil {
+			exitError(err)
+		}
+
+		sim := retention.Simulate(memories, *ttl, *personalTTL, *frequencyWeight, retention.DefaultCurve, time.Now().UTC())
+		outputJSON(map[string]any{
+			"status":           "ok",
+			"simulated":        true,
+			"ttl":              sim.TTL,
+			"personal_ttl":     personalTTL.String(),
+			"frequency_weight": *frequencyWeight,
+			"total":            sim.Total,
+			"pinned":           sim.Pinned,
+			"locked":           sim.Locked,
+			"forgotten":        sim.Forgotten,
+			"remaining":        sim.Remaining,
+			"by_type":          sim.ByType,
+			"by_source":        sim.BySource,
+			"curve":            sim.Curve,
+		})
+		return
+	}
+
+	if *dryRun {
+		previewForget(ctx, s, *ttl, *personalTTL, *frequencyWeight, *hard)
+		return
+	}
+
+	if *compress {
+		personalDeleted, err := forgetPersonal(ctx, s, *ttl, *personalTTL)
+		if err != nil {
+			exitError(err)
+		}
+		// Summarizing is far slower than a delete; don't hold it to
+		// connect's default timeout.
+		cctx, ccancel := context.WithTimeout(context.Background(), compressTimeout)
+		defer ccancel()
+		compressStale(cctx, s, *ttl, *frequencyWeight, *groupSize, personalDeleted)
+		return
+	}
+
+	result, err := forgetStale(ctx, s, *ttl, *personalTTL, *frequencyWeight, *hard)
+	if err != nil {
+		exitError(err)
+	}
+	outputJSON(result)
+}
+
+// forgetPersonal deletes the personal memories stale at personalTTL, if
+// that is shorter than ttl, and returns how many. Personal memories go
+// first, on their own (shorter) clock. This also keeps them out of
+// --compress summaries: a personal memory stale at --ttl is stale at the
+// personal TTL too, so it is already gone. They are deleted rather than
+// archived: keeping them would defeat the shorter TTL. Both tiers are
+// swept, so moving a memory to the cold tier doesn't keep it any longer.
+func forgetPersonal(ctx context.Context, s *store.Store, ttl, personalTTL time.Duration) (int, error) {
+	pttl := min(personalTTL, ttl)
+	if pttl >= ttl {
+		return 0, nil
+	}
+	deleted := 0
+	for _, t := range s.Tiers() {
+		n, err := t.ForgetPersonal(ctx, pttl)
+		if err != nil {
+			return 0, err
+		}
+		deleted += n
+	}
+	recordAudit(ctx, "forget", deleted, nil, map[string]any{"ttl": pttl.String(), "sensitivity": store.SensitivityPersonal})
+	return deleted, nil
+}
+
+// forgetStale runs a forget pass over both tiers: personal memories stale
+// at personalTTL are deleted, then the rest stale at ttl archived, or
+// deleted if hard. It returns forget's response.
+func forgetStale(ctx context.Context, s *store.Store, ttl, personalTTL time.Duration, weight float64, hard bool) (map[string]any, error) {
+	personalDeleted, err := forgetPersonal(ctx, s, ttl, personalTTL)
+	if err != nil {
+		return nil, err
+	}
+	result := map[string]any{
+		"status":           "ok",
+		"personal_deleted": personalDeleted,
+		"ttl":              ttl.String(),
+		"personal_ttl":     personalTTL.String(),
+		"frequency_weight": weight,
+	}
+	if hard {
+		deleted := 0
+		for _, t := range s.Tiers() {
+			n, err := t.Forget(ctx, ttl)
+			if err != nil {
+				return nil, err
+			}
+			deleted += n
+		}
+		recordAudit(ctx, "forget", deleted, nil, map[string]any{"ttl": ttl.String(), "frequency_weight": weight})
+		result["deleted"] = deleted + personalDeleted
+		return result, nil
+	}
+
+	var ids []string
+	for _, t := range s.Tiers() {
+		archived, err := t.Archive(ctx, ttl)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range archived {
+			ids = append(ids, r.ID)
+		}
+	}
+	if len(ids) > 0 {
+		recordAudit(ctx, "archive", len(ids), ids, map[string]any{"ttl": ttl.String(), "frequency_weight": weight, "reason": store.ArchiveReasonForget})
+	}
+	result["deleted"] = personalDeleted
+	result["archived"] = len(ids)
+	return result, nil
+}
+
+// compressTimeout bounds a whole forget --compress pass, which makes one
+// generation and one embedding call per group.
+const compressTimeout = 10 * time.Minute
+
+// purgeEmbeddingLimit caps how many memories the embedding pass of purge
+// considers per entity.
+const purgeEmbeddingLimit = 200
+
+// defaultColdAfter is how long a memory goes unaccessed before tier moves
+// it to the cold tier.
+const defaultColdAfter = 90 * retention.Day
+
+// runTier moves memories nobody has recalled in a long time from the
+// collection into its cold tier, keeping the collection searches run
+// against small and fast. Cold memories stay searchable with search
+// --include-cold, and move back when found.
+func runTier(args []string) {
+	fs := flag.NewFlagSet("tier", flag.ExitOnError)
+	olderThanFlag := durationFlag(defaultColdAfter)
+	fs.Var(&olderThanFlag, "older-than", "Move memories not accessed within this duration (e.g. 90d)")
+	dryRun := fs.Bool("dry-run", false, "List the memories that would move without changing anything")
+	fs.Parse(args)
+
+	olderThan := time.Duration(olderThanFlag)
+	if olderThan <= 0 {
+		exitJSON("error", "older-than must be positive")
+	}
+
+	s, ctx, cancel := connect()
+	defer cancel()
+	defer s.Close()
+
+	result := map[string]any{
+		"status":     "ok",
+		"older_than": olderThan.String(),
+	}
+	var moved []store.Result
+	var err error
+	if *dryRun {
+		moved, err = s.Stale(ctx, olderThan)
+		if err != nil {
+			exitError(err)
+		}
+		listed := make([]map[string]any, 0, len(moved))
+		for _, r := range moved {
+			listed = append(listed, map[string]any{
+				"id":            r.ID,
+				"text":          r.Payload["text"],
+				"last_accessed": r.Payload["last_accessed"],
+			})
+		}
+		result["dry_run"] = true
+		result["would_move"] = len(moved)
+		result["memories"] = listed
+	} else {
+		moved, err = s.Freeze(ctx, olderThan)
+		if err != nil {
+			exitError(err)
+		}
+		result["moved"] = len(moved)
+		// Cached searches may still list the moved memories.
+		payloads := make([]map[string]any, len(moved))
+		for i, r := range moved {
+			payloads[i] = r.Payload
+		}
+		invalidateCache(payloads...)
+	}
+
+	hot, err := s.Count(ctx)
+	if err != nil {
+		exitError(err)
+	}
+	cold, err := s.Cold().Count(ctx)
+	if err != nil {
+		exitError(err)
+	}
+	result["hot"] = hot
+	result["cold"] = cold
+	outputJSON(result)
+}
+
+// defaultSandbox is the sandbox sandbox create makes without --name.
+const defaultSandbox = "default"
+
+// runSandbox manages sandboxes: copies of the collection where destructive
+// commands -- forget with an aggressive TTL, hygiene, compress -- can be run
+// with --sandbox and their effect diffed against the real memories before
+// they are run for real.
+func runSandbox(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: clawbrain sandbox create|list|diff|drop [--name NAME] [flags]")
+		os.Exit(1)
+	}
+	action := args[0]
+	fs := flag.NewFlagSet("sandbox "+action, flag.ExitOnError)
+	name := fs.String("name", defaultSandbox, "Sandbox to create, diff or drop")
+	from := fs.String("from", globalNamespace, "create: namespace to copy, or memories for the default collection")
+	replace := fs.Bool("replace", false, "create: start over a sandbox that already exists")
+	limit := fs.Int("limit", 20, "diff: most memories to list of each kind (0 for all)")
+	fs.Parse(args[1:])
+
+	switch action {
+	case "create", "list", "diff", "drop":
+	default:
+		exitJSON("error", fmt.Sprintf("unknown sandbox action %q: use create, list, diff or drop", action))
+	}
+	if globalSandbox != "" {
+		exitJSON("error", "--sandbox can't be used with the sandbox command; use --name")
+	}
+	if err := store.ValidateSandbox(*name); err != nil {
+		exitError(err)
+	}
+	if *limit < 0 {
+		exitJSON("error", "limit must not be negative")
+	}
+	namespace := *from
+	if namespace == store.DefaultCollection {
+		namespace = ""
+	}
+	if namespace != "" {
+		if err := store.ValidateNamespace(namespace); err != nil {
+			exitError(err)
+		}
+	}
+
+	s, err := openStore()
+	if err != nil {
+		exitError(err)
+	}
+	defer s.Close()
+	s.SetNamespace(namespace)
+	// Copying a large collection takes longer than a command usually may.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	sandboxes, err := s.Sandboxes(ctx)
+	if err != nil {
+		exitError(err)
+	}
+	if sandboxes == nil {
+		sandboxes = []store.SandboxInfo{}
+	}
+	exists := slices.ContainsFunc(sandboxes, func(info store.SandboxInfo) bool { return info.Name == *name })
+	if action == "list" {
+		outputJSON(map[string]any{"status": "ok", "namespace": namespace, "sandboxes": sandboxes})
+		return
+	}
+	if action != "create" && !exists {
+		exitJSON("error", fmt.Sprintf("no sandbox %q; make it with clawbrain sandbox create --name %s", *name, *name))
+	}
+
+	sandbox := s.Sandbox(*name)
+	result := map[string]any{"status": "ok", "sandbox": *name, "namespace": namespace}
+	switch action {
+	case "create":
+		if exists && !*replace {
+			exitJSON("error", fmt.Sprintf("sandbox %q already exists; drop it or use --replace", *name))
+		}
+		if err := dropSandbox(ctx, sandbox); err != nil {
+			exitError(err)
+		}
+		copied, err := s.CopyTo(ctx, sandbox)
+		if err != nil {
+			exitError(err)
+		}
+		copiedCold, err := s.Cold().CopyTo(ctx, sandbox.Cold())
+		if err != nil {
+			exitError(err)
+		}
+		result["copied"] = copied
+		result["copied_cold"] = copiedCold
+	case "diff":
+		diff, err := sandbox.Diff(ctx, s)
+		if err != nil {
+			exitError(err)
+		}
+		result["removed"] = len(diff.Removed)
+		result["added"] = len(diff.Added)
+		result["changed"] = len(diff.Changed)
+		result["memories"] = map[string]any{
+			"removed": diffResults(diff.Removed, *limit),
+			"added":   diffResults(diff.Added, *limit),
+			"changed": diff.Changed[:limitOf(len(diff.Changed), *limit)],
+		}
+	case "drop":
+		if err := dropSandbox(ctx, sandbox); err != nil {
+			exitError(err)
+		}
+		result["dropped"] = true
+	}
+	outputJSON(result)
+}
+
+// dropSandbox deletes a sandbox's collection and its cold tier.
+func dropSandbox(ctx context.Context, sandbox *store.Store) error {
+	if err := sandbox.DeleteCollection(ctx); err != nil {
+		return err
+	}
+	return sandbox.Cold().DeleteCollection(ctx)
+}
+
+// diffResults lists up to limit memories of a sandbox diff by ID and text;
+// a limit of 0 lists them all.
+func diffResults(results []store.Result, limit int) []map[string]any {
+	listed := make([]map[string]any, 0, limitOf(len(results), limit))
+	for _, r := range results[:limitOf(len(results), limit)] {
+		listed = append(listed, map[string]any{"id": r.ID, "text": r.Payload["text"]})
+	}
+	return listed
+}
+
+// limitOf returns how many of n items a limit lets through, 0 being no
+// limit.
+func limitOf(n, limit int) int {
+	if limit == 0 {
+		return n
+	}
+	return min(n, limit)
+}
+
+func runPurge(args []string) {
+	fs := flag.NewFlagSet("purge", flag.ExitOnError)
+	var entities multiFlag
+	fs.Var(&entities, "entity", "Person, project or topic whose memories to remove (repeatable)")
+	archived := fs.Bool("archived", false, "Permanently delete archived memories whose grace period (--older-than) is over")
+	olderThanFlag := durationFlag(defaultArchiveGrace)
+	fs.Var(&olderThanFlag, "older-than", "With --archived: only memories archived at least this long ago (e.g. 30d)")
+	dryRun := fs.Bool("dry-run", false, "List the memories that would be removed without changing anything")
+	archive := fs.Bool("archive", false, "Archive the matches (hidden from search) instead of deleting them")
+	minScore := fs.Float64("min-score", 0.6, "Embedding similarity at which a memory counts as about the entity")
+	noEmbedding := fs.Bool("no-embedding", false, "Match by name, attribution and tags only, skipping the embedding pass")
+	fs.Parse(args)
+
+	if *archived {
+		for _, name := range []string{"entity", "archive", "min-score", "no-embedding"} {
+			if flagSet(fs, name) {
+				exitJSON("error", fmt.Sprintf("--%s can't be combined with --archived", name))
+			}
+		}
+		purgeArchived(time.Duration(olderThanFlag), *dryRun)
+		return
+	}
+	if flagSet(fs, "older-than") {
+		exitJSON("error", "--older-than requires --archived")
+	}
+	if len(entities) == 0 {
+		exitJSON("error", "--entity or --archived is required")
+	}
+	for _, e := range entities {
+		if strings.TrimSpace(e) == "" {
+			exitJSON("error", "--entity must not be empty")
+		}
+	}
+	if *minScore <= 0 || *minScore > 1 {
+		exitJSON("error", "min-score must be greater than 0 and at most 1")
+	}
+
+	s, ctx, cancel := connect()
+	defer cancel()
+	defer s.Close()
+
+	// Both tiers: a memory tier moved to the cold one is still about the
+	// entity. Each entity is embedded once, the first time a tier needs it.
+	tiers := s.Tiers()
+	tierIDs := make([][]string, len(tiers))
+	var vectors [][]float32
+	var ids, locked []string
+	var removed []*purge.Hit
+	listed := []map[string]any{}
+	for i, t := range tiers {
+		memories, err := t.All(ctx)
+		if err != nil {
+			exitError(err)
+		}
+		found := purge.Collect{}
+		for _, m := range memories {
+			for _, e := range entities {
+				if reasons := purge.Match(m.Payload, e); reasons != nil {
+					found.Add(m, 0, reasons...)
+				}
+			}
+		}
+		if !*noEmbedding && len(memories) > 0 {
+			if vectors == nil {
+				emb := newEmbedder()
+				for _, e := range entities {
+					vector, err := emb.Embed(ctx, globalModel, e)
+					if err != nil {
+						exitJSON("error", fmt.Sprintf("embedding failed: %v (use --no-embedding to match by name only)", err))
+					}
+					vectors = append(vectors, vector)
+				}
+			}
+			for _, vector := range vectors {
+				similar, err := t.FindSimilar(ctx, vector, float32(*minScore), purgeEmbeddingLimit)
+				if err != nil {
+					exitError(err)
+				}
+				for _, r := range similar {
+					found.Add(r, r.Score, purge.ByEmbedding)
+				}
+			}
+		}
+
+		// Locked memories are reported but left alone, as everywhere else;
+		// unlock them and purge again to remove them too.
+		for _, h := range found.Hits() {
+			entry := map[string]any{"id": h.ID, "matched_by": h.MatchedBy}
+			if h.Score > 0 {
+				entry["score"] = h.Score
+			}
+			if *dryRun {
+				entry["text"] = h.Payload["text"]
+			}
+			if t.IsCold() {
+				entry["cold"] = true
+			}
+			if store.IsLocked(h.Payload) {
+				entry["locked"] = true
+				locked = append(locked, h.ID)
+			} else {
+				ids = append(ids, h.ID)
+				tierIDs[i] = append(tierIDs[i], h.ID)
+				removed = append(removed, h)
+			}
+			listed = append(listed, entry)
+		}
+	}
+
+	result := map[string]any{
+		"status":   "ok",
+		"entities": []string(entities),
+		"matched":  len(listed),
+		"memories": listed,
+	}
+	if len(locked) > 0 {
+		result["locked"] = locked
+	}
+	if *dryRun {
+		result["dry_run"] = true
+		outputJSON(result)
+		return
+	}
+	action := "deleted"
+	if *archive {
+		action = "archived"
+	}
+	result[action] = len(ids)
+	if len(ids) == 0 {
+		outputJSON(result)
+		return
+	}
+
+	detail := map[string]any{"entities": []string(entities)}
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	for i, t := range tiers {
+		if !*archive {
+			if err := t.DeleteIDs(ctx, tierIDs[i]); err != nil {
+				exitError(err)
+			}
+			continue
+		}
+		if len(tierIDs[i]) == 0 {
+			continue
+		}
+		updates := make(map[string]map[string]any, len(tierIDs[i]))
+		for _, id := range tierIDs[i] {
+			updates[id] = store.ArchivePayload(now, store.ArchiveReasonPurge)
+		}
+		if err := t.SetPayloads(ctx, updates); err != nil {
+			exitError(err)
+		}
+	}
+	if *archive {
+		recordAudit(ctx, "archive", len(ids), ids, detail)
+	} else {
+		recordAudit(ctx, "purge", len(ids), ids, detail)
+	}
+
+	// Cached searches may still list the removed memories.
+	var texts, tags []string
+	for _, h := range removed {
+		if text, ok := h.Payload["text"].(string); ok {
+			texts = append(texts, text)
+		}
+		tags = append(tags, store.Tags(h.Payload)...)
+	}
+	invalidateCache(map[string]any{"text": strings.Join(texts, "\n"), "tags": store.TagsValue(tags)})
+
+	outputJSON(result)
+}
+
+// defaultArchiveGrace is how long an archived memory can be brought back
+// before purge --archived removes it for good.
+const defaultArchiveGrace = 30 * retention.Day
+
+// purgeArchived permanently deletes the memories archived more than
+// olderThan ago. Locked memories are left alone.
+func purgeArchived(olderThan time.Duration, dryRun bool) {
+	if olderThan < 0 {
+		exitJSON("error", "older-than must be non-negative")
+	}
+
+	s, ctx, cancel := connect()
+	defer cancel()
+	defer s.Close()
+
+	cutoff := time.Now().UTC().Add(-olderThan)
+	tiers := s.Tiers()
+	tierIDs := make([][]string, len(tiers))
+	ids := []string{}
+	listed := []map[string]any{}
+	for i, t := range tiers {
+		expired, err := t.ArchivedBefore(ctx, cutoff)
+		if err != nil {
+			exitError(err)
+		}
+		for _, r := range expired {
+			entry := map[string]any{
+				"id":             r.ID,
+				"text":           r.Payload["text"],
+				"archived_at":    r.Payload[store.ArchivedAtField],
+				"archive_reason": r.Payload[store.ArchiveReasonField],
+			}
+			if t.IsCold() {
+				entry["cold"] = true
+			}
+			listed = append(listed, entry)
+			ids = append(ids, r.ID)
+			tierIDs[i] = append(tierIDs[i], r.ID)
+		}
+	}
+	result := map[string]any{
+		"status":     "ok",
+		"older_than": olderThan.String(),
+		"memories":   listed,
+	}
+	if dryRun {
+		result["dry_run"] = true
+		result["would_delete"] = len(ids)
+		outputJSON(result)
+		return
+	}
+	if len(ids) > 0 {
+		for i, t := range tiers {
+			if err := t.DeleteIDs(ctx, tierIDs[i]); err != nil {
+				exitError(err)
+			}
+		}
+		recordAudit(ctx, "purge", len(ids), ids, map[string]any{"archived_older_than": olderThan.String()})
+	}
+	result["deleted"] = len(ids)
+	outputJSON(result)
+}
+
+func runHygiene(args []string) {
+	fs := flag.NewFlagSet("hygiene", flag.ExitOnError)
+	limit := fs.Int("limit", 20, "Maximum number of memories to list (0 = all)")
+	action := fs.String("action", "", "Only list memories with this recommended action: delete, refresh or confirm")
+	fs.Parse(args)
+
+	if *limit < 0 {
+		exitJSON("error", "limit must be non-negative")
+	}
+	switch *action {
+	case "", hygiene.ActionDelete, hygiene.ActionRefresh, hygiene.ActionConfirm:
+	default:
+		exitJSON("error", fmt.Sprintf("unknown action %q (want delete, refresh or confirm)", *action))
+	}
+
+	s, ctx, cancel := connect()
+	defer cancel()
+	defer s.Close()
+
+	memories, err := s.All(ctx)
+	if err != nil {
+		exitError(err)
+	}
+
+	now := time.Now().UTC()
+	sources := hygiene.NewSources()
+	assessed := make([]hygiene.Assessment, 0, len(memories))
+	for _, m := range memories {
+		assessed = append(assessed, hygiene.Assess(m, sources, now))
+	}
+
+	worst := hygiene.Worst(assessed)
+	byAction := map[string]int{}
+	results := []hygiene.Assessment{}
+	for _, a := range worst {
+		byAction[a.Action]++
+		if *action != "" && a.Action != *action {
+			continue
+		}
+		results = append(results, a)
+	}
+	if *limit > 0 && len(results) > *limit {
+		results = results[:*limit]
+	}
+
+	outputJSON(map[string]any{
+		"status":       "ok",
+		"total":        len(memories),
+		"needs_action": len(worst),
+		"by_action":    byAction,
+		"returned":     len(results),
+		"results":      results,
+	})
+}
+
+// previewForget lists what forget would delete or archive with these
+// settings, without changing anything. Each memory's reason says which TTL
+// caught it, and its action what would happen to it.
+func previewForget(ctx context.Context, s *store.Store, ttl, personalTTL time.Duration, weight float64, hard bool) {
+	listed := []map[string]any{}
+	seen := map[string]bool{}
+	counts := map[string]int{"delete": 0, "archive": 0}
+	list := func(t *store.Store, memories []store.Result, reason, action string) {
+		for _, r := range memories {
+			if seen[r.ID] {
+				continue
+			}
+			seen[r.ID] = true
+			entry := map[string]any{
+				"id":            r.ID,
+				"text":          r.Payload["text"],
+				"last_accessed": r.Payload["last_accessed"],
+				"reason":        reason,
+				"action":        action,
+			}
+			if t.IsCold() {
+				entry["cold"] = true
+			}
+			listed = append(listed, entry)
+			counts[action]++
+		}
+	}
+	for _, t := range s.Tiers() {
+		if pttl := min(personalTTL, ttl); pttl < ttl {
+			personal, err := t.StalePersonal(ctx, pttl)
+			if err != nil {
+				exitError(err)
+			}
+			list(t, personal, "personal_ttl", "delete")
+		}
+		if hard {
+			stale, err := t.Stale(ctx, ttl)
+			if err != nil {
+				exitError(err)
+			}
+			list(t, stale, "ttl", "delete")
+		} else {
+			stale, err := t.StaleUnarchived(ctx, ttl)
+			if err != nil {
+				exitError(err)
+			}
+			list(t, stale, "ttl", "archive")
+		}
+	}
+
+	outputJSON(map[string]any{
+		"status":           "ok",
+		"dry_run":          true,
+		"would_delete":     counts["delete"],
+		"would_archive":    counts["archive"],
+		"ttl":              ttl.String(),
+		"personal_ttl":     personalTTL.String(),
+		"frequency_weight": weight,
+		"memories":         listed,
+	})
+}
+
+// compressStale replaces each group of stale memories with a single summary
+// memory. A group's originals are deleted only after its summary has been
+// embedded and stored, so a failed summarization never loses data — the
+// group is reported in errors and left for the next run.
+func compressStale(ctx context.Context, s *store.Store, ttl time.Duration, weight float64, groupSize, personalDeleted int) {
+	memories, err := s.All(ctx)
+	if err != nil {
+		exitError(err)
+	}
+	groups := retention.GroupStale(retention.Stale(memories, ttl, weight, time.Now().UTC()), groupSize)
+
+	oc := ollama.New(globalOllamaURL)
+	emb := newEmbedder()
+	summaries := []map[string]any{}
+	errs := []string{}
+	compressed := 0
+	for _, g := range groups {
+		summary, err := oc.Generate(ctx, globalLLMModel, retention.SummaryPrompt(g))
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("summarize %s: %v", groupLabel(g), err))
+			continue
+		}
+		if strings.TrimSpace(summary) == "" {
+			errs = append(errs, fmt.Sprintf("summarize %s: empty summary", groupLabel(g)))
+			continue
+		}
+
+		payload := retention.SummaryPayload(g, summary)
+		vector, source, err := embedder.EmbedSource(ctx, emb, globalModel, payload["text"].(string))
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("embed summary of %s: %v", groupLabel(g), err))
+			continue
+		}
+		setEmbeddedBy(payload, source)
+		summaryID, err := s.Add(ctx, "", vector, payload)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("store summary of %s: %v", groupLabel(g), err))
+			continue
+		}
+
+		ids := mergedIDs(g.Memories)
+		if err := s.DeleteIDs(ctx, ids); err != nil {
+			errs = append(errs, fmt.Sprintf("delete originals of %s: %v", groupLabel(g), err))
+			continue
+		}
+		recordAudit(ctx, "compress", len(ids), ids, map[string]any{"ttl": ttl.String(), "summary_id": summaryID})
+
+		compressed += len(ids)
+		summaries = append(summaries, map[string]any{
+			"id":     summaryID,
+			"type":   g.Type,
+			"source": g.Source,
+			"count":  len(ids),
+		})
+	}
+
+	result := map[string]any{
+		"status":           "ok",
+		"ttl":              ttl.String(),
+		"compressed":       compressed,
+		"personal_deleted": personalDeleted,
+		"summaries":        summaries,
+	}
+	if len(errs) > 0 {
+		result["errors"] = errs
+	}
+	outputJSON(result)
+}
+
+// groupLabel describes a compression group in error messages.
+func groupLabel(g retention.Group) string {
+	return fmt.Sprintf("group (type=%q source=%q, %d memories)", g.Type, g.Source, len(g.Memories))
+}
+
+func runRetentionReport(args []string) {
+	fs := flag.NewFlagSet("retention-report", flag.ExitOnError)
+	format := fs.String("format", "json", "Report format: json or markdown")
+	out := fs.String("out", "", "Write the report to this file instead of stdout")
+	fs.Parse(args)
+
+	if *format != "json" && *format != "markdown" {
+		exitJSON("error", fmt.Sprintf("unknown format %q (want json or markdown)", *format))
+	}
+
+	auditLog := audit.New(globalAuditLog)
+	events, err := auditLog.Events()
+	if err != nil {
+		exitError(err)
+	}
+
+	s, ctx, cancel := connect()
+	defer cancel()
+	defer s.Close()
+
+	memories, err := s.All(ctx)
+	if err != nil {
+		exitError(err)
+	}
+
+	report := retention.BuildReport(memories, events, auditLog.Enabled(), time.Now().UTC())
+
+	var data []byte
+	if *format == "markdown" {
+		data = []byte(report.Markdown())
+	} else {
+		data, err = json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			exitJSON("error", fmt.Sprintf("marshal report: %v", err))
+		}
+	}
+
+	if *out == "" {
+		if *format == "markdown" {
+			fmt.Print(string(data))
+			return
+		}
+		outputJSON(map[string]any{
+			"status": "ok",
+			"report": report,
+		})
+		return
+	}
+
+	if err := os.WriteFile(*out, data, 0o644); err != nil {
+		exitJSON("error", fmt.Sprintf("write report: %v", err))
+	}
+	outputJSON(map[string]any{
+		"status": "ok",
+		"format": *format,
+		"out":    *out,
+	})
+}
+
+// backupTimeout bounds export and import, which go through the whole
+// collection and may re-embed every memory.
+const backupTimeout = 10 * time.Minute
+
+func runExport(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	out := fs.String("out", "", "JSONL file to write the export to (required)")
+	includePersonal := fs.Bool("include-personal", false, "Include personal memories, which are left out by default")
+	fs.Parse(args)
+
+	if *out == "" {
+		exitJSON("error", "--out is required")
+	}
+
+	s, err := openStore()
+	if err != nil {
+		exitError(err)
+	}
+	defer s.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), backupTimeout)
+	defer cancel()
+
+	dims, err := s.VectorSize(ctx)
+	if err != nil {
+		exitError(err)
+	}
+	syncState := readSyncState(ctx, s)
+	// The cold tier is exported too, after the hot collection; import
+	// restores every memory hot, for tier to move again.
+	cold := 0
+	exported, err := writeBackup(*out, globalModel, dims, syncState, func(w *backup.Writer) error {
+		filter := store.Filter{ExcludePersonal: !*includePersonal}
+		if err := s.Export(ctx, filter, w.Write); err != nil {
+			return err
+		}
+		hot := w.Count()
+		if err := s.Cold().Export(ctx, filter, w.Write); err != nil {
+			return err
+		}
+		cold = w.Count() - hot
+		return nil
+	})
+	if err != nil {
+		exitError(err)
+	}
+
+	outputJSON(map[string]any{
+		"status":           "ok",
+		"out":              *out,
+		"exported":         exported,
+		"cold":             cold,
+		"sync_files":       len(syncState),
+		"dimensions":       dims,
+		"model":            globalModel,
+		"include_personal": *includePersonal,
+	})
+}
+
+// readSyncState returns sync's record of the files it ingested into the
+// namespace, by path, for an export to carry along. If the sync state is
+// unreachable the export goes ahead without it, with a warning.
+func readSyncState(ctx context.Context, s *store.Store) map[string]string {
+	st, err := openSyncState(ctx, s)
+	if err != nil {
+		log.Printf("warning: sync state not exported: %v", err)
+		return nil
+	}
+	defer st.Close()
+	keys, err := st.Scan(sync.RedisKeyPattern(globalNamespace, ""))
+	if err != nil {
+		log.Printf("warning: sync state not exported: %v", err)
+		return nil
+	}
+	state := make(map[string]string, len(keys))
+	for _, key := range keys {
+		value, found, err := st.Get(key)
+		if err != nil {
+			log.Printf("warning: sync state not exported: %v", err)
+			return nil
+		}
+		if found {
+			state[sync.PathFromRedisKey(globalNamespace, key)] = value
+		}
+	}
+	return state
+}
+
+// restoreSyncState records the files of an export's sync state as synced
+// into the namespace, so that the next sync skips the files whose memories
+// were just imported rather than ingesting them a second time. It returns
+// how many files it recorded.
+func restoreSyncState(ctx context.Context, s *store.Store, state map[string]string) (int, error) {
+	if len(state) == 0 {
+		return 0, nil
+	}
+	st, err := openSyncState(ctx, s)
+	if err != nil {
+		return 0, err
+	}
+	defer st.Close()
+	restored := 0
+	for path, value := range state {
+		key := sync.RedisKey(globalNamespace, path)
+		if sync.IsMemoryMD(path) {
+			err = st.SetWithTTL(key, value, sync.MemoryMDTTLSeconds())
+		} else {
+			err = st.Set(key, value)
+		}
+		if err != nil {
+			return restored, err
+		}
+		restored++
+	}
+	return restored, nil
+}
+
+// writeBackup writes an export file of vectors made by model to path, with
+// write adding the memories, and returns how many it wrote. It writes next
+// to path and renames at the end, so a failed export never replaces a good
+// one.
+func writeBackup(path, model string, dims uint64, syncState map[string]string, write func(*backup.Writer) error) (int, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return 0, err
+	}
+	fail := func(err error) (int, error) {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return 0, err
+	}
+	w, err := backup.NewWriter(tmp, backup.Header{
+		Model:      model,
+		Dimensions: dims,
+		Agent:      globalAgent,
+		Namespace:  globalNamespace,
+		ExportedAt: time.Now().UTC().Format(time.RFC3339),
+		SyncState:  syncState,
+	})
+	if err != nil {
+		return fail(err)
+	}
+	if err := write(w); err != nil {
+		return fail(err)
 	}
-
-	return deleted
+	if err := w.Flush(); err != nil {
+		return fail(err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fail(err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return 0, err
+	}
+	return w.Count(), nil
 }
 
-// oldestCreatedAt returns the earliest created_at timestamp from a set of
-// merged results. Returns "" if no valid created_at is found.
-func oldestCreatedAt(results []store.Result) string {
-	oldest := ""
-	for _, r := range results {
-		if ca, ok := r.Payload["created_at"].(string); ok {
-			if oldest == "" || ca < oldest {
-				oldest = ca
-			}
+func runImport(args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	in := fs.String("in", "", "Export file to restore (- for stdin; required)")
+	reembed := fs.Bool("reembed", false, "Re-embed memory texts with the current model when the export's vectors don't fit it")
+	noSyncState := fs.Bool("no-sync-state", false, "Don't restore the export's sync state, so sync ingests its files again")
+	fs.Parse(args)
+
+	if *in == "" {
+		exitJSON("error", "--in is required")
+	}
+	var r io.Reader = os.Stdin
+	if *in != "-" {
+		f, err := os.Open(*in)
+		if err != nil {
+			exitError(err)
 		}
+		defer f.Close()
+		r = f
+	}
+	header, points, err := backup.Read(r)
+	if err != nil {
+		exitJSON("error", fmt.Sprintf("%s: %v", *in, err))
 	}
-	return oldest
-}
 
-// mergedIDs extracts the point IDs from a set of merged results.
-func mergedIDs(results []store.Result) []string {
-	ids := make([]string, len(results))
-	for i, r := range results {
-		ids[i] = r.ID
+	s, err := openStore()
+	if err != nil {
+		exitError(err)
 	}
-	return ids
-}
+	defer s.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), backupTimeout)
+	defer cancel()
 
-// multiFlag implements flag.Value to allow repeated flags (e.g. --file a --file b).
-type multiFlag []string
+	dims, err := s.VectorSize(ctx)
+	if err != nil {
+		exitError(err)
+	}
+	mismatch := len(points) > 0 && dims != 0 && dims != header.Dimensions
+	if mismatch && !*reembed {
+		exitJSON("error", fmt.Sprintf("the export's vectors have %d dimensions but the collection's have %d; pass --reembed to re-embed with %s",
+			header.Dimensions, dims, globalModel))
+	}
 
-func (f *multiFlag) String() string { return strings.Join(*f, ", ") }
-func (f *multiFlag) Set(value string) error {
-	*f = append(*f, value)
-	return nil
+	// Vectors from another model are only comparable to each other, so
+	// --reembed redoes them all whenever the model or the size is off.
+	reembedded := *reembed && len(points) > 0 && (mismatch || header.Model != globalModel)
+	var skipped []string
+	if reembedded {
+		points, skipped, err = reembedPoints(ctx, points)
+		if err != nil {
+			exitError(err)
+		}
+	}
+	if err := s.Import(ctx, points); err != nil {
+		exitError(err)
+	}
+
+	result := map[string]any{
+		"status":     "ok",
+		"in":         *in,
+		"imported":   len(points),
+		"reembedded": reembedded,
+	}
+	if len(skipped) > 0 {
+		result["skipped"] = skipped
+	}
+	// Without the sync state, the next sync would ingest every file the
+	// imported memories came from again.
+	if !*noSyncState && len(header.SyncState) > 0 {
+		restored, err := restoreSyncState(ctx, s, header.SyncState)
+		if err != nil {
+			log.Printf("warning: sync state not restored: %v", err)
+		}
+		result["sync_restored"] = restored
+	}
+	// A large import leaves segments without an index; make sure the
+	// optimizer picks them up rather than waiting to be triggered, and tell
+	// the caller whether searches are still scanning.
+	if len(points) > 0 {
+		if err := s.Optimize(ctx); err != nil {
+			log.Printf("warning: %v", err)
+		} else if index, err := s.IndexStatus(ctx); err == nil {
+			result["index"] = index
+		}
+	}
+	outputJSON(result)
 }
 
-func runSync(args []string) {
-	fs := flag.NewFlagSet("sync", flag.ExitOnError)
-	var files multiFlag
-	var dirs multiFlag
-	var excludes multiFlag
-	fs.Var(&files, "file", "Path to a markdown file to ingest (repeatable)")
-	fs.Var(&dirs, "dir", "Path to a directory of markdown files (repeatable)")
-	fs.Var(&excludes, "exclude", "Glob pattern to exclude from sync (repeatable)")
-	basePath := fs.String("base", ".", "Base path for default file discovery (env: CLAWBRAIN_WORKSPACE)")
+// optimizePollInterval is how often optimize checks whether the optimizer
+// is done.
+const optimizePollInterval = time.Second
+
+// runOptimize triggers the Qdrant optimizers, which build the vector index
+// of segments that lack one, and waits for them to finish, so a large
+// import can be followed by searches that run against the index rather
+// than scanning.
+func runOptimize(args []string) {
+	fs := flag.NewFlagSet("optimize", flag.ExitOnError)
+	statusOnly := fs.Bool("status", false, "Only report the indexing status, without triggering the optimizers")
+	noWait := fs.Bool("no-wait", false, "Trigger the optimizers and return without waiting for them")
+	timeout := fs.Duration("timeout", 30*time.Minute, "How long to wait for the optimizers to finish")
 	fs.Parse(args)
 
-	// Environment variable override for base path
-	if v := os.Getenv("CLAWBRAIN_WORKSPACE"); v != "" && *basePath == "." {
-		*basePath = v
+	if *statusOnly && *noWait {
+		exitJSON("error", "--status and --no-wait are mutually exclusive")
+	}
+	if *timeout <= 0 {
+		exitJSON("error", "timeout must be positive")
 	}
 
-	// Connect to services. Sync is a batch operation that may process many
-	// files and chunks, so use a much longer timeout than the default 30s.
-	s, err := store.New(globalHost, globalPort)
+	s, err := openStore()
 	if err != nil {
-		exitJSON("error", err.Error())
+		exitError(err)
 	}
 	defer s.Close()
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
 	defer cancel()
 
-	oc := ollama.New(globalOllamaURL)
-
-	rc, err := redis.New(globalRedisHost, globalRedisPort)
+	result := map[string]any{"status": "ok"}
+	var index store.IndexStatus
+	switch {
+	case *statusOnly:
+		index, err = s.IndexStatus(ctx)
+	case *noWait:
+		if err = s.Optimize(ctx); err == nil {
+			result["triggered"] = true
+			index, err = s.IndexStatus(ctx)
+		}
+	default:
+		start := time.Now()
+		if err = s.Optimize(ctx); err == nil {
+			result["triggered"] = true
+			index, err = s.WaitOptimized(ctx, optimizePollInterval)
+			result["waited_ms"] = time.Since(start).Milliseconds()
+		}
+	}
 	if err != nil {
-		exitJSON("error", fmt.Sprintf("redis: %v", err))
+		exitError(err)
+	}
+	result["index"] = index
+	outputJSON(result)
+}
+
+// reembedPoints replaces each point's vector with an embedding of its text
+// by the current model, batchEmbedSize texts per request. Memories without
+// text can't be re-embedded; they are dropped and their IDs returned.
+// runMigrateEmbeddings re-embeds every memory with the current --model and
+// rebuilds the collection around the new vectors, after backing it up.
+func runMigrateEmbeddings(args []string) {
+	fs := flag.NewFlagSet("migrate-embeddings", flag.ExitOnError)
+	backupPath := fs.String("backup", "", "Export file to write the collection to before rebuilding it (required)")
+	skipTextless := fs.Bool("skip-textless", false, "Leave out memories without text, which can't be re-embedded (they stay in the backup)")
+	dryRun := fs.Bool("dry-run", false, "Report what would be re-embedded without changing anything")
+	fs.Parse(args)
+
+	if *backupPath == "" && !*dryRun {
+		exitJSON("error", "--backup is required")
+	}
+	if globalAgent != "" {
+		exitJSON("error", "migrate-embeddings rebuilds the whole collection, every agent's memories included; run it without --agent")
 	}
-	defer rc.Close()
 
-	// Discover files
-	discovered, err := sync.DiscoverFiles(*basePath, files, dirs)
+	s, err := openStore()
 	if err != nil {
-		exitJSON("error", fmt.Sprintf("discover files: %v", err))
+		exitError(err)
 	}
+	defer s.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), backupTimeout)
+	defer cancel()
 
-	// Load ignore patterns: .clawbrain-ignore file + --exclude flags
-	ignorePatterns := sync.LoadIgnorePatterns(*basePath)
-	ignorePatterns = append(ignorePatterns, excludes...)
+	from, err := s.Embedding(ctx)
+	if err != nil {
+		exitError(err)
+	}
+	if !from.Exists {
+		exitJSON("error", "there is no collection to migrate")
+	}
+	var points []store.Point
+	if err := s.Export(ctx, store.Filter{}, func(p store.Point) error {
+		points = append(points, p)
+		return nil
+	}); err != nil {
+		exitError(err)
+	}
+	var textless []string
+	for _, p := range points {
+		if text, _ := p.Payload["text"].(string); strings.TrimSpace(text) == "" {
+			textless = append(textless, p.ID)
+		}
+	}
 
-	if len(discovered) == 0 {
-		outputJSON(map[string]any{
-			"status":  "ok",
-			"files":   0,
-			"added":   0,
-			"skipped": 0,
-			"results": []any{},
-		})
+	result := map[string]any{
+		"status": "ok",
+		"from":   from,
+		"model":  globalModel,
+	}
+	if len(textless) > 0 {
+		result["textless"] = textless
+	}
+	if *dryRun {
+		result["dry_run"] = true
+		result["would_reembed"] = len(points) - len(textless)
+		outputJSON(result)
 		return
 	}
+	if len(textless) > 0 && !*skipTextless {
+		exitJSON("error", fmt.Sprintf("%d memories have no text to re-embed; pass --skip-textless to leave them out (they stay in the backup)", len(textless)))
+	}
 
-	totalAdded := 0
-	totalSkipped := 0
-	var results []sync.FileResult
-
-	for _, filePath := range discovered {
-		// Check ignore patterns
-		if sync.IsIgnored(filePath, ignorePatterns) {
-			fr := sync.FileResult{
-				File:    filePath,
-				Skipped: 1,
-				Reason:  "excluded by ignore pattern",
+	if _, err := writeBackup(*backupPath, from.Model, from.Dimensions, readSyncState(ctx, s), func(w *backup.Writer) error {
+		for _, p := range points {
+			if err := w.Write(p); err != nil {
+				return err
 			}
-			results = append(results, fr)
-			totalSkipped++
-			continue
 		}
+		return nil
+	}); err != nil {
+		exitError(err)
+	}
+	// Embed everything before touching the collection: if Ollama fails
+	// halfway, nothing has changed.
+	points, _, err = reembedPoints(ctx, points)
+	if err != nil {
+		exitError(err)
+	}
+	if err := s.DeleteCollection(ctx); err != nil {
+		exitError(err)
+	}
+	if err := s.Import(ctx, points); err != nil {
+		exitError(fmt.Errorf("%w; the collection is gone, restore it with import --in %s", err, *backupPath))
+	}
+	if len(textless) > 0 {
+		recordAudit(ctx, "migrate-embeddings", len(textless), textless, map[string]any{"from": from.Model, "to": globalModel})
+	}
+
+	to, err := s.Embedding(ctx)
+	if err != nil {
+		exitError(err)
+	}
+	result["to"] = to
+	result["reembedded"] = len(points)
+	result["backup"] = *backupPath
+	outputJSON(result)
+}
+
+func reembedPoints(ctx context.Context, points []store.Point) (kept []store.Point, skipped []string, err error) {
+	for _, p := range points {
+		if text, _ := p.Payload["text"].(string); strings.TrimSpace(text) != "" {
+			kept = append(kept, p)
+		} else {
+			skipped = append(skipped, p.ID)
+		}
+	}
+	emb := newEmbedder()
+	for start := 0; start < len(kept); start += batchEmbedSize {
+		chunk := kept[start:min(start+batchEmbedSize, len(kept))]
+		texts := make([]string, len(chunk))
+		for i, p := range chunk {
+			texts[i] = p.Payload["text"].(string)
+		}
+		vectors, source, err := embedder.EmbedBatchSource(ctx, emb, globalModel, texts)
+		if err != nil {
+			return nil, nil, fmt.Errorf("embedding failed: %w", err)
+		}
+		for i := range chunk {
+			chunk[i].Vector = vectors[i]
+			setEmbeddedBy(chunk[i].Payload, source)
+		}
+	}
+	return kept, skipped, nil
+}
 
-		// Skip today's daily file — it's still being written
-		if sync.IsTodayDailyFile(filePath) {
-			fr := sync.FileResult{
-				File:    filePath,
-				Skipped: 1,
-				Reason:  "today's daily file, still growing",
-			}
-			results = append(results, fr)
-			totalSkipped++
-			continue
+// maxSeed bounds seed --n; a bigger fixture is a load test, not a fixture.
+const maxSeed = 100000
+
+func runSeed(args []string) {
+	fs := flag.NewFlagSet("seed", flag.ExitOnError)
+	scenario := fs.String("scenario", "orientation", "Kind of collection to generate (see --list)")
+	n := fs.Int("n", 500, "Number of memories to generate")
+	seedValue := fs.Int64("seed", 1, "Random seed; the same seed always generates the same memories")
+	vectorSize := fs.Int("vector-size", 0, "Use random vectors of this size instead of embedding with Ollama (for load benchmarks)")
+	clearOld := fs.Bool("clear", false, "First delete memories seeded earlier with this scenario")
+	list := fs.Bool("list", false, "List the scenarios and exit")
+	fs.Parse(args)
+
+	if *list {
+		var scenarios []map[string]string
+		for _, name := range seed.Scenarios() {
+			scenarios = append(scenarios, map[string]string{"name": name, "description": seed.Describe(name)})
+		}
+		outputJSON(map[string]any{"status": "ok", "scenarios": scenarios})
+		return
+	}
+	if *n < 1 || *n > maxSeed {
+		exitJSON("error", fmt.Sprintf("n must be between 1 and %d", maxSeed))
+	}
+	if *vectorSize < 0 {
+		exitJSON("error", "vector-size must be non-negative")
+	}
+	memories, err := seed.Generate(*scenario, *n, *seedValue, time.Now())
+	if err != nil {
+		exitError(err)
+	}
+	allowed := loadConfig().MemoryTypes()
+	for _, m := range memories {
+		if err := store.NormalizeType(m.Payload, allowed); err != nil {
+			exitJSON("error", fmt.Sprintf("scenario %s: %v", *scenario, err))
 		}
+	}
 
-		redisKey := sync.RedisKey(filePath)
-		isMemoryMD := sync.IsMemoryMD(filePath)
+	s, err := openStore()
+	if err != nil {
+		exitError(err)
+	}
+	defer s.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), backupTimeout)
+	defer cancel()
 
-		// For non-MEMORY.md files, check Redis first (cheap) before reading
-		// the file. These files are immutable — a simple existence check suffices.
-		if !isMemoryMD {
-			exists, err := rc.Exists(redisKey)
-			if err != nil {
-				exists = false
+	var vectors [][]float32
+	if *vectorSize > 0 {
+		vectors = seed.Vectors(len(memories), *vectorSize, *seedValue)
+	} else {
+		emb := newEmbedder()
+		for start := 0; start < len(memories); start += batchEmbedSize {
+			chunk := memories[start:min(start+batchEmbedSize, len(memories))]
+			texts := make([]string, len(chunk))
+			for i, m := range chunk {
+				texts[i] = m.Payload["text"].(string)
 			}
-			if exists {
-				fr := sync.FileResult{
-					File:    filePath,
-					Skipped: 1,
-					Reason:  "already synced",
-				}
-				results = append(results, fr)
-				totalSkipped++
-				continue
+			embedded, err := emb.EmbedBatch(ctx, globalModel, texts)
+			if err != nil {
+				exitJSON("error", fmt.Sprintf("embedding failed: %v (use --vector-size to seed without Ollama)", err))
 			}
+			vectors = append(vectors, embedded...)
 		}
+	}
+	if len(vectors) > 0 {
+		guardEmbedding(ctx, s, vectors[0], *vectorSize == 0)
+	}
 
-		// Read file content
-		content, err := os.ReadFile(filePath)
+	var cleared []string
+	if *clearOld {
+		old, err := s.Find(ctx, []store.Condition{{Key: seed.Field, Value: *scenario}})
 		if err != nil {
-			fr := sync.FileResult{
-				File:   filePath,
-				Reason: fmt.Sprintf("read error: %v", err),
-			}
-			results = append(results, fr)
-			continue
+			exitError(err)
 		}
-
-		text := string(content)
-		if strings.TrimSpace(text) == "" {
-			fr := sync.FileResult{
-				File:    filePath,
-				Skipped: 1,
-				Reason:  "empty file",
-			}
-			results = append(results, fr)
-			totalSkipped++
-			continue
+		for _, r := range old {
+			cleared = append(cleared, r.ID)
 		}
-
-		// For MEMORY.md: compare content hash — re-sync only if file changed.
-		var contentHash string
-		if isMemoryMD {
-			contentHash = sync.ContentHash(content)
-			storedHash, found, err := rc.Get(redisKey)
-			if err == nil && found && storedHash == contentHash {
-				fr := sync.FileResult{
-					File:    filePath,
-					Skipped: 1,
-					Reason:  "already synced (unchanged)",
-				}
-				results = append(results, fr)
-				totalSkipped++
-				continue
-			}
+		if err := s.DeleteIDs(ctx, cleared); err != nil {
+			exitError(err)
 		}
+		recordAudit(ctx, "seed", len(cleared), cleared, map[string]any{"scenario": *scenario})
+	}
 
-		// Chunk the file
-		chunks := sync.Chunk(text, sync.DefaultChunkSize, sync.DefaultChunkOverlap)
-		added := 0
+	points := make([]store.Point, len(memories))
+	for i, m := range memories {
+		points[i] = store.Point{ID: m.ID, Vector: vectors[i], Payload: m.Payload}
+	}
+	// Import keeps the generated timestamps, where Add would stamp now.
+	if err := s.Import(ctx, points); err != nil {
+		exitError(err)
+	}
 
-		for i, chunk := range chunks {
-			normalized := sync.NormalizeText(chunk)
-			if normalized == "" {
-				continue
-			}
+	outputJSON(map[string]any{
+		"status":   "ok",
+		"scenario": *scenario,
+		"seed":     *seedValue,
+		"seeded":   len(points),
+		"cleared":  len(cleared),
+		"embedded": *vectorSize == 0,
+	})
+}
 
-			// Embed via Ollama
-			vector, err := oc.Embed(ctx, globalModel, normalized)
-			if err != nil {
-				// Non-fatal per chunk: log and continue
-				log.Printf("sync: embed failed for %s chunk %d: %v", filePath, i, err)
-				continue
-			}
+// recordAudit appends a deletion event to the audit log, if one is configured.
+// Failures are logged but not fatal — the deletion has already happened and
+// its result must still reach the caller.
+func recordAudit(ctx context.Context, action string, count int, ids []string, detail map[string]any) {
+	// What a sandbox loses is an experiment, not a deletion to account for.
+	if count == 0 || globalSandbox != "" {
+		return
+	}
+	err := audit.New(globalAuditLog).Record(audit.Event{
+		Action:    action,
+		Count:     count,
+		IDs:       ids,
+		Detail:    detail,
+		RequestID: reqid.From(ctx),
+	})
+	if err != nil {
+		log.Printf("warning: failed to record audit event: %v", err)
+	}
+}
 
-			// Add to store with source metadata
-			payload := map[string]any{
-				"text":        normalized,
-				"source":      filePath,
-				"chunk_index": i,
-			}
+func runCheck() {
+	s, ctx, cancel := connect()
+	defer cancel()
+	defer s.Close()
 
-			// Run dedup before adding (same as regular add)
-			merged := dedupAndDelete(ctx, s, vector)
-			if len(merged) > 0 {
-				if ca := oldestCreatedAt(merged); ca != "" {
-					payload["created_at"] = ca
-				}
-			}
+	// Check the vector store
+	if err := s.Check(ctx); err != nil {
+		exitJSON("error", fmt.Sprintf("%s: %v", globalBackend, err))
+	}
 
-			_, err = s.Add(ctx, "", vector, payload)
-			if err != nil {
-				log.Printf("sync: store failed for %s chunk %d: %v", filePath, i, err)
-				continue
+	// Check the embedder
+	if err := newEmbedder().Health(ctx); err != nil {
+		exitJSON("error", fmt.Sprintf("%s: %v", globalEmbedder, err))
+	}
+
+	storeName := "Qdrant"
+	switch globalBackend {
+	case store.BackendFile:
+		storeName = "The file store"
+	case store.BackendSQLite:
+		storeName = "The SQLite store"
+	}
+	message := storeName + " and Ollama verified"
+	if globalEmbedder != embedder.Ollama {
+		message = storeName + " and the " + globalEmbedder + " embedder verified"
+	}
+	response := map[string]any{
+		"status":  "ok",
+		"message": message,
+	}
+	// With replicas, the check passes while any endpoint is up; one that
+	// is down degrades it.
+	if endpoints := s.CheckEndpoints(ctx); endpoints != nil {
+		response["endpoints"] = endpoints
+		for _, e := range endpoints {
+			if !e.Healthy {
+				response["status"] = "degraded"
+			}
+		}
+	}
+	// So with embedder fallbacks, while any provider is up.
+	if embedChain != nil {
+		providers := embedChain.Check(ctx)
+		response["embedders"] = providers
+		for _, p := range providers {
+			if !p.Healthy {
+				response["status"] = "degraded"
 			}
-			added++
 		}
+	}
+	outputJSON(response)
+}
 
-		// Only mark file as processed in Redis if at least one chunk
-		// was successfully stored. If all chunks failed (e.g. Ollama
-		// was down), leave the file unmarked so it gets retried next run.
-		if added > 0 {
-			if isMemoryMD {
-				// Store the content hash so we can detect changes next run.
-				// Use a 7-day TTL as a safety net — even if the file hasn't
-				// changed, it will be re-synced after a week. This catches
-				// edge cases like hash collisions or corrupted state.
-				rc.SetWithTTL(redisKey, contentHash, sync.MemoryMDTTLSeconds())
-			} else {
-				rc.Set(redisKey, "1")
+// selftestStage is the outcome of one stage of selftest.
+type selftestStage struct {
+	Name     string `json:"name"`
+	OK       bool   `json:"ok"`
+	Detail   string `json:"detail,omitempty"`
+	Error    string `json:"error,omitempty"`
+	Duration int64  `json:"duration_ms"`
+}
+
+// Texts selftest stores. The control memory reads nothing like the canary,
+// so neither is merged into the other.
+const (
+	selftestCanary  = "clawbrain selftest canary: the lighthouse keeper logs the tide at dawn"
+	selftestQuery   = "who writes down the tide in the morning at the lighthouse"
+	selftestControl = "clawbrain selftest control: a quarterly invoice for office chairs"
+)
+
+// runSelftest runs the memory loop end to end in a scratch namespace:
+// add a canary, find it by meaning, add it again and check it merges, pin
+// it, forget everything stale and check the pinned canary survives while
+// an unpinned control memory doesn't. Each stage runs this binary's own
+// commands, with the same settings, so it checks what an agent would run;
+// check only shows the services answer. The namespace is dropped at the
+// end, pass or fail.
+func runSelftest(args []string) {
+	fs := flag.NewFlagSet("selftest", flag.ExitOnError)
+	keep := fs.Bool("keep", false, "Keep the scratch namespace for inspection instead of dropping it")
+	fs.Parse(args)
+
+	self, err := os.Executable()
+	if err != nil {
+		exitError(err)
+	}
+	namespace := fmt.Sprintf("selftest-%d", time.Now().UnixNano())
+	env := globalEnv()
+	env["CLAWBRAIN_NAMESPACE"] = namespace
+	// The scratch namespace isn't sandboxed, and a sandbox of it wouldn't
+	// exist.
+	env["CLAWBRAIN_SANDBOX"] = ""
+
+	// run runs a command in the namespace and returns its JSON response.
+	run := func(args ...string) (map[string]any, error) {
+		cmd := exec.Command(self, args...)
+		cmd.Env = plugin.Env(env)
+		out, err := cmd.Output()
+		lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+		var response map[string]any
+		if jerr := json.Unmarshal([]byte(lines[len(lines)-1]), &response); jerr != nil {
+			if err == nil {
+				err = jerr
 			}
+			return nil, fmt.Errorf("%s: %w", args[0], err)
+		}
+		if status, _ := response["status"].(string); status != "ok" {
+			message, _ := response["message"].(string)
+			return response, fmt.Errorf("%s: %s: %s", args[0], status, message)
 		}
+		return response, nil
+	}
 
-		fr := sync.FileResult{
-			File:  filePath,
-			Added: added,
+	var stages []selftestStage
+	failed := ""
+	// stage runs fn unless an earlier stage failed.
+	stage := func(name string, fn func() (string, error)) {
+		if failed != "" {
+			return
 		}
-		results = append(results, fr)
-		totalAdded += added
+		start := time.Now()
+		detail, err := fn()
+		st := selftestStage{Name: name, OK: err == nil, Detail: detail, Duration: time.Since(start).Milliseconds()}
+		if err != nil {
+			st.Error = err.Error()
+			failed = name
+		}
+		stages = append(stages, st)
 	}
 
-	outputJSON(map[string]any{
-		"status":  "ok",
-		"files":   len(discovered),
-		"added":   totalAdded,
-		"skipped": totalSkipped,
-		"results": results,
+	var canary, control string
+	stage("add", func() (string, error) {
+		r, err := run("add", "--text", selftestCanary, "--no-merge")
+		if err != nil {
+			return "", err
+		}
+		canary, _ = r["id"].(string)
+		return "stored canary " + canary, nil
+	})
+	stage("search", func() (string, error) {
+		r, err := run("search", "--query", selftestQuery, "--limit", "1")
+		if err != nil {
+			return "", err
+		}
+		results, _ := r["results"].([]any)
+		if len(results) == 0 {
+			return "", errors.New("search found nothing")
+		}
+		top, _ := results[0].(map[string]any)
+		if id, _ := top["id"].(string); id != canary {
+			return "", fmt.Errorf("search found %s, not the canary %s", id, canary)
+		}
+		return fmt.Sprintf("found the canary with score %v", top["score"]), nil
+	})
+	stage("dedup", func() (string, error) {
+		r, err := run("add", "--text", selftestCanary)
+		if err != nil {
+			return "", err
+		}
+		if merged, _ := r["merged_id"].(string); merged != canary {
+			return "", errors.New("adding the canary again didn't merge it")
+		}
+		canary, _ = r["id"].(string)
+		return "merged into " + canary, nil
+	})
+	stage("pin", func() (string, error) {
+		if _, err := run("update", "--id", canary, "--payload", `{"pinned":true}`); err != nil {
+			return "", err
+		}
+		r, err := run("add", "--text", selftestControl, "--no-merge")
+		if err != nil {
+			return "", err
+		}
+		control, _ = r["id"].(string)
+		return "pinned the canary; stored unpinned control " + control, nil
+	})
+	stage("forget", func() (string, error) {
+		r, err := run("forget", "--ttl", "0s", "--hard")
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("forgot %v memories", r["deleted"]), nil
+	})
+	stage("survival", func() (string, error) {
+		r, err := run("get", "--id", canary)
+		if err != nil {
+			return "", fmt.Errorf("pinned canary didn't survive forget: %w", err)
+		}
+		if payload, _ := r["payload"].(map[string]any); !retention.IsPinned(payload) {
+			return "", errors.New("canary is no longer pinned")
+		}
+		if _, err := run("get", "--id", control); err == nil {
+			return "", errors.New("unpinned control survived forget")
+		}
+		return "pinned canary kept, control forgotten", nil
 	})
+
+	cleanup := selftestStage{Name: "cleanup", OK: true}
+	start := time.Now()
+	if *keep {
+		cleanup.Detail = "kept namespace " + namespace
+	} else if err := dropNamespace(namespace); err != nil {
+		cleanup.OK, cleanup.Error = false, err.Error()
+	} else {
+		cleanup.Detail = "dropped namespace " + namespace
+	}
+	cleanup.Duration = time.Since(start).Milliseconds()
+	stages = append(stages, cleanup)
+
+	result := map[string]any{"status": "ok", "namespace": namespace, "stages": stages}
+	switch {
+	case failed != "":
+		result["status"] = "error"
+		result["message"] = "selftest failed at " + failed
+	case !cleanup.OK:
+		result["status"] = "error"
+		result["message"] = "selftest passed but couldn't drop namespace " + namespace
+	}
+	outputJSON(result)
+	if result["status"] != "ok" {
+		os.Exit(1)
+	}
 }
 
-func runSearch(args []string) {
-	fs := flag.NewFlagSet("search", flag.ExitOnError)
-	query := fs.String("query", "", "Text to search for (default mode)")
-	vectorJSON := fs.String("vector", "", "Query embedding as JSON array (advanced, overrides text mode)")
-	minScore := fs.Float64("min-score", 0.0, "Minimum similarity score threshold")
-	limit := fs.Uint64("limit", 1, "Maximum number of results")
+// dropNamespace deletes a namespace's collections, hot and cold.
+func dropNamespace(namespace string) error {
+	s, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	s.SetNamespace(namespace)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return dropSandbox(ctx, s)
+}
+
+// runVersion prints the clawbrain version and, with --verbose, what a
+// script or client needs to know what it is talking to: how the binary
+// was built, the versions of the services it is configured for, its
+// features and the collection's schema version. A service that can't be
+// reached is reported with its error rather than failing the command.
+func runVersion(args []string) {
+	fs := flag.NewFlagSet("version", flag.ExitOnError)
+	verbose := fs.Bool("verbose", false, "Also report build info, service versions, features and the collection schema")
+	timeout := fs.Duration("timeout", 10*time.Second, "How long to wait for each service")
 	fs.Parse(args)
 
-	s, ctx, cancel := connect()
-	defer cancel()
-	defer s.Close()
+	if *timeout <= 0 {
+		exitJSON("error", "timeout must be positive")
+	}
+	result := map[string]any{
+		"status":  "ok",
+		"version": version,
+	}
+	if !*verbose {
+		outputJSON(result)
+		return
+	}
 
-	if *vectorJSON != "" {
-		// Advanced vector mode
-		var vector []float32
-		if err := json.Unmarshal([]byte(*vectorJSON), &vector); err != nil {
-			exitJSON("error", fmt.Sprintf("invalid vector JSON: %v", err))
+	build := map[string]any{"go": runtime.Version(), "os": runtime.GOOS, "arch": runtime.GOARCH}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				build["revision"] = setting.Value
+			case "vcs.time":
+				build["time"] = setting.Value
+			case "vcs.modified":
+				build["modified"] = setting.Value == "true"
+			}
 		}
+	}
 
-		results, err := s.Retrieve(ctx, vector, float32(*minScore), *limit)
+	services := map[string]any{}
+	probe := func(name string, fn func(ctx context.Context) (string, error)) {
+		ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+		defer cancel()
+		v, err := fn(ctx)
+		service := map[string]any{"ok": err == nil}
 		if err != nil {
-			exitJSON("error", err.Error())
+			service["error"] = err.Error()
+		} else if v != "" {
+			service["version"] = v
 		}
-
-		outputJSON(map[string]any{
-			"status":     "ok",
-			"results":    results,
-			"returned":   len(results),
-			"confidence": confidence(results),
-		})
-	} else if *query != "" {
-		// Default text mode: embed query via Ollama, then search
-		oc := ollama.New(globalOllamaURL)
-		vector, err := oc.Embed(ctx, globalModel, *query)
+		services[name] = service
+	}
+	var schema map[string]any
+	probe(globalBackend, func(ctx context.Context) (string, error) {
+		s, err := openStore()
 		if err != nil {
-			exitJSON("error", fmt.Sprintf("embedding failed: %v", err))
+			return "", err
 		}
-
-		results, err := s.Retrieve(ctx, vector, float32(*minScore), *limit)
+		defer s.Close()
+		v, err := s.ServerVersion(ctx)
+		if err != nil {
+			return "", err
+		}
+		if sc, err := s.Schema(ctx); err == nil {
+			schema = map[string]any{"exists": sc.Exists, "latest_version": store.SchemaVersion}
+			if sc.Exists {
+				schema["schema_version"] = sc.Version
+				schema["pending"] = len(sc.Pending)
+				if sc.Clawbrain != "" {
+					schema["clawbrain_version"] = sc.Clawbrain
+				}
+			}
+		}
+		return v, nil
+	})
+	probe(globalEmbedder, func(ctx context.Context) (string, error) {
+		if globalEmbedder == embedder.Ollama {
+			return ollama.New(globalOllamaURL).Version(ctx)
+		}
+		return "", newEmbedder().Health(ctx)
+	})
+	probe("redis", func(ctx context.Context) (string, error) {
+		rc, err := redis.New(globalRedisHost, globalRedisPort)
 		if err != nil {
-			exitJSON("error", err.Error())
+			return "", err
 		}
+		defer rc.Close()
+		return rc.ServerVersion()
+	})
 
-		outputJSON(map[string]any{
-			"status":     "ok",
-			"results":    results,
-			"returned":   len(results),
-			"confidence": confidence(results),
+	sync.RegisterCommands()
+	maps.Copy(result, map[string]any{
+		"build":         build,
+		"backend":       globalBackend,
+		"state_backend": globalState,
+		"embedder":      globalEmbedder,
+		"model":         globalModel,
+		"services":      services,
+		"features":      features,
+		"formats":       sync.Extensions(),
+	})
+	if schema != nil {
+		result["schema"] = schema
+	}
+	outputJSON(result)
+}
+
+// newEmbedder returns the embedder chosen with --embedder: Ollama at
+// --ollama-url, or an OpenAI-compatible server at --embedder-url. With
+// --embedder-fallbacks, it is the chain of that one and the fallbacks.
+func newEmbedder() embedder.Embedder {
+	url := globalOllamaURL
+	if globalEmbedder == embedder.OpenAI {
+		url = globalEmbedderURL
+	}
+	e, err := embedder.New(globalEmbedder, url, globalAPIKey)
+	if err != nil {
+		exitError(err)
+	}
+	if globalFallbacks != "" {
+		embedChainOnce.Do(func() {
+			fallbacks, err := embedder.ParseProviders(globalFallbacks, globalAPIKey)
+			if err != nil {
+				exitError(err)
+			}
+			primary := embedder.Provider{Name: embedder.ProviderName(globalEmbedder, url), Embedder: e}
+			if embedChain, err = embedder.NewChain(append([]embedder.Provider{primary}, fallbacks...), embedder.DefaultRecheck); err != nil {
+				exitError(err)
+			}
 		})
-	} else {
-		fmt.Fprintln(os.Stderr, "Error: --query is required (or --vector for advanced mode)")
-		fs.Usage()
-		os.Exit(1)
+		e = embedChain
 	}
+	if globalTiming {
+		e = embedder.Meter(e, &embedUsage)
+	}
+	return e
 }
 
-func runDelete(args []string) {
-	fs := flag.NewFlagSet("delete", flag.ExitOnError)
-	days := fs.Int("d", 30, "Delete memories not accessed in the last N days")
+// embedChain is the embedder chain of --embedder-fallbacks, made once so
+// that every embed of the process, serve's included, skips the providers
+// found down.
+var (
+	embedChainOnce gosync.Once
+	embedChain     *embedder.Chain
+)
+
+// warmupText is embedded to make Ollama load the model into memory.
+const warmupText = "warmup"
+
+// runWarmup opens the Qdrant connection, pings Redis and embeds a dummy text
+// so Ollama loads the model, letting a container entrypoint pay the cold-start
+// cost before the first real agent query. Every step runs even if an earlier
+// one fails, so the output shows everything that isn't ready.
+func runWarmup(args []string) {
+	fs := flag.NewFlagSet("warmup", flag.ExitOnError)
+	timeout := fs.Duration("timeout", 2*time.Minute, "Overall time limit; loading a model from disk can be slow")
 	fs.Parse(args)
 
-	if *days < 0 {
-		exitJSON("error", "days must be non-negative")
+	if *timeout <= 0 {
+		exitJSON("error", "timeout must be positive")
 	}
 
-	ttl := time.Duration(*days) * 24 * time.Hour
-
-	s, ctx, cancel := connect()
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
 	defer cancel()
-	defer s.Close()
 
-	deleted, err := s.Forget(ctx, ttl)
-	if err != nil {
-		exitJSON("error", err.Error())
+	steps := map[string]any{}
+	failed := false
+	step := func(name string, fn func() error) {
+		start := time.Now()
+		err := fn()
+		result := map[string]any{
+			"ok":          err == nil,
+			"duration_ms": time.Since(start).Milliseconds(),
+		}
+		if err != nil {
+			result["error"] = err.Error()
+			failed = true
+		}
+		steps[name] = result
+	}
+
+	step(globalBackend, func() error {
+		s, err := newStore(store.PoolOptions{})
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+		return s.Ping(ctx)
+	})
+	// Redis is only needed up front when it holds the sync state.
+	if globalState == sync.StateRedis {
+		step("redis", func() error {
+			rc, err := redis.New(globalRedisHost, globalRedisPort)
+			if err != nil {
+				return err
+			}
+			defer rc.Close()
+			return rc.Ping()
+		})
 	}
+	step(globalEmbedder, func() error {
+		_, err := newEmbedder().Embed(ctx, globalModel, warmupText)
+		return err
+	})
 
+	status := "ok"
+	if failed {
+		status = "error"
+	}
 	outputJSON(map[string]any{
-		"status":  "ok",
-		"deleted": deleted,
-		"days":    *days,
+		"status": status,
+		"model":  globalModel,
+		"steps":  steps,
 	})
+	if failed {
+		os.Exit(1)
+	}
 }
 
-func runCheck() {
+// maxHistogramBuckets keeps score-histogram output readable.
+const maxHistogramBuckets = 100
+
+func runScoreHistogram(args []string) {
+	fs := flag.NewFlagSet("score-histogram", flag.ExitOnError)
+	query := fs.String("query", "", "Text to score every memory against")
+	vectorJSON := fs.String("vector", "", "Query embedding as JSON array (advanced, overrides text mode)")
+	buckets := fs.Int("buckets", 10, "Number of equal-width score buckets between 0 and 1")
+	var types multiFlag
+	fs.Var(&types, "type", "Only memories of this type; \"untyped\" matches memories without one (repeatable, ORed)")
+	fs.Parse(args)
+
+	if *query == "" && *vectorJSON == "" {
+		exitJSON("error", "--query is required (or --vector for advanced mode)")
+	}
+	if *buckets < 1 || *buckets > maxHistogramBuckets {
+		exitJSON("error", fmt.Sprintf("buckets must be between 1 and %d", maxHistogramBuckets))
+	}
+	// Score what search would consider, so the histogram explains its results.
+	filter := store.Filter{ExcludePersonal: globalShared, ExcludeArchived: true, ExcludeSuperseded: true}
+	if len(types) > 0 {
+		var err error
+		if filter.Types, err = store.TypeFilter(types, loadConfig().MemoryTypes()); err != nil {
+			exitError(err)
+		}
+	}
+	var vector []float32
+	if *vectorJSON != "" {
+		if err := json.Unmarshal([]byte(*vectorJSON), &vector); err != nil {
+			exitJSON("error", fmt.Sprintf("invalid vector JSON: %v", err))
+		}
+	}
+
 	s, ctx, cancel := connect()
 	defer cancel()
 	defer s.Close()
 
-	// Check Qdrant
-	if err := s.Check(ctx); err != nil {
-		exitJSON("error", fmt.Sprintf("qdrant: %v", err))
+	if vector == nil {
+		var err error
+		vector, err = newEmbedder().Embed(ctx, globalModel, *query)
+		if err != nil {
+			exitError(fmt.Errorf("embedding failed: %w", err))
+		}
 	}
-
-	// Check Ollama
-	oc := ollama.New(globalOllamaURL)
-	if err := oc.Health(ctx); err != nil {
-		exitJSON("error", fmt.Sprintf("ollama: %v", err))
+	guardEmbedding(ctx, s, vector, *vectorJSON == "")
+	total, err := s.Count(ctx)
+	if err != nil {
+		exitError(err)
+	}
+	var scores []float32
+	if total > 0 {
+		// Cosine similarity bottoms out at -1, so this threshold keeps
+		// every memory. Scoring doesn't count as recalling them.
+		results, err := s.FindSimilarFiltered(ctx, vector, -1, total, filter)
+		if err != nil {
+			exitError(err)
+		}
+		for _, r := range results {
+			scores = append(scores, r.Score)
+		}
 	}
 
 	outputJSON(map[string]any{
-		"status":  "ok",
-		"message": "Qdrant and Ollama verified",
+		"status":    "ok",
+		"query":     *query,
+		"histogram": ranking.Histogram(scores, *buckets),
+		"confidence_thresholds": map[string]float64{
+			"high":   highConfidence,
+			"medium": mediumConfidence,
+		},
 	})
 }
 
+// Top scores at which search reports high and medium confidence.
+const (
+	highConfidence   = 0.7
+	mediumConfidence = 0.4
+)
+
 // confidence returns a confidence label based on the top result score.
 // This helps agents quickly assess whether the results are trustworthy
 // without needing to interpret raw similarity scores.
@@ -692,37 +8814,264 @@ func confidence(results []store.Result) string {
 	}
 	top := results[0].Score
 	switch {
-	case top >= 0.7:
+	case top >= highConfidence:
 		return "high"
-	case top >= 0.4:
+	case top >= mediumConfidence:
 		return "medium"
 	default:
 		return "low"
 	}
 }
 
+// loadConfig reads the config file named by --config / CLAWBRAIN_CONFIG.
+// With no config file set it returns an empty config that enforces nothing.
+func loadConfig() *config.Config {
+	cfg, err := config.Load(globalConfig)
+	if err != nil {
+		exitError(err)
+	}
+	return cfg
+}
+
+// limitResponse applies the config's limits to a response about to be
+// output; see limits.Limits.Apply for fields.
+func limitResponse(response map[string]any, fields ...string) map[string]any {
+	limited, err := loadConfig().Limits.Apply(response, fields...)
+	if err != nil {
+		exitError(err)
+	}
+	return limited
+}
+
 // connect creates a store connection and a context with timeout.
 // The caller should defer both s.Close() and cancel().
 func connect() (*store.Store, context.Context, context.CancelFunc) {
-	s, err := store.New(globalHost, globalPort)
+	s, err := openStore()
 	if err != nil {
-		exitJSON("error", err.Error())
+		exitError(err)
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	checkSchema(ctx, s)
 	return s, ctx, cancel
 }
 
+// checkSchema refuses to go on against a collection written by a newer
+// clawbrain, and warns about one that needs clawbrain migrate. If the
+// schema can't be read, the command itself reports why Qdrant failed.
+func checkSchema(ctx context.Context, s *store.Store) {
+	sc, err := s.CheckSchema(ctx)
+	var se *store.SchemaError
+	if errors.As(err, &se) {
+		exitError(err)
+	}
+	if err == nil && len(sc.Pending) > 0 {
+		log.Printf("warning: collection is at schema version %d, this build uses %d; run clawbrain migrate", sc.Version, store.SchemaVersion)
+	}
+}
+
+// migrateTimeout bounds a migration run: backfills touch every memory.
+const migrateTimeout = 10 * time.Minute
+
+// runMigrate applies the schema migrations the collection is missing, in
+// order. They only add indexes and fields, so other clawbrain processes,
+// old or new, keep working while it runs.
+func runMigrate(args []string) {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "List the pending migrations without applying them")
+	fs.Parse(args)
+
+	s, err := openStore()
+	if err != nil {
+		exitError(err)
+	}
+	defer s.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), migrateTimeout)
+	defer cancel()
+
+	sc, err := s.CheckSchema(ctx)
+	if err != nil {
+		exitError(err)
+	}
+	if *dryRun {
+		outputJSON(map[string]any{
+			"status":            "ok",
+			"dry_run":           true,
+			"namespace":         globalNamespace,
+			"schema_version":    sc.Version,
+			"latest_version":    store.SchemaVersion,
+			"clawbrain_version": sc.Clawbrain,
+			"pending":           sc.Pending,
+		})
+		return
+	}
+
+	applied, err := s.Migrate(ctx)
+	if err != nil {
+		outputJSON(map[string]any{
+			"status":  "error",
+			"message": err.Error(),
+			"applied": applied,
+		})
+		os.Exit(1)
+	}
+	to := sc.Version
+	if len(applied) > 0 {
+		to = applied[len(applied)-1].Version
+	}
+	outputJSON(map[string]any{
+		"status":         "ok",
+		"namespace":      globalNamespace,
+		"from_version":   sc.Version,
+		"schema_version": to,
+		"applied":        applied,
+	})
+}
+
+// openStore connects to the vector store chosen with --backend, pointed at
+// --namespace's collection and scoped to --agent when they are set.
+func openStore() (*store.Store, error) {
+	return openPooledStore(store.PoolOptions{})
+}
+
+// openPooledStore is openStore with the given connection pool, for
+// long-running commands serving many callers.
+func openPooledStore(pool store.PoolOptions) (*store.Store, error) {
+	s, err := newStore(pool)
+	if err != nil {
+		return nil, err
+	}
+	if globalTiming {
+		s.TimeCalls(&storeTimer)
+	}
+	s.SetNamespace(globalNamespace)
+	if globalSandbox != "" {
+		s.SetSandbox(globalSandbox)
+	}
+	s.SetAgent(globalAgent)
+	s.SetClientVersion(version)
+	s.SetModel(globalModel)
+	return s, nil
+}
+
+// sqliteFile is the name of the SQLite backend's database in --path.
+const sqliteFile = "clawbrain.db"
+
+// newStore opens the backend chosen with --backend: Qdrant at --host and
+// --port, failing over to --replicas if any, or the file store or SQLite
+// database in --path. The pool only applies to Qdrant.
+func newStore(pool store.PoolOptions) (*store.Store, error) {
+	switch globalBackend {
+	case store.BackendFile:
+		return store.NewFile(expandHome(globalPath))
+	case store.BackendSQLite:
+		return store.NewSQLite(filepath.Join(expandHome(globalPath), sqliteFile))
+	}
+	if globalReplicas == "" {
+		return store.NewWithPool(globalHost, globalPort, pool)
+	}
+	replicas, err := store.ParseEndpoints(globalReplicas, globalPort)
+	if err != nil {
+		return nil, err
+	}
+	endpoints := append([]store.Endpoint{{Host: globalHost, Port: globalPort}}, replicas...)
+	return store.NewReplicated(endpoints, pool, globalWrites, store.DefaultRecheck)
+}
+
+// expandHome replaces a leading ~ in path with the home directory, for
+// defaults and env values the shell didn't expand.
+func expandHome(path string) string {
+	rest, ok := strings.CutPrefix(path, "~")
+	if !ok || (rest != "" && rest[0] != '/' && rest[0] != filepath.Separator) {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return home + rest
+}
+
+// setEmbeddedBy records in payload which provider of the embedder chain
+// (--embedder-fallbacks) embedded its new vector, or drops the record of
+// an earlier one when source is empty, as without a chain.
+func setEmbeddedBy(payload map[string]any, source string) {
+	if source == "" {
+		delete(payload, store.EmbeddedByField)
+		return
+	}
+	payload[store.EmbeddedByField] = source
+}
+
+// checkEmbedding returns an error if the vector doesn't fit the collection.
+// embedded says clawbrain made it with --model, rather than the caller
+// bringing it, so the model is checked too.
+func checkEmbedding(ctx context.Context, s *store.Store, vector []float32, embedded bool) error {
+	model := ""
+	if embedded {
+		model = globalModel
+	}
+	return s.CheckEmbedding(ctx, model, len(vector))
+}
+
+// guardEmbedding is checkEmbedding for commands: it exits on a mismatch,
+// before the vector reaches Qdrant.
+func guardEmbedding(ctx context.Context, s *store.Store, vector []float32, embedded bool) {
+	if err := checkEmbedding(ctx, s, vector, embedded); err != nil {
+		exitError(err)
+	}
+}
+
 // outputJSON marshals the value and prints it to stdout.
 func outputJSON(v any) {
+	m, isMap := v.(map[string]any)
+	if isMap && globalRequestID != "" {
+		if _, set := m[reqid.Field]; !set {
+			m[reqid.Field] = globalRequestID
+		}
+	}
 	data, err := json.Marshal(v)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, `{"status":"error","message":"json marshal: %v"}`, err)
 		os.Exit(1)
 	}
+	if _, set := m["timing"]; isMap && globalTiming && !set {
+		m["timing"] = timing(len(data))
+		data, _ = json.Marshal(m)
+	}
 	fmt.Println(string(data))
 }
 
+// Process-wide usage counters behind --timing: newEmbedder meters its
+// embedders on embedUsage, openPooledStore times its stores on storeTimer.
+var (
+	startTime  = time.Now()
+	embedUsage embedder.Usage
+	storeTimer store.CallTimer
+)
+
+// timing reports what the command has spent so far: its running time,
+// embedding requests and the time they took, the texts and tokens they
+// embedded, calls to the store (Qdrant or the file or SQLite backend) and
+// the time those took, and the size of the response, without the report.
+// Times are in milliseconds; calls that overlap, as a batch's do, each
+// count in full.
+func timing(bytes int) map[string]any {
+	ms := func(d time.Duration) float64 {
+		return math.Round(float64(d)/float64(time.Microsecond)) / 1000
+	}
+	return map[string]any{
+		"total_ms":        ms(time.Since(startTime)),
+		"embed_ms":        ms(embedUsage.Elapsed()),
+		"embed_calls":     embedUsage.Calls(),
+		"texts_embedded":  embedUsage.Texts(),
+		"tokens_embedded": embedUsage.Tokens(),
+		"store_ms":        ms(storeTimer.Elapsed()),
+		"store_calls":     storeTimer.Calls(),
+		"bytes_returned":  bytes,
+	}
+}
+
 // exitJSON outputs an error as JSON and exits with code 1.
 func exitJSON(status string, message string) {
 	outputJSON(map[string]any{
@@ -731,3 +9080,15 @@ func exitJSON(status string, message string) {
 	})
 	os.Exit(1)
 }
+
+// exitError outputs err as a JSON error and exits with code 1. If err
+// means Qdrant or Ollama is overloaded, it reports {"status":"backoff"}
+// with a retry_after in seconds instead and exits with code 75
+// (EX_TEMPFAIL), so callers can wait and retry rather than give up.
+func exitError(err error) {
+	if be := backoff.Classify(err); be != nil {
+		outputJSON(be.Response())
+		os.Exit(75)
+	}
+	exitJSON("error", err.Error())
+}
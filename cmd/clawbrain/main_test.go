@@ -1,18 +1,31 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/hsk-coder/clawbrain/internal/store"
+	"golang.org/x/net/websocket"
 )
 
 // buildBinary builds the clawbrain CLI and returns the path to the binary.
@@ -127,153 +140,191 @@ func TestCLICheck(t *testing.T) {
 	}
 }
 
-func TestCLIAddMissingFlags(t *testing.T) {
+func TestCLIWarmup(t *testing.T) {
 	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+	skipIfNoRedis(t)
 
-	tests := []struct {
-		name string
-		args []string
-	}{
-		{"no flags", []string{"add"}},
-		{"missing text and vector", []string{"add", "--payload", "{}"}},
+	out, err := runCLI(t, binary, "--ollama-url", fakeOllama(t).URL, "warmup")
+	if err != nil {
+		t.Fatalf("warmup failed: %v\n%s", err, out)
 	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			_, err := runCLI(t, binary, tt.args...)
-			if err == nil {
-				t.Fatal("expected error for missing required flags")
-			}
-		})
+	result := parseJSON(t, out)
+	if result["status"] != "ok" {
+		t.Errorf("expected status ok, got %v", result)
+	}
+	steps, _ := result["steps"].(map[string]any)
+	for _, name := range []string{"qdrant", "redis", "ollama"} {
+		if step, _ := steps[name].(map[string]any); step["ok"] != true {
+			t.Errorf("expected %s step ok, got %v", name, steps[name])
+		}
 	}
 }
 
-func TestCLIAddVectorRejectsEmptyPayload(t *testing.T) {
+func TestCLIWarmupReportsFailedStep(t *testing.T) {
 	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
 
-	tests := []struct {
-		name    string
-		args    []string
-		wantMsg string
-	}{
-		{
-			"no payload flag",
-			[]string{"add", "--vector", "[0.1, 0.2, 0.3, 0.4]"},
-			"payload must contain a non-empty \"text\" field",
-		},
-		{
-			"empty payload object",
-			[]string{"add", "--vector", "[0.1, 0.2, 0.3, 0.4]", "--payload", "{}"},
-			"payload must contain a non-empty \"text\" field",
-		},
-		{
-			"payload with empty text",
-			[]string{"add", "--vector", "[0.1, 0.2, 0.3, 0.4]", "--payload", `{"text": ""}`},
-			"payload must contain a non-empty \"text\" field",
-		},
-		{
-			"payload with null text",
-			[]string{"add", "--vector", "[0.1, 0.2, 0.3, 0.4]", "--payload", `{"text": null}`},
-			"payload must contain a non-empty \"text\" field",
-		},
-		{
-			"payload with other fields but no text",
-			[]string{"add", "--vector", "[0.1, 0.2, 0.3, 0.4]", "--payload", `{"source": "test"}`},
-			"payload must contain a non-empty \"text\" field",
-		},
+	out, err := runCLI(t, binary, "--ollama-url", "http://127.0.0.1:1", "warmup", "--timeout", "5s")
+	if err == nil {
+		t.Fatalf("expected warmup to fail without Ollama\n%s", out)
 	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			out, err := runCLI(t, binary, tt.args...)
-			if err == nil {
-				t.Fatal("expected error for missing text in payload")
-			}
-			result := parseJSON(t, out)
-			if result["status"] != "error" {
-				t.Errorf("expected status error, got %v", result["status"])
-			}
-			if msg, ok := result["message"].(string); !ok || msg != tt.wantMsg {
-				t.Errorf("expected message %q, got %v", tt.wantMsg, result["message"])
-			}
-		})
+	result := parseJSON(t, out)
+	if result["status"] != "error" {
+		t.Errorf("expected status error, got %v", result)
+	}
+	steps, _ := result["steps"].(map[string]any)
+	ollamaStep, _ := steps["ollama"].(map[string]any)
+	if ollamaStep["ok"] != false || ollamaStep["error"] == nil {
+		t.Errorf("expected failed ollama step with an error, got %v", steps["ollama"])
+	}
+	if qdrantStep, _ := steps["qdrant"].(map[string]any); qdrantStep["ok"] != true {
+		t.Errorf("expected qdrant step to still run and pass, got %v", steps["qdrant"])
 	}
 }
 
-func TestCLIAddAndSearch(t *testing.T) {
+func TestCLIVersion(t *testing.T) {
 	binary := buildBinary(t)
-	skipIfNoQdrant(t, binary)
+	dir := t.TempDir()
 
-	// Cleanup at the end
-	defer cleanupMemories(t)
+	out, err := runCLI(t, binary, "version")
+	if err != nil {
+		t.Fatalf("version failed: %v\n%s", err, out)
+	}
+	result := parseJSON(t, out)
+	if result["version"] != "dev" || result["build"] != nil {
+		t.Errorf("expected only the version without --verbose, got %v", result)
+	}
 
-	// Add a memory
-	out, err := runCLI(t, binary, "add",
-		"--vector", "[0.1, 0.2, 0.3, 0.4]",
-		"--payload", `{"text": "cli test memory"}`,
-	)
+	out, err = runCLI(t, binary, "--backend", "file", "--path", dir, "--ollama-url", fakeOllama(t).URL,
+		"--redis-port", "1", "version", "--verbose", "--timeout", "5s")
 	if err != nil {
-		t.Fatalf("add failed: %v\n%s", err, out)
+		t.Fatalf("version --verbose failed: %v\n%s", err, out)
+	}
+	result = parseJSON(t, out)
+	if build, _ := result["build"].(map[string]any); build["go"] == nil {
+		t.Errorf("expected the Go version in build, got %v", result["build"])
+	}
+	services, _ := result["services"].(map[string]any)
+	if ollama, _ := services["ollama"].(map[string]any); ollama["ok"] != true || ollama["version"] != "0.9.0" {
+		t.Errorf("expected the Ollama version, got %v", services["ollama"])
+	}
+	if file, _ := services["file"].(map[string]any); file["ok"] != true {
+		t.Errorf("expected the file backend ok, got %v", services["file"])
 	}
+	if redis, _ := services["redis"].(map[string]any); redis["ok"] != false || redis["error"] == nil {
+		t.Errorf("expected an unreachable Redis reported with its error, got %v", services["redis"])
+	}
+	features, _ := result["features"].([]any)
+	if !slices.Contains(features, any("cold-tier")) {
+		t.Errorf("expected features to list cold-tier, got %v", features)
+	}
+	if schema, _ := result["schema"].(map[string]any); schema["latest_version"] == nil {
+		t.Errorf("expected the schema version, got %v", result["schema"])
+	}
+}
 
-	addResult := parseJSON(t, out)
-	if addResult["status"] != "ok" {
-		t.Fatalf("expected status ok, got %v", addResult["status"])
+func TestCLIRequestID(t *testing.T) {
+	binary := buildBinary(t)
+
+	var header atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header.Store(r.Header.Get("X-Request-Id"))
+		http.Error(w, "model not found", http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	out, err := runCLI(t, binary, "--ollama-url", srv.URL, "--request-id", "agent-7:turn.12", "embed", "--text", "hello")
+	if err == nil {
+		t.Fatalf("expected embed to fail against the failing server\n%s", out)
 	}
-	if addResult["id"] == nil || addResult["id"] == "" {
-		t.Fatal("expected non-empty id")
+	result := parseJSON(t, out)
+	if result["status"] != "error" || result["request_id"] != "agent-7:turn.12" {
+		t.Errorf("expected the error to carry the request ID, got %v", result)
+	}
+	if header.Load() != "agent-7:turn.12" {
+		t.Errorf("expected the request ID sent to Ollama, got %v", header.Load())
 	}
 
-	// Search for it
-	out, err = runCLI(t, binary, "search",
-		"--vector", "[0.1, 0.2, 0.3, 0.4]",
-		"--min-score", "0.9",
-	)
+	out, err = runCLI(t, binary, "version")
 	if err != nil {
-		t.Fatalf("search failed: %v\n%s", err, out)
+		t.Fatalf("version failed: %v\n%s", err, out)
 	}
-
-	searchResult := parseJSON(t, out)
-	if searchResult["status"] != "ok" {
-		t.Fatalf("expected status ok, got %v", searchResult["status"])
+	if id, _ := parseJSON(t, out)["request_id"].(string); len(id) != 16 {
+		t.Errorf("expected a generated request ID, got %q", id)
 	}
-	returned, ok := searchResult["returned"].(float64)
-	if !ok || returned < 1 {
-		t.Fatalf("expected at least 1 result, got %v", searchResult["returned"])
+
+	if out, err := runCLI(t, binary, "--request-id", "two words", "version"); err == nil {
+		t.Errorf("expected an invalid request ID to be rejected\n%s", out)
 	}
 }
 
-func TestCLIAddWithCustomID(t *testing.T) {
+func TestCLITiming(t *testing.T) {
 	binary := buildBinary(t)
-	skipIfNoQdrant(t, binary)
-
-	defer cleanupMemories(t)
-
-	customID := "12345678-1234-1234-1234-123456789abc"
-	out, err := runCLI(t, binary, "add",
-		"--vector", "[0.5, 0.5, 0.5, 0.5]",
-		"--payload", `{"text": "custom id"}`,
-		"--id", customID,
-	)
+	words := wordsOllama(t)
+	// Embeds like wordsOllama and reports a token a word, as Ollama does.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct{ Input any }
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &req)
+		resp, err := http.Post(words.URL+r.URL.Path, "application/json", bytes.NewReader(body))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		var reply map[string]any
+		json.NewDecoder(resp.Body).Decode(&reply)
+		reply["prompt_eval_count"] = len(strings.Fields(fmt.Sprint(req.Input)))
+		json.NewEncoder(w).Encode(reply)
+	}))
+	defer srv.Close()
+	global := []string{"--backend", "file", "--path", t.TempDir(), "--ollama-url", srv.URL}
+
+	out, err := runCLI(t, binary, append(global, "add", "--text", "deploys go out on tuesdays")...)
 	if err != nil {
 		t.Fatalf("add failed: %v\n%s", err, out)
 	}
+	if _, ok := parseJSON(t, out)["timing"]; ok {
+		t.Errorf("expected no timing without --timing\n%s", out)
+	}
 
+	out, err = runCLI(t, binary, append([]string{"--timing"}, append(global, "search", "--query", "when do deploys go out")...)...)
+	if err != nil {
+		t.Fatalf("search failed: %v\n%s", err, out)
+	}
 	result := parseJSON(t, out)
-	if result["id"] != customID {
-		t.Errorf("expected id %q, got %v", customID, result["id"])
+	timing, ok := result["timing"].(map[string]any)
+	if !ok {
+		t.Fatalf("expected a timing report\n%s", out)
+	}
+	if timing["embed_calls"] != float64(1) || timing["texts_embedded"] != float64(1) || timing["tokens_embedded"] != float64(5) {
+		t.Errorf("expected the query's one embedding of 5 tokens, got %v", timing)
+	}
+	if calls, _ := timing["store_calls"].(float64); calls == 0 {
+		t.Errorf("expected store calls counted, got %v", timing)
+	}
+	for _, field := range []string{"total_ms", "embed_ms", "store_ms"} {
+		if _, ok := timing[field].(float64); !ok {
+			t.Errorf("expected %s, got %v", field, timing)
+		}
+	}
+	delete(result, "timing")
+	data, _ := json.Marshal(result)
+	if timing["bytes_returned"] != float64(len(data)) {
+		t.Errorf("expected bytes_returned %d, the response without the report; got %v", len(data), timing["bytes_returned"])
 	}
 }
 
-func TestCLISearchMissingFlags(t *testing.T) {
+func TestCLIAddMissingFlags(t *testing.T) {
 	binary := buildBinary(t)
 
 	tests := []struct {
 		name string
 		args []string
 	}{
-		{"no flags", []string{"search"}},
-		{"missing query and vector", []string{"search", "--limit", "5"}},
+		{"no flags", []string{"add"}},
+		{"missing text and vector", []string{"add", "--payload", "{}"}},
 	}
 
 	for _, tt := range tests {
@@ -286,1556 +337,7451 @@ func TestCLISearchMissingFlags(t *testing.T) {
 	}
 }
 
-func TestCLIDelete(t *testing.T) {
+func TestCLIEmbed(t *testing.T) {
 	binary := buildBinary(t)
-	skipIfNoQdrant(t, binary)
-
-	defer cleanupMemories(t)
+	ollamaURL := fakeOllama(t).URL
 
-	// Add a memory
-	out, err := runCLI(t, binary, "add",
-		"--vector", "[0.1, 0.2, 0.3, 0.4]",
-		"--payload", `{"text": "will be deleted"}`,
-	)
+	out, err := runCLI(t, binary, "--ollama-url", ollamaURL, "embed", "--text", "deploys go out on tuesdays")
 	if err != nil {
-		t.Fatalf("add failed: %v\n%s", err, out)
+		t.Fatalf("embed failed: %v\n%s", err, out)
+	}
+	result := parseJSON(t, out)
+	if vector, _ := result["vector"].([]any); len(vector) != 4 || result["dimensions"] != float64(4) {
+		t.Errorf("expected a 4-dim vector, got %s", out)
 	}
 
-	// Delete with 0 days — should delete everything
-	out, err = runCLI(t, binary, "delete",
-		"-d", "0",
-	)
+	out, err = runCLI(t, binary, "--ollama-url", ollamaURL, "embed", "--text", "one", "--text", "two")
 	if err != nil {
-		t.Fatalf("delete failed: %v\n%s", err, out)
+		t.Fatalf("embed of two texts failed: %v\n%s", err, out)
 	}
-
-	result := parseJSON(t, out)
-	if result["status"] != "ok" {
-		t.Fatalf("expected status ok, got %v", result["status"])
+	embeddings, _ := parseJSON(t, out)["embeddings"].([]any)
+	if len(embeddings) != 2 || embeddings[1].(map[string]any)["text"] != "two" {
+		t.Errorf("expected an embedding per text in order, got %s", out)
 	}
-	deleted, ok := result["deleted"].(float64)
-	if !ok || deleted < 1 {
-		t.Fatalf("expected at least 1 deletion, got %v", result["deleted"])
+
+	// Empty texts are refused before calling Ollama.
+	out, err = runCLI(t, binary, "--ollama-url", ollamaURL, "embed", "--text", " ")
+	if err == nil || parseJSON(t, out)["status"] != "error" {
+		t.Errorf("expected an empty text to be rejected\n%s", out)
 	}
+}
 
-	// Verify empty via search
-	out, err = runCLI(t, binary, "search",
-		"--vector", "[0.1, 0.2, 0.3, 0.4]",
-		"--limit", "10",
-	)
+func TestCLIOpenAIEmbedder(t *testing.T) {
+	binary := buildBinary(t)
+	url := fakeOllama(t).URL
+
+	out, err := runCLI(t, binary, "--embedder", "openai", "--embedder-url", url+"/v1", "--api-key", "sk-test",
+		"--model", "text-embedding-3-small", "embed", "--text", "one", "--text", "two")
 	if err != nil {
-		t.Fatalf("search failed: %v\n%s", err, out)
+		t.Fatalf("embed failed: %v\n%s", err, out)
+	}
+	result := parseJSON(t, out)
+	embeddings, _ := result["embeddings"].([]any)
+	if len(embeddings) != 2 || result["dimensions"] != float64(3) || result["model"] != "text-embedding-3-small" {
+		t.Errorf("expected two 3-dim embeddings from the OpenAI API, got %s", out)
 	}
 
-	searchResult := parseJSON(t, out)
-	returned, _ := searchResult["returned"].(float64)
-	if returned != 0 {
-		t.Errorf("expected 0 results after delete, got %v", returned)
+	out, err = runCLI(t, binary, "--embedder", "openai", "--embedder-url", url, "--api-key", "wrong", "embed", "--text", "one")
+	if err == nil || !strings.Contains(parseJSON(t, out)["message"].(string), "401") {
+		t.Errorf("expected a rejected key to fail, got %s", out)
+	}
+
+	out, err = runCLI(t, binary, "--embedder", "cohere", "embed", "--text", "one")
+	if err == nil || !strings.Contains(parseJSON(t, out)["message"].(string), "unknown embedder") {
+		t.Errorf("expected an unknown embedder to be rejected, got %s", out)
 	}
 }
 
-func TestCLIAddSearchPreservesPayload(t *testing.T) {
+func TestCLIFileBackend(t *testing.T) {
 	binary := buildBinary(t)
-	skipIfNoQdrant(t, binary)
+	url := fakeOllama(t).URL
+	file := []string{"--backend", "file", "--path", t.TempDir(), "--ollama-url", url}
 
-	defer cleanupMemories(t)
-
-	// Add with rich payload
-	out, err := runCLI(t, binary, "add",
-		"--vector", "[0.1, 0.2, 0.3, 0.4]",
-		"--payload", `{"text": "preserved", "count": 42, "active": true}`,
-	)
+	out, err := runCLI(t, binary, append(file, "add", "--no-merge", "--text", "deploys go out on tuesdays", "--tag", "ops")...)
 	if err != nil {
 		t.Fatalf("add failed: %v\n%s", err, out)
 	}
+	id := parseJSON(t, out)["id"]
 
-	// Search and check payload fields
-	out, err = runCLI(t, binary, "search",
-		"--vector", "[0.1, 0.2, 0.3, 0.4]",
-		"--min-score", "0.9",
-	)
+	out, err = runCLI(t, binary, append(file, "search", "--query", "when do deploys go out", "--tag", "ops")...)
 	if err != nil {
 		t.Fatalf("search failed: %v\n%s", err, out)
 	}
-
-	result := parseJSON(t, out)
-	results := result["results"].([]any)
-	if len(results) == 0 {
-		t.Fatal("expected at least 1 result")
+	results, _ := parseJSON(t, out)["results"].([]any)
+	if len(results) != 1 || results[0].(map[string]any)["id"] != id {
+		t.Errorf("expected the memory from the file store, got %s", out)
 	}
 
-	payload := results[0].(map[string]any)["payload"].(map[string]any)
-
-	if payload["text"] != "preserved" {
-		t.Errorf("expected text 'preserved', got %v", payload["text"])
-	}
-	if payload["count"] != float64(42) { // JSON numbers are float64
-		t.Errorf("expected count 42, got %v", payload["count"])
-	}
-	if payload["active"] != true {
-		t.Errorf("expected active true, got %v", payload["active"])
-	}
-	// Verify auto-injected timestamps exist
-	if payload["created_at"] == nil {
-		t.Error("missing created_at in payload")
+	out, err = runCLI(t, binary, append(file, "tags")...)
+	if err != nil || !strings.Contains(string(out), `"tag":"ops"`) {
+		t.Errorf("expected the ops tag counted, got %v\n%s", err, out)
 	}
-	if payload["last_accessed"] == nil {
-		t.Error("missing last_accessed in payload")
+
+	out, err = runCLI(t, binary, "--backend", "postgres", "tags")
+	if err == nil || !strings.Contains(parseJSON(t, out)["message"].(string), "unknown backend") {
+		t.Errorf("expected an unknown backend to be rejected, got %s", out)
 	}
 }
 
-func TestCLIDeleteInvalidDays(t *testing.T) {
+func TestCLISQLiteBackend(t *testing.T) {
 	binary := buildBinary(t)
+	dir := t.TempDir()
+	sqlite := []string{"--backend", "sqlite", "--path", dir, "--ollama-url", fakeOllama(t).URL}
 
-	_, err := runCLI(t, binary, "delete",
-		"-d", "not-a-number",
-	)
-	if err == nil {
-		t.Fatal("expected error for invalid -d value")
+	out, err := runCLI(t, binary, append(sqlite, "add", "--no-merge", "--text", "deploys go out on tuesdays", "--tag", "ops")...)
+	if err != nil {
+		t.Fatalf("add failed: %v\n%s", err, out)
 	}
-}
+	id := parseJSON(t, out)["id"]
 
-func TestCLIInvalidVectorJSON(t *testing.T) {
-	binary := buildBinary(t)
-
-	out, err := runCLI(t, binary, "add",
-		"--vector", "not json",
-		"--payload", "{}",
-	)
-	if err == nil {
-		t.Fatal("expected error for invalid vector JSON")
+	out, err = runCLI(t, binary, append(sqlite, "search", "--query", "when do deploys go out", "--tag", "ops")...)
+	if err != nil {
+		t.Fatalf("search failed: %v\n%s", err, out)
 	}
-	result := parseJSON(t, out)
-	if result["status"] != "error" {
-		t.Errorf("expected status error, got %v", result["status"])
+	results, _ := parseJSON(t, out)["results"].([]any)
+	if len(results) != 1 || results[0].(map[string]any)["id"] != id {
+		t.Errorf("expected the memory from the SQLite store, got %s", out)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "clawbrain.db")); err != nil {
+		t.Errorf("expected the database in --path: %v", err)
 	}
 }
 
-func TestCLIInvalidPayloadJSON(t *testing.T) {
+func TestCLISearchWidens(t *testing.T) {
 	binary := buildBinary(t)
+	file := []string{"--backend", "file", "--path", t.TempDir()}
+	for _, v := range []string{"[1, 0, 0, 0]", "[0, 1, 0, 0]", "[0, 0, 1, 0]"} {
+		out, err := runCLI(t, binary, append(file, "add", "--no-merge", "--vector", v, "--text", "memory "+v)...)
+		if err != nil {
+			t.Fatalf("add failed: %v\n%s", err, out)
+		}
+	}
 
-	out, err := runCLI(t, binary, "add",
-		"--vector", "[0.1]",
-		"--payload", "not json",
-	)
-	if err == nil {
-		t.Fatal("expected error for invalid payload JSON")
+	// Nothing is close to the query: the default limit of 1 is widened.
+	query := []string{"search", "--vector", "[0.3, 0.3, 0.3, 1]", "--min-score", "0.2"}
+	out, err := runCLI(t, binary, append(file, query...)...)
+	if err != nil {
+		t.Fatalf("search failed: %v\n%s", err, out)
 	}
 	result := parseJSON(t, out)
-	if result["status"] != "error" {
-		t.Errorf("expected status error, got %v", result["status"])
+	widened, _ := result["widened"].(map[string]any)
+	if result["returned"] != float64(3) || widened == nil || widened["limit"] != float64(5) || widened["from_confidence"] != "low" {
+		t.Errorf("expected a widened search returning every memory, got %s", out)
+	}
+	if widened["min_score"] != 0.1 {
+		t.Errorf("expected the min score halved, got %v", widened["min_score"])
 	}
-}
-
-// --- Get command tests ---
-
-func TestCLIGetMissingFlags(t *testing.T) {
-	binary := buildBinary(t)
 
-	tests := []struct {
-		name string
-		args []string
-	}{
-		{"no flags", []string{"get"}},
-		{"missing id", []string{"get"}},
+	for _, flag := range []string{"--no-widen", "--limit=1"} {
+		out, err = runCLI(t, binary, append(file, append(query, flag)...)...)
+		if err != nil {
+			t.Fatalf("search failed: %v\n%s", err, out)
+		}
+		result = parseJSON(t, out)
+		if _, ok := result["widened"]; ok || result["returned"] != float64(1) {
+			t.Errorf("%s: expected no widening, got %s", flag, out)
+		}
 	}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			_, err := runCLI(t, binary, tt.args...)
-			if err == nil {
-				t.Fatal("expected error for missing required flags")
-			}
-		})
+	// A confident match is left alone.
+	out, err = runCLI(t, binary, append(file, "search", "--vector", "[1, 0, 0, 0]")...)
+	if err != nil {
+		t.Fatalf("search failed: %v\n%s", err, out)
+	}
+	if result = parseJSON(t, out); result["widened"] != nil || result["confidence"] != "high" {
+		t.Errorf("expected a confident search not to widen, got %s", out)
 	}
 }
 
-func TestCLIGetByID(t *testing.T) {
+func TestCLIRankingProfile(t *testing.T) {
 	binary := buildBinary(t)
-	skipIfNoQdrant(t, binary)
-
-	defer cleanupMemories(t)
-
-	customID := "11111111-2222-3333-4444-555555555555"
+	file := []string{"--backend", "file", "--path", t.TempDir()}
+	var todoID string
+	for _, m := range []struct{ vector, typ string }{{"[1, 0, 0, 0]", "fact"}, {"[0.9, 0.3, 0, 0]", "todo"}} {
+		out, err := runCLI(t, binary, append(file, "add", "--no-merge", "--vector", m.vector, "--text", m.typ, "--type", m.typ)...)
+		if err != nil {
+			t.Fatalf("add failed: %v\n%s", err, out)
+		}
+		todoID, _ = parseJSON(t, out)["id"].(string)
+	}
+	top := func(args ...string) map[string]any {
+		t.Helper()
+		out, err := runCLI(t, binary, append(append(file, args...), "search", "--vector", "[1, 0, 0, 0]", "--limit", "1")...)
+		if err != nil {
+			t.Fatalf("search failed: %v\n%s", err, out)
+		}
+		return parseJSON(t, out)
+	}
 
-	// Add a memory with a known ID
-	out, err := runCLI(t, binary, "add",
-		"--vector", "[0.1, 0.2, 0.3, 0.4]",
-		"--payload", `{"text": "get me by id", "tag": "test"}`,
-		"--id", customID,
-	)
+	out, err := runCLI(t, binary, append(file, "ranking", "set", "--type-boost", "todo=0.2")...)
 	if err != nil {
-		t.Fatalf("add failed: %v\n%s", err, out)
+		t.Fatalf("ranking set failed: %v\n%s", err, out)
+	}
+	result := top()
+	results, _ := result["results"].([]any)
+	if len(results) != 1 || results[0].(map[string]any)["id"] != todoID || result["ranking_profile"] != "collection" {
+		t.Errorf("expected the boosted todo first under the collection profile, got %v", result)
+	}
+	if result = top("--agent", "alice"); result["ranking_profile"] != "collection" {
+		t.Errorf("expected an agent without a profile to use the collection's, got %v", result)
 	}
 
-	// Get by ID
-	out, err = runCLI(t, binary, "get",
-		"--id", customID,
-	)
+	out, err = runCLI(t, binary, append(file, "--agent", "alice", "ranking", "set", "--pinned-bonus", "0.1")...)
 	if err != nil {
-		t.Fatalf("get failed: %v\n%s", err, out)
+		t.Fatalf("ranking set failed: %v\n%s", err, out)
+	}
+	out, err = runCLI(t, binary, append(file, "--agent", "alice", "ranking", "show")...)
+	if err != nil || parseJSON(t, out)["applies"] != "alice" {
+		t.Errorf("expected alice's own profile to apply, got %v\n%s", err, out)
 	}
 
-	result := parseJSON(t, out)
-	if result["status"] != "ok" {
-		t.Fatalf("expected status ok, got %v", result["status"])
+	out, err = runCLI(t, binary, append(file, "ranking", "clear")...)
+	if err != nil || parseJSON(t, out)["cleared"] != true {
+		t.Fatalf("ranking clear failed: %v\n%s", err, out)
 	}
-	if result["id"] != customID {
-		t.Errorf("expected id %q, got %v", customID, result["id"])
+	if result = top(); result["ranking_profile"] != nil {
+		t.Errorf("expected no profile after clear, got %v", result)
 	}
 
-	payload, ok := result["payload"].(map[string]any)
-	if !ok {
-		t.Fatal("expected payload to be a map")
+	out, err = runCLI(t, binary, append(file, "ranking", "set", "--type-boost", "todo")...)
+	if err == nil {
+		t.Errorf("expected a malformed type boost to be rejected\n%s", out)
 	}
-	if payload["text"] != "get me by id" {
-		t.Errorf("expected text 'get me by id', got %v", payload["text"])
+}
+
+func TestCLISearchBoost(t *testing.T) {
+	binary := buildBinary(t)
+	file := []string{"--backend", "file", "--path", t.TempDir()}
+	var todoID string
+	for _, m := range []struct{ vector, typ string }{{"[1, 0, 0, 0]", "fact"}, {"[0.9, 0.3, 0, 0]", "todo"}} {
+		out, err := runCLI(t, binary, append(file, "add", "--no-merge", "--vector", m.vector, "--text", m.typ, "--type", m.typ)...)
+		if err != nil {
+			t.Fatalf("add failed: %v\n%s", err, out)
+		}
+		todoID, _ = parseJSON(t, out)["id"].(string)
 	}
-	if payload["tag"] != "test" {
-		t.Errorf("expected tag 'test', got %v", payload["tag"])
+	top := func(args ...string) any {
+		t.Helper()
+		out, err := runCLI(t, binary, append(file, append([]string{"search", "--vector", "[1, 0, 0, 0]", "--limit", "1"}, args...)...)...)
+		if err != nil {
+			t.Fatalf("search failed: %v\n%s", err, out)
+		}
+		results, _ := parseJSON(t, out)["results"].([]any)
+		if len(results) != 1 {
+			t.Fatalf("expected one result, got %s", out)
+		}
+		return results[0].(map[string]any)["id"]
 	}
-	if payload["created_at"] == nil {
-		t.Error("missing created_at in payload")
+
+	if top() == todoID {
+		t.Errorf("expected the closer fact first without a boost")
 	}
-	if payload["last_accessed"] == nil {
-		t.Error("missing last_accessed in payload")
+	if top("--boost", "type:todo=0.2") != todoID {
+		t.Errorf("expected --boost to lift the todo first")
+	}
+
+	config := filepath.Join(t.TempDir(), "clawbrain.json")
+	if err := os.WriteFile(config, []byte(`{"boosts": {"type:todo": 0.2}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	file = append(file, "--config", config)
+	if top() != todoID {
+		t.Errorf("expected the config's boost to lift the todo first")
+	}
+	if top("--boost", "type:todo=0") == todoID {
+		t.Errorf("expected --boost to override the config's boost for its type")
+	}
+
+	out, err := runCLI(t, binary, append(file, "search", "--vector", "[1, 0, 0, 0]", "--boost", "type:chore=0.1")...)
+	if err == nil || !strings.Contains(parseJSON(t, out)["message"].(string), "unknown type") {
+		t.Errorf("expected a boost for an unknown type to be rejected, got %s", out)
 	}
 }
 
-func TestCLIGetNotFound(t *testing.T) {
+func TestCLISearchMustContain(t *testing.T) {
 	binary := buildBinary(t)
-	skipIfNoQdrant(t, binary)
-
-	defer cleanupMemories(t)
+	file := []string{"--backend", "file", "--path", t.TempDir()}
+	var trackerID string
+	for _, m := range []struct{ vector, text string }{
+		{"[1, 0, 0, 0]", "clicks are counted twice"},
+		{"[0.9, 0.3, 0, 0]", "the tracker for each link is rebuilt nightly"},
+		{"[0.5, 0.5, 0, 0]", "Link-Tracker drops clicks from mobile"},
+	} {
+		out, err := runCLI(t, binary, append(file, "add", "--no-merge", "--vector", m.vector, "--text", m.text)...)
+		if err != nil {
+			t.Fatalf("add failed: %v\n%s", err, out)
+		}
+		trackerID, _ = parseJSON(t, out)["id"].(string)
+	}
 
-	// Add something so the collection exists
-	out, err := runCLI(t, binary, "add",
-		"--vector", "[0.1, 0.2, 0.3, 0.4]",
-		"--payload", `{"text": "placeholder"}`,
-	)
+	out, err := runCLI(t, binary, append(file, "search", "--vector", "[1, 0, 0, 0]", "--limit", "3", "--must-contain", "link-tracker")...)
 	if err != nil {
-		t.Fatalf("add failed: %v\n%s", err, out)
+		t.Fatalf("search failed: %v\n%s", err, out)
 	}
-
-	// Try to get a nonexistent ID
-	out, err = runCLI(t, binary, "get",
-		"--id", "00000000-0000-0000-0000-000000000000",
-	)
-	if err == nil {
-		t.Fatal("expected error for nonexistent memory")
+	results, _ := parseJSON(t, out)["results"].([]any)
+	if len(results) != 1 || results[0].(map[string]any)["id"] != trackerID {
+		t.Errorf("expected only the memory naming link-tracker, got %s", out)
 	}
 
-	result := parseJSON(t, out)
-	if result["status"] != "error" {
-		t.Errorf("expected status error, got %v", result["status"])
+	out, err = runCLI(t, binary, append(file, "search", "--vector", "[1, 0, 0, 0]", "--must-contain", " ")...)
+	if err == nil || !strings.Contains(parseJSON(t, out)["message"].(string), "must not be empty") {
+		t.Errorf("expected an empty phrase to be rejected, got %s", out)
 	}
 }
 
-// --- Text mode tests (require both Qdrant and Ollama) ---
-
-func TestCLITextAddAndSearch(t *testing.T) {
+func TestCLISearchSinceUntil(t *testing.T) {
 	binary := buildBinary(t)
-	skipIfNoQdrant(t, binary)
-	skipIfNoOllama(t)
+	file := []string{"--backend", "file", "--path", t.TempDir()}
+	add := func(text string) any {
+		t.Helper()
+		out, err := runCLI(t, binary, append(file, "add", "--no-merge", "--vector", "[1, 0, 0, 0]", "--text", text)...)
+		if err != nil {
+			t.Fatalf("add failed: %v\n%s", err, out)
+		}
+		return parseJSON(t, out)["id"]
+	}
+	search := func(args ...string) []any {
+		t.Helper()
+		out, err := runCLI(t, binary, append(append(file, "search", "--vector", "[1, 0, 0, 0]", "--limit", "5"), args...)...)
+		if err != nil {
+			t.Fatalf("search %v failed: %v\n%s", args, err, out)
+		}
+		results, _ := parseJSON(t, out)["results"].([]any)
+		return results
+	}
 
-	defer cleanupMemories(t)
+	oldID := add("the staging database was resized")
+	mid := time.Now().UTC().Format(time.RFC3339Nano)
+	time.Sleep(10 * time.Millisecond)
+	newID := add("the staging database was moved to a new region")
 
-	// Add a text memory (embedding happens via Ollama)
-	out, err := runCLI(t, binary, "add",
-		"--text", "the user prefers dark mode for coding",
-	)
-	if err != nil {
-		t.Fatalf("add text failed: %v\n%s", err, out)
+	if results := search("--since", mid); len(results) != 1 || results[0].(map[string]any)["id"] != newID {
+		t.Errorf("expected --since to keep the newer memory only, got %v", results)
 	}
-
-	addResult := parseJSON(t, out)
-	if addResult["status"] != "ok" {
-		t.Fatalf("expected status ok, got %v", addResult["status"])
+	if results := search("--until", mid); len(results) != 1 || results[0].(map[string]any)["id"] != oldID {
+		t.Errorf("expected --until to keep the older memory only, got %v", results)
 	}
-	if addResult["id"] == nil || addResult["id"] == "" {
-		t.Fatal("expected non-empty id")
+	if results := search("--since", "1h"); len(results) != 2 {
+		t.Errorf("expected both memories stored within the hour, got %v", results)
+	}
+	if results := search("--until", "2000-01-01"); len(results) != 0 {
+		t.Errorf("expected nothing stored before 2000, got %v", results)
 	}
 
-	// Search by text query (query is also embedded via Ollama)
-	out, err = runCLI(t, binary, "search",
-		"--query", "dark mode",
-		"--limit", "5",
-	)
-	if err != nil {
-		t.Fatalf("search text failed: %v\n%s", err, out)
+	for _, args := range [][]string{
+		{"--since", "yesterday"},
+		{"--since", "1h", "--until", "2h"},
+		{"--since", "1h", "--time-field", "updated_at"},
+	} {
+		out, err := runCLI(t, binary, append(append(file, "search", "--vector", "[1, 0, 0, 0]"), args...)...)
+		if err == nil || parseJSON(t, out)["status"] != "error" {
+			t.Errorf("expected %v to be rejected, got %s", args, out)
+		}
 	}
+}
 
-	searchResult := parseJSON(t, out)
-	if searchResult["status"] != "ok" {
-		t.Fatalf("expected status ok, got %v", searchResult["status"])
+func TestCLIRecent(t *testing.T) {
+	binary := buildBinary(t)
+	// No embedder runs: an unreachable one would fail the command.
+	file := []string{"--backend", "file", "--path", t.TempDir(), "--ollama-url", "http://127.0.0.1:1"}
+	run := func(args ...string) map[string]any {
+		t.Helper()
+		out, err := runCLI(t, binary, append(file, args...)...)
+		if err != nil {
+			t.Fatalf("%v failed: %v\n%s", args, err, out)
+		}
+		return parseJSON(t, out)
 	}
-	returned, ok := searchResult["returned"].(float64)
-	if !ok || returned < 1 {
-		t.Fatalf("expected at least 1 result, got %v", searchResult["returned"])
+	ids := func(res map[string]any) []any {
+		var out []any
+		for _, m := range res["memories"].([]any) {
+			out = append(out, m.(map[string]any)["id"])
+		}
+		return out
 	}
 
-	// Verify the text is in the payload and score is present
-	results := searchResult["results"].([]any)
-	firstResult := results[0].(map[string]any)
-	payload := firstResult["payload"].(map[string]any)
-	if payload["text"] != "the user prefers dark mode for coding" {
-		t.Errorf("expected text in payload, got %v", payload["text"])
+	var added []any
+	for _, m := range []struct{ vector, text, kind string }{
+		{"[1, 0, 0, 0]", "renew the TLS certificate", "todo"},
+		{"[0, 1, 0, 0]", "deploys go out on tuesdays", "fact"},
+		{"[0, 0, 1, 0]", "write the postmortem", "todo"},
+	} {
+		added = append(added, run("add", "--no-merge", "--vector", m.vector, "--text", m.text, "--type", m.kind)["id"])
 	}
-	if _, ok := firstResult["score"].(float64); !ok {
-		t.Error("expected score in result")
+	added = append(added, run("add", "--no-merge", "--vector", "[0, 0, 0, 1]", "--text", "deploys go out on thursdays", "--supersedes", added[1].(string))["id"])
+
+	if got := ids(run("recent", "--limit", "3")); !reflect.DeepEqual(got, []any{added[3], added[2], added[0]}) {
+		t.Errorf("expected the 3 newest memories not superseded, newest first, got %v", got)
+	}
+	if got := ids(run("recent", "--type", "todo")); !reflect.DeepEqual(got, []any{added[2], added[0]}) {
+		t.Errorf("expected the todos, newest first, got %v", got)
+	}
+	res := run("recent", "--limit", "1", "--since", "1h", "--select", "payload.text")
+	if memories := res["memories"].([]any); len(memories) != 1 || !reflect.DeepEqual(memories[0], map[string]any{"payload": map[string]any{"text": "deploys go out on thursdays"}}) {
+		t.Errorf("expected the selected text of the newest memory, got %v", res)
+	}
+	if out, err := runCLI(t, binary, append(file, "recent", "--limit", "0")...); err == nil {
+		t.Errorf("expected --limit 0 to be rejected, got %s", out)
 	}
 }
 
-func TestCLITextAddWithPayload(t *testing.T) {
+func TestCLITier(t *testing.T) {
 	binary := buildBinary(t)
-	skipIfNoQdrant(t, binary)
-	skipIfNoOllama(t)
+	file := []string{"--backend", "file", "--path", t.TempDir()}
+	run := func(args ...string) map[string]any {
+		t.Helper()
+		out, err := runCLI(t, binary, append(file, args...)...)
+		if err != nil {
+			t.Fatalf("%v failed: %v\n%s", args, err, out)
+		}
+		return parseJSON(t, out)
+	}
+	top := func(args ...string) any {
+		t.Helper()
+		res := run(append([]string{"search", "--vector", "[0, 1, 0, 0]", "--limit", "1", "--min-score", "0.9"}, args...)...)
+		if results, _ := res["results"].([]any); len(results) == 1 {
+			return results[0].(map[string]any)["id"]
+		}
+		return nil
+	}
 
-	defer cleanupMemories(t)
+	run("add", "--no-merge", "--vector", "[1, 0, 0, 0]", "--text", "deploys go out on tuesdays")
+	coldID := run("add", "--no-merge", "--vector", "[0, 1, 0, 0]", "--text", "the old office had a blue door")["id"]
+	time.Sleep(1100 * time.Millisecond)
+	run("search", "--vector", "[1, 0, 0, 0]", "--limit", "1")
 
-	// Add text with extra payload
-	out, err := runCLI(t, binary, "add",
-		"--text", "golang is great for cli tools",
-		"--payload", `{"source": "conversation", "confidence": 0.9}`,
-	)
-	if err != nil {
-		t.Fatalf("add text failed: %v\n%s", err, out)
+	if res := run("tier", "--older-than", "1s", "--dry-run"); res["would_move"] != 1.0 || res["cold"] != 0.0 {
+		t.Errorf("expected a dry run to list the unrecalled memory only, got %v", res)
+	}
+	if res := run("tier", "--older-than", "1s"); res["moved"] != 1.0 || res["hot"] != 1.0 || res["cold"] != 1.0 {
+		t.Fatalf("expected the unrecalled memory to move to the cold tier, got %v", res)
+	}
+	if id := top(); id != nil {
+		t.Errorf("expected a plain search to skip the cold tier, got %v", id)
+	}
+	if id := top("--include-cold"); id != coldID {
+		t.Errorf("expected --include-cold to find the cold memory, got %v", id)
+	}
+	if id := top(); id != coldID {
+		t.Errorf("expected the found memory to be back in the hot collection, got %v", id)
 	}
 
-	addResult := parseJSON(t, out)
-	if addResult["status"] != "ok" {
-		t.Fatalf("expected status ok, got %v", addResult["status"])
+	out, err := runCLI(t, binary, append(file, "tier", "--older-than", "0")...)
+	if err == nil || parseJSON(t, out)["status"] != "error" {
+		t.Errorf("expected a zero --older-than to be rejected, got %s", out)
 	}
+}
 
-	// Search and check payload
-	out, err = runCLI(t, binary, "search",
-		"--query", "golang",
-		"--limit", "5",
-	)
-	if err != nil {
-		t.Fatalf("search failed: %v\n%s", err, out)
+func TestCLITierLookupsByID(t *testing.T) {
+	binary := buildBinary(t)
+	file := []string{"--backend", "file", "--path", t.TempDir()}
+	run := func(args ...string) map[string]any {
+		t.Helper()
+		out, err := runCLI(t, binary, append(file, args...)...)
+		if err != nil {
+			t.Fatalf("%v failed: %v\n%s", args, err, out)
+		}
+		return parseJSON(t, out)
 	}
 
-	result := parseJSON(t, out)
-	results := result["results"].([]any)
-	if len(results) == 0 {
-		t.Fatal("expected at least 1 result")
+	lockID := run("add", "--no-merge", "--vector", "[1, 0, 0, 0]", "--text", "the api keys rotate monthly")["id"].(string)
+	updateID := run("add", "--no-merge", "--vector", "[0, 1, 0, 0]", "--text", "standup is at 09:30")["id"].(string)
+	oldID := run("add", "--no-merge", "--vector", "[0, 0, 1, 0]", "--text", "deploys go out on tuesdays")["id"].(string)
+	readID := run("add", "--no-merge", "--vector", "[0, 0, 0, 1]", "--text", "the old office had a blue door")["id"].(string)
+	time.Sleep(1100 * time.Millisecond)
+	if res := run("tier", "--older-than", "1s"); res["moved"] != 4.0 {
+		t.Fatalf("expected every memory to move to the cold tier, got %v", res)
 	}
 
-	payload := results[0].(map[string]any)["payload"].(map[string]any)
-	if payload["source"] != "conversation" {
-		t.Errorf("expected source 'conversation', got %v", payload["source"])
+	// Reading by ID finds cold memories where they are.
+	if res := run("similarity", "--a", readID, "--b", lockID); res["similarity"] != 0.0 {
+		t.Errorf("expected orthogonal cold memories to score 0, got %v", res)
 	}
-	if payload["text"] != "golang is great for cli tools" {
-		t.Errorf("expected text preserved, got %v", payload["text"])
+	if res := run("resource", "read", "clawbrain://memory/"+readID); res["memory"].(map[string]any)["id"] != readID {
+		t.Errorf("expected the resource to read the cold memory, got %v", res)
 	}
-	if payload["created_at"] == nil {
-		t.Error("missing created_at")
+
+	// Writing by ID moves the memory back first.
+	run("lock", "--id", lockID)
+	if res := run("update", "--id", updateID, "--payload", `{"room": "blue"}`); res["revision"] != 2.0 {
+		t.Errorf("expected the cold memory updated to revision 2, got %v", res)
 	}
-	if payload["last_accessed"] == nil {
-		t.Error("missing last_accessed")
+	newID := run("add", "--no-merge", "--vector", "[0, 0, 1, 0]", "--text", "deploys go out on thursdays", "--supersedes", oldID)["id"]
+	if res := run("tier", "--older-than", "1h", "--dry-run"); res["hot"] != 4.0 || res["cold"] != 1.0 {
+		t.Errorf("expected only the memory read to stay cold, got %v", res)
+	}
+
+	if payload := run("get", "--id", lockID)["payload"].(map[string]any); payload["locked"] != true {
+		t.Errorf("expected the memory locked, got %v", payload)
+	}
+	if payload := run("get", "--id", updateID)["payload"].(map[string]any); payload["room"] != "blue" {
+		t.Errorf("expected the memory updated, got %v", payload)
+	}
+	if payload := run("get", "--id", oldID)["payload"].(map[string]any); payload["superseded_by"] != newID {
+		t.Errorf("expected superseded_by %v, got %v", newID, payload)
 	}
 }
 
-func TestCLITextAddMissingText(t *testing.T) {
+func TestCLITierColdMemories(t *testing.T) {
 	binary := buildBinary(t)
+	file := []string{"--backend", "file", "--path", t.TempDir()}
+	run := func(args ...string) map[string]any {
+		t.Helper()
+		out, err := runCLI(t, binary, append(file, args...)...)
+		if err != nil {
+			t.Fatalf("%v failed: %v\n%s", args, err, out)
+		}
+		return parseJSON(t, out)
+	}
 
-	// No --text and no --vector should fail
-	_, err := runCLI(t, binary, "add")
-	if err == nil {
-		t.Fatal("expected error when neither --text nor --vector provided")
+	aliceID := run("add", "--no-merge", "--vector", "[1, 0, 0, 0]", "--text", "alice takes her coffee black")["id"].(string)
+	doorID := run("add", "--no-merge", "--vector", "[0, 1, 0, 0]", "--text", "the old office had a blue door")["id"].(string)
+	deployID := run("add", "--no-merge", "--vector", "[0, 0, 1, 0]", "--text", "deploys go out on tuesdays")["id"].(string)
+	time.Sleep(1100 * time.Millisecond)
+	if res := run("tier", "--older-than", "1s"); res["moved"] != 3.0 {
+		t.Fatalf("expected every memory to move to the cold tier, got %v", res)
 	}
-}
 
-func TestCLITextSearchMissingQuery(t *testing.T) {
-	binary := buildBinary(t)
+	export := filepath.Join(t.TempDir(), "export.jsonl")
+	if res := run("export", "--out", export); res["exported"] != 3.0 || res["cold"] != 3.0 {
+		t.Errorf("expected the cold memories exported, got %v", res)
+	}
 
-	// No --query and no --vector should fail
-	_, err := runCLI(t, binary, "search")
-	if err == nil {
-		t.Fatal("expected error when neither --query nor --vector provided")
+	// Fetching a cold memory recalls it, back into the hot collection.
+	if res := run("get", "--id", doorID); res["payload"].(map[string]any)["text"] != "the old office had a blue door" {
+		t.Errorf("expected get to find the cold memory, got %v", res)
+	}
+	res := run("search", "--vector", "[0, 1, 0, 0]", "--min-score", "0.9")
+	if results, _ := res["results"].([]any); len(results) != 1 || results[0].(map[string]any)["id"] != doorID {
+		t.Errorf("expected the fetched memory back in the hot collection, got %v", res)
 	}
-}
 
-func TestCLITextDelete(t *testing.T) {
-	binary := buildBinary(t)
-	skipIfNoQdrant(t, binary)
-	skipIfNoOllama(t)
-
-	defer cleanupMemories(t)
-
-	// Add a text memory
-	out, err := runCLI(t, binary, "add",
-		"--text", "this memory will be deleted",
-	)
-	if err != nil {
-		t.Fatalf("add failed: %v\n%s", err, out)
-	}
-
-	// Delete with 0 days — should delete everything
-	out, err = runCLI(t, binary, "delete",
-		"-d", "0",
-	)
-	if err != nil {
-		t.Fatalf("delete failed: %v\n%s", err, out)
-	}
-
-	result := parseJSON(t, out)
-	if result["status"] != "ok" {
-		t.Fatalf("expected status ok, got %v", result["status"])
-	}
-	deleted, ok := result["deleted"].(float64)
-	if !ok || deleted < 1 {
-		t.Fatalf("expected at least 1 deletion, got %v", result["deleted"])
+	res = run("purge", "--entity", "alice", "--no-embedding")
+	listed, _ := res["memories"].([]any)
+	if res["deleted"] != 1.0 || len(listed) != 1 || listed[0].(map[string]any)["id"] != aliceID || listed[0].(map[string]any)["cold"] != true {
+		t.Errorf("expected purge to delete the cold memory about the entity, got %v", res)
 	}
-
-	// Verify search returns nothing
-	out, err = runCLI(t, binary, "search",
-		"--query", "deleted",
-		"--limit", "10",
-	)
-	if err != nil {
-		t.Fatalf("search failed: %v\n%s", err, out)
+	if res := run("delete", "--id", deployID); res["deleted"] != 1.0 {
+		t.Errorf("expected delete --id to delete the cold memory, got %v", res)
 	}
-
-	searchResult := parseJSON(t, out)
-	returned, _ := searchResult["returned"].(float64)
-	if returned != 0 {
-		t.Errorf("expected 0 results after delete, got %v", returned)
+	if res := run("export", "--out", export); res["exported"] != 1.0 || res["cold"] != 0.0 {
+		t.Errorf("expected only the fetched memory left, got %v", res)
 	}
 }
 
-func TestCLITextAddWithCustomID(t *testing.T) {
+func TestCLISimilarity(t *testing.T) {
 	binary := buildBinary(t)
-	skipIfNoQdrant(t, binary)
-	skipIfNoOllama(t)
-
-	defer cleanupMemories(t)
+	ollamaURL := fakeOllama(t).URL
 
-	customID := "aabbccdd-1122-3344-5566-778899aabbcc"
-	out, err := runCLI(t, binary, "add",
-		"--text", "text with custom id",
-		"--id", customID,
-	)
+	// fakeOllama embeds every text the same, so any two texts are identical.
+	out, err := runCLI(t, binary, "--ollama-url", ollamaURL, "similarity",
+		"--a", "deploys go out on tuesdays", "--b", "deploys go out on thursdays", "--dedup-threshold", "0.95")
 	if err != nil {
-		t.Fatalf("add failed: %v\n%s", err, out)
+		t.Fatalf("similarity failed: %v\n%s", err, out)
 	}
-
 	result := parseJSON(t, out)
-	if result["id"] != customID {
-		t.Errorf("expected id %q, got %v", customID, result["id"])
+	if score, _ := result["similarity"].(float64); score < 0.999 || result["would_merge"] != true {
+		t.Errorf("expected identical embeddings to score 1 and merge, got %s", out)
+	}
+	if result["dedup_threshold"].(float64) < 0.949 {
+		t.Errorf("expected the --dedup-threshold to be reported, got %v", result["dedup_threshold"])
+	}
+
+	out, err = runCLI(t, binary, "--ollama-url", ollamaURL, "similarity", "--a", "only one side")
+	if err == nil {
+		t.Errorf("expected a missing --b to be rejected\n%s", out)
 	}
 }
 
-func TestCLITextSemanticSearch(t *testing.T) {
+func TestCLISimilarityMemories(t *testing.T) {
 	binary := buildBinary(t)
 	skipIfNoQdrant(t, binary)
-	skipIfNoOllama(t)
-
 	defer cleanupMemories(t)
 
-	// Add memories with distinct topics
-	memories := []string{
-		"the user prefers dark mode for coding at night",
-		"deploy the application to production every friday",
-		"use golang and qdrant for the memory system",
-	}
-	for _, m := range memories {
-		out, err := runCLI(t, binary, "add",
-			"--text", m,
-		)
+	var ids []string
+	for _, v := range []string{"[1, 0, 0, 0]", "[1, 1, 0, 0]"} {
+		out, err := runCLI(t, binary, "add", "--no-merge", "--vector", v, "--text", "memory "+v)
 		if err != nil {
 			t.Fatalf("add failed: %v\n%s", err, out)
 		}
+		ids = append(ids, parseJSON(t, out)["id"].(string))
 	}
 
-	// Search for something semantically related to dark mode
-	out, err := runCLI(t, binary, "search",
-		"--query", "night theme preferences",
-		"--limit", "3",
-	)
+	out, err := runCLI(t, binary, "similarity", "--a", ids[0], "--b", ids[1])
 	if err != nil {
-		t.Fatalf("search failed: %v\n%s", err, out)
+		t.Fatalf("similarity failed: %v\n%s", err, out)
 	}
-
 	result := parseJSON(t, out)
-	if result["status"] != "ok" {
-		t.Fatalf("expected status ok, got %v", result["status"])
+	if score := result["similarity"].(float64); score < 0.707 || score > 0.708 || result["would_merge"] != false {
+		t.Errorf("expected cos 45° (0.7071) below the threshold, got %s", out)
 	}
-
-	results := result["results"].([]any)
-	if len(results) == 0 {
-		t.Fatal("expected at least 1 result")
+	if a := result["a"].(map[string]any); a["id"] != ids[0] || a["text"] != "memory [1, 0, 0, 0]" {
+		t.Errorf("unexpected operand %v", a)
 	}
 
-	// The top result should be about dark mode (semantically closest to "night theme preferences")
-	topPayload := results[0].(map[string]any)["payload"].(map[string]any)
-	topText := topPayload["text"].(string)
-	if topText != "the user prefers dark mode for coding at night" {
-		t.Errorf("expected dark mode memory as top result for 'night theme preferences', got %q", topText)
+	out, err = runCLI(t, binary, "similarity", "--a", ids[0], "--b", "00000000-0000-0000-0000-000000000000")
+	if err == nil {
+		t.Errorf("expected a missing memory to be rejected\n%s", out)
 	}
 }
 
-func TestCLIGlobalOllamaFlags(t *testing.T) {
+func TestCLIAddVectorWithText(t *testing.T) {
 	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+	defer cleanupMemories(t)
 
-	// Test that --ollama-url and --model flags are accepted without error
-	// (they'll fail because of missing --text/--vector, but shouldn't fail on flag parsing)
-	_, err := runCLI(t, binary, "--ollama-url", "http://example.com:11434", "--model", "test-model", "add")
-	if err == nil {
-		t.Fatal("expected error for missing --text, not a pass")
+	// --text with --vector stores the text without calling Ollama.
+	out, err := runCLI(t, binary, "--ollama-url", "http://127.0.0.1:1", "add", "--no-merge",
+		"--vector", "[0.3, 0.1, 0.4, 0.1]", "--text", "precomputed elsewhere")
+	if err != nil {
+		t.Fatalf("add --vector --text failed: %v\n%s", err, out)
+	}
+	id := parseJSON(t, out)["id"].(string)
+
+	out, err = runCLI(t, binary, "get", "--id", id)
+	if err != nil {
+		t.Fatalf("get failed: %v\n%s", err, out)
+	}
+	if payload := parseJSON(t, out)["payload"].(map[string]any); payload["text"] != "precomputed elsewhere" {
+		t.Errorf("expected the --text to be stored, got %v", payload)
 	}
-	// The error should be about missing text/vector, not about unknown flags
 }
 
-func TestCLIGlobalHostFlag(t *testing.T) {
+func TestCLIAddVectorRejectsEmptyPayload(t *testing.T) {
 	binary := buildBinary(t)
-	skipIfNoQdrant(t, binary)
-	skipIfNoOllama(t)
 
-	// --host before command should work
-	out, err := runCLI(t, binary, "--host", "localhost", "check")
-	if err != nil {
-		t.Fatalf("check with --host failed: %v\n%s", err, out)
+	tests := []struct {
+		name    string
+		args    []string
+		wantMsg string
+	}{
+		{
+			"no payload flag",
+			[]string{"add", "--vector", "[0.1, 0.2, 0.3, 0.4]"},
+			"payload must contain a non-empty \"text\" field",
+		},
+		{
+			"empty payload object",
+			[]string{"add", "--vector", "[0.1, 0.2, 0.3, 0.4]", "--payload", "{}"},
+			"payload must contain a non-empty \"text\" field",
+		},
+		{
+			"payload with empty text",
+			[]string{"add", "--vector", "[0.1, 0.2, 0.3, 0.4]", "--payload", `{"text": ""}`},
+			"payload must contain a non-empty \"text\" field",
+		},
+		{
+			"payload with null text",
+			[]string{"add", "--vector", "[0.1, 0.2, 0.3, 0.4]", "--payload", `{"text": null}`},
+			"payload must contain a non-empty \"text\" field",
+		},
+		{
+			"payload with other fields but no text",
+			[]string{"add", "--vector", "[0.1, 0.2, 0.3, 0.4]", "--payload", `{"source": "test"}`},
+			"payload must contain a non-empty \"text\" field",
+		},
 	}
-	result := parseJSON(t, out)
-	if result["status"] != "ok" {
-		t.Fatalf("expected status ok, got %v", result["status"])
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := runCLI(t, binary, tt.args...)
+			if err == nil {
+				t.Fatal("expected error for missing text in payload")
+			}
+			result := parseJSON(t, out)
+			if result["status"] != "error" {
+				t.Errorf("expected status error, got %v", result["status"])
+			}
+			if msg, ok := result["message"].(string); !ok || msg != tt.wantMsg {
+				t.Errorf("expected message %q, got %v", tt.wantMsg, result["message"])
+			}
+		})
 	}
 }
 
-// --- Confidence field tests ---
-
-func TestCLIConfidenceFieldPresent(t *testing.T) {
+func TestCLIAddAndSearch(t *testing.T) {
 	binary := buildBinary(t)
 	skipIfNoQdrant(t, binary)
 
+	// Cleanup at the end
 	defer cleanupMemories(t)
 
 	// Add a memory
 	out, err := runCLI(t, binary, "add",
 		"--vector", "[0.1, 0.2, 0.3, 0.4]",
-		"--payload", `{"text": "confidence test"}`,
+		"--payload", `{"text": "cli test memory"}`,
 	)
 	if err != nil {
 		t.Fatalf("add failed: %v\n%s", err, out)
 	}
 
-	// Search — response should include confidence field
+	addResult := parseJSON(t, out)
+	if addResult["status"] != "ok" {
+		t.Fatalf("expected status ok, got %v", addResult["status"])
+	}
+	if addResult["id"] == nil || addResult["id"] == "" {
+		t.Fatal("expected non-empty id")
+	}
+
+	// Search for it
 	out, err = runCLI(t, binary, "search",
 		"--vector", "[0.1, 0.2, 0.3, 0.4]",
+		"--min-score", "0.9",
 	)
 	if err != nil {
 		t.Fatalf("search failed: %v\n%s", err, out)
 	}
 
-	result := parseJSON(t, out)
-	conf, ok := result["confidence"].(string)
-	if !ok {
-		t.Fatalf("expected confidence field as string, got %v (%T)", result["confidence"], result["confidence"])
+	searchResult := parseJSON(t, out)
+	if searchResult["status"] != "ok" {
+		t.Fatalf("expected status ok, got %v", searchResult["status"])
 	}
-	if conf != "high" && conf != "medium" && conf != "low" && conf != "none" {
-		t.Errorf("unexpected confidence value %q", conf)
+	returned, ok := searchResult["returned"].(float64)
+	if !ok || returned < 1 {
+		t.Fatalf("expected at least 1 result, got %v", searchResult["returned"])
 	}
 }
 
-func TestCLIConfidenceHigh(t *testing.T) {
+func TestCLIAddWithCustomID(t *testing.T) {
 	binary := buildBinary(t)
 	skipIfNoQdrant(t, binary)
 
 	defer cleanupMemories(t)
 
-	// Add and search with identical vector — score ~1.0, should be "high"
+	customID := "12345678-1234-1234-1234-123456789abc"
 	out, err := runCLI(t, binary, "add",
 		"--vector", "[0.5, 0.5, 0.5, 0.5]",
-		"--payload", `{"text": "exact match"}`,
+		"--payload", `{"text": "custom id"}`,
+		"--id", customID,
 	)
 	if err != nil {
 		t.Fatalf("add failed: %v\n%s", err, out)
 	}
 
-	out, err = runCLI(t, binary, "search",
-		"--vector", "[0.5, 0.5, 0.5, 0.5]",
-	)
-	if err != nil {
-		t.Fatalf("search failed: %v\n%s", err, out)
-	}
-
 	result := parseJSON(t, out)
-	if result["confidence"] != "high" {
-		t.Errorf("expected confidence 'high' for exact match, got %v", result["confidence"])
+	if result["id"] != customID {
+		t.Errorf("expected id %q, got %v", customID, result["id"])
 	}
 }
 
-func TestCLIConfidenceNone(t *testing.T) {
+func TestCLISearchMissingFlags(t *testing.T) {
 	binary := buildBinary(t)
-	skipIfNoQdrant(t, binary)
-
-	defer cleanupMemories(t)
 
-	// Add a memory then search with min-score so high nothing matches
-	out, err := runCLI(t, binary, "add",
-		"--vector", "[0.1, 0.2, 0.3, 0.4]",
-		"--payload", `{"text": "will not match"}`,
-	)
-	if err != nil {
-		t.Fatalf("add failed: %v\n%s", err, out)
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"no flags", []string{"search"}},
+		{"missing query and vector", []string{"search", "--limit", "5"}},
 	}
 
-	out, err = runCLI(t, binary, "search",
-		"--vector", "[0.9, -0.9, 0.9, -0.9]",
-		"--min-score", "0.99",
-	)
-	if err != nil {
-		t.Fatalf("search failed: %v\n%s", err, out)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := runCLI(t, binary, tt.args...)
+			if err == nil {
+				t.Fatal("expected error for missing required flags")
+			}
+		})
 	}
+}
 
-	result := parseJSON(t, out)
-	if result["confidence"] != "none" {
-		t.Errorf("expected confidence 'none' for empty results, got %v", result["confidence"])
-	}
-	returned, _ := result["returned"].(float64)
-	if returned != 0 {
-		t.Errorf("expected 0 results, got %v", returned)
+func TestCLISearchInvalidHalfLife(t *testing.T) {
+	binary := buildBinary(t)
+
+	for _, v := range []string{"soon", "-5d"} {
+		if _, err := runCLI(t, binary, "search", "--vector", "[0.1, 0.2, 0.3, 0.4]", "--half-life", v); err == nil {
+			t.Errorf("expected error for --half-life %s", v)
+		}
 	}
 }
 
-func TestCLIConfidenceLow(t *testing.T) {
+func TestCLISearchRejectsRecencyBoost(t *testing.T) {
 	binary := buildBinary(t)
-	skipIfNoQdrant(t, binary)
 
-	defer cleanupMemories(t)
-
-	// Add a memory with one vector, query with a very different one
-	// Cosine similarity of orthogonal-ish vectors should be low
-	out, err := runCLI(t, binary, "add",
-		"--vector", "[1.0, 0.0, 0.0, 0.0]",
-		"--payload", `{"text": "low confidence test"}`,
-	)
-	if err != nil {
-		t.Fatalf("add failed: %v\n%s", err, out)
-	}
-
-	// Query with a nearly orthogonal vector — score should be close to 0
-	out, err = runCLI(t, binary, "search",
-		"--vector", "[0.01, 1.0, 0.0, 0.0]",
-	)
-	if err != nil {
-		t.Fatalf("search failed: %v\n%s", err, out)
-	}
-
-	result := parseJSON(t, out)
-	conf := result["confidence"].(string)
-	if conf != "low" {
-		// Check the actual score to understand
-		results := result["results"].([]any)
-		if len(results) > 0 {
-			score := results[0].(map[string]any)["score"].(float64)
-			t.Errorf("expected confidence 'low' for near-orthogonal vectors, got %q (score: %.4f)", conf, score)
-		} else {
-			t.Errorf("expected confidence 'low', got %q", conf)
+	for _, args := range [][]string{
+		{"--recency-boost", "-0.1"},
+		{"--recency-scale", "3d"},
+		{"--recency-boost", "0.05", "--recency-scale", "0s"},
+		{"--recency-boost", "0.05", "--recency-scale", "soon"},
+	} {
+		args = append([]string{"search", "--vector", "[0.1, 0.2, 0.3, 0.4]"}, args...)
+		if _, err := runCLI(t, binary, args...); err == nil {
+			t.Errorf("expected error for %v", args[3:])
 		}
 	}
 }
 
-func TestCLIConfidenceWithTextQuery(t *testing.T) {
+func TestCLISearchRecencyBoost(t *testing.T) {
 	binary := buildBinary(t)
 	skipIfNoQdrant(t, binary)
-	skipIfNoOllama(t)
 
 	defer cleanupMemories(t)
 
-	// Add a text memory
-	out, err := runCLI(t, binary, "add",
-		"--text", "the cat sat on the mat",
-	)
-	if err != nil {
-		t.Fatalf("add failed: %v\n%s", err, out)
+	// Same vector, so similarity ties; only last_accessed tells them apart.
+	// Import keeps timestamps where add would stamp them.
+	const stale, fresh = "4f8a7c1e-2b3d-4e5f-8a9b-0c1d2e3f4a5b", "9e2a7c1e-2b3d-4e5f-8a9b-0c1d2e3f4a5b"
+	export := filepath.Join(t.TempDir(), "export.jsonl")
+	lines := `{"clawbrain_export":1,"model":"all-minilm","dimensions":4}
+{"id":"` + stale + `","vector":[0.1,0.2,0.3,0.4],"payload":{"text":"deploys go out on tuesdays","last_accessed":"2026-01-01T00:00:00Z"}}
+{"id":"` + fresh + `","vector":[0.1,0.2,0.3,0.4],"payload":{"text":"deploys go out on wednesdays","last_accessed":"` + time.Now().UTC().Format(time.RFC3339) + `"}}
+`
+	if err := os.WriteFile(export, []byte(lines), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := runCLI(t, binary, "import", "--in", export); err != nil {
+		t.Fatalf("import failed: %v\n%s", err, out)
 	}
 
-	// Query with semantically related text — should have confidence field
-	out, err = runCLI(t, binary, "search",
-		"--query", "cat sitting on a mat",
-		"--limit", "3",
-	)
+	out, err := runCLI(t, binary, "search", "--vector", "[0.1, 0.2, 0.3, 0.4]", "--recency-boost", "0.05")
 	if err != nil {
 		t.Fatalf("search failed: %v\n%s", err, out)
 	}
-
-	result := parseJSON(t, out)
-	conf, ok := result["confidence"].(string)
-	if !ok {
-		t.Fatalf("expected confidence field as string in text mode, got %v (%T)", result["confidence"], result["confidence"])
+	results := parseJSON(t, out)["results"].([]any)
+	if len(results) != 1 || results[0].(map[string]any)["id"] != fresh {
+		t.Errorf("expected the recently accessed memory first, got %v", results)
 	}
-	// For nearly identical text, confidence should be high
-	if conf != "high" {
-		results := result["results"].([]any)
-		if len(results) > 0 {
-			score := results[0].(map[string]any)["score"].(float64)
-			t.Logf("Note: confidence=%q, top score=%.4f", conf, score)
+}
+
+func TestCLISearchRejectsPaging(t *testing.T) {
+	binary := buildBinary(t)
+
+	for _, args := range [][]string{
+		{"--cursor", "not-a-cursor"},
+		{"--offset", "5", "--cursor", "bzE6NQ"},
+		{"--offset", "5", "--per-type-limit", "todo=2"},
+	} {
+		args = append([]string{"search", "--vector", "[0.1, 0.2, 0.3, 0.4]"}, args...)
+		if _, err := runCLI(t, binary, args...); err == nil {
+			t.Errorf("expected error for %v", args[3:])
 		}
 	}
 }
 
-func TestCLIPinnedMemorySurvivesDelete(t *testing.T) {
+func TestCLISearchPaging(t *testing.T) {
 	binary := buildBinary(t)
 	skipIfNoQdrant(t, binary)
-	skipIfNoOllama(t)
 
 	defer cleanupMemories(t)
 
-	// Add a pinned memory
-	out, err := runCLI(t, binary, "add",
-		"--text", "this memory is pinned and should survive",
-		"--pinned",
-	)
-	if err != nil {
-		t.Fatalf("add pinned failed: %v\n%s", err, out)
-	}
-
-	addResult := parseJSON(t, out)
-	if addResult["status"] != "ok" {
-		t.Fatalf("expected status ok, got %v", addResult["status"])
+	for _, vec := range []string{"[0.1, 0.2, 0.3, 0.4]", "[0.1, 0.2, 0.3, 0.5]", "[0.1, 0.2, 0.3, 0.6]"} {
+		if out, err := runCLI(t, binary, "add", "--no-merge", "--vector", vec, "--payload", `{"text": "page me"}`); err != nil {
+			t.Fatalf("add failed: %v\n%s", err, out)
+		}
 	}
-	pinnedID := addResult["id"].(string)
 
-	// Add an unpinned memory
-	out, err = runCLI(t, binary, "add",
-		"--text", "this memory is not pinned and should be deleted",
-	)
+	seen := map[any]bool{}
+	args := []string{"search", "--vector", "[0.1, 0.2, 0.3, 0.4]", "--limit", "2"}
+	out, err := runCLI(t, binary, args...)
 	if err != nil {
-		t.Fatalf("add unpinned failed: %v\n%s", err, out)
+		t.Fatalf("search failed: %v\n%s", err, out)
 	}
-
-	// Delete with 0 days — should delete only the unpinned one
-	out, err = runCLI(t, binary, "delete",
-		"-d", "0",
-	)
-	if err != nil {
-		t.Fatalf("delete failed: %v\n%s", err, out)
+	first := parseJSON(t, out)
+	cursor, ok := first["next_cursor"].(string)
+	if !ok || first["returned"] != float64(2) {
+		t.Fatalf("expected a full first page with a cursor, got %v", first)
 	}
-
-	deleteResult := parseJSON(t, out)
-	deleted, _ := deleteResult["deleted"].(float64)
-	if deleted != 1 {
-		t.Fatalf("expected 1 deletion (unpinned only), got %v", deleted)
+	for _, r := range first["results"].([]any) {
+		seen[r.(map[string]any)["id"]] = true
 	}
 
-	// Verify the pinned memory is still retrievable by ID
-	out, err = runCLI(t, binary, "get",
-		"--id", pinnedID,
-	)
+	out, err = runCLI(t, binary, append(args, "--cursor", cursor)...)
 	if err != nil {
-		t.Fatalf("get pinned failed: %v\n%s", err, out)
-	}
-
-	getResult := parseJSON(t, out)
-	if getResult["status"] != "ok" {
-		t.Fatalf("expected status ok, got %v", getResult["status"])
+		t.Fatalf("search failed: %v\n%s", err, out)
 	}
-	payload := getResult["payload"].(map[string]any)
-	if payload["text"] != "this memory is pinned and should survive" {
-		t.Errorf("expected pinned memory text, got %v", payload["text"])
+	second := parseJSON(t, out)
+	if second["returned"] != float64(1) || second["next_cursor"] != nil {
+		t.Fatalf("expected a last page of one without a cursor, got %v", second)
 	}
-	if payload["pinned"] != true {
-		t.Errorf("expected pinned=true in payload, got %v", payload["pinned"])
+	if id := second["results"].([]any)[0].(map[string]any)["id"]; seen[id] {
+		t.Errorf("second page repeated %v", id)
 	}
 }
 
-// --- Dedup-merge tests ---
+func TestCLISelectRejects(t *testing.T) {
+	binary := buildBinary(t)
 
-func TestCLIDedupMergeExactDuplicate(t *testing.T) {
+	for _, args := range [][]string{
+		{"search", "--vector", "[0.1, 0.2, 0.3, 0.4]", "--select", "id,"},
+		{"get", "--id", "4f8a7c1e-2b3d-4e5f-8a9b-0c1d2e3f4a5b", "--select", "payload..text"},
+	} {
+		if _, err := runCLI(t, binary, args...); err == nil {
+			t.Errorf("expected %v to be rejected", args)
+		}
+	}
+}
+
+func TestCLISelect(t *testing.T) {
 	binary := buildBinary(t)
 	skipIfNoQdrant(t, binary)
-	skipIfNoOllama(t)
 
 	defer cleanupMemories(t)
 
-	// Add the first memory
-	out, err := runCLI(t, binary, "add",
-		"--text", "lico is a Korean software engineer living in London",
-	)
+	out, err := runCLI(t, binary, "add", "--no-merge", "--vector", "[0.1, 0.2, 0.3, 0.4]",
+		"--payload", `{"text": "deploys go out on tuesdays", "source": "chat"}`)
 	if err != nil {
-		t.Fatalf("first add failed: %v\n%s", err, out)
-	}
-	first := parseJSON(t, out)
-	if first["status"] != "ok" {
-		t.Fatalf("expected status ok, got %v", first["status"])
+		t.Fatalf("add failed: %v\n%s", err, out)
 	}
-	firstID := first["id"].(string)
+	id := parseJSON(t, out)["id"].(string)
 
-	// Add the same text again — should trigger merge
-	out, err = runCLI(t, binary, "add",
-		"--text", "lico is a Korean software engineer living in London",
-	)
+	out, err = runCLI(t, binary, "search", "--vector", "[0.1, 0.2, 0.3, 0.4]", "--select", "id,payload.text")
 	if err != nil {
-		t.Fatalf("second add failed: %v\n%s", err, out)
+		t.Fatalf("search failed: %v\n%s", err, out)
 	}
-	second := parseJSON(t, out)
-	if second["status"] != "ok" {
-		t.Fatalf("expected status ok, got %v", second["status"])
+	result := parseJSON(t, out)
+	want := map[string]any{"id": id, "payload": map[string]any{"text": "deploys go out on tuesdays"}}
+	if results := result["results"].([]any); len(results) != 1 || fmt.Sprint(results[0]) != fmt.Sprint(want) {
+		t.Errorf("expected only id and payload.text, got %v", results)
 	}
-
-	// The old memory should have been merged (deleted + replaced)
-	if second["merged_id"] != firstID {
-		t.Errorf("expected merged_id %q, got %v", firstID, second["merged_id"])
+	if result["confidence"] == nil {
+		t.Errorf("expected the rest of the response kept, got %v", result)
 	}
 
-	// There should only be 1 memory total, not 2
-	out, err = runCLI(t, binary, "search",
-		"--query", "lico is a Korean software engineer living in London",
-		"--limit", "10",
-	)
+	out, err = runCLI(t, binary, "get", "--id", id, "--select", "payload.source")
 	if err != nil {
-		t.Fatalf("search failed: %v\n%s", err, out)
+		t.Fatalf("get failed: %v\n%s", err, out)
 	}
-	search := parseJSON(t, out)
-	returned := int(search["returned"].(float64))
-	if returned != 1 {
-		t.Fatalf("expected 1 memory after dedup merge, got %d", returned)
+	if got := parseJSON(t, out); fmt.Sprint(got) != fmt.Sprint(map[string]any{"status": "ok", "payload": map[string]any{"source": "chat"}}) {
+		t.Errorf("expected only payload.source, got %v", got)
 	}
 }
 
-func TestCLIDedupMergePreservesCreatedAt(t *testing.T) {
+func TestCLISearchHalfLife(t *testing.T) {
 	binary := buildBinary(t)
 	skipIfNoQdrant(t, binary)
-	skipIfNoOllama(t)
 
 	defer cleanupMemories(t)
 
-	// Add the first memory
-	out, err := runCLI(t, binary, "add",
-		"--text", "the sky is blue on a clear day",
+	// An exact match created long ago, and a slightly worse match created now.
+	out, err := runCLI(t, binary, "add", "--no-merge",
+		"--vector", "[0.1, 0.2, 0.3, 0.4]",
+		"--payload", `{"text": "old config", "created_at": "2024-01-01T00:00:00Z"}`,
 	)
 	if err != nil {
-		t.Fatalf("first add failed: %v\n%s", err, out)
+		t.Fatalf("add old failed: %v\n%s", err, out)
 	}
-	first := parseJSON(t, out)
-	firstID := first["id"].(string)
-
-	// Fetch the original created_at
-	out, err = runCLI(t, binary, "get", "--id", firstID)
+	out, err = runCLI(t, binary, "add", "--no-merge",
+		"--vector", "[0.1, 0.2, 0.3, 0.5]",
+		"--payload", `{"text": "new config"}`,
+	)
 	if err != nil {
-		t.Fatalf("get failed: %v\n%s", err, out)
+		t.Fatalf("add new failed: %v\n%s", err, out)
 	}
-	firstGet := parseJSON(t, out)
-	originalCreatedAt := firstGet["payload"].(map[string]any)["created_at"].(string)
 
-	time.Sleep(1100 * time.Millisecond)
-
-	// Add the same text again — should merge
-	out, err = runCLI(t, binary, "add",
-		"--text", "the sky is blue on a clear day",
-	)
-	if err != nil {
-		t.Fatalf("second add failed: %v\n%s", err, out)
+	top := func(args ...string) string {
+		t.Helper()
+		out, err := runCLI(t, binary, append([]string{"search", "--vector", "[0.1, 0.2, 0.3, 0.4]", "--limit", "1"}, args...)...)
+		if err != nil {
+			t.Fatalf("search failed: %v\n%s", err, out)
+		}
+		results, _ := parseJSON(t, out)["results"].([]any)
+		if len(results) != 1 {
+			t.Fatalf("expected 1 result, got %d\n%s", len(results), out)
+		}
+		return results[0].(map[string]any)["payload"].(map[string]any)["text"].(string)
 	}
-	second := parseJSON(t, out)
-	secondID := second["id"].(string)
 
-	// The new memory should have the original created_at preserved
-	out, err = runCLI(t, binary, "get", "--id", secondID)
-	if err != nil {
-		t.Fatalf("get merged failed: %v\n%s", err, out)
+	if got := top(); got != "old config" {
+		t.Errorf("without half-life expected the exact match first, got %q", got)
 	}
-	merged := parseJSON(t, out)
-	mergedCreatedAt := merged["payload"].(map[string]any)["created_at"].(string)
+	if got := top("--half-life", "30d"); got != "new config" {
+		t.Errorf("with half-life expected the fresh memory first, got %q", got)
+	}
+}
 
-	if mergedCreatedAt != originalCreatedAt {
-		t.Errorf("created_at not preserved: original=%s, merged=%s", originalCreatedAt, mergedCreatedAt)
+func TestCLISearchInvalidPerTypeLimit(t *testing.T) {
+	binary := buildBinary(t)
+
+	out, err := runCLI(t, binary, "search", "--vector", "[0.1, 0.2, 0.3, 0.4]", "--per-type-limit", "todo=zero")
+	if err == nil {
+		t.Fatal("expected error for invalid --per-type-limit")
+	}
+	if parseJSON(t, out)["status"] != "error" {
+		t.Errorf("expected status error\n%s", out)
 	}
 }
 
-func TestCLINoMergeFlag(t *testing.T) {
+func TestCLISearchPerTypeLimit(t *testing.T) {
 	binary := buildBinary(t)
 	skipIfNoQdrant(t, binary)
-	skipIfNoOllama(t)
 
 	defer cleanupMemories(t)
 
-	// Add the first memory
-	out, err := runCLI(t, binary, "add",
-		"--text", "water is composed of hydrogen and oxygen",
-	)
-	if err != nil {
-		t.Fatalf("first add failed: %v\n%s", err, out)
+	// Todos dominate similarity; the lesson and fact are further away.
+	add := func(vector, payload string) {
+		t.Helper()
+		if out, err := runCLI(t, binary, "add", "--no-merge", "--vector", vector, "--payload", payload); err != nil {
+			t.Fatalf("add failed: %v\n%s", err, out)
+		}
 	}
-	first := parseJSON(t, out)
-	if first["status"] != "ok" {
-		t.Fatalf("expected status ok, got %v", first["status"])
+	for i := 0; i < 4; i++ {
+		add("[0.1, 0.2, 0.3, 0.4]", fmt.Sprintf(`{"text": "todo %d", "type": "todo"}`, i))
 	}
+	add("[0.4, 0.3, 0.2, 0.1]", `{"text": "a lesson", "type": "lesson"}`)
+	add("[0.9, 0.1, 0.1, 0.1]", `{"text": "a fact", "type": "fact"}`)
+	add("[0.1, 0.2, 0.3, 0.5]", `{"text": "untyped note"}`)
 
-	// Add the same text with --no-merge — should NOT merge, creating a duplicate
-	out, err = runCLI(t, binary, "add",
-		"--text", "water is composed of hydrogen and oxygen",
-		"--no-merge",
-	)
+	out, err := runCLI(t, binary, "search", "--vector", "[0.1, 0.2, 0.3, 0.4]", "--per-type-limit", "todo=2,lesson=1")
 	if err != nil {
-		t.Fatalf("second add failed: %v\n%s", err, out)
+		t.Fatalf("search failed: %v\n%s", err, out)
 	}
-	second := parseJSON(t, out)
-	if second["status"] != "ok" {
-		t.Fatalf("expected status ok, got %v", second["status"])
+	result := parseJSON(t, out)
+	if result["returned"] != float64(3) {
+		t.Fatalf("expected 3 results (2 todo + 1 lesson), got %v\n%s", result["returned"], out)
 	}
-
-	// merged_id should NOT be present
-	if second["merged_id"] != nil {
-		t.Errorf("expected no merged_id with --no-merge, got %v", second["merged_id"])
+	byType, _ := result["by_type"].(map[string]any)
+	if byType["todo"] != float64(2) || byType["lesson"] != float64(1) || len(byType) != 2 {
+		t.Errorf("unexpected by_type: %v", byType)
 	}
 
-	// There should be 2 memories (duplicate allowed)
-	out, err = runCLI(t, binary, "search",
-		"--query", "water is composed of hydrogen and oxygen",
-		"--limit", "10",
-	)
+	// * picks up the unlisted types, untyped included.
+	out, err = runCLI(t, binary, "search", "--vector", "[0.1, 0.2, 0.3, 0.4]", "--per-type-limit", "todo=1,*=5")
 	if err != nil {
 		t.Fatalf("search failed: %v\n%s", err, out)
 	}
-	search := parseJSON(t, out)
-	returned := int(search["returned"].(float64))
-	if returned != 2 {
-		t.Fatalf("expected 2 memories with --no-merge, got %d", returned)
+	byType, _ = parseJSON(t, out)["by_type"].(map[string]any)
+	if byType["todo"] != float64(1) || byType["lesson"] != float64(1) || byType["fact"] != float64(1) || byType["untyped"] != float64(1) {
+		t.Errorf("unexpected by_type with *: %v", byType)
 	}
 }
 
-func TestCLIDedupMergeVectorMode(t *testing.T) {
+func TestCLISearchRouteFlags(t *testing.T) {
+	binary := buildBinary(t)
+
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"without query", []string{"search", "--route", "--vector", "[0.1, 0.2, 0.3, 0.4]"}},
+		{"with per-type-limit", []string{"search", "--route", "--query", "todos", "--per-type-limit", "todo=1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := runCLI(t, binary, tt.args...)
+			if err == nil {
+				t.Fatalf("expected error\n%s", out)
+			}
+			if parseJSON(t, out)["status"] != "error" {
+				t.Errorf("expected status error\n%s", out)
+			}
+		})
+	}
+}
+
+func TestCLISearchRoute(t *testing.T) {
 	binary := buildBinary(t)
 	skipIfNoQdrant(t, binary)
+	// The fake embeds every query as [0.3, 0.1, 0.4, 0.1].
+	ollamaURL := fakeOllama(t).URL
 
 	defer cleanupMemories(t)
 
-	vector := "[0.1,0.2,0.3,0.4]"
-	payload := `{"text":"vector mode dedup test"}`
+	add := func(vector, payload string) {
+		t.Helper()
+		if out, err := runCLI(t, binary, "add", "--no-merge", "--vector", vector, "--payload", payload); err != nil {
+			t.Fatalf("add failed: %v\n%s", err, out)
+		}
+	}
+	add("[0.3, 0.1, 0.4, 0.1]", `{"text": "the staging cluster runs on k8s"}`)
+	add("[0.1, 0.9, 0.1, 0.1]", `{"text": "rotate the TLS certs", "type": "todo"}`)
+	add("[0.1, 0.1, 0.1, 0.9]", `{"text": "Priya owns the billing service"}`)
+
+	search := func(query string) map[string]any {
+		t.Helper()
+		out, err := runCLI(t, binary, "--ollama-url", ollamaURL, "search", "--route", "--query", query, "--limit", "1")
+		if err != nil {
+			t.Fatalf("search failed: %v\n%s", err, out)
+		}
+		return parseJSON(t, out)
+	}
+	topText := func(result map[string]any) string {
+		results, _ := result["results"].([]any)
+		if len(results) == 0 {
+			return ""
+		}
+		return results[0].(map[string]any)["payload"].(map[string]any)["text"].(string)
+	}
+
+	todo := search("which tasks are still pending?")
+	if route, _ := todo["route"].(map[string]any); route["intent"] != "todo_status" {
+		t.Errorf("expected todo_status route, got %v", todo["route"])
+	}
+	if got := topText(todo); got != "rotate the TLS certs" {
+		t.Errorf("expected the todo despite lower similarity, got %q", got)
+	}
+
+	entity := search("what do we know about Priya")
+	if route, _ := entity["route"].(map[string]any); route["strategy"] != "keyword" {
+		t.Errorf("expected keyword strategy, got %v", entity["route"])
+	}
+	if got := topText(entity); got != "Priya owns the billing service" {
+		t.Errorf("expected the memory naming Priya, got %q", got)
+	}
+
+	// No memory mentions Zed, so the keyword route falls back to similarity.
+	fallback := search("what do we know about Zed")
+	if fallback["route_fallback"] != true {
+		t.Errorf("expected route_fallback, got %v", fallback)
+	}
+	if got := topText(fallback); got != "the staging cluster runs on k8s" {
+		t.Errorf("expected the nearest memory after fallback, got %q", got)
+	}
+}
+
+func TestCLISearchHybridFlags(t *testing.T) {
+	binary := buildBinary(t)
+
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"without query", []string{"search", "--hybrid", "--vector", "[0.1, 0.2, 0.3, 0.4]"}},
+		{"negative weight", []string{"search", "--query", "tls", "--keyword-weight", "-1"}},
+		{"weight without query", []string{"search", "--keyword-weight", "2", "--vector", "[0.1, 0.2, 0.3, 0.4]"}},
+		{"with per-type-limit", []string{"search", "--hybrid", "--query", "tls", "--per-type-limit", "todo=1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := runCLI(t, binary, tt.args...)
+			if err == nil {
+				t.Fatalf("expected error\n%s", out)
+			}
+			if parseJSON(t, out)["status"] != "error" {
+				t.Errorf("expected status error\n%s", out)
+			}
+		})
+	}
+}
+
+func TestCLISearchHybrid(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+	// The fake embeds every query as [0.3, 0.1, 0.4, 0.1].
+	ollamaURL := fakeOllama(t).URL
+
+	defer cleanupMemories(t)
+
+	add := func(vector, payload string) {
+		t.Helper()
+		if out, err := runCLI(t, binary, "add", "--no-merge", "--vector", vector, "--payload", payload); err != nil {
+			t.Fatalf("add failed: %v\n%s", err, out)
+		}
+	}
+	add("[0.3, 0.1, 0.4, 0.1]", `{"text": "the staging cluster runs on k8s"}`)
+	add("[0.1, 0.9, 0.1, 0.1]", `{"text": "todo: rotate the TLS certs"}`)
+	add("[0.1, 0.1, 0.1, 0.9]", `{"text": "Priya owns the billing service"}`)
+
+	search := func(args ...string) map[string]any {
+		t.Helper()
+		args = append([]string{"--ollama-url", ollamaURL, "search", "--query", "rotate TLS certs", "--limit", "1"}, args...)
+		out, err := runCLI(t, binary, args...)
+		if err != nil {
+			t.Fatalf("search failed: %v\n%s", err, out)
+		}
+		return parseJSON(t, out)
+	}
+	topText := func(result map[string]any) string {
+		results, _ := result["results"].([]any)
+		if len(results) == 0 {
+			return ""
+		}
+		return results[0].(map[string]any)["payload"].(map[string]any)["text"].(string)
+	}
+
+	if got := topText(search()); got != "the staging cluster runs on k8s" {
+		t.Errorf("expected similarity alone to rank the nearest memory first, got %q", got)
+	}
+	hybrid := search("--hybrid")
+	if got := topText(hybrid); got != "todo: rotate the TLS certs" {
+		t.Errorf("expected the exact-term match first, got %q", got)
+	}
+	if kw, _ := hybrid["keywords"].([]any); len(kw) != 3 {
+		t.Errorf("expected 3 keywords, got %v", hybrid["keywords"])
+	}
+	if got := topText(search("--keyword-weight", "0")); got != "the staging cluster runs on k8s" {
+		t.Errorf("expected a zero keyword weight to rank by similarity, got %q", got)
+	}
+}
+
+func TestCLISearchCacheFlags(t *testing.T) {
+	binary := buildBinary(t)
+
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"without query", []string{"search", "--cache", "--vector", "[0.1, 0.2, 0.3, 0.4]"}},
+		{"zero ttl", []string{"search", "--query", "status", "--cache-ttl", "0s"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := runCLI(t, binary, tt.args...)
+			if err == nil {
+				t.Fatalf("expected error\n%s", out)
+			}
+			if parseJSON(t, out)["status"] != "error" {
+				t.Errorf("expected status error\n%s", out)
+			}
+		})
+	}
+
+	if _, err := runCLI(t, binary, "search", "--query", "status", "--cache-ttl", "soon"); err == nil {
+		t.Error("expected error for --cache-ttl soon")
+	}
+}
+
+func TestCLISearchQueriesFileRejects(t *testing.T) {
+	binary := buildBinary(t)
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	good := write("good.jsonl", `{"query": "deploy day"}`+"\n")
+
+	// The file and flags are checked before connecting, so no services are needed.
+	tests := map[string][]string{
+		"with --query":   {"--queries-file", good, "--query", "deploy"},
+		"with --cache":   {"--queries-file", good, "--cache"},
+		"missing file":   {"--queries-file", filepath.Join(dir, "nope.jsonl")},
+		"empty file":     {"--queries-file", write("empty.jsonl", "\n")},
+		"bad json":       {"--queries-file", write("bad.jsonl", "{\"query\": \"ok\"}\nnot json\n")},
+		"query + vector": {"--queries-file", write("both.jsonl", `{"query": "x", "vector": [1, 0]}`+"\n")},
+		"route + vector": {"--queries-file", write("vec.jsonl", `{"vector": [1, 0]}`+"\n"), "--route"},
+	}
+	for name, args := range tests {
+		out, err := runCLI(t, binary, append([]string{"search"}, args...)...)
+		if err == nil || parseJSON(t, out)["status"] != "error" {
+			t.Errorf("%s: expected status error\n%s", name, out)
+		}
+	}
+}
+
+func TestCLISearchQueriesFile(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+	ollamaURL := fakeOllama(t).URL
+
+	defer cleanupMemories(t)
+
+	for _, text := range []string{"deploys go out on tuesdays", "standup is at 10:00"} {
+		if out, err := runCLI(t, binary, "add", "--no-merge", "--vector", "[0.3, 0.1, 0.4, 0.1]",
+			"--payload", fmt.Sprintf(`{"text": %q}`, text)); err != nil {
+			t.Fatalf("add failed: %v\n%s", err, out)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "queries.jsonl")
+	lines := `{"id": "q1", "query": "when do deploys happen?"}
+{"id": "q2", "query": "when is standup?", "limit": 2}
+
+{"id": "q3", "vector": [0.3, 0.1, 0.4, 0.1], "min_score": 0.5}
+`
+	if err := os.WriteFile(path, []byte(lines), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := runCLI(t, binary, "--ollama-url", ollamaURL, "search", "--queries-file", path)
+	if err != nil {
+		t.Fatalf("batch search failed: %v\n%s", err, out)
+	}
+	result := parseJSON(t, out)
+	if result["queries"] != 3.0 || result["failed"] != 0.0 {
+		t.Fatalf("expected 3 queries and no failures\n%s", out)
+	}
+	entries := result["results"].([]any)
+	wantReturned := []float64{1, 2, 1}
+	for i, e := range entries {
+		entry := e.(map[string]any)
+		if entry["id"] != fmt.Sprintf("q%d", i+1) {
+			t.Errorf("entry %d: expected results in file order, got id %v", i, entry["id"])
+		}
+		if entry["returned"] != wantReturned[i] {
+			t.Errorf("entry %d: expected %v results, got %v", i, wantReturned[i], entry["returned"])
+		}
+	}
+	if entries[0].(map[string]any)["query"] != "when do deploys happen?" {
+		t.Errorf("expected the query text echoed back, got %v", entries[0])
+	}
+}
+
+func TestCLISearchCache(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+	skipIfNoRedis(t)
+	ollamaURL := fakeOllama(t).URL
+
+	defer cleanupMemories(t)
+
+	add := func(payload string) {
+		t.Helper()
+		if out, err := runCLI(t, binary, "add", "--no-merge", "--vector", "[0.3, 0.1, 0.4, 0.1]", "--payload", payload); err != nil {
+			t.Fatalf("add failed: %v\n%s", err, out)
+		}
+	}
+	search := func() map[string]any {
+		t.Helper()
+		out, err := runCLI(t, binary, "--ollama-url", ollamaURL, "search", "--cache", "--query", "status of Zephyr?")
+		if err != nil {
+			t.Fatalf("search failed: %v\n%s", err, out)
+		}
+		return parseJSON(t, out)
+	}
+
+	// Mentioning Zephyr also clears any entry left by an earlier run.
+	add(`{"text": "Zephyr is in beta"}`)
+
+	if first := search(); first["cached"] != false {
+		t.Errorf("expected a miss on the first search, got %v", first)
+	}
+	second := search()
+	if second["cached"] != true {
+		t.Fatalf("expected a hit on the repeated search, got %v", second)
+	}
+	if results, _ := second["results"].([]any); len(results) != 1 {
+		t.Errorf("expected the cached result, got %v", second["results"])
+	}
+
+	add(`{"text": "unrelated note about lunch"}`)
+	if again := search(); again["cached"] != true {
+		t.Errorf("expected an unrelated add to keep the entry, got %v", again)
+	}
+
+	add(`{"text": "zephyr shipped to production"}`)
+	third := search()
+	if third["cached"] != false {
+		t.Errorf("expected an add mentioning Zephyr to invalidate, got %v", third)
+	}
+	if results, _ := third["results"].([]any); len(results) != 3 {
+		t.Errorf("expected fresh results including new memories, got %v", third["results"])
+	}
+}
+
+func TestCLIRemindFlags(t *testing.T) {
+	binary := buildBinary(t)
+
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"unknown day", []string{"add", "--text", "x", "--remind", "every blursday"}},
+		{"past date", []string{"add", "--text", "x", "--remind", "2020-01-01"}},
+		{"negative watch", []string{"due", "--watch", "-1m"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := runCLI(t, binary, tt.args...)
+			if err == nil {
+				t.Fatalf("expected error\n%s", out)
+			}
+			if parseJSON(t, out)["status"] != "error" {
+				t.Errorf("expected status error\n%s", out)
+			}
+		})
+	}
+}
+
+func TestCLIDue(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+
+	defer cleanupMemories(t)
+
+	add := func(args ...string) map[string]any {
+		t.Helper()
+		out, err := runCLI(t, binary, append([]string{"add", "--no-merge", "--vector", "[0.1, 0.2, 0.3, 0.4]"}, args...)...)
+		if err != nil {
+			t.Fatalf("add failed: %v\n%s", err, out)
+		}
+		return parseJSON(t, out)
+	}
+	due := func(args ...string) map[string]any {
+		t.Helper()
+		out, err := runCLI(t, binary, append([]string{"due"}, args...)...)
+		if err != nil {
+			t.Fatalf("due failed: %v\n%s", err, out)
+		}
+		return parseJSON(t, out)
+	}
+
+	future := add("--payload", `{"text": "weekly review"}`, "--remind", "every friday 09:00")
+	next, _ := future["remind_next"].(string)
+	if at, err := time.Parse(time.RFC3339, next); err != nil || !at.After(time.Now()) {
+		t.Fatalf("expected a future remind_next, got %v", future)
+	}
+	if got := due(); got["count"] != float64(0) {
+		t.Fatalf("expected nothing due yet, got %v", got)
+	}
+
+	// Reminders that came due while nobody was looking.
+	recurring := add("--payload", `{"text": "water the plants", "remind": "every 1h", "remind_next": "2020-01-01T00:00:00Z"}`)["id"]
+	oneShot := add("--payload", `{"text": "call the vendor", "remind": "in 1h", "remind_next": "2020-01-02T00:00:00Z"}`)["id"]
+
+	listed := due()
+	results, _ := listed["results"].([]any)
+	if len(results) != 2 || results[0].(map[string]any)["id"] != recurring || results[1].(map[string]any)["id"] != oneShot {
+		t.Fatalf("expected both overdue reminders, oldest first, got %v", listed)
+	}
+	if again := due(); again["count"] != float64(2) {
+		t.Errorf("expected listing without --ack to leave reminders due, got %v", again)
+	}
+
+	acked := due("--ack")
+	entries, _ := acked["acked"].([]any)
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 acked reminders, got %v", acked)
+	}
+	rescheduled, _ := entries[0].(map[string]any)["remind_next"].(string)
+	if at, err := time.Parse(time.RFC3339, rescheduled); err != nil || !at.After(time.Now()) {
+		t.Errorf("expected the recurring reminder rescheduled into the future, got %v", entries[0])
+	}
+	if _, ok := entries[1].(map[string]any)["remind_next"]; ok {
+		t.Errorf("expected the one-shot reminder to be finished, got %v", entries[1])
+	}
+
+	if after := due(); after["count"] != float64(0) {
+		t.Errorf("expected nothing due after --ack, got %v", after)
+	}
+
+	out, err := runCLI(t, binary, "get", "--id", oneShot.(string))
+	if err != nil {
+		t.Fatalf("get failed: %v\n%s", err, out)
+	}
+	payload := parseJSON(t, out)["payload"].(map[string]any)
+	if payload["remind_next"] != nil || payload["reminded_at"] == nil {
+		t.Errorf("expected finished reminder to record reminded_at and drop remind_next, got %v", payload)
+	}
+}
+
+func TestCLISearchFilterFlags(t *testing.T) {
+	binary := buildBinary(t)
+
+	for _, filter := range []string{"priority>=high", "location~52.5,13.4", "source=/old/notes/"} {
+		t.Run(filter, func(t *testing.T) {
+			out, err := runCLI(t, binary, "search", "--vector", "[0.1, 0.2, 0.3, 0.4]", "--filter", filter)
+			if err == nil {
+				t.Fatalf("expected error\n%s", out)
+			}
+			if parseJSON(t, out)["status"] != "error" {
+				t.Errorf("expected status error\n%s", out)
+			}
+		})
+	}
+}
+
+func TestCLIAddTypedFieldRejects(t *testing.T) {
+	binary := buildBinary(t)
+
+	cfg := filepath.Join(t.TempDir(), "clawbrain.json")
+	if err := os.WriteFile(cfg, []byte(`{"fields": {"priority": "integer"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Typed fields are checked before connecting, so no services are needed.
+	out, err := runCLI(t, binary, "--config", cfg, "add",
+		"--vector", "[0.1, 0.2, 0.3, 0.4]",
+		"--payload", `{"text": "ship it", "priority": "urgent"}`,
+	)
+	if err == nil {
+		t.Fatalf("expected typed field rejection\n%s", out)
+	}
+	result := parseJSON(t, out)
+	if result["status"] != "error" || !strings.Contains(result["message"].(string), "priority") {
+		t.Errorf("expected an error naming the field, got %v", result)
+	}
+}
+
+func TestCLISearchFilter(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+
+	defer cleanupMemories(t)
+
+	cfg := filepath.Join(t.TempDir(), "clawbrain.json")
+	if err := os.WriteFile(cfg, []byte(`{"fields": {"priority": "integer", "location": "geo"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	add := func(payload string) {
+		t.Helper()
+		if out, err := runCLI(t, binary, "--config", cfg, "add", "--no-merge", "--vector", "[0.1, 0.2, 0.3, 0.4]", "--payload", payload); err != nil {
+			t.Fatalf("add failed: %v\n%s", err, out)
+		}
+	}
+	add(`{"text": "fix the outage", "type": "todo", "priority": 5, "location": {"lat": 52.52, "lon": 13.405}}`)
+	add(`{"text": "tidy the wiki", "type": "todo", "priority": 1, "location": "48.8566,2.3522"}`)
+	add(`{"text": "no priority here"}`)
+
+	search := func(filters ...string) []string {
+		t.Helper()
+		args := []string{"search", "--vector", "[0.1, 0.2, 0.3, 0.4]", "--limit", "10"}
+		for _, f := range filters {
+			args = append(args, "--filter", f)
+		}
+		out, err := runCLI(t, binary, args...)
+		if err != nil {
+			t.Fatalf("search failed: %v\n%s", err, out)
+		}
+		var texts []string
+		results, _ := parseJSON(t, out)["results"].([]any)
+		for _, r := range results {
+			texts = append(texts, r.(map[string]any)["payload"].(map[string]any)["text"].(string))
+		}
+		sort.Strings(texts)
+		return texts
+	}
+
+	if got := search("priority>=3"); len(got) != 1 || got[0] != "fix the outage" {
+		t.Errorf("priority>=3: got %v", got)
+	}
+	if got := search("priority<3"); len(got) != 1 || got[0] != "tidy the wiki" {
+		t.Errorf("priority<3: got %v", got)
+	}
+	if got := search("location~48.85,2.35,10km"); len(got) != 1 || got[0] != "tidy the wiki" {
+		t.Errorf("geo radius around Paris: got %v", got)
+	}
+	if got := search("type=todo", "priority>0"); len(got) != 2 {
+		t.Errorf("type=todo AND priority>0: got %v", got)
+	}
+	if got := search("priority=5"); len(got) != 1 || got[0] != "fix the outage" {
+		t.Errorf("priority=5: got %v", got)
+	}
+}
+
+func TestCLIAddAttributionRejects(t *testing.T) {
+	binary := buildBinary(t)
+
+	// Attribution is checked before connecting, so no services are needed.
+	out, err := runCLI(t, binary, "add",
+		"--vector", "[0.1, 0.2, 0.3, 0.4]",
+		"--payload", `{"text": "ship it", "speaker": 7}`,
+	)
+	if err == nil {
+		t.Fatalf("expected non-string speaker to be rejected\n%s", out)
+	}
+	result := parseJSON(t, out)
+	if result["status"] != "error" || !strings.Contains(result["message"].(string), "speaker") {
+		t.Errorf("expected an error naming the field, got %v", result)
+	}
+}
+
+func TestCLISearchAuthorSpeaker(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+
+	defer cleanupMemories(t)
+
+	add := func(args ...string) {
+		t.Helper()
+		args = append([]string{"add", "--no-merge", "--vector", "[0.1, 0.2, 0.3, 0.4]"}, args...)
+		if out, err := runCLI(t, binary, args...); err != nil {
+			t.Fatalf("add failed: %v\n%s", err, out)
+		}
+	}
+	add("--payload", `{"text": "lico wants dark mode"}`, "--speaker", "Lico", "--author", "claw")
+	add("--payload", `{"text": "dark mode needs a theme token pass", "author": "Claw"}`)
+	add("--payload", `{"text": "unattributed"}`)
+
+	search := func(args ...string) []string {
+		t.Helper()
+		args = append([]string{"search", "--vector", "[0.1, 0.2, 0.3, 0.4]", "--limit", "10"}, args...)
+		out, err := runCLI(t, binary, args...)
+		if err != nil {
+			t.Fatalf("search failed: %v\n%s", err, out)
+		}
+		var texts []string
+		results, _ := parseJSON(t, out)["results"].([]any)
+		for _, r := range results {
+			texts = append(texts, r.(map[string]any)["payload"].(map[string]any)["text"].(string))
+		}
+		sort.Strings(texts)
+		return texts
+	}
+
+	if got := search("--speaker", "LICO"); len(got) != 1 || got[0] != "lico wants dark mode" {
+		t.Errorf("--speaker LICO: got %v", got)
+	}
+	if got := search("--author", "claw"); len(got) != 2 {
+		t.Errorf("--author claw: got %v", got)
+	}
+	if got := search("--author", "claw", "--speaker", "lico"); len(got) != 1 {
+		t.Errorf("--author claw --speaker lico: got %v", got)
+	}
+	if got := search("--author", "nobody"); len(got) != 0 {
+		t.Errorf("--author nobody: got %v", got)
+	}
+}
+
+func TestCLITypeFlags(t *testing.T) {
+	binary := buildBinary(t)
+
+	// Types are checked before connecting, so no services are needed.
+	for _, args := range [][]string{
+		{"add", "--vector", "[0.1, 0.2, 0.3, 0.4]", "--payload", `{"text": "ship it"}`, "--type", "meeting"},
+		{"add", "--vector", "[0.1, 0.2, 0.3, 0.4]", "--payload", `{"text": "ship it", "type": "chore"}`},
+		{"search", "--vector", "[0.1, 0.2, 0.3, 0.4]", "--type", "meeting"},
+		{"search", "--query", "open work", "--type", "todo", "--route"},
+		{"search", "--vector", "[0.1, 0.2, 0.3, 0.4]", "--type", "todo", "--per-type-limit", "todo=2"},
+	} {
+		out, err := runCLI(t, binary, args...)
+		if err == nil {
+			t.Errorf("%v: expected an error\n%s", args, out)
+			continue
+		}
+		if parseJSON(t, out)["status"] != "error" {
+			t.Errorf("%v: expected status error\n%s", args, out)
+		}
+	}
+}
+
+func TestCLISearchType(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+
+	defer cleanupMemories(t)
+
+	add := func(args ...string) {
+		t.Helper()
+		args = append([]string{"add", "--no-merge", "--vector", "[0.1, 0.2, 0.3, 0.4]"}, args...)
+		if out, err := runCLI(t, binary, args...); err != nil {
+			t.Fatalf("add failed: %v\n%s", err, out)
+		}
+	}
+	add("--payload", `{"text": "rotate the api keys"}`, "--type", "TODO")
+	add("--payload", `{"text": "update the changelog", "type": "todo"}`)
+	add("--payload", `{"text": "retries need jitter"}`, "--type", "lesson")
+	add("--payload", `{"text": "unfiled"}`)
+
+	search := func(args ...string) []string {
+		t.Helper()
+		args = append([]string{"search", "--vector", "[0.1, 0.2, 0.3, 0.4]", "--limit", "10"}, args...)
+		out, err := runCLI(t, binary, args...)
+		if err != nil {
+			t.Fatalf("search failed: %v\n%s", err, out)
+		}
+		var texts []string
+		results, _ := parseJSON(t, out)["results"].([]any)
+		for _, r := range results {
+			texts = append(texts, r.(map[string]any)["payload"].(map[string]any)["text"].(string))
+		}
+		sort.Strings(texts)
+		return texts
+	}
+
+	if got := search("--type", "todo"); len(got) != 2 || got[0] != "rotate the api keys" {
+		t.Errorf("--type todo: got %v", got)
+	}
+	if got := search("--type", "lesson", "--type", "untyped"); len(got) != 2 || got[1] != "unfiled" {
+		t.Errorf("--type lesson --type untyped: got %v", got)
+	}
+	if got := search("--type", "fact"); len(got) != 0 {
+		t.Errorf("--type fact: got %v", got)
+	}
+}
+
+func TestCLIAddSensitivityRejects(t *testing.T) {
+	binary := buildBinary(t)
+
+	// Sensitivity is checked before connecting, so no services are needed.
+	out, err := runCLI(t, binary, "add", "--vector", "[0.1, 0.2, 0.3, 0.4]",
+		"--payload", `{"text": "ship it"}`, "--sensitivity", "secret")
+	if err == nil {
+		t.Fatalf("expected unknown sensitivity to be rejected\n%s", out)
+	}
+	if parseJSON(t, out)["status"] != "error" {
+		t.Errorf("expected status error\n%s", out)
+	}
+}
+
+func TestCLIDedupThresholdRejects(t *testing.T) {
+	binary := buildBinary(t)
+
+	// The threshold is checked before connecting, so no services are needed.
+	for _, args := range [][]string{
+		{"add", "--text", "ship it", "--dedup-threshold", "0"},
+		{"add", "--text", "ship it", "--dedup-threshold", "1.5"},
+		{"add", "--text", "ship it", "--dedup-threshold", "0.9", "--no-merge"},
+		{"sync", "--dedup-threshold", "-1"},
+	} {
+		out, err := runCLI(t, binary, args...)
+		if err == nil {
+			t.Fatalf("expected %v to be rejected\n%s", args, out)
+		}
+		if parseJSON(t, out)["status"] != "error" {
+			t.Errorf("%v: expected status error\n%s", args, out)
+		}
+	}
+
+	t.Setenv("CLAWBRAIN_DEDUP_THRESHOLD", "high")
+	out, err := runCLI(t, binary, "add", "--text", "ship it")
+	if err == nil {
+		t.Fatalf("expected an unparsable CLAWBRAIN_DEDUP_THRESHOLD to be rejected\n%s", out)
+	}
+	if msg, _ := parseJSON(t, out)["message"].(string); !strings.Contains(msg, "CLAWBRAIN_DEDUP_THRESHOLD") {
+		t.Errorf("expected the error to name the variable\n%s", out)
+	}
+}
+
+func TestCLIAgentRejectsInvalidName(t *testing.T) {
+	binary := buildBinary(t)
+
+	// The agent name is checked before connecting, so no services are needed.
+	out, err := runCLI(t, binary, "--agent", "two words", "search", "--vector", "[0.1, 0.2, 0.3, 0.4]")
+	if err == nil {
+		t.Fatalf("expected invalid agent name to be rejected\n%s", out)
+	}
+	if parseJSON(t, out)["status"] != "error" {
+		t.Errorf("expected status error\n%s", out)
+	}
+}
+
+func TestCLINamespaceRejectsInvalidName(t *testing.T) {
+	binary := buildBinary(t)
+
+	out, err := runCLI(t, binary, "--namespace", "a.b", "search", "--vector", "[0.1, 0.2, 0.3, 0.4]")
+	if err == nil {
+		t.Fatalf("expected invalid namespace to be rejected\n%s", out)
+	}
+	if parseJSON(t, out)["status"] != "error" {
+		t.Errorf("expected status error\n%s", out)
+	}
+}
+
+func TestCLIPlugin(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("plugin scripts are shell scripts")
+	}
+	binary := buildBinary(t)
+
+	dir := t.TempDir()
+	script := "#!/bin/sh\nprintf '{\"args\":\"%s\",\"host\":\"%s\",\"namespace\":\"%s\"}\\n' \"$*\" \"$CLAWBRAIN_HOST\" \"$CLAWBRAIN_NAMESPACE\"\nexit 3\n"
+	if err := os.WriteFile(filepath.Join(dir, "clawbrain-hello"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	out, err := runCLI(t, binary, "--host", "qdrant.internal", "--namespace", "team", "hello", "--flag", "x")
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 {
+		t.Fatalf("expected the plugin's exit code 3, got %v\n%s", err, out)
+	}
+	result := parseJSON(t, out)
+	if result["args"] != "--flag x" || result["host"] != "qdrant.internal" || result["namespace"] != "team" {
+		t.Errorf("plugin got the wrong arguments or settings: %v", result)
+	}
+
+	out, err = runCLI(t, binary, "plugins")
+	if err != nil {
+		t.Fatalf("plugins failed: %v\n%s", err, out)
+	}
+	found := false
+	for _, p := range parseJSON(t, out)["plugins"].([]any) {
+		if p.(map[string]any)["name"] == "hello" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected hello among the plugins, got %s", out)
+	}
+}
+
+func TestCLIMigrate(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+
+	defer cleanupMemories(t)
+
+	if out, err := runCLI(t, binary, "add", "--no-merge", "--vector", "[0.1, 0.2, 0.3, 0.4]", "--payload", `{"text": "schema check"}`); err != nil {
+		t.Fatalf("add failed: %v\n%s", err, out)
+	}
+
+	// A collection created by this build is already current.
+	out, err := runCLI(t, binary, "migrate", "--dry-run")
+	if err != nil {
+		t.Fatalf("migrate --dry-run failed: %v\n%s", err, out)
+	}
+	result := parseJSON(t, out)
+	if result["schema_version"] != result["latest_version"] || len(result["pending"].([]any)) != 0 {
+		t.Errorf("expected nothing pending, got %v", result)
+	}
+
+	out, err = runCLI(t, binary, "migrate")
+	if err != nil {
+		t.Fatalf("migrate failed: %v\n%s", err, out)
+	}
+	if applied := parseJSON(t, out)["applied"].([]any); len(applied) != 0 {
+		t.Errorf("expected no migrations applied, got %v", applied)
+	}
+}
+
+func TestCLIMigrateEmbeddingsRejects(t *testing.T) {
+	binary := buildBinary(t)
+
+	// Checked before connecting, so no services are needed.
+	for _, args := range [][]string{
+		{"migrate-embeddings"},
+		{"--agent", "support-bot", "migrate-embeddings", "--backup", filepath.Join(t.TempDir(), "b.jsonl")},
+	} {
+		out, err := runCLI(t, binary, args...)
+		if err == nil || parseJSON(t, out)["status"] != "error" {
+			t.Errorf("expected %v to be rejected\n%s", args, out)
+		}
+	}
+}
+
+func TestCLIEmbeddingGuard(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+	ollamaURL := fakeOllama(t).URL
+
+	cleanupMemories(t)
+	defer cleanupMemories(t)
+
+	// The collection is created for all-minilm, the default model.
+	if out, err := runCLI(t, binary, "--ollama-url", ollamaURL, "add", "--text", "deploys go out on tuesdays"); err != nil {
+		t.Fatalf("add failed: %v\n%s", err, out)
+	}
+
+	// A vector of another size, or text embedded by another model, is refused.
+	out, err := runCLI(t, binary, "search", "--vector", "[0.1, 0.2, 0.3]")
+	if err == nil || !strings.Contains(parseJSON(t, out)["message"].(string), "migrate-embeddings") {
+		t.Errorf("expected a 3-dimension vector to be refused with a hint\n%s", out)
+	}
+	out, err = runCLI(t, binary, "--ollama-url", ollamaURL, "--model", "other-model", "add", "--text", "standup is at 10")
+	if err == nil || !strings.Contains(parseJSON(t, out)["message"].(string), "--model all-minilm") {
+		t.Errorf("expected text embedded by another model to be refused\n%s", out)
+	}
+
+	// migrate-embeddings re-embeds with the new model, after a backup.
+	backupFile := filepath.Join(t.TempDir(), "before.jsonl")
+	out, err = runCLI(t, binary, "--ollama-url", ollamaURL, "--model", "other-model", "migrate-embeddings", "--backup", backupFile)
+	if err != nil {
+		t.Fatalf("migrate-embeddings failed: %v\n%s", err, out)
+	}
+	result := parseJSON(t, out)
+	if result["reembedded"] != float64(1) || result["to"].(map[string]any)["model"] != "other-model" {
+		t.Errorf("unexpected migration result %s", out)
+	}
+	if _, err := os.Stat(backupFile); err != nil {
+		t.Errorf("expected a backup: %v", err)
+	}
+	if out, err := runCLI(t, binary, "--ollama-url", ollamaURL, "--model", "other-model", "add", "--text", "standup is at 10"); err != nil {
+		t.Errorf("expected the new model to be accepted after migrating: %v\n%s", err, out)
+	}
+}
+
+func TestCLINamespaces(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+
+	cleanupMemories(t)
+	defer cleanupMemories(t)
+	defer func() {
+		s, err := store.New("localhost", 6334)
+		if err != nil {
+			return
+		}
+		defer s.Close()
+		s.SetNamespace("cli-test")
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		s.DeleteCollection(ctx)
+	}()
+
+	for _, args := range [][]string{
+		{"add", "--vector", "[0.1, 0.2, 0.3, 0.4]", "--payload", `{"text": "default note"}`},
+		{"--namespace", "cli-test", "add", "--vector", "[0.1, 0.2, 0.3, 0.4]", "--payload", `{"text": "namespaced note"}`},
+		{"--namespace", "cli-test", "add", "--no-merge", "--vector", "[0.4, 0.3, 0.2, 0.1]", "--payload", `{"text": "another namespaced note"}`},
+	} {
+		if out, err := runCLI(t, binary, args...); err != nil {
+			t.Fatalf("%v failed: %v\n%s", args, err, out)
+		}
+	}
+
+	out, err := runCLI(t, binary, "search", "--vector", "[0.1, 0.2, 0.3, 0.4]", "--limit", "10")
+	if err != nil {
+		t.Fatalf("search failed: %v\n%s", err, out)
+	}
+	if returned := parseJSON(t, out)["returned"]; returned != 1.0 {
+		t.Errorf("expected only the default memory outside the namespace, got %v", returned)
+	}
+
+	out, err = runCLI(t, binary, "namespaces")
+	if err != nil {
+		t.Fatalf("namespaces failed: %v\n%s", err, out)
+	}
+	counts := make(map[string]any)
+	for _, ns := range parseJSON(t, out)["namespaces"].([]any) {
+		ns := ns.(map[string]any)
+		counts[ns["name"].(string)] = ns["count"]
+	}
+	if counts[""] != 1.0 || counts["cli-test"] != 2.0 {
+		t.Errorf("unexpected namespace counts %v", counts)
+	}
+}
+
+func TestCLISharedHidesPersonal(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+
+	defer cleanupMemories(t)
+
+	add := func(args ...string) string {
+		t.Helper()
+		args = append([]string{"add", "--no-merge", "--vector", "[0.1, 0.2, 0.3, 0.4]"}, args...)
+		out, err := runCLI(t, binary, args...)
+		if err != nil {
+			t.Fatalf("add failed: %v\n%s", err, out)
+		}
+		return parseJSON(t, out)["id"].(string)
+	}
+	personal := add("--payload", `{"text": "lico is allergic to peanuts"}`, "--sensitivity", "Personal")
+	add("--payload", `{"text": "the office has a peanut-free kitchen"}`)
+
+	count := func(shared bool, extra ...string) int {
+		t.Helper()
+		var args []string
+		if shared {
+			args = append(args, "--shared")
+		}
+		args = append(args, "search", "--vector", "[0.1, 0.2, 0.3, 0.4]", "--limit", "10")
+		out, err := runCLI(t, binary, append(args, extra...)...)
+		if err != nil {
+			t.Fatalf("search failed: %v\n%s", err, out)
+		}
+		results, _ := parseJSON(t, out)["results"].([]any)
+		return len(results)
+	}
+	if got := count(false); got != 2 {
+		t.Errorf("expected both memories outside a shared context, got %d", got)
+	}
+	if got := count(true); got != 1 {
+		t.Errorf("expected the personal memory hidden with --shared, got %d", got)
+	}
+	if got := count(true, "--include-personal"); got != 2 {
+		t.Errorf("expected --include-personal to show it again, got %d", got)
+	}
+
+	out, err := runCLI(t, binary, "--shared", "get", "--id", personal)
+	if err == nil || parseJSON(t, out)["status"] != "error" {
+		t.Errorf("expected get to refuse a personal memory with --shared\n%s", out)
+	}
+	out, err = runCLI(t, binary, "--shared", "get", "--id", personal, "--include-personal")
+	if err != nil {
+		t.Fatalf("get --include-personal failed: %v\n%s", err, out)
+	}
+	payload := parseJSON(t, out)["payload"].(map[string]any)
+	if payload["sensitivity"] != "personal" {
+		t.Errorf("expected normalized sensitivity, got %v", payload["sensitivity"])
+	}
+}
+
+func TestCLIAddPreconditionFlags(t *testing.T) {
+	binary := buildBinary(t)
+
+	// Preconditions are checked before connecting, so no services are needed.
+	for _, args := range [][]string{
+		{"--if-version", "1"},
+		{"--id", "4f8a7c1e-2b3d-4e5f-8a9b-0c1d2e3f4a5b", "--if-last-accessed-before", "yesterday"},
+		{"--id", "4f8a7c1e-2b3d-4e5f-8a9b-0c1d2e3f4a5b", "--if-version", "-1"},
+	} {
+		args = append([]string{"add", "--vector", "[0.1, 0.2, 0.3, 0.4]", "--payload", `{"text": "x"}`}, args...)
+		out, err := runCLI(t, binary, args...)
+		if err == nil || parseJSON(t, out)["status"] != "error" {
+			t.Errorf("expected %v to be rejected\n%s", args, out)
+		}
+	}
+}
+
+func TestCLIAddIfVersionConflict(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+
+	defer cleanupMemories(t)
+
+	write := func(text string, extra ...string) (map[string]any, error) {
+		t.Helper()
+		args := append([]string{"add", "--no-merge", "--vector", "[0.1, 0.2, 0.3, 0.4]",
+			"--payload", fmt.Sprintf(`{"text": %q}`, text)}, extra...)
+		out, err := runCLI(t, binary, args...)
+		return parseJSON(t, out), err
+	}
+	first, err := write("standup is at 09:30")
+	if err != nil {
+		t.Fatalf("add failed: %v\n%v", err, first)
+	}
+	id := first["id"].(string)
+	if first["revision"] != 1.0 {
+		t.Errorf("expected a new memory at revision 1, got %v", first["revision"])
+	}
+
+	second, err := write("standup is at 10:00", "--id", id, "--if-version", "1")
+	if err != nil || second["revision"] != 2.0 {
+		t.Fatalf("expected the correction to land at revision 2: %v\n%v", err, second)
+	}
+
+	// Another agent that also read revision 1 must not clobber it.
+	third, err := write("standup is at 09:45", "--id", id, "--if-version", "1")
+	if err == nil || third["status"] != "conflict" {
+		t.Fatalf("expected a conflict\n%v", third)
+	}
+	conflict := third["conflict"].(map[string]any)
+	if conflict["revision"] != 2.0 || conflict["id"] != id {
+		t.Errorf("expected the conflict to report revision 2, got %v", conflict)
+	}
+
+	out, err := runCLI(t, binary, "get", "--id", id)
+	if err != nil {
+		t.Fatalf("get failed: %v\n%s", err, out)
+	}
+	if text := parseJSON(t, out)["payload"].(map[string]any)["text"]; text != "standup is at 10:00" {
+		t.Errorf("expected the first correction to survive, got %v", text)
+	}
+}
+
+func TestCLIUpdateRejects(t *testing.T) {
+	binary := buildBinary(t)
+
+	const id = "4f8a7c1e-2b3d-4e5f-8a9b-0c1d2e3f4a5b"
+	// All checked before connecting, so no services are needed.
+	for _, args := range [][]string{
+		{"--text", "x"},
+		{"--id", id},
+		{"--id", id, "--alias", "standup", "--text", "x"},
+		{"--id", "not-a-uuid", "--text", "x"},
+		{"--id", id, "--text", " "},
+		{"--id", id, "--payload", "{not json"},
+		{"--id", id, "--payload", `{"created_at": "2020-01-01T00:00:00Z"}`},
+		{"--id", id, "--payload", `{"text": ""}`},
+		{"--id", id, "--text", "x", "--if-version", "-1"},
+	} {
+		if _, err := runCLI(t, binary, append([]string{"update"}, args...)...); err == nil {
+			t.Errorf("expected %v to be rejected", args)
+		}
+	}
+
+	// Only lock and unlock change a lock.
+	for _, patch := range []string{`{"locked": true}`, `{"locked": null}`} {
+		out, err := runCLI(t, binary, "update", "--id", id, "--payload", patch)
+		if err == nil || !strings.Contains(fmt.Sprint(parseJSON(t, out)["message"]), "lock or unlock") {
+			t.Errorf("expected %s to be rejected in favor of lock/unlock, got %s", patch, out)
+		}
+	}
+}
+
+func TestCLIUpdatePayload(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+
+	defer cleanupMemories(t)
+
+	out, err := runCLI(t, binary, "add", "--no-merge", "--pinned", "--vector", "[0.1, 0.2, 0.3, 0.4]",
+		"--payload", `{"text": "standup is at 09:30", "source": "chat", "room": "ops"}`)
+	if err != nil {
+		t.Fatalf("add failed: %v\n%s", err, out)
+	}
+	id := parseJSON(t, out)["id"].(string)
+	out, _ = runCLI(t, binary, "get", "--id", id)
+	before := parseJSON(t, out)["payload"].(map[string]any)
+
+	// A payload-only edit keeps the vector, so it needs no Ollama.
+	out, err = runCLI(t, binary, "update", "--id", id, "--if-version", "1", "--payload", `{"source": "meeting", "room": null}`)
+	if err != nil {
+		t.Fatalf("update failed: %v\n%s", err, out)
+	}
+	result := parseJSON(t, out)
+	if result["revision"] != 2.0 || result["reembedded"] != false {
+		t.Errorf("expected revision 2 without re-embedding, got %v", result)
+	}
+
+	out, err = runCLI(t, binary, "get", "--id", id)
+	if err != nil {
+		t.Fatalf("get failed: %v\n%s", err, out)
+	}
+	after := parseJSON(t, out)["payload"].(map[string]any)
+	if after["source"] != "meeting" || after["room"] != nil || after["text"] != "standup is at 09:30" {
+		t.Errorf("expected the patch merged into the payload, got %v", after)
+	}
+	if after["pinned"] != true || after["created_at"] != before["created_at"] {
+		t.Errorf("expected pinned and created_at kept, got %v", after)
+	}
+
+	out, err = runCLI(t, binary, "update", "--id", id, "--if-version", "1", "--payload", `{"source": "email"}`)
+	if err == nil || parseJSON(t, out)["status"] != "conflict" {
+		t.Errorf("expected a stale --if-version to conflict\n%s", out)
+	}
+}
+
+func TestCLIAddLinkFlags(t *testing.T) {
+	binary := buildBinary(t)
+
+	// Links are parsed before connecting, so no services are needed.
+	for _, args := range [][]string{
+		{"--relate", "deploy-notes"},
+		{"--relate", "4f8a7c1e-2b3d-4e5f-8a9b-0c1d2e3f4a5b:Caused By"},
+		{"--supersedes", "4f8a7c1e-2b3d-4e5f-8a9b-0c1d2e3f4a5b:related"},
+		{"--relate", "4f8a7c1e-2b3d-4e5f-8a9b-0c1d2e3f4a5b", "--id", "9e2a7c1e-2b3d-4e5f-8a9b-0c1d2e3f4a5b", "--if-version", "1"},
+		{"--if-version", "1"},
+	} {
+		args = append([]string{"add", "--vector", "[0.1, 0.2, 0.3, 0.4]", "--payload", `{"text": "x"}`}, args...)
+		out, err := runCLI(t, binary, args...)
+		if err == nil || parseJSON(t, out)["status"] != "error" {
+			t.Errorf("expected %v to be rejected\n%s", args, out)
+		}
+	}
+}
+
+func TestCLIAddWithLinks(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+
+	defer cleanupMemories(t)
+
+	add := func(args ...string) (map[string]any, error) {
+		t.Helper()
+		out, err := runCLI(t, binary, append([]string{"add", "--vector", "[0.1, 0.2, 0.3, 0.4]"}, args...)...)
+		return parseJSON(t, out), err
+	}
+	old, err := add("--no-merge", "--payload", `{"text": "standup is at 09:30"}`)
+	if err != nil {
+		t.Fatalf("add failed: %v\n%v", err, old)
+	}
+	oldID := old["id"].(string)
+
+	// The identical vector would normally merge the old memory away; a link
+	// target is never deduplicated.
+	res, err := add("--payload", `{"text": "standup is at 10:00"}`,
+		"--supersedes", oldID, "--relate", oldID+":corrects", "--tag", "schedule")
+	if err != nil {
+		t.Fatalf("linked add failed: %v\n%v", err, res)
+	}
+	if _, merged := res["merged_id"]; merged {
+		t.Errorf("expected the superseded memory not to be merged\n%v", res)
+	}
+	newID := res["id"].(string)
+
+	out, err := runCLI(t, binary, "get", "--id", oldID)
+	if err != nil {
+		t.Fatalf("get failed: %v\n%s", err, out)
+	}
+	payload := parseJSON(t, out)["payload"].(map[string]any)
+	if payload["superseded_by"] != newID {
+		t.Errorf("expected superseded_by %s, got %v", newID, payload["superseded_by"])
+	}
+	if rel, _ := payload["relations"].([]any); len(rel) != 1 {
+		t.Errorf("expected a reverse relation, got %v", payload["relations"])
+	}
+
+	// Search leaves the stale belief out unless asked for it.
+	search := func(args ...string) []string {
+		t.Helper()
+		args = append([]string{"search", "--vector", "[0.1, 0.2, 0.3, 0.4]", "--limit", "10"}, args...)
+		out, err := runCLI(t, binary, args...)
+		if err != nil {
+			t.Fatalf("search failed: %v\n%s", err, out)
+		}
+		var ids []string
+		results, _ := parseJSON(t, out)["results"].([]any)
+		for _, r := range results {
+			ids = append(ids, r.(map[string]any)["id"].(string))
+		}
+		return ids
+	}
+	if got := search(); len(got) != 1 || got[0] != newID {
+		t.Errorf("expected only the superseding memory, got %v", got)
+	}
+	if got := search("--include-superseded"); len(got) != 2 {
+		t.Errorf("expected --include-superseded to return both memories, got %v", got)
+	}
+
+	// A dangling link fails the add without storing anything.
+	res, err = add("--no-merge", "--payload", `{"text": "orphan"}`,
+		"--relate", "4f8a7c1e-2b3d-4e5f-8a9b-0c1d2e3f4a5b")
+	if err == nil || res["status"] != "error" {
+		t.Errorf("expected a missing link target to be an error\n%v", res)
+	}
+}
+
+func TestCLISupersedeIfVersion(t *testing.T) {
+	binary := buildBinary(t)
+	dir := t.TempDir()
+	cli := func(args ...string) (map[string]any, error) {
+		t.Helper()
+		out, err := runCLI(t, binary, append([]string{"--backend", "file", "--path", dir}, args...)...)
+		return parseJSON(t, out), err
+	}
+
+	old, err := cli("add", "--no-merge", "--vector", "[1, 0, 0, 0]", "--text", "standup is at 09:30")
+	if err != nil {
+		t.Fatalf("add failed: %v\n%v", err, old)
+	}
+	oldID := old["id"].(string)
+	if res, err := cli("update", "--id", oldID, "--payload", `{"room": "blue"}`); err != nil {
+		t.Fatalf("update failed: %v\n%v", err, res)
+	}
+
+	// The old memory is at revision 2 now: a supersede that read revision 1
+	// conflicts and stores nothing.
+	res, err := cli("add", "--no-merge", "--vector", "[0, 1, 0, 0]", "--text", "standup is at 10:00",
+		"--supersedes", oldID, "--if-version", "1")
+	if err == nil || res["status"] != "conflict" {
+		t.Fatalf("expected a stale --if-version to conflict\n%v", res)
+	}
+	if conflict, _ := res["conflict"].(map[string]any); conflict["id"] != oldID || conflict["revision"] != float64(2) {
+		t.Errorf("expected the conflict to report %s at revision 2, got %v", oldID, res["conflict"])
+	}
+	got, _ := cli("get", "--id", oldID)
+	if payload := got["payload"].(map[string]any); payload["superseded_by"] != nil {
+		t.Errorf("expected the old memory not superseded, got %v", payload)
+	}
+	if recent, _ := cli("recent"); len(recent["memories"].([]any)) != 1 {
+		t.Errorf("expected no new memory to be stored, got %v", recent["memories"])
+	}
+
+	res, err = cli("add", "--no-merge", "--vector", "[0, 1, 0, 0]", "--text", "standup is at 10:00",
+		"--supersedes", oldID, "--if-version", "2")
+	if err != nil {
+		t.Fatalf("supersede at the current revision failed: %v\n%v", err, res)
+	}
+	got, _ = cli("get", "--id", oldID)
+	if payload := got["payload"].(map[string]any); payload["superseded_by"] != res["id"] {
+		t.Errorf("expected superseded_by %v, got %v", res["id"], payload["superseded_by"])
+	}
+}
+
+// startServe runs a clawbrain serve command line on a free port and returns
+// its base URL. The server is killed when the test ends.
+func startServe(t *testing.T, binary string, args ...string) string {
+	t.Helper()
+	args = append(args, "--addr", "127.0.0.1:0")
+	cmd := exec.Command(binary, args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("failed to start serve: %v", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+	var started struct {
+		Status string `json:"status"`
+		Addr   string `json:"addr"`
+	}
+	if err := json.NewDecoder(stdout).Decode(&started); err != nil || started.Status != "listening" {
+		t.Fatalf("serve didn't start: %v %+v", err, started)
+	}
+	return "http://" + started.Addr
+}
+
+// getHTTP sends a GET with an optional If-None-Match header.
+func getHTTP(t *testing.T, url, ifNoneMatch string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ifNoneMatch != "" {
+		req.Header.Set("If-None-Match", ifNoneMatch)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestCLIServeRejectsBadRequests(t *testing.T) {
+	binary := buildBinary(t)
+	// Parameters are checked before Qdrant is asked anything.
+	base := startServe(t, binary, "serve")
+
+	for _, path := range []string{"/search", "/search?query=tls&limit=many", "/search?query=tls&type=chore", "/memories?limit=-1"} {
+		resp := getHTTP(t, base+path, "")
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", path, resp.StatusCode)
+		}
+	}
+}
+
+func TestCLIServeRejectsOtherNamespace(t *testing.T) {
+	binary := buildBinary(t)
+	base := startServe(t, binary, "--namespace", "support", "serve")
+
+	for _, namespace := range []string{"", "billing"} {
+		resp := getHTTP(t, base+"/search?query=tls&namespace="+namespace, "")
+		var body map[string]any
+		json.NewDecoder(resp.Body).Decode(&body)
+		if resp.StatusCode != http.StatusBadRequest || !strings.Contains(fmt.Sprint(body["message"]), `"support"`) {
+			t.Errorf("namespace %q: expected 400 naming the server's namespace, got %d %v", namespace, resp.StatusCode, body)
+		}
+	}
+}
+
+func TestCLIServeRejectsBackoffThresholds(t *testing.T) {
+	binary := buildBinary(t)
+	for _, args := range [][]string{
+		{"serve", "--backoff-error-rate", "1.5"},
+		{"serve", "--backoff-error-rate", "-0.1"},
+		{"serve", "--backoff-latency", "-1s"},
+	} {
+		out, err := runCLI(t, binary, args...)
+		if err == nil {
+			t.Fatalf("%v: expected non-zero exit", args)
+		}
+		if result := parseJSON(t, out); result["status"] != "error" {
+			t.Errorf("%v: expected error status, got %v", args, result["status"])
+		}
+	}
+}
+
+func TestCLIServeRejectsPoolSettings(t *testing.T) {
+	binary := buildBinary(t)
+	for _, args := range [][]string{
+		{"serve", "--qdrant-connections", "0"},
+		{"serve", "--max-concurrent", "-1"},
+	} {
+		out, err := runCLI(t, binary, args...)
+		if err == nil {
+			t.Fatalf("%v: expected non-zero exit", args)
+		}
+		if result := parseJSON(t, out); result["status"] != "error" {
+			t.Errorf("%v: expected error status, got %v", args, result["status"])
+		}
+	}
+}
+
+func TestCLIServePoolStats(t *testing.T) {
+	binary := buildBinary(t)
+	// The pool connects lazily, so its stats are there without Qdrant.
+	base := startServe(t, binary, "serve", "--qdrant-connections", "2", "--max-concurrent", "5")
+
+	resp := getHTTP(t, base+"/pool", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var body struct {
+		Status string
+		Pool   store.PoolStats
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Status != "ok" || body.Pool.Connections != 2 || body.Pool.MaxConcurrent != 5 {
+		t.Errorf("unexpected pool stats %+v", body)
+	}
+}
+
+func TestCLIServeETags(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+
+	defer cleanupMemories(t)
+
+	add := func(text string) {
+		t.Helper()
+		if out, err := runCLI(t, binary, "add", "--no-merge", "--vector", "[0.1, 0.2, 0.3, 0.4]", "--payload", `{"text": "`+text+`"}`); err != nil {
+			t.Fatalf("add failed: %v\n%s", err, out)
+		}
+	}
+	add("deploys go out on tuesdays")
+	base := startServe(t, binary, "serve")
+
+	first := getHTTP(t, base+"/memories", "")
+	etag := first.Header.Get("ETag")
+	if first.StatusCode != http.StatusOK || !strings.HasPrefix(etag, `W/"`) {
+		t.Fatalf("expected 200 with a weak ETag, got %d %q", first.StatusCode, etag)
+	}
+	var listing map[string]any
+	json.NewDecoder(first.Body).Decode(&listing)
+	if listing["returned"] != 1.0 {
+		t.Errorf("expected one memory, got %v", listing)
+	}
+
+	if resp := getHTTP(t, base+"/memories", etag); resp.StatusCode != http.StatusNotModified {
+		t.Errorf("expected 304 for an unchanged collection, got %d", resp.StatusCode)
+	}
+	if resp := getHTTP(t, base+"/memories?limit=5", etag); resp.StatusCode != http.StatusOK {
+		t.Errorf("expected a different request to be served, got %d", resp.StatusCode)
+	}
+
+	add("deploys freeze in december")
+	changed := getHTTP(t, base+"/memories", etag)
+	if changed.StatusCode != http.StatusOK || changed.Header.Get("ETag") == etag {
+		t.Errorf("expected a new response after a write, got %d %q", changed.StatusCode, changed.Header.Get("ETag"))
+	}
+}
+
+func TestCLIServeWSRejectsForeignOrigin(t *testing.T) {
+	binary := buildBinary(t)
+	base := startServe(t, binary, "serve")
+	wsURL := "ws" + strings.TrimPrefix(base, "http") + "/ws"
+
+	if ws, err := websocket.Dial(wsURL, "", "http://evil.example"); err == nil {
+		ws.Close()
+		t.Fatal("expected a page from another origin to be refused")
+	}
+}
+
+func TestCLIServeWS(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+	// The fake embeds every query as [0.3, 0.1, 0.4, 0.1].
+	ollamaURL := fakeOllama(t).URL
+
+	defer cleanupMemories(t)
+
+	add := func(vector, text string) string {
+		t.Helper()
+		out, err := runCLI(t, binary, "add", "--no-merge", "--vector", vector, "--payload", `{"text": "`+text+`"}`)
+		if err != nil {
+			t.Fatalf("add failed: %v\n%s", err, out)
+		}
+		return parseJSON(t, out)["id"].(string)
+	}
+	add("[0.1, 0.1, 0.1, 0.9]", "the billing service is written in go")
+
+	base := startServe(t, binary, "--ollama-url", ollamaURL, "serve")
+	ws, err := websocket.Dial("ws"+strings.TrimPrefix(base, "http")+"/ws", "", base)
+	if err != nil {
+		t.Fatalf("dial /ws: %v", err)
+	}
+	defer ws.Close()
+	ws.SetDeadline(time.Now().Add(30 * time.Second))
+
+	receive := func() map[string]any {
+		t.Helper()
+		var msg map[string]any
+		if err := websocket.JSON.Receive(ws, &msg); err != nil {
+			t.Fatalf("receive: %v", err)
+		}
+		return msg
+	}
+
+	err = websocket.JSON.Send(ws, map[string]any{"action": "subscribe", "id": "certs", "query": "tls certs", "min_score": 0.9})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg := receive(); msg["event"] != "subscribed" || len(msg["results"].([]any)) != 0 {
+		t.Fatalf("expected an empty subscription, got %v", msg)
+	}
+
+	// Give the watcher a poll to take its first snapshot.
+	time.Sleep(3 * time.Second)
+	id := add("[0.3, 0.1, 0.4, 0.1]", "tls certs expire in june")
+
+	if msg := receive(); msg["event"] != "added" || msg["id"] != id {
+		t.Errorf("expected an added event for %s, got %v", id, msg)
+	}
+	msg := receive()
+	results, _ := msg["results"].([]any)
+	if msg["event"] != "match" || msg["subscription"] != "certs" || len(results) != 1 {
+		t.Errorf("expected a live match for the new memory, got %v", msg)
+	}
+}
+
+// sendHTTP sends a request with an optional Origin header.
+func sendHTTP(t *testing.T, method, url, origin string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, url, err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestCLIServeUIFlag(t *testing.T) {
+	binary := buildBinary(t)
+	id := "0b6f1c1e-8a43-4d7e-9a51-2f3c0f6f8d10"
+
+	plain := startServe(t, binary, "serve")
+	if resp := getHTTP(t, plain+"/", ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected no dashboard without --ui, got %d", resp.StatusCode)
+	}
+	if resp := sendHTTP(t, http.MethodDelete, plain+"/memories/"+id, ""); resp.StatusCode == http.StatusOK {
+		t.Error("expected deletes to be unavailable without --ui")
+	}
+
+	ui := startServe(t, binary, "serve", "--ui")
+	resp := getHTTP(t, ui+"/", "")
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		t.Errorf("expected the dashboard with --ui, got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	// Checked before Qdrant is asked anything.
+	if resp := sendHTTP(t, http.MethodPost, ui+"/memories/"+id+"/pin", "http://evil.example"); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected a cross-origin pin to be refused, got %d", resp.StatusCode)
+	}
+	if resp := sendHTTP(t, http.MethodDelete, ui+"/memories/not-an-id", ""); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected a bad ID to be rejected, got %d", resp.StatusCode)
+	}
+}
+
+func TestCLIServeUIActions(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+
+	defer cleanupMemories(t)
+
+	out, err := runCLI(t, binary, "add", "--no-merge", "--vector", "[0.1, 0.2, 0.3, 0.4]", "--payload", `{"text": "deploys go out on tuesdays"}`)
+	if err != nil {
+		t.Fatalf("add failed: %v\n%s", err, out)
+	}
+	id := parseJSON(t, out)["id"].(string)
+	base := startServe(t, binary, "serve", "--ui")
+	memory := base + "/memories/" + id
+
+	if resp := getHTTP(t, memory, ""); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected to inspect the memory, got %d", resp.StatusCode)
+	}
+	if resp := sendHTTP(t, http.MethodPost, memory+"/pin", ""); resp.StatusCode != http.StatusOK {
+		t.Fatalf("pin: got %d", resp.StatusCode)
+	}
+	if resp := sendHTTP(t, http.MethodDelete, memory, ""); resp.StatusCode != http.StatusConflict {
+		t.Errorf("expected a pinned memory to be kept, got %d", resp.StatusCode)
+	}
+	if resp := sendHTTP(t, http.MethodPost, memory+"/unpin", ""); resp.StatusCode != http.StatusOK {
+		t.Fatalf("unpin: got %d", resp.StatusCode)
+	}
+	if resp := sendHTTP(t, http.MethodDelete, memory, base); resp.StatusCode != http.StatusOK {
+		t.Fatalf("delete: got %d", resp.StatusCode)
+	}
+	if resp := getHTTP(t, memory, ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected the memory to be gone, got %d", resp.StatusCode)
+	}
+
+	var stats map[string]any
+	json.NewDecoder(getHTTP(t, base+"/stats", "").Body).Decode(&stats)
+	if report, _ := stats["report"].(map[string]any); report["total"] != 0.0 {
+		t.Errorf("expected an empty report, got %v", stats)
+	}
+}
+
+func TestCLIExportImportRejects(t *testing.T) {
+	binary := buildBinary(t)
+
+	// Flags and the export file are checked before connecting, so no
+	// services are needed.
+	bad := filepath.Join(t.TempDir(), "bad.jsonl")
+	if err := os.WriteFile(bad, []byte(`{"id":"x"}`+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"export"},
+		{"import"},
+		{"import", "--in", filepath.Join(t.TempDir(), "missing.jsonl")},
+		{"import", "--in", bad},
+	} {
+		out, err := runCLI(t, binary, args...)
+		if err == nil || parseJSON(t, out)["status"] != "error" {
+			t.Errorf("expected error for %v, got: %s", args, out)
+		}
+	}
+}
+
+func TestCLIExportImport(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+
+	cleanupMemories(t)
+	defer cleanupMemories(t)
+
+	add := func(args ...string) string {
+		t.Helper()
+		args = append([]string{"add", "--no-merge", "--vector", "[0.1, 0.2, 0.3, 0.4]"}, args...)
+		out, err := runCLI(t, binary, args...)
+		if err != nil {
+			t.Fatalf("add failed: %v\n%s", err, out)
+		}
+		return parseJSON(t, out)["id"].(string)
+	}
+	id := add("--payload", `{"text": "deploys go out on tuesdays"}`, "--type", "lesson")
+	add("--payload", `{"text": "alice's birthday is in may"}`, "--sensitivity", "personal")
+
+	dir := t.TempDir()
+	export := func(path string, args ...string) map[string]any {
+		t.Helper()
+		out, err := runCLI(t, binary, append([]string{"export", "--out", path}, args...)...)
+		if err != nil {
+			t.Fatalf("export failed: %v\n%s", err, out)
+		}
+		return parseJSON(t, out)
+	}
+	if res := export(filepath.Join(dir, "all.jsonl"), "--include-personal"); res["exported"] != float64(2) {
+		t.Errorf("expected 2 memories with --include-personal, got %v", res)
+	}
+	path := filepath.Join(dir, "memories.jsonl")
+	if res := export(path); res["exported"] != float64(1) || res["dimensions"] != float64(4) {
+		t.Errorf("expected the personal memory to be left out, got %v", res)
+	}
+
+	cleanupMemories(t)
+	out, err := runCLI(t, binary, "import", "--in", path)
+	if err != nil {
+		t.Fatalf("import failed: %v\n%s", err, out)
+	}
+	if res := parseJSON(t, out); res["imported"] != float64(1) || res["reembedded"] != false {
+		t.Errorf("unexpected import result %v", res)
+	}
+	out, err = runCLI(t, binary, "get", "--id", id)
+	if err != nil {
+		t.Fatalf("get failed: %v\n%s", err, out)
+	}
+	if payload := parseJSON(t, out)["payload"].(map[string]any); payload["type"] != "lesson" {
+		t.Errorf("expected the payload to be restored, got %v", payload)
+	}
+
+	// Vectors of another size don't fit without re-embedding.
+	other := filepath.Join(dir, "other.jsonl")
+	content := `{"clawbrain_export":1,"model":"other","dimensions":2}
+{"id":"4f8a7c1e-2b3d-4e5f-8a9b-0c1d2e3f4a5b","vector":[0.1,0.2],"payload":{"text":"standup is at 10:00"}}
+`
+	if err := os.WriteFile(other, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out, err = runCLI(t, binary, "import", "--in", other)
+	if err == nil || !strings.Contains(parseJSON(t, out)["message"].(string), "--reembed") {
+		t.Errorf("expected a dimension mismatch error, got: %s", out)
+	}
+}
+
+func TestCLIExportImportSyncState(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoRedis(t)
+
+	var embedded atomic.Int64
+	ollama := hashingOllama(t, &embedded)
+	filePath := filepath.Join(t.TempDir(), "deploys.md")
+	if err := os.WriteFile(filePath, []byte("# Deploys\n\nShip on tuesdays."), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cleanupRedisKey(t, "sync:"+filePath)
+	defer cleanupRedisKey(t, "sync:"+filePath)
+	run := func(dir string, args ...string) map[string]any {
+		t.Helper()
+		global := []string{"--backend", "file", "--path", dir, "--ollama-url", ollama.URL}
+		out, err := runCLI(t, binary, append(global, args...)...)
+		if err != nil {
+			t.Fatalf("%v failed: %v\n%s", args, err, out)
+		}
+		return parseJSON(t, out)
+	}
+
+	original := t.TempDir()
+	if res := run(original, "sync", "--file", filePath); res["added"] != 1.0 {
+		t.Fatalf("expected the file synced, got %v", res)
+	}
+	export := filepath.Join(t.TempDir(), "export.jsonl")
+	if res := run(original, "export", "--out", export); res["sync_files"] == nil || res["sync_files"].(float64) < 1 {
+		t.Fatalf("expected the sync state exported, got %v", res)
+	}
+
+	// A fresh instance: no memories, no sync state.
+	cleanupRedisKey(t, "sync:"+filePath)
+	restored := t.TempDir()
+	if res := run(restored, "import", "--in", export); res["imported"] != 1.0 || res["sync_restored"] == nil {
+		t.Fatalf("expected the memory and the sync state restored, got %v", res)
+	}
+	if res := run(restored, "sync", "--file", filePath); res["added"] != 0.0 || res["skipped"] != 1.0 {
+		t.Errorf("expected the imported file not to be ingested again, got %v", res)
+	}
+
+	cleanupRedisKey(t, "sync:"+filePath)
+	fresh := t.TempDir()
+	if res := run(fresh, "import", "--in", export, "--no-sync-state"); res["sync_restored"] != nil {
+		t.Errorf("expected --no-sync-state to leave the sync state alone, got %v", res)
+	}
+	if res := run(fresh, "sync", "--file", filePath); res["skipped"] != 0.0 {
+		t.Errorf("expected the file synced again without its sync state, got %v", res)
+	}
+}
+
+func TestCLIOptimize(t *testing.T) {
+	binary := buildBinary(t)
+	file := []string{"--backend", "file", "--path", t.TempDir()}
+
+	out, err := runCLI(t, binary, append(file, "optimize", "--status", "--no-wait")...)
+	if err == nil || parseJSON(t, out)["status"] != "error" {
+		t.Errorf("expected --status and --no-wait to be rejected, got %s", out)
+	}
+	out, err = runCLI(t, binary, append(file, "optimize", "--status")...)
+	if err != nil {
+		t.Fatalf("optimize --status failed: %v\n%s", err, out)
+	}
+	if index := parseJSON(t, out)["index"].(map[string]any); index["exists"] != false {
+		t.Errorf("expected no collection yet, got %v", index)
+	}
+
+	if out, err := runCLI(t, binary, append(file, "add", "--vector", "[1, 0, 0, 0]", "--text", "deploys go out on tuesdays")...); err != nil {
+		t.Fatalf("add failed: %v\n%s", err, out)
+	}
+	out, err = runCLI(t, binary, append(file, "optimize")...)
+	if err != nil {
+		t.Fatalf("optimize failed: %v\n%s", err, out)
+	}
+	res := parseJSON(t, out)
+	index := res["index"].(map[string]any)
+	if res["triggered"] != true || index["status"] != "green" || index["points"] != 1.0 || index["indexed_vectors"] != 1.0 {
+		t.Errorf("expected an optimized collection of one memory, got %s", out)
+	}
+}
+
+func TestCLIScoreHistogramRejects(t *testing.T) {
+	binary := buildBinary(t)
+
+	for _, args := range [][]string{
+		{"score-histogram"},
+		{"score-histogram", "--query", "deploys", "--buckets", "0"},
+		{"score-histogram", "--query", "deploys", "--buckets", "101"},
+		{"score-histogram", "--query", "deploys", "--type", "nonsense"},
+		{"score-histogram", "--vector", "not json"},
+	} {
+		out, err := runCLI(t, binary, args...)
+		if err == nil || parseJSON(t, out)["status"] != "error" {
+			t.Errorf("expected error for %v, got: %s", args, out)
+		}
+	}
+}
+
+func TestCLIScoreHistogram(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+
+	cleanupMemories(t)
+	defer cleanupMemories(t)
+
+	for _, vector := range []string{"[0.1, 0.2, 0.3, 0.4]", "[0.4, 0.3, 0.2, 0.1]", "[-0.1, -0.2, -0.3, -0.4]"} {
+		out, err := runCLI(t, binary, "add", "--no-merge", "--vector", vector, "--payload", `{"text": "memory"}`)
+		if err != nil {
+			t.Fatalf("add failed: %v\n%s", err, out)
+		}
+	}
+
+	out, err := runCLI(t, binary, "score-histogram", "--vector", "[0.1, 0.2, 0.3, 0.4]", "--buckets", "4")
+	if err != nil {
+		t.Fatalf("score-histogram failed: %v\n%s", err, out)
+	}
+	h := parseJSON(t, out)["histogram"].(map[string]any)
+	if h["count"] != float64(3) || h["below_zero"] != float64(1) {
+		t.Errorf("expected 3 scores with 1 below zero, got %v", h)
+	}
+	buckets := h["buckets"].([]any)
+	top := buckets[0].(map[string]any)
+	if len(buckets) != 4 || top["count"] != float64(1) || buckets[3].(map[string]any)["at_least"] != float64(2) {
+		t.Errorf("unexpected buckets %v", buckets)
+	}
+}
+
+func TestCLIAddBatchRejects(t *testing.T) {
+	binary := buildBinary(t)
+
+	// The file is checked before connecting, so no services are needed.
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	good := write("good.jsonl", `{"text": "deploys go out on tuesdays"}`+"\n")
+	for _, args := range [][]string{
+		{"add", "--batch-file", good, "--text", "standup is at 10:00"},
+		{"add", "--batch-file", good, "--alias", "deploys"},
+		{"add", "--batch-file", good, "--sensitivity", "secret"},
+		{"add", "--batch-file", filepath.Join(dir, "missing.jsonl")},
+		{"add", "--batch-file", write("empty.jsonl", "\n")},
+		{"add", "--batch-file", write("notext.jsonl", `{"payload": {"source": "chat"}}`)},
+		{"add", "--batch-file", write("badid.jsonl", `{"text": "a", "id": "deploy-notes"}`)},
+		{"add", "--batch-file", write("alias.jsonl", `{"text": "a", "payload": {"alias": "deploys"}}`)},
+		{"add", "--batch-file", write("twice.jsonl", `{"text": "a", "id": "4f8a7c1e-2b3d-4e5f-8a9b-0c1d2e3f4a5b"}
+{"text": "b", "id": "4f8a7c1e-2b3d-4e5f-8a9b-0c1d2e3f4a5b"}`)},
+		{"add", "--batch-file", write("badjson.jsonl", `{"text": "a"}`+"\n{")},
+	} {
+		out, err := runCLI(t, binary, args...)
+		if err == nil || parseJSON(t, out)["status"] != "error" {
+			t.Errorf("expected error for %v, got: %s", args, out)
+		}
+	}
+}
+
+func TestCLIAddBatch(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+
+	cleanupMemories(t)
+	defer cleanupMemories(t)
+
+	fixedID := "4f8a7c1e-2b3d-4e5f-8a9b-0c1d2e3f4a5b"
+	path := filepath.Join(t.TempDir(), "memories.jsonl")
+	content := `{"text": "deploys go out on tuesdays", "vector": [0.1, 0.2, 0.3, 0.4]}
+{"id": "` + fixedID + `", "vector": [0.4, 0.3, 0.2, 0.1], "payload": {"text": "standup is at 10:00", "type": "fact", "tags": ["schedule"]}}
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := runCLI(t, binary, "add", "--batch-file", path, "--no-merge", "--tag", "imported", "--type", "lesson")
+	if err != nil {
+		t.Fatalf("batch add failed: %v\n%s", err, out)
+	}
+	res := parseJSON(t, out)
+	ids, _ := res["ids"].([]any)
+	if res["added"] != float64(2) || len(ids) != 2 || ids[1] != fixedID {
+		t.Fatalf("unexpected batch result %v", res)
+	}
+
+	out, err = runCLI(t, binary, "get", "--id", fixedID)
+	if err != nil {
+		t.Fatalf("get failed: %v\n%s", err, out)
+	}
+	payload := parseJSON(t, out)["payload"].(map[string]any)
+	if payload["type"] != "fact" || len(payload["tags"].([]any)) != 2 {
+		t.Errorf("expected the line's type to win and tags to combine, got %v", payload)
+	}
+
+	// IDs in a batch must be new.
+	out, err = runCLI(t, binary, "add", "--batch-file", path, "--no-merge")
+	if err == nil || parseJSON(t, out)["status"] != "error" {
+		t.Errorf("expected an existing ID to fail the batch, got: %s", out)
+	}
+}
+
+func TestCLISeedRejects(t *testing.T) {
+	binary := buildBinary(t)
+
+	// Scenarios are generated before connecting, so no services are needed.
+	out, err := runCLI(t, binary, "seed", "--list")
+	if err != nil {
+		t.Fatalf("seed --list failed: %v\n%s", err, out)
+	}
+	if scenarios, _ := parseJSON(t, out)["scenarios"].([]any); len(scenarios) < 2 {
+		t.Errorf("expected the scenarios to be listed, got %s", out)
+	}
+	for _, args := range [][]string{
+		{"seed", "--scenario", "nonsense"},
+		{"seed", "--n", "0"},
+		{"seed", "--n", "100001"},
+		{"seed", "--vector-size", "-1"},
+	} {
+		out, err := runCLI(t, binary, args...)
+		if err == nil || parseJSON(t, out)["status"] != "error" {
+			t.Errorf("expected error for %v, got: %s", args, out)
+		}
+	}
+}
+
+func TestCLISeed(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+
+	cleanupMemories(t)
+	defer cleanupMemories(t)
+
+	seedCLI := func(args ...string) map[string]any {
+		t.Helper()
+		out, err := runCLI(t, binary, append([]string{"seed", "--vector-size", "4"}, args...)...)
+		if err != nil {
+			t.Fatalf("seed failed: %v\n%s", err, out)
+		}
+		return parseJSON(t, out)
+	}
+	if res := seedCLI("--n", "20"); res["seeded"] != float64(20) || res["embedded"] != false {
+		t.Fatalf("unexpected seed result %v", res)
+	}
+	// The same seed regenerates the same IDs, so a smaller run overlaps the
+	// first; --clear removes the rest.
+	if res := seedCLI("--n", "10", "--clear"); res["cleared"] != float64(20) || res["seeded"] != float64(10) {
+		t.Errorf("expected 20 cleared and 10 seeded, got %v", res)
+	}
+	if res := seedCLI("--n", "5", "--clear"); res["cleared"] != float64(10) {
+		t.Errorf("expected 10 cleared, got %v", res)
+	}
+}
+
+// writePNG writes a file that content sniffing recognizes as a PNG.
+func writePNG(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "shot.png")
+	if err := os.WriteFile(path, []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCLIAddImageFlags(t *testing.T) {
+	binary := buildBinary(t)
+	png := writePNG(t)
+	notImage := filepath.Join(t.TempDir(), "notes.txt")
+	if err := os.WriteFile(notImage, []byte("plain text"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"with text", []string{"add", "--image", png, "--text", "x"}},
+		{"with vector", []string{"add", "--image", png, "--vector", "[0.1, 0.2, 0.3, 0.4]"}},
+		{"caption without image", []string{"add", "--caption", "x", "--text", "x"}},
+		{"missing file", []string{"add", "--image", filepath.Join(t.TempDir(), "nope.png")}},
+		{"not an image", []string{"add", "--image", notImage}},
+		// Captioning runs before connecting to Qdrant.
+		{"vision model unreachable", []string{"--ollama-url", "http://127.0.0.1:1", "add", "--image", png}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := runCLI(t, binary, tt.args...)
+			if err == nil {
+				t.Fatalf("expected error\n%s", out)
+			}
+			if parseJSON(t, out)["status"] != "error" {
+				t.Errorf("expected status error\n%s", out)
+			}
+		})
+	}
+}
+
+func TestCLIAddImage(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+	ollamaURL := fakeOllama(t).URL
+
+	defer cleanupMemories(t)
+
+	png := writePNG(t)
+	out, err := runCLI(t, binary, "--ollama-url", ollamaURL, "add", "--image", png)
+	if err != nil {
+		t.Fatalf("add --image failed: %v\n%s", err, out)
+	}
+	added := parseJSON(t, out)
+	if added["caption"] != fakeCaption || added["image"] != png {
+		t.Fatalf("expected caption and image path in response, got %v", added)
+	}
+
+	// A second screenshot with an identical caption is kept, not merged.
+	out, err = runCLI(t, binary, "--ollama-url", ollamaURL, "add", "--image", png, "--caption", "the settings page")
+	if err != nil {
+		t.Fatalf("add --image --caption failed: %v\n%s", err, out)
+	}
+	if second := parseJSON(t, out); second["caption"] != "the settings page" || second["merged_id"] != nil {
+		t.Errorf("expected manual caption and no merge, got %v", second)
+	}
+
+	out, err = runCLI(t, binary, "--ollama-url", ollamaURL, "search", "--query", "session token error", "--limit", "5")
+	if err != nil {
+		t.Fatalf("search failed: %v\n%s", err, out)
+	}
+	results, _ := parseJSON(t, out)["results"].([]any)
+	if len(results) != 2 {
+		t.Fatalf("expected both image memories, got %v", results)
+	}
+	payload := results[0].(map[string]any)["payload"].(map[string]any)
+	if payload["image"] != png || payload["image_type"] != "image/png" || payload["image_sha256"] == nil {
+		t.Errorf("expected image reference in payload, got %v", payload)
+	}
+}
+
+func TestCLIAddDryRun(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+
+	cleanupMemories(t)
+	defer cleanupMemories(t)
+
+	out, err := runCLI(t, binary, "add", "--vector", "[0.1, 0.2, 0.3, 0.4]",
+		"--payload", `{"text": "deploys go out on tuesdays"}`, "--alias", "deploy-day")
+	if err != nil {
+		t.Fatalf("add failed: %v\n%s", err, out)
+	}
+	id := parseJSON(t, out)["id"].(string)
+
+	out, err = runCLI(t, binary, "add", "--dry-run", "--vector", "[0.1, 0.2, 0.3, 0.41]",
+		"--payload", `{"text": "deploys go out on thursdays"}`)
+	if err != nil {
+		t.Fatalf("add --dry-run failed: %v\n%s", err, out)
+	}
+	result := parseJSON(t, out)
+	if result["dry_run"] != true || result["action"] != "create" {
+		t.Fatalf("expected a create preview, got %v", result)
+	}
+	merge, _ := result["would_merge"].([]any)
+	if len(merge) != 1 || merge[0].(map[string]any)["id"] != id {
+		t.Errorf("expected the preview to merge %s, got %v", id, result["would_merge"])
+	}
+	// The merged memory's alias would carry over.
+	if result["alias"] != "deploy-day" {
+		t.Errorf("expected the alias to be inherited, got %v", result["alias"])
+	}
+
+	out, err = runCLI(t, binary, "add", "--dry-run", "--id", id, "--no-merge",
+		"--vector", "[0.1, 0.2, 0.3, 0.4]", "--payload", `{"text": "deploys go out on fridays"}`)
+	if err != nil {
+		t.Fatalf("add --dry-run --id failed: %v\n%s", err, out)
+	}
+	result = parseJSON(t, out)
+	if result["action"] != "update" || result["revision"] != 2.0 {
+		t.Errorf("expected an update to revision 2, got %v", result)
+	}
+
+	out, err = runCLI(t, binary, "add", "--dry-run", "--id", id, "--if-version", "5",
+		"--vector", "[0.1, 0.2, 0.3, 0.4]", "--payload", `{"text": "deploys go out on fridays"}`)
+	if err == nil || parseJSON(t, out)["status"] != "conflict" {
+		t.Errorf("expected the preview to report the conflict, got %s", out)
+	}
+
+	// Nothing was written.
+	out, err = runCLI(t, binary, "get", "--id", id)
+	if err != nil {
+		t.Fatalf("get failed: %v\n%s", err, out)
+	}
+	payload := parseJSON(t, out)["payload"].(map[string]any)
+	if payload["text"] != "deploys go out on tuesdays" || payload["revision"] != 1.0 {
+		t.Errorf("expected the memory untouched, got %v", payload)
+	}
+}
+
+func TestCLIAddVerbose(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+
+	cleanupMemories(t)
+	defer cleanupMemories(t)
+
+	ids := make(map[string]string)
+	for _, m := range []struct{ name, vector string }{
+		{"pinned", "[0.1, 0.2, 0.3, 0.4]"},
+		{"distant", "[0.4, 0.3, 0.2, 0.1]"},
+	} {
+		args := []string{"add", "--no-merge", "--vector", m.vector, "--payload", fmt.Sprintf(`{"text": %q}`, m.name)}
+		if m.name == "pinned" {
+			args = append(args, "--pinned")
+		}
+		out, err := runCLI(t, binary, args...)
+		if err != nil {
+			t.Fatalf("add failed: %v\n%s", err, out)
+		}
+		ids[m.name] = parseJSON(t, out)["id"].(string)
+	}
+
+	out, err := runCLI(t, binary, "add", "--verbose", "--vector", "[0.1, 0.2, 0.3, 0.41]",
+		"--payload", `{"text": "near the pinned one"}`)
+	if err != nil {
+		t.Fatalf("add --verbose failed: %v\n%s", err, out)
+	}
+	result := parseJSON(t, out)
+	if _, merged := result["merged_id"]; merged {
+		t.Errorf("expected nothing merged, got %v", result)
+	}
+	dedup, _ := result["dedup"].(map[string]any)
+	candidates, _ := dedup["candidates"].([]any)
+	decisions := make(map[string]any)
+	for _, c := range candidates {
+		c := c.(map[string]any)
+		decisions[c["id"].(string)] = c["decision"]
+	}
+	if decisions[ids["pinned"]] != "pinned" || decisions[ids["distant"]] != "below_threshold" {
+		t.Errorf("unexpected decisions %v for %v", decisions, ids)
+	}
+	payload, _ := result["payload"].(map[string]any)
+	if payload["text"] != "near the pinned one" || payload["last_accessed"] == nil {
+		t.Errorf("expected the stored payload, got %v", result["payload"])
+	}
+}
+
+func TestCLIDelete(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+
+	defer cleanupMemories(t)
+
+	// Add a memory
+	out, err := runCLI(t, binary, "add",
+		"--vector", "[0.1, 0.2, 0.3, 0.4]",
+		"--payload", `{"text": "will be deleted"}`,
+	)
+	if err != nil {
+		t.Fatalf("add failed: %v\n%s", err, out)
+	}
+
+	// Delete with 0 days — should archive everything
+	out, err = runCLI(t, binary, "delete",
+		"-d", "0",
+	)
+	if err != nil {
+		t.Fatalf("delete failed: %v\n%s", err, out)
+	}
+
+	result := parseJSON(t, out)
+	if result["status"] != "ok" {
+		t.Fatalf("expected status ok, got %v", result["status"])
+	}
+	archived, ok := result["archived"].(float64)
+	if !ok || archived < 1 || result["deleted"] != 0.0 {
+		t.Fatalf("expected at least 1 archived and none deleted, got %v", result)
+	}
+
+	// Archived memories are still there for --hard to delete.
+	out, err = runCLI(t, binary, "delete", "-d", "0", "--hard")
+	if err != nil {
+		t.Fatalf("delete --hard failed: %v\n%s", err, out)
+	}
+	if deleted, _ := parseJSON(t, out)["deleted"].(float64); deleted < 1 {
+		t.Fatalf("expected at least 1 deletion, got %s", out)
+	}
+
+	// Verify empty via search
+	out, err = runCLI(t, binary, "search",
+		"--vector", "[0.1, 0.2, 0.3, 0.4]",
+		"--include-archived",
+		"--limit", "10",
+	)
+	if err != nil {
+		t.Fatalf("search failed: %v\n%s", err, out)
+	}
+
+	searchResult := parseJSON(t, out)
+	returned, _ := searchResult["returned"].(float64)
+	if returned != 0 {
+		t.Errorf("expected 0 results after delete, got %v", returned)
+	}
+}
+
+func TestCLIDeleteDryRun(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+
+	cleanupMemories(t)
+	defer cleanupMemories(t)
+
+	out, err := runCLI(t, binary, "add", "--vector", "[0.1, 0.2, 0.3, 0.4]",
+		"--payload", `{"text": "would be deleted"}`)
+	if err != nil {
+		t.Fatalf("add failed: %v\n%s", err, out)
+	}
+	id := parseJSON(t, out)["id"].(string)
+
+	out, err = runCLI(t, binary, "delete", "-d", "0", "--dry-run")
+	if err != nil {
+		t.Fatalf("delete --dry-run failed: %v\n%s", err, out)
+	}
+	result := parseJSON(t, out)
+	listed, _ := result["memories"].([]any)
+	if result["dry_run"] != true || result["would_archive"] != 1.0 || len(listed) != 1 {
+		t.Fatalf("expected one memory listed, got %v", result)
+	}
+	if entry := listed[0].(map[string]any); entry["id"] != id || entry["text"] != "would be deleted" {
+		t.Errorf("unexpected entry %v", entry)
+	}
+
+	if out, err := runCLI(t, binary, "get", "--id", id); err != nil {
+		t.Errorf("expected the memory to survive a dry run: %v\n%s", err, out)
+	}
+}
+
+func TestCLIDeleteFrequencyWeight(t *testing.T) {
+	binary := buildBinary(t)
+	global := []string{"--backend", "file", "--path", t.TempDir()}
+
+	// Both untouched for ten days; only one was ever recalled. Import keeps
+	// timestamps and counts where add would stamp them.
+	const idle, recalled = "4f8a7c1e-2b3d-4e5f-8a9b-0c1d2e3f4a5b", "9e2a7c1e-2b3d-4e5f-8a9b-0c1d2e3f4a5b"
+	accessed := time.Now().UTC().Add(-10 * 24 * time.Hour).Format(time.RFC3339)
+	export := filepath.Join(t.TempDir(), "export.jsonl")
+	lines := `{"clawbrain_export":1,"model":"all-minilm","dimensions":4}
+{"id":"` + idle + `","vector":[0.1,0.2,0.3,0.4],"payload":{"text":"never looked at again","last_accessed":"` + accessed + `"}}
+{"id":"` + recalled + `","vector":[0.4,0.3,0.2,0.1],"payload":{"text":"leaned on for months","last_accessed":"` + accessed + `","access_count":3}}
+`
+	if err := os.WriteFile(export, []byte(lines), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := runCLI(t, binary, append(global, "import", "--in", export)...); err != nil {
+		t.Fatalf("import failed: %v\n%s", err, out)
+	}
+
+	out, err := runCLI(t, binary, append(global, "delete", "-d", "7", "--frequency-weight", "0", "--dry-run")...)
+	if err != nil {
+		t.Fatalf("delete --dry-run failed: %v\n%s", err, out)
+	}
+	if result := parseJSON(t, out); result["would_archive"] != 2.0 {
+		t.Errorf("expected weight 0 to go on last_accessed alone, got %v", result)
+	}
+
+	// At the default weight of 1, three recalls stretch 7 days to 21.
+	out, err = runCLI(t, binary, append(global, "delete", "-d", "7")...)
+	if err != nil {
+		t.Fatalf("delete failed: %v\n%s", err, out)
+	}
+	if result := parseJSON(t, out); result["archived"] != 1.0 || result["frequency_weight"] != 1.0 {
+		t.Fatalf("expected only the idle memory archived, got %v", result)
+	}
+	if out, err := runCLI(t, binary, append(global, "get", "--id", recalled)...); err != nil {
+		t.Errorf("expected the recalled memory to survive: %v\n%s", err, out)
+	}
+
+	for _, args := range [][]string{
+		{"delete", "--frequency-weight", "-1"},
+		{"delete", "--id", recalled, "--frequency-weight", "2"},
+		{"delete", "--id", recalled, "--hard"},
+	} {
+		if out, err := runCLI(t, binary, append(global, args...)...); err == nil {
+			t.Errorf("expected %v to fail, got: %s", args, out)
+		}
+	}
+}
+
+func TestCLIAddSearchPreservesPayload(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+
+	defer cleanupMemories(t)
+
+	// Add with rich payload
+	out, err := runCLI(t, binary, "add",
+		"--vector", "[0.1, 0.2, 0.3, 0.4]",
+		"--payload", `{"text": "preserved", "count": 42, "active": true}`,
+	)
+	if err != nil {
+		t.Fatalf("add failed: %v\n%s", err, out)
+	}
+
+	// Search and check payload fields
+	out, err = runCLI(t, binary, "search",
+		"--vector", "[0.1, 0.2, 0.3, 0.4]",
+		"--min-score", "0.9",
+	)
+	if err != nil {
+		t.Fatalf("search failed: %v\n%s", err, out)
+	}
+
+	result := parseJSON(t, out)
+	results := result["results"].([]any)
+	if len(results) == 0 {
+		t.Fatal("expected at least 1 result")
+	}
+
+	payload := results[0].(map[string]any)["payload"].(map[string]any)
+
+	if payload["text"] != "preserved" {
+		t.Errorf("expected text 'preserved', got %v", payload["text"])
+	}
+	if payload["count"] != float64(42) { // JSON numbers are float64
+		t.Errorf("expected count 42, got %v", payload["count"])
+	}
+	if payload["active"] != true {
+		t.Errorf("expected active true, got %v", payload["active"])
+	}
+	// Verify auto-injected timestamps exist
+	if payload["created_at"] == nil {
+		t.Error("missing created_at in payload")
+	}
+	if payload["last_accessed"] == nil {
+		t.Error("missing last_accessed in payload")
+	}
+}
+
+func TestCLIDeleteInvalidDays(t *testing.T) {
+	binary := buildBinary(t)
+
+	_, err := runCLI(t, binary, "delete",
+		"-d", "not-a-number",
+	)
+	if err == nil {
+		t.Fatal("expected error for invalid -d value")
+	}
+}
+
+func TestCLIDeleteTargetedRejects(t *testing.T) {
+	binary := buildBinary(t)
+
+	const id = "4f8a7c1e-2b3d-4e5f-8a9b-0c1d2e3f4a5b"
+	// All checked before connecting, so no services are needed.
+	for _, args := range [][]string{
+		{"--id", id, "-d", "7"},
+		{"--id", "not-a-uuid"},
+		{"--ids", id + ",nope"},
+		{"--filter", "source"},
+		{"--id", id, "--include-pinned"},
+		{"--ids", id, "--if-version", "1"},
+		{"--id", id, "--filter", "source=chat", "--if-version", "1"},
+		{"--if-version", "1"},
+	} {
+		if _, err := runCLI(t, binary, append([]string{"delete"}, args...)...); err == nil {
+			t.Errorf("expected %v to be rejected", args)
+		}
+	}
+}
+
+func TestCLIDeleteByIDAndFilter(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+
+	defer cleanupMemories(t)
+
+	add := func(vector, payload string, extra ...string) string {
+		t.Helper()
+		out, err := runCLI(t, binary, append([]string{"add", "--no-merge", "--vector", vector, "--payload", payload}, extra...)...)
+		if err != nil {
+			t.Fatalf("add failed: %v\n%s", err, out)
+		}
+		return parseJSON(t, out)["id"].(string)
+	}
+	one := add("[0.1, 0.2, 0.3, 0.4]", `{"text": "one", "source": "import"}`)
+	two := add("[0.4, 0.3, 0.2, 0.1]", `{"text": "two", "source": "import"}`, "--pinned")
+	keep := add("[0.2, 0.2, 0.2, 0.2]", `{"text": "keep", "source": "chat"}`)
+
+	// A filter spares pinned memories unless told otherwise.
+	out, err := runCLI(t, binary, "delete", "--filter", "source=import", "--dry-run")
+	if err != nil {
+		t.Fatalf("delete --dry-run failed: %v\n%s", err, out)
+	}
+	result := parseJSON(t, out)
+	if result["would_delete"] != 1.0 || result["skipped_pinned"] != 1.0 {
+		t.Errorf("expected one deletable and one pinned match, got %v", result)
+	}
+
+	out, err = runCLI(t, binary, "delete", "--filter", "source=import")
+	if err != nil || parseJSON(t, out)["deleted"] != 1.0 {
+		t.Fatalf("expected one memory deleted: %v\n%s", err, out)
+	}
+	if out, _ := runCLI(t, binary, "get", "--id", one); parseJSON(t, out)["status"] != "error" {
+		t.Errorf("expected %s to be gone", one)
+	}
+
+	// Naming a pinned memory deletes it; a missing ID is reported.
+	const gone = "00000000-0000-0000-0000-000000000000"
+	out, err = runCLI(t, binary, "delete", "--ids", two+","+gone)
+	if err != nil {
+		t.Fatalf("delete --ids failed: %v\n%s", err, out)
+	}
+	result = parseJSON(t, out)
+	if result["deleted"] != 1.0 || len(result["missing"].([]any)) != 1 {
+		t.Errorf("expected the pinned memory deleted and one missing, got %v", result)
+	}
+
+	out, err = runCLI(t, binary, "delete", "--id", keep, "--if-version", "7")
+	if err == nil || parseJSON(t, out)["status"] != "conflict" {
+		t.Errorf("expected a stale --if-version to conflict\n%s", out)
+	}
+	if out, _ := runCLI(t, binary, "get", "--id", keep); parseJSON(t, out)["status"] != "ok" {
+		t.Errorf("expected %s to survive the conflict", keep)
+	}
+}
+
+func TestCLIInvalidVectorJSON(t *testing.T) {
+	binary := buildBinary(t)
+
+	out, err := runCLI(t, binary, "add",
+		"--vector", "not json",
+		"--payload", "{}",
+	)
+	if err == nil {
+		t.Fatal("expected error for invalid vector JSON")
+	}
+	result := parseJSON(t, out)
+	if result["status"] != "error" {
+		t.Errorf("expected status error, got %v", result["status"])
+	}
+}
+
+func TestCLIInvalidPayloadJSON(t *testing.T) {
+	binary := buildBinary(t)
+
+	out, err := runCLI(t, binary, "add",
+		"--vector", "[0.1]",
+		"--payload", "not json",
+	)
+	if err == nil {
+		t.Fatal("expected error for invalid payload JSON")
+	}
+	result := parseJSON(t, out)
+	if result["status"] != "error" {
+		t.Errorf("expected status error, got %v", result["status"])
+	}
+}
+
+// --- Get command tests ---
+
+func TestCLIGetMissingFlags(t *testing.T) {
+	binary := buildBinary(t)
+
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"no flags", []string{"get"}},
+		{"missing id", []string{"get"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := runCLI(t, binary, tt.args...)
+			if err == nil {
+				t.Fatal("expected error for missing required flags")
+			}
+		})
+	}
+}
+
+func TestCLIGetByID(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+
+	defer cleanupMemories(t)
+
+	customID := "11111111-2222-3333-4444-555555555555"
+
+	// Add a memory with a known ID
+	out, err := runCLI(t, binary, "add",
+		"--vector", "[0.1, 0.2, 0.3, 0.4]",
+		"--payload", `{"text": "get me by id", "tag": "test"}`,
+		"--id", customID,
+	)
+	if err != nil {
+		t.Fatalf("add failed: %v\n%s", err, out)
+	}
+
+	// Get by ID
+	out, err = runCLI(t, binary, "get",
+		"--id", customID,
+	)
+	if err != nil {
+		t.Fatalf("get failed: %v\n%s", err, out)
+	}
+
+	result := parseJSON(t, out)
+	if result["status"] != "ok" {
+		t.Fatalf("expected status ok, got %v", result["status"])
+	}
+	if result["id"] != customID {
+		t.Errorf("expected id %q, got %v", customID, result["id"])
+	}
+
+	payload, ok := result["payload"].(map[string]any)
+	if !ok {
+		t.Fatal("expected payload to be a map")
+	}
+	if payload["text"] != "get me by id" {
+		t.Errorf("expected text 'get me by id', got %v", payload["text"])
+	}
+	if payload["tag"] != "test" {
+		t.Errorf("expected tag 'test', got %v", payload["tag"])
+	}
+	if payload["created_at"] == nil {
+		t.Error("missing created_at in payload")
+	}
+	if payload["last_accessed"] == nil {
+		t.Error("missing last_accessed in payload")
+	}
+}
+
+func TestCLIGetNotFound(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+
+	defer cleanupMemories(t)
+
+	// Add something so the collection exists
+	out, err := runCLI(t, binary, "add",
+		"--vector", "[0.1, 0.2, 0.3, 0.4]",
+		"--payload", `{"text": "placeholder"}`,
+	)
+	if err != nil {
+		t.Fatalf("add failed: %v\n%s", err, out)
+	}
+
+	// Try to get a nonexistent ID
+	out, err = runCLI(t, binary, "get",
+		"--id", "00000000-0000-0000-0000-000000000000",
+	)
+	if err == nil {
+		t.Fatal("expected error for nonexistent memory")
+	}
+
+	result := parseJSON(t, out)
+	if result["status"] != "error" {
+		t.Errorf("expected status error, got %v", result["status"])
+	}
+}
+
+func TestCLIGetIDAndAliasExclusive(t *testing.T) {
+	binary := buildBinary(t)
+
+	out, err := runCLI(t, binary, "get", "--id", "11111111-2222-3333-4444-555555555555", "--alias", "deploy-checklist")
+	if err == nil {
+		t.Fatal("expected error when both --id and --alias are given")
+	}
+	result := parseJSON(t, out)
+	if result["status"] != "error" {
+		t.Errorf("expected status error, got %v", result["status"])
+	}
+}
+
+func TestCLIAddInvalidAlias(t *testing.T) {
+	binary := buildBinary(t)
+
+	out, err := runCLI(t, binary, "add",
+		"--vector", "[0.1, 0.2, 0.3, 0.4]",
+		"--payload", `{"text": "aliased"}`,
+		"--alias", "not a valid alias",
+	)
+	if err == nil {
+		t.Fatal("expected error for invalid alias")
+	}
+	result := parseJSON(t, out)
+	if result["status"] != "error" {
+		t.Errorf("expected status error, got %v", result["status"])
+	}
+}
+
+func TestCLIAlias(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+
+	defer cleanupMemories(t)
+
+	out, err := runCLI(t, binary, "add",
+		"--vector", "[0.1, 0.2, 0.3, 0.4]",
+		"--payload", `{"text": "deploy checklist v1"}`,
+		"--alias", "deploy-checklist",
+	)
+	if err != nil {
+		t.Fatalf("add failed: %v\n%s", err, out)
+	}
+	first := parseJSON(t, out)
+	if first["alias"] != "deploy-checklist" {
+		t.Errorf("expected alias in add response, got %v", first["alias"])
+	}
+
+	out, err = runCLI(t, binary, "get", "--alias", "deploy-checklist")
+	if err != nil {
+		t.Fatalf("get --alias failed: %v\n%s", err, out)
+	}
+	result := parseJSON(t, out)
+	if result["id"] != first["id"] {
+		t.Errorf("expected alias to resolve to %v, got %v", first["id"], result["id"])
+	}
+
+	// Re-using the alias on an unrelated memory moves it there.
+	out, err = runCLI(t, binary, "add",
+		"--vector", "[0.9, -0.9, 0.9, -0.9]",
+		"--payload", `{"text": "deploy checklist v2"}`,
+		"--alias", "deploy-checklist",
+	)
+	if err != nil {
+		t.Fatalf("second add failed: %v\n%s", err, out)
+	}
+	second := parseJSON(t, out)
+	moved, _ := second["alias_moved_from"].([]any)
+	if len(moved) != 1 || moved[0] != first["id"] {
+		t.Errorf("expected alias_moved_from [%v], got %v", first["id"], second["alias_moved_from"])
+	}
+
+	out, err = runCLI(t, binary, "get", "--alias", "deploy-checklist")
+	if err != nil {
+		t.Fatalf("get --alias failed: %v\n%s", err, out)
+	}
+	result = parseJSON(t, out)
+	if result["id"] != second["id"] {
+		t.Errorf("expected alias to resolve to %v, got %v", second["id"], result["id"])
+	}
+
+	out, err = runCLI(t, binary, "get", "--alias", "no-such-alias")
+	if err == nil {
+		t.Fatal("expected error for unknown alias")
+	}
+	if parseJSON(t, out)["status"] != "error" {
+		t.Errorf("expected status error for unknown alias")
+	}
+}
+
+func TestCLILockMissingFlags(t *testing.T) {
+	binary := buildBinary(t)
+
+	for _, cmd := range []string{"lock", "unlock"} {
+		t.Run(cmd, func(t *testing.T) {
+			if _, err := runCLI(t, binary, cmd); err == nil {
+				t.Fatalf("expected error for %s without --id or --alias", cmd)
+			}
+		})
+	}
+}
+
+func TestCLILock(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+
+	defer cleanupMemories(t)
+
+	lockedID := "22222222-3333-4444-5555-666666666666"
+	out, err := runCLI(t, binary, "add",
+		"--vector", "[0.1, 0.2, 0.3, 0.4]",
+		"--payload", `{"text": "never force-push to main"}`,
+		"--id", lockedID,
+		"--alias", "safety-rule",
+	)
+	if err != nil {
+		t.Fatalf("add failed: %v\n%s", err, out)
+	}
+
+	out, err = runCLI(t, binary, "lock", "--alias", "safety-rule")
+	if err != nil {
+		t.Fatalf("lock failed: %v\n%s", err, out)
+	}
+	if result := parseJSON(t, out); result["locked"] != true || result["id"] != lockedID {
+		t.Fatalf("unexpected lock response: %v", result)
+	}
+
+	// Overwriting by ID is refused.
+	out, err = runCLI(t, binary, "add",
+		"--vector", "[0.5, 0.5, 0.5, 0.5]",
+		"--payload", `{"text": "force-push whenever"}`,
+		"--id", lockedID,
+	)
+	if err == nil {
+		t.Fatalf("expected add over a locked memory to fail\n%s", out)
+	}
+
+	// An identical memory does not merge the locked one away.
+	out, err = runCLI(t, binary, "add",
+		"--vector", "[0.1, 0.2, 0.3, 0.4]",
+		"--payload", `{"text": "never force-push to main (again)"}`,
+	)
+	if err != nil {
+		t.Fatalf("duplicate add failed: %v\n%s", err, out)
+	}
+	if merged, ok := parseJSON(t, out)["merged_ids"]; ok {
+		t.Fatalf("expected locked memory to be exempt from dedup, got merged_ids %v", merged)
+	}
+
+	// Deletion skips it; only the unlocked duplicate goes.
+	out, err = runCLI(t, binary, "delete", "-d", "0", "--hard")
+	if err != nil {
+		t.Fatalf("delete failed: %v\n%s", err, out)
+	}
+	if deleted, _ := parseJSON(t, out)["deleted"].(float64); deleted != 1 {
+		t.Fatalf("expected 1 deletion (unlocked only), got %v", deleted)
+	}
+
+	out, err = runCLI(t, binary, "unlock", "--id", lockedID)
+	if err != nil {
+		t.Fatalf("unlock failed: %v\n%s", err, out)
+	}
+	if parseJSON(t, out)["locked"] != false {
+		t.Fatalf("expected locked=false after unlock\n%s", out)
+	}
+
+	out, err = runCLI(t, binary, "delete", "-d", "0", "--hard")
+	if err != nil {
+		t.Fatalf("delete failed: %v\n%s", err, out)
+	}
+	if deleted, _ := parseJSON(t, out)["deleted"].(float64); deleted != 1 {
+		t.Fatalf("expected the unlocked memory to be deleted, got %v", deleted)
+	}
+}
+
+func TestCLIAddPolicyRejects(t *testing.T) {
+	binary := buildBinary(t)
+
+	cfg := filepath.Join(t.TempDir(), "clawbrain.json")
+	policy := `{"policy": {"max_text_length": 10, "required_fields": {"*": ["source"]}, "banned_patterns": ["(?i)password"]}}`
+	if err := os.WriteFile(cfg, []byte(policy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Policy is enforced before connecting, so no services are needed.
+	out, err := runCLI(t, binary, "--config", cfg, "add",
+		"--vector", "[0.1, 0.2, 0.3, 0.4]",
+		"--payload", `{"text": "the admin password is hunter2"}`,
+	)
+	if err == nil {
+		t.Fatalf("expected policy rejection\n%s", out)
+	}
+
+	result := parseJSON(t, out)
+	if result["status"] != "rejected" {
+		t.Fatalf("expected status rejected, got %v", result["status"])
+	}
+	violations, _ := result["violations"].([]any)
+	got := map[string]bool{}
+	for _, v := range violations {
+		got[v.(map[string]any)["rule"].(string)] = true
+	}
+	for _, rule := range []string{"max_text_length", "required_field", "banned_pattern"} {
+		if !got[rule] {
+			t.Errorf("expected a %s violation, got %v", rule, violations)
+		}
+	}
+}
+
+func TestCLIInvalidConfig(t *testing.T) {
+	binary := buildBinary(t)
+
+	out, err := runCLI(t, binary, "--config", filepath.Join(t.TempDir(), "missing.json"), "add",
+		"--vector", "[0.1, 0.2, 0.3, 0.4]",
+		"--payload", `{"text": "anything"}`,
+	)
+	if err == nil {
+		t.Fatal("expected error for missing config file")
+	}
+	if parseJSON(t, out)["status"] != "error" {
+		t.Errorf("expected status error\n%s", out)
+	}
+}
+
+// --- Text mode tests (require both Qdrant and Ollama) ---
+
+func TestCLITextAddAndSearch(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+	skipIfNoOllama(t)
+
+	defer cleanupMemories(t)
+
+	// Add a text memory (embedding happens via Ollama)
+	out, err := runCLI(t, binary, "add",
+		"--text", "the user prefers dark mode for coding",
+	)
+	if err != nil {
+		t.Fatalf("add text failed: %v\n%s", err, out)
+	}
+
+	addResult := parseJSON(t, out)
+	if addResult["status"] != "ok" {
+		t.Fatalf("expected status ok, got %v", addResult["status"])
+	}
+	if addResult["id"] == nil || addResult["id"] == "" {
+		t.Fatal("expected non-empty id")
+	}
+
+	// Search by text query (query is also embedded via Ollama)
+	out, err = runCLI(t, binary, "search",
+		"--query", "dark mode",
+		"--limit", "5",
+	)
+	if err != nil {
+		t.Fatalf("search text failed: %v\n%s", err, out)
+	}
+
+	searchResult := parseJSON(t, out)
+	if searchResult["status"] != "ok" {
+		t.Fatalf("expected status ok, got %v", searchResult["status"])
+	}
+	returned, ok := searchResult["returned"].(float64)
+	if !ok || returned < 1 {
+		t.Fatalf("expected at least 1 result, got %v", searchResult["returned"])
+	}
+
+	// Verify the text is in the payload and score is present
+	results := searchResult["results"].([]any)
+	firstResult := results[0].(map[string]any)
+	payload := firstResult["payload"].(map[string]any)
+	if payload["text"] != "the user prefers dark mode for coding" {
+		t.Errorf("expected text in payload, got %v", payload["text"])
+	}
+	if _, ok := firstResult["score"].(float64); !ok {
+		t.Error("expected score in result")
+	}
+}
+
+func TestCLITextAddWithPayload(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+	skipIfNoOllama(t)
+
+	defer cleanupMemories(t)
+
+	// Add text with extra payload
+	out, err := runCLI(t, binary, "add",
+		"--text", "golang is great for cli tools",
+		"--payload", `{"source": "conversation", "confidence": 0.9}`,
+	)
+	if err != nil {
+		t.Fatalf("add text failed: %v\n%s", err, out)
+	}
+
+	addResult := parseJSON(t, out)
+	if addResult["status"] != "ok" {
+		t.Fatalf("expected status ok, got %v", addResult["status"])
+	}
+
+	// Search and check payload
+	out, err = runCLI(t, binary, "search",
+		"--query", "golang",
+		"--limit", "5",
+	)
+	if err != nil {
+		t.Fatalf("search failed: %v\n%s", err, out)
+	}
+
+	result := parseJSON(t, out)
+	results := result["results"].([]any)
+	if len(results) == 0 {
+		t.Fatal("expected at least 1 result")
+	}
+
+	payload := results[0].(map[string]any)["payload"].(map[string]any)
+	if payload["source"] != "conversation" {
+		t.Errorf("expected source 'conversation', got %v", payload["source"])
+	}
+	if payload["text"] != "golang is great for cli tools" {
+		t.Errorf("expected text preserved, got %v", payload["text"])
+	}
+	if payload["created_at"] == nil {
+		t.Error("missing created_at")
+	}
+	if payload["last_accessed"] == nil {
+		t.Error("missing last_accessed")
+	}
+}
+
+func TestCLITextAddMissingText(t *testing.T) {
+	binary := buildBinary(t)
+
+	// No --text and no --vector should fail
+	_, err := runCLI(t, binary, "add")
+	if err == nil {
+		t.Fatal("expected error when neither --text nor --vector provided")
+	}
+}
+
+func TestCLITextSearchMissingQuery(t *testing.T) {
+	binary := buildBinary(t)
+
+	// No --query and no --vector should fail
+	_, err := runCLI(t, binary, "search")
+	if err == nil {
+		t.Fatal("expected error when neither --query nor --vector provided")
+	}
+}
+
+func TestCLITextDelete(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+	skipIfNoOllama(t)
+
+	defer cleanupMemories(t)
+
+	// Add a text memory
+	out, err := runCLI(t, binary, "add",
+		"--text", "this memory will be deleted",
+	)
+	if err != nil {
+		t.Fatalf("add failed: %v\n%s", err, out)
+	}
+
+	// Delete with 0 days — should delete everything
+	out, err = runCLI(t, binary, "delete",
+		"-d", "0", "--hard",
+	)
+	if err != nil {
+		t.Fatalf("delete failed: %v\n%s", err, out)
+	}
+
+	result := parseJSON(t, out)
+	if result["status"] != "ok" {
+		t.Fatalf("expected status ok, got %v", result["status"])
+	}
+	deleted, ok := result["deleted"].(float64)
+	if !ok || deleted < 1 {
+		t.Fatalf("expected at least 1 deletion, got %v", result["deleted"])
+	}
+
+	// Verify search returns nothing
+	out, err = runCLI(t, binary, "search",
+		"--query", "deleted",
+		"--limit", "10",
+	)
+	if err != nil {
+		t.Fatalf("search failed: %v\n%s", err, out)
+	}
+
+	searchResult := parseJSON(t, out)
+	returned, _ := searchResult["returned"].(float64)
+	if returned != 0 {
+		t.Errorf("expected 0 results after delete, got %v", returned)
+	}
+}
+
+func TestCLITextAddWithCustomID(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+	skipIfNoOllama(t)
+
+	defer cleanupMemories(t)
+
+	customID := "aabbccdd-1122-3344-5566-778899aabbcc"
+	out, err := runCLI(t, binary, "add",
+		"--text", "text with custom id",
+		"--id", customID,
+	)
+	if err != nil {
+		t.Fatalf("add failed: %v\n%s", err, out)
+	}
+
+	result := parseJSON(t, out)
+	if result["id"] != customID {
+		t.Errorf("expected id %q, got %v", customID, result["id"])
+	}
+}
+
+func TestCLITextSemanticSearch(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+	skipIfNoOllama(t)
+
+	defer cleanupMemories(t)
+
+	// Add memories with distinct topics
+	memories := []string{
+		"the user prefers dark mode for coding at night",
+		"deploy the application to production every friday",
+		"use golang and qdrant for the memory system",
+	}
+	for _, m := range memories {
+		out, err := runCLI(t, binary, "add",
+			"--text", m,
+		)
+		if err != nil {
+			t.Fatalf("add failed: %v\n%s", err, out)
+		}
+	}
+
+	// Search for something semantically related to dark mode
+	out, err := runCLI(t, binary, "search",
+		"--query", "night theme preferences",
+		"--limit", "3",
+	)
+	if err != nil {
+		t.Fatalf("search failed: %v\n%s", err, out)
+	}
+
+	result := parseJSON(t, out)
+	if result["status"] != "ok" {
+		t.Fatalf("expected status ok, got %v", result["status"])
+	}
+
+	results := result["results"].([]any)
+	if len(results) == 0 {
+		t.Fatal("expected at least 1 result")
+	}
+
+	// The top result should be about dark mode (semantically closest to "night theme preferences")
+	topPayload := results[0].(map[string]any)["payload"].(map[string]any)
+	topText := topPayload["text"].(string)
+	if topText != "the user prefers dark mode for coding at night" {
+		t.Errorf("expected dark mode memory as top result for 'night theme preferences', got %q", topText)
+	}
+}
+
+func TestCLIGlobalOllamaFlags(t *testing.T) {
+	binary := buildBinary(t)
+
+	// Test that --ollama-url and --model flags are accepted without error
+	// (they'll fail because of missing --text/--vector, but shouldn't fail on flag parsing)
+	_, err := runCLI(t, binary, "--ollama-url", "http://example.com:11434", "--model", "test-model", "add")
+	if err == nil {
+		t.Fatal("expected error for missing --text, not a pass")
+	}
+	// The error should be about missing text/vector, not about unknown flags
+}
+
+func TestCLIGlobalHostFlag(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+	skipIfNoOllama(t)
+
+	// --host before command should work
+	out, err := runCLI(t, binary, "--host", "localhost", "check")
+	if err != nil {
+		t.Fatalf("check with --host failed: %v\n%s", err, out)
+	}
+	result := parseJSON(t, out)
+	if result["status"] != "ok" {
+		t.Fatalf("expected status ok, got %v", result["status"])
+	}
+}
+
+// --- Confidence field tests ---
+
+func TestCLIConfidenceFieldPresent(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+
+	defer cleanupMemories(t)
+
+	// Add a memory
+	out, err := runCLI(t, binary, "add",
+		"--vector", "[0.1, 0.2, 0.3, 0.4]",
+		"--payload", `{"text": "confidence test"}`,
+	)
+	if err != nil {
+		t.Fatalf("add failed: %v\n%s", err, out)
+	}
+
+	// Search — response should include confidence field
+	out, err = runCLI(t, binary, "search",
+		"--vector", "[0.1, 0.2, 0.3, 0.4]",
+	)
+	if err != nil {
+		t.Fatalf("search failed: %v\n%s", err, out)
+	}
+
+	result := parseJSON(t, out)
+	conf, ok := result["confidence"].(string)
+	if !ok {
+		t.Fatalf("expected confidence field as string, got %v (%T)", result["confidence"], result["confidence"])
+	}
+	if conf != "high" && conf != "medium" && conf != "low" && conf != "none" {
+		t.Errorf("unexpected confidence value %q", conf)
+	}
+}
+
+func TestCLIConfidenceHigh(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+
+	defer cleanupMemories(t)
+
+	// Add and search with identical vector — score ~1.0, should be "high"
+	out, err := runCLI(t, binary, "add",
+		"--vector", "[0.5, 0.5, 0.5, 0.5]",
+		"--payload", `{"text": "exact match"}`,
+	)
+	if err != nil {
+		t.Fatalf("add failed: %v\n%s", err, out)
+	}
+
+	out, err = runCLI(t, binary, "search",
+		"--vector", "[0.5, 0.5, 0.5, 0.5]",
+	)
+	if err != nil {
+		t.Fatalf("search failed: %v\n%s", err, out)
+	}
+
+	result := parseJSON(t, out)
+	if result["confidence"] != "high" {
+		t.Errorf("expected confidence 'high' for exact match, got %v", result["confidence"])
+	}
+}
+
+func TestCLIConfidenceNone(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+
+	defer cleanupMemories(t)
+
+	// Add a memory then search with min-score so high nothing matches
+	out, err := runCLI(t, binary, "add",
+		"--vector", "[0.1, 0.2, 0.3, 0.4]",
+		"--payload", `{"text": "will not match"}`,
+	)
+	if err != nil {
+		t.Fatalf("add failed: %v\n%s", err, out)
+	}
+
+	out, err = runCLI(t, binary, "search",
+		"--vector", "[0.9, -0.9, 0.9, -0.9]",
+		"--min-score", "0.99",
+	)
+	if err != nil {
+		t.Fatalf("search failed: %v\n%s", err, out)
+	}
+
+	result := parseJSON(t, out)
+	if result["confidence"] != "none" {
+		t.Errorf("expected confidence 'none' for empty results, got %v", result["confidence"])
+	}
+	returned, _ := result["returned"].(float64)
+	if returned != 0 {
+		t.Errorf("expected 0 results, got %v", returned)
+	}
+}
+
+func TestCLIConfidenceLow(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+
+	defer cleanupMemories(t)
+
+	// Add a memory with one vector, query with a very different one
+	// Cosine similarity of orthogonal-ish vectors should be low
+	out, err := runCLI(t, binary, "add",
+		"--vector", "[1.0, 0.0, 0.0, 0.0]",
+		"--payload", `{"text": "low confidence test"}`,
+	)
+	if err != nil {
+		t.Fatalf("add failed: %v\n%s", err, out)
+	}
+
+	// Query with a nearly orthogonal vector — score should be close to 0
+	out, err = runCLI(t, binary, "search",
+		"--vector", "[0.01, 1.0, 0.0, 0.0]",
+	)
+	if err != nil {
+		t.Fatalf("search failed: %v\n%s", err, out)
+	}
+
+	result := parseJSON(t, out)
+	conf := result["confidence"].(string)
+	if conf != "low" {
+		// Check the actual score to understand
+		results := result["results"].([]any)
+		if len(results) > 0 {
+			score := results[0].(map[string]any)["score"].(float64)
+			t.Errorf("expected confidence 'low' for near-orthogonal vectors, got %q (score: %.4f)", conf, score)
+		} else {
+			t.Errorf("expected confidence 'low', got %q", conf)
+		}
+	}
+}
+
+func TestCLIConfidenceWithTextQuery(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+	skipIfNoOllama(t)
+
+	defer cleanupMemories(t)
+
+	// Add a text memory
+	out, err := runCLI(t, binary, "add",
+		"--text", "the cat sat on the mat",
+	)
+	if err != nil {
+		t.Fatalf("add failed: %v\n%s", err, out)
+	}
+
+	// Query with semantically related text — should have confidence field
+	out, err = runCLI(t, binary, "search",
+		"--query", "cat sitting on a mat",
+		"--limit", "3",
+	)
+	if err != nil {
+		t.Fatalf("search failed: %v\n%s", err, out)
+	}
+
+	result := parseJSON(t, out)
+	conf, ok := result["confidence"].(string)
+	if !ok {
+		t.Fatalf("expected confidence field as string in text mode, got %v (%T)", result["confidence"], result["confidence"])
+	}
+	// For nearly identical text, confidence should be high
+	if conf != "high" {
+		results := result["results"].([]any)
+		if len(results) > 0 {
+			score := results[0].(map[string]any)["score"].(float64)
+			t.Logf("Note: confidence=%q, top score=%.4f", conf, score)
+		}
+	}
+}
+
+func TestCLIPinnedMemorySurvivesDelete(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+	skipIfNoOllama(t)
+
+	defer cleanupMemories(t)
+
+	// Add a pinned memory
+	out, err := runCLI(t, binary, "add",
+		"--text", "this memory is pinned and should survive",
+		"--pinned",
+	)
+	if err != nil {
+		t.Fatalf("add pinned failed: %v\n%s", err, out)
+	}
+
+	addResult := parseJSON(t, out)
+	if addResult["status"] != "ok" {
+		t.Fatalf("expected status ok, got %v", addResult["status"])
+	}
+	pinnedID := addResult["id"].(string)
+
+	// Add an unpinned memory
+	out, err = runCLI(t, binary, "add",
+		"--text", "this memory is not pinned and should be deleted",
+	)
+	if err != nil {
+		t.Fatalf("add unpinned failed: %v\n%s", err, out)
+	}
+
+	// Delete with 0 days — should archive only the unpinned one
+	out, err = runCLI(t, binary, "delete",
+		"-d", "0",
+	)
+	if err != nil {
+		t.Fatalf("delete failed: %v\n%s", err, out)
+	}
+
+	deleteResult := parseJSON(t, out)
+	archived, _ := deleteResult["archived"].(float64)
+	if archived != 1 {
+		t.Fatalf("expected 1 archived (unpinned only), got %v", archived)
+	}
+
+	// Verify the pinned memory is still retrievable by ID
+	out, err = runCLI(t, binary, "get",
+		"--id", pinnedID,
+	)
+	if err != nil {
+		t.Fatalf("get pinned failed: %v\n%s", err, out)
+	}
+
+	getResult := parseJSON(t, out)
+	if getResult["status"] != "ok" {
+		t.Fatalf("expected status ok, got %v", getResult["status"])
+	}
+	payload := getResult["payload"].(map[string]any)
+	if payload["text"] != "this memory is pinned and should survive" {
+		t.Errorf("expected pinned memory text, got %v", payload["text"])
+	}
+	if payload["pinned"] != true {
+		t.Errorf("expected pinned=true in payload, got %v", payload["pinned"])
+	}
+}
+
+// --- Dedup-merge tests ---
+
+func TestCLIDedupMergeExactDuplicate(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+	skipIfNoOllama(t)
+
+	defer cleanupMemories(t)
+
+	// Add the first memory
+	out, err := runCLI(t, binary, "add",
+		"--text", "lico is a Korean software engineer living in London",
+	)
+	if err != nil {
+		t.Fatalf("first add failed: %v\n%s", err, out)
+	}
+	first := parseJSON(t, out)
+	if first["status"] != "ok" {
+		t.Fatalf("expected status ok, got %v", first["status"])
+	}
+	firstID := first["id"].(string)
+
+	// Add the same text again — should trigger merge
+	out, err = runCLI(t, binary, "add",
+		"--text", "lico is a Korean software engineer living in London",
+	)
+	if err != nil {
+		t.Fatalf("second add failed: %v\n%s", err, out)
+	}
+	second := parseJSON(t, out)
+	if second["status"] != "ok" {
+		t.Fatalf("expected status ok, got %v", second["status"])
+	}
+
+	// The old memory should have been merged (deleted + replaced)
+	if second["merged_id"] != firstID {
+		t.Errorf("expected merged_id %q, got %v", firstID, second["merged_id"])
+	}
+	// ...and reported with its score, for auditing
+	merged, _ := second["merged"].([]any)
+	if len(merged) != 1 {
+		t.Fatalf("expected one merged entry, got %v", second["merged"])
+	}
+	if m := merged[0].(map[string]any); m["id"] != firstID || m["score"].(float64) < second["dedup_threshold"].(float64) {
+		t.Errorf("expected %s merged at or above the threshold, got %v (threshold %v)", firstID, m, second["dedup_threshold"])
+	}
+
+	// There should only be 1 memory total, not 2
+	out, err = runCLI(t, binary, "search",
+		"--query", "lico is a Korean software engineer living in London",
+		"--limit", "10",
+	)
+	if err != nil {
+		t.Fatalf("search failed: %v\n%s", err, out)
+	}
+	search := parseJSON(t, out)
+	returned := int(search["returned"].(float64))
+	if returned != 1 {
+		t.Fatalf("expected 1 memory after dedup merge, got %d", returned)
+	}
+}
+
+func TestCLIDedupMergePreservesCreatedAt(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+	skipIfNoOllama(t)
+
+	defer cleanupMemories(t)
+
+	// Add the first memory
+	out, err := runCLI(t, binary, "add",
+		"--text", "the sky is blue on a clear day",
+	)
+	if err != nil {
+		t.Fatalf("first add failed: %v\n%s", err, out)
+	}
+	first := parseJSON(t, out)
+	firstID := first["id"].(string)
+
+	// Fetch the original created_at
+	out, err = runCLI(t, binary, "get", "--id", firstID)
+	if err != nil {
+		t.Fatalf("get failed: %v\n%s", err, out)
+	}
+	firstGet := parseJSON(t, out)
+	originalCreatedAt := firstGet["payload"].(map[string]any)["created_at"].(string)
+
+	time.Sleep(1100 * time.Millisecond)
+
+	// Add the same text again — should merge
+	out, err = runCLI(t, binary, "add",
+		"--text", "the sky is blue on a clear day",
+	)
+	if err != nil {
+		t.Fatalf("second add failed: %v\n%s", err, out)
+	}
+	second := parseJSON(t, out)
+	secondID := second["id"].(string)
+
+	// The new memory should have the original created_at preserved
+	out, err = runCLI(t, binary, "get", "--id", secondID)
+	if err != nil {
+		t.Fatalf("get merged failed: %v\n%s", err, out)
+	}
+	merged := parseJSON(t, out)
+	mergedCreatedAt := merged["payload"].(map[string]any)["created_at"].(string)
+
+	if mergedCreatedAt != originalCreatedAt {
+		t.Errorf("created_at not preserved: original=%s, merged=%s", originalCreatedAt, mergedCreatedAt)
+	}
+}
+
+func TestCLINoMergeFlag(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+	skipIfNoOllama(t)
+
+	defer cleanupMemories(t)
+
+	// Add the first memory
+	out, err := runCLI(t, binary, "add",
+		"--text", "water is composed of hydrogen and oxygen",
+	)
+	if err != nil {
+		t.Fatalf("first add failed: %v\n%s", err, out)
+	}
+	first := parseJSON(t, out)
+	if first["status"] != "ok" {
+		t.Fatalf("expected status ok, got %v", first["status"])
+	}
+
+	// Add the same text with --no-merge — should NOT merge, creating a duplicate
+	out, err = runCLI(t, binary, "add",
+		"--text", "water is composed of hydrogen and oxygen",
+		"--no-merge",
+	)
+	if err != nil {
+		t.Fatalf("second add failed: %v\n%s", err, out)
+	}
+	second := parseJSON(t, out)
+	if second["status"] != "ok" {
+		t.Fatalf("expected status ok, got %v", second["status"])
+	}
+
+	// merged_id should NOT be present
+	if second["merged_id"] != nil {
+		t.Errorf("expected no merged_id with --no-merge, got %v", second["merged_id"])
+	}
+
+	// There should be 2 memories (duplicate allowed)
+	out, err = runCLI(t, binary, "search",
+		"--query", "water is composed of hydrogen and oxygen",
+		"--limit", "10",
+	)
+	if err != nil {
+		t.Fatalf("search failed: %v\n%s", err, out)
+	}
+	search := parseJSON(t, out)
+	returned := int(search["returned"].(float64))
+	if returned != 2 {
+		t.Fatalf("expected 2 memories with --no-merge, got %d", returned)
+	}
+}
+
+func TestCLIDedupMergeVectorMode(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+
+	defer cleanupMemories(t)
+
+	vector := "[0.1,0.2,0.3,0.4]"
+	payload := `{"text":"vector mode dedup test"}`
+
+	// Add the first memory in vector mode
+	out, err := runCLI(t, binary, "add",
+		"--vector", vector,
+		"--payload", payload,
+	)
+	if err != nil {
+		t.Fatalf("first add failed: %v\n%s", err, out)
+	}
+	first := parseJSON(t, out)
+	firstID := first["id"].(string)
+
+	// Add the same vector again — should trigger merge
+	out, err = runCLI(t, binary, "add",
+		"--vector", vector,
+		"--payload", payload,
+	)
+	if err != nil {
+		t.Fatalf("second add failed: %v\n%s", err, out)
+	}
+	second := parseJSON(t, out)
+
+	if second["merged_id"] != firstID {
+		t.Errorf("expected merged_id %q, got %v", firstID, second["merged_id"])
+	}
+}
+
+func TestCLIDedupMergeMultipleDuplicates(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+	skipIfNoOllama(t)
+
+	defer cleanupMemories(t)
+
+	// Create 3 duplicates using --no-merge so they all coexist
+	ids := make([]string, 3)
+	for i := 0; i < 3; i++ {
+		out, err := runCLI(t, binary, "add",
+			"--text", "the capital of France is Paris",
+			"--no-merge",
+		)
+		if err != nil {
+			t.Fatalf("add #%d failed: %v\n%s", i+1, err, out)
+		}
+		r := parseJSON(t, out)
+		if r["status"] != "ok" {
+			t.Fatalf("add #%d: expected status ok, got %v", i+1, r["status"])
+		}
+		ids[i] = r["id"].(string)
+	}
+
+	// Verify all 3 exist
+	out, err := runCLI(t, binary, "search",
+		"--query", "the capital of France is Paris",
+		"--limit", "10",
+	)
+	if err != nil {
+		t.Fatalf("search failed: %v\n%s", err, out)
+	}
+	search := parseJSON(t, out)
+	if int(search["returned"].(float64)) != 3 {
+		t.Fatalf("expected 3 memories before merge, got %v", search["returned"])
+	}
+
+	// Add the same text WITH merge enabled — should delete all 3 and replace
+	out, err = runCLI(t, binary, "add",
+		"--text", "the capital of France is Paris",
+	)
+	if err != nil {
+		t.Fatalf("merge add failed: %v\n%s", err, out)
+	}
+	result := parseJSON(t, out)
+	if result["status"] != "ok" {
+		t.Fatalf("expected status ok, got %v", result["status"])
+	}
+
+	// merged_ids should contain all 3 old IDs
+	mergedIDs, ok := result["merged_ids"].([]any)
+	if !ok {
+		t.Fatalf("expected merged_ids to be an array, got %T: %v", result["merged_ids"], result["merged_ids"])
+	}
+	if len(mergedIDs) != 3 {
+		t.Errorf("expected 3 merged_ids, got %d: %v", len(mergedIDs), mergedIDs)
+	}
+
+	// Backward compat: merged_id should still be present
+	if result["merged_id"] == nil {
+		t.Errorf("expected merged_id for backward compat, got nil")
+	}
+
+	// There should be exactly 1 memory now
+	out, err = runCLI(t, binary, "search",
+		"--query", "the capital of France is Paris",
+		"--limit", "10",
+	)
+	if err != nil {
+		t.Fatalf("search after merge failed: %v\n%s", err, out)
+	}
+	search = parseJSON(t, out)
+	returned := int(search["returned"].(float64))
+	if returned != 1 {
+		t.Fatalf("expected 1 memory after merge, got %d", returned)
+	}
+}
+
+func TestCLIDedupMergeMultiplePreservesOldestCreatedAt(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+	skipIfNoOllama(t)
+
+	defer cleanupMemories(t)
+
+	// Add first memory normally (will get the earliest created_at)
+	out, err := runCLI(t, binary, "add",
+		"--text", "dogs are loyal companions",
+	)
+	if err != nil {
+		t.Fatalf("first add failed: %v\n%s", err, out)
+	}
+	first := parseJSON(t, out)
+	firstID := first["id"].(string)
+
+	// Fetch original created_at
+	out, err = runCLI(t, binary, "get", "--id", firstID)
+	if err != nil {
+		t.Fatalf("get failed: %v\n%s", err, out)
+	}
+	firstGet := parseJSON(t, out)
+	originalCreatedAt := firstGet["payload"].(map[string]any)["created_at"].(string)
+
+	time.Sleep(1100 * time.Millisecond)
+
+	// Add a second duplicate via --no-merge
+	out, err = runCLI(t, binary, "add",
+		"--text", "dogs are loyal companions",
+		"--no-merge",
+	)
+	if err != nil {
+		t.Fatalf("second add failed: %v\n%s", err, out)
+	}
+
+	time.Sleep(1100 * time.Millisecond)
+
+	// Now merge all — should preserve the original (oldest) created_at
+	out, err = runCLI(t, binary, "add",
+		"--text", "dogs are loyal companions",
+	)
+	if err != nil {
+		t.Fatalf("merge add failed: %v\n%s", err, out)
+	}
+	result := parseJSON(t, out)
+	newID := result["id"].(string)
+
+	out, err = runCLI(t, binary, "get", "--id", newID)
+	if err != nil {
+		t.Fatalf("get merged failed: %v\n%s", err, out)
+	}
+	merged := parseJSON(t, out)
+	mergedCreatedAt := merged["payload"].(map[string]any)["created_at"].(string)
+
+	if mergedCreatedAt != originalCreatedAt {
+		t.Errorf("created_at not preserved from oldest duplicate: original=%s, merged=%s", originalCreatedAt, mergedCreatedAt)
+	}
+}
+
+// --- Collections command removed ---
+
+func TestCLICollectionsCommandRemoved(t *testing.T) {
+	binary := buildBinary(t)
+	_, err := runCLI(t, binary, "collections")
+	if err == nil {
+		t.Fatal("expected error for removed 'collections' command")
+	}
+}
+
+// --- Env var tests ---
+
+// TestCLIEnvPort verifies that CLAWBRAIN_PORT is read from the environment.
+// exec.Command inherits the parent's env, so t.Setenv propagates to the
+// subprocess and the binary's init() picks it up before flag parsing.
+//
+// The two subtests together prove the env var is actually used:
+//   - CLAWBRAIN_PORT=6334 (valid) must succeed — proves the var is read
+//   - CLAWBRAIN_PORT=9999 (unreachable) must fail — distinguishes "env var
+//     read and used" from "env var ignored, default 6334 used accidentally"
+func TestCLIEnvPort(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+	skipIfNoOllama(t)
+
+	t.Run("valid port connects", func(t *testing.T) {
+		t.Setenv("CLAWBRAIN_PORT", "6334")
+		out, err := runCLI(t, binary, "check")
+		if err != nil {
+			t.Fatalf("check with CLAWBRAIN_PORT=6334 failed: %v\n%s", err, out)
+		}
+		result := parseJSON(t, out)
+		if result["status"] != "ok" {
+			t.Fatalf("expected status ok, got %v", result["status"])
+		}
+	})
+
+	t.Run("wrong port causes non-zero exit", func(t *testing.T) {
+		// If CLAWBRAIN_PORT were ignored, the binary would fall back to the
+		// default port (6334) and check would succeed. A non-zero exit here
+		// proves the env var was actually read and passed to the Qdrant client.
+		t.Setenv("CLAWBRAIN_PORT", "9999")
+		_, err := runCLI(t, binary, "check")
+		if err == nil {
+			t.Fatal("expected non-zero exit when CLAWBRAIN_PORT points to unreachable port")
+		}
+	})
+}
+
+// --- Sync command tests ---
+
+// skipIfNoRedis skips the test if Redis is not running on localhost:6379.
+func skipIfNoRedis(t *testing.T) {
+	t.Helper()
+	conn, err := net.DialTimeout("tcp", "localhost:6379", 2*time.Second)
+	if err != nil {
+		t.Skipf("Redis not available on localhost:6379, skipping: %v", err)
+	}
+	conn.Close()
+}
+
+// cleanupRedisKey deletes a key from Redis. Uses a raw TCP connection
+// to avoid importing the redis package in tests.
+func cleanupRedisKey(t *testing.T, key string) {
+	t.Helper()
+	conn, err := net.DialTimeout("tcp", "localhost:6379", 2*time.Second)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	// Send DEL command via RESP
+	cmd := fmt.Sprintf("*2\r\n$3\r\nDEL\r\n$%d\r\n%s\r\n", len(key), key)
+	conn.Write([]byte(cmd))
+}
+
+func TestCLISyncSingleFile(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+	skipIfNoOllama(t)
+	skipIfNoRedis(t)
+
+	defer cleanupMemories(t)
+
+	// Create a temp markdown file
+	dir := t.TempDir()
+	filePath := dir + "/test-notes.md"
+	os.WriteFile(filePath, []byte("# Storage\n\nThe project uses PostgreSQL for persistence and Redis for caching."), 0644)
+
+	// Clean Redis key for this file
+	cleanupRedisKey(t, "sync:"+filePath)
+	defer cleanupRedisKey(t, "sync:"+filePath)
+
+	out, err := runCLI(t, binary, "sync", "--file", filePath)
+	if err != nil {
+		t.Fatalf("sync failed: %v\n%s", err, out)
+	}
+
+	result := parseJSON(t, out)
+	if result["status"] != "ok" {
+		t.Fatalf("expected status ok, got %v", result["status"])
+	}
+
+	added, _ := result["added"].(float64)
+	if added < 1 {
+		t.Fatalf("expected at least 1 chunk added, got %v", added)
+	}
+
+	// Verify the memory was actually stored by searching for it
+	searchOut, err := runCLI(t, binary, "search", "--query", "PostgreSQL database", "--limit", "1")
+	if err != nil {
+		t.Fatalf("search failed: %v\n%s", err, searchOut)
+	}
+
+	searchResult := parseJSON(t, searchOut)
+	results := searchResult["results"].([]any)
+	if len(results) == 0 {
+		t.Fatal("expected search to find the synced memory")
+	}
+
+	topPayload := results[0].(map[string]any)["payload"].(map[string]any)
+	if topPayload["source"] != filePath {
+		t.Errorf("expected source=%q, got %v", filePath, topPayload["source"])
+	}
+	if topPayload["start_line"] != float64(3) || topPayload["end_line"] != float64(3) || topPayload["anchor"] != "storage" {
+		t.Errorf("expected line 3 under #storage, got %v", topPayload)
+	}
+	if topPayload["heading_path"] != "# Storage" || !strings.HasPrefix(topPayload["text"].(string), "# Storage\n\n") {
+		t.Errorf("expected the chunk led by its heading path, got %v", topPayload)
+	}
+}
+
+func TestCLIProvenance(t *testing.T) {
+	binary := buildBinary(t)
+
+	// --id is checked before connecting, so no services are needed.
+	if out, err := runCLI(t, binary, "provenance"); err == nil {
+		t.Errorf("expected provenance without --id to fail\n%s", out)
+	}
+
+	skipIfNoQdrant(t, binary)
+	skipIfNoRedis(t)
+	ollamaURL := fakeOllama(t).URL
+	defer cleanupMemories(t)
+
+	filePath := t.TempDir() + "/deploys.md"
+	os.WriteFile(filePath, []byte("# Deploys\n\nShip on tuesdays."), 0644)
+	cleanupRedisKey(t, "sync:"+filePath)
+	defer cleanupRedisKey(t, "sync:"+filePath)
+	if out, err := runCLI(t, binary, "--ollama-url", ollamaURL, "sync", "--file", filePath); err != nil {
+		t.Fatalf("sync failed: %v\n%s", err, out)
+	}
+	out, _ := runCLI(t, binary, "search", "--vector", "[0.3, 0.1, 0.4, 0.1]")
+	id := parseJSON(t, out)["results"].([]any)[0].(map[string]any)["id"].(string)
+
+	check := func(want string) map[string]any {
+		t.Helper()
+		out, err := runCLI(t, binary, "provenance", "--id", id)
+		if err != nil {
+			t.Fatalf("provenance failed: %v\n%s", err, out)
+		}
+		result := parseJSON(t, out)
+		if result["provenance"] != want {
+			t.Fatalf("expected %s, got %s", want, out)
+		}
+		return result
+	}
+	drift := func() any {
+		t.Helper()
+		out, _ := runCLI(t, binary, "get", "--id", id)
+		return parseJSON(t, out)["payload"].(map[string]any)["drift"]
+	}
+
+	check("unchanged")
+	os.WriteFile(filePath, []byte("# Deploys\n\nShip on fridays."), 0644)
+	if result := check("changed"); result["current_text"] != "Ship on fridays." || result["drifted"] != true {
+		t.Errorf("unexpected changed report %v", result)
+	}
+	if d := drift(); d != "changed" {
+		t.Errorf("expected the memory flagged as changed, got %v", d)
+	}
+	os.WriteFile(filePath, []byte("# Notes\n\n# Deploys\n\nShip on tuesdays."), 0644)
+	check("moved")
+	os.WriteFile(filePath, []byte("# Deploys\n\nShip on tuesdays."), 0644)
+	check("unchanged")
+	if d := drift(); d != nil {
+		t.Errorf("expected the drift flag cleared, got %v", d)
+	}
+	os.Remove(filePath)
+	check("missing")
+}
+
+func TestCLIRefresh(t *testing.T) {
+	binary := buildBinary(t)
+
+	// --id is checked before connecting, so no services are needed.
+	if out, err := runCLI(t, binary, "refresh"); err == nil {
+		t.Errorf("expected refresh without --id to fail\n%s", out)
+	}
+
+	skipIfNoQdrant(t, binary)
+	skipIfNoRedis(t)
+	ollamaURL := fakeOllama(t).URL
+	defer cleanupMemories(t)
+
+	filePath := t.TempDir() + "/deploys.md"
+	os.WriteFile(filePath, []byte("# Deploys\n\nShip on tuesdays."), 0644)
+	cleanupRedisKey(t, "sync:"+filePath)
+	defer cleanupRedisKey(t, "sync:"+filePath)
+	if out, err := runCLI(t, binary, "--ollama-url", ollamaURL, "sync", "--file", filePath); err != nil {
+		t.Fatalf("sync failed: %v\n%s", err, out)
+	}
+	out, _ := runCLI(t, binary, "search", "--vector", "[0.3, 0.1, 0.4, 0.1]")
+	id := parseJSON(t, out)["results"].([]any)[0].(map[string]any)["id"].(string)
+	payload := func() map[string]any {
+		t.Helper()
+		out, _ := runCLI(t, binary, "get", "--id", id)
+		return parseJSON(t, out)["payload"].(map[string]any)
+	}
+	createdAt := payload()["created_at"]
+
+	out, err := runCLI(t, binary, "--ollama-url", ollamaURL, "refresh", "--id", id)
+	if err != nil || parseJSON(t, out)["refreshed"] != false {
+		t.Errorf("expected an unchanged memory to be left alone: %v\n%s", err, out)
+	}
+
+	// Edited text is re-read from the recorded lines and re-embedded.
+	os.WriteFile(filePath, []byte("# Deploys\n\nShip on fridays."), 0644)
+	out, err = runCLI(t, binary, "--ollama-url", ollamaURL, "refresh", "--id", id)
+	if err != nil {
+		t.Fatalf("refresh failed: %v\n%s", err, out)
+	}
+	if result := parseJSON(t, out); result["reembedded"] != true || result["provenance"] != "changed" {
+		t.Errorf("unexpected refresh result %s", out)
+	}
+	p := payload()
+	if p["text"] != "# Deploys\n\nShip on fridays." || p["created_at"] != createdAt || p["revision"] != float64(2) {
+		t.Errorf("expected the text updated in place, got %v", p)
+	}
+
+	// Moved text only gets new lines.
+	os.WriteFile(filePath, []byte("# Notes\n\n# Deploys\n\nShip on fridays."), 0644)
+	out, err = runCLI(t, binary, "--ollama-url", ollamaURL, "refresh", "--id", id)
+	if err != nil || parseJSON(t, out)["reembedded"] != false {
+		t.Fatalf("refresh of moved text failed: %v\n%s", err, out)
+	}
+	if p := payload(); p["start_line"] != float64(5) || p["anchor"] != "deploys" {
+		t.Errorf("expected the new location recorded, got %v", p)
+	}
+
+	os.Remove(filePath)
+	if out, err := runCLI(t, binary, "refresh", "--id", id); err == nil {
+		t.Errorf("expected refresh of a memory whose file is gone to fail\n%s", out)
+	}
+}
+
+func TestCLISyncDedupScope(t *testing.T) {
+	binary := buildBinary(t)
+
+	// The scope is checked before connecting, so no services are needed.
+	out, err := runCLI(t, binary, "sync", "--dedup-scope", "folder")
+	if err == nil || parseJSON(t, out)["status"] != "error" {
+		t.Errorf("expected an unknown --dedup-scope to be rejected\n%s", out)
+	}
+
+	skipIfNoQdrant(t, binary)
+	skipIfNoOllama(t)
+	skipIfNoRedis(t)
+	defer cleanupMemories(t)
+
+	// The same boilerplate in two files stays in both with --dedup-scope file.
+	dir := t.TempDir()
+	boilerplate := []byte("Template: every incident review lists impact, timeline and follow-up actions.")
+	a, b := dir+"/review-a.md", dir+"/review-b.md"
+	os.WriteFile(a, boilerplate, 0644)
+	os.WriteFile(b, boilerplate, 0644)
+	for _, f := range []string{a, b} {
+		cleanupRedisKey(t, "sync:"+f)
+		defer cleanupRedisKey(t, "sync:"+f)
+	}
+
+	out, err = runCLI(t, binary, "sync", "--file", a, "--file", b, "--dedup-scope", "file")
+	if err != nil {
+		t.Fatalf("sync failed: %v\n%s", err, out)
+	}
+	result := parseJSON(t, out)
+	if result["added"] != float64(2) {
+		t.Errorf("expected a chunk from each file, got %v", result["added"])
+	}
+	dups, _ := result["cross_file_duplicates"].([]any)
+	if len(dups) != 1 {
+		t.Fatalf("expected one cross-file duplicate, got %s", out)
+	}
+	if d := dups[0].(map[string]any); d["file"] != b || d["duplicate_source"] != a || d["merged"] != false {
+		t.Errorf("unexpected duplicate report %v", d)
+	}
+}
+
+func TestCLISyncSkipsDuplicates(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+	skipIfNoOllama(t)
+	skipIfNoRedis(t)
+
+	defer cleanupMemories(t)
+
+	dir := t.TempDir()
+	filePath := dir + "/test-dedup.md"
+	os.WriteFile(filePath, []byte("Kubernetes pods are scheduled by the control plane."), 0644)
+
+	// Clean Redis key
+	cleanupRedisKey(t, "sync:"+filePath)
+	defer cleanupRedisKey(t, "sync:"+filePath)
+
+	// First sync — should add
+	out1, err := runCLI(t, binary, "sync", "--file", filePath)
+	if err != nil {
+		t.Fatalf("first sync failed: %v\n%s", err, out1)
+	}
+	result1 := parseJSON(t, out1)
+	added1, _ := result1["added"].(float64)
+	if added1 < 1 {
+		t.Fatalf("first sync should add chunks, got %v", added1)
+	}
+
+	// Second sync — should skip (file already in Redis)
+	out2, err := runCLI(t, binary, "sync", "--file", filePath)
+	if err != nil {
+		t.Fatalf("second sync failed: %v\n%s", err, out2)
+	}
+	result2 := parseJSON(t, out2)
+	added2, _ := result2["added"].(float64)
+	skipped2, _ := result2["skipped"].(float64)
+	if added2 != 0 {
+		t.Errorf("second sync should add 0 chunks, got %v", added2)
+	}
+	if skipped2 < 1 {
+		t.Errorf("second sync should skip the file, got skipped=%v", skipped2)
+	}
+}
+
+func TestCLISyncDiffsChunks(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoRedis(t)
+
+	var embedded atomic.Int64
+	ollama := hashingOllama(t, &embedded)
+
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "MEMORY.md")
+	cleanupRedisKey(t, "sync:"+filePath)
+	defer cleanupRedisKey(t, "sync:"+filePath)
+	args := []string{"--backend", "file", "--path", t.TempDir(), "--ollama-url", ollama.URL, "sync", "--file", filePath}
+	paragraph := func(topic string) string {
+		return strings.Repeat("Notes about "+topic+" that go on for a while. ", 30)
+	}
+	write := func(topics ...string) {
+		var paragraphs []string
+		for _, topic := range topics {
+			paragraphs = append(paragraphs, paragraph(topic))
+		}
+		if err := os.WriteFile(filePath, []byte(strings.Join(paragraphs, "\n\n")), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	resync := func() map[string]any {
+		t.Helper()
+		embedded.Store(0)
+		out, err := runCLI(t, binary, args...)
+		if err != nil {
+			t.Fatalf("sync failed: %v\n%s", err, out)
+		}
+		return parseJSON(t, out)
+	}
+
+	write("deploys", "billing", "the cat")
+	first := resync()
+	added, _ := first["added"].(float64)
+	if added < 3 || embedded.Load() != int64(added) {
+		t.Fatalf("expected every chunk embedded and added, got %d embeds: %v", embedded.Load(), first)
+	}
+
+	// Chunks overlap, so changing the last paragraph changes the chunks
+	// that reach into it and no others.
+	write("deploys", "billing", "the dog")
+	second := resync()
+	if second["unchanged"].(float64) < 1 || second["added"].(float64) >= added || second["deleted"] != second["added"] {
+		t.Errorf("expected only the chunks of the changed paragraph replaced, got %v", second)
+	}
+	if embedded.Load() != int64(second["added"].(float64)) {
+		t.Errorf("expected only the changed chunks embedded, got %d embeds: %v", embedded.Load(), second)
+	}
+
+	write("deploys", "billing")
+	third := resync()
+	if third["unchanged"].(float64) < 1 || third["deleted"].(float64) <= third["added"].(float64) {
+		t.Errorf("expected the removed paragraph's chunks deleted, got %v", third)
+	}
+	if embedded.Load() != int64(third["added"].(float64)) {
+		t.Errorf("expected only the changed chunks embedded, got %d embeds: %v", embedded.Load(), third)
+	}
+}
+
+func TestCLISyncFrontmatter(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoRedis(t)
+
+	var embedded atomic.Int64
+	ollama := hashingOllama(t, &embedded)
+	dir := t.TempDir()
+	notes, private := filepath.Join(dir, "MEMORY.md"), filepath.Join(dir, "private.md")
+	for _, path := range []string{notes, private} {
+		cleanupRedisKey(t, "sync:"+path)
+		defer cleanupRedisKey(t, "sync:"+path)
+	}
+	write := func(path, frontmatter string) {
+		t.Helper()
+		content := "---\n" + frontmatter + "---\n# Deploys\n\nShip on tuesdays."
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	global := []string{"--backend", "file", "--path", t.TempDir(), "--ollama-url", ollama.URL}
+	run := func(args ...string) map[string]any {
+		t.Helper()
+		out, err := runCLI(t, binary, append(global, args...)...)
+		if err != nil {
+			t.Fatalf("%v failed: %v\n%s", args, err, out)
+		}
+		return parseJSON(t, out)
+	}
+	payloads := func() []map[string]any {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), "export.jsonl")
+		run("export", "--out", path)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out []map[string]any
+		for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n")[1:] {
+			var p struct{ Payload map[string]any }
+			json.Unmarshal([]byte(line), &p)
+			out = append(out, p.Payload)
+		}
+		return out
+	}
+
+	write(notes, "title: Ops notes\ntags: [ops]\ndate: 2024-03-05T09:00:00Z\npinned: true\n")
+	write(private, "clawbrain: ignore\n")
+	res := run("sync", "--dir", dir)
+	if res["added"] != 1.0 || res["skipped"] != 1.0 {
+		t.Fatalf("expected the ignored file skipped and the other added, got %v", res)
+	}
+	got := payloads()
+	if len(got) != 1 {
+		t.Fatalf("expected one memory, got %v", got)
+	}
+	p := got[0]
+	if strings.Contains(p["text"].(string), "title") || p["title"] != "Ops notes" || p["pinned"] != true {
+		t.Errorf("expected the frontmatter mapped into the payload and left out of the text, got %v", p)
+	}
+	if p["created_at"] != "2024-03-05T09:00:00Z" || fmt.Sprint(p["tags"]) != "[ops]" {
+		t.Errorf("expected the date and tags from the frontmatter, got %v", p)
+	}
+
+	// A frontmatter edit updates the chunk in place, without re-embedding.
+	write(notes, "title: Ops notes\ntags:\n  - ops\n  - release\n")
+	embedded.Store(0)
+	if res := run("sync", "--file", notes); res["unchanged"] != 1.0 || embedded.Load() != 0 {
+		t.Errorf("expected the chunk kept as it was, got %d embeds: %v", embedded.Load(), res)
+	}
+	if p := payloads()[0]; fmt.Sprint(p["tags"]) != "[ops release]" {
+		t.Errorf("expected the new tag added to the chunk, got %v", p)
+	}
+}
+
+func TestCLISyncConcurrency(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoRedis(t)
+
+	var embedded atomic.Int64
+	ollama := hashingOllama(t, &embedded)
+	runSync := func(concurrency string) []any {
+		dir := t.TempDir()
+		for i := range 8 {
+			path := filepath.Join(dir, fmt.Sprintf("note-%d.md", i))
+			cleanupRedisKey(t, "sync:"+path)
+			defer cleanupRedisKey(t, "sync:"+path)
+			content := fmt.Sprintf("# Note %d\n\n%s\n\n## More\n\nDetail %d.", i, strings.Repeat(fmt.Sprintf("Line %d of the note. ", i), 120), i)
+			if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		out, err := runCLI(t, binary, "--backend", "file", "--path", t.TempDir(), "--ollama-url", ollama.URL,
+			"sync", "--dir", dir, "--concurrency", concurrency, "--rate", "1000")
+		if err != nil {
+			t.Fatalf("sync --concurrency %s failed: %v\n%s", concurrency, err, out)
+		}
+		results := parseJSON(t, out)["results"].([]any)
+		for _, r := range results {
+			r := r.(map[string]any)
+			r["file"] = filepath.Base(r["file"].(string))
+		}
+		return results
+	}
+
+	serial := runSync("1")
+	serialEmbeds := embedded.Swap(0)
+	parallel := runSync("8")
+	if !reflect.DeepEqual(serial, parallel) {
+		t.Errorf("expected the same per-file results whatever the concurrency\nserial:   %v\nparallel: %v", serial, parallel)
+	}
+	if embedded.Load() != serialEmbeds {
+		t.Errorf("expected every chunk embedded once, got %d embeds against %d", embedded.Load(), serialEmbeds)
+	}
+	if first := parallel[0].(map[string]any); first["file"] != "note-0.md" || first["added"].(float64) < 2 {
+		t.Errorf("expected results in discovery order with every chunk added, got %v", parallel)
+	}
+
+	if out, err := runCLI(t, binary, "--backend", "file", "--path", t.TempDir(), "sync", "--concurrency", "0"); err == nil {
+		t.Errorf("expected --concurrency 0 to be rejected\n%s", out)
+	}
+}
+
+func TestCLISyncProgressResume(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoRedis(t)
+
+	var embedded atomic.Int64
+	healthy := hashingOllama(t, &embedded)
+	var broken atomic.Bool
+	broken.Store(true)
+	ollama := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if broken.Load() && strings.Contains(string(body), "flaky") {
+			http.Error(w, "model crashed", http.StatusInternalServerError)
+			return
+		}
+		resp, err := http.Post(healthy.URL+r.URL.Path, "application/json", bytes.NewReader(body))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		io.Copy(w, resp.Body)
+	}))
+	defer ollama.Close()
+
+	const namespace = "progresstest"
+	run, done := "syncrun@"+namespace+":run", "syncrun@"+namespace+":done"
+	cleanupRedisKey(t, run)
+	cleanupRedisKey(t, done)
+	defer cleanupRedisKey(t, run)
+	defer cleanupRedisKey(t, done)
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"a.md": "Deploys go out on tuesdays.",
+		"b.md": "This one is flaky to embed.",
+		"c.md": "The office has a blue door.",
+	} {
+		path := filepath.Join(dir, name)
+		cleanupRedisKey(t, "sync@"+namespace+":"+path)
+		defer cleanupRedisKey(t, "sync@"+namespace+":"+path)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	global := []string{"--backend", "file", "--path", t.TempDir(), "--ollama-url", ollama.URL, "--namespace", namespace}
+	lines := func(out []byte) []map[string]any {
+		var parsed []map[string]any
+		for _, line := range strings.Split(string(out), "\n") {
+			if strings.HasPrefix(line, "{") {
+				parsed = append(parsed, parseJSON(t, []byte(line)))
+			}
+		}
+		return parsed
+	}
+
+	out, err := runCLI(t, binary, append(global, "sync", "--dir", dir, "--progress")...)
+	if err != nil {
+		t.Fatalf("sync failed: %v\n%s", err, out)
+	}
+	events := lines(out)
+	if len(events) != 8 || events[0]["event"] != "start" || events[0]["files"] != 3.0 {
+		t.Fatalf("expected start, a chunk and a file event per file, and the result, got %s", out)
+	}
+	if events[1]["event"] != "chunk" || events[1]["status"] != "added" || events[2]["event"] != "file" || events[2]["index"] != 1.0 {
+		t.Errorf("expected each file's chunk and file events in order, got %v then %v", events[1], events[2])
+	}
+	if events[3]["status"] != "failed" {
+		t.Errorf("expected the flaky chunk reported failed, got %v", events[3])
+	}
+	result := events[len(events)-1]
+	if result["event"] != nil || result["added"] != 2.0 || result["resumable"] != 1.0 {
+		t.Fatalf("expected the result last, with one file left to resume, got %v", result)
+	}
+
+	// The resumed run retries only the file that failed, even when
+	// others have been added to the directory since.
+	broken.Store(false)
+	if err := os.WriteFile(filepath.Join(dir, "d.md"), []byte("Added after the interrupt."), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	defer cleanupRedisKey(t, "sync@"+namespace+":"+filepath.Join(dir, "d.md"))
+	embedded.Store(0)
+	out, err = runCLI(t, binary, append(global, "sync", "--dir", dir, "--resume")...)
+	if err != nil {
+		t.Fatalf("sync --resume failed: %v\n%s", err, out)
+	}
+	result = parseJSON(t, out)
+	if result["resumed"] != true || result["files"] != 3.0 || result["added"] != 1.0 || result["skipped"] != 2.0 || result["resumable"] != nil {
+		t.Errorf("expected the resumed run to finish the flaky file only, got %s", out)
+	}
+	if embedded.Load() != 1 {
+		t.Errorf("expected only the flaky file embedded, got %d embeds", embedded.Load())
+	}
+
+	// With the run finished there is nothing to resume: the next run
+	// starts afresh.
+	out, err = runCLI(t, binary, append(global, "sync", "--dir", dir, "--resume")...)
+	if err != nil {
+		t.Fatalf("sync --resume failed: %v\n%s", err, out)
+	}
+	if result := parseJSON(t, out); result["resumed"] != nil || result["files"] != 4.0 {
+		t.Errorf("expected a fresh run over all four files, got %s", out)
+	}
+}
+
+func TestCLISyncFormats(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the extractor plugin is a shell script")
+	}
+	binary := buildBinary(t)
+	skipIfNoRedis(t)
+
+	var embedded atomic.Int64
+	ollama := hashingOllama(t, &embedded)
+	bin := t.TempDir()
+	script := "#!/bin/sh\nif [ \"$1\" = \"${1%broken.pdfx}\" ]; then echo 'Extracted report text.'; else echo 'damaged file' >&2; exit 1; fi\n"
+	if err := os.WriteFile(filepath.Join(bin, "clawbrain-extract-pdfx"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"todo.txt":    "# not a heading in plain text\nBuy milk.",
+		"ops.org":     "#+TITLE: Ops\n* Deploys\nShip on tuesdays.",
+		"report.pdfx": "%PDF-binary",
+		"broken.pdfx": "%PDF-binary",
+		"image.png":   "\x89PNG",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		cleanupRedisKey(t, "sync:"+path)
+		defer cleanupRedisKey(t, "sync:"+path)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	global := []string{"--backend", "file", "--path", t.TempDir(), "--ollama-url", ollama.URL}
+	out, err := runCLI(t, binary, append(global, "sync", "--dir", dir)...)
+	if err != nil {
+		t.Fatalf("sync failed: %v\n%s", err, out)
+	}
+	res := parseJSON(t, out)
+	if res["files"] != 4.0 || res["added"] != 3.0 {
+		t.Fatalf("expected the .txt, .org and extracted files synced and the .png left out, got %s", out)
+	}
+	for _, r := range res["results"].([]any) {
+		r := r.(map[string]any)
+		if strings.HasSuffix(r["file"].(string), "broken.pdfx") && !strings.Contains(fmt.Sprint(r["reason"]), "damaged file") {
+			t.Errorf("expected the extractor's error reported, got %v", r)
+		}
+	}
+
+	export := filepath.Join(t.TempDir(), "export.jsonl")
+	if out, err := runCLI(t, binary, append(global, "export", "--out", export)...); err != nil {
+		t.Fatalf("export failed: %v\n%s", err, out)
+	}
+	data, err := os.ReadFile(export)
+	if err != nil {
+		t.Fatal(err)
+	}
+	texts := map[string]any{}
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n")[1:] {
+		var p struct{ Payload map[string]any }
+		json.Unmarshal([]byte(line), &p)
+		texts[filepath.Base(p.Payload["source"].(string))] = p.Payload["text"]
+	}
+	want := map[string]any{
+		"todo.txt":    "# not a heading in plain text\nBuy milk.",
+		"ops.org":     "# Deploys\n\nShip on tuesdays.",
+		"report.pdfx": "Extracted report text.",
+	}
+	for name, text := range want {
+		if texts[name] != text {
+			t.Errorf("%s: got %q, want %q", name, texts[name], text)
+		}
+	}
+}
+
+func TestCLIUnsync(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoRedis(t)
+
+	var embedded atomic.Int64
+	ollama := hashingOllama(t, &embedded)
+	dir := t.TempDir()
+	keep, gone := filepath.Join(dir, "keep.md"), filepath.Join(dir, "gone.md")
+	for _, path := range []string{keep, gone} {
+		cleanupRedisKey(t, "sync:"+path)
+		defer cleanupRedisKey(t, "sync:"+path)
+		if err := os.WriteFile(path, []byte("# "+filepath.Base(path)+"\n\nNotes kept in "+path), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	global := []string{"--backend", "file", "--path", t.TempDir(), "--ollama-url", ollama.URL}
+	run := func(args ...string) map[string]any {
+		t.Helper()
+		out, err := runCLI(t, binary, append(global, args...)...)
+		if err != nil {
+			t.Fatalf("%v failed: %v\n%s", args, err, out)
+		}
+		return parseJSON(t, out)
+	}
+	sources := func() map[string]int {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), "export.jsonl")
+		run("export", "--out", path)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts := map[string]int{}
+		for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n")[1:] {
+			var p struct{ Payload map[string]any }
+			json.Unmarshal([]byte(line), &p)
+			counts[p.Payload["source"].(string)]++
+		}
+		return counts
+	}
+
+	if res := run("sync", "--dir", dir); res["added"] != 2.0 {
+		t.Fatalf("expected a chunk from each file, got %v", res)
+	}
+	if err := os.Remove(gone); err != nil {
+		t.Fatal(err)
+	}
+
+	res := run("sync", "--dir", dir)
+	if missing, _ := res["missing_files"].([]any); !slices.Contains(missing, any(gone)) || res["pruned"] != nil {
+		t.Errorf("expected sync to report the deleted file without pruning, got %v", res)
+	}
+	if res := run("unsync", "--missing", "--dry-run"); res["would_remove"] != 1.0 {
+		t.Errorf("expected a dry run to count the deleted file's chunk, got %v", res)
+	}
+	if counts := sources(); counts[gone] != 1 {
+		t.Errorf("expected a dry run to change nothing, got %v", counts)
+	}
+
+	res = run("sync", "--dir", dir, "--prune")
+	if res["pruned"] != 1.0 {
+		t.Errorf("expected --prune to delete the deleted file's chunk, got %v", res)
+	}
+	if counts := sources(); counts[gone] != 0 || counts[keep] != 1 {
+		t.Errorf("expected only the kept file's chunk to remain, got %v", counts)
+	}
+
+	res = run("unsync", "--file", keep, "--archive")
+	if res["archived"] != 1.0 {
+		t.Errorf("expected unsync --archive to archive the chunk, got %v", res)
+	}
+	// Its sync state is gone too, so syncing the file again ingests it.
+	if res := run("sync", "--file", keep); res["added"] != 1.0 {
+		t.Errorf("expected the unsynced file to be ingested again, got %v", res)
+	}
+}
+
+func TestCLISyncEmptyFile(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+	skipIfNoOllama(t)
+	skipIfNoRedis(t)
+
+	dir := t.TempDir()
+	filePath := dir + "/empty.md"
+	os.WriteFile(filePath, []byte(""), 0644)
+
+	cleanupRedisKey(t, "sync:"+filePath)
+
+	out, err := runCLI(t, binary, "sync", "--file", filePath)
+	if err != nil {
+		t.Fatalf("sync failed: %v\n%s", err, out)
+	}
+
+	result := parseJSON(t, out)
+	if result["status"] != "ok" {
+		t.Fatalf("expected status ok, got %v", result["status"])
+	}
+	added, _ := result["added"].(float64)
+	if added != 0 {
+		t.Errorf("expected 0 chunks from empty file, got %v", added)
+	}
+}
+
+func TestCLISyncDirectory(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+	skipIfNoOllama(t)
+	skipIfNoRedis(t)
+
+	defer cleanupMemories(t)
+
+	dir := t.TempDir()
+	os.WriteFile(dir+"/one.md", []byte("Go is a statically typed language."), 0644)
+	os.WriteFile(dir+"/two.md", []byte("Rust provides memory safety without garbage collection."), 0644)
+
+	// Clean Redis keys
+	cleanupRedisKey(t, "sync:"+dir+"/one.md")
+	cleanupRedisKey(t, "sync:"+dir+"/two.md")
+	defer cleanupRedisKey(t, "sync:"+dir+"/one.md")
+	defer cleanupRedisKey(t, "sync:"+dir+"/two.md")
+
+	out, err := runCLI(t, binary, "sync", "--dir", dir)
+	if err != nil {
+		t.Fatalf("sync failed: %v\n%s", err, out)
+	}
+
+	result := parseJSON(t, out)
+	if result["status"] != "ok" {
+		t.Fatalf("expected status ok, got %v", result["status"])
+	}
+
+	files, _ := result["files"].(float64)
+	if files != 2 {
+		t.Errorf("expected 2 files, got %v", files)
+	}
+
+	added, _ := result["added"].(float64)
+	if added < 2 {
+		t.Errorf("expected at least 2 chunks added, got %v", added)
+	}
+}
+
+func TestCLISyncNoFiles(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+	skipIfNoOllama(t)
+	skipIfNoRedis(t)
+
+	dir := t.TempDir()
+
+	out, err := runCLI(t, binary, "sync", "--base", dir)
+	if err != nil {
+		t.Fatalf("sync failed: %v\n%s", err, out)
+	}
+
+	result := parseJSON(t, out)
+	if result["status"] != "ok" {
+		t.Fatalf("expected status ok, got %v", result["status"])
+	}
+	files, _ := result["files"].(float64)
+	if files != 0 {
+		t.Errorf("expected 0 files, got %v", files)
+	}
+}
+
+func TestCLISyncSkipsTodayDailyFile(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+	skipIfNoOllama(t)
+	skipIfNoRedis(t)
+
+	dir := t.TempDir()
+	today := time.Now().Format("2006-01-02")
+	todayFile := dir + "/" + today + ".md"
+	os.WriteFile(todayFile, []byte("Today's notes are still being written."), 0644)
+
+	out, err := runCLI(t, binary, "sync", "--file", todayFile)
+	if err != nil {
+		t.Fatalf("sync failed: %v\n%s", err, out)
+	}
+
+	result := parseJSON(t, out)
+	if result["status"] != "ok" {
+		t.Fatalf("expected status ok, got %v", result["status"])
+	}
+	added, _ := result["added"].(float64)
+	skipped, _ := result["skipped"].(float64)
+	if added != 0 {
+		t.Errorf("expected 0 added for today's file, got %v", added)
+	}
+	if skipped < 1 {
+		t.Errorf("expected today's file to be skipped, got skipped=%v", skipped)
+	}
+}
+
+func TestCLISyncMemoryMDResyncsOnChange(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+	skipIfNoOllama(t)
+	skipIfNoRedis(t)
+
+	defer cleanupMemories(t)
+
+	dir := t.TempDir()
+	memoryPath := dir + "/MEMORY.md"
+
+	// Write initial content
+	os.WriteFile(memoryPath, []byte("The preferred language is Go."), 0644)
+
+	// Clean Redis key (use absolute path since DiscoverFiles returns absolute paths)
+	absPath, _ := os.Getwd()
+	_ = absPath
+	cleanupRedisKey(t, "sync:"+memoryPath)
+	defer cleanupRedisKey(t, "sync:"+memoryPath)
+
+	// First sync — should add chunks
+	out, err := runCLI(t, binary, "sync", "--file", memoryPath)
+	if err != nil {
+		t.Fatalf("first sync failed: %v\n%s", err, out)
+	}
+	result1 := parseJSON(t, out)
+	added1, _ := result1["added"].(float64)
+	if added1 < 1 {
+		t.Fatalf("first sync should add chunks, got %v", added1)
+	}
+
+	// Second sync with same content — should skip (hash unchanged)
+	out, err = runCLI(t, binary, "sync", "--file", memoryPath)
+	if err != nil {
+		t.Fatalf("second sync failed: %v\n%s", err, out)
+	}
+	result2 := parseJSON(t, out)
+	added2, _ := result2["added"].(float64)
+	skipped2, _ := result2["skipped"].(float64)
+	if added2 != 0 {
+		t.Errorf("second sync (unchanged) should add 0, got %v", added2)
+	}
+	if skipped2 < 1 {
+		t.Errorf("second sync should skip MEMORY.md, got skipped=%v", skipped2)
+	}
+	// Check reason
+	results2 := result2["results"].([]any)
+	if len(results2) > 0 {
+		reason := results2[0].(map[string]any)["reason"]
+		if reason != "already synced (unchanged)" {
+			t.Errorf("expected reason 'already synced (unchanged)', got %v", reason)
+		}
+	}
+
+	// Update MEMORY.md content
+	os.WriteFile(memoryPath, []byte("The preferred language is Rust now."), 0644)
+
+	// Third sync — should re-sync because content hash changed
+	out, err = runCLI(t, binary, "sync", "--file", memoryPath)
+	if err != nil {
+		t.Fatalf("third sync failed: %v\n%s", err, out)
+	}
+	result3 := parseJSON(t, out)
+	added3, _ := result3["added"].(float64)
+	if added3 < 1 {
+		t.Fatalf("third sync (changed content) should add chunks, got %v", added3)
+	}
+}
+
+func TestCLISyncExcludeFlag(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+	skipIfNoOllama(t)
+	skipIfNoRedis(t)
+
+	defer cleanupMemories(t)
+
+	dir := t.TempDir()
+	os.WriteFile(dir+"/keep.md", []byte("This file should be synced."), 0644)
+	os.WriteFile(dir+"/skip.md", []byte("This file should be excluded."), 0644)
+
+	cleanupRedisKey(t, "sync:"+dir+"/keep.md")
+	cleanupRedisKey(t, "sync:"+dir+"/skip.md")
+	defer cleanupRedisKey(t, "sync:"+dir+"/keep.md")
+	defer cleanupRedisKey(t, "sync:"+dir+"/skip.md")
+
+	// Sync with --exclude skip.md
+	out, err := runCLI(t, binary, "sync", "--dir", dir, "--exclude", "skip.md")
+	if err != nil {
+		t.Fatalf("sync failed: %v\n%s", err, out)
+	}
+
+	result := parseJSON(t, out)
+	if result["status"] != "ok" {
+		t.Fatalf("expected status ok, got %v", result["status"])
+	}
+
+	// Should have processed 2 files but only added from keep.md
+	files, _ := result["files"].(float64)
+	if files != 2 {
+		t.Errorf("expected 2 discovered files, got %v", files)
+	}
+
+	added, _ := result["added"].(float64)
+	skipped, _ := result["skipped"].(float64)
+	if added < 1 {
+		t.Errorf("expected at least 1 added chunk (from keep.md), got %v", added)
+	}
+	if skipped < 1 {
+		t.Errorf("expected at least 1 skipped (excluded skip.md), got %v", skipped)
+	}
+
+	// Verify the excluded file's result reason
+	results := result["results"].([]any)
+	for _, r := range results {
+		fr := r.(map[string]any)
+		file := fr["file"].(string)
+		if filepath.Base(file) == "skip.md" {
+			reason, _ := fr["reason"].(string)
+			if reason != "excluded by ignore pattern" {
+				t.Errorf("expected reason 'excluded by ignore pattern' for skip.md, got %q", reason)
+			}
+		}
+	}
+}
+
+func TestCLISyncClawbrainIgnoreFile(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+	skipIfNoOllama(t)
+	skipIfNoRedis(t)
+
+	defer cleanupMemories(t)
+
+	dir := t.TempDir()
+	os.WriteFile(dir+"/keep.md", []byte("This file should be synced."), 0644)
+	os.WriteFile(dir+"/ignored.md", []byte("This file should be ignored via .clawbrain-ignore."), 0644)
+	os.WriteFile(dir+"/.clawbrain-ignore", []byte("ignored.md\n"), 0644)
+
+	cleanupRedisKey(t, "sync:"+dir+"/keep.md")
+	cleanupRedisKey(t, "sync:"+dir+"/ignored.md")
+	defer cleanupRedisKey(t, "sync:"+dir+"/keep.md")
+	defer cleanupRedisKey(t, "sync:"+dir+"/ignored.md")
+
+	// Sync with --base pointing to the dir (so .clawbrain-ignore is found)
+	out, err := runCLI(t, binary, "sync", "--dir", dir, "--base", dir)
+	if err != nil {
+		t.Fatalf("sync failed: %v\n%s", err, out)
+	}
+
+	result := parseJSON(t, out)
+	if result["status"] != "ok" {
+		t.Fatalf("expected status ok, got %v", result["status"])
+	}
+
+	added, _ := result["added"].(float64)
+	skipped, _ := result["skipped"].(float64)
+	if added < 1 {
+		t.Errorf("expected at least 1 added chunk (from keep.md), got %v", added)
+	}
+	if skipped < 1 {
+		t.Errorf("expected at least 1 skipped (ignored.md), got %v", skipped)
+	}
+
+	// Verify ignored.md has the right reason
+	results := result["results"].([]any)
+	for _, r := range results {
+		fr := r.(map[string]any)
+		file := fr["file"].(string)
+		if filepath.Base(file) == "ignored.md" {
+			reason, _ := fr["reason"].(string)
+			if reason != "excluded by ignore pattern" {
+				t.Errorf("expected reason 'excluded by ignore pattern' for ignored.md, got %q", reason)
+			}
+		}
+	}
+}
+
+// --- Forget command tests ---
+
+func TestCLIForgetNegativeTTL(t *testing.T) {
+	binary := buildBinary(t)
+	out, err := runCLI(t, binary, "forget", "--ttl", "-1h")
+	if err == nil {
+		t.Fatalf("expected error for negative ttl, got: %s", out)
+	}
+}
+
+func TestCLIForgetNegativeFrequencyWeight(t *testing.T) {
+	binary := buildBinary(t)
+	out, err := runCLI(t, binary, "forget", "--frequency-weight", "-1")
+	if err == nil {
+		t.Fatalf("expected error for negative frequency-weight, got: %s", out)
+	}
+	if result := parseJSON(t, out); result["message"] != "frequency-weight must be non-negative" {
+		t.Errorf("unexpected message %v", result["message"])
+	}
+}
+
+func TestCLIForgetSimulate(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+
+	cleanupMemories(t)
+	defer cleanupMemories(t)
+
+	for _, p := range []string{
+		`{"text": "simulated todo", "type": "todo"}`,
+		`{"text": "simulated note", "source": "/notes/a.md"}`,
+	} {
+		out, err := runCLI(t, binary, "add", "--no-merge",
+			"--vector", "[0.1, 0.2, 0.3, 0.4]",
+			"--payload", p,
+		)
+		if err != nil {
+			t.Fatalf("add failed: %v\n%s", err, out)
+		}
+	}
+	out, err := runCLI(t, binary, "add", "--no-merge", "--pinned",
+		"--vector", "[0.4, 0.3, 0.2, 0.1]",
+		"--payload", `{"text": "simulated pinned"}`,
+	)
+	if err != nil {
+		t.Fatalf("add pinned failed: %v\n%s", err, out)
+	}
+
+	// A zero TTL makes every unpinned memory a candidate.
+	out, err = runCLI(t, binary, "forget", "--simulate", "--ttl", "0s")
+	if err != nil {
+		t.Fatalf("forget --simulate failed: %v\n%s", err, out)
+	}
+
+	result := parseJSON(t, out)
+	if result["status"] != "ok" {
+		t.Fatalf("expected status ok, got %v", result["status"])
+	}
+	if result["forgotten"] != float64(2) {
+		t.Errorf("expected 2 forgotten, got %v", result["forgotten"])
+	}
+	if result["pinned"] != float64(1) {
+		t.Errorf("expected 1 pinned, got %v", result["pinned"])
+	}
+	byType := result["by_type"].(map[string]any)
+	if byType["todo"] != float64(1) || byType["untyped"] != float64(1) {
+		t.Errorf("unexpected by_type: %v", byType)
+	}
+	bySource := result["by_source"].(map[string]any)
+	if bySource["/notes/a.md"] != float64(1) || bySource["manual"] != float64(1) {
+		t.Errorf("unexpected by_source: %v", bySource)
+	}
+	if curve, ok := result["curve"].([]any); !ok || len(curve) == 0 {
+		t.Errorf("expected non-empty curve, got %v", result["curve"])
+	}
+
+	// Simulation must not delete anything.
+	s, err := store.New("localhost", 6334)
+	if err != nil {
+		t.Fatalf("store.New failed: %v", err)
+	}
+	defer s.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	count, err := s.Count(ctx)
+	if err != nil {
+		t.Fatalf("count failed: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("expected 3 memories after simulation, got %d", count)
+	}
+}
+
+func TestCLIForgetDryRun(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+
+	cleanupMemories(t)
+	defer cleanupMemories(t)
+
+	out, err := runCLI(t, binary, "add", "--no-merge",
+		"--vector", "[0.1, 0.2, 0.3, 0.4]", "--text", "stale sprint goal")
+	if err != nil {
+		t.Fatalf("add failed: %v\n%s", err, out)
+	}
+	staleID := parseJSON(t, out)["id"].(string)
+	out, err = runCLI(t, binary, "add", "--no-merge", "--pinned",
+		"--vector", "[0.4, 0.3, 0.2, 0.1]", "--text", "pinned fact")
+	if err != nil {
+		t.Fatalf("add pinned failed: %v\n%s", err, out)
+	}
+
+	out, err = runCLI(t, binary, "forget", "--dry-run", "--ttl", "0s")
+	if err != nil {
+		t.Fatalf("forget --dry-run failed: %v\n%s", err, out)
+	}
+	result := parseJSON(t, out)
+	if result["dry_run"] != true || result["would_archive"] != float64(1) || result["would_delete"] != float64(0) {
+		t.Fatalf("expected one memory to be listed for archiving, got %s", out)
+	}
+	m := result["memories"].([]any)[0].(map[string]any)
+	if m["id"] != staleID || m["text"] != "stale sprint goal" || m["last_accessed"] == nil || m["reason"] != "ttl" || m["action"] != "archive" {
+		t.Errorf("unexpected listing %v", m)
+	}
+
+	// Nothing is deleted.
+	out, err = runCLI(t, binary, "get", "--id", staleID)
+	if err != nil {
+		t.Errorf("expected the memory to survive a dry run: %v\n%s", err, out)
+	}
+}
+
+func TestCLIForgetTTL(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+
+	cleanupMemories(t)
+	defer cleanupMemories(t)
 
-	// Add the first memory in vector mode
 	out, err := runCLI(t, binary, "add",
-		"--vector", vector,
-		"--payload", payload,
+		"--vector", "[0.1, 0.2, 0.3, 0.4]",
+		"--payload", `{"text": "will be forgotten"}`,
+	)
+	if err != nil {
+		t.Fatalf("add failed: %v\n%s", err, out)
+	}
+
+	out, err = runCLI(t, binary, "forget", "--ttl", "0s")
+	if err != nil {
+		t.Fatalf("forget failed: %v\n%s", err, out)
+	}
+	result := parseJSON(t, out)
+	if result["archived"] != float64(1) || result["deleted"] != float64(0) {
+		t.Errorf("expected 1 memory archived and none deleted, got %s", out)
+	}
+
+	// Archived memories aren't archived again, and --hard deletes them.
+	out, err = runCLI(t, binary, "forget", "--ttl", "0s")
+	if err != nil {
+		t.Fatalf("second forget failed: %v\n%s", err, out)
+	}
+	if result := parseJSON(t, out); result["archived"] != float64(0) {
+		t.Errorf("expected nothing left to archive, got %s", out)
+	}
+	out, err = runCLI(t, binary, "forget", "--hard", "--ttl", "0s")
+	if err != nil {
+		t.Fatalf("forget --hard failed: %v\n%s", err, out)
+	}
+	if result := parseJSON(t, out); result["deleted"] != float64(1) {
+		t.Errorf("expected 1 deletion, got %s", out)
+	}
+}
+
+func TestCLIArchiveLifecycle(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+
+	cleanupMemories(t)
+	defer cleanupMemories(t)
+
+	var ids []string
+	for _, v := range []string{"[0.1, 0.2, 0.3, 0.4]", "[0.4, 0.3, 0.2, 0.1]"} {
+		out, err := runCLI(t, binary, "add", "--no-merge", "--vector", v, "--text", "archived "+v)
+		if err != nil {
+			t.Fatalf("add failed: %v\n%s", err, out)
+		}
+		ids = append(ids, parseJSON(t, out)["id"].(string))
+	}
+	if out, err := runCLI(t, binary, "forget", "--ttl", "0s"); err != nil {
+		t.Fatalf("forget failed: %v\n%s", err, out)
+	}
+
+	search := func(extra ...string) []any {
+		t.Helper()
+		args := append([]string{"search", "--vector", "[0.1, 0.2, 0.3, 0.4]", "--limit", "1"}, extra...)
+		out, err := runCLI(t, binary, args...)
+		if err != nil {
+			t.Fatalf("search failed: %v\n%s", err, out)
+		}
+		return parseJSON(t, out)["results"].([]any)
+	}
+	if results := search(); len(results) != 0 {
+		t.Fatalf("expected archived memories to be hidden, got %v", results)
+	}
+	// Found with --include-archived, the memory is restored.
+	results := search("--include-archived")
+	if len(results) != 1 || results[0].(map[string]any)["id"] != ids[0] {
+		t.Fatalf("expected the archived memory with --include-archived, got %v", results)
+	}
+	if results := search(); len(results) != 1 {
+		t.Errorf("expected the restored memory to be searchable again, got %v", results)
+	}
+
+	// The other one is still archived: purge removes it once its grace
+	// period is over.
+	out, err := runCLI(t, binary, "purge", "--archived", "--older-than", "1h")
+	if err != nil {
+		t.Fatalf("purge --archived failed: %v\n%s", err, out)
+	}
+	if result := parseJSON(t, out); result["deleted"] != float64(0) {
+		t.Errorf("expected nothing past a 1h grace period, got %s", out)
+	}
+	out, err = runCLI(t, binary, "purge", "--archived", "--older-than", "0s")
+	if err != nil {
+		t.Fatalf("purge --archived failed: %v\n%s", err, out)
+	}
+	if result := parseJSON(t, out); result["deleted"] != float64(1) {
+		t.Errorf("expected the archived memory to be purged, got %s", out)
+	}
+	if out, err := runCLI(t, binary, "get", "--id", ids[1]); err == nil {
+		t.Errorf("expected the purged memory to be gone\n%s", out)
+	}
+}
+
+func TestCLISandbox(t *testing.T) {
+	binary := buildBinary(t)
+	file := []string{"--backend", "file", "--path", t.TempDir()}
+	run := func(args ...string) map[string]any {
+		t.Helper()
+		out, err := runCLI(t, binary, append(file, args...)...)
+		if err != nil {
+			t.Fatalf("%v failed: %v\n%s", args, err, out)
+		}
+		return parseJSON(t, out)
+	}
+
+	doomed := run("add", "--no-merge", "--vector", "[1, 0, 0, 0]", "--text", "deploys go out on tuesdays")["id"].(string)
+	run("add", "--no-merge", "--vector", "[0, 1, 0, 0]", "--text", "the office has a blue door")
+
+	if res := run("sandbox", "create", "--name", "what-if", "--from", "memories"); res["copied"] != 2.0 {
+		t.Fatalf("expected both memories copied, got %v", res)
+	}
+	if out, err := runCLI(t, binary, append(file, "sandbox", "create", "--name", "what-if")...); err == nil {
+		t.Errorf("expected creating an existing sandbox without --replace to fail\n%s", out)
+	}
+	run("--sandbox", "what-if", "delete", "--id", doomed)
+	run("--sandbox", "what-if", "add", "--no-merge", "--vector", "[0, 0, 1, 0]", "--text", "a summary of the old notes")
+
+	res := run("sandbox", "diff", "--name", "what-if")
+	if res["removed"] != 1.0 || res["added"] != 1.0 || res["changed"] != 0.0 {
+		t.Fatalf("expected one memory removed and one added in the sandbox, got %v", res)
+	}
+	removed := res["memories"].(map[string]any)["removed"].([]any)
+	if removed[0].(map[string]any)["id"] != doomed {
+		t.Errorf("expected the deleted memory listed, got %v", removed)
+	}
+	if res := run("get", "--id", doomed); res["status"] != "ok" {
+		t.Errorf("expected the real memory untouched, got %v", res)
+	}
+	if list := run("sandbox", "list")["sandboxes"].([]any); len(list) != 1 {
+		t.Errorf("expected one sandbox, got %v", list)
+	}
+	if out, err := runCLI(t, binary, append(file, "--sandbox", "what-if", "sync")...); err == nil {
+		t.Errorf("expected sync to refuse to run in a sandbox\n%s", out)
+	}
+
+	run("sandbox", "drop", "--name", "what-if")
+	if list := run("sandbox", "list")["sandboxes"].([]any); len(list) != 0 {
+		t.Errorf("expected the sandbox gone, got %v", list)
+	}
+}
+
+func TestCLIPurgeFlags(t *testing.T) {
+	binary := buildBinary(t)
+
+	tests := map[string][]string{
+		"missing entity":       {"purge", "--dry-run"},
+		"blank entity":         {"purge", "--entity", " "},
+		"zero min-score":       {"purge", "--entity", "John Doe", "--min-score", "0"},
+		"archived with entity": {"purge", "--archived", "--entity", "John Doe"},
+		"older-than alone":     {"purge", "--entity", "John Doe", "--older-than", "30d"},
+	}
+	for name, args := range tests {
+		t.Run(name, func(t *testing.T) {
+			out, err := runCLI(t, binary, args...)
+			if err == nil {
+				t.Fatalf("expected error\n%s", out)
+			}
+			if parseJSON(t, out)["status"] != "error" {
+				t.Errorf("expected status error\n%s", out)
+			}
+		})
+	}
+}
+
+func TestCLIPurgeEntity(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+	ollamaURL := fakeOllama(t).URL
+
+	defer cleanupMemories(t)
+
+	add := func(vector, payload string, extra ...string) string {
+		t.Helper()
+		args := append([]string{"add", "--no-merge", "--vector", vector, "--payload", payload}, extra...)
+		out, err := runCLI(t, binary, args...)
+		if err != nil {
+			t.Fatalf("add failed: %v\n%s", err, out)
+		}
+		return parseJSON(t, out)["id"].(string)
+	}
+	other := "[0.1, 0.2, 0.3, 0.4]"
+	named := add(other, `{"text": "John Doe likes green tea"}`)
+	spoken := add(other, `{"text": "I prefer morning meetings"}`, "--speaker", "John Doe")
+	// The fake embedder returns this vector for every text, so the entity
+	// embeds right on top of it.
+	similar := add("[0.3, 0.1, 0.4, 0.1]", `{"text": "his daughter starts school in september"}`)
+	locked := add(other, `{"text": "John Doe signed the contract"}`)
+	kept := add(other, `{"text": "deploys go out on tuesdays"}`)
+	if out, err := runCLI(t, binary, "lock", "--id", locked); err != nil {
+		t.Fatalf("lock failed: %v\n%s", err, out)
+	}
+
+	purgeIDs := func(result map[string]any) map[string]bool {
+		ids := map[string]bool{}
+		for _, m := range result["memories"].([]any) {
+			ids[m.(map[string]any)["id"].(string)] = true
+		}
+		return ids
+	}
+
+	out, err := runCLI(t, binary, "--ollama-url", ollamaURL, "purge", "--entity", "john doe", "--min-score", "0.9", "--dry-run")
+	if err != nil {
+		t.Fatalf("dry run failed: %v\n%s", err, out)
+	}
+	result := parseJSON(t, out)
+	ids := purgeIDs(result)
+	if result["dry_run"] != true || result["matched"] != float64(4) {
+		t.Fatalf("expected a dry run matching 4 memories, got %v", result)
+	}
+	for _, id := range []string{named, spoken, similar, locked} {
+		if !ids[id] {
+			t.Errorf("expected %s in the dry run", id)
+		}
+	}
+	if ids[kept] {
+		t.Error("unrelated memory matched")
+	}
+
+	out, err = runCLI(t, binary, "--ollama-url", ollamaURL, "purge", "--entity", "john doe", "--min-score", "0.9")
+	if err != nil {
+		t.Fatalf("purge failed: %v\n%s", err, out)
+	}
+	result = parseJSON(t, out)
+	if result["deleted"] != float64(3) {
+		t.Errorf("expected 3 deletions (the locked memory is kept), got %v", result["deleted"])
+	}
+	for id, want := range map[string]bool{named: false, spoken: false, similar: false, locked: true, kept: true} {
+		_, err := runCLI(t, binary, "get", "--id", id)
+		if got := err == nil; got != want {
+			t.Errorf("memory %s exists = %v, want %v", id, got, want)
+		}
+	}
+}
+
+func TestCLIPurgeArchive(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+
+	defer cleanupMemories(t)
+
+	out, err := runCLI(t, binary, "add", "--no-merge", "--vector", "[0.1, 0.2, 0.3, 0.4]",
+		"--payload", `{"text": "Jane Roe asked for a refund"}`)
+	if err != nil {
+		t.Fatalf("add failed: %v\n%s", err, out)
+	}
+	id := parseJSON(t, out)["id"].(string)
+
+	out, err = runCLI(t, binary, "purge", "--entity", "Jane Roe", "--no-embedding", "--archive")
+	if err != nil {
+		t.Fatalf("purge failed: %v\n%s", err, out)
+	}
+	if parseJSON(t, out)["archived"] != float64(1) {
+		t.Fatalf("expected 1 archived memory\n%s", out)
+	}
+
+	out, err = runCLI(t, binary, "search", "--vector", "[0.1, 0.2, 0.3, 0.4]", "--limit", "10")
+	if err != nil {
+		t.Fatalf("search failed: %v\n%s", err, out)
+	}
+	if results, _ := parseJSON(t, out)["results"].([]any); len(results) != 0 {
+		t.Errorf("expected archived memory hidden from search, got %v", results)
+	}
+
+	out, err = runCLI(t, binary, "get", "--id", id)
+	if err != nil {
+		t.Fatalf("get failed: %v\n%s", err, out)
+	}
+	if parseJSON(t, out)["payload"].(map[string]any)["archived"] != true {
+		t.Errorf("expected archived payload\n%s", out)
+	}
+}
+
+func TestCLIHygieneFlags(t *testing.T) {
+	binary := buildBinary(t)
+
+	for name, args := range map[string][]string{
+		"unknown action": {"hygiene", "--action", "burn"},
+		"negative limit": {"hygiene", "--limit", "-1"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			out, err := runCLI(t, binary, args...)
+			if err == nil {
+				t.Fatalf("expected error\n%s", out)
+			}
+			if parseJSON(t, out)["status"] != "error" {
+				t.Errorf("expected status error\n%s", out)
+			}
+		})
+	}
+}
+
+func TestCLIHygiene(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+
+	defer cleanupMemories(t)
+
+	add := func(payload string) string {
+		t.Helper()
+		out, err := runCLI(t, binary, "add", "--no-merge", "--vector", "[0.1, 0.2, 0.3, 0.4]", "--payload", payload)
+		if err != nil {
+			t.Fatalf("add failed: %v\n%s", err, out)
+		}
+		return parseJSON(t, out)["id"].(string)
+	}
+	superseded := add(`{"text": "staging runs postgres 13", "superseded_by": "postgres 16 upgrade"}`)
+	unsure := add(`{"text": "the vendor might be called acme", "confidence": "low"}`)
+	orphan := add(`{"text": "old note", "source": "/nonexistent/clawbrain/notes.md"}`)
+	add(`{"text": "deploys go out on tuesdays"}`)
+
+	out, err := runCLI(t, binary, "hygiene")
+	if err != nil {
+		t.Fatalf("hygiene failed: %v\n%s", err, out)
+	}
+	result := parseJSON(t, out)
+	if result["total"] != float64(4) || result["needs_action"] != float64(3) {
+		t.Fatalf("expected 3 of 4 memories to need action, got %v", result)
+	}
+	results := result["results"].([]any)
+	want := map[string]string{superseded: "delete", unsure: "confirm", orphan: "confirm"}
+	for _, r := range results {
+		m := r.(map[string]any)
+		if want[m["id"].(string)] != m["action"] {
+			t.Errorf("memory %v: action %v", m["text"], m["action"])
+		}
+	}
+	if first := results[0].(map[string]any); first["id"] != superseded || first["score"] != float64(0) {
+		t.Errorf("expected the superseded memory first with score 0, got %v", first)
+	}
+
+	out, err = runCLI(t, binary, "hygiene", "--action", "delete")
+	if err != nil {
+		t.Fatalf("hygiene --action failed: %v\n%s", err, out)
+	}
+	if got := parseJSON(t, out)["returned"]; got != float64(1) {
+		t.Errorf("expected 1 delete recommendation, got %v", got)
+	}
+}
+
+// --- Retention report tests ---
+
+func TestCLIForgetCompressFlags(t *testing.T) {
+	binary := buildBinary(t)
+
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"with simulate", []string{"forget", "--compress", "--simulate"}},
+		{"with dry run", []string{"forget", "--compress", "--dry-run"}},
+		{"dry run with simulate", []string{"forget", "--dry-run", "--simulate"}},
+		{"hard with compress", []string{"forget", "--compress", "--hard"}},
+		{"zero group size", []string{"forget", "--compress", "--group-size", "0"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := runCLI(t, binary, tt.args...)
+			if err == nil {
+				t.Fatalf("expected error\n%s", out)
+			}
+			if parseJSON(t, out)["status"] != "error" {
+				t.Errorf("expected status error\n%s", out)
+			}
+		})
+	}
+}
+
+// fakeOllama serves /api/generate and /api/embed so compression and image
+// captioning can be tested without those models installed. Every embedding is
+// the same 4-dim vector; every summary is "summary of N notes" and every
+// image caption is fakeCaption.
+const fakeCaption = "A login form showing the error: invalid session token."
+
+// hashingOllama is an Ollama embed endpoint that gives each text its own
+// vector, derived from its hash, so different texts never deduplicate. It
+// counts the texts it embeds in embedded.
+func hashingOllama(t *testing.T, embedded *atomic.Int64) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct{ Input any }
+		json.NewDecoder(r.Body).Decode(&req)
+		inputs, ok := req.Input.([]any)
+		if !ok {
+			inputs = []any{req.Input}
+		}
+		embeddings := make([][]float64, len(inputs))
+		for i, in := range inputs {
+			sum := sha256.Sum256([]byte(fmt.Sprint(in)))
+			for _, b := range sum[:16] {
+				embeddings[i] = append(embeddings[i], float64(b)-127.5)
+			}
+		}
+		embedded.Add(int64(len(inputs)))
+		json.NewEncoder(w).Encode(map[string]any{"embeddings": embeddings})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// wordsOllama is an Ollama whose embeddings count words, hashed into 64
+// dimensions, so texts sharing words are similar, as they would be to a
+// real model, and a paraphrase can be found by its meaning.
+func wordsOllama(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct{ Input any }
+		json.NewDecoder(r.Body).Decode(&req)
+		inputs, ok := req.Input.([]any)
+		if !ok {
+			inputs = []any{req.Input}
+		}
+		embeddings := make([][]float64, len(inputs))
+		for i, in := range inputs {
+			embeddings[i] = make([]float64, 64)
+			for _, word := range strings.Fields(strings.ToLower(fmt.Sprint(in))) {
+				sum := sha256.Sum256([]byte(strings.Trim(word, ".,:;")))
+				embeddings[i][sum[0]%64]++
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]any{"embeddings": embeddings})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func fakeOllama(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]any
+		json.NewDecoder(r.Body).Decode(&req)
+		switch r.URL.Path {
+		case "/api/generate":
+			if _, ok := req["images"]; ok {
+				json.NewEncoder(w).Encode(map[string]any{"response": fakeCaption})
+				return
+			}
+			prompt, _ := req["prompt"].(string)
+			notes := strings.Count(prompt, "\n- ")
+			json.NewEncoder(w).Encode(map[string]any{"response": fmt.Sprintf("summary of %d notes", notes)})
+		case "/api/embed":
+			// One embedding per input; batched requests send a list.
+			n := 1
+			if inputs, ok := req["input"].([]any); ok {
+				n = len(inputs)
+			}
+			embeddings := make([][]float64, n)
+			for i := range embeddings {
+				embeddings[i] = []float64{0.3, 0.1, 0.4, 0.1}
+			}
+			json.NewEncoder(w).Encode(map[string]any{"embeddings": embeddings})
+		case "/v1/embeddings":
+			// The OpenAI-compatible API, for --embedder openai. Vectors
+			// are 3-dim so they can't be mistaken for Ollama's.
+			if r.Header.Get("Authorization") != "Bearer sk-test" {
+				http.Error(w, `{"error":{"message":"bad key"}}`, http.StatusUnauthorized)
+				return
+			}
+			inputs, _ := req["input"].([]any)
+			data := make([]map[string]any, len(inputs))
+			for i := range data {
+				data[i] = map[string]any{"index": i, "embedding": []float64{0.2, 0.7, 0.1}}
+			}
+			json.NewEncoder(w).Encode(map[string]any{"data": data})
+		case "/api/version":
+			json.NewEncoder(w).Encode(map[string]any{"version": "0.9.0"})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestCLIForgetCompress(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+	ollamaURL := fakeOllama(t).URL
+
+	defer cleanupMemories(t)
+
+	for _, v := range []string{"[1, 0, 0, 0]", "[0, 1, 0, 0]", "[0, 0, 1, 0]"} {
+		out, err := runCLI(t, binary, "add", "--no-merge",
+			"--vector", v,
+			"--payload", `{"text": "note `+v+`", "type": "lesson", "source": "/ws/notes.md"}`,
+		)
+		if err != nil {
+			t.Fatalf("add failed: %v\n%s", err, out)
+		}
+	}
+	out, err := runCLI(t, binary, "add", "--pinned",
+		"--vector", "[0, 0, 0, 1]",
+		"--payload", `{"text": "pinned note"}`,
 	)
 	if err != nil {
-		t.Fatalf("first add failed: %v\n%s", err, out)
+		t.Fatalf("add pinned failed: %v\n%s", err, out)
+	}
+
+	out, err = runCLI(t, binary, "--ollama-url", ollamaURL, "forget", "--compress", "--ttl", "0s")
+	if err != nil {
+		t.Fatalf("forget --compress failed: %v\n%s", err, out)
+	}
+	result := parseJSON(t, out)
+	if result["compressed"] != float64(3) {
+		t.Fatalf("expected 3 memories compressed, got %v\n%s", result["compressed"], out)
+	}
+	summaries, _ := result["summaries"].([]any)
+	if len(summaries) != 1 {
+		t.Fatalf("expected 1 summary, got %v", summaries)
+	}
+	summaryID := summaries[0].(map[string]any)["id"].(string)
+
+	out, err = runCLI(t, binary, "get", "--id", summaryID)
+	if err != nil {
+		t.Fatalf("get summary failed: %v\n%s", err, out)
+	}
+	payload := parseJSON(t, out)["payload"].(map[string]any)
+	if payload["text"] != "summary of 3 notes" || payload["compressed"] != true {
+		t.Errorf("unexpected summary payload: %v", payload)
+	}
+	if payload["type"] != "lesson" || payload["source"] != "/ws/notes.md" {
+		t.Errorf("expected summary to keep type and source, got %v", payload)
+	}
+	if from, _ := payload["compressed_from"].([]any); len(from) != 3 {
+		t.Errorf("expected 3 compressed_from IDs, got %v", payload["compressed_from"])
+	}
+
+	// Originals are gone; the summary and the pinned memory remain.
+	s, err := store.New("localhost", 6334)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if n, err := s.Count(ctx); err != nil || n != 2 {
+		t.Errorf("expected 2 memories left (summary + pinned), got %d, %v", n, err)
+	}
+}
+
+func TestCLIRetentionReportInvalidFormat(t *testing.T) {
+	binary := buildBinary(t)
+	out, err := runCLI(t, binary, "retention-report", "--format", "xml")
+	if err == nil {
+		t.Fatalf("expected error for unknown format, got: %s", out)
+	}
+}
+
+func TestCLIRetentionReport(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+
+	cleanupMemories(t)
+	defer cleanupMemories(t)
+
+	auditLog := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	for _, p := range []string{
+		`{"text": "report fact", "type": "fact"}`,
+		`{"text": "report stale"}`,
+	} {
+		out, err := runCLI(t, binary, "add", "--no-merge",
+			"--vector", "[0.1, 0.2, 0.3, 0.4]",
+			"--payload", p,
+		)
+		if err != nil {
+			t.Fatalf("add failed: %v\n%s", err, out)
+		}
+	}
+
+	// Forget everything with the audit log enabled so a deletion is recorded.
+	out, err := runCLI(t, binary, "--audit-log", auditLog, "forget", "--hard", "--ttl", "0s")
+	if err != nil {
+		t.Fatalf("forget failed: %v\n%s", err, out)
 	}
-	first := parseJSON(t, out)
-	firstID := first["id"].(string)
 
-	// Add the same vector again — should trigger merge
 	out, err = runCLI(t, binary, "add",
-		"--vector", vector,
-		"--payload", payload,
+		"--vector", "[0.4, 0.3, 0.2, 0.1]",
+		"--payload", `{"text": "report survivor", "type": "lesson"}`,
 	)
 	if err != nil {
-		t.Fatalf("second add failed: %v\n%s", err, out)
+		t.Fatalf("add failed: %v\n%s", err, out)
 	}
-	second := parseJSON(t, out)
 
-	if second["merged_id"] != firstID {
-		t.Errorf("expected merged_id %q, got %v", firstID, second["merged_id"])
+	out, err = runCLI(t, binary, "--audit-log", auditLog, "retention-report")
+	if err != nil {
+		t.Fatalf("retention-report failed: %v\n%s", err, out)
+	}
+	result := parseJSON(t, out)
+	report := result["report"].(map[string]any)
+	if report["total"] != float64(1) {
+		t.Errorf("expected 1 memory in report, got %v", report["total"])
+	}
+	byType := report["by_type"].(map[string]any)
+	if _, ok := byType["lesson"]; !ok {
+		t.Errorf("expected lesson type in report, got %v", byType)
+	}
+	deletions := report["deletions"].(map[string]any)
+	if deletions["audit_enabled"] != true || deletions["total"] != float64(2) {
+		t.Errorf("expected 2 audited deletions, got %v", deletions)
+	}
+
+	mdPath := filepath.Join(t.TempDir(), "report.md")
+	out, err = runCLI(t, binary, "--audit-log", auditLog, "retention-report", "--format", "markdown", "--out", mdPath)
+	if err != nil {
+		t.Fatalf("retention-report markdown failed: %v\n%s", err, out)
+	}
+	md, err := os.ReadFile(mdPath)
+	if err != nil {
+		t.Fatalf("read markdown report: %v", err)
+	}
+	if !strings.Contains(string(md), "# ClawBrain Retention Report") {
+		t.Errorf("unexpected markdown report:\n%s", md)
 	}
 }
 
-func TestCLIDedupMergeMultipleDuplicates(t *testing.T) {
+// --- Tag command tests ---
+
+func TestCLITagMissingFlags(t *testing.T) {
+	binary := buildBinary(t)
+
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"no action", []string{"tag"}},
+		{"unknown action", []string{"tag", "rename", "--tag", "x", "--filter", "type=todo"}},
+		{"no tag", []string{"tag", "add", "--filter", "type=todo"}},
+		{"no filter or id", []string{"tag", "add", "--tag", "x"}},
+		{"invalid filter", []string{"tag", "add", "--tag", "x", "--filter", "type"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if out, err := runCLI(t, binary, tt.args...); err == nil {
+				t.Fatalf("expected error, got: %s", out)
+			}
+		})
+	}
+}
+
+func TestCLITagAddRemove(t *testing.T) {
 	binary := buildBinary(t)
 	skipIfNoQdrant(t, binary)
-	skipIfNoOllama(t)
 
+	cleanupMemories(t)
 	defer cleanupMemories(t)
 
-	// Create 3 duplicates using --no-merge so they all coexist
-	ids := make([]string, 3)
-	for i := 0; i < 3; i++ {
-		out, err := runCLI(t, binary, "add",
-			"--text", "the capital of France is Paris",
-			"--no-merge",
+	var oldID string
+	for i, p := range []string{
+		`{"text": "old note", "source": "/old/notes/a.md"}`,
+		`{"text": "new note", "source": "/new/notes/b.md"}`,
+	} {
+		out, err := runCLI(t, binary, "add", "--no-merge",
+			"--vector", "[0.1, 0.2, 0.3, 0.4]",
+			"--payload", p,
 		)
 		if err != nil {
-			t.Fatalf("add #%d failed: %v\n%s", i+1, err, out)
+			t.Fatalf("add failed: %v\n%s", err, out)
 		}
-		r := parseJSON(t, out)
-		if r["status"] != "ok" {
-			t.Fatalf("add #%d: expected status ok, got %v", i+1, r["status"])
+		if i == 0 {
+			oldID = parseJSON(t, out)["id"].(string)
 		}
-		ids[i] = r["id"].(string)
 	}
 
-	// Verify all 3 exist
-	out, err := runCLI(t, binary, "search",
-		"--query", "the capital of France is Paris",
-		"--limit", "10",
-	)
+	out, err := runCLI(t, binary, "tag", "add", "--dry-run", "--tag", "obsolete", "--filter", "source=/old/notes/")
 	if err != nil {
-		t.Fatalf("search failed: %v\n%s", err, out)
+		t.Fatalf("tag add --dry-run failed: %v\n%s", err, out)
 	}
-	search := parseJSON(t, out)
-	if int(search["returned"].(float64)) != 3 {
-		t.Fatalf("expected 3 memories before merge, got %v", search["returned"])
+	result := parseJSON(t, out)
+	changes, _ := result["changes"].([]any)
+	if result["would_update"] != float64(1) || len(changes) != 1 {
+		t.Fatalf("expected one change previewed, got %v", result)
+	}
+	if after, _ := changes[0].(map[string]any)["after"].([]any); len(after) != 1 || after[0] != "obsolete" {
+		t.Errorf("expected tags [obsolete] after, got %v", changes[0])
 	}
 
-	// Add the same text WITH merge enabled — should delete all 3 and replace
-	out, err = runCLI(t, binary, "add",
-		"--text", "the capital of France is Paris",
-	)
+	out, err = runCLI(t, binary, "tag", "add", "--tag", "obsolete", "--tag", "archive-me", "--filter", "source=/old/notes/")
 	if err != nil {
-		t.Fatalf("merge add failed: %v\n%s", err, out)
+		t.Fatalf("tag add failed: %v\n%s", err, out)
 	}
-	result := parseJSON(t, out)
-	if result["status"] != "ok" {
-		t.Fatalf("expected status ok, got %v", result["status"])
+	result = parseJSON(t, out)
+	if result["matched"] != float64(1) || result["updated"] != float64(1) {
+		t.Fatalf("expected 1 matched/updated, got %v", result)
 	}
 
-	// merged_ids should contain all 3 old IDs
-	mergedIDs, ok := result["merged_ids"].([]any)
-	if !ok {
-		t.Fatalf("expected merged_ids to be an array, got %T: %v", result["merged_ids"], result["merged_ids"])
+	// Re-adding the same tags is a no-op.
+	out, err = runCLI(t, binary, "tag", "add", "--tag", "obsolete", "--filter", "source=/old/notes/")
+	if err != nil {
+		t.Fatalf("tag add failed: %v\n%s", err, out)
 	}
-	if len(mergedIDs) != 3 {
-		t.Errorf("expected 3 merged_ids, got %d: %v", len(mergedIDs), mergedIDs)
+	if parseJSON(t, out)["updated"] != float64(0) {
+		t.Errorf("expected re-tagging to update nothing, got %s", out)
 	}
 
-	// Backward compat: merged_id should still be present
-	if result["merged_id"] == nil {
-		t.Errorf("expected merged_id for backward compat, got nil")
+	out, err = runCLI(t, binary, "tag", "remove", "--tag", "obsolete", "--id", oldID)
+	if err != nil {
+		t.Fatalf("tag remove failed: %v\n%s", err, out)
+	}
+	if parseJSON(t, out)["updated"] != float64(1) {
+		t.Errorf("expected 1 updated on remove, got %s", out)
 	}
 
-	// There should be exactly 1 memory now
-	out, err = runCLI(t, binary, "search",
-		"--query", "the capital of France is Paris",
-		"--limit", "10",
-	)
+	out, err = runCLI(t, binary, "get", "--id", oldID)
 	if err != nil {
-		t.Fatalf("search after merge failed: %v\n%s", err, out)
+		t.Fatalf("get failed: %v\n%s", err, out)
 	}
-	search = parseJSON(t, out)
-	returned := int(search["returned"].(float64))
-	if returned != 1 {
-		t.Fatalf("expected 1 memory after merge, got %d", returned)
+	tags, _ := parseJSON(t, out)["payload"].(map[string]any)["tags"].([]any)
+	if len(tags) != 1 || tags[0] != "archive-me" {
+		t.Errorf("expected tags [archive-me], got %v", tags)
 	}
 }
 
-func TestCLIDedupMergeMultiplePreservesOldestCreatedAt(t *testing.T) {
+func TestCLITagsRejects(t *testing.T) {
+	binary := buildBinary(t)
+	for _, args := range [][]string{
+		{"tags", "--limit", "-1"},
+		{"search", "--query", "deploys", "--tag", ""},
+	} {
+		out, err := runCLI(t, binary, args...)
+		if err == nil {
+			t.Errorf("expected error for %v, got: %s", args, out)
+			continue
+		}
+		if result := parseJSON(t, out); result["status"] != "error" {
+			t.Errorf("expected status error for %v, got %v", args, result)
+		}
+	}
+}
+
+func TestCLITags(t *testing.T) {
 	binary := buildBinary(t)
 	skipIfNoQdrant(t, binary)
-	skipIfNoOllama(t)
 
+	cleanupMemories(t)
 	defer cleanupMemories(t)
 
-	// Add first memory normally (will get the earliest created_at)
-	out, err := runCLI(t, binary, "add",
-		"--text", "dogs are loyal companions",
-	)
+	for _, m := range []struct {
+		vector string
+		tags   []string
+	}{
+		{"[0.1, 0.2, 0.3, 0.4]", []string{"project:billing", "priority:high"}},
+		{"[0.4, 0.3, 0.2, 0.1]", []string{"project:billing"}},
+		{"[0.2, 0.1, 0.4, 0.3]", []string{"project:link-tracker"}},
+	} {
+		args := []string{"add", "--no-merge", "--vector", m.vector, "--payload", `{"text": "tagged note"}`}
+		for _, tag := range m.tags {
+			args = append(args, "--tag", tag)
+		}
+		if out, err := runCLI(t, binary, args...); err != nil {
+			t.Fatalf("add failed: %v\n%s", err, out)
+		}
+	}
+
+	out, err := runCLI(t, binary, "tags", "--prefix", "project:")
 	if err != nil {
-		t.Fatalf("first add failed: %v\n%s", err, out)
+		t.Fatalf("tags failed: %v\n%s", err, out)
+	}
+	result := parseJSON(t, out)
+	tags, _ := result["tags"].([]any)
+	if result["total"] != 2.0 || len(tags) != 2 {
+		t.Fatalf("expected two project tags, got %v", result)
+	}
+	if top := tags[0].(map[string]any); top["tag"] != "project:billing" || top["count"] != 2.0 {
+		t.Errorf("expected project:billing on 2 memories first, got %v", top)
 	}
-	first := parseJSON(t, out)
-	firstID := first["id"].(string)
 
-	// Fetch original created_at
-	out, err = runCLI(t, binary, "get", "--id", firstID)
+	out, err = runCLI(t, binary, "search", "--vector", "[0.1, 0.2, 0.3, 0.4]", "--limit", "10",
+		"--tag", "project:billing", "--tag", "priority:high")
 	if err != nil {
-		t.Fatalf("get failed: %v\n%s", err, out)
+		t.Fatalf("search failed: %v\n%s", err, out)
 	}
-	firstGet := parseJSON(t, out)
-	originalCreatedAt := firstGet["payload"].(map[string]any)["created_at"].(string)
+	if returned := parseJSON(t, out)["returned"]; returned != 1.0 {
+		t.Errorf("expected only the memory with both tags, got %v", returned)
+	}
+}
 
-	time.Sleep(1100 * time.Millisecond)
+// --- Resource move tests ---
 
-	// Add a second duplicate via --no-merge
-	out, err = runCLI(t, binary, "add",
-		"--text", "dogs are loyal companions",
-		"--no-merge",
-	)
-	if err != nil {
-		t.Fatalf("second add failed: %v\n%s", err, out)
+func TestCLIResourceMoveMissingFlags(t *testing.T) {
+	binary := buildBinary(t)
+	for _, args := range [][]string{
+		{"resource"},
+		{"resource", "move"},
+		{"resource", "move", "--from", "/old"},
+	} {
+		if out, err := runCLI(t, binary, args...); err == nil {
+			t.Errorf("expected error for %v, got: %s", args, out)
+		}
 	}
+}
 
-	time.Sleep(1100 * time.Millisecond)
+func TestCLIResourceMove(t *testing.T) {
+	binary := buildBinary(t)
+	skipIfNoQdrant(t, binary)
+	skipIfNoOllama(t)
+	skipIfNoRedis(t)
 
-	// Now merge all — should preserve the original (oldest) created_at
-	out, err = runCLI(t, binary, "add",
-		"--text", "dogs are loyal companions",
-	)
+	cleanupMemories(t)
+	defer cleanupMemories(t)
+
+	oldDir := filepath.Join(t.TempDir(), "old")
+	newDir := filepath.Join(t.TempDir(), "new")
+	if err := os.MkdirAll(oldDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	notePath := filepath.Join(oldDir, "note.md")
+	if err := os.WriteFile(notePath, []byte("The staging server lives in Frankfurt."), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	movedPath := filepath.Join(newDir, "note.md")
+	defer cleanupRedisKey(t, "sync:"+notePath)
+	defer cleanupRedisKey(t, "sync:"+movedPath)
+
+	out, err := runCLI(t, binary, "sync", "--file", notePath)
 	if err != nil {
-		t.Fatalf("merge add failed: %v\n%s", err, out)
+		t.Fatalf("sync failed: %v\n%s", err, out)
+	}
+
+	out, err = runCLI(t, binary, "resource", "move", "--from", oldDir, "--to", newDir)
+	if err != nil {
+		t.Fatalf("resource move failed: %v\n%s", err, out)
 	}
 	result := parseJSON(t, out)
-	newID := result["id"].(string)
+	if result["updated"] != float64(1) {
+		t.Errorf("expected 1 updated memory, got %v", result["updated"])
+	}
+	if result["sync_keys_moved"] != float64(1) {
+		t.Errorf("expected 1 sync key moved, got %v", result["sync_keys_moved"])
+	}
 
-	out, err = runCLI(t, binary, "get", "--id", newID)
+	// The file now lives at its new path; sync must recognise it as done.
+	if err := os.MkdirAll(newDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(notePath, movedPath); err != nil {
+		t.Fatal(err)
+	}
+	out, err = runCLI(t, binary, "sync", "--file", movedPath)
 	if err != nil {
-		t.Fatalf("get merged failed: %v\n%s", err, out)
+		t.Fatalf("sync after move failed: %v\n%s", err, out)
 	}
-	merged := parseJSON(t, out)
-	mergedCreatedAt := merged["payload"].(map[string]any)["created_at"].(string)
-
-	if mergedCreatedAt != originalCreatedAt {
-		t.Errorf("created_at not preserved from oldest duplicate: original=%s, merged=%s", originalCreatedAt, mergedCreatedAt)
+	if added := parseJSON(t, out)["added"]; added != float64(0) {
+		t.Errorf("expected moved file to be skipped, got added=%v", added)
 	}
 }
 
-// --- Collections command removed ---
+func TestMain(m *testing.M) {
+	os.Exit(m.Run())
+}
 
-func TestCLICollectionsCommandRemoved(t *testing.T) {
+func TestCLISyncStateBackends(t *testing.T) {
 	binary := buildBinary(t)
-	_, err := runCLI(t, binary, "collections")
-	if err == nil {
-		t.Fatal("expected error for removed 'collections' command")
+	var embedded atomic.Int64
+	ollama := hashingOllama(t, &embedded)
+
+	for _, backend := range []string{"file", "qdrant"} {
+		t.Run(backend, func(t *testing.T) {
+			dir, data := t.TempDir(), t.TempDir()
+			note := filepath.Join(dir, "note.md")
+			if err := os.WriteFile(note, []byte("# Note\n\nShip on tuesdays."), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			// No Redis listens on port 1: the state must live elsewhere.
+			cli := func(args ...string) map[string]any {
+				t.Helper()
+				args = append([]string{"--backend", "file", "--path", data, "--redis-port", "1",
+					"--state-backend", backend, "--ollama-url", ollama.URL}, args...)
+				out, err := runCLI(t, binary, args...)
+				if err != nil {
+					t.Fatalf("%v failed: %v\n%s", args, err, out)
+				}
+				return parseJSON(t, out)
+			}
+
+			if result := cli("sync", "--dir", dir); result["added"].(float64) != 1 {
+				t.Fatalf("expected the note added, got %v", result)
+			}
+			result := cli("sync", "--dir", dir)
+			if r := result["results"].([]any)[0].(map[string]any); r["reason"] != "already synced" {
+				t.Errorf("expected the second sync to skip the note, got %v", result)
+			}
+			if result := cli("unsync", "--file", note); result["deleted"].(float64) != 1 {
+				t.Errorf("expected unsync to delete the note's memory, got %v", result)
+			}
+			if result := cli("sync", "--dir", dir); result["added"].(float64) != 1 {
+				t.Errorf("expected the note synced afresh after unsync, got %v", result)
+			}
+		})
 	}
-}
 
-// --- Env var tests ---
+	out, err := runCLI(t, binary, "--backend", "file", "--path", t.TempDir(), "--state-backend", "etcd", "sync")
+	if err == nil || !strings.Contains(string(out), "unknown state backend") {
+		t.Errorf("expected an unknown state backend to be rejected\n%s", out)
+	}
+}
 
-// TestCLIEnvPort verifies that CLAWBRAIN_PORT is read from the environment.
-// exec.Command inherits the parent's env, so t.Setenv propagates to the
-// subprocess and the binary's init() picks it up before flag parsing.
-//
-// The two subtests together prove the env var is actually used:
-//   - CLAWBRAIN_PORT=6334 (valid) must succeed — proves the var is read
-//   - CLAWBRAIN_PORT=9999 (unreachable) must fail — distinguishes "env var
-//     read and used" from "env var ignored, default 6334 used accidentally"
-func TestCLIEnvPort(t *testing.T) {
+func TestCLIOrient(t *testing.T) {
 	binary := buildBinary(t)
-	skipIfNoQdrant(t, binary)
-	skipIfNoOllama(t)
-
-	t.Run("valid port connects", func(t *testing.T) {
-		t.Setenv("CLAWBRAIN_PORT", "6334")
-		out, err := runCLI(t, binary, "check")
+	var embedded atomic.Int64
+	ollama := hashingOllama(t, &embedded)
+	data, dir := t.TempDir(), t.TempDir()
+	cli := func(args ...string) map[string]any {
+		t.Helper()
+		args = append([]string{"--backend", "file", "--path", data, "--state-backend", "file", "--ollama-url", ollama.URL}, args...)
+		out, err := runCLI(t, binary, args...)
 		if err != nil {
-			t.Fatalf("check with CLAWBRAIN_PORT=6334 failed: %v\n%s", err, out)
+			t.Fatalf("%v failed: %v\n%s", args, err, out)
 		}
-		result := parseJSON(t, out)
-		if result["status"] != "ok" {
-			t.Fatalf("expected status ok, got %v", result["status"])
-		}
-	})
+		return parseJSON(t, out)
+	}
 
-	t.Run("wrong port causes non-zero exit", func(t *testing.T) {
-		// If CLAWBRAIN_PORT were ignored, the binary would fall back to the
-		// default port (6334) and check would succeed. A non-zero exit here
-		// proves the env var was actually read and passed to the Qdrant client.
-		t.Setenv("CLAWBRAIN_PORT", "9999")
-		_, err := runCLI(t, binary, "check")
-		if err == nil {
-			t.Fatal("expected non-zero exit when CLAWBRAIN_PORT points to unreachable port")
-		}
-	})
-}
+	if result := cli("orient"); result["total"] != float64(0) || result["last_synced_at"] != nil {
+		t.Fatalf("expected an empty store to orient to nothing, got %v", result)
+	}
 
-// --- Sync command tests ---
+	oldTodo := cli("add", "--no-merge", "--type", "todo", "--text", "rotate the staging keys")["id"].(string)
+	cli("add", "--no-merge", "--type", "todo", "--supersedes", oldTodo, "--text", "rotate every key before launch")
+	cli("add", "--no-merge", "--pinned", "--type", "fact", "--text", "prod runs in eu-west-1")
+	if err := os.WriteFile(filepath.Join(dir, "notes.md"), []byte("# Notes\n\nDeploys happen on tuesdays."), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cli("sync", "--dir", dir)
 
-// skipIfNoRedis skips the test if Redis is not running on localhost:6379.
-func skipIfNoRedis(t *testing.T) {
-	t.Helper()
-	conn, err := net.DialTimeout("tcp", "localhost:6379", 2*time.Second)
-	if err != nil {
-		t.Skipf("Redis not available on localhost:6379, skipping: %v", err)
+	result := cli("orient", "--recent", "2")
+	if result["total"] != float64(3) {
+		t.Errorf("expected the superseded todo left out of the total, got %v", result)
+	}
+	if types := result["types"].(map[string]any); types["todo"] != float64(1) || types["fact"] != float64(1) || types["untyped"] != float64(1) {
+		t.Errorf("expected counts by type, got %v", types)
+	}
+	recent := result["recent"].([]any)
+	if len(recent) != 2 || !strings.Contains(recent[0].(map[string]any)["payload"].(map[string]any)["text"].(string), "tuesdays") {
+		t.Errorf("expected the two newest memories, the synced note first, got %v", recent)
+	}
+	if pinned := result["pinned"].([]any); len(pinned) != 1 {
+		t.Errorf("expected the pinned fact, got %v", pinned)
+	}
+	todos := result["todos"].([]any)
+	if len(todos) != 1 || todos[0].(map[string]any)["id"] == oldTodo {
+		t.Errorf("expected only the todo that superseded the old one, got %v", todos)
+	}
+	if _, ok := result["last_synced_at"].(string); !ok {
+		t.Errorf("expected the time of the last sync, got %v", result)
 	}
-	conn.Close()
-}
 
-// cleanupRedisKey deletes a key from Redis. Uses a raw TCP connection
-// to avoid importing the redis package in tests.
-func cleanupRedisKey(t *testing.T, key string) {
-	t.Helper()
-	conn, err := net.DialTimeout("tcp", "localhost:6379", 2*time.Second)
-	if err != nil {
-		return
+	out, err := runCLI(t, binary, "--backend", "file", "--path", data, "orient", "--recent", "-1")
+	if err == nil || parseJSON(t, out)["status"] != "error" {
+		t.Errorf("expected a negative --recent to be rejected\n%s", out)
 	}
-	defer conn.Close()
-	// Send DEL command via RESP
-	cmd := fmt.Sprintf("*2\r\n$3\r\nDEL\r\n$%d\r\n%s\r\n", len(key), key)
-	conn.Write([]byte(cmd))
 }
 
-func TestCLISyncSingleFile(t *testing.T) {
+func TestCLILimits(t *testing.T) {
 	binary := buildBinary(t)
-	skipIfNoQdrant(t, binary)
-	skipIfNoOllama(t)
-	skipIfNoRedis(t)
-
-	defer cleanupMemories(t)
-
-	// Create a temp markdown file
-	dir := t.TempDir()
-	filePath := dir + "/test-notes.md"
-	os.WriteFile(filePath, []byte("The project uses PostgreSQL for persistence and Redis for caching."), 0644)
+	var embedded atomic.Int64
+	ollama := hashingOllama(t, &embedded)
+	data := t.TempDir()
+	cfg := filepath.Join(t.TempDir(), "clawbrain.json")
+	if err := os.WriteFile(cfg, []byte(`{"limits": {"max_text_length": 40, "max_response_bytes": 1500}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cli := func(args ...string) map[string]any {
+		t.Helper()
+		args = append([]string{"--backend", "file", "--path", data, "--config", cfg, "--ollama-url", ollama.URL}, args...)
+		out, err := runCLI(t, binary, args...)
+		if err != nil {
+			t.Fatalf("%v failed: %v\n%s", args, err, out)
+		}
+		return parseJSON(t, out)
+	}
 
-	// Clean Redis key for this file
-	cleanupRedisKey(t, "sync:"+filePath)
-	defer cleanupRedisKey(t, "sync:"+filePath)
+	long := "the deploy log: " + strings.Repeat("step ok; ", 500)
+	id := cli("add", "--no-merge", "--text", long)["id"].(string)
+	got := cli("get", "--id", id)
+	text := got["payload"].(map[string]any)["text"].(string)
+	if !strings.HasSuffix(text, "…") || len([]rune(text)) != 41 {
+		t.Errorf("expected the text cut to 40 characters, got %q", text)
+	}
+	if cut, _ := got["truncated"].([]any); len(cut) != 1 || cut[0] != "payload.text" {
+		t.Errorf("expected the cut marked, got %v", got)
+	}
 
-	out, err := runCLI(t, binary, "sync", "--file", filePath)
-	if err != nil {
-		t.Fatalf("sync failed: %v\n%s", err, out)
+	for i := range 20 {
+		cli("add", "--no-merge", "--text", fmt.Sprintf("deploy note %d: %s", i, strings.Repeat("x", 30)))
+	}
+	result := cli("search", "--query", "deploy", "--limit", "20", "--min-score", "-1")
+	results := result["results"].([]any)
+	omitted, _ := result["omitted"].(float64)
+	if omitted == 0 || len(results)+int(omitted) != 20 || result["returned"] != float64(len(results)) {
+		t.Errorf("expected results dropped to fit 1500 bytes and counted, got %d results, %v omitted, %v returned", len(results), result["omitted"], result["returned"])
 	}
+}
 
-	result := parseJSON(t, out)
-	if result["status"] != "ok" {
-		t.Fatalf("expected status ok, got %v", result["status"])
+func TestCLIResources(t *testing.T) {
+	binary := buildBinary(t)
+	var embedded atomic.Int64
+	ollama := hashingOllama(t, &embedded)
+	global := []string{"--backend", "file", "--path", t.TempDir(), "--ollama-url", ollama.URL}
+	cli := func(args ...string) map[string]any {
+		t.Helper()
+		out, err := runCLI(t, binary, append(global, args...)...)
+		if err != nil {
+			t.Fatalf("%v failed: %v\n%s", args, err, out)
+		}
+		return parseJSON(t, out)
 	}
 
-	added, _ := result["added"].(float64)
-	if added < 1 {
-		t.Fatalf("expected at least 1 chunk added, got %v", added)
+	pinned := cli("add", "--no-merge", "--pinned", "--text", "prod runs in eu-west-1")["id"].(string)
+	recent := cli("add", "--no-merge", "--text", "the release train leaves on thursdays")["id"].(string)
+
+	uris := map[string]bool{}
+	for _, r := range cli("resource", "list")["resources"].([]any) {
+		uris[r.(map[string]any)["uri"].(string)] = true
+	}
+	for _, uri := range []string{"clawbrain://recent", "clawbrain://pinned", "clawbrain://memory/" + pinned, "clawbrain://memory/" + recent} {
+		if !uris[uri] {
+			t.Errorf("expected %s listed, got %v", uri, uris)
+		}
 	}
 
-	// Verify the memory was actually stored by searching for it
-	searchOut, err := runCLI(t, binary, "search", "--query", "PostgreSQL database", "--limit", "1")
-	if err != nil {
-		t.Fatalf("search failed: %v\n%s", err, searchOut)
+	memories := cli("resource", "read", "clawbrain://pinned")["memories"].([]any)
+	if len(memories) != 1 || memories[0].(map[string]any)["id"] != pinned {
+		t.Errorf("expected only the pinned memory, got %v", memories)
+	}
+	memories = cli("resource", "read", "--limit", "1", "clawbrain://recent")["memories"].([]any)
+	if len(memories) != 1 || memories[0].(map[string]any)["id"] != recent {
+		t.Errorf("expected the newest memory, got %v", memories)
+	}
+	memory := cli("resource", "read", "clawbrain://memory/"+recent)["memory"].(map[string]any)
+	if memory["payload"].(map[string]any)["text"] != "the release train leaves on thursdays" {
+		t.Errorf("expected the memory, got %v", memory)
 	}
 
-	searchResult := parseJSON(t, searchOut)
-	results := searchResult["results"].([]any)
-	if len(results) == 0 {
-		t.Fatal("expected search to find the synced memory")
+	for _, uri := range []string{"clawbrain://everything", "clawbrain://memory/6f1c2d3e-0000-4000-8000-000000000001"} {
+		out, err := runCLI(t, binary, append(global, "resource", "read", uri)...)
+		if err == nil || parseJSON(t, out)["status"] != "error" {
+			t.Errorf("expected reading %s to fail\n%s", uri, out)
+		}
 	}
 
-	topPayload := results[0].(map[string]any)["payload"].(map[string]any)
-	if topPayload["source"] != filePath {
-		t.Errorf("expected source=%q, got %v", filePath, topPayload["source"])
+	base := startServe(t, binary, append(global, "serve")...)
+	resp := getHTTP(t, base+"/resources/read?uri="+url.QueryEscape("clawbrain://memory/"+pinned), "")
+	defer resp.Body.Close()
+	var body map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || resp.StatusCode != http.StatusOK || body["memory"].(map[string]any)["id"] != pinned {
+		t.Errorf("expected serve to read the memory, got %d %v %v", resp.StatusCode, body, err)
+	}
+	if resp := getHTTP(t, base+"/resources/read?uri="+url.QueryEscape("clawbrain://memory/6f1c2d3e-0000-4000-8000-000000000001"), ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for a missing memory, got %d", resp.StatusCode)
+	}
+	if resp := getHTTP(t, base+"/resources/read?uri=nope", ""); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown URI, got %d", resp.StatusCode)
 	}
 }
 
-func TestCLISyncSkipsDuplicates(t *testing.T) {
+func TestCLISearchRelated(t *testing.T) {
 	binary := buildBinary(t)
-	skipIfNoQdrant(t, binary)
-	skipIfNoOllama(t)
-	skipIfNoRedis(t)
+	var embedded atomic.Int64
+	ollama := hashingOllama(t, &embedded)
+	global := []string{"--backend", "file", "--path", t.TempDir(), "--ollama-url", ollama.URL}
+	cli := func(args ...string) map[string]any {
+		t.Helper()
+		out, err := runCLI(t, binary, append(global, args...)...)
+		if err != nil {
+			t.Fatalf("%v failed: %v\n%s", args, err, out)
+		}
+		return parseJSON(t, out)
+	}
 
-	defer cleanupMemories(t)
+	runbook := cli("add", "--no-merge", "--text", "the deploy runbook lives in the ops wiki")["id"].(string)
+	cli("add", "--no-merge", "--text", "deploys are frozen on fridays")
+	cli("add", "--no-merge", "--text", "staging deploys run every hour")
+	target := cli("add", "--no-merge", "--relate", runbook+":see-also", "--text", "the deploy pipeline needs two approvals")["id"].(string)
 
-	dir := t.TempDir()
-	filePath := dir + "/test-dedup.md"
-	os.WriteFile(filePath, []byte("Kubernetes pods are scheduled by the control plane."), 0644)
+	result := cli("search", "--query", "the deploy pipeline needs two approvals", "--limit", "1", "--related")
+	top := result["results"].([]any)[0].(map[string]any)["id"].(string)
+	if top != target {
+		t.Fatalf("expected the exact memory first, got %v", result)
+	}
+	hint := result["related"].(map[string]any)[target].(map[string]any)
+	neighbors := hint["neighbors"].([]any)
+	if len(neighbors) != 2 || slices.Contains(neighbors, any(target)) {
+		t.Errorf("expected two neighbors besides the result itself, got %v", neighbors)
+	}
+	links := hint["links"].([]any)
+	if len(links) != 1 || links[0].(map[string]any)["id"] != runbook || links[0].(map[string]any)["kind"] != "see-also" {
+		t.Errorf("expected the link to the runbook, got %v", links)
+	}
+	if _, ok := cli("search", "--query", "deploys", "--limit", "1")["related"]; ok {
+		t.Error("expected no related hints unless asked for")
+	}
 
-	// Clean Redis key
-	cleanupRedisKey(t, "sync:"+filePath)
-	defer cleanupRedisKey(t, "sync:"+filePath)
+	missing := "6f1c2d3e-0000-4000-8000-000000000001"
+	// The runbook is likely a neighbor too; it is fetched once.
+	var ids []string
+	for _, n := range neighbors {
+		ids = append(ids, n.(string))
+	}
+	ids = append(ids, runbook, missing)
+	var want []string
+	for _, id := range ids[:len(ids)-1] {
+		if !slices.Contains(want, id) {
+			want = append(want, id)
+		}
+	}
+	got := cli("get", "--ids", strings.Join(ids, ","))
+	memories := got["memories"].([]any)
+	if len(memories) != len(want) || memories[0].(map[string]any)["id"] != want[0] {
+		t.Errorf("expected %v in order, got %v", want, got)
+	}
+	if m := got["missing"].([]any); len(m) != 1 || m[0] != missing {
+		t.Errorf("expected the unknown ID reported missing, got %v", got)
+	}
+	out, err := runCLI(t, binary, append(global, "get", "--ids", runbook, "--id", runbook)...)
+	if err == nil || parseJSON(t, out)["status"] != "error" {
+		t.Errorf("expected --ids with --id to be rejected\n%s", out)
+	}
+}
 
-	// First sync — should add
-	out1, err := runCLI(t, binary, "sync", "--file", filePath)
+func TestCLISelftest(t *testing.T) {
+	binary := buildBinary(t)
+	ollama := wordsOllama(t)
+	data := t.TempDir()
+	global := []string{"--backend", "file", "--path", data, "--ollama-url", ollama.URL}
+
+	// A memory outside the scratch namespace, stale to forget --ttl 0s.
+	out, err := runCLI(t, binary, append(global, "add", "--text", "the real memories stay put")...)
 	if err != nil {
-		t.Fatalf("first sync failed: %v\n%s", err, out1)
+		t.Fatalf("add failed: %v\n%s", err, out)
 	}
-	result1 := parseJSON(t, out1)
-	added1, _ := result1["added"].(float64)
-	if added1 < 1 {
-		t.Fatalf("first sync should add chunks, got %v", added1)
+	id := parseJSON(t, out)["id"].(string)
+
+	out, err = runCLI(t, binary, append(global, "selftest")...)
+	if err != nil {
+		t.Fatalf("selftest failed: %v\n%s", err, out)
+	}
+	result := parseJSON(t, out)
+	var names []string
+	for _, st := range result["stages"].([]any) {
+		stage := st.(map[string]any)
+		if stage["ok"] != true {
+			t.Fatalf("stage %v failed: %v", stage["name"], stage)
+		}
+		names = append(names, stage["name"].(string))
+	}
+	if want := []string{"add", "search", "dedup", "pin", "forget", "survival", "cleanup"}; !slices.Equal(names, want) {
+		t.Fatalf("expected stages %v, got %v", want, names)
 	}
 
-	// Second sync — should skip (file already in Redis)
-	out2, err := runCLI(t, binary, "sync", "--file", filePath)
+	if out, err := runCLI(t, binary, append(global, "get", "--id", id)...); err != nil {
+		t.Fatalf("selftest touched a memory outside its namespace: %v\n%s", err, out)
+	}
+	out, err = runCLI(t, binary, append(global, "namespaces")...)
 	if err != nil {
-		t.Fatalf("second sync failed: %v\n%s", err, out2)
+		t.Fatalf("namespaces failed: %v\n%s", err, out)
 	}
-	result2 := parseJSON(t, out2)
-	added2, _ := result2["added"].(float64)
-	skipped2, _ := result2["skipped"].(float64)
-	if added2 != 0 {
-		t.Errorf("second sync should add 0 chunks, got %v", added2)
+	for _, ns := range parseJSON(t, out)["namespaces"].([]any) {
+		if name := ns.(map[string]any)["name"]; name != "" {
+			t.Fatalf("expected the scratch namespace dropped, got %v", name)
+		}
 	}
-	if skipped2 < 1 {
-		t.Errorf("second sync should skip the file, got skipped=%v", skipped2)
+
+	// A store that can't be written fails at the first stage, not later.
+	out, err = runCLI(t, binary, "--backend", "file", "--path", "/dev/null/nowhere", "--ollama-url", ollama.URL, "selftest")
+	if err == nil {
+		t.Fatalf("expected selftest to fail, got %s", out)
+	}
+	if result := parseJSON(t, out); result["status"] != "error" || result["message"] != "selftest failed at add" {
+		t.Fatalf("expected a failure at add, got %v", result)
 	}
 }
 
-func TestCLISyncEmptyFile(t *testing.T) {
+func TestCLIServeAutoForget(t *testing.T) {
 	binary := buildBinary(t)
-	skipIfNoQdrant(t, binary)
-	skipIfNoOllama(t)
-	skipIfNoRedis(t)
-
-	dir := t.TempDir()
-	filePath := dir + "/empty.md"
-	os.WriteFile(filePath, []byte(""), 0644)
+	var embedded atomic.Int64
+	ollama := hashingOllama(t, &embedded)
+	configPath := filepath.Join(t.TempDir(), "clawbrain.json")
+	if err := os.WriteFile(configPath, []byte(`{"auto_forget": {"on_start": true, "ttl": "1s", "hard": true}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	global := []string{"--backend", "file", "--path", t.TempDir(), "--state-backend", "file", "--ollama-url", ollama.URL, "--config", configPath}
+	add := func(text string, pinned bool) string {
+		t.Helper()
+		args := append(global, "add", "--no-merge", "--text", text)
+		if pinned {
+			args = append(args, "--pinned")
+		}
+		out, err := runCLI(t, binary, args...)
+		if err != nil {
+			t.Fatalf("add failed: %v\n%s", err, out)
+		}
+		return parseJSON(t, out)["id"].(string)
+	}
+	exists := func(id string) bool {
+		_, err := runCLI(t, binary, append(global, "get", "--id", id)...)
+		return err == nil
+	}
 
-	cleanupRedisKey(t, "sync:"+filePath)
+	stale := add("the old build server lived under a desk", false)
+	pinned := add("prod runs in eu-west-1", true)
+	time.Sleep(1500 * time.Millisecond)
 
-	out, err := runCLI(t, binary, "sync", "--file", filePath)
-	if err != nil {
-		t.Fatalf("sync failed: %v\n%s", err, out)
+	startServe(t, binary, append(global, "serve")...)
+	deadline := time.Now().Add(10 * time.Second)
+	for exists(stale) {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the stale memory forgotten at start")
+		}
+		time.Sleep(100 * time.Millisecond)
 	}
-
-	result := parseJSON(t, out)
-	if result["status"] != "ok" {
-		t.Fatalf("expected status ok, got %v", result["status"])
+	if !exists(pinned) {
+		t.Fatal("expected the pinned memory kept")
 	}
-	added, _ := result["added"].(float64)
-	if added != 0 {
-		t.Errorf("expected 0 chunks from empty file, got %v", added)
+
+	// A second server started right after finds the pass locked and
+	// leaves the memories to the first one's schedule.
+	stale = add("the coffee machine on floor two is broken", false)
+	time.Sleep(1500 * time.Millisecond)
+	startServe(t, binary, append(global, "serve")...)
+	time.Sleep(time.Second)
+	if !exists(stale) {
+		t.Fatal("expected a second start within the lock not to run a pass")
 	}
 }
 
-func TestCLISyncDirectory(t *testing.T) {
+func TestCLIDecisions(t *testing.T) {
 	binary := buildBinary(t)
-	skipIfNoQdrant(t, binary)
-	skipIfNoOllama(t)
-	skipIfNoRedis(t)
+	ollama := wordsOllama(t)
+	global := []string{"--backend", "file", "--path", t.TempDir(), "--ollama-url", ollama.URL}
+
+	out, err := runCLI(t, binary, append(global, "add",
+		"--context", "orders and payments must commit together",
+		"--option", "postgres", "--option", "dynamodb",
+		"--choice", "use postgres for orders",
+		"--consequences", "we run a database ourselves",
+		"--tag", "project:shop")...)
+	if err != nil {
+		t.Fatalf("add failed: %v\n%s", err, out)
+	}
+	id := parseJSON(t, out)["id"].(string)
+	if out, err := runCLI(t, binary, append(global, "add", "--text", "the shop ships on fridays", "--no-merge")...); err != nil {
+		t.Fatalf("add failed: %v\n%s", err, out)
+	}
 
-	defer cleanupMemories(t)
+	// A decision needs its context and choice, and the flags only make decisions.
+	for _, args := range [][]string{
+		{"add", "--choice", "use postgres"},
+		{"add", "--type", "decision", "--text", "we picked postgres"},
+		{"add", "--type", "fact", "--context", "orders", "--choice", "postgres"},
+	} {
+		if out, err := runCLI(t, binary, append(global, args...)...); err == nil {
+			t.Errorf("%v: expected an error\n%s", args, out)
+		}
+	}
 
-	dir := t.TempDir()
-	os.WriteFile(dir+"/one.md", []byte("Go is a statically typed language."), 0644)
-	os.WriteFile(dir+"/two.md", []byte("Rust provides memory safety without garbage collection."), 0644)
+	want := "Decision: use postgres for orders\n" +
+		"Context: orders and payments must commit together\n" +
+		"Options: postgres; dynamodb\n" +
+		"Consequences: we run a database ourselves"
 
-	// Clean Redis keys
-	cleanupRedisKey(t, "sync:"+dir+"/one.md")
-	cleanupRedisKey(t, "sync:"+dir+"/two.md")
-	defer cleanupRedisKey(t, "sync:"+dir+"/one.md")
-	defer cleanupRedisKey(t, "sync:"+dir+"/two.md")
+	out, err = runCLI(t, binary, append(global, "get", "--id", id)...)
+	if err != nil {
+		t.Fatalf("get failed: %v\n%s", err, out)
+	}
+	got := parseJSON(t, out)
+	if got["card"] != want {
+		t.Errorf("get: expected card\n%s\ngot %v", want, got["card"])
+	}
+	if payload := got["payload"].(map[string]any); payload["type"] != "decision" || payload["text"] != want {
+		t.Errorf("expected a decision embedded as its card, got %v", payload)
+	}
+
+	out, err = runCLI(t, binary, append(global, "search", "--query", "postgres for orders", "--limit", "5")...)
+	if err != nil {
+		t.Fatalf("search failed: %v\n%s", err, out)
+	}
+	cards, _ := parseJSON(t, out)["cards"].(map[string]any)
+	if len(cards) != 1 || cards[id] != want {
+		t.Errorf("search: expected the decision's card alone, got %v", cards)
+	}
 
-	out, err := runCLI(t, binary, "sync", "--dir", dir)
+	out, err = runCLI(t, binary, append(global, "decisions", "list")...)
 	if err != nil {
-		t.Fatalf("sync failed: %v\n%s", err, out)
+		t.Fatalf("decisions list failed: %v\n%s", err, out)
 	}
-
-	result := parseJSON(t, out)
-	if result["status"] != "ok" {
-		t.Fatalf("expected status ok, got %v", result["status"])
+	listed := parseJSON(t, out)
+	decisions := listed["decisions"].([]any)
+	if len(decisions) != 1 || listed["total"] != float64(1) {
+		t.Fatalf("expected one decision, got %v", listed)
 	}
-
-	files, _ := result["files"].(float64)
-	if files != 2 {
-		t.Errorf("expected 2 files, got %v", files)
+	d := decisions[0].(map[string]any)
+	if d["id"] != id || d["choice"] != "use postgres for orders" || d["card"] != want {
+		t.Errorf("unexpected decision %v", d)
+	}
+	if options, _ := d["options"].([]any); len(options) != 2 || options[1] != "dynamodb" {
+		t.Errorf("expected options kept as a list, got %v", d["options"])
 	}
 
-	added, _ := result["added"].(float64)
-	if added < 2 {
-		t.Errorf("expected at least 2 chunks added, got %v", added)
+	out, err = runCLI(t, binary, append(global, "decisions", "list", "--tag", "project:other")...)
+	if err != nil {
+		t.Fatalf("decisions list --tag failed: %v\n%s", err, out)
+	}
+	if got := parseJSON(t, out)["decisions"].([]any); len(got) != 0 {
+		t.Errorf("expected no decisions tagged project:other, got %v", got)
 	}
 }
 
-func TestCLISyncNoFiles(t *testing.T) {
+func TestCLIDedupChecksText(t *testing.T) {
 	binary := buildBinary(t)
-	skipIfNoQdrant(t, binary)
-	skipIfNoOllama(t)
-	skipIfNoRedis(t)
-
-	dir := t.TempDir()
+	ollama := wordsOllama(t)
+	global := []string{"--backend", "file", "--path", t.TempDir(), "--ollama-url", ollama.URL}
 
-	out, err := runCLI(t, binary, "sync", "--base", dir)
+	out, err := runCLI(t, binary, append(global, "add", "--text", "the api listens on port 8080")...)
 	if err != nil {
-		t.Fatalf("sync failed: %v\n%s", err, out)
+		t.Fatalf("add failed: %v\n%s", err, out)
 	}
+	id := parseJSON(t, out)["id"].(string)
 
+	// Close enough to merge by vector, but a different port is a different fact.
+	out, err = runCLI(t, binary, append(global, "add", "--text", "the api listens on port 9090",
+		"--dedup-threshold", "0.5", "--verbose")...)
+	if err != nil {
+		t.Fatalf("add failed: %v\n%s", err, out)
+	}
 	result := parseJSON(t, out)
-	if result["status"] != "ok" {
-		t.Fatalf("expected status ok, got %v", result["status"])
+	if _, merged := result["merged_id"]; merged {
+		t.Fatalf("expected a different port kept apart, got %v", result)
 	}
-	files, _ := result["files"].(float64)
-	if files != 0 {
-		t.Errorf("expected 0 files, got %v", files)
+	candidates := result["dedup"].(map[string]any)["candidates"].([]any)
+	if c := candidates[0].(map[string]any); c["id"] != id || c["decision"] != "text_differs" {
+		t.Errorf("expected %s skipped as text_differs, got %v", id, c)
+	}
+
+	out, err = runCLI(t, binary, append(global, "similarity", "--a", id, "--b", "the api listens on port 9090",
+		"--dedup-threshold", "0.5")...)
+	if err != nil {
+		t.Fatalf("similarity failed: %v\n%s", err, out)
+	}
+	if sim := parseJSON(t, out); sim["would_merge"] != false || sim["text_overlap"] != 5.0/7 {
+		t.Errorf("expected no merge at 5/7 overlap, got %v", sim)
+	}
+
+	// A rewording of the same fact still merges.
+	out, err = runCLI(t, binary, append(global, "add", "--text", "The API listens on port 8080.", "--dedup-threshold", "0.5")...)
+	if err != nil {
+		t.Fatalf("add failed: %v\n%s", err, out)
+	}
+	if merged := parseJSON(t, out)["merged_id"]; merged != id {
+		t.Errorf("expected %s merged, got %v", id, merged)
 	}
 }
 
-func TestCLISyncSkipsTodayDailyFile(t *testing.T) {
+func TestCLIDedupChecksType(t *testing.T) {
 	binary := buildBinary(t)
-	skipIfNoQdrant(t, binary)
-	skipIfNoOllama(t)
-	skipIfNoRedis(t)
-
-	dir := t.TempDir()
-	today := time.Now().Format("2006-01-02")
-	todayFile := dir + "/" + today + ".md"
-	os.WriteFile(todayFile, []byte("Today's notes are still being written."), 0644)
+	ollama := wordsOllama(t)
+	global := []string{"--backend", "file", "--path", t.TempDir(), "--ollama-url", ollama.URL}
 
-	out, err := runCLI(t, binary, "sync", "--file", todayFile)
+	out, err := runCLI(t, binary, append(global, "add", "--text", "rotate the api keys", "--type", "fact")...)
 	if err != nil {
-		t.Fatalf("sync failed: %v\n%s", err, out)
+		t.Fatalf("add failed: %v\n%s", err, out)
 	}
+	id := parseJSON(t, out)["id"].(string)
 
+	// The same text as a todo is a different memory.
+	out, err = runCLI(t, binary, append(global, "add", "--text", "rotate the api keys", "--type", "todo", "--verbose")...)
+	if err != nil {
+		t.Fatalf("add failed: %v\n%s", err, out)
+	}
 	result := parseJSON(t, out)
-	if result["status"] != "ok" {
-		t.Fatalf("expected status ok, got %v", result["status"])
+	if _, merged := result["merged_id"]; merged {
+		t.Fatalf("expected a todo kept apart from the fact, got %v", result)
 	}
-	added, _ := result["added"].(float64)
-	skipped, _ := result["skipped"].(float64)
-	if added != 0 {
-		t.Errorf("expected 0 added for today's file, got %v", added)
+	candidates := result["dedup"].(map[string]any)["candidates"].([]any)
+	if c := candidates[0].(map[string]any); c["id"] != id || c["decision"] != "different_type" {
+		t.Errorf("expected %s skipped as different_type, got %v", id, c)
 	}
-	if skipped < 1 {
-		t.Errorf("expected today's file to be skipped, got skipped=%v", skipped)
+
+	out, err = runCLI(t, binary, append(global, "add", "--text", "rotate the api keys", "--type", "fact")...)
+	if err != nil {
+		t.Fatalf("add failed: %v\n%s", err, out)
+	}
+	if merged := parseJSON(t, out)["merged_id"]; merged != id {
+		t.Errorf("expected the fact merged into %s, got %v", id, merged)
 	}
 }
 
-func TestCLISyncMemoryMDResyncsOnChange(t *testing.T) {
+func TestCLIGetSummarize(t *testing.T) {
 	binary := buildBinary(t)
-	skipIfNoQdrant(t, binary)
-	skipIfNoOllama(t)
-	skipIfNoRedis(t)
-
-	defer cleanupMemories(t)
-
-	dir := t.TempDir()
-	memoryPath := dir + "/MEMORY.md"
-
-	// Write initial content
-	os.WriteFile(memoryPath, []byte("The preferred language is Go."), 0644)
-
-	// Clean Redis key (use absolute path since DiscoverFiles returns absolute paths)
-	absPath, _ := os.Getwd()
-	_ = absPath
-	cleanupRedisKey(t, "sync:"+memoryPath)
-	defer cleanupRedisKey(t, "sync:"+memoryPath)
+	ollama := fakeOllama(t)
+	global := []string{"--backend", "file", "--path", t.TempDir(), "--ollama-url", ollama.URL}
 
-	// First sync — should add chunks
-	out, err := runCLI(t, binary, "sync", "--file", memoryPath)
+	long := strings.Repeat("the release checklist has many steps. ", 40)
+	out, err := runCLI(t, binary, append(global, "add", "--text", long, "--payload", `{"source": "/docs/release.md"}`)...)
 	if err != nil {
-		t.Fatalf("first sync failed: %v\n%s", err, out)
+		t.Fatalf("add failed: %v\n%s", err, out)
 	}
-	result1 := parseJSON(t, out)
-	added1, _ := result1["added"].(float64)
-	if added1 < 1 {
-		t.Fatalf("first sync should add chunks, got %v", added1)
+	longID := parseJSON(t, out)["id"].(string)
+	out, err = runCLI(t, binary, append(global, "add", "--text", "deploys go out on thursdays", "--no-merge")...)
+	if err != nil {
+		t.Fatalf("add failed: %v\n%s", err, out)
 	}
+	shortID := parseJSON(t, out)["id"].(string)
 
-	// Second sync with same content — should skip (hash unchanged)
-	out, err = runCLI(t, binary, "sync", "--file", memoryPath)
+	out, err = runCLI(t, binary, append(global, "get", "--id", longID, "--summarize")...)
 	if err != nil {
-		t.Fatalf("second sync failed: %v\n%s", err, out)
+		t.Fatalf("get --summarize failed: %v\n%s", err, out)
 	}
-	result2 := parseJSON(t, out)
-	added2, _ := result2["added"].(float64)
-	skipped2, _ := result2["skipped"].(float64)
-	if added2 != 0 {
-		t.Errorf("second sync (unchanged) should add 0, got %v", added2)
+	got := parseJSON(t, out)
+	if got["summarized"] != true || got["summary"] != "summary of 0 notes" {
+		t.Errorf("expected a summary, got %v", got)
 	}
-	if skipped2 < 1 {
-		t.Errorf("second sync should skip MEMORY.md, got skipped=%v", skipped2)
+	payload := got["payload"].(map[string]any)
+	if _, ok := payload["text"]; ok || payload["source"] != "/docs/release.md" {
+		t.Errorf("expected the text left out and the rest kept, got %v", payload)
 	}
-	// Check reason
-	results2 := result2["results"].([]any)
-	if len(results2) > 0 {
-		reason := results2[0].(map[string]any)["reason"]
-		if reason != "already synced (unchanged)" {
-			t.Errorf("expected reason 'already synced (unchanged)', got %v", reason)
-		}
+	full := got["full_text"].(map[string]any)
+	if full["id"] != longID || full["field"] != "payload.text" || full["length"] != float64(len([]rune(long))) {
+		t.Errorf("unexpected full_text %v", full)
 	}
 
-	// Update MEMORY.md content
-	os.WriteFile(memoryPath, []byte("The preferred language is Rust now."), 0644)
+	// Short texts come back whole; --ids summarizes each memory.
+	out, err = runCLI(t, binary, append(global, "get", "--ids", shortID+","+longID, "--summarize")...)
+	if err != nil {
+		t.Fatalf("get --ids --summarize failed: %v\n%s", err, out)
+	}
+	memories := parseJSON(t, out)["memories"].([]any)
+	short, summarized := memories[0].(map[string]any), memories[1].(map[string]any)
+	if short["summarized"] != false || short["payload"].(map[string]any)["text"] != "deploys go out on thursdays" {
+		t.Errorf("expected the short memory whole, got %v", short)
+	}
+	if summarized["summarized"] != true {
+		t.Errorf("expected the long memory summarized, got %v", summarized)
+	}
 
-	// Third sync — should re-sync because content hash changed
-	out, err = runCLI(t, binary, "sync", "--file", memoryPath)
+	// The full text is still there to fetch.
+	out, err = runCLI(t, binary, append(global, "get", "--id", longID, "--select", "payload.text")...)
 	if err != nil {
-		t.Fatalf("third sync failed: %v\n%s", err, out)
+		t.Fatalf("get --select failed: %v\n%s", err, out)
 	}
-	result3 := parseJSON(t, out)
-	added3, _ := result3["added"].(float64)
-	if added3 < 1 {
-		t.Fatalf("third sync (changed content) should add chunks, got %v", added3)
+	if text := parseJSON(t, out)["payload"].(map[string]any)["text"]; text != long {
+		t.Errorf("expected the full text kept, got %v", text)
+	}
+	if out, err := runCLI(t, binary, append(global, "get", "--id", longID, "--summarize", "--select", "id")...); err == nil {
+		t.Errorf("expected --summarize with --select to fail\n%s", out)
 	}
 }
 
-func TestCLISyncExcludeFlag(t *testing.T) {
+func TestCLISearchRerank(t *testing.T) {
 	binary := buildBinary(t)
-	skipIfNoQdrant(t, binary)
-	skipIfNoOllama(t)
-	skipIfNoRedis(t)
-
-	defer cleanupMemories(t)
-
-	dir := t.TempDir()
-	os.WriteFile(dir+"/keep.md", []byte("This file should be synced."), 0644)
-	os.WriteFile(dir+"/skip.md", []byte("This file should be excluded."), 0644)
-
-	cleanupRedisKey(t, "sync:"+dir+"/keep.md")
-	cleanupRedisKey(t, "sync:"+dir+"/skip.md")
-	defer cleanupRedisKey(t, "sync:"+dir+"/keep.md")
-	defer cleanupRedisKey(t, "sync:"+dir+"/skip.md")
-
-	// Sync with --exclude skip.md
-	out, err := runCLI(t, binary, "sync", "--dir", dir, "--exclude", "skip.md")
-	if err != nil {
-		t.Fatalf("sync failed: %v\n%s", err, out)
+	words := wordsOllama(t)
+	var grades atomic.Int64
+	var down atomic.Bool
+	// Embeds like wordsOllama; grades a memory 9 if it names a city, 1 if not.
+	ollama := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/generate" {
+			resp, err := http.Post(words.URL+r.URL.Path, "application/json", r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+			defer resp.Body.Close()
+			io.Copy(w, resp.Body)
+			return
+		}
+		if down.Load() {
+			http.Error(w, "model not found", http.StatusNotFound)
+			return
+		}
+		grades.Add(1)
+		var req struct{ Prompt string }
+		json.NewDecoder(r.Body).Decode(&req)
+		_, memory, _ := strings.Cut(req.Prompt, "Memory: ")
+		grade := "1"
+		if strings.Contains(memory, "frankfurt") {
+			grade = "9"
+		}
+		json.NewEncoder(w).Encode(map[string]any{"response": grade})
+	}))
+	t.Cleanup(ollama.Close)
+	global := []string{"--backend", "file", "--path", t.TempDir(), "--ollama-url", ollama.URL}
+
+	var ids []string
+	for _, text := range []string{
+		"where is the staging server? nobody knows where the staging server is",
+		"the staging server is slow where it is",
+		"staging lives in frankfurt",
+	} {
+		out, err := runCLI(t, binary, append(global, "add", "--text", text, "--no-merge")...)
+		if err != nil {
+			t.Fatalf("add failed: %v\n%s", err, out)
+		}
+		ids = append(ids, parseJSON(t, out)["id"].(string))
 	}
-
-	result := parseJSON(t, out)
-	if result["status"] != "ok" {
-		t.Fatalf("expected status ok, got %v", result["status"])
+	search := func(args ...string) map[string]any {
+		t.Helper()
+		out, err := runCLI(t, binary, append(append(global, "search", "--query", "where is the staging server", "--limit", "1"), args...)...)
+		if err != nil {
+			t.Fatalf("search %v failed: %v\n%s", args, err, out)
+		}
+		return parseJSON(t, out)
 	}
 
-	// Should have processed 2 files but only added from keep.md
-	files, _ := result["files"].(float64)
-	if files != 2 {
-		t.Errorf("expected 2 discovered files, got %v", files)
+	// By similarity alone the question wins; graded, the answer does.
+	if top := search()["results"].([]any)[0].(map[string]any)["id"]; top != ids[0] {
+		t.Fatalf("expected the question on top by similarity, got %v", top)
 	}
-
-	added, _ := result["added"].(float64)
-	skipped, _ := result["skipped"].(float64)
-	if added < 1 {
-		t.Errorf("expected at least 1 added chunk (from keep.md), got %v", added)
+	got := search("--rerank")
+	results := got["results"].([]any)
+	if len(results) != 1 || results[0].(map[string]any)["id"] != ids[2] {
+		t.Errorf("expected the answer on top after reranking, got %v", results)
 	}
-	if skipped < 1 {
-		t.Errorf("expected at least 1 skipped (excluded skip.md), got %v", skipped)
+	reranked := got["reranked"].(map[string]any)
+	if reranked["candidates"] != float64(3) || reranked["grades"].(map[string]any)[ids[2]] != 0.9 {
+		t.Errorf("unexpected rerank report %v", reranked)
+	}
+	if _, ok := got["next_cursor"]; ok {
+		t.Errorf("expected no next_cursor on a reranked page, got %v", got)
+	}
+	if n := grades.Load(); n != 3 {
+		t.Errorf("expected 3 candidates graded, got %d", n)
 	}
 
-	// Verify the excluded file's result reason
-	results := result["results"].([]any)
-	for _, r := range results {
-		fr := r.(map[string]any)
-		file := fr["file"].(string)
-		if filepath.Base(file) == "skip.md" {
-			reason, _ := fr["reason"].(string)
-			if reason != "excluded by ignore pattern" {
-				t.Errorf("expected reason 'excluded by ignore pattern' for skip.md, got %q", reason)
-			}
+	// A reranker that fails leaves the similarity order.
+	down.Store(true)
+	got = search("--rerank")
+	if got["rerank_error"] == nil || got["results"].([]any)[0].(map[string]any)["id"] != ids[0] {
+		t.Errorf("expected similarity order with a rerank_error, got %v", got)
+	}
+
+	for _, args := range [][]string{
+		{"search", "--vector", "[0.1, 0.2]", "--rerank"},
+		{"search", "--query", "staging", "--rerank", "--cursor", store.EncodeCursor(5)},
+		{"search", "--query", "staging", "--rerank-candidates", "0"},
+	} {
+		if out, err := runCLI(t, binary, append(global, args...)...); err == nil {
+			t.Errorf("%v: expected an error\n%s", args, out)
 		}
 	}
 }
 
-func TestCLISyncClawbrainIgnoreFile(t *testing.T) {
+func TestCLISearchExpand(t *testing.T) {
 	binary := buildBinary(t)
-	skipIfNoQdrant(t, binary)
-	skipIfNoOllama(t)
-	skipIfNoRedis(t)
+	words := wordsOllama(t)
+	var down atomic.Bool
+	// Embeds like wordsOllama; paraphrases any query in the deploy box's
+	// words.
+	ollama := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/generate" {
+			resp, err := http.Post(words.URL+r.URL.Path, "application/json", r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+			defer resp.Body.Close()
+			io.Copy(w, resp.Body)
+			return
+		}
+		if down.Load() {
+			http.Error(w, "model not found", http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"response": "Rewrites:\n1. deploy box location\n2. deploy box region\n"})
+	}))
+	t.Cleanup(ollama.Close)
+	global := []string{"--backend", "file", "--path", t.TempDir(), "--ollama-url", ollama.URL}
+
+	var ids []string
+	for _, text := range []string{
+		"where is the staging server? nobody knows",
+		"the staging server is slow",
+		"the deploy box sits in the frankfurt region",
+	} {
+		out, err := runCLI(t, binary, append(global, "add", "--text", text, "--no-merge")...)
+		if err != nil {
+			t.Fatalf("add failed: %v\n%s", err, out)
+		}
+		ids = append(ids, parseJSON(t, out)["id"].(string))
+	}
+	search := func(args ...string) map[string]any {
+		t.Helper()
+		out, err := runCLI(t, binary, append(append(global, "search", "--query", "where is the staging server", "--limit", "1"), args...)...)
+		if err != nil {
+			t.Fatalf("search %v failed: %v\n%s", args, err, out)
+		}
+		return parseJSON(t, out)
+	}
 
-	defer cleanupMemories(t)
+	// The query's words miss the deploy box; both rewrites rank it first.
+	plain := search()["results"].([]any)[0].(map[string]any)["id"]
+	if plain == ids[2] {
+		t.Fatal("expected the deploy box missed without expansion")
+	}
+	got := search("--expand")
+	results := got["results"].([]any)
+	if len(results) != 1 || results[0].(map[string]any)["id"] != ids[2] {
+		t.Errorf("expected the deploy box on top after expansion, got %v", results)
+	}
+	expanded := got["expanded"].(map[string]any)
+	if queries := expanded["queries"].([]any); len(queries) != 2 || queries[0] != "deploy box location" {
+		t.Errorf("unexpected rewrites %v", queries)
+	}
+	if expanded["found"] != float64(1) || expanded["model"] != "llama3.2" {
+		t.Errorf("unexpected expansion report %v", expanded)
+	}
+	if _, ok := got["next_cursor"]; ok {
+		t.Errorf("expected no next_cursor on an expanded page, got %v", got)
+	}
 
-	dir := t.TempDir()
-	os.WriteFile(dir+"/keep.md", []byte("This file should be synced."), 0644)
-	os.WriteFile(dir+"/ignored.md", []byte("This file should be ignored via .clawbrain-ignore."), 0644)
-	os.WriteFile(dir+"/.clawbrain-ignore", []byte("ignored.md\n"), 0644)
+	// A model that fails leaves the query's own results.
+	down.Store(true)
+	got = search("--expand")
+	if got["expand_error"] == nil || got["results"].([]any)[0].(map[string]any)["id"] != plain {
+		t.Errorf("expected the query's own results with an expand_error, got %v", got)
+	}
 
-	cleanupRedisKey(t, "sync:"+dir+"/keep.md")
-	cleanupRedisKey(t, "sync:"+dir+"/ignored.md")
-	defer cleanupRedisKey(t, "sync:"+dir+"/keep.md")
-	defer cleanupRedisKey(t, "sync:"+dir+"/ignored.md")
+	for _, args := range [][]string{
+		{"search", "--vector", "[0.1, 0.2]", "--expand"},
+		{"search", "--query", "staging", "--expand", "--cursor", store.EncodeCursor(5)},
+		{"search", "--query", "staging", "--expand-model", "m", "--per-type-limit", "fact:2"},
+	} {
+		if out, err := runCLI(t, binary, append(global, args...)...); err == nil {
+			t.Errorf("%v: expected an error\n%s", args, out)
+		}
+	}
+}
 
-	// Sync with --base pointing to the dir (so .clawbrain-ignore is found)
-	out, err := runCLI(t, binary, "sync", "--dir", dir, "--base", dir)
-	if err != nil {
-		t.Fatalf("sync failed: %v\n%s", err, out)
+func TestCLIReplicaFlags(t *testing.T) {
+	binary := buildBinary(t)
+	for _, args := range [][]string{
+		{"--replicas", "qdrant-b:port", "check"},
+		{"--replicas", "qdrant-b", "--replica-writes", "sometimes", "check"},
+	} {
+		out, err := runCLI(t, binary, args...)
+		if err == nil {
+			t.Errorf("%v: expected an error\n%s", args, out)
+			continue
+		}
+		if result := parseJSON(t, out); result["status"] != "error" {
+			t.Errorf("%v: expected an error status, got %v", args, result)
+		}
 	}
+}
 
-	result := parseJSON(t, out)
-	if result["status"] != "ok" {
-		t.Fatalf("expected status ok, got %v", result["status"])
+func TestCLIEmbedderFallbacks(t *testing.T) {
+	binary := buildBinary(t)
+	backup := wordsOllama(t)
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	file := []string{"--backend", "file", "--path", t.TempDir(), "--ollama-url", down.URL, "--embedder-fallbacks", "ollama=" + backup.URL}
+	run := func(args ...string) map[string]any {
+		t.Helper()
+		out, err := runCLI(t, binary, append(file, args...)...)
+		if err != nil {
+			t.Fatalf("%v failed: %v\n%s", args, err, out)
+		}
+		return parseJSON(t, out)
 	}
 
-	added, _ := result["added"].(float64)
-	skipped, _ := result["skipped"].(float64)
-	if added < 1 {
-		t.Errorf("expected at least 1 added chunk (from keep.md), got %v", added)
+	id := run("add", "--text", "deploys go out on tuesdays")["id"].(string)
+	backupName := "ollama@" + strings.TrimPrefix(backup.URL, "http://")
+	payload := run("get", "--id", id)["payload"].(map[string]any)
+	if payload["embedded_by"] != backupName {
+		t.Errorf("expected the backup to embed while the primary is down, got %v", payload)
 	}
-	if skipped < 1 {
-		t.Errorf("expected at least 1 skipped (ignored.md), got %v", skipped)
+	if results, _ := run("search", "--query", "when do deploys go out")["results"].([]any); len(results) != 1 {
+		t.Errorf("expected the search to embed with the backup too, got %v", results)
 	}
 
-	// Verify ignored.md has the right reason
-	results := result["results"].([]any)
-	for _, r := range results {
-		fr := r.(map[string]any)
-		file := fr["file"].(string)
-		if filepath.Base(file) == "ignored.md" {
-			reason, _ := fr["reason"].(string)
-			if reason != "excluded by ignore pattern" {
-				t.Errorf("expected reason 'excluded by ignore pattern' for ignored.md, got %q", reason)
-			}
-		}
+	res := run("check")
+	embedders, _ := res["embedders"].([]any)
+	if res["status"] != "degraded" || len(embedders) != 2 {
+		t.Fatalf("expected a degraded check listing both embedders, got %v", res)
+	}
+	if primary := embedders[0].(map[string]any); primary["healthy"] != false || primary["primary"] != true {
+		t.Errorf("expected the primary to be down, got %v", primary)
 	}
-}
 
-func TestMain(m *testing.M) {
-	os.Exit(m.Run())
+	out, err := runCLI(t, binary, "--embedder-fallbacks", "http://backup:11434", "check")
+	if err == nil || parseJSON(t, out)["status"] != "error" {
+		t.Errorf("expected a fallback without a kind to be rejected, got %s", out)
+	}
 }
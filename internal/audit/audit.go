@@ -0,0 +1,103 @@
+// Package audit keeps an append-only record of destructive memory operations
+// (forget, delete, purge) as JSON lines in a local file. The log exists so
+// deletions can be accounted for after the fact, e.g. in a retention report.
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Event is a single audited operation.
+type Event struct {
+	Time   string         `json:"time"`
+	Action string         `json:"action"`
+	Count  int            `json:"count"`
+	IDs    []string       `json:"ids,omitempty"`
+	Detail map[string]any `json:"detail,omitempty"`
+	// RequestID is the ID of the command or request that did it (see
+	// package reqid).
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// Log appends events to a JSONL file. A Log with an empty path is disabled:
+// Record is a no-op and Events returns nothing.
+type Log struct {
+	path string
+}
+
+// New returns a Log writing to path. An empty path disables auditing.
+func New(path string) *Log {
+	return &Log{path: path}
+}
+
+// Enabled reports whether events are being recorded.
+func (l *Log) Enabled() bool {
+	return l.path != ""
+}
+
+// Record appends an event to the log, stamping it with the current time if
+// the event has none. The parent directory is created if missing.
+func (l *Log) Record(e Event) error {
+	if !l.Enabled() {
+		return nil
+	}
+	if e.Time == "" {
+		e.Time = time.Now().UTC().Format(time.RFC3339Nano)
+	}
+
+	line, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("marshal audit event: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(l.path), 0o755); err != nil {
+		return fmt.Errorf("create audit log dir: %w", err)
+	}
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("open audit log: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("write audit log: %w", err)
+	}
+	return nil
+}
+
+// Events reads every event in the log. A missing log file yields no events.
+// Malformed lines are skipped so a single bad write can't hide the history.
+func (l *Log) Events() ([]Event, error) {
+	if !l.Enabled() {
+		return nil, nil
+	}
+	f, err := os.Open(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open audit log: %w", err)
+	}
+	defer f.Close()
+
+	var events []Event
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		events = append(events, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read audit log: %w", err)
+	}
+	return events, nil
+}
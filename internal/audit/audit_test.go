@@ -0,0 +1,81 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRecordAndEvents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "audit.jsonl")
+	l := New(path)
+
+	if !l.Enabled() {
+		t.Fatal("expected log with a path to be enabled")
+	}
+
+	events, err := l.Events()
+	if err != nil {
+		t.Fatalf("Events on missing file failed: %v", err)
+	}
+	if len(events) != 0 {
+		t.Fatalf("expected no events before first write, got %d", len(events))
+	}
+
+	if err := l.Record(Event{Action: "forget", Count: 3}); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	if err := l.Record(Event{Action: "delete", Count: 1, IDs: []string{"abc"}}); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+
+	events, err = l.Events()
+	if err != nil {
+		t.Fatalf("Events failed: %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events))
+	}
+	if events[0].Action != "forget" || events[0].Count != 3 {
+		t.Errorf("unexpected first event: %+v", events[0])
+	}
+	if events[0].Time == "" {
+		t.Error("expected Record to stamp a time")
+	}
+	if len(events[1].IDs) != 1 || events[1].IDs[0] != "abc" {
+		t.Errorf("unexpected second event IDs: %v", events[1].IDs)
+	}
+}
+
+func TestEventsSkipsMalformedLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	content := `{"time":"2026-01-01T00:00:00Z","action":"forget","count":2}
+not json
+{"time":"2026-01-02T00:00:00Z","action":"delete","count":1}
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := New(path).Events()
+	if err != nil {
+		t.Fatalf("Events failed: %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("expected 2 valid events, got %d", len(events))
+	}
+}
+
+func TestDisabledLog(t *testing.T) {
+	l := New("")
+	if l.Enabled() {
+		t.Fatal("expected empty path to disable the log")
+	}
+	if err := l.Record(Event{Action: "forget"}); err != nil {
+		t.Fatalf("Record on disabled log should be a no-op, got %v", err)
+	}
+	events, err := l.Events()
+	if err != nil || events != nil {
+		t.Fatalf("expected no events and no error, got %v, %v", events, err)
+	}
+}
@@ -0,0 +1,230 @@
+// Package backoff recognizes an overloaded backend, so callers can tell an
+// agent to come back later instead of failing it outright. Classify turns
+// the errors Qdrant and Ollama give under load into an Error with a
+// retry-after; Monitor watches recent calls and reports overload before
+// requests start timing out.
+package backoff
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hsk-coder/clawbrain/internal/embedder"
+	"github.com/hsk-coder/clawbrain/internal/ollama"
+	"github.com/qdrant/go-client/qdrant"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultRetryAfter is suggested when the backend doesn't say how long to
+// wait.
+const DefaultRetryAfter = 5 * time.Second
+
+// Error reports that a backend is overloaded and the call is worth retrying
+// after RetryAfter.
+type Error struct {
+	Backend    string // "qdrant", "ollama", "embedder", or empty if it can't be told
+	RetryAfter time.Duration
+	Reason     string
+	Err        error // the underlying error, if any
+}
+
+func (e *Error) Error() string {
+	backend := e.Backend
+	if backend == "" {
+		backend = "backend"
+	}
+	return fmt.Sprintf("%s overloaded: %s", backend, e.Reason)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Seconds returns RetryAfter rounded up to whole seconds, at least 1, as
+// the Retry-After header and the backoff response carry it.
+func (e *Error) Seconds() int {
+	return max(1, int(math.Ceil(e.RetryAfter.Seconds())))
+}
+
+// Response returns the {"status":"backoff"} object the CLI prints and serve
+// answers with.
+func (e *Error) Response() map[string]any {
+	resp := map[string]any{
+		"status":      "backoff",
+		"message":     e.Error(),
+		"retry_after": e.Seconds(),
+	}
+	if e.Backend != "" {
+		resp["backend"] = e.Backend
+	}
+	return resp
+}
+
+// Classify returns err as an *Error if it means a backend is overloaded or
+// unreachable for now: Qdrant refusing work or unavailable, Ollama
+// answering 429 or 503, or a call running out of time. Other errors, such
+// as bad input or a missing memory, give nil.
+func Classify(err error) *Error {
+	if err == nil {
+		return nil
+	}
+	var be *Error
+	if errors.As(err, &be) {
+		return be
+	}
+
+	var exhausted *qdrant.QdrantResourceExhaustedError
+	if errors.As(err, &exhausted) {
+		return &Error{Backend: "qdrant", RetryAfter: time.Duration(exhausted.RetryAfterS) * time.Second, Reason: "rate limited", Err: err}
+	}
+
+	var se *ollama.StatusError
+	if errors.As(err, &se) {
+		switch se.Code {
+		case 429, 503:
+			wait := se.RetryAfter
+			if wait <= 0 {
+				wait = DefaultRetryAfter
+			}
+			return &Error{Backend: "ollama", RetryAfter: wait, Reason: fmt.Sprintf("answered %d", se.Code), Err: err}
+		}
+		return nil
+	}
+
+	var ee *embedder.StatusError
+	if errors.As(err, &ee) {
+		switch ee.Code {
+		case 429, 503:
+			wait := ee.RetryAfter
+			if wait <= 0 {
+				wait = DefaultRetryAfter
+			}
+			return &Error{Backend: "embedder", RetryAfter: wait, Reason: fmt.Sprintf("answered %d", ee.Code), Err: err}
+		}
+		return nil
+	}
+
+	// The Ollama client speaks HTTP, Qdrant gRPC: a failed HTTP request
+	// can only be Ollama.
+	var ue *url.Error
+	if errors.As(err, &ue) {
+		if errors.Is(err, context.DeadlineExceeded) || ue.Timeout() {
+			return &Error{Backend: "ollama", RetryAfter: DefaultRetryAfter, Reason: "timed out", Err: err}
+		}
+		return nil
+	}
+
+	if st, ok := status.FromError(err); ok {
+		switch st.Code() {
+		case codes.ResourceExhausted:
+			return &Error{Backend: "qdrant", RetryAfter: DefaultRetryAfter, Reason: "rate limited", Err: err}
+		case codes.Unavailable:
+			return &Error{Backend: "qdrant", RetryAfter: DefaultRetryAfter, Reason: "unavailable", Err: err}
+		case codes.DeadlineExceeded:
+			return &Error{Backend: "qdrant", RetryAfter: DefaultRetryAfter, Reason: "timed out", Err: err}
+		}
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return &Error{RetryAfter: DefaultRetryAfter, Reason: "timed out", Err: err}
+	}
+	return nil
+}
+
+// Monitor tracks the latency and failures of recent calls over a sliding
+// window and reports overload once either crosses its threshold. A zero
+// threshold disables that check.
+type Monitor struct {
+	Window       time.Duration // how far back calls count
+	MaxLatency   time.Duration // median latency above which calls are shed
+	MaxErrorRate float64       // share of failed calls above which calls are shed
+	MinSamples   int           // calls needed in the window before judging
+
+	mu      sync.Mutex
+	samples []sample
+	now     func() time.Time
+}
+
+type sample struct {
+	at      time.Time
+	latency time.Duration
+	failed  bool
+}
+
+// NewMonitor returns a Monitor over a 30-second window that judges once it
+// has seen 5 calls.
+func NewMonitor(maxLatency time.Duration, maxErrorRate float64) *Monitor {
+	return &Monitor{
+		Window:       30 * time.Second,
+		MaxLatency:   maxLatency,
+		MaxErrorRate: maxErrorRate,
+		MinSamples:   5,
+		now:          time.Now,
+	}
+}
+
+// Observe records a finished call.
+func (m *Monitor) Observe(latency time.Duration, failed bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	m.prune(now)
+	m.samples = append(m.samples, sample{at: now, latency: latency, failed: failed})
+}
+
+// Check returns an *Error if the calls in the window say the backends are
+// overloaded, nil otherwise. The suggested wait is how long until the
+// oldest call leaves the window, when the verdict is next reconsidered
+// from fresh calls.
+func (m *Monitor) Check() *Error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	m.prune(now)
+	n := len(m.samples)
+	if n == 0 || n < m.MinSamples {
+		return nil
+	}
+
+	failed := 0
+	latencies := make([]time.Duration, n)
+	for i, s := range m.samples {
+		latencies[i] = s.latency
+		if s.failed {
+			failed++
+		}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	median := latencies[n/2]
+
+	var reason string
+	switch {
+	case m.MaxErrorRate > 0 && float64(failed)/float64(n) > m.MaxErrorRate:
+		reason = fmt.Sprintf("%d of the last %d calls failed", failed, n)
+	case m.MaxLatency > 0 && median > m.MaxLatency:
+		reason = fmt.Sprintf("median latency %s over the last %d calls, above %s", median.Round(time.Millisecond), n, m.MaxLatency)
+	default:
+		return nil
+	}
+	wait := m.samples[0].at.Add(m.Window).Sub(now)
+	if wait <= 0 {
+		wait = DefaultRetryAfter
+	}
+	return &Error{RetryAfter: wait, Reason: reason}
+}
+
+// prune drops calls older than the window. Samples are in time order.
+func (m *Monitor) prune(now time.Time) {
+	cutoff := now.Add(-m.Window)
+	i := 0
+	for i < len(m.samples) && m.samples[i].at.Before(cutoff) {
+		i++
+	}
+	m.samples = m.samples[i:]
+}
@@ -0,0 +1,122 @@
+package backoff
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hsk-coder/clawbrain/internal/embedder"
+	"github.com/hsk-coder/clawbrain/internal/ollama"
+	"github.com/qdrant/go-client/qdrant"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		backend     string
+		retryAfter  time.Duration
+		wantBackoff bool
+	}{
+		{"nil", nil, "", 0, false},
+		{"plain", errors.New("memory not found"), "", 0, false},
+		{"qdrant rate limited", fmt.Errorf("search: %w", &qdrant.QdrantResourceExhaustedError{Reason: "too many requests", RetryAfterS: 7}), "qdrant", 7 * time.Second, true},
+		{"qdrant unavailable", fmt.Errorf("scroll points: %w", status.Error(codes.Unavailable, "connection refused")), "qdrant", DefaultRetryAfter, true},
+		{"qdrant deadline", status.Error(codes.DeadlineExceeded, "deadline exceeded"), "qdrant", DefaultRetryAfter, true},
+		{"qdrant bad request", status.Error(codes.InvalidArgument, "wrong vector size"), "", 0, false},
+		{"ollama busy", fmt.Errorf("embedding failed: %w", &ollama.StatusError{Code: 503, RetryAfter: 3 * time.Second}), "ollama", 3 * time.Second, true},
+		{"ollama throttled", &ollama.StatusError{Code: 429}, "ollama", DefaultRetryAfter, true},
+		{"ollama missing model", &ollama.StatusError{Code: 404}, "", 0, false},
+		{"embedder throttled", &embedder.StatusError{Code: 429, RetryAfter: 7 * time.Second}, "embedder", 7 * time.Second, true},
+		{"embedder bad key", &embedder.StatusError{Code: 401}, "", 0, false},
+		{"deadline", fmt.Errorf("count: %w", context.DeadlineExceeded), "", DefaultRetryAfter, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Classify(tt.err)
+			if !tt.wantBackoff {
+				if got != nil {
+					t.Fatalf("expected no backoff, got %v", got)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatal("expected a backoff error")
+			}
+			if got.Backend != tt.backend || got.RetryAfter != tt.retryAfter {
+				t.Errorf("expected %s after %s, got %s after %s", tt.backend, tt.retryAfter, got.Backend, got.RetryAfter)
+			}
+			if !errors.Is(got, tt.err) && !errors.Is(got.Err, tt.err) {
+				t.Errorf("expected the original error to be kept, got %v", got.Err)
+			}
+		})
+	}
+}
+
+func TestResponse(t *testing.T) {
+	e := &Error{Backend: "ollama", RetryAfter: 1500 * time.Millisecond, Reason: "answered 503"}
+	resp := e.Response()
+	if resp["status"] != "backoff" || resp["backend"] != "ollama" || resp["retry_after"] != 2 {
+		t.Errorf("unexpected response %v", resp)
+	}
+	if resp["message"] != "ollama overloaded: answered 503" {
+		t.Errorf("unexpected message %q", resp["message"])
+	}
+}
+
+// testMonitor returns a Monitor on a clock the test moves by hand.
+func testMonitor(maxLatency time.Duration, maxErrorRate float64) (*Monitor, *time.Time) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	m := NewMonitor(maxLatency, maxErrorRate)
+	m.now = func() time.Time { return now }
+	return m, &now
+}
+
+func TestMonitorErrorRate(t *testing.T) {
+	m, now := testMonitor(0, 0.5)
+	for i := range 4 {
+		m.Observe(10*time.Millisecond, i > 0)
+	}
+	if e := m.Check(); e != nil {
+		t.Fatalf("expected no verdict before MinSamples calls, got %v", e)
+	}
+	m.Observe(10*time.Millisecond, true)
+	e := m.Check()
+	if e == nil {
+		t.Fatal("expected backoff with 4 of 5 calls failed")
+	}
+	if e.RetryAfter != 30*time.Second {
+		t.Errorf("expected to wait out the window, got %s", e.RetryAfter)
+	}
+
+	*now = now.Add(31 * time.Second)
+	if e := m.Check(); e != nil {
+		t.Errorf("expected recovery once the failures left the window, got %v", e)
+	}
+}
+
+func TestMonitorLatency(t *testing.T) {
+	m, now := testMonitor(time.Second, 0)
+	for range 5 {
+		m.Observe(100*time.Millisecond, false)
+		*now = now.Add(time.Second)
+	}
+	if e := m.Check(); e != nil {
+		t.Fatalf("expected fast calls to pass, got %v", e)
+	}
+	for range 6 {
+		m.Observe(3*time.Second, true)
+	}
+	e := m.Check()
+	if e == nil {
+		t.Fatal("expected backoff once the median is slow")
+	}
+	// Failures alone don't count when the error rate check is off.
+	if e.Backend != "" || e.RetryAfter != 25*time.Second {
+		t.Errorf("unexpected backoff %+v", e)
+	}
+}
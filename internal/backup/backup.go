@@ -0,0 +1,119 @@
+// Package backup reads and writes memory exports: a JSONL file whose first
+// line is a header describing the export, followed by one memory per line
+// with its vector and payload. Keeping vectors means a restore needs no
+// embedding model, as long as it goes into a collection of the same size.
+package backup
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/hsk-coder/clawbrain/internal/store"
+)
+
+// FormatVersion is the export format this package writes. Read refuses
+// files from a newer version.
+const FormatVersion = 1
+
+// Header is the first line of an export.
+type Header struct {
+	Format     int    `json:"clawbrain_export"`
+	Model      string `json:"model"`      // embedding model configured at export time
+	Dimensions uint64 `json:"dimensions"` // size of every vector in the file
+	Agent      string `json:"agent,omitempty"`
+	Namespace  string `json:"namespace,omitempty"`
+	ExportedAt string `json:"exported_at"`
+	// SyncState is what sync had recorded about each file it ingested into
+	// the namespace, by path: a content hash for MEMORY.md, "1" for files
+	// synced once. Restoring it keeps sync from ingesting them all again.
+	SyncState map[string]string `json:"sync_state,omitempty"`
+}
+
+// Writer writes an export, header first.
+type Writer struct {
+	w     *bufio.Writer
+	enc   *json.Encoder
+	count int
+}
+
+// NewWriter writes the header to w and returns a Writer for the memories.
+// Call Flush when done.
+func NewWriter(w io.Writer, h Header) (*Writer, error) {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	h.Format = FormatVersion
+	if err := enc.Encode(h); err != nil {
+		return nil, err
+	}
+	return &Writer{w: bw, enc: enc}, nil
+}
+
+// Write appends one memory.
+func (w *Writer) Write(p store.Point) error {
+	if err := w.enc.Encode(p); err != nil {
+		return err
+	}
+	w.count++
+	return nil
+}
+
+// Count returns how many memories have been written.
+func (w *Writer) Count() int {
+	return w.count
+}
+
+// Flush writes any buffered data to the underlying writer.
+func (w *Writer) Flush() error {
+	return w.w.Flush()
+}
+
+// Read parses an export. Every memory must have a UUID, a payload and a
+// vector of the header's size; the first bad line fails the whole read, so
+// nothing is restored from a damaged file.
+func Read(r io.Reader) (Header, []store.Point, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	var h Header
+	var points []store.Point
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := scanner.Bytes()
+		if len(text) == 0 {
+			continue
+		}
+		if h.Format == 0 {
+			if err := json.Unmarshal(text, &h); err != nil || h.Format == 0 {
+				return Header{}, nil, fmt.Errorf("line %d: not a clawbrain export header", line)
+			}
+			if h.Format > FormatVersion {
+				return Header{}, nil, fmt.Errorf("export format %d is newer than this clawbrain supports (%d)", h.Format, FormatVersion)
+			}
+			continue
+		}
+		var p store.Point
+		if err := json.Unmarshal(text, &p); err != nil {
+			return Header{}, nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		if err := store.ValidateID(p.ID); err != nil {
+			return Header{}, nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		if p.Payload == nil {
+			return Header{}, nil, fmt.Errorf("line %d: memory %s has no payload", line, p.ID)
+		}
+		if uint64(len(p.Vector)) != h.Dimensions {
+			return Header{}, nil, fmt.Errorf("line %d: memory %s has %d dimensions, header says %d", line, p.ID, len(p.Vector), h.Dimensions)
+		}
+		points = append(points, p)
+	}
+	if err := scanner.Err(); err != nil {
+		return Header{}, nil, err
+	}
+	if h.Format == 0 {
+		return Header{}, nil, fmt.Errorf("empty export")
+	}
+	return h, points, nil
+}
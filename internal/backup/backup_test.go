@@ -0,0 +1,67 @@
+package backup
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/hsk-coder/clawbrain/internal/store"
+)
+
+func TestWriteRead(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, Header{Model: "all-minilm", Dimensions: 4, ExportedAt: "2026-10-01T12:00:00Z",
+		SyncState: map[string]string{"/workspace/MEMORY.md": "9f86d081"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	points := []store.Point{
+		{ID: "4f8a7c1e-2b3d-4e5f-8a9b-0c1d2e3f4a5b", Vector: []float32{0.1, 0.2, 0.3, 0.4}, Payload: map[string]any{"text": "deploys go out on tuesdays"}},
+		{ID: "9e2a7c1e-2b3d-4e5f-8a9b-0c1d2e3f4a5b", Vector: []float32{0.4, 0.3, 0.2, 0.1}, Payload: map[string]any{"text": "standup is at 10:00", "pinned": true}},
+	}
+	for _, p := range points {
+		if err := w.Write(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if w.Count() != 2 {
+		t.Errorf("expected a count of 2, got %d", w.Count())
+	}
+
+	h, got, err := Read(&buf)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if h.Format != FormatVersion || h.Model != "all-minilm" || h.Dimensions != 4 || h.SyncState["/workspace/MEMORY.md"] != "9f86d081" {
+		t.Errorf("unexpected header %+v", h)
+	}
+	if len(got) != 2 || got[1].ID != points[1].ID || got[1].Vector[0] != 0.4 || got[1].Payload["pinned"] != true {
+		t.Errorf("memories didn't survive the round trip: %+v", got)
+	}
+}
+
+func TestReadRejects(t *testing.T) {
+	header := `{"clawbrain_export":1,"model":"all-minilm","dimensions":2}` + "\n"
+	tests := []struct {
+		name, input, want string
+	}{
+		{"empty", "", "empty export"},
+		{"no header", `{"id":"x"}`, "not a clawbrain export header"},
+		{"newer format", `{"clawbrain_export":99}`, "newer"},
+		{"bad id", header + `{"id":"deploy-notes","vector":[1,2],"payload":{}}`, "not a memory ID"},
+		{"no payload", header + `{"id":"4f8a7c1e-2b3d-4e5f-8a9b-0c1d2e3f4a5b","vector":[1,2]}`, "no payload"},
+		{"wrong size", header + `{"id":"4f8a7c1e-2b3d-4e5f-8a9b-0c1d2e3f4a5b","vector":[1],"payload":{}}`, "1 dimensions"},
+		{"bad json", header + "\n{", "line 3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := Read(strings.NewReader(tt.input))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected an error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
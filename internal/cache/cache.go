@@ -0,0 +1,176 @@
+// Package cache stores search responses in Redis, keyed by a normalized form
+// of the query, so agents asking near-identical questions on a schedule skip
+// the embedding call and the vector search. Entries expire after a TTL and
+// are invalidated early when a memory is added that mentions an entity from
+// the cached query or shares a tag with a cached result.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/hsk-coder/clawbrain/internal/redis"
+)
+
+// Redis key layout.
+const (
+	keyPrefix   = "clawbrain:cache:"
+	entryPrefix = keyPrefix + "entry:"
+	entityIndex = keyPrefix + "entity:"  // + lower-cased entity → set of entry keys
+	tagIndex    = keyPrefix + "tag:"     // + tag → set of entry keys
+	entitySet   = keyPrefix + "entities" // every entity with a live index
+)
+
+// DefaultTTL is how long a cached response lives without invalidation.
+const DefaultTTL = 30 * time.Minute
+
+// Entry is a cached search response.
+type Entry struct {
+	Query    string          `json:"query"`
+	Entities []string        `json:"entities,omitempty"`
+	Tags     []string        `json:"tags,omitempty"`
+	Response json.RawMessage `json:"response"`
+	CachedAt string          `json:"cached_at"`
+}
+
+// Cache reads and writes entries in Redis.
+type Cache struct {
+	rc  *redis.Client
+	ttl time.Duration
+}
+
+// New returns a Cache storing entries in rc for ttl.
+func New(rc *redis.Client, ttl time.Duration) *Cache {
+	return &Cache{rc: rc, ttl: ttl}
+}
+
+// Normalize folds case, drops punctuation and collapses whitespace, so
+// "What's pending?" and "whats   pending" share a cache entry.
+func Normalize(query string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(query) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		case unicode.IsSpace(r) || r == '-' || r == '_' || r == '.' || r == '/':
+			b.WriteRune(' ')
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// Key returns the cache key for a query. scope distinguishes searches for the
+// same query that can return different results (limit, model, filters, ...).
+func Key(query, scope string) string {
+	sum := sha256.Sum256([]byte(Normalize(query) + "\x00" + scope))
+	return entryPrefix + hex.EncodeToString(sum[:])
+}
+
+// Get returns the cached entry for key, if any.
+func (c *Cache) Get(key string) (*Entry, bool, error) {
+	raw, ok, err := c.rc.Get(key)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	var e Entry
+	if err := json.Unmarshal([]byte(raw), &e); err != nil {
+		// A corrupt entry is a miss, not an error; it'll be overwritten.
+		return nil, false, nil
+	}
+	return &e, true, nil
+}
+
+// Put stores an entry under key and indexes it by its entities and tags for
+// invalidation. Index sets expire with the entry.
+func (c *Cache) Put(key string, e Entry) error {
+	if e.CachedAt == "" {
+		e.CachedAt = time.Now().UTC().Format(time.RFC3339Nano)
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("marshal cache entry: %w", err)
+	}
+	ttl := max(int(c.ttl.Seconds()), 1)
+	if err := c.rc.SetWithTTL(key, string(data), ttl); err != nil {
+		return fmt.Errorf("store cache entry: %w", err)
+	}
+
+	for _, entity := range e.Entities {
+		name := strings.ToLower(entity)
+		if err := c.index(entityIndex+name, key, ttl); err != nil {
+			return err
+		}
+		// The registry lives as long as the newest entry; names whose index
+		// already expired just match nothing until then.
+		if err := c.index(entitySet, name, ttl); err != nil {
+			return err
+		}
+	}
+	for _, tag := range e.Tags {
+		if err := c.index(tagIndex+tag, key, ttl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// index adds key to an invalidation set and extends the set's TTL.
+func (c *Cache) index(set, key string, ttl int) error {
+	if err := c.rc.SAdd(set, key); err != nil {
+		return fmt.Errorf("index cache entry: %w", err)
+	}
+	if err := c.rc.Expire(set, ttl); err != nil {
+		return fmt.Errorf("index cache entry: %w", err)
+	}
+	return nil
+}
+
+// Invalidate drops every entry a newly added memory could change: entries
+// whose query names an entity the text mentions (case-insensitively), and
+// entries whose results carry one of the tags. Returns how many entries were
+// dropped.
+func (c *Cache) Invalidate(text string, tags []string) (int, error) {
+	lower := strings.ToLower(text)
+	var sets []string
+
+	entities, err := c.rc.SMembers(entitySet)
+	if err != nil {
+		return 0, fmt.Errorf("read cache entities: %w", err)
+	}
+	var dropped []string
+	for _, name := range entities {
+		if strings.Contains(lower, name) {
+			sets = append(sets, entityIndex+name)
+			dropped = append(dropped, name)
+		}
+	}
+	for _, tag := range tags {
+		sets = append(sets, tagIndex+tag)
+	}
+
+	var keys []string
+	for _, set := range sets {
+		members, err := c.rc.SMembers(set)
+		if err != nil {
+			return 0, fmt.Errorf("read cache index: %w", err)
+		}
+		keys = append(keys, members...)
+	}
+
+	n, err := c.rc.Del(keys...)
+	if err != nil {
+		return 0, fmt.Errorf("drop cache entries: %w", err)
+	}
+	if _, err := c.rc.Del(sets...); err != nil {
+		return 0, fmt.Errorf("drop cache index: %w", err)
+	}
+	if err := c.rc.SRem(entitySet, dropped...); err != nil {
+		return 0, fmt.Errorf("drop cache entities: %w", err)
+	}
+	return n, nil
+}
@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/hsk-coder/clawbrain/internal/redis"
+)
+
+func TestNormalize(t *testing.T) {
+	cases := map[string]string{
+		"What's pending?":           "whats pending",
+		"  whats   PENDING ":        "whats pending",
+		"status of auth-service":    "status of auth service",
+		"notes/2024_01.md changes!": "notes 2024 01 md changes",
+		"":                          "",
+	}
+	for in, want := range cases {
+		if got := Normalize(in); got != want {
+			t.Errorf("Normalize(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestKey(t *testing.T) {
+	if Key("What's pending?", "limit=5") != Key("whats   pending", "limit=5") {
+		t.Error("expected equivalent queries to share a key")
+	}
+	if Key("whats pending", "limit=5") == Key("whats pending", "limit=10") {
+		t.Error("expected scope to change the key")
+	}
+	if Key("whats pending", "") == Key("whats done", "") {
+		t.Error("expected different queries to get different keys")
+	}
+}
+
+func skipIfNoRedis(t *testing.T) *redis.Client {
+	t.Helper()
+	conn, err := net.DialTimeout("tcp", "localhost:6379", 2*time.Second)
+	if err != nil {
+		t.Skipf("Redis not available on localhost:6379, skipping: %v", err)
+	}
+	conn.Close()
+	rc, err := redis.New("localhost", 6379)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { rc.Close() })
+	return rc
+}
+
+func TestPutGetInvalidate(t *testing.T) {
+	rc := skipIfNoRedis(t)
+	c := New(rc, time.Minute)
+
+	byEntity := Key("clawbrain_test status of Zephyr", "")
+	byTag := Key("clawbrain_test deploy notes", "")
+	other := Key("clawbrain_test unrelated", "")
+	t.Cleanup(func() { rc.Del(byEntity, byTag, other) })
+
+	response := json.RawMessage(`{"status":"ok","results":[]}`)
+	entries := map[string]Entry{
+		byEntity: {Query: "status of Zephyr", Entities: []string{"Zephyr"}, Response: response},
+		byTag:    {Query: "deploy notes", Tags: []string{"clawbrain-test-deploy"}, Response: response},
+		other:    {Query: "unrelated", Entities: []string{"Quokka"}, Response: response},
+	}
+	for key, e := range entries {
+		if err := c.Put(key, e); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+
+	got, ok, err := c.Get(byEntity)
+	if err != nil || !ok {
+		t.Fatalf("expected hit, got ok=%v err=%v", ok, err)
+	}
+	if got.Query != "status of Zephyr" || string(got.Response) != string(response) || got.CachedAt == "" {
+		t.Errorf("unexpected entry: %+v", got)
+	}
+
+	n, err := c.Invalidate("Zephyr rollout finished", []string{"clawbrain-test-deploy"})
+	if err != nil {
+		t.Fatalf("Invalidate failed: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 entries invalidated, got %d", n)
+	}
+	for _, key := range []string{byEntity, byTag} {
+		if _, ok, _ := c.Get(key); ok {
+			t.Errorf("expected %s to be invalidated", key)
+		}
+	}
+	if _, ok, _ := c.Get(other); !ok {
+		t.Error("expected unrelated entry to survive")
+	}
+}
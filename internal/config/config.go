@@ -0,0 +1,171 @@
+// Package config loads the optional clawbrain JSON config file. It holds
+// settings too structured to pass as flags, such as write policies and typed
+// payload fields.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/hsk-coder/clawbrain/internal/limits"
+	"github.com/hsk-coder/clawbrain/internal/policy"
+	"github.com/hsk-coder/clawbrain/internal/ranking"
+	"github.com/hsk-coder/clawbrain/internal/retention"
+	"github.com/hsk-coder/clawbrain/internal/store"
+)
+
+// Config is the top-level config file shape.
+type Config struct {
+	Policy policy.Policy `json:"policy"`
+	// Fields declares typed payload fields, e.g. {"priority": "integer",
+	// "location": "geo"}. add coerces their values and indexes them.
+	Fields map[string]store.FieldKind `json:"fields"`
+	// Types lists the allowed memory types. Empty means store.DefaultTypes.
+	Types []string `json:"types"`
+	// Boosts adds to the search score of memories of a type, keyed like
+	// search --boost: {"type:todo": 0.05}.
+	Boosts map[string]float64 `json:"boosts"`
+	// Limits caps the memories search, get and orient return, so one
+	// huge memory can't flood an agent's context.
+	Limits limits.Limits `json:"limits"`
+	// AutoForget has serve run forget itself, for deployments with no cron
+	// to run it.
+	AutoForget AutoForget `json:"auto_forget"`
+}
+
+// AutoForget schedules the forget passes serve runs. Durations take the
+// forms forget's flags do, e.g. "30d" or "12h".
+type AutoForget struct {
+	// OnStart runs a pass as the server starts.
+	OnStart bool `json:"on_start,omitempty"`
+	// Interval runs a pass this often; empty runs none on a schedule.
+	Interval string `json:"interval,omitempty"`
+	// TTL and PersonalTTL are forget's --ttl and --personal-ttl; empty
+	// means their defaults.
+	TTL         string `json:"ttl,omitempty"`
+	PersonalTTL string `json:"personal_ttl,omitempty"`
+	// Hard deletes stale memories instead of archiving them.
+	Hard bool `json:"hard,omitempty"`
+}
+
+const (
+	// DefaultAutoForgetTTL is the TTL of an auto-forget pass that names
+	// none, the same as forget's.
+	DefaultAutoForgetTTL = 30 * retention.Day
+	// MinAutoForgetInterval is the shortest auto-forget interval.
+	MinAutoForgetInterval = time.Minute
+)
+
+// Enabled reports whether serve runs any pass.
+func (a AutoForget) Enabled() bool {
+	return a.OnStart || a.Interval != ""
+}
+
+// Durations returns the interval, TTL and personal TTL, with the defaults
+// filled in. Load has validated them.
+func (a AutoForget) Durations() (interval, ttl, personalTTL time.Duration) {
+	interval, _ = parseOptionalDuration(a.Interval, 0)
+	ttl, _ = parseOptionalDuration(a.TTL, DefaultAutoForgetTTL)
+	personalTTL, _ = parseOptionalDuration(a.PersonalTTL, retention.DefaultPersonalTTL)
+	return interval, ttl, personalTTL
+}
+
+// validate checks the durations parse, and that the interval isn't so
+// short a pass could still be running when the next one starts.
+func (a AutoForget) validate() error {
+	for name, v := range map[string]string{"interval": a.Interval, "ttl": a.TTL, "personal_ttl": a.PersonalTTL} {
+		d, err := parseOptionalDuration(v, 0)
+		if err != nil || d < 0 {
+			return fmt.Errorf("invalid %s %q", name, v)
+		}
+	}
+	if interval, _, _ := a.Durations(); a.Interval != "" && interval < MinAutoForgetInterval {
+		return fmt.Errorf("interval must be at least %s", MinAutoForgetInterval)
+	}
+	return nil
+}
+
+// parseOptionalDuration parses s, or returns def if it is empty.
+func parseOptionalDuration(s string, def time.Duration) (time.Duration, error) {
+	if s == "" {
+		return def, nil
+	}
+	return retention.ParseDuration(s)
+}
+
+// MemoryTypes returns the allowed memory types.
+func (c *Config) MemoryTypes() []string {
+	if len(c.Types) == 0 {
+		return store.DefaultTypes
+	}
+	return c.Types
+}
+
+// TypeBoosts returns the boosts per memory type, or nil if there are
+// none. Load has validated them.
+func (c *Config) TypeBoosts() map[string]float64 {
+	if len(c.Boosts) == 0 {
+		return nil
+	}
+	boosts := make(map[string]float64, len(c.Boosts))
+	for target, boost := range c.Boosts {
+		typ, _ := ranking.BoostType(target)
+		boosts[typ] = boost
+	}
+	return boosts
+}
+
+// Load reads and validates the config file at path. An empty path returns
+// the zero Config, which enforces nothing. Unknown keys are rejected so a
+// typo in a guardrail doesn't silently disable it.
+func Load(path string) (*Config, error) {
+	cfg := &Config{}
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(cfg); err != nil {
+		return nil, fmt.Errorf("parse config %s: %w", path, err)
+	}
+
+	if err := cfg.Policy.Compile(); err != nil {
+		return nil, fmt.Errorf("config %s: %w", path, err)
+	}
+	for name, kind := range cfg.Fields {
+		if err := kind.Validate(); err != nil {
+			return nil, fmt.Errorf("config %s: field %q: %w", path, name, err)
+		}
+	}
+	if err := store.ValidateTypes(cfg.Types); err != nil {
+		return nil, fmt.Errorf("config %s: %w", path, err)
+	}
+	for target, boost := range cfg.Boosts {
+		typ, err := ranking.BoostType(target)
+		if err == nil {
+			_, err = store.TypeFilter([]string{typ}, cfg.MemoryTypes())
+		}
+		if err != nil {
+			return nil, fmt.Errorf("config %s: boost: %w", path, err)
+		}
+		if boost < 0 {
+			return nil, fmt.Errorf("config %s: boost for %q must be non-negative", path, target)
+		}
+	}
+	if err := cfg.Limits.Validate(); err != nil {
+		return nil, fmt.Errorf("config %s: limits: %w", path, err)
+	}
+	if err := cfg.AutoForget.validate(); err != nil {
+		return nil, fmt.Errorf("config %s: auto_forget: %w", path, err)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,151 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hsk-coder/clawbrain/internal/retention"
+	"github.com/hsk-coder/clawbrain/internal/store"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "clawbrain.json")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadEmptyPath(t *testing.T) {
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("Load(\"\") failed: %v", err)
+	}
+	if vs := cfg.Policy.Evaluate(map[string]any{"text": "anything"}); vs != nil {
+		t.Errorf("empty config should enforce nothing, got %+v", vs)
+	}
+}
+
+func TestLoadPolicy(t *testing.T) {
+	path := writeConfig(t, `{"policy": {"max_text_length": 10, "banned_patterns": ["secret"]}}`)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Policy.MaxTextLength != 10 {
+		t.Errorf("expected max_text_length 10, got %d", cfg.Policy.MaxTextLength)
+	}
+	// Load compiles patterns, so Evaluate works straight away.
+	if vs := cfg.Policy.Evaluate(map[string]any{"text": "a secret"}); len(vs) != 1 {
+		t.Errorf("expected banned pattern to apply, got %+v", vs)
+	}
+}
+
+func TestLoadFields(t *testing.T) {
+	path := writeConfig(t, `{"fields": {"priority": "integer", "location": "geo"}}`)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Fields["priority"] != store.FieldInteger || cfg.Fields["location"] != store.FieldGeo {
+		t.Errorf("unexpected fields: %v", cfg.Fields)
+	}
+}
+
+func TestLoadTypes(t *testing.T) {
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.MemoryTypes(); len(got) != len(store.DefaultTypes) {
+		t.Errorf("expected the default types, got %v", got)
+	}
+
+	cfg, err = Load(writeConfig(t, `{"types": ["todo", "decision"]}`))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if got := cfg.MemoryTypes(); len(got) != 2 || got[1] != "decision" {
+		t.Errorf("expected the configured types, got %v", got)
+	}
+}
+
+func TestLoadBoosts(t *testing.T) {
+	cfg, err := Load(writeConfig(t, `{"boosts": {"type:todo": 0.05, "type:untyped": 0.01}}`))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if got := cfg.TypeBoosts(); len(got) != 2 || got["todo"] != 0.05 || got["untyped"] != 0.01 {
+		t.Errorf("expected boosts by type, got %v", got)
+	}
+	if got := (&Config{}).TypeBoosts(); got != nil {
+		t.Errorf("expected no boosts without config, got %v", got)
+	}
+}
+
+func TestLoadLimits(t *testing.T) {
+	path := writeConfig(t, `{"limits": {"max_text_length": 2000, "max_response_bytes": 65536}}`)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Limits.MaxTextLength != 2000 || cfg.Limits.MaxResponseBytes != 65536 || cfg.Limits.MaxResultBytes != 0 {
+		t.Errorf("unexpected limits: %+v", cfg.Limits)
+	}
+}
+
+func TestLoadAutoForget(t *testing.T) {
+	path := writeConfig(t, `{"auto_forget": {"on_start": true, "interval": "1d", "hard": true}}`)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !cfg.AutoForget.Enabled() || !cfg.AutoForget.Hard {
+		t.Errorf("unexpected auto_forget: %+v", cfg.AutoForget)
+	}
+	interval, ttl, personalTTL := cfg.AutoForget.Durations()
+	if interval != 24*time.Hour || ttl != DefaultAutoForgetTTL || personalTTL != retention.DefaultPersonalTTL {
+		t.Errorf("unexpected durations: %v, %v, %v", interval, ttl, personalTTL)
+	}
+
+	cfg, _ = Load("")
+	if cfg.AutoForget.Enabled() {
+		t.Error("expected no auto-forget without a config")
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+	}{
+		{"missing file", filepath.Join(t.TempDir(), "nope.json")},
+		{"invalid json", writeConfig(t, `{"policy": `)},
+		{"unknown key", writeConfig(t, `{"policy": {"max_text_lenght": 10}}`)},
+		{"bad pattern", writeConfig(t, `{"policy": {"banned_patterns": ["("]}}`)},
+		{"unknown field type", writeConfig(t, `{"fields": {"priority": "number"}}`)},
+		{"bad type name", writeConfig(t, `{"types": ["Decision"]}`)},
+		{"reserved type", writeConfig(t, `{"types": ["todo", "untyped"]}`)},
+		{"bad boost target", writeConfig(t, `{"boosts": {"todo": 0.05}}`)},
+		{"unknown boost type", writeConfig(t, `{"boosts": {"type:meeting": 0.05}}`)},
+		{"negative boost", writeConfig(t, `{"boosts": {"type:todo": -0.05}}`)},
+		{"negative limit", writeConfig(t, `{"limits": {"max_response_bytes": -1}}`)},
+		{"unknown limit", writeConfig(t, `{"limits": {"max_bytes": 100}}`)},
+		{"bad auto-forget ttl", writeConfig(t, `{"auto_forget": {"on_start": true, "ttl": "a month"}}`)},
+		{"short auto-forget interval", writeConfig(t, `{"auto_forget": {"interval": "10s"}}`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Load(tt.path); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
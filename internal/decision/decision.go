@@ -0,0 +1,141 @@
+// Package decision gives memories of the decision type the structure of an
+// architecture decision record: the context that forced a choice, the
+// options weighed, the choice made and its consequences. A text blob loses
+// which option won and why; these fields keep it, and a decision's card
+// renders them for reading.
+package decision
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hsk-coder/clawbrain/internal/store"
+)
+
+// Type is the memory type of a decision.
+const Type = "decision"
+
+// Payload fields of a decision.
+const (
+	ContextField      = "context"
+	OptionsField      = "options"
+	ChoiceField       = "choice"
+	ConsequencesField = "consequences"
+)
+
+// CardField holds a decision's card on a memory get returns.
+const CardField = "card"
+
+// Fields are the payload fields of a decision, in the order a card shows
+// them.
+var Fields = []string{ChoiceField, ContextField, OptionsField, ConsequencesField}
+
+// Is reports whether payload is a decision's.
+func Is(payload map[string]any) bool {
+	t, _ := payload[store.TypeField].(string)
+	return strings.EqualFold(strings.TrimSpace(t), Type)
+}
+
+// Validate checks a decision's fields and tidies them in place: context
+// and choice are required strings, options an optional list of strings
+// and consequences an optional string. Surrounding space is trimmed and
+// empty optional fields removed.
+func Validate(payload map[string]any) error {
+	for _, field := range []string{ContextField, ChoiceField, ConsequencesField} {
+		v, ok := payload[field]
+		if !ok || v == nil {
+			delete(payload, field)
+			continue
+		}
+		s, isStr := v.(string)
+		if !isStr {
+			return fmt.Errorf("decision field %q must be a string, got %v", field, v)
+		}
+		if s = strings.TrimSpace(s); s == "" {
+			delete(payload, field)
+		} else {
+			payload[field] = s
+		}
+	}
+	for _, field := range []string{ContextField, ChoiceField} {
+		if _, ok := payload[field]; !ok {
+			return fmt.Errorf("a decision needs a %q", field)
+		}
+	}
+
+	v, ok := payload[OptionsField]
+	if !ok || v == nil {
+		delete(payload, OptionsField)
+		return nil
+	}
+	var raw []any
+	switch list := v.(type) {
+	case []any:
+		raw = list
+	case []string:
+		for _, o := range list {
+			raw = append(raw, o)
+		}
+	default:
+		return fmt.Errorf("decision field %q must be a list of strings, got %v", OptionsField, v)
+	}
+	options := make([]any, 0, len(raw))
+	for _, o := range raw {
+		s, isStr := o.(string)
+		if !isStr {
+			return fmt.Errorf("decision field %q must be a list of strings, got %v", OptionsField, o)
+		}
+		if s = strings.TrimSpace(s); s == "" {
+			return fmt.Errorf("decision field %q must not hold an empty option", OptionsField)
+		}
+		options = append(options, s)
+	}
+	if len(options) == 0 {
+		delete(payload, OptionsField)
+	} else {
+		payload[OptionsField] = options
+	}
+	return nil
+}
+
+// Options returns a decision's options.
+func Options(payload map[string]any) []string {
+	var options []string
+	switch list := payload[OptionsField].(type) {
+	case []any:
+		for _, o := range list {
+			if s, ok := o.(string); ok {
+				options = append(options, s)
+			}
+		}
+	case []string:
+		options = list
+	}
+	return options
+}
+
+// Card renders a decision for reading, one field a line:
+//
+//	Decision: use Postgres
+//	Context: we need transactions across orders and payments
+//	Options: Postgres; DynamoDB
+//	Consequences: we run a database ourselves
+//
+// It is also the text a decision added without one is embedded by.
+func Card(payload map[string]any) string {
+	var lines []string
+	for _, field := range Fields {
+		value, _ := payload[field].(string)
+		label := strings.ToUpper(field[:1]) + field[1:]
+		switch field {
+		case ChoiceField:
+			label = "Decision"
+		case OptionsField:
+			value = strings.Join(Options(payload), "; ")
+		}
+		if value != "" {
+			lines = append(lines, label+": "+value)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,75 @@
+package decision
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	payload := map[string]any{
+		"type":       "decision",
+		ContextField: "  we need transactions across orders and payments ",
+		ChoiceField:  "use Postgres",
+		OptionsField: []string{" Postgres", "DynamoDB "},
+		// An empty optional field is dropped rather than kept blank.
+		ConsequencesField: " ",
+	}
+	if err := Validate(payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload[ContextField] != "we need transactions across orders and payments" {
+		t.Errorf("expected context trimmed, got %q", payload[ContextField])
+	}
+	if got := Options(payload); !slices.Equal(got, []string{"Postgres", "DynamoDB"}) {
+		t.Errorf("expected trimmed options, got %q", got)
+	}
+	if _, ok := payload[ConsequencesField]; ok {
+		t.Errorf("expected empty consequences removed, got %q", payload[ConsequencesField])
+	}
+
+	for name, bad := range map[string]map[string]any{
+		"no context":        {ChoiceField: "use Postgres"},
+		"no choice":         {ContextField: "we need transactions"},
+		"blank choice":      {ContextField: "we need transactions", ChoiceField: "  "},
+		"non-string field":  {ContextField: "we need transactions", ChoiceField: 3.0},
+		"options a string":  {ContextField: "x", ChoiceField: "y", OptionsField: "Postgres"},
+		"empty option":      {ContextField: "x", ChoiceField: "y", OptionsField: []any{"Postgres", ""}},
+		"non-string option": {ContextField: "x", ChoiceField: "y", OptionsField: []any{"Postgres", 2.0}},
+	} {
+		if err := Validate(bad); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestCard(t *testing.T) {
+	payload := map[string]any{
+		"type":            "decision",
+		ContextField:      "we need transactions across orders and payments",
+		ChoiceField:       "use Postgres",
+		OptionsField:      []any{"Postgres", "DynamoDB"},
+		ConsequencesField: "we run a database ourselves",
+	}
+	want := "Decision: use Postgres\n" +
+		"Context: we need transactions across orders and payments\n" +
+		"Options: Postgres; DynamoDB\n" +
+		"Consequences: we run a database ourselves"
+	if got := Card(payload); got != want {
+		t.Errorf("got card\n%s\nwant\n%s", got, want)
+	}
+
+	delete(payload, OptionsField)
+	delete(payload, ConsequencesField)
+	if got := Card(payload); got != "Decision: use Postgres\nContext: we need transactions across orders and payments" {
+		t.Errorf("expected absent fields left out, got\n%s", got)
+	}
+}
+
+func TestIs(t *testing.T) {
+	if !Is(map[string]any{"type": "Decision"}) {
+		t.Error("expected a decision")
+	}
+	if Is(map[string]any{"type": "fact"}) || Is(map[string]any{}) {
+		t.Error("expected only decisions to be decisions")
+	}
+}
@@ -0,0 +1,245 @@
+package embedder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultRecheck is how long a Chain skips a provider that failed before
+// trying it again.
+const DefaultRecheck = 30 * time.Second
+
+// Provider is one embedding server of a Chain.
+type Provider struct {
+	// Name identifies the provider in payloads and reports, e.g.
+	// ollama@localhost:11434; see ProviderName.
+	Name string
+	Embedder
+}
+
+// ProviderName names the provider of the given kind at baseURL by its kind
+// and host, leaving out any path, credentials and query.
+func ProviderName(kind, baseURL string) string {
+	if u, err := url.Parse(baseURL); err == nil && u.Host != "" {
+		return kind + "@" + u.Host
+	}
+	return kind + "@" + baseURL
+}
+
+// ParseProviders reads a comma-separated list of KIND=URL, e.g.
+// openai=https://api.example.com, into Providers. apiKey is used by the
+// OpenAI ones.
+func ParseProviders(spec, apiKey string) ([]Provider, error) {
+	var providers []Provider
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		kind, baseURL, ok := strings.Cut(item, "=")
+		if !ok || baseURL == "" {
+			return nil, fmt.Errorf("invalid embedder %q: want KIND=URL, e.g. %s=http://backup:11434", item, Ollama)
+		}
+		e, err := New(kind, baseURL, apiKey)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, Provider{Name: ProviderName(kind, baseURL), Embedder: e})
+	}
+	return providers, nil
+}
+
+// ProviderStatus is how one provider of a Chain stands.
+type ProviderStatus struct {
+	Name      string `json:"name"`
+	Primary   bool   `json:"primary"`
+	Healthy   bool   `json:"healthy"`
+	Failovers int64  `json:"failovers"`            // times embeds moved off it
+	DownSince string `json:"down_since,omitempty"` // RFC 3339, while unhealthy
+	LastError string `json:"last_error,omitempty"`
+}
+
+// Chain is an Embedder over providers in order of preference, say a local
+// Ollama and then a remote backup: each embed goes to the first provider
+// that is up, and one that fails moves on to the next. The provider it
+// left is skipped for the recheck time, then tried again. Every provider
+// must serve the model asked for, so that their vectors are alike.
+type Chain struct {
+	providers []*link
+	recheck   time.Duration
+}
+
+// link is a Provider and how it has been doing.
+type link struct {
+	Provider
+
+	mu        sync.Mutex
+	downSince time.Time
+	failed    time.Time
+	failovers int64
+	lastError string
+}
+
+// NewChain returns a Chain over providers, the first the primary.
+func NewChain(providers []Provider, recheck time.Duration) (*Chain, error) {
+	if len(providers) == 0 {
+		return nil, errors.New("no embedders")
+	}
+	c := &Chain{recheck: recheck}
+	for _, p := range providers {
+		c.providers = append(c.providers, &link{Provider: p})
+	}
+	return c, nil
+}
+
+// usable reports whether the provider is up, or has been down long enough
+// to try again.
+func (l *link) usable(recheck time.Duration) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.downSince.IsZero() || time.Since(l.failed) >= recheck
+}
+
+// fail marks the provider down after err.
+func (l *link) fail(err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if l.downSince.IsZero() {
+		l.downSince = now
+	}
+	l.failed = now
+	l.failovers++
+	l.lastError = err.Error()
+}
+
+// recover marks the provider up after it served a call.
+func (l *link) recover() {
+	l.mu.Lock()
+	l.downSince = time.Time{}
+	l.mu.Unlock()
+}
+
+// try runs fn on the first usable provider, moving down the chain while
+// they fail, and returns the name of the one that succeeded. When every
+// provider is marked down, all are tried anyway, in order.
+func try[T any](ctx context.Context, c *Chain, fn func(Embedder) (T, error)) (T, string, error) {
+	var zero T
+	var errs []error
+	for _, anyway := range []bool{false, true} {
+		for _, l := range c.providers {
+			if !anyway && !l.usable(c.recheck) {
+				continue
+			}
+			v, err := fn(l.Embedder)
+			if err == nil {
+				l.recover()
+				return v, l.Name, nil
+			}
+			if ctx.Err() != nil {
+				return zero, "", err
+			}
+			l.fail(err)
+			errs = append(errs, fmt.Errorf("%s: %w", l.Name, err))
+		}
+		if len(errs) > 0 {
+			break
+		}
+	}
+	return zero, "", errors.Join(errs...)
+}
+
+func (c *Chain) Embed(ctx context.Context, model, text string) ([]float32, error) {
+	v, _, err := c.EmbedSource(ctx, model, text)
+	return v, err
+}
+
+func (c *Chain) EmbedBatch(ctx context.Context, model string, texts []string) ([][]float32, error) {
+	v, _, err := c.EmbedBatchSource(ctx, model, texts)
+	return v, err
+}
+
+// EmbedSource is Embed, also returning the name of the provider that
+// embedded the text.
+func (c *Chain) EmbedSource(ctx context.Context, model, text string) ([]float32, string, error) {
+	return try(ctx, c, func(e Embedder) ([]float32, error) { return e.Embed(ctx, model, text) })
+}
+
+// EmbedBatchSource is EmbedBatch, also returning the name of the provider
+// that embedded the texts; a batch goes to one provider whole.
+func (c *Chain) EmbedBatchSource(ctx context.Context, model string, texts []string) ([][]float32, string, error) {
+	return try(ctx, c, func(e Embedder) ([][]float32, error) { return e.EmbedBatch(ctx, model, texts) })
+}
+
+// Health succeeds when any provider is reachable.
+func (c *Chain) Health(ctx context.Context) error {
+	_, _, err := try(ctx, c, func(e Embedder) (struct{}, error) { return struct{}{}, e.Health(ctx) })
+	return err
+}
+
+// Check checks the health of every provider now and reports them, the
+// primary first.
+func (c *Chain) Check(ctx context.Context) []ProviderStatus {
+	out := make([]ProviderStatus, len(c.providers))
+	for i, l := range c.providers {
+		if err := l.Health(ctx); err != nil {
+			l.fail(err)
+		} else {
+			l.recover()
+		}
+		l.mu.Lock()
+		out[i] = ProviderStatus{
+			Name:      l.Name,
+			Primary:   i == 0,
+			Healthy:   l.downSince.IsZero(),
+			Failovers: l.failovers,
+		}
+		if !l.downSince.IsZero() {
+			out[i].DownSince = l.downSince.UTC().Format(time.RFC3339)
+			out[i].LastError = l.lastError
+		}
+		l.mu.Unlock()
+	}
+	return out
+}
+
+// CountTokens has every provider count the tokens it embeds on n.
+func (c *Chain) CountTokens(n *atomic.Int64) {
+	for _, l := range c.providers {
+		if t, ok := l.Embedder.(tokenCounter); ok {
+			t.CountTokens(n)
+		}
+	}
+}
+
+// sourced is an Embedder that reports which provider embedded a text, as
+// a Chain does.
+type sourced interface {
+	EmbedSource(ctx context.Context, model, text string) ([]float32, string, error)
+	EmbedBatchSource(ctx context.Context, model string, texts []string) ([][]float32, string, error)
+}
+
+// EmbedSource embeds text with e and returns the name of the provider that
+// did when e is a Chain, or "" otherwise.
+func EmbedSource(ctx context.Context, e Embedder, model, text string) ([]float32, string, error) {
+	if s, ok := e.(sourced); ok {
+		return s.EmbedSource(ctx, model, text)
+	}
+	v, err := e.Embed(ctx, model, text)
+	return v, "", err
+}
+
+// EmbedBatchSource is EmbedSource for a batch of texts.
+func EmbedBatchSource(ctx context.Context, e Embedder, model string, texts []string) ([][]float32, string, error) {
+	if s, ok := e.(sourced); ok {
+		return s.EmbedBatchSource(ctx, model, texts)
+	}
+	v, err := e.EmbedBatch(ctx, model, texts)
+	return v, "", err
+}
@@ -0,0 +1,41 @@
+// Package embedder turns text into vectors. Ollama's client is one
+// Embedder; OpenAI is another, speaking the /v1/embeddings API shared by
+// OpenAI, LM Studio, vLLM and most hosted providers.
+package embedder
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hsk-coder/clawbrain/internal/ollama"
+)
+
+// Embedder embeds text with a named model.
+type Embedder interface {
+	// Embed returns the vector of one text.
+	Embed(ctx context.Context, model, text string) ([]float32, error)
+	// EmbedBatch embeds several texts in one request, returning the
+	// vectors in the order of texts.
+	EmbedBatch(ctx context.Context, model string, texts []string) ([][]float32, error)
+	// Health checks whether the backend is reachable.
+	Health(ctx context.Context) error
+}
+
+// Embedder kinds accepted by New.
+const (
+	Ollama = "ollama"
+	OpenAI = "openai"
+)
+
+// New returns the Embedder of the given kind. baseURL is the Ollama URL for
+// Ollama, and the OpenAI-compatible server for OpenAI (DefaultOpenAIURL if
+// empty); apiKey is only used by OpenAI.
+func New(kind, baseURL, apiKey string) (Embedder, error) {
+	switch kind {
+	case Ollama:
+		return ollama.New(baseURL), nil
+	case OpenAI:
+		return NewOpenAI(baseURL, apiKey), nil
+	}
+	return nil, fmt.Errorf("unknown embedder %q (want %s or %s)", kind, Ollama, OpenAI)
+}
@@ -0,0 +1,178 @@
+package embedder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/hsk-coder/clawbrain/internal/reqid"
+)
+
+// DefaultOpenAIURL is the server an OpenAI embedder talks to when none is
+// given.
+const DefaultOpenAIURL = "https://api.openai.com"
+
+// OpenAIClient talks to an OpenAI-compatible embeddings API over HTTP.
+type OpenAIClient struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+	tokens     *atomic.Int64 // see CountTokens
+}
+
+// NewOpenAI creates a client for the server at baseURL, with or without the
+// trailing /v1 (LM Studio and vLLM document it both ways). apiKey is sent as
+// a bearer token; local servers usually need none.
+func NewOpenAI(baseURL, apiKey string) *OpenAIClient {
+	if baseURL == "" {
+		baseURL = DefaultOpenAIURL
+	}
+	baseURL = strings.TrimSuffix(strings.TrimSuffix(baseURL, "/"), "/v1")
+	return &OpenAIClient{
+		baseURL:    baseURL,
+		apiKey:     apiKey,
+		httpClient: &http.Client{Transport: &reqid.Transport{}},
+	}
+}
+
+// embeddingsRequest is the JSON body for POST /v1/embeddings.
+type embeddingsRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+// embeddingsResponse is the JSON response from POST /v1/embeddings.
+type embeddingsResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float64 `json:"embedding"`
+	} `json:"data"`
+	Usage struct {
+		PromptTokens int64 `json:"prompt_tokens"`
+	} `json:"usage"`
+}
+
+// Embed generates an embedding vector for text using the specified model.
+func (c *OpenAIClient) Embed(ctx context.Context, model, text string) ([]float32, error) {
+	vecs, err := c.EmbedBatch(ctx, model, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return vecs[0], nil
+}
+
+// EmbedBatch embeds several texts in a single request. The vectors are in
+// the order of texts.
+func (c *OpenAIClient) EmbedBatch(ctx context.Context, model string, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	body, err := json.Marshal(embeddingsRequest{Model: model, Input: texts})
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	resp, err := c.do(ctx, http.MethodPost, "/v1/embeddings", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result embeddingsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	if len(result.Data) != len(texts) {
+		return nil, fmt.Errorf("embedder returned %d embeddings for %d inputs", len(result.Data), len(texts))
+	}
+
+	// The API may answer out of order; index says which input each is for.
+	vecs := make([][]float32, len(texts))
+	for _, d := range result.Data {
+		if d.Index < 0 || d.Index >= len(texts) || vecs[d.Index] != nil {
+			return nil, fmt.Errorf("embedder returned an embedding for unknown input %d", d.Index)
+		}
+		if len(d.Embedding) == 0 {
+			return nil, fmt.Errorf("embedder returned an empty embedding")
+		}
+		vec := make([]float32, len(d.Embedding))
+		for j, v := range d.Embedding {
+			vec[j] = float32(v)
+		}
+		vecs[d.Index] = vec
+	}
+	if c.tokens != nil {
+		c.tokens.Add(result.Usage.PromptTokens)
+	}
+	return vecs, nil
+}
+
+// CountTokens makes the client add the tokens the server reports embedding
+// to n, for usage accounting.
+func (c *OpenAIClient) CountTokens(n *atomic.Int64) {
+	c.tokens = n
+}
+
+// Health checks whether the server is reachable and accepts the API key, by
+// listing its models.
+func (c *OpenAIClient) Health(ctx context.Context) error {
+	resp, err := c.do(ctx, http.MethodGet, "/v1/models", nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// do sends a request to path and returns the response if it answered 200.
+func (c *OpenAIClient) do(ctx context.Context, method, path string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("embedder request failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, statusError(resp)
+	}
+	return resp, nil
+}
+
+// StatusError is a non-200 answer from an OpenAI-compatible server.
+// RetryAfter holds the Retry-After header of a 429 or 503, if it sent one.
+type StatusError struct {
+	Code       int
+	Body       string
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("embedder returned %d: %s", e.Code, e.Body)
+}
+
+// statusError reads a failed response into a *StatusError.
+func statusError(resp *http.Response) *StatusError {
+	body, _ := io.ReadAll(resp.Body)
+	e := &StatusError{Code: resp.StatusCode, Body: string(body)}
+	if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs > 0 {
+		e.RetryAfter = time.Duration(secs) * time.Second
+	}
+	return e
+}
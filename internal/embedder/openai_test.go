@@ -0,0 +1,95 @@
+package embedder
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestOpenAIEmbedBatch(t *testing.T) {
+	var got embeddingsRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/embeddings" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "Bearer sk-test" {
+			t.Errorf("unexpected Authorization %q", auth)
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		// Answer out of order: index decides which input a vector is for.
+		w.Write([]byte(`{"data":[{"index":1,"embedding":[0.5,0.25]},{"index":0,"embedding":[1,0]}]}`))
+	}))
+	defer srv.Close()
+
+	// The /v1 suffix is optional.
+	c := NewOpenAI(srv.URL+"/v1/", "sk-test")
+	vecs, err := c.EmbedBatch(context.Background(), "text-embedding-3-small", []string{"a", "b"})
+	if err != nil {
+		t.Fatalf("EmbedBatch failed: %v", err)
+	}
+	if want := [][]float32{{1, 0}, {0.5, 0.25}}; !reflect.DeepEqual(vecs, want) {
+		t.Errorf("got %v, want %v", vecs, want)
+	}
+	if got.Model != "text-embedding-3-small" || !reflect.DeepEqual(got.Input, []string{"a", "b"}) {
+		t.Errorf("unexpected request %+v", got)
+	}
+}
+
+func TestOpenAIEmbedErrors(t *testing.T) {
+	tests := map[string]http.HandlerFunc{
+		"wrong count": func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"data":[]}`))
+		},
+		"unknown index": func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"data":[{"index":3,"embedding":[1]}]}`))
+		},
+		"empty embedding": func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"data":[{"index":0,"embedding":[]}]}`))
+		},
+	}
+	for name, h := range tests {
+		srv := httptest.NewServer(h)
+		if _, err := NewOpenAI(srv.URL, "").Embed(context.Background(), "m", "text"); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+		srv.Close()
+	}
+}
+
+func TestOpenAIStatusError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "7")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"error":{"message":"rate limited"}}`))
+	}))
+	defer srv.Close()
+
+	_, err := NewOpenAI(srv.URL, "").Embed(context.Background(), "m", "text")
+	var se *StatusError
+	if !errors.As(err, &se) {
+		t.Fatalf("expected a *StatusError, got %v", err)
+	}
+	if se.Code != http.StatusTooManyRequests || se.RetryAfter != 7*time.Second {
+		t.Errorf("unexpected error %+v", se)
+	}
+	if err := NewOpenAI(srv.URL, "").Health(context.Background()); !errors.As(err, &se) {
+		t.Errorf("expected Health to fail with a *StatusError, got %v", err)
+	}
+}
+
+func TestNew(t *testing.T) {
+	if e, err := New(Ollama, "http://localhost:11434", ""); err != nil || e == nil {
+		t.Errorf("New(ollama): %v", err)
+	}
+	if e, err := New(OpenAI, "", "key"); err != nil || e.(*OpenAIClient).baseURL != DefaultOpenAIURL {
+		t.Errorf("New(openai): %v", err)
+	}
+	if _, err := New("cohere", "", ""); err == nil {
+		t.Error("expected an unknown embedder to be rejected")
+	}
+}
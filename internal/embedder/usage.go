@@ -0,0 +1,73 @@
+package embedder
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// Usage adds up what the Embedders metered on it spent: requests, the time
+// they took, and the texts and tokens they embedded. One Usage can meter
+// several Embedders.
+type Usage struct {
+	calls, texts, tokens, nanos atomic.Int64
+}
+
+// Calls returns how many embedding requests finished.
+func (u *Usage) Calls() int64 { return u.calls.Load() }
+
+// Texts returns how many texts were embedded.
+func (u *Usage) Texts() int64 { return u.texts.Load() }
+
+// Tokens returns how many tokens the servers reported embedding.
+func (u *Usage) Tokens() int64 { return u.tokens.Load() }
+
+// Elapsed returns the total time spent in finished requests.
+func (u *Usage) Elapsed() time.Duration { return time.Duration(u.nanos.Load()) }
+
+// tokenCounter is an Embedder that reports the tokens its server counted,
+// as the Ollama and OpenAI clients do.
+type tokenCounter interface {
+	CountTokens(n *atomic.Int64)
+}
+
+// Meter returns e counting what it embeds on u. Tokens are counted when e
+// reports them; otherwise only requests, texts and time are.
+func Meter(e Embedder, u *Usage) Embedder {
+	if c, ok := e.(tokenCounter); ok {
+		c.CountTokens(&u.tokens)
+	}
+	return &metered{Embedder: e, usage: u}
+}
+
+// metered is an Embedder whose embeddings count on a Usage.
+type metered struct {
+	Embedder
+	usage *Usage
+}
+
+func (m *metered) since(start time.Time, texts int) {
+	m.usage.calls.Add(1)
+	m.usage.texts.Add(int64(texts))
+	m.usage.nanos.Add(int64(time.Since(start)))
+}
+
+func (m *metered) Embed(ctx context.Context, model, text string) ([]float32, error) {
+	defer m.since(time.Now(), 1)
+	return m.Embedder.Embed(ctx, model, text)
+}
+
+func (m *metered) EmbedBatch(ctx context.Context, model string, texts []string) ([][]float32, error) {
+	defer m.since(time.Now(), len(texts))
+	return m.Embedder.EmbedBatch(ctx, model, texts)
+}
+
+func (m *metered) EmbedSource(ctx context.Context, model, text string) ([]float32, string, error) {
+	defer m.since(time.Now(), 1)
+	return EmbedSource(ctx, m.Embedder, model, text)
+}
+
+func (m *metered) EmbedBatchSource(ctx context.Context, model string, texts []string) ([][]float32, string, error) {
+	defer m.since(time.Now(), len(texts))
+	return EmbedBatchSource(ctx, m.Embedder, model, texts)
+}
@@ -0,0 +1,27 @@
+package embedder
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMeter(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":[{"index":0,"embedding":[1,0]},{"index":1,"embedding":[0,1]}],"usage":{"prompt_tokens":7}}`))
+	}))
+	defer srv.Close()
+
+	var usage Usage
+	e := Meter(NewOpenAI(srv.URL, ""), &usage)
+	if _, err := e.EmbedBatch(context.Background(), "m", []string{"a", "b"}); err != nil {
+		t.Fatalf("EmbedBatch failed: %v", err)
+	}
+	if usage.Calls() != 1 || usage.Texts() != 2 || usage.Tokens() != 7 {
+		t.Errorf("expected 1 call, 2 texts and 7 tokens; got %d, %d and %d", usage.Calls(), usage.Texts(), usage.Tokens())
+	}
+	if usage.Elapsed() <= 0 {
+		t.Errorf("expected time spent, got %v", usage.Elapsed())
+	}
+}
@@ -0,0 +1,76 @@
+// Package expansion rewrites a search query into a few paraphrases with a
+// generative model. A query's embedding sits near memories phrased like
+// the question; one that says the same thing in other words ("the deploy
+// box" for "the staging server") can fall out of the top matches. Searching
+// for every paraphrase and fusing the rankings finds it.
+package expansion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultQueries is how many paraphrases an expanded search asks for.
+const DefaultQueries = 3
+
+// maxQueryLength caps, in characters, a paraphrase taken from a reply; a
+// longer line is the model answering rather than rephrasing.
+const maxQueryLength = 300
+
+// Generator completes a prompt with a model, as *ollama.Client does.
+type Generator interface {
+	Generate(ctx context.Context, model, prompt string) (string, error)
+}
+
+// Prompt builds the prompt asking a model for n paraphrases of query, one
+// a line.
+func Prompt(query string, n int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Rewrite the search query below in %d different ways that mean the same but use other words, ", n)
+	b.WriteString("as someone might have written a note answering it. Keep names and numbers as they are. ")
+	b.WriteString("Reply with one rewrite a line and nothing else.\n\n")
+	fmt.Fprintf(&b, "Query: %s\n", strings.TrimSpace(query))
+	return b.String()
+}
+
+// listMarker matches the numbering or bullet a model puts before a line.
+var listMarker = regexp.MustCompile(`^\s*(?:[-*•]|\d+[.)]|\(\d+\))\s*`)
+
+// Parse reads up to n paraphrases out of a model's reply: its lines,
+// without list markers or quotes. Blank lines, a preamble ending in a
+// colon, overlong lines, repeats and the query itself are skipped.
+func Parse(reply, query string, n int) []string {
+	var queries []string
+	seen := map[string]bool{strings.ToLower(strings.TrimSpace(query)): true}
+	for _, line := range strings.Split(reply, "\n") {
+		line = listMarker.ReplaceAllString(line, "")
+		line = strings.Trim(strings.TrimSpace(line), `"'“”`)
+		key := strings.ToLower(line)
+		if line == "" || strings.HasSuffix(line, ":") || len([]rune(line)) > maxQueryLength || seen[key] {
+			continue
+		}
+		seen[key] = true
+		queries = append(queries, line)
+		if len(queries) == n {
+			break
+		}
+	}
+	return queries
+}
+
+// Expand asks model for n paraphrases of query. A reply without any is an
+// error, as is a failed call.
+func Expand(ctx context.Context, gen Generator, model, query string, n int) ([]string, error) {
+	reply, err := gen.Generate(ctx, model, Prompt(query, n))
+	if err != nil {
+		return nil, fmt.Errorf("expand query: %w", err)
+	}
+	queries := Parse(reply, query, n)
+	if len(queries) == 0 {
+		return nil, errors.New("expand query: the model's reply held no paraphrases")
+	}
+	return queries, nil
+}
@@ -0,0 +1,52 @@
+package expansion
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	reply := "Here are three rewrites:\n" +
+		"1. Where does the deploy box live?\n" +
+		"2) \"Which region hosts staging?\"\n" +
+		"\n" +
+		"- where is staging?\n" +
+		"* Which region hosts staging?\n" +
+		"(3) staging server location\n" +
+		"4. one too many\n"
+	want := []string{"Where does the deploy box live?", "Which region hosts staging?", "staging server location"}
+	if got := Parse(reply, "Where is staging?", 3); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := Parse(strings.Repeat("x", maxQueryLength+1), "q", 3); got != nil {
+		t.Errorf("expected an overlong line skipped, got %q", got)
+	}
+}
+
+type fixedReply struct {
+	reply string
+	err   error
+}
+
+func (f fixedReply) Generate(context.Context, string, string) (string, error) {
+	return f.reply, f.err
+}
+
+func TestExpand(t *testing.T) {
+	got, err := Expand(context.Background(), fixedReply{reply: "deploy box location\nstaging region"}, "m", "where is staging?", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, []string{"deploy box location", "staging region"}) {
+		t.Errorf("got %q", got)
+	}
+	if _, err := Expand(context.Background(), fixedReply{reply: "where is staging?\n"}, "m", "where is staging?", 3); err == nil {
+		t.Error("expected an error for a reply with no paraphrases")
+	}
+	if _, err := Expand(context.Background(), fixedReply{err: errors.New("model not found")}, "m", "q", 3); err == nil {
+		t.Error("expected the model's error")
+	}
+}
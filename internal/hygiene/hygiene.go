@@ -0,0 +1,239 @@
+// Package hygiene scores how healthy each stored memory is and recommends
+// what to do about the unhealthy ones. Where forget applies one blunt rule
+// (not accessed within a TTL), a health score weighs several signals, so a
+// cleanup pass can start with the memories most likely to be wrong or dead
+// weight rather than merely the oldest.
+package hygiene
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hsk-coder/clawbrain/internal/retention"
+	"github.com/hsk-coder/clawbrain/internal/store"
+	"github.com/hsk-coder/clawbrain/internal/sync"
+)
+
+// Recommended actions.
+const (
+	ActionKeep    = "keep"    // healthy, nothing to do
+	ActionDelete  = "delete"  // superseded or dead weight
+	ActionRefresh = "refresh" // the source file changed; re-sync it
+	ActionConfirm = "confirm" // possibly wrong; check it and re-add or delete
+)
+
+// Source states for memories synced from a file.
+const (
+	SourceNone    = ""        // not synced from a file
+	SourceLive    = "live"    // the file still contains the memory's text
+	SourceChanged = "changed" // the file exists but no longer contains it
+	SourceMissing = "missing" // the file is gone
+)
+
+// Weights of each signal in the health score. They sum to 1.
+const (
+	weightAccess     = 0.40
+	weightAge        = 0.15
+	weightConfidence = 0.25
+	weightSource     = 0.20
+)
+
+// Half-lives after which the access and age signals are worth half.
+const (
+	accessHalfLife = 30 * retention.Day
+	ageHalfLife    = 365 * retention.Day
+)
+
+// Thresholds below which a signal triggers an action.
+const (
+	lowConfidence = 0.5
+	staleAccess   = 0.25 // two access half-lives: 60 days untouched
+)
+
+// Components are the individual signals, each from 0 (bad) to 1 (good).
+type Components struct {
+	Access     float64 `json:"access"`
+	Age        float64 `json:"age"`
+	Confidence float64 `json:"confidence"`
+	Source     float64 `json:"source"`
+}
+
+// Assessment is the health of one memory.
+type Assessment struct {
+	ID          string     `json:"id"`
+	Score       float64    `json:"score"`
+	Action      string     `json:"action"`
+	Reasons     []string   `json:"reasons,omitempty"`
+	Components  Components `json:"components"`
+	Source      string     `json:"source,omitempty"`
+	SourceState string     `json:"source_state,omitempty"`
+	Text        string     `json:"text"`
+}
+
+// Assess scores a memory. Superseded memories score 0. A pinned or locked
+// memory is never recommended for deletion; it gets confirm instead.
+func Assess(m store.Result, sources *Sources, now time.Time) Assessment {
+	p := m.Payload
+	a := Assessment{ID: m.ID, Action: ActionKeep}
+	a.Text, _ = p["text"].(string)
+
+	// Like forget, treat a memory without a last_accessed as live.
+	var idle time.Duration
+	if t, ok := retention.LastAccessed(p); ok {
+		idle = now.Sub(t)
+	}
+	a.Components.Access = decay(idle, accessHalfLife)
+	a.Components.Age = 1
+	if created, ok := retention.CreatedAt(p); ok {
+		a.Components.Age = decay(now.Sub(created), ageHalfLife)
+	}
+	a.Components.Confidence = Confidence(p)
+
+	// A compress summary keeps its group's source but paraphrases it, so
+	// its text is never found verbatim in the file.
+	a.Source, _ = p["source"].(string)
+	a.Components.Source = 1
+	if compressed, _ := p["compressed"].(bool); a.Source != "" && sources != nil && !compressed {
+		a.SourceState = sources.State(a.Source, a.Text)
+		switch a.SourceState {
+		case SourceChanged:
+			a.Components.Source = 0.3
+		case SourceMissing:
+			a.Components.Source = 0.2
+		}
+	}
+
+	c := a.Components
+	a.Score = round(weightAccess*c.Access + weightAge*c.Age + weightConfidence*c.Confidence + weightSource*c.Source)
+
+	// Reasons in priority order: the first one decides the action.
+	type finding struct{ action, reason string }
+	var found []finding
+	if by := supersededBy(p); by != "" {
+		a.Score = 0
+		found = append(found, finding{ActionDelete, "superseded by " + by})
+	}
+	switch a.SourceState {
+	case SourceChanged:
+		found = append(found, finding{ActionRefresh, "source file changed since it was synced"})
+	case SourceMissing:
+		found = append(found, finding{ActionConfirm, "source file no longer exists"})
+	}
+	if c.Confidence < lowConfidence {
+		found = append(found, finding{ActionConfirm, fmt.Sprintf("low confidence (%.2f)", c.Confidence)})
+	}
+	if c.Access < staleAccess {
+		found = append(found, finding{ActionDelete, fmt.Sprintf("not accessed in %.0f days", idle.Hours()/24)})
+	}
+
+	for _, f := range found {
+		a.Reasons = append(a.Reasons, f.reason)
+	}
+	if len(found) > 0 {
+		a.Action = found[0].action
+	}
+	if a.Action == ActionDelete && (retention.IsPinned(p) || store.IsLocked(p)) {
+		a.Action = ActionConfirm
+		a.Reasons = append(a.Reasons, "pinned or locked, so not deleted outright")
+	}
+	return a
+}
+
+// Confidence reads a memory's confidence from its payload: a number from 0
+// to 1, or a label (high, medium, low). Memories without one are assumed
+// sound and score 1.
+func Confidence(payload map[string]any) float64 {
+	switch v := payload["confidence"].(type) {
+	case float64:
+		return math.Max(0, math.Min(1, v))
+	case string:
+		switch strings.ToLower(v) {
+		case "high":
+			return 1
+		case "medium":
+			return 0.6
+		case "low":
+			return 0.3
+		}
+	}
+	return 1
+}
+
+// supersededBy returns what superseded a memory, or "" if nothing did.
+func supersededBy(payload map[string]any) string {
+	switch v := payload["superseded_by"].(type) {
+	case string:
+		return v
+	case bool:
+		if v {
+			return "a newer memory"
+		}
+	}
+	return ""
+}
+
+// Worst returns the assessments that need action, lowest score first, then
+// by ID for a stable order.
+func Worst(all []Assessment) []Assessment {
+	var out []Assessment
+	for _, a := range all {
+		if a.Action != ActionKeep {
+			out = append(out, a)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Score != out[j].Score {
+			return out[i].Score < out[j].Score
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
+// Sources checks synced memories against the files they came from. Each
+// file is read once.
+type Sources struct {
+	files map[string]*string // normalized contents; nil if unreadable
+}
+
+// NewSources returns an empty source checker.
+func NewSources() *Sources {
+	return &Sources{files: map[string]*string{}}
+}
+
+// State reports whether the file at path still contains text. Both sides
+// are normalized the way sync normalizes chunks.
+func (s *Sources) State(path, text string) string {
+	content, ok := s.files[path]
+	if !ok {
+		if data, err := os.ReadFile(path); err == nil {
+			normalized := sync.NormalizeText(string(data))
+			content = &normalized
+		}
+		s.files[path] = content
+	}
+	switch {
+	case content == nil:
+		return SourceMissing
+	case strings.Contains(*content, sync.NormalizeText(text)):
+		return SourceLive
+	default:
+		return SourceChanged
+	}
+}
+
+// decay halves a signal every halfLife.
+func decay(elapsed, halfLife time.Duration) float64 {
+	if elapsed <= 0 {
+		return 1
+	}
+	return math.Pow(0.5, float64(elapsed)/float64(halfLife))
+}
+
+func round(v float64) float64 {
+	return math.Round(v*1000) / 1000
+}
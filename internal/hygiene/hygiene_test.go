@@ -0,0 +1,105 @@
+package hygiene
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hsk-coder/clawbrain/internal/retention"
+	"github.com/hsk-coder/clawbrain/internal/store"
+)
+
+var now = time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+
+// memory builds a memory last accessed idle ago and created age ago.
+func memory(id string, idle, age time.Duration, extra map[string]any) store.Result {
+	payload := map[string]any{
+		"text":          "memory " + id,
+		"last_accessed": now.Add(-idle).Format(time.RFC3339Nano),
+		"created_at":    now.Add(-age).Format(time.RFC3339Nano),
+	}
+	for k, v := range extra {
+		payload[k] = v
+	}
+	return store.Result{ID: id, Payload: payload}
+}
+
+func TestAssessHealthy(t *testing.T) {
+	a := Assess(memory("a", 0, 0, nil), NewSources(), now)
+	if a.Action != ActionKeep || a.Score != 1 || len(a.Reasons) != 0 {
+		t.Errorf("expected a fresh memory to be healthy, got %+v", a)
+	}
+}
+
+func TestAssessActions(t *testing.T) {
+	dir := t.TempDir()
+	notes := filepath.Join(dir, "notes.md")
+	if err := os.WriteFile(notes, []byte("# Notes\n\nThe  deploy runs on Tuesdays.\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		m      store.Result
+		action string
+	}{
+		{"superseded", memory("s", 0, 0, map[string]any{"superseded_by": "abc"}), ActionDelete},
+		{"stale", memory("st", 90*retention.Day, 90*retention.Day, nil), ActionDelete},
+		{"stale but pinned", memory("p", 90*retention.Day, 90*retention.Day, map[string]any{"pinned": true}), ActionConfirm},
+		{"low confidence", memory("c", 0, 0, map[string]any{"confidence": "low"}), ActionConfirm},
+		{"confident", memory("h", 0, 0, map[string]any{"confidence": 0.9}), ActionKeep},
+		{"source live", memory("l", 0, 0, map[string]any{"source": notes, "text": "The deploy runs on Tuesdays."}), ActionKeep},
+		{"source changed", memory("ch", 0, 0, map[string]any{"source": notes, "text": "The deploy runs on Fridays."}), ActionRefresh},
+		{"source missing", memory("m", 0, 0, map[string]any{"source": filepath.Join(dir, "gone.md")}), ActionConfirm},
+		{"compressed summary", memory("z", 0, 0, map[string]any{"source": notes, "compressed": true}), ActionKeep},
+	}
+	sources := NewSources()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := Assess(tt.m, sources, now)
+			if a.Action != tt.action {
+				t.Errorf("action = %q, want %q (reasons %v)", a.Action, tt.action, a.Reasons)
+			}
+			if a.Action != ActionKeep && len(a.Reasons) == 0 {
+				t.Error("expected a reason for the action")
+			}
+		})
+	}
+}
+
+func TestAssessSupersededScoresZero(t *testing.T) {
+	a := Assess(memory("s", 0, 0, map[string]any{"superseded_by": true}), nil, now)
+	if a.Score != 0 || a.Reasons[0] != "superseded by a newer memory" {
+		t.Errorf("unexpected assessment: %+v", a)
+	}
+}
+
+func TestConfidence(t *testing.T) {
+	tests := map[any]float64{"high": 1, "Medium": 0.6, "low": 0.3, 0.4: 0.4, 7.0: 1, -1.0: 0, "unsure": 1}
+	for in, want := range tests {
+		if got := Confidence(map[string]any{"confidence": in}); got != want {
+			t.Errorf("Confidence(%v) = %v, want %v", in, got, want)
+		}
+	}
+	if got := Confidence(map[string]any{}); got != 1 {
+		t.Errorf("missing confidence = %v, want 1", got)
+	}
+}
+
+func TestWorst(t *testing.T) {
+	all := []Assessment{
+		{ID: "b", Score: 0.4, Action: ActionConfirm},
+		{ID: "k", Score: 0.1, Action: ActionKeep},
+		{ID: "a", Score: 0.4, Action: ActionDelete},
+		{ID: "c", Score: 0.2, Action: ActionRefresh},
+	}
+	var ids []string
+	for _, a := range Worst(all) {
+		ids = append(ids, a.ID)
+	}
+	if !reflect.DeepEqual(ids, []string{"c", "a", "b"}) {
+		t.Errorf("Worst order = %v", ids)
+	}
+}
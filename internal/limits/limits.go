@@ -0,0 +1,226 @@
+// Package limits caps the size of the memories a read hands back, so one
+// pathological memory -- a pasted log, a whole file synced as one chunk --
+// can't fill an agent's context window or overrun the message size of the
+// transport carrying it. What is cut is marked, never silently dropped.
+package limits
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"unicode/utf8"
+)
+
+// Limits caps what a read returns. The zero Limits caps nothing.
+type Limits struct {
+	// MaxTextLength caps the text of each returned memory, in characters.
+	MaxTextLength int `json:"max_text_length,omitempty"`
+	// MaxResultBytes caps each returned memory, as JSON.
+	MaxResultBytes int `json:"max_result_bytes,omitempty"`
+	// MaxResponseBytes caps the whole response, as JSON.
+	MaxResponseBytes int `json:"max_response_bytes,omitempty"`
+}
+
+// Truncation markers.
+const (
+	// Ellipsis ends every string that was cut short.
+	Ellipsis = "…"
+	// TruncatedField lists, on a memory, the paths of the fields that were
+	// cut short or removed, e.g. ["payload.text"].
+	TruncatedField = "truncated"
+	// OmittedField counts, on a response, the memories left out of it.
+	OmittedField = "omitted"
+)
+
+// minKept is how many characters of a string the per-memory cap keeps
+// before it gives up shortening strings and removes whole fields.
+const minKept = 64
+
+// Validate checks that no limit is negative.
+func (l Limits) Validate() error {
+	for name, v := range map[string]int{
+		"max_text_length":    l.MaxTextLength,
+		"max_result_bytes":   l.MaxResultBytes,
+		"max_response_bytes": l.MaxResponseBytes,
+	} {
+		if v < 0 {
+			return fmt.Errorf("%s must be non-negative, got %d", name, v)
+		}
+	}
+	return nil
+}
+
+// IsZero reports whether l caps nothing.
+func (l Limits) IsZero() bool {
+	return l == Limits{}
+}
+
+// Apply returns response with the limits enforced. fields name the entries
+// of response holding memories, each a list of them or a single one; with
+// none, response is itself a memory. A memory is an object whose text is
+// in "payload.text" or "text".
+//
+// Each memory's text is cut to MaxTextLength, then the memory to
+// MaxResultBytes by shortening its longest strings and, if that isn't
+// enough, dropping its largest payload fields; what was cut is listed in
+// its TruncatedField. Then memories are dropped from the end of the
+// longest list until the response fits in MaxResponseBytes, counted in
+// OmittedField, and a "returned" count is lowered to match.
+func (l Limits) Apply(response map[string]any, fields ...string) (map[string]any, error) {
+	if l.IsZero() {
+		return response, nil
+	}
+	out, err := generic(response)
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		l.limitMemory(out)
+	}
+	for _, field := range fields {
+		switch v := out[field].(type) {
+		case []any:
+			for _, item := range v {
+				if m, ok := item.(map[string]any); ok {
+					l.limitMemory(m)
+				}
+			}
+		case map[string]any:
+			l.limitMemory(v)
+		}
+	}
+	if l.MaxResponseBytes > 0 {
+		l.fitResponse(out, fields)
+	}
+	return out, nil
+}
+
+// generic converts v to the maps and slices encoding/json decodes into,
+// so it can be cut without knowing its types. Numbers stay json.Number,
+// which encodes exactly as it was.
+func generic(v map[string]any) (map[string]any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var out map[string]any
+	if err := dec.Decode(&out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// size returns the length of v as JSON.
+func size(v any) int {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return 0
+	}
+	return len(data)
+}
+
+// truncate cuts s to n characters, ending it with Ellipsis.
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:n]) + Ellipsis
+}
+
+// limitMemory applies MaxTextLength and MaxResultBytes to one memory.
+func (l Limits) limitMemory(m map[string]any) {
+	var cut []string
+	mark := func(path string) {
+		for _, p := range cut {
+			if p == path {
+				return
+			}
+		}
+		cut = append(cut, path)
+	}
+	payload, prefix := m, ""
+	if p, ok := m["payload"].(map[string]any); ok {
+		payload, prefix = p, "payload."
+	}
+	if text, ok := payload["text"].(string); ok && l.MaxTextLength > 0 {
+		if short := truncate(text, l.MaxTextLength); short != text {
+			payload["text"] = short
+			mark(prefix + "text")
+		}
+	}
+	if l.MaxResultBytes > 0 {
+		for size(m) > l.MaxResultBytes {
+			// Keys in order, so ties are broken the same way every time.
+			keys := slices.Sorted(maps.Keys(payload))
+			// The longest string goes first: it is most likely the culprit,
+			// and keeping its start keeps the most meaning per byte.
+			key, longest := "", 0
+			for _, k := range keys {
+				if s, ok := payload[k].(string); ok && utf8.RuneCountInString(s) > longest {
+					key, longest = k, utf8.RuneCountInString(s)
+				}
+			}
+			// A string cut to minKept is minKept+1 long with its Ellipsis.
+			if longest > minKept+1 {
+				excess := size(m) - l.MaxResultBytes + len(Ellipsis)
+				s := payload[key].(string)
+				// Cut what is over, and at least half, but keep minKept.
+				keep := max(minKept, min(longest/2, longest-excess))
+				payload[key] = truncate(s, keep)
+				mark(prefix + key)
+				continue
+			}
+			key, largest := "", 0
+			for _, k := range keys {
+				// The text is what the memory is; the ID is how to get
+				// the rest of it.
+				if k == "text" || k == "id" {
+					continue
+				}
+				if n := size(payload[k]); n > largest {
+					key, largest = k, n
+				}
+			}
+			if key == "" {
+				break
+			}
+			delete(payload, key)
+			mark(prefix + key)
+		}
+	}
+	if len(cut) > 0 {
+		sort.Strings(cut)
+		m[TruncatedField] = cut
+	}
+}
+
+// fitResponse drops memories from the end of the longest of fields' lists
+// until response fits in MaxResponseBytes or the lists are empty.
+func (l Limits) fitResponse(response map[string]any, fields []string) {
+	omitted := 0
+	for size(response) > l.MaxResponseBytes {
+		field, longest := "", 0
+		for _, f := range fields {
+			if list, ok := response[f].([]any); ok && len(list) > longest {
+				field, longest = f, len(list)
+			}
+		}
+		if field == "" {
+			break
+		}
+		response[field] = response[field].([]any)[:longest-1]
+		omitted++
+		response[OmittedField] = omitted
+	}
+	if n, ok := response["returned"].(json.Number); ok && omitted > 0 {
+		if returned, err := n.Int64(); err == nil {
+			response["returned"] = returned - int64(omitted)
+		}
+	}
+}
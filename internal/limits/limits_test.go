@@ -0,0 +1,108 @@
+package limits
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func memory(id, text string, extra map[string]any) map[string]any {
+	payload := map[string]any{"text": text}
+	for k, v := range extra {
+		payload[k] = v
+	}
+	return map[string]any{"id": id, "score": 0.9, "payload": payload}
+}
+
+func TestZeroLimitsChangeNothing(t *testing.T) {
+	response := map[string]any{"status": "ok", "results": []any{memory("a", strings.Repeat("x", 10000), nil)}}
+	out, err := Limits{}.Apply(response, "results")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out["results"].([]any)[0].(map[string]any)["payload"].(map[string]any)["text"] != strings.Repeat("x", 10000) {
+		t.Errorf("expected the text untouched, got %v", out)
+	}
+}
+
+func TestMaxTextLength(t *testing.T) {
+	response := map[string]any{"status": "ok", "results": []any{
+		memory("a", "héllo wörld", nil),
+		memory("b", "short", nil),
+	}}
+	out, err := Limits{MaxTextLength: 5}.Apply(response, "results")
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := out["results"].([]any)
+	first := results[0].(map[string]any)
+	if text := first["payload"].(map[string]any)["text"]; text != "héllo"+Ellipsis {
+		t.Errorf("expected the text cut to 5 characters, got %q", text)
+	}
+	if cut := first[TruncatedField]; len(cut.([]string)) != 1 || cut.([]string)[0] != "payload.text" {
+		t.Errorf("expected payload.text marked as truncated, got %v", cut)
+	}
+	second := results[1].(map[string]any)
+	if _, ok := second[TruncatedField]; ok || second["payload"].(map[string]any)["text"] != "short" {
+		t.Errorf("expected a short text left alone, got %v", second)
+	}
+	if _, err := json.Marshal(out); err != nil {
+		t.Errorf("expected the response to still encode: %v", err)
+	}
+}
+
+func TestMaxResultBytes(t *testing.T) {
+	big := memory("a", strings.Repeat("t", 2000), map[string]any{
+		"notes":  strings.Repeat("n", 5000),
+		"matrix": []any{strings.Repeat("1", 60), strings.Repeat("2", 60), strings.Repeat("3", 60)},
+		"type":   "fact",
+	})
+	out, err := Limits{MaxResultBytes: 400}.Apply(big)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := size(out); n > 400 {
+		t.Errorf("expected the memory to fit in 400 bytes, got %d: %v", n, out)
+	}
+	payload := out["payload"].(map[string]any)
+	if !strings.HasPrefix(payload["text"].(string), strings.Repeat("t", minKept)) {
+		t.Errorf("expected the start of the text kept, got %v", payload)
+	}
+	if payload["type"] != "fact" || out["id"] != "a" {
+		t.Errorf("expected small fields and the ID kept, got %v", out)
+	}
+	if cut := strings.Join(out[TruncatedField].([]string), ","); !strings.Contains(cut, "payload.notes") || !strings.Contains(cut, "payload.text") {
+		t.Errorf("expected the cut fields listed, got %q", cut)
+	}
+}
+
+func TestMaxResponseBytes(t *testing.T) {
+	var results []any
+	for _, id := range []string{"a", "b", "c", "d"} {
+		results = append(results, memory(id, strings.Repeat(id, 100), nil))
+	}
+	response := map[string]any{"status": "ok", "returned": 4, "results": results}
+	out, err := Limits{MaxResponseBytes: 400}.Apply(response, "results")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := size(out); n > 400 {
+		t.Errorf("expected the response to fit in 400 bytes, got %d", n)
+	}
+	kept := out["results"].([]any)
+	if len(kept) == 0 || len(kept) == 4 || kept[0].(map[string]any)["id"] != "a" {
+		t.Fatalf("expected the last results dropped, got %v", kept)
+	}
+	if out[OmittedField] != 4-len(kept) || out["returned"] != int64(len(kept)) {
+		t.Errorf("expected omitted and returned to add up, got %v and %v", out[OmittedField], out["returned"])
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := (Limits{MaxResultBytes: -1}).Validate(); err == nil {
+		t.Error("expected a negative limit to be rejected")
+	}
+	if err := (Limits{MaxTextLength: 10}).Validate(); err != nil {
+		t.Errorf("expected a positive limit to pass, got %v", err)
+	}
+}
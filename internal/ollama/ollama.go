@@ -3,44 +3,71 @@ package ollama
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/hsk-coder/clawbrain/internal/reqid"
 )
 
 // Client talks to a running Ollama instance over HTTP.
 type Client struct {
 	baseURL    string
 	httpClient *http.Client
+	tokens     *atomic.Int64 // see CountTokens
 }
 
 // New creates a new Ollama client. baseURL is typically "http://localhost:11434".
 func New(baseURL string) *Client {
 	return &Client{
 		baseURL:    baseURL,
-		httpClient: &http.Client{},
+		httpClient: &http.Client{Transport: &reqid.Transport{}},
 	}
 }
 
-// embedRequest is the JSON body for POST /api/embed.
+// embedRequest is the JSON body for POST /api/embed. Input is a single
+// string or a list of strings.
 type embedRequest struct {
 	Model string `json:"model"`
-	Input string `json:"input"`
+	Input any    `json:"input"`
 }
 
 // embedResponse is the JSON response from POST /api/embed.
 type embedResponse struct {
-	Model      string      `json:"model"`
-	Embeddings [][]float64 `json:"embeddings"`
+	Model           string      `json:"model"`
+	Embeddings      [][]float64 `json:"embeddings"`
+	PromptEvalCount int64       `json:"prompt_eval_count"`
 }
 
 // Embed generates an embedding vector for the given text using the specified model.
 // Returns a float32 slice suitable for Qdrant storage.
 func (c *Client) Embed(ctx context.Context, model string, text string) ([]float32, error) {
+	vecs, err := c.embed(ctx, model, text, 1)
+	if err != nil {
+		return nil, err
+	}
+	return vecs[0], nil
+}
+
+// EmbedBatch embeds several texts in a single request, which is much faster
+// than one request per text. The vectors are in the order of texts.
+func (c *Client) EmbedBatch(ctx context.Context, model string, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	return c.embed(ctx, model, texts, len(texts))
+}
+
+// embed posts input to /api/embed and expects want embeddings back.
+func (c *Client) embed(ctx context.Context, model string, input any, want int) ([][]float32, error) {
 	body, err := json.Marshal(embedRequest{
 		Model: model,
-		Input: text,
+		Input: input,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("marshal request: %w", err)
@@ -59,8 +86,7 @@ func (c *Client) Embed(ctx context.Context, model string, text string) ([]float3
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		respBody, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("ollama returned %d: %s", resp.StatusCode, string(respBody))
+		return nil, statusError(resp)
 	}
 
 	var result embedResponse
@@ -71,15 +97,30 @@ func (c *Client) Embed(ctx context.Context, model string, text string) ([]float3
 	if len(result.Embeddings) == 0 || len(result.Embeddings[0]) == 0 {
 		return nil, fmt.Errorf("ollama returned empty embeddings")
 	}
+	if len(result.Embeddings) != want {
+		return nil, fmt.Errorf("ollama returned %d embeddings for %d inputs", len(result.Embeddings), want)
+	}
+	if c.tokens != nil {
+		c.tokens.Add(result.PromptEvalCount)
+	}
 
 	// Convert float64 → float32 for Qdrant.
-	f64 := result.Embeddings[0]
-	vec := make([]float32, len(f64))
-	for i, v := range f64 {
-		vec[i] = float32(v)
+	vecs := make([][]float32, len(result.Embeddings))
+	for i, f64 := range result.Embeddings {
+		vec := make([]float32, len(f64))
+		for j, v := range f64 {
+			vec[j] = float32(v)
+		}
+		vecs[i] = vec
 	}
 
-	return vec, nil
+	return vecs, nil
+}
+
+// CountTokens makes the client add the tokens Ollama reports embedding to
+// n, for usage accounting.
+func (c *Client) CountTokens(n *atomic.Int64) {
+	c.tokens = n
 }
 
 // Health checks whether Ollama is reachable.
@@ -101,3 +142,110 @@ func (c *Client) Health(ctx context.Context) error {
 
 	return nil
 }
+
+// Version returns the version of the Ollama server, from GET /api/version.
+func (c *Client) Version(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/api/version", nil)
+	if err != nil {
+		return "", fmt.Errorf("create request: %w", err)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("cannot reach Ollama at %s — is it running? %w", c.baseURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("ollama returned status %d", resp.StatusCode)
+	}
+	var v struct {
+		Version string `json:"version"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+		return "", fmt.Errorf("decode response: %w", err)
+	}
+	return v.Version, nil
+}
+
+// generateRequest is the JSON body for POST /api/generate.
+type generateRequest struct {
+	Model  string   `json:"model"`
+	Prompt string   `json:"prompt"`
+	Images []string `json:"images,omitempty"` // base64-encoded, for vision models
+	Stream bool     `json:"stream"`
+}
+
+// generateResponse is the (non-streaming) JSON response from POST /api/generate.
+type generateResponse struct {
+	Model    string `json:"model"`
+	Response string `json:"response"`
+}
+
+// Generate runs a single non-streaming completion of prompt with the given
+// model and returns the generated text.
+func (c *Client) Generate(ctx context.Context, model string, prompt string) (string, error) {
+	return c.generate(ctx, generateRequest{Model: model, Prompt: prompt})
+}
+
+// Describe asks a vision model (e.g. llava) to respond to prompt about an
+// image, given as raw file bytes, and returns the generated text.
+func (c *Client) Describe(ctx context.Context, model string, prompt string, image []byte) (string, error) {
+	return c.generate(ctx, generateRequest{
+		Model:  model,
+		Prompt: prompt,
+		Images: []string{base64.StdEncoding.EncodeToString(image)},
+	})
+}
+
+// generate sends a non-streaming /api/generate request.
+func (c *Client) generate(ctx context.Context, gr generateRequest) (string, error) {
+	gr.Stream = false
+	body, err := json.Marshal(gr)
+	if err != nil {
+		return "", fmt.Errorf("marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/api/generate", bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("ollama request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", statusError(resp)
+	}
+
+	var result generateResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("decode response: %w", err)
+	}
+
+	return result.Response, nil
+}
+
+// StatusError is a non-200 answer from Ollama. RetryAfter holds the
+// Retry-After header of a 429 or 503, if Ollama sent one.
+type StatusError struct {
+	Code       int
+	Body       string
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("ollama returned %d: %s", e.Code, e.Body)
+}
+
+// statusError reads a failed response into a *StatusError.
+func statusError(resp *http.Response) *StatusError {
+	body, _ := io.ReadAll(resp.Body)
+	e := &StatusError{Code: resp.StatusCode, Body: string(body)}
+	if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs > 0 {
+		e.RetryAfter = time.Duration(secs) * time.Second
+	}
+	return e
+}
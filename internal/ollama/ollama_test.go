@@ -2,6 +2,11 @@ package ollama
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 )
@@ -126,3 +131,163 @@ func TestEmbedBadModel(t *testing.T) {
 		t.Fatal("expected error for nonexistent model")
 	}
 }
+
+func TestGenerate(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/generate" {
+			http.NotFound(w, r)
+			return
+		}
+		var req generateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Stream {
+			http.Error(w, "expected non-streaming request", http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(generateResponse{Model: req.Model, Response: "echo: " + req.Prompt})
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	got, err := New(srv.URL).Generate(ctx, "llama3.2", "hello")
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if got != "echo: hello" {
+		t.Errorf("expected echoed response, got %q", got)
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	image := []byte("\x89PNG\r\n\x1a\nfake image bytes")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req generateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(req.Images) != 1 {
+			http.Error(w, "expected one image", http.StatusBadRequest)
+			return
+		}
+		decoded, err := base64.StdEncoding.DecodeString(req.Images[0])
+		if err != nil || string(decoded) != string(image) {
+			http.Error(w, "image not base64 of the input", http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(generateResponse{Model: req.Model, Response: "a screenshot"})
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	got, err := New(srv.URL).Describe(ctx, "llava", "describe this", image)
+	if err != nil {
+		t.Fatalf("Describe failed: %v", err)
+	}
+	if got != "a screenshot" {
+		t.Errorf("expected caption, got %q", got)
+	}
+}
+
+func TestGenerateErrorStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":"model not found"}`, http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := New(srv.URL).Generate(ctx, "missing", "hello"); err == nil {
+		t.Fatal("expected error for non-200 response")
+	}
+}
+
+func TestVersion(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/version" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"version":"0.5.7"}`))
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	v, err := New(srv.URL).Version(ctx)
+	if err != nil || v != "0.5.7" {
+		t.Errorf("Version = %q, %v", v, err)
+	}
+}
+
+func TestEmbedOverloaded(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "12")
+		http.Error(w, "server busy", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := New(srv.URL).Embed(ctx, testModel, "hello")
+	var se *StatusError
+	if !errors.As(err, &se) {
+		t.Fatalf("expected a *StatusError, got %v", err)
+	}
+	if se.Code != http.StatusServiceUnavailable || se.RetryAfter != 12*time.Second {
+		t.Errorf("unexpected status error %+v", se)
+	}
+}
+
+func TestEmbedBatch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Input []string `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "expected a list of inputs", http.StatusBadRequest)
+			return
+		}
+		var resp embedResponse
+		for i := range req.Input {
+			resp.Embeddings = append(resp.Embeddings, []float64{float64(i), 1})
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	vecs, err := New(srv.URL).EmbedBatch(ctx, "all-minilm", []string{"a", "b", "c"})
+	if err != nil {
+		t.Fatalf("EmbedBatch failed: %v", err)
+	}
+	if len(vecs) != 3 || vecs[2][0] != 2 {
+		t.Errorf("expected three vectors in input order, got %v", vecs)
+	}
+}
+
+func TestEmbedBatchCountMismatch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(embedResponse{Embeddings: [][]float64{{1, 0}}})
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := New(srv.URL).EmbedBatch(ctx, "all-minilm", []string{"a", "b"}); err == nil {
+		t.Fatal("expected error when fewer embeddings than inputs come back")
+	}
+}
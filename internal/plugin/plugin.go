@@ -0,0 +1,108 @@
+// Package plugin finds external clawbrain subcommands. Like git, an
+// executable named clawbrain-<name> anywhere on PATH becomes the command
+// "clawbrain <name>", so teams can add their own importers and reports
+// without forking. Built-in commands always win over a plugin of the same
+// name.
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// Prefix is what a plugin executable's name starts with.
+const Prefix = "clawbrain-"
+
+// Plugin is an external subcommand found on PATH.
+type Plugin struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+// Find returns the path of the plugin for the command name. Names with a
+// path separator are refused, so a command can't reach outside PATH.
+func Find(name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, "-") {
+		return "", fmt.Errorf("invalid plugin name %q", name)
+	}
+	return exec.LookPath(Prefix + name)
+}
+
+// List returns every plugin on PATH, sorted by name. When two directories
+// hold a plugin of the same name, the first on PATH wins, as it would when
+// run.
+func List() []Plugin {
+	seen := map[string]bool{}
+	var plugins []Plugin
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if dir == "" {
+			dir = "."
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			name, ok := commandName(e.Name())
+			if !ok || seen[name] {
+				continue
+			}
+			path := filepath.Join(dir, e.Name())
+			if !executable(path) {
+				continue
+			}
+			seen[name] = true
+			plugins = append(plugins, Plugin{Name: name, Path: path})
+		}
+	}
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].Name < plugins[j].Name })
+	return plugins
+}
+
+// commandName returns the command a file on PATH provides, if it is a
+// plugin.
+func commandName(file string) (string, bool) {
+	if !strings.HasPrefix(file, Prefix) {
+		return "", false
+	}
+	name := strings.TrimPrefix(file, Prefix)
+	if runtime.GOOS == "windows" {
+		ext := filepath.Ext(name)
+		if !strings.EqualFold(ext, ".exe") && !strings.EqualFold(ext, ".bat") && !strings.EqualFold(ext, ".cmd") {
+			return "", false
+		}
+		name = strings.TrimSuffix(name, ext)
+	}
+	return name, name != ""
+}
+
+// executable reports whether path is a regular file that can be run.
+func executable(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	return runtime.GOOS == "windows" || info.Mode().Perm()&0o111 != 0
+}
+
+// Env returns the environment a plugin runs with: the caller's, with vars
+// set on top. Plugins read the resolved global settings from the same
+// CLAWBRAIN_* variables the CLI itself reads, so a plugin calling back
+// into clawbrain inherits them.
+func Env(vars map[string]string) []string {
+	env := os.Environ()
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		env = append(env, k+"="+vars[k])
+	}
+	return env
+}
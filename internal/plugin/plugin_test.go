@@ -0,0 +1,58 @@
+package plugin
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+)
+
+// writeExecutable creates a file in dir, executable or not.
+func writeExecutable(t *testing.T, dir, name string, mode os.FileMode) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"), mode); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFindAndList(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("plugins are found by extension on Windows")
+	}
+	first, second := t.TempDir(), t.TempDir()
+	report := writeExecutable(t, first, "clawbrain-report", 0o755)
+	writeExecutable(t, second, "clawbrain-report", 0o755) // shadowed by first
+	importer := writeExecutable(t, second, "clawbrain-import-jira", 0o755)
+	writeExecutable(t, second, "clawbrain-notes", 0o644) // not executable
+	writeExecutable(t, second, "other-tool", 0o755)
+	t.Setenv("PATH", first+string(os.PathListSeparator)+second)
+
+	path, err := Find("report")
+	if err != nil || path != report {
+		t.Errorf("Find(report) = %q, %v; want %q", path, err, report)
+	}
+	if _, err := Find("notes"); err == nil {
+		t.Error("expected a non-executable file not to be found")
+	}
+	for _, name := range []string{"", "../report", "-x"} {
+		if _, err := Find(name); err == nil {
+			t.Errorf("expected Find(%q) to be refused", name)
+		}
+	}
+
+	want := []Plugin{{"import-jira", importer}, {"report", report}}
+	if got := List(); !slices.Equal(got, want) {
+		t.Errorf("List() = %v, want %v", got, want)
+	}
+}
+
+func TestEnv(t *testing.T) {
+	t.Setenv("CLAWBRAIN_TEST_INHERITED", "yes")
+	env := Env(map[string]string{"CLAWBRAIN_HOST": "qdrant"})
+	if !slices.Contains(env, "CLAWBRAIN_TEST_INHERITED=yes") || !slices.Contains(env, "CLAWBRAIN_HOST=qdrant") {
+		t.Errorf("Env() missing inherited or set vars: %v", env)
+	}
+}
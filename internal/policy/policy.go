@@ -0,0 +1,136 @@
+// Package policy evaluates configurable write policies against a memory
+// payload before it is stored. Policies let fleet operators put guardrails on
+// what agents may store: text length limits, required fields per memory type,
+// banned content patterns, and required tags.
+package policy
+
+import (
+	"fmt"
+	"regexp"
+	"unicode/utf8"
+)
+
+// AllTypes is the required_fields key whose fields apply to every memory,
+// regardless of type.
+const AllTypes = "*"
+
+// Policy is a set of write rules. The zero Policy allows everything.
+type Policy struct {
+	// MaxTextLength caps the text field, in characters. 0 means no limit.
+	MaxTextLength int `json:"max_text_length,omitempty"`
+	// RequiredFields maps a memory type to payload fields it must carry.
+	// Fields under AllTypes ("*") are required on every memory.
+	RequiredFields map[string][]string `json:"required_fields,omitempty"`
+	// BannedPatterns are regular expressions the text must not match.
+	BannedPatterns []string `json:"banned_patterns,omitempty"`
+	// RequiredTags must all be present in the payload's tags array.
+	RequiredTags []string `json:"required_tags,omitempty"`
+
+	banned []*regexp.Regexp
+}
+
+// Violation is a single structured rejection reason.
+type Violation struct {
+	Rule    string `json:"rule"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// Compile validates and compiles the banned patterns. It must be called
+// before Evaluate when BannedPatterns is set.
+func (p *Policy) Compile() error {
+	p.banned = make([]*regexp.Regexp, 0, len(p.BannedPatterns))
+	for _, pattern := range p.BannedPatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid banned pattern %q: %w", pattern, err)
+		}
+		p.banned = append(p.banned, re)
+	}
+	if p.MaxTextLength < 0 {
+		return fmt.Errorf("max_text_length must be non-negative, got %d", p.MaxTextLength)
+	}
+	return nil
+}
+
+// Evaluate checks a payload against every rule and returns all violations,
+// or nil if the payload may be stored. All rules are checked so the caller
+// can fix everything in one pass rather than one rejection at a time.
+func (p *Policy) Evaluate(payload map[string]any) []Violation {
+	var out []Violation
+	text, _ := payload["text"].(string)
+
+	if p.MaxTextLength > 0 {
+		if n := utf8.RuneCountInString(text); n > p.MaxTextLength {
+			out = append(out, Violation{
+				Rule:    "max_text_length",
+				Field:   "text",
+				Message: fmt.Sprintf("text is %d characters, limit is %d", n, p.MaxTextLength),
+			})
+		}
+	}
+
+	memType, _ := payload["type"].(string)
+	required := append([]string{}, p.RequiredFields[AllTypes]...)
+	if memType != "" {
+		required = append(required, p.RequiredFields[memType]...)
+	}
+	for _, field := range required {
+		if !present(payload[field]) {
+			msg := fmt.Sprintf("field %q is required", field)
+			if memType != "" {
+				msg = fmt.Sprintf("field %q is required for type %q", field, memType)
+			}
+			out = append(out, Violation{Rule: "required_field", Field: field, Message: msg})
+		}
+	}
+
+	// Report the pattern, not the matched text: the match may be the very
+	// secret the pattern exists to keep out of storage and logs.
+	for i, re := range p.banned {
+		if re.MatchString(text) {
+			out = append(out, Violation{
+				Rule:    "banned_pattern",
+				Field:   "text",
+				Message: fmt.Sprintf("text matches banned pattern %q", p.BannedPatterns[i]),
+			})
+		}
+	}
+
+	if len(p.RequiredTags) > 0 {
+		have := map[string]bool{}
+		if list, ok := payload["tags"].([]any); ok {
+			for _, t := range list {
+				if s, ok := t.(string); ok {
+					have[s] = true
+				}
+			}
+		}
+		for _, tag := range p.RequiredTags {
+			if !have[tag] {
+				out = append(out, Violation{
+					Rule:    "required_tag",
+					Field:   "tags",
+					Message: fmt.Sprintf("tag %q is required", tag),
+				})
+			}
+		}
+	}
+
+	return out
+}
+
+// present reports whether a payload value counts as set: not missing, not
+// null, and not an empty string or list.
+func present(v any) bool {
+	switch val := v.(type) {
+	case nil:
+		return false
+	case string:
+		return val != ""
+	case []any:
+		return len(val) > 0
+	default:
+		return true
+	}
+}
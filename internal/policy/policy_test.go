@@ -0,0 +1,97 @@
+package policy
+
+import (
+	"strings"
+	"testing"
+)
+
+func compiled(t *testing.T, p Policy) *Policy {
+	t.Helper()
+	if err := p.Compile(); err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+	return &p
+}
+
+func rules(vs []Violation) []string {
+	out := make([]string, len(vs))
+	for i, v := range vs {
+		out[i] = v.Rule + ":" + v.Field
+	}
+	return out
+}
+
+func TestZeroPolicyAllowsEverything(t *testing.T) {
+	p := compiled(t, Policy{})
+	if vs := p.Evaluate(map[string]any{"text": strings.Repeat("x", 100000)}); vs != nil {
+		t.Errorf("expected no violations, got %+v", vs)
+	}
+}
+
+func TestMaxTextLength(t *testing.T) {
+	p := compiled(t, Policy{MaxTextLength: 5})
+
+	if vs := p.Evaluate(map[string]any{"text": "héllo"}); vs != nil {
+		t.Errorf("5 characters should pass (multi-byte counts once), got %+v", vs)
+	}
+	vs := p.Evaluate(map[string]any{"text": "hello!"})
+	if len(vs) != 1 || vs[0].Rule != "max_text_length" {
+		t.Fatalf("expected max_text_length violation, got %+v", vs)
+	}
+}
+
+func TestRequiredFields(t *testing.T) {
+	p := compiled(t, Policy{RequiredFields: map[string][]string{
+		AllTypes:   {"source"},
+		"decision": {"rationale"},
+	}})
+
+	vs := p.Evaluate(map[string]any{"text": "x", "type": "decision", "source": ""})
+	got := rules(vs)
+	if len(got) != 2 || got[0] != "required_field:source" || got[1] != "required_field:rationale" {
+		t.Fatalf("expected source and rationale violations, got %v", got)
+	}
+	if !strings.Contains(vs[1].Message, `type "decision"`) {
+		t.Errorf("expected message to name the type, got %q", vs[1].Message)
+	}
+
+	if vs := p.Evaluate(map[string]any{"text": "x", "source": "/a.md"}); vs != nil {
+		t.Errorf("untyped memory only needs the * fields, got %+v", vs)
+	}
+}
+
+func TestBannedPatterns(t *testing.T) {
+	p := compiled(t, Policy{BannedPatterns: []string{`(?i)password\s*=`, `AKIA[0-9A-Z]{16}`}})
+
+	vs := p.Evaluate(map[string]any{"text": "db Password = hunter2"})
+	if len(vs) != 1 || vs[0].Rule != "banned_pattern" {
+		t.Fatalf("expected banned_pattern violation, got %+v", vs)
+	}
+	if strings.Contains(vs[0].Message, "hunter2") {
+		t.Errorf("violation must not echo the matched text: %q", vs[0].Message)
+	}
+	if vs := p.Evaluate(map[string]any{"text": "rotate passwords quarterly"}); vs != nil {
+		t.Errorf("expected no violations, got %+v", vs)
+	}
+}
+
+func TestRequiredTags(t *testing.T) {
+	p := compiled(t, Policy{RequiredTags: []string{"team", "reviewed"}})
+
+	vs := p.Evaluate(map[string]any{"text": "x", "tags": []any{"team"}})
+	if got := rules(vs); len(got) != 1 || got[0] != "required_tag:tags" {
+		t.Fatalf("expected one missing tag, got %v", got)
+	}
+	if vs := p.Evaluate(map[string]any{"text": "x", "tags": []any{"reviewed", "team"}}); vs != nil {
+		t.Errorf("expected no violations, got %+v", vs)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	if err := (&Policy{BannedPatterns: []string{"("}}).Compile(); err == nil {
+		t.Error("expected error for invalid regex")
+	}
+	if err := (&Policy{MaxTextLength: -1}).Compile(); err == nil {
+		t.Error("expected error for negative max_text_length")
+	}
+}
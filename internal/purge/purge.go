@@ -0,0 +1,167 @@
+// Package purge finds every memory about a given entity -- a person, a
+// project, a topic -- so it can be removed on request. A deletion request
+// has to be thorough, so a memory is matched several ways: by name in its
+// text, by its attribution and tags, and (in the caller) by embedding
+// similarity for memories that discuss the entity without naming it.
+package purge
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/hsk-coder/clawbrain/internal/store"
+)
+
+// Reasons a memory matched, as reported in matched_by.
+const (
+	ByKeyword     = "keyword"     // the text names the entity
+	ByAttribution = "attribution" // author or speaker is the entity
+	ByTag         = "tag"         // a tag is the entity
+	ByEmbedding   = "embedding"   // the text is semantically close to the entity
+)
+
+// Match reports why a payload matches entity, or nil if it doesn't. Text is
+// matched case-insensitively on word boundaries, so "Ann" doesn't match
+// "Annual".
+func Match(payload map[string]any, entity string) []string {
+	var reasons []string
+	if text, _ := payload["text"].(string); Mentions(text, entity) {
+		reasons = append(reasons, ByKeyword)
+	}
+	name := store.NormalizeName(entity)
+	for _, field := range []string{store.AuthorField, store.SpeakerField} {
+		if v, _ := payload[field].(string); v != "" && v == name {
+			reasons = append(reasons, ByAttribution)
+			break
+		}
+	}
+	for _, tag := range store.Tags(payload) {
+		if store.NormalizeName(tag) == name {
+			reasons = append(reasons, ByTag)
+			break
+		}
+	}
+	return reasons
+}
+
+// Mentions reports whether text contains entity as a whole word or phrase,
+// ignoring case and treating any run of whitespace in entity as matching
+// any run of whitespace in text.
+func Mentions(text, entity string) bool {
+	words := strings.Fields(strings.ToLower(entity))
+	if len(words) == 0 {
+		return false
+	}
+	lower := strings.ToLower(text)
+	for from := 0; ; {
+		i := strings.Index(lower[from:], words[0])
+		if i < 0 {
+			return false
+		}
+		start := from + i
+		if end, ok := matchWords(lower, start, words); ok && boundary(lower, start, end) {
+			return true
+		}
+		from = start + len(words[0])
+	}
+}
+
+// matchWords reports whether words follow each other from start, separated
+// by whitespace, and where the match ends.
+func matchWords(text string, start int, words []string) (int, bool) {
+	pos := start
+	for i, w := range words {
+		if i > 0 {
+			rest := strings.TrimLeftFunc(text[pos:], unicode.IsSpace)
+			if len(rest) == len(text[pos:]) {
+				return 0, false
+			}
+			pos = len(text) - len(rest)
+		}
+		if !strings.HasPrefix(text[pos:], w) {
+			return 0, false
+		}
+		pos += len(w)
+	}
+	return pos, true
+}
+
+// boundary reports whether text[start:end] is not glued to a letter or digit
+// on either side.
+func boundary(text string, start, end int) bool {
+	before := []rune(text[:start])
+	if len(before) > 0 && isWord(before[len(before)-1]) {
+		return false
+	}
+	for _, r := range text[end:] {
+		return !isWord(r)
+	}
+	return true
+}
+
+func isWord(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// Hit is a memory found by a purge, with every reason it matched.
+type Hit struct {
+	ID        string         `json:"id"`
+	MatchedBy []string       `json:"matched_by"`
+	Score     float32        `json:"score,omitempty"` // embedding similarity, when matched that way
+	Payload   map[string]any `json:"-"`
+}
+
+// Collect merges matches into hits keyed by memory ID, combining reasons
+// for memories found more than once.
+type Collect map[string]*Hit
+
+// Add records that memory r matched for reasons. A positive score is kept
+// as the hit's embedding similarity.
+func (c Collect) Add(r store.Result, score float32, reasons ...string) {
+	h, ok := c[r.ID]
+	if !ok {
+		h = &Hit{ID: r.ID, Payload: r.Payload}
+		c[r.ID] = h
+	}
+	for _, reason := range reasons {
+		if !contains(h.MatchedBy, reason) {
+			h.MatchedBy = append(h.MatchedBy, reason)
+		}
+	}
+	if score > h.Score {
+		h.Score = score
+	}
+}
+
+// Hits returns the collected hits, strongest evidence first: memories
+// naming the entity outright before those matched only by embedding, then
+// by ID for a stable order.
+func (c Collect) Hits() []*Hit {
+	hits := make([]*Hit, 0, len(c))
+	for _, h := range c {
+		sort.Strings(h.MatchedBy)
+		hits = append(hits, h)
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		ei, ej := embeddingOnly(hits[i]), embeddingOnly(hits[j])
+		if ei != ej {
+			return ej
+		}
+		return hits[i].ID < hits[j].ID
+	})
+	return hits
+}
+
+func embeddingOnly(h *Hit) bool {
+	return len(h.MatchedBy) == 1 && h.MatchedBy[0] == ByEmbedding
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package purge
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hsk-coder/clawbrain/internal/store"
+)
+
+func TestMentions(t *testing.T) {
+	tests := []struct {
+		text, entity string
+		want         bool
+	}{
+		{"Met John Doe for coffee.", "John Doe", true},
+		{"met JOHN   doe\nagain", "john doe", true},
+		{"John Doe's birthday", "John Doe", true},
+		{"Johnny Doe called", "John Doe", false},
+		{"Annual review", "Ann", false},
+		{"Ann, Bob and the Annual review", "ann", true},
+		{"see john-doe notes", "John", true},
+		{"John alone", "John Doe", false},
+		{"", "John", false},
+		{"anything", "  ", false},
+		{"Über café", "über", true},
+	}
+	for _, tt := range tests {
+		if got := Mentions(tt.text, tt.entity); got != tt.want {
+			t.Errorf("Mentions(%q, %q) = %v, want %v", tt.text, tt.entity, got, tt.want)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	payload := map[string]any{
+		"text":    "John Doe prefers email",
+		"speaker": "john doe",
+		"tags":    []any{"John Doe", "contacts"},
+	}
+	want := []string{ByKeyword, ByAttribution, ByTag}
+	if got := Match(payload, "john  DOE"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Match = %v, want %v", got, want)
+	}
+	if got := Match(map[string]any{"text": "unrelated"}, "John Doe"); got != nil {
+		t.Errorf("expected no match, got %v", got)
+	}
+}
+
+func TestCollect(t *testing.T) {
+	c := Collect{}
+	c.Add(store.Result{ID: "b"}, 0.7, ByEmbedding)
+	c.Add(store.Result{ID: "c"}, 0, ByKeyword)
+	c.Add(store.Result{ID: "a"}, 0, ByTag)
+	c.Add(store.Result{ID: "a"}, 0.8, ByEmbedding, ByTag)
+
+	hits := c.Hits()
+	var ids []string
+	for _, h := range hits {
+		ids = append(ids, h.ID)
+	}
+	if !reflect.DeepEqual(ids, []string{"a", "c", "b"}) {
+		t.Errorf("expected named matches before embedding-only ones, got %v", ids)
+	}
+	if !reflect.DeepEqual(hits[0].MatchedBy, []string{ByEmbedding, ByTag}) || hits[0].Score != 0.8 {
+		t.Errorf("unexpected merged hit: %+v", hits[0])
+	}
+}
@@ -0,0 +1,91 @@
+package ranking
+
+import (
+	"math"
+	"sort"
+)
+
+// Bucket is one bar of a score histogram: the scores in [Min, Max), or
+// [Min, Max] for the top bucket.
+type Bucket struct {
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Count int     `json:"count"`
+	// AtLeast counts the scores at or above Min: what a search with
+	// --min-score Min would have to choose from.
+	AtLeast int `json:"at_least"`
+}
+
+// Distribution summarizes a set of similarity scores.
+type Distribution struct {
+	Count   int      `json:"count"`
+	Min     float64  `json:"min"`
+	Max     float64  `json:"max"`
+	Mean    float64  `json:"mean"`
+	Median  float64  `json:"median"`
+	P90     float64  `json:"p90"`
+	Below   int      `json:"below_zero"` // negative scores, outside every bucket
+	Buckets []Bucket `json:"buckets"`
+}
+
+// Histogram splits [0, 1] into n equal buckets, highest first, and counts
+// the scores in each. Cosine similarity can dip below zero; such scores are
+// counted in Below rather than stretching the buckets.
+func Histogram(scores []float32, n int) Distribution {
+	d := Distribution{Count: len(scores), Buckets: make([]Bucket, n)}
+	width := 1 / float64(n)
+	for i := range d.Buckets {
+		// Highest first, like search results.
+		lo := float64(n-1-i) * width
+		d.Buckets[i] = Bucket{Min: round(lo), Max: round(lo + width)}
+	}
+	if len(scores) == 0 {
+		return d
+	}
+
+	sorted := make([]float64, len(scores))
+	sum := 0.0
+	for i, s := range scores {
+		// Drop float32 noise, so a score of 0.7 lands in the bucket
+		// starting at 0.7 rather than the one below.
+		sorted[i] = math.Round(float64(s)*1e6) / 1e6
+		sum += sorted[i]
+	}
+	sort.Float64s(sorted)
+	d.Min = round(sorted[0])
+	d.Max = round(sorted[len(sorted)-1])
+	d.Mean = round(sum / float64(len(sorted)))
+	d.Median = round(percentile(sorted, 0.5))
+	d.P90 = round(percentile(sorted, 0.9))
+
+	for _, s := range sorted {
+		if s < 0 {
+			d.Below++
+			continue
+		}
+		i := n - 1 - int(s*float64(n))
+		d.Buckets[max(i, 0)].Count++
+	}
+	total := 0
+	for i := range d.Buckets {
+		total += d.Buckets[i].Count
+		d.Buckets[i].AtLeast = total
+	}
+	return d
+}
+
+// percentile interpolates the p-th percentile of sorted values.
+func percentile(sorted []float64, p float64) float64 {
+	pos := p * float64(len(sorted)-1)
+	lo := int(pos)
+	if lo+1 >= len(sorted) {
+		return sorted[lo]
+	}
+	return sorted[lo] + (pos-float64(lo))*(sorted[lo+1]-sorted[lo])
+}
+
+// round keeps histogram numbers readable: four decimals is finer than any
+// --min-score anyone picks.
+func round(v float64) float64 {
+	return math.Round(v*1e4) / 1e4
+}
@@ -0,0 +1,36 @@
+package ranking
+
+import "testing"
+
+func TestHistogram(t *testing.T) {
+	d := Histogram([]float32{0.95, 0.7, 0.69, 0.4, 0.1, 1, -0.2}, 5)
+	if d.Count != 7 || d.Below != 1 || d.Min != -0.2 || d.Max != 1 {
+		t.Errorf("unexpected summary %+v", d)
+	}
+	want := []struct {
+		min     float64
+		count   int
+		atLeast int
+	}{{0.8, 2, 2}, {0.6, 2, 4}, {0.4, 1, 5}, {0.2, 0, 5}, {0, 1, 6}}
+	if len(d.Buckets) != len(want) {
+		t.Fatalf("expected %d buckets, got %+v", len(want), d.Buckets)
+	}
+	for i, w := range want {
+		b := d.Buckets[i]
+		if b.Min != w.min || b.Count != w.count || b.AtLeast != w.atLeast {
+			t.Errorf("bucket %d: got %+v, want min %g count %d at_least %d", i, b, w.min, w.count, w.atLeast)
+		}
+	}
+	if d.Median != 0.69 {
+		t.Errorf("expected median 0.69, got %g", d.Median)
+	}
+
+	// A score right on a boundary belongs to the bucket starting there.
+	if d := Histogram([]float32{0.7}, 10); d.Buckets[3].Min != 0.6 || d.Buckets[2].Count != 1 {
+		t.Errorf("expected 0.7 in the [0.7, 0.8) bucket, got %+v", d.Buckets)
+	}
+
+	if d := Histogram(nil, 4); d.Count != 0 || len(d.Buckets) != 4 || d.Buckets[0].Max != 1 {
+		t.Errorf("unexpected empty histogram %+v", d)
+	}
+}
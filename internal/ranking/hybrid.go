@@ -0,0 +1,138 @@
+package ranking
+
+import (
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/hsk-coder/clawbrain/internal/store"
+	"github.com/hsk-coder/clawbrain/internal/textnorm"
+)
+
+// DefaultKeywordWeight weighs keyword matches the same as vector similarity
+// in a hybrid search.
+const DefaultKeywordWeight = 1.0
+
+// rrfK is the reciprocal rank fusion constant. The usual 60 keeps a single
+// list's top hit from drowning out results both lists agree on.
+const rrfK = 60
+
+// stopwords are dropped from keyword terms: they appear in nearly every
+// memory and would make every memory a keyword match.
+var stopwords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "but": true, "by": true, "did": true, "do": true, "does": true,
+	"for": true, "from": true, "how": true, "i": true, "in": true, "is": true,
+	"it": true, "me": true, "my": true, "of": true, "on": true, "or": true,
+	"our": true, "so": true, "that": true, "the": true, "this": true,
+	"to": true, "was": true, "we": true, "what": true, "when": true,
+	"where": true, "which": true, "who": true, "why": true, "with": true,
+	"you": true,
+}
+
+// Terms returns the keyword terms of a query: its distinct words, without
+// stopwords or single characters. Chinese and Japanese come out as pairs of
+// characters and Korean without its particles (see textnorm.Words); a
+// single kanji is kept, since it is often a word of its own.
+func Terms(query string) []string {
+	var terms []string
+	for _, w := range textnorm.Words(query) {
+		short := len([]rune(w)) < 2 && !textnorm.IsCJK(w)
+		if short || stopwords[w] || textnorm.IsFunctionWord(w) || slices.Contains(terms, w) {
+			continue
+		}
+		terms = append(terms, w)
+	}
+	return terms
+}
+
+// KeywordScore returns the fraction of terms that appear as words in the
+// memory's text: 1 when it mentions all of them. Chinese, Japanese and
+// Korean terms match anywhere in the text, as they aren't set off by spaces.
+func KeywordScore(payload map[string]any, terms []string) float64 {
+	if len(terms) == 0 {
+		return 0
+	}
+	text, _ := payload[store.TextField].(string)
+	folded := strings.ToLower(textnorm.Fold(text))
+	have := make(map[string]bool)
+	for _, w := range textnorm.Words(text) {
+		have[w] = true
+	}
+	matched := 0
+	for _, t := range terms {
+		if have[t] || textnorm.IsCJK(t) && strings.Contains(folded, t) {
+			matched++
+		}
+	}
+	return float64(matched) / float64(len(terms))
+}
+
+// RankByKeywords orders keyword matches by how many of the terms they
+// contain, keeping the incoming (similarity) order among equals. Results
+// containing none of the terms as whole words are dropped.
+func RankByKeywords(results []store.Result, terms []string) []store.Result {
+	scores := make(map[string]float64, len(results))
+	out := results[:0]
+	for _, r := range results {
+		if s := KeywordScore(r.Payload, terms); s > 0 {
+			scores[r.ID] = s
+			out = append(out, r)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return scores[out[i].ID] > scores[out[j].ID]
+	})
+	return out
+}
+
+// FuseRRF merges a similarity ranking and a keyword ranking with weighted
+// reciprocal rank fusion: each result earns 1/(60+rank) from the similarity
+// list and keywordWeight/(60+rank) from the keyword list, and the merged list
+// is ordered by the sum. Results keep their similarity score; only the order
+// reflects the fusion.
+func FuseRRF(similar, keyword []store.Result, keywordWeight float64) []store.Result {
+	fused := make(map[string]float64)
+	var out []store.Result
+	add := func(list []store.Result, weight float64) {
+		for rank, r := range list {
+			if _, seen := fused[r.ID]; !seen {
+				out = append(out, r)
+			}
+			fused[r.ID] += weight / float64(rrfK+rank+1)
+		}
+	}
+	add(similar, 1)
+	add(keyword, keywordWeight)
+	sort.SliceStable(out, func(i, j int) bool {
+		return fused[out[i].ID] > fused[out[j].ID]
+	})
+	return out
+}
+
+// FuseQueries merges the rankings of several phrasings of one query with
+// reciprocal rank fusion, each list weighing the same: a result earns
+// 1/(60+rank) from every list it is in, so one found by most phrasings
+// beats one a single phrasing ranked first. A result found by several
+// keeps its best score.
+func FuseQueries(lists ...[]store.Result) []store.Result {
+	fused := make(map[string]float64)
+	index := make(map[string]int)
+	var out []store.Result
+	for _, list := range lists {
+		for rank, r := range list {
+			i, seen := index[r.ID]
+			if !seen {
+				index[r.ID] = len(out)
+				out = append(out, r)
+			} else if r.Score > out[i].Score {
+				out[i] = r
+			}
+			fused[r.ID] += 1 / float64(rrfK+rank+1)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return fused[out[i].ID] > fused[out[j].ID]
+	})
+	return out
+}
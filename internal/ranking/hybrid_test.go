@@ -0,0 +1,110 @@
+package ranking
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hsk-coder/clawbrain/internal/store"
+)
+
+func TestTerms(t *testing.T) {
+	got := Terms("What is the TODO for the auth-service deploy? deploy!")
+	want := []string{"todo", "auth", "service", "deploy"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	if got := Terms("what is it"); got != nil {
+		t.Errorf("expected no terms, got %v", got)
+	}
+}
+
+func TestTerms_CJK(t *testing.T) {
+	for query, want := range map[string][]string{
+		"東京タワーに行きたい":  {"東京", "タワ", "ワー", "行"},
+		"서울에서 회의는 언제": {"서울", "회의", "언제"},
+		"ＡＰＩキーの場所":    {"api", "キー", "場所"},
+		"我们的数据库在哪里":   {"们的", "的数", "数据", "据库", "库在", "在哪", "哪里"},
+	} {
+		if got := Terms(query); !reflect.DeepEqual(got, want) {
+			t.Errorf("Terms(%q) = %q, want %q", query, got, want)
+		}
+	}
+}
+
+func TestKeywordScore_CJK(t *testing.T) {
+	payload := map[string]any{"text": "来週、東京タワーの近くでＡＰＩキーを更新する"}
+	if got := KeywordScore(payload, Terms("東京タワー")); got != 1 {
+		t.Errorf("expected every term to match, got %v", got)
+	}
+	if got := KeywordScore(payload, Terms("api キー")); got != 1 {
+		t.Errorf("expected fullwidth text to match halfwidth terms, got %v", got)
+	}
+	korean := map[string]any{"text": "서울에서 분기 회의를 했다"}
+	if got := KeywordScore(korean, Terms("서울 회의")); got != 1 {
+		t.Errorf("expected Korean terms to match despite particles, got %v", got)
+	}
+}
+
+func TestRankByKeywords(t *testing.T) {
+	text := func(id, s string) store.Result {
+		return store.Result{ID: id, Payload: map[string]any{"text": s}}
+	}
+	ranked := RankByKeywords([]store.Result{
+		text("a", "the deploy went fine"),
+		text("b", "todo: rotate deploy keys"),
+		text("c", "redeployed yesterday"),
+	}, []string{"todo", "deploy"})
+
+	var ids []string
+	for _, r := range ranked {
+		ids = append(ids, r.ID)
+	}
+	if !reflect.DeepEqual(ids, []string{"b", "a"}) {
+		t.Errorf("expected b, a; got %v", ids)
+	}
+}
+
+func TestFuseRRF(t *testing.T) {
+	similar := []store.Result{{ID: "a", Score: 0.9}, {ID: "b", Score: 0.8}, {ID: "c", Score: 0.3}}
+	keyword := []store.Result{{ID: "c", Score: 0.3}, {ID: "b", Score: 0.8}}
+
+	ids := func(results []store.Result) []string {
+		var out []string
+		for _, r := range results {
+			out = append(out, r.ID)
+		}
+		return out
+	}
+	// c tops the keyword list, b is second in both: c edges ahead.
+	if got := ids(FuseRRF(similar, keyword, 1)); !reflect.DeepEqual(got, []string{"c", "b", "a"}) {
+		t.Errorf("equal weights: expected c, b, a; got %v", got)
+	}
+	if got := ids(FuseRRF(similar, keyword, 0)); !reflect.DeepEqual(got, []string{"a", "b", "c"}) {
+		t.Errorf("zero keyword weight: expected similarity order, got %v", got)
+	}
+	if got := FuseRRF(similar, keyword, 1); got[0].Score != 0.3 {
+		t.Errorf("expected results to keep their similarity score, got %v", got[0].Score)
+	}
+}
+
+func TestFuseQueries(t *testing.T) {
+	original := []store.Result{{ID: "a", Score: 0.9}, {ID: "b", Score: 0.6}}
+	paraphrase := []store.Result{{ID: "c", Score: 0.8}, {ID: "b", Score: 0.7}}
+	again := []store.Result{{ID: "b", Score: 0.65}, {ID: "d", Score: 0.5}}
+
+	got := FuseQueries(original, paraphrase, again)
+	var ids []string
+	for _, r := range got {
+		ids = append(ids, r.ID)
+	}
+	// b is found by every phrasing; a and c each top one list.
+	if !reflect.DeepEqual(ids, []string{"b", "a", "c", "d"}) {
+		t.Errorf("expected b, a, c, d; got %v", ids)
+	}
+	if got[0].Score != 0.7 {
+		t.Errorf("expected b to keep its best score 0.7, got %v", got[0].Score)
+	}
+	if got := FuseQueries(original); !reflect.DeepEqual(got, original) {
+		t.Errorf("expected a single list unchanged, got %v", got)
+	}
+}
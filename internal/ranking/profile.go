@@ -0,0 +1,166 @@
+package ranking
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hsk-coder/clawbrain/internal/retention"
+	"github.com/hsk-coder/clawbrain/internal/store"
+)
+
+// FrequencyHalf is the number of recalls at which a memory gets half of a
+// profile's frequency weight. The bonus grows quickly for the first few
+// recalls and then levels off, so a memory recalled a thousand times can't
+// outrank every better match.
+const FrequencyHalf = 5
+
+// Profile is a set of ranking weights stored with a collection, for all of
+// it or for one agent, so the CLI, the plugin and serve rank the same way
+// without each caller repeating flags. Every weight is added to the
+// similarity score: zero leaves the ranking alone.
+type Profile struct {
+	// RecencyBoost is added to the scores of recently accessed memories,
+	// decaying at a half-life of RecencyScale (DefaultRecencyScale if
+	// empty), as with search --recency-boost.
+	RecencyBoost float64 `json:"recency_boost,omitempty"`
+	RecencyScale string  `json:"recency_scale,omitempty"`
+	// FrequencyWeight is added, scaled by count/(count+FrequencyHalf), to
+	// memories by how often they have been recalled.
+	FrequencyWeight float64 `json:"frequency_weight,omitempty"`
+	// TypeBoosts is added to memories of each type; "untyped" stands for
+	// memories without one.
+	TypeBoosts map[string]float64 `json:"type_boosts,omitempty"`
+	// PinnedBonus is added to pinned memories.
+	PinnedBonus float64 `json:"pinned_bonus,omitempty"`
+}
+
+// Validate returns an error if a weight is negative or the scale doesn't
+// parse.
+func (p Profile) Validate() error {
+	if p.RecencyBoost < 0 || p.FrequencyWeight < 0 || p.PinnedBonus < 0 {
+		return fmt.Errorf("ranking weights must be non-negative")
+	}
+	if p.RecencyScale != "" {
+		scale, err := retention.ParseDuration(p.RecencyScale)
+		if err != nil {
+			return fmt.Errorf("invalid recency_scale %q: %w", p.RecencyScale, err)
+		}
+		if scale <= 0 {
+			return fmt.Errorf("recency_scale must be positive")
+		}
+	}
+	for typ, boost := range p.TypeBoosts {
+		if typ == "" {
+			return fmt.Errorf("type boost with an empty type")
+		}
+		if boost < 0 {
+			return fmt.Errorf("boost for type %q must be non-negative", typ)
+		}
+	}
+	return nil
+}
+
+// Empty reports whether the profile changes nothing.
+func (p Profile) Empty() bool {
+	return p.RecencyBoost == 0 && p.FrequencyWeight == 0 && len(p.TypeBoosts) == 0 && p.PinnedBonus == 0
+}
+
+// Scale returns the half-life of the recency boost.
+func (p Profile) Scale() time.Duration {
+	if scale, err := retention.ParseDuration(p.RecencyScale); err == nil && p.RecencyScale != "" {
+		return scale
+	}
+	return DefaultRecencyScale
+}
+
+// Resolve picks the profile that applies to agent: its own if it has one,
+// else the collection's, stored under "". An agent's profile replaces the
+// collection's rather than adding to it. It returns the key it chose, and
+// false if neither exists.
+func Resolve(profiles map[string]Profile, agent string) (Profile, string, bool) {
+	if p, ok := profiles[agent]; ok && agent != "" {
+		return p, agent, true
+	}
+	p, ok := profiles[""]
+	return p, "", ok
+}
+
+// ApplyFrequency adds up to weight to each result's score by how often it
+// has been recalled, and re-sorts by the adjusted score.
+func ApplyFrequency(results []store.Result, weight float64) {
+	if weight <= 0 {
+		return
+	}
+	for i := range results {
+		n := float64(store.AccessCount(results[i].Payload))
+		results[i].Score += float32(weight * n / (n + FrequencyHalf))
+	}
+	sortByScore(results)
+}
+
+// ApplyBoosts adds each result's type boost, and the pinned bonus if it is
+// pinned, to its score and re-sorts by the adjusted score.
+func ApplyBoosts(results []store.Result, typeBoosts map[string]float64, pinnedBonus float64) {
+	if len(typeBoosts) == 0 && pinnedBonus <= 0 {
+		return
+	}
+	for i := range results {
+		boost := typeBoosts[retention.TypeOf(results[i].Payload)]
+		if pinned, _ := results[i].Payload["pinned"].(bool); pinned {
+			boost += pinnedBonus
+		}
+		results[i].Score += float32(boost)
+	}
+	sortByScore(results)
+}
+
+func sortByScore(results []store.Result) {
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+}
+
+// ParseTypeBoost parses a type boost like "todo=0.05".
+func ParseTypeBoost(spec string) (string, float64, error) {
+	typ, value, ok := strings.Cut(spec, "=")
+	typ = strings.TrimSpace(typ)
+	if !ok || typ == "" {
+		return "", 0, fmt.Errorf("invalid type boost %q: expected TYPE=BOOST", spec)
+	}
+	boost, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || boost < 0 {
+		return "", 0, fmt.Errorf("invalid type boost %q: BOOST must be a non-negative number", spec)
+	}
+	return typ, boost, nil
+}
+
+// ParseBoost parses a search boost like "type:todo=0.05". Types are the
+// only thing a boost can target so far; the prefix leaves room for more.
+func ParseBoost(spec string) (string, float64, error) {
+	target, value, ok := strings.Cut(spec, "=")
+	if !ok {
+		return "", 0, fmt.Errorf("invalid boost %q: expected type:TYPE=BOOST", spec)
+	}
+	typ, err := BoostType(target)
+	if err != nil {
+		return "", 0, err
+	}
+	boost, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || boost < 0 {
+		return "", 0, fmt.Errorf("invalid boost %q: BOOST must be a non-negative number", spec)
+	}
+	return typ, boost, nil
+}
+
+// BoostType returns the memory type a boost target like "type:todo" names.
+func BoostType(target string) (string, error) {
+	kind, typ, ok := strings.Cut(strings.TrimSpace(target), ":")
+	typ = strings.ToLower(strings.TrimSpace(typ))
+	if !ok || kind != "type" || typ == "" {
+		return "", fmt.Errorf("invalid boost target %q: expected type:TYPE", target)
+	}
+	return typ, nil
+}
@@ -0,0 +1,103 @@
+package ranking
+
+import (
+	"math"
+	"testing"
+
+	"github.com/hsk-coder/clawbrain/internal/retention"
+	"github.com/hsk-coder/clawbrain/internal/store"
+)
+
+func TestProfileValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile Profile
+		wantErr bool
+	}{
+		{"empty", Profile{}, false},
+		{"all weights", Profile{RecencyBoost: 0.05, RecencyScale: "3d", FrequencyWeight: 0.1, TypeBoosts: map[string]float64{"todo": 0.05}, PinnedBonus: 0.02}, false},
+		{"negative weight", Profile{FrequencyWeight: -0.1}, true},
+		{"bad scale", Profile{RecencyBoost: 0.05, RecencyScale: "soon"}, true},
+		{"negative type boost", Profile{TypeBoosts: map[string]float64{"todo": -1}}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.profile.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+	if got := (Profile{RecencyScale: "3d"}).Scale(); got != 3*retention.Day {
+		t.Errorf("Scale() = %v, want 3 days", got)
+	}
+	if got := (Profile{}).Scale(); got != DefaultRecencyScale {
+		t.Errorf("Scale() without a scale = %v, want the default", got)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	profiles := map[string]Profile{
+		"":      {PinnedBonus: 0.1},
+		"alice": {FrequencyWeight: 0.2},
+	}
+	if p, key, ok := Resolve(profiles, "alice"); !ok || key != "alice" || p.FrequencyWeight != 0.2 || p.PinnedBonus != 0 {
+		t.Errorf("expected alice's own profile, replacing the collection's, got %+v %q %v", p, key, ok)
+	}
+	if p, key, ok := Resolve(profiles, "bob"); !ok || key != "" || p.PinnedBonus != 0.1 {
+		t.Errorf("expected the collection's profile for bob, got %+v %q %v", p, key, ok)
+	}
+	if _, _, ok := Resolve(map[string]Profile{"alice": {}}, ""); ok {
+		t.Error("expected no profile for the collection")
+	}
+}
+
+func TestApplyFrequency(t *testing.T) {
+	results := []store.Result{
+		{ID: "never", Score: 0.80, Payload: map[string]any{}},
+		{ID: "often", Score: 0.75, Payload: map[string]any{store.AccessCountField: int64(5)}},
+	}
+	ApplyFrequency(results, 0.2)
+	if results[0].ID != "often" {
+		t.Fatalf("expected the often recalled memory first, got %s", results[0].ID)
+	}
+	// Five recalls earn half the weight.
+	if math.Abs(float64(results[0].Score)-0.85) > 1e-6 {
+		t.Errorf("expected 0.75 + 0.1, got %v", results[0].Score)
+	}
+	if results[1].Score != 0.80 {
+		t.Errorf("expected a memory never recalled to keep its score, got %v", results[1].Score)
+	}
+}
+
+func TestApplyBoosts(t *testing.T) {
+	results := []store.Result{
+		{ID: "fact", Score: 0.80, Payload: map[string]any{"type": "fact"}},
+		{ID: "todo", Score: 0.78, Payload: map[string]any{"type": "todo"}},
+		{ID: "pinned", Score: 0.70, Payload: map[string]any{"pinned": true}},
+	}
+	ApplyBoosts(results, map[string]float64{"todo": 0.05, Untyped: 0.05}, 0.1)
+	order := []string{results[0].ID, results[1].ID, results[2].ID}
+	if order[0] != "pinned" || order[1] != "todo" || order[2] != "fact" {
+		t.Errorf("expected pinned (untyped), todo, fact, got %v", order)
+	}
+}
+
+func TestParseTypeBoost(t *testing.T) {
+	if typ, boost, err := ParseTypeBoost("todo=0.05"); err != nil || typ != "todo" || boost != 0.05 {
+		t.Errorf("ParseTypeBoost(todo=0.05) = %q, %v, %v", typ, boost, err)
+	}
+	for _, bad := range []string{"todo", "=0.1", "todo=high", "todo=-1"} {
+		if _, _, err := ParseTypeBoost(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestParseBoost(t *testing.T) {
+	if typ, boost, err := ParseBoost("type:Todo=0.05"); err != nil || typ != "todo" || boost != 0.05 {
+		t.Errorf("ParseBoost(type:Todo=0.05) = %q, %v, %v", typ, boost, err)
+	}
+	for _, bad := range []string{"todo=0.05", "tag:ops=0.05", "type:=0.05", "type:todo", "type:todo=-1"} {
+		if _, _, err := ParseBoost(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
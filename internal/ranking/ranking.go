@@ -0,0 +1,73 @@
+// Package ranking adjusts the similarity scores returned by the vector store
+// with query-time signals, such as how old a memory is. It works on results
+// already fetched from the store and never writes back.
+package ranking
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/hsk-coder/clawbrain/internal/retention"
+	"github.com/hsk-coder/clawbrain/internal/store"
+)
+
+// CandidateFactor is how many more candidates than requested a caller should
+// fetch before re-ranking, so memories that climb after adjustment are in the
+// pool to begin with.
+const CandidateFactor = 4
+
+// DefaultRecencyScale is the half-life of a recency boost when none is given:
+// a memory recalled a week ago gets half the boost of one recalled today.
+const DefaultRecencyScale = 7 * retention.Day
+
+// AgeDecay returns the exponential decay factor for a memory of the given age:
+// 1 at age zero, 0.5 at one half-life, 0.25 at two. A non-positive half-life
+// or age means no decay.
+func AgeDecay(age, halfLife time.Duration) float64 {
+	if halfLife <= 0 || age <= 0 {
+		return 1
+	}
+	return math.Pow(0.5, float64(age)/float64(halfLife))
+}
+
+// ApplyHalfLife scales each result's score by the age decay of its created_at
+// and re-sorts by the adjusted score. Results without a created_at keep their
+// score. Age is measured from creation, not last access, so genuinely old
+// information ranks below fresh equivalents no matter how often it's recalled.
+func ApplyHalfLife(results []store.Result, halfLife time.Duration, now time.Time) {
+	if halfLife <= 0 {
+		return
+	}
+	for i := range results {
+		created, ok := retention.CreatedAt(results[i].Payload)
+		if !ok {
+			continue
+		}
+		results[i].Score = float32(float64(results[i].Score) * AgeDecay(now.Sub(created), halfLife))
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+}
+
+// ApplyRecencyBoost adds up to boost to each result's score, decaying with
+// the time since its last_accessed at a half-life of scale, and re-sorts by
+// the adjusted score. Results without a last_accessed get no boost. Unlike
+// ApplyHalfLife it adds rather than scales, so a small boost only reorders
+// memories whose similarities are close and never buries a much better match.
+func ApplyRecencyBoost(results []store.Result, boost float64, scale time.Duration, now time.Time) {
+	if boost <= 0 {
+		return
+	}
+	for i := range results {
+		accessed, ok := retention.LastAccessed(results[i].Payload)
+		if !ok {
+			continue
+		}
+		results[i].Score += float32(boost * AgeDecay(now.Sub(accessed), scale))
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+}
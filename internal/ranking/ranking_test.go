@@ -0,0 +1,81 @@
+package ranking
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/hsk-coder/clawbrain/internal/retention"
+	"github.com/hsk-coder/clawbrain/internal/store"
+)
+
+func TestAgeDecay(t *testing.T) {
+	tests := []struct {
+		name     string
+		age      time.Duration
+		halfLife time.Duration
+		want     float64
+	}{
+		{"fresh", 0, 30 * retention.Day, 1},
+		{"one half-life", 30 * retention.Day, 30 * retention.Day, 0.5},
+		{"two half-lives", 60 * retention.Day, 30 * retention.Day, 0.25},
+		{"no half-life", 60 * retention.Day, 0, 1},
+		{"future timestamp", -retention.Day, 30 * retention.Day, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := AgeDecay(tt.age, tt.halfLife); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("AgeDecay(%v, %v) = %v, want %v", tt.age, tt.halfLife, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyHalfLife(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	created := func(age time.Duration) string { return now.Add(-age).Format(time.RFC3339Nano) }
+
+	results := []store.Result{
+		{ID: "old", Score: 0.9, Payload: map[string]any{"created_at": created(60 * retention.Day)}},
+		{ID: "fresh", Score: 0.8, Payload: map[string]any{"created_at": created(0)}},
+		{ID: "undated", Score: 0.5, Payload: map[string]any{}},
+	}
+
+	ApplyHalfLife(results, 30*retention.Day, now)
+
+	order := []string{results[0].ID, results[1].ID, results[2].ID}
+	if order[0] != "fresh" || order[1] != "undated" || order[2] != "old" {
+		t.Fatalf("expected fresh, undated, old; got %v", order)
+	}
+	if math.Abs(float64(results[2].Score)-0.225) > 1e-6 {
+		t.Errorf("expected old score 0.9*0.25=0.225, got %v", results[2].Score)
+	}
+	if results[1].Score != 0.5 {
+		t.Errorf("expected undated score unchanged, got %v", results[1].Score)
+	}
+}
+
+func TestApplyRecencyBoost(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	accessed := func(age time.Duration) string { return now.Add(-age).Format(time.RFC3339Nano) }
+
+	results := []store.Result{
+		{ID: "stale", Score: 0.81, Payload: map[string]any{"last_accessed": accessed(70 * retention.Day)}},
+		{ID: "recent", Score: 0.80, Payload: map[string]any{"last_accessed": accessed(0)}},
+		{ID: "week-old", Score: 0.79, Payload: map[string]any{"last_accessed": accessed(7 * retention.Day)}},
+		{ID: "better", Score: 0.95, Payload: map[string]any{"last_accessed": accessed(70 * retention.Day)}},
+	}
+
+	ApplyRecencyBoost(results, 0.05, 7*retention.Day, now)
+
+	order := []string{results[0].ID, results[1].ID, results[2].ID, results[3].ID}
+	if order[0] != "better" || order[1] != "recent" || order[2] != "week-old" || order[3] != "stale" {
+		t.Fatalf("expected better, recent, week-old, stale; got %v", order)
+	}
+	if math.Abs(float64(results[1].Score)-0.85) > 1e-6 {
+		t.Errorf("expected recent score 0.80+0.05=0.85, got %v", results[1].Score)
+	}
+	if math.Abs(float64(results[2].Score)-0.815) > 1e-6 {
+		t.Errorf("expected week-old score 0.79+0.025=0.815, got %v", results[2].Score)
+	}
+}
@@ -0,0 +1,101 @@
+package ranking
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hsk-coder/clawbrain/internal/store"
+)
+
+// Special type names accepted in a per-type limit spec.
+const (
+	// OtherTypes stands for every type not listed elsewhere in the spec.
+	OtherTypes = "*"
+	// Untyped stands for memories without a type.
+	Untyped = "untyped"
+)
+
+// TypeLimit caps how many results of one memory type a search returns.
+type TypeLimit struct {
+	Type  string `json:"type"`
+	Limit uint64 `json:"limit"`
+}
+
+// ParseTypeLimits parses a spec like "todo=2,lesson=2,fact=3". Types not
+// listed are excluded unless the spec includes "*=N" for them.
+func ParseTypeLimits(spec string) ([]TypeLimit, error) {
+	var out []TypeLimit
+	seen := map[string]bool{}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		typ, n, ok := strings.Cut(part, "=")
+		typ = strings.TrimSpace(typ)
+		if !ok || typ == "" {
+			return nil, fmt.Errorf("invalid per-type limit %q: expected TYPE=N", part)
+		}
+		limit, err := strconv.ParseUint(strings.TrimSpace(n), 10, 64)
+		if err != nil || limit == 0 {
+			return nil, fmt.Errorf("invalid per-type limit %q: N must be a positive integer", part)
+		}
+		if seen[typ] {
+			return nil, fmt.Errorf("type %q listed more than once", typ)
+		}
+		seen[typ] = true
+		out = append(out, TypeLimit{Type: typ, Limit: limit})
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("per-type limit spec is empty")
+	}
+	return out, nil
+}
+
+// Total returns the sum of all limits — the most results a per-type search
+// can return.
+func Total(limits []TypeLimit) uint64 {
+	var n uint64
+	for _, l := range limits {
+		n += l.Limit
+	}
+	return n
+}
+
+// FilterFor returns the store filter selecting the memories one limit applies
+// to. For OtherTypes that is every type not listed in limits.
+func FilterFor(l TypeLimit, limits []TypeLimit) store.Filter {
+	if l.Type != OtherTypes {
+		return store.Filter{Types: []string{storeType(l.Type)}}
+	}
+	var exclude []string
+	for _, other := range limits {
+		if other.Type != OtherTypes {
+			exclude = append(exclude, storeType(other.Type))
+		}
+	}
+	return store.Filter{ExcludeTypes: exclude}
+}
+
+// storeType maps the Untyped label to the store's "" untyped marker.
+func storeType(t string) string {
+	if t == Untyped {
+		return ""
+	}
+	return t
+}
+
+// MergeByScore combines per-type result sets into a single list ordered by
+// score, highest first.
+func MergeByScore(sets ...[]store.Result) []store.Result {
+	out := []store.Result{}
+	for _, set := range sets {
+		out = append(out, set...)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Score > out[j].Score
+	})
+	return out
+}
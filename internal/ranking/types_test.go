@@ -0,0 +1,60 @@
+package ranking
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hsk-coder/clawbrain/internal/store"
+)
+
+func TestParseTypeLimits(t *testing.T) {
+	got, err := ParseTypeLimits("todo=2, lesson=2,fact=3,*=1")
+	if err != nil {
+		t.Fatalf("ParseTypeLimits failed: %v", err)
+	}
+	want := []TypeLimit{{"todo", 2}, {"lesson", 2}, {"fact", 3}, {"*", 1}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if Total(got) != 8 {
+		t.Errorf("expected total 8, got %d", Total(got))
+	}
+
+	for _, bad := range []string{"", "todo", "todo=0", "todo=x", "=2", "todo=1,todo=2"} {
+		if _, err := ParseTypeLimits(bad); err == nil {
+			t.Errorf("ParseTypeLimits(%q) should fail", bad)
+		}
+	}
+}
+
+func TestFilterFor(t *testing.T) {
+	limits := []TypeLimit{{"todo", 2}, {Untyped, 1}, {OtherTypes, 3}}
+
+	if f := FilterFor(limits[0], limits); !reflect.DeepEqual(f, store.Filter{Types: []string{"todo"}}) {
+		t.Errorf("unexpected todo filter: %+v", f)
+	}
+	if f := FilterFor(limits[1], limits); !reflect.DeepEqual(f, store.Filter{Types: []string{""}}) {
+		t.Errorf("untyped should map to the empty type, got %+v", f)
+	}
+	if f := FilterFor(limits[2], limits); !reflect.DeepEqual(f, store.Filter{ExcludeTypes: []string{"todo", ""}}) {
+		t.Errorf("* should exclude every listed type, got %+v", f)
+	}
+}
+
+func TestMergeByScore(t *testing.T) {
+	merged := MergeByScore(
+		[]store.Result{{ID: "a", Score: 0.5}, {ID: "b", Score: 0.2}},
+		[]store.Result{{ID: "c", Score: 0.9}},
+		nil,
+	)
+	var ids []string
+	for _, r := range merged {
+		ids = append(ids, r.ID)
+	}
+	if !reflect.DeepEqual(ids, []string{"c", "a", "b"}) {
+		t.Errorf("expected c, a, b; got %v", ids)
+	}
+	if MergeByScore() == nil {
+		t.Error("expected non-nil empty slice")
+	}
+}
@@ -0,0 +1,88 @@
+package redis
+
+// Match reports whether key matches a glob pattern the way SCAN's MATCH
+// does: "*" matches any run of characters, "?" any one, "[abc]" and "[a-z]"
+// one of a set ("[^a]" one outside it), and "\" makes the next character
+// literal. It lets stores that keep sync state somewhere other than Redis
+// answer the same patterns.
+func Match(pattern, key string) bool {
+	p, k := []rune(pattern), []rune(key)
+	for len(p) > 0 {
+		switch p[0] {
+		case '*':
+			for len(p) > 0 && p[0] == '*' {
+				p = p[1:]
+			}
+			if len(p) == 0 {
+				return true
+			}
+			for i := range len(k) + 1 {
+				if Match(string(p), string(k[i:])) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(k) == 0 {
+				return false
+			}
+		case '[':
+			if len(k) == 0 {
+				return false
+			}
+			matched, rest, ok := matchClass(p[1:], k[0])
+			if !ok {
+				// An unclosed "[" is literal.
+				if k[0] != '[' {
+					return false
+				}
+				break
+			}
+			if !matched {
+				return false
+			}
+			p, k = rest, k[1:]
+			continue
+		case '\\':
+			if len(p) > 1 {
+				p = p[1:]
+			}
+			fallthrough
+		default:
+			if len(k) == 0 || p[0] != k[0] {
+				return false
+			}
+		}
+		p, k = p[1:], k[1:]
+	}
+	return len(k) == 0
+}
+
+// matchClass matches r against the set that p starts with, just after its
+// "[", and returns the pattern after the closing "]". ok is false if the
+// set is never closed.
+func matchClass(p []rune, r rune) (matched bool, rest []rune, ok bool) {
+	negate := len(p) > 0 && p[0] == '^'
+	if negate {
+		p = p[1:]
+	}
+	for i := 0; i < len(p); i++ {
+		switch {
+		case p[i] == ']':
+			return matched != negate, p[i+1:], true
+		case p[i] == '\\' && i+1 < len(p):
+			i++
+			matched = matched || p[i] == r
+		case i+2 < len(p) && p[i+1] == '-' && p[i+2] != ']':
+			lo, hi := p[i], p[i+2]
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			matched = matched || lo <= r && r <= hi
+			i += 2
+		default:
+			matched = matched || p[i] == r
+		}
+	}
+	return false, nil, false
+}
@@ -0,0 +1,33 @@
+package redis
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern, key string
+		want         bool
+	}{
+		{"sync:*", "sync:/notes/a.md", true},
+		{"sync:*", "syncrun:run", false},
+		{"sync@work:*", "sync@work:/a.md", true},
+		{"sync:/notes*", "sync:/notes-old/a.md", true},
+		{"sync:/a?.md", "sync:/ab.md", true},
+		{"sync:/a?.md", "sync:/a.md", false},
+		{"sync:/[ab].md", "sync:/b.md", true},
+		{"sync:/[^ab].md", "sync:/b.md", false},
+		{"sync:/[a-c].md", "sync:/c.md", true},
+		{`sync:/\*.md`, "sync:/*.md", true},
+		{`sync:/\*.md`, "sync:/x.md", false},
+		{`sync:/\[draft\]*`, "sync:/[draft] plan.md", true},
+		{"sync:/[draft", "sync:/[draft", true},
+		{"", "", true},
+		{"*", "", true},
+		{"a*b*c", "aXbYc", true},
+		{"a*b*c", "aXbY", false},
+	}
+	for _, tt := range tests {
+		if got := Match(tt.pattern, tt.key); got != tt.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tt.pattern, tt.key, got, tt.want)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 // Package redis provides a minimal Redis client using the RESP protocol.
-// It supports only the commands needed by ClawBrain's sync feature:
-// SET, EXISTS, and SET with EX (TTL). No external dependencies.
+// It supports only the commands needed by ClawBrain: SET, GET, EXISTS,
+// SET with EX (TTL), and SCAN/RENAME for sync state, plus DEL, EXPIRE and
+// the set commands SADD/SREM/SMEMBERS for the search cache's invalidation
+// index. No external dependencies.
 package redis
 
 import (
@@ -9,6 +11,7 @@ import (
 	"io"
 	"net"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -42,6 +45,23 @@ func (c *Client) Ping() error {
 	return err
 }
 
+// ServerVersion returns the version of the Redis server, as INFO reports it.
+func (c *Client) ServerVersion() (string, error) {
+	if err := c.sendCommand("INFO", "server"); err != nil {
+		return "", err
+	}
+	info, err := c.readBulk()
+	if err != nil {
+		return "", err
+	}
+	for line := range strings.SplitSeq(info, "\n") {
+		if v, ok := strings.CutPrefix(strings.TrimSpace(line), "redis_version:"); ok {
+			return v, nil
+		}
+	}
+	return "", fmt.Errorf("INFO reply has no redis_version")
+}
+
 // Set stores a key with a value and no expiry.
 func (c *Client) Set(key, value string) error {
 	if err := c.sendCommand("SET", key, value); err != nil {
@@ -60,6 +80,21 @@ func (c *Client) SetWithTTL(key, value string, ttlSeconds int) error {
 	return err
 }
 
+// SetNX stores a key with a value and a TTL in seconds only if the key
+// isn't set, and reports whether it was. It is atomic, so processes can
+// take a lock with it.
+func (c *Client) SetNX(key, value string, ttlSeconds int) (bool, error) {
+	if err := c.sendCommand("SET", key, value, "NX", "EX", strconv.Itoa(ttlSeconds)); err != nil {
+		return false, err
+	}
+	line, err := c.readLine()
+	if err != nil {
+		return false, err
+	}
+	// "+OK" if set, a null bulk string if the key was already there.
+	return line == "+OK", nil
+}
+
 // Get retrieves the value of a key. Returns ("", false, nil) if the key
 // does not exist.
 func (c *Client) Get(key string) (string, bool, error) {
@@ -107,6 +142,170 @@ func (c *Client) Exists(key string) (bool, error) {
 	return false, fmt.Errorf("unexpected EXISTS reply: %q", line)
 }
 
+// Scan returns every key matching a glob pattern, iterating SCAN cursors
+// until the server reports completion. Unlike KEYS, SCAN does not block
+// the server on large keyspaces.
+func (c *Client) Scan(pattern string) ([]string, error) {
+	var keys []string
+	cursor := "0"
+	for {
+		if err := c.sendCommand("SCAN", cursor, "MATCH", pattern, "COUNT", "100"); err != nil {
+			return nil, err
+		}
+		// Reply: *2 / $<len> cursor / *<n> / $<len> key ...
+		line, err := c.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if line != "*2" {
+			return nil, fmt.Errorf("unexpected SCAN reply: %q", line)
+		}
+		next, err := c.readBulk()
+		if err != nil {
+			return nil, err
+		}
+		line, err = c.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if len(line) < 2 || line[0] != '*' {
+			return nil, fmt.Errorf("unexpected SCAN reply: %q", line)
+		}
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("unexpected SCAN reply: %q", line)
+		}
+		for i := 0; i < n; i++ {
+			key, err := c.readBulk()
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, key)
+		}
+		if next == "0" {
+			return keys, nil
+		}
+		cursor = next
+	}
+}
+
+// Rename moves a key to a new name, keeping its value and TTL.
+func (c *Client) Rename(key, newKey string) error {
+	if err := c.sendCommand("RENAME", key, newKey); err != nil {
+		return err
+	}
+	_, err := c.readLine()
+	return err
+}
+
+// Del deletes keys and returns how many existed.
+func (c *Client) Del(keys ...string) (int, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	if err := c.sendCommand(append([]string{"DEL"}, keys...)...); err != nil {
+		return 0, err
+	}
+	return c.readInt()
+}
+
+// Expire sets a key's TTL in seconds.
+func (c *Client) Expire(key string, ttlSeconds int) error {
+	if err := c.sendCommand("EXPIRE", key, strconv.Itoa(ttlSeconds)); err != nil {
+		return err
+	}
+	_, err := c.readInt()
+	return err
+}
+
+// SAdd adds members to the set stored at key.
+func (c *Client) SAdd(key string, members ...string) error {
+	if len(members) == 0 {
+		return nil
+	}
+	if err := c.sendCommand(append([]string{"SADD", key}, members...)...); err != nil {
+		return err
+	}
+	_, err := c.readInt()
+	return err
+}
+
+// SRem removes members from the set stored at key.
+func (c *Client) SRem(key string, members ...string) error {
+	if len(members) == 0 {
+		return nil
+	}
+	if err := c.sendCommand(append([]string{"SREM", key}, members...)...); err != nil {
+		return err
+	}
+	_, err := c.readInt()
+	return err
+}
+
+// SMembers returns every member of the set stored at key. A missing key is
+// an empty set.
+func (c *Client) SMembers(key string) ([]string, error) {
+	if err := c.sendCommand("SMEMBERS", key); err != nil {
+		return nil, err
+	}
+	line, err := c.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 2 || line[0] != '*' {
+		return nil, fmt.Errorf("unexpected SMEMBERS reply: %q", line)
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil {
+		return nil, fmt.Errorf("unexpected SMEMBERS reply: %q", line)
+	}
+	members := make([]string, 0, max(n, 0))
+	for i := 0; i < n; i++ {
+		m, err := c.readBulk()
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	return members, nil
+}
+
+// readInt reads a RESP integer reply (":<n>\r\n").
+func (c *Client) readInt() (int, error) {
+	line, err := c.readLine()
+	if err != nil {
+		return 0, err
+	}
+	if len(line) < 2 || line[0] != ':' {
+		return 0, fmt.Errorf("expected integer reply, got %q", line)
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil {
+		return 0, fmt.Errorf("unexpected integer reply: %q", line)
+	}
+	return n, nil
+}
+
+// readBulk reads a RESP bulk string ("$<len>\r\n<data>\r\n").
+func (c *Client) readBulk() (string, error) {
+	line, err := c.readLine()
+	if err != nil {
+		return "", err
+	}
+	if len(line) < 2 || line[0] != '$' {
+		return "", fmt.Errorf("expected bulk string, got %q", line)
+	}
+	length, err := strconv.Atoi(line[1:])
+	if err != nil || length < 0 {
+		return "", fmt.Errorf("unexpected bulk length: %q", line)
+	}
+	data := make([]byte, length+2) // +2 for trailing \r\n
+	if _, err := io.ReadFull(c.rd, data); err != nil {
+		return "", err
+	}
+	return string(data[:length]), nil
+}
+
 // sendCommand writes a RESP array command to the connection.
 func (c *Client) sendCommand(args ...string) error {
 	// RESP array: *<count>\r\n followed by $<len>\r\n<data>\r\n for each arg
@@ -1,7 +1,9 @@
 package redis
 
 import (
+	"bufio"
 	"net"
+	"sort"
 	"testing"
 	"time"
 )
@@ -28,6 +30,24 @@ func TestPing(t *testing.T) {
 	}
 }
 
+func TestServerVersion(t *testing.T) {
+	skipIfNoRedis(t)
+	c, err := New("localhost", 6379)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	v, err := c.ServerVersion()
+	if err != nil || v == "" {
+		t.Fatalf("ServerVersion = %q, %v", v, err)
+	}
+	// The connection is still in step afterwards.
+	if err := c.Ping(); err != nil {
+		t.Errorf("Ping after ServerVersion failed: %v", err)
+	}
+}
+
 func TestSetAndExists(t *testing.T) {
 	skipIfNoRedis(t)
 	c, err := New("localhost", 6379)
@@ -111,6 +131,29 @@ func TestSetWithTTL(t *testing.T) {
 	}
 }
 
+func TestSetNX(t *testing.T) {
+	skipIfNoRedis(t)
+	c, err := New("localhost", 6379)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	key := "clawbrain_test:set_nx"
+	c.Del(key)
+	defer c.Del(key)
+
+	if set, err := c.SetNX(key, "first", 60); err != nil || !set {
+		t.Fatalf("expected the first SetNX to set the key, got %v (err=%v)", set, err)
+	}
+	if set, err := c.SetNX(key, "second", 60); err != nil || set {
+		t.Fatalf("expected the second SetNX to leave the key, got %v (err=%v)", set, err)
+	}
+	if value, _, _ := c.Get(key); value != "first" {
+		t.Errorf("expected the first value kept, got %q", value)
+	}
+}
+
 func TestGetAndSet(t *testing.T) {
 	skipIfNoRedis(t)
 	c, err := New("localhost", 6379)
@@ -172,6 +215,143 @@ func TestGetAndSet(t *testing.T) {
 	c.readLine()
 }
 
+func TestScanAndRename(t *testing.T) {
+	skipIfNoRedis(t)
+	c, err := New("localhost", 6379)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	keys := []string{"clawbrain_test:scan:/old/a.md", "clawbrain_test:scan:/old/b.md"}
+	moved := "clawbrain_test:scan:/new/a.md"
+	cleanup := func() {
+		for _, k := range append(keys, moved) {
+			c.sendCommand("DEL", k)
+			c.readLine()
+		}
+	}
+	cleanup()
+	defer cleanup()
+
+	for _, k := range keys {
+		if err := c.SetWithTTL(k, "1", 60); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	found, err := c.Scan("clawbrain_test:scan:/old/*")
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if len(found) != 2 {
+		t.Fatalf("expected 2 keys, got %v", found)
+	}
+
+	if err := c.Rename(keys[0], moved); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	exists, err := c.Exists(moved)
+	if err != nil || !exists {
+		t.Fatalf("expected renamed key to exist (err=%v)", err)
+	}
+	exists, err = c.Exists(keys[0])
+	if err != nil || exists {
+		t.Fatalf("expected old key to be gone (err=%v)", err)
+	}
+
+	if err := c.Rename("clawbrain_test:scan:missing", moved); err == nil {
+		t.Fatal("expected error renaming a missing key")
+	}
+}
+
+func TestSetsDelAndExpire(t *testing.T) {
+	skipIfNoRedis(t)
+	c, err := New("localhost", 6379)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	set := "clawbrain_test:set"
+	plain := "clawbrain_test:plain"
+	c.Del(set, plain)
+	defer c.Del(set, plain)
+
+	members, err := c.SMembers(set)
+	if err != nil || len(members) != 0 {
+		t.Fatalf("expected empty set for missing key, got %v, %v", members, err)
+	}
+
+	if err := c.SAdd(set, "a", "b", "c"); err != nil {
+		t.Fatalf("SAdd failed: %v", err)
+	}
+	if err := c.SRem(set, "b"); err != nil {
+		t.Fatalf("SRem failed: %v", err)
+	}
+	members, err = c.SMembers(set)
+	if err != nil {
+		t.Fatalf("SMembers failed: %v", err)
+	}
+	sort.Strings(members)
+	if len(members) != 2 || members[0] != "a" || members[1] != "c" {
+		t.Fatalf("expected [a c], got %v", members)
+	}
+
+	if err := c.Set(plain, "v"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Expire(plain, 1); err != nil {
+		t.Fatalf("Expire failed: %v", err)
+	}
+	time.Sleep(1100 * time.Millisecond)
+	if exists, _ := c.Exists(plain); exists {
+		t.Error("expected key to expire")
+	}
+
+	n, err := c.Del(set, plain)
+	if err != nil {
+		t.Fatalf("Del failed: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 key deleted (the set), got %d", n)
+	}
+}
+
+// TestScanMultipleCursors drives Scan against a scripted server to verify it
+// follows cursors until the server returns 0.
+func TestScanMultipleCursors(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	c := &Client{conn: client, rd: bufio.NewReader(client)}
+	defer c.Close()
+
+	replies := []string{
+		"*2\r\n$2\r\n17\r\n*2\r\n$6\r\nsync:a\r\n$6\r\nsync:b\r\n",
+		"*2\r\n$1\r\n0\r\n*1\r\n$6\r\nsync:c\r\n",
+	}
+	go func() {
+		rd := bufio.NewReader(server)
+		for _, reply := range replies {
+			// Each SCAN command is an array of 6 bulk strings: consume 13 lines.
+			for i := 0; i < 13; i++ {
+				if _, err := rd.ReadString('\n'); err != nil {
+					return
+				}
+			}
+			server.Write([]byte(reply))
+		}
+	}()
+
+	keys, err := c.Scan("sync:*")
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if len(keys) != 3 || keys[0] != "sync:a" || keys[2] != "sync:c" {
+		t.Fatalf("expected [sync:a sync:b sync:c], got %v", keys)
+	}
+}
+
 func TestConnectionError(t *testing.T) {
 	// Connect to a port that's definitely not Redis
 	_, err := New("localhost", 1)
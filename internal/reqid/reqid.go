@@ -0,0 +1,83 @@
+// Package reqid gives each clawbrain command or served request an ID that
+// travels with its calls to Qdrant and Ollama, its log lines and its JSON
+// response, so one failed memory operation can be followed across every
+// service it touched.
+package reqid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+)
+
+// Header carries the ID on HTTP requests and responses: calls to Ollama and
+// other embedders, and requests to and answers from clawbrain serve.
+const Header = "X-Request-Id"
+
+// MetadataKey carries the ID in the gRPC metadata of calls to Qdrant.
+const MetadataKey = "x-request-id"
+
+// Field is the key of the ID in JSON responses.
+const Field = "request_id"
+
+// valid is what an ID handed in by a caller may look like: short enough for
+// a log line and free of anything that can't go in a header.
+var valid = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// New returns a new random ID: 16 hex digits.
+func New() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Valid reports whether id, as given by a caller, can be used as an ID.
+func Valid(id string) bool {
+	return valid.MatchString(id)
+}
+
+type key struct{}
+
+// defaultID is the ID of calls whose context doesn't carry one.
+var defaultID string
+
+// SetDefault makes id the ID of every call whose context doesn't carry one:
+// the CLI runs one command per process, so its ID is the process's.
+func SetDefault(id string) {
+	defaultID = id
+}
+
+// With returns a context carrying id.
+func With(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, key{}, id)
+}
+
+// From returns the ID ctx carries, or the default ID if it carries none.
+func From(ctx context.Context) string {
+	if id, ok := ctx.Value(key{}).(string); ok {
+		return id
+	}
+	return defaultID
+}
+
+// Transport is an http.RoundTripper that sends the ID of each request's
+// context in the Header, for the clients of HTTP services.
+type Transport struct {
+	// Base makes the requests; http.DefaultTransport if nil.
+	Base http.RoundTripper
+}
+
+// RoundTrip sends req with its ID.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if id := From(req.Context()); id != "" && req.Header.Get(Header) == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set(Header, id)
+	}
+	return base.RoundTrip(req)
+}
@@ -0,0 +1,56 @@
+package reqid
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFrom(t *testing.T) {
+	defer SetDefault("")
+	SetDefault("cli-run")
+	if got := From(context.Background()); got != "cli-run" {
+		t.Errorf("expected the default ID without one in the context, got %q", got)
+	}
+	if got := From(With(context.Background(), "req-1")); got != "req-1" {
+		t.Errorf("expected the context's ID, got %q", got)
+	}
+}
+
+func TestNewAndValid(t *testing.T) {
+	a, b := New(), New()
+	if len(a) != 16 || a == b {
+		t.Errorf("expected distinct 16-digit IDs, got %q and %q", a, b)
+	}
+	for id, want := range map[string]bool{
+		a:                         true,
+		"agent-7:turn.12":         true,
+		"":                        false,
+		"has space":               false,
+		"line\nbreak":             false,
+		string(make([]byte, 129)): false,
+	} {
+		if Valid(id) != want {
+			t.Errorf("Valid(%q) = %v, want %v", id, !want, want)
+		}
+	}
+}
+
+func TestTransport(t *testing.T) {
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get(Header)
+	}))
+	defer srv.Close()
+	client := &http.Client{Transport: &Transport{}}
+	req, _ := http.NewRequestWithContext(With(context.Background(), "req-2"), http.MethodGet, srv.URL, nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got != "req-2" {
+		t.Errorf("expected the ID sent in %s, got %q", Header, got)
+	}
+}
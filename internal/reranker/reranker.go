@@ -0,0 +1,114 @@
+// Package reranker reorders search results by asking a generative model how
+// well each one answers the query. A vector search compares the query and
+// each memory embedded apart, so a memory that shares the query's topic
+// but not its question can outscore the one that answers it; a model that
+// reads the query and the memory together, as a cross-encoder does, tells
+// them apart. It costs one model call per result, so it is for the few
+// dozen best vector matches, not the collection.
+package reranker
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/hsk-coder/clawbrain/internal/store"
+)
+
+// DefaultCandidates is how many vector matches a reranked search scores.
+const DefaultCandidates = 50
+
+// maxTextLength caps, in characters, the memory text put in a prompt. The
+// start of a memory says what it is about; the rest only slows the model.
+const maxTextLength = 2000
+
+// concurrency is how many candidates are scored at once.
+const concurrency = 4
+
+// Generator completes a prompt with a model, as *ollama.Client does.
+type Generator interface {
+	Generate(ctx context.Context, model, prompt string) (string, error)
+}
+
+// Prompt builds the prompt asking a model to grade how well text answers
+// query, from 0 to 10.
+func Prompt(query, text string) string {
+	text = strings.TrimSpace(text)
+	if utf8.RuneCountInString(text) > maxTextLength {
+		text = string([]rune(text)[:maxTextLength]) + "…"
+	}
+	var b strings.Builder
+	b.WriteString("Grade how well the memory answers the query, from 0 (unrelated) to 10 (answers it directly). ")
+	b.WriteString("A memory on the same topic that doesn't answer the query scores low. Reply with the number only.\n\n")
+	fmt.Fprintf(&b, "Query: %s\n", strings.TrimSpace(query))
+	fmt.Fprintf(&b, "Memory: %s\n", text)
+	return b.String()
+}
+
+var number = regexp.MustCompile(`\d+(\.\d+)?`)
+
+// ParseScore reads the grade out of a model's reply and scales it to
+// [0, 1]. Models given to chatter are read by their first number; a reply
+// without one reports false.
+func ParseScore(reply string) (float32, bool) {
+	m := number.FindString(reply)
+	if m == "" {
+		return 0, false
+	}
+	grade, err := strconv.ParseFloat(m, 64)
+	if err != nil {
+		return 0, false
+	}
+	return float32(min(grade, 10) / 10), true
+}
+
+// Rerank grades each of results against query with model and returns them
+// best graded first, with their grades keyed by ID. Results keep their
+// order among equal grades, and their scores, which stay the similarity
+// the search ranked them by. A reply without a grade counts as 0; a failed
+// call fails the whole rerank, leaving results as they were.
+func Rerank(ctx context.Context, gen Generator, model, query string, results []store.Result) ([]store.Result, map[string]float32, error) {
+	grades := make([]float32, len(results))
+	errs := make([]error, len(results))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, r := range results {
+		text, _ := r.Payload["text"].(string)
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			reply, err := gen.Generate(ctx, model, Prompt(query, text))
+			if err != nil {
+				errs[i] = fmt.Errorf("grade %s: %w", r.ID, err)
+				return
+			}
+			grades[i], _ = ParseScore(reply)
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return results, nil, err
+		}
+	}
+
+	order := make([]int, len(results))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return grades[order[a]] > grades[order[b]] })
+	reranked := make([]store.Result, len(results))
+	byID := make(map[string]float32, len(results))
+	for i, j := range order {
+		reranked[i] = results[j]
+		byID[results[j].ID] = grades[j]
+	}
+	return reranked, byID, nil
+}
@@ -0,0 +1,91 @@
+package reranker
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hsk-coder/clawbrain/internal/store"
+)
+
+// gradeByWord grades a memory 9 if it contains the word, 2 otherwise.
+type gradeByWord struct {
+	word string
+	fail bool
+}
+
+func (g gradeByWord) Generate(_ context.Context, _, prompt string) (string, error) {
+	if g.fail {
+		return "", errors.New("model not found")
+	}
+	_, memory, _ := strings.Cut(prompt, "Memory: ")
+	if strings.Contains(memory, g.word) {
+		return "9", nil
+	}
+	return "Score: 2 -- same topic, no answer", nil
+}
+
+func results(texts ...string) []store.Result {
+	out := make([]store.Result, len(texts))
+	for i, text := range texts {
+		out[i] = store.Result{ID: string(rune('a' + i)), Score: 0.9 - float32(i)/10, Payload: map[string]any{"text": text}}
+	}
+	return out
+}
+
+func TestRerank(t *testing.T) {
+	in := results("the staging server is slow", "the staging server lives in frankfurt", "staging deploys need approval")
+	got, grades, err := Rerank(context.Background(), gradeByWord{word: "frankfurt"}, "m", "where is staging?", in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, r := range got {
+		ids = append(ids, r.ID)
+	}
+	// b rises to the top; a and c, graded alike, keep their order.
+	if strings.Join(ids, "") != "bac" {
+		t.Errorf("expected order bac, got %v", ids)
+	}
+	if got[0].Score != in[1].Score {
+		t.Errorf("expected scores kept, got %v", got[0].Score)
+	}
+	if grades["b"] != 0.9 || grades["a"] != 0.2 {
+		t.Errorf("unexpected grades %v", grades)
+	}
+
+	got, _, err = Rerank(context.Background(), gradeByWord{fail: true}, "m", "q", in)
+	if err == nil {
+		t.Fatal("expected a failed call to fail the rerank")
+	}
+	if got[0].ID != "a" {
+		t.Errorf("expected results left as they were, got %v", got)
+	}
+}
+
+func TestParseScore(t *testing.T) {
+	for reply, want := range map[string]float32{
+		"7":                   0.7,
+		" 10\n":               1,
+		"Score: 3.5/10":       0.35,
+		"42, it's very close": 1,
+	} {
+		if got, ok := ParseScore(reply); !ok || got != want {
+			t.Errorf("ParseScore(%q) = %v, %v; want %v", reply, got, ok, want)
+		}
+	}
+	if _, ok := ParseScore("very relevant"); ok {
+		t.Error("expected a reply without a number to fail")
+	}
+}
+
+func TestPromptCapsText(t *testing.T) {
+	prompt := Prompt(" where is staging? ", strings.Repeat("x", 3*maxTextLength))
+	if !strings.Contains(prompt, "Query: where is staging?\n") {
+		t.Errorf("prompt missing query:\n%s", prompt)
+	}
+	if n := strings.Count(prompt, "x"); n != maxTextLength {
+		t.Errorf("expected the text cut to %d characters, got %d", maxTextLength, n)
+	}
+}
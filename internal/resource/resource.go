@@ -0,0 +1,55 @@
+// Package resource names memories, and the views of them a client browses,
+// with URIs: clawbrain://memory/<id> for one memory, clawbrain://recent
+// and clawbrain://pinned for lists. Clients that show memory rather than
+// search it -- a UI, an editor sidebar -- list and read these instead of
+// composing queries.
+package resource
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hsk-coder/clawbrain/internal/store"
+)
+
+// URIs of the lists.
+const (
+	Recent = "clawbrain://recent"
+	Pinned = "clawbrain://pinned"
+)
+
+// MemoryTemplate is the URI template of a memory, in RFC 6570 form.
+const MemoryTemplate = memoryPrefix + "{id}"
+
+const memoryPrefix = "clawbrain://memory/"
+
+// Kind is what a URI names.
+type Kind string
+
+const (
+	KindMemory Kind = "memory"
+	KindRecent Kind = "recent"
+	KindPinned Kind = "pinned"
+)
+
+// Memory returns the URI of the memory with id.
+func Memory(id string) string {
+	return memoryPrefix + id
+}
+
+// Parse returns what uri names and, for a memory, its ID.
+func Parse(uri string) (Kind, string, error) {
+	switch uri {
+	case Recent:
+		return KindRecent, "", nil
+	case Pinned:
+		return KindPinned, "", nil
+	}
+	if id, ok := strings.CutPrefix(uri, memoryPrefix); ok {
+		if err := store.ValidateID(id); err != nil {
+			return "", "", fmt.Errorf("resource %q: %w", uri, err)
+		}
+		return KindMemory, id, nil
+	}
+	return "", "", fmt.Errorf("unknown resource %q (want %s, %s or %s)", uri, MemoryTemplate, Recent, Pinned)
+}
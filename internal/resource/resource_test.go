@@ -0,0 +1,34 @@
+package resource
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	id := "6f1c2d3e-0000-4000-8000-000000000001"
+	tests := []struct {
+		uri  string
+		kind Kind
+		id   string
+	}{
+		{Recent, KindRecent, ""},
+		{Pinned, KindPinned, ""},
+		{Memory(id), KindMemory, id},
+	}
+	for _, tt := range tests {
+		kind, gotID, err := Parse(tt.uri)
+		if err != nil || kind != tt.kind || gotID != tt.id {
+			t.Errorf("Parse(%q) = %q, %q, %v; want %q, %q", tt.uri, kind, gotID, err, tt.kind, tt.id)
+		}
+	}
+
+	for _, uri := range []string{
+		"clawbrain://memory/not-a-uuid",
+		"clawbrain://memory/",
+		"clawbrain://recent/",
+		"https://example.com/recent",
+		"",
+	} {
+		if _, _, err := Parse(uri); err == nil {
+			t.Errorf("Parse(%q): expected error", uri)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package retention
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hsk-coder/clawbrain/internal/store"
+)
+
+// DefaultGroupSize caps how many memories are summarized together, keeping
+// each summarization prompt comfortably inside a small model's context.
+const DefaultGroupSize = 20
+
+// Group is a set of stale memories sharing a type and source, summarized into
+// a single archival memory by forget --compress. Type and Source are the raw
+// payload values, empty when unset.
+type Group struct {
+	Type     string
+	Source   string
+	Memories []store.Result
+}
+
+// Stale returns the memories a forget pass at ttl and frequency weight would
+// delete: those that have outlived their retention TTL (see Outlived) and
+// are neither pinned nor locked.
+func Stale(memories []store.Result, ttl time.Duration, weight float64, now time.Time) []store.Result {
+	var out []store.Result
+	for _, m := range memories {
+		if IsPinned(m.Payload) || store.IsLocked(m.Payload) {
+			continue
+		}
+		if Outlived(m.Payload, ttl, weight, now) {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// GroupStale groups memories by type and source, oldest first within each
+// group, splitting groups larger than maxSize. Groups are returned in a
+// deterministic order (by type, then source).
+func GroupStale(memories []store.Result, maxSize int) []Group {
+	if maxSize <= 0 {
+		maxSize = DefaultGroupSize
+	}
+
+	type key struct{ typ, source string }
+	byKey := map[key][]store.Result{}
+	for _, m := range memories {
+		t, _ := m.Payload["type"].(string)
+		src, _ := m.Payload["source"].(string)
+		k := key{t, src}
+		byKey[k] = append(byKey[k], m)
+	}
+
+	keys := make([]key, 0, len(byKey))
+	for k := range byKey {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].typ != keys[j].typ {
+			return keys[i].typ < keys[j].typ
+		}
+		return keys[i].source < keys[j].source
+	})
+
+	var groups []Group
+	for _, k := range keys {
+		members := byKey[k]
+		sort.SliceStable(members, func(i, j int) bool {
+			a, _ := members[i].Payload["created_at"].(string)
+			b, _ := members[j].Payload["created_at"].(string)
+			return a < b
+		})
+		for start := 0; start < len(members); start += maxSize {
+			end := min(start+maxSize, len(members))
+			groups = append(groups, Group{Type: k.typ, Source: k.source, Memories: members[start:end]})
+		}
+	}
+	return groups
+}
+
+// SummaryPrompt builds the prompt asking a model to condense the group into a
+// single memory that keeps every distinct fact.
+func SummaryPrompt(g Group) string {
+	var b strings.Builder
+	b.WriteString("Summarize the following notes into one compact paragraph. ")
+	b.WriteString("Keep every distinct fact, decision, name, number and date; drop repetition. ")
+	b.WriteString("Do not add information that is not in the notes. Reply with the summary only.\n\n")
+	if g.Type != "" {
+		fmt.Fprintf(&b, "Type: %s\n", g.Type)
+	}
+	if g.Source != "" {
+		fmt.Fprintf(&b, "Source: %s\n", g.Source)
+	}
+	b.WriteString("Notes:\n")
+	for _, m := range g.Memories {
+		text, _ := m.Payload["text"].(string)
+		fmt.Fprintf(&b, "- %s\n", strings.TrimSpace(text))
+	}
+	return b.String()
+}
+
+// SummaryPayload builds the payload of the archival memory replacing the
+// group. It keeps the group's type and source, inherits the oldest created_at
+// so the summary dates from its earliest original, and records which memories
+// it was compressed from.
+func SummaryPayload(g Group, summary string) map[string]any {
+	ids := make([]any, len(g.Memories))
+	oldest := ""
+	for i, m := range g.Memories {
+		ids[i] = m.ID
+		if ca, ok := m.Payload["created_at"].(string); ok && (oldest == "" || ca < oldest) {
+			oldest = ca
+		}
+	}
+
+	payload := map[string]any{
+		"text":            strings.TrimSpace(summary),
+		"compressed":      true,
+		"compressed_from": ids,
+	}
+	if g.Type != "" {
+		payload["type"] = g.Type
+	}
+	if g.Source != "" {
+		payload["source"] = g.Source
+	}
+	if oldest != "" {
+		payload["created_at"] = oldest
+	}
+	return payload
+}
+
+// CondensePrompt builds the prompt asking a model to condense one long
+// memory, e.g. a synced chunk, for reading in place of it. source, if not
+// empty, is the file the text came from.
+func CondensePrompt(text, source string) string {
+	var b strings.Builder
+	b.WriteString("Condense the following text into a short summary a reader can decide from whether to read all of it. ")
+	b.WriteString("Keep the main facts, decisions, names, numbers and dates; leave out detail and examples. ")
+	b.WriteString("Do not add information that is not in the text. Reply with the summary only.\n\n")
+	if source != "" {
+		fmt.Fprintf(&b, "Source: %s\n", source)
+	}
+	b.WriteString("Text:\n")
+	b.WriteString(strings.TrimSpace(text))
+	b.WriteString("\n")
+	return b.String()
+}
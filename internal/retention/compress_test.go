@@ -0,0 +1,106 @@
+package retention
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hsk-coder/clawbrain/internal/store"
+)
+
+func TestStale(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	memories := []store.Result{
+		memory(now, 2*Day, nil),
+		memory(now, 40*Day, nil),
+		memory(now, 40*Day, map[string]any{"pinned": true}),
+		memory(now, 40*Day, map[string]any{"locked": true}),
+	}
+
+	if got := Stale(memories, 30*Day, 0, now); len(got) != 1 {
+		t.Fatalf("expected 1 stale memory (pinned and locked excluded), got %d", len(got))
+	}
+}
+
+func TestGroupStale(t *testing.T) {
+	mk := func(id, typ, source, created string) store.Result {
+		p := map[string]any{"text": id, "created_at": created}
+		if typ != "" {
+			p["type"] = typ
+		}
+		if source != "" {
+			p["source"] = source
+		}
+		return store.Result{ID: id, Payload: p}
+	}
+	memories := []store.Result{
+		mk("b", "fact", "/a.md", "2026-01-02T00:00:00Z"),
+		mk("a", "fact", "/a.md", "2026-01-01T00:00:00Z"),
+		mk("c", "fact", "/a.md", "2026-01-03T00:00:00Z"),
+		mk("d", "", "", "2026-01-01T00:00:00Z"),
+		mk("e", "fact", "/b.md", "2026-01-01T00:00:00Z"),
+	}
+
+	groups := GroupStale(memories, 2)
+
+	// untyped first, then fact//a.md split in two, then fact//b.md.
+	if len(groups) != 4 {
+		t.Fatalf("expected 4 groups, got %d: %+v", len(groups), groups)
+	}
+	if groups[0].Type != "" || groups[0].Memories[0].ID != "d" {
+		t.Errorf("unexpected first group: %+v", groups[0])
+	}
+	if groups[1].Source != "/a.md" || len(groups[1].Memories) != 2 || groups[1].Memories[0].ID != "a" || groups[1].Memories[1].ID != "b" {
+		t.Errorf("expected /a.md group split oldest first, got %+v", groups[1])
+	}
+	if len(groups[2].Memories) != 1 || groups[2].Memories[0].ID != "c" {
+		t.Errorf("expected remainder of /a.md group, got %+v", groups[2])
+	}
+	if groups[3].Source != "/b.md" {
+		t.Errorf("expected /b.md group last, got %+v", groups[3])
+	}
+}
+
+func TestSummaryPromptAndPayload(t *testing.T) {
+	g := Group{
+		Type:   "lesson",
+		Source: "/notes.md",
+		Memories: []store.Result{
+			{ID: "a", Payload: map[string]any{"text": "deploys need a tag", "created_at": "2026-01-05T00:00:00Z"}},
+			{ID: "b", Payload: map[string]any{"text": " rollbacks use the previous tag ", "created_at": "2026-01-01T00:00:00Z"}},
+		},
+	}
+
+	prompt := SummaryPrompt(g)
+	for _, want := range []string{"Type: lesson", "Source: /notes.md", "- deploys need a tag\n", "- rollbacks use the previous tag\n"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt missing %q:\n%s", want, prompt)
+		}
+	}
+
+	p := SummaryPayload(g, "  Deploys are tagged; rollbacks reuse the previous tag.\n")
+	if p["text"] != "Deploys are tagged; rollbacks reuse the previous tag." {
+		t.Errorf("expected trimmed summary text, got %q", p["text"])
+	}
+	if p["compressed"] != true || p["type"] != "lesson" || p["source"] != "/notes.md" {
+		t.Errorf("unexpected summary payload: %v", p)
+	}
+	if p["created_at"] != "2026-01-01T00:00:00Z" {
+		t.Errorf("expected oldest created_at, got %v", p["created_at"])
+	}
+	if from, _ := p["compressed_from"].([]any); len(from) != 2 || from[0] != "a" {
+		t.Errorf("unexpected compressed_from: %v", p["compressed_from"])
+	}
+}
+
+func TestCondensePrompt(t *testing.T) {
+	prompt := CondensePrompt("  the release process has five steps\n", "/docs/release.md")
+	for _, want := range []string{"Source: /docs/release.md\n", "Text:\nthe release process has five steps\n"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt missing %q:\n%s", want, prompt)
+		}
+	}
+	if strings.Contains(CondensePrompt("text", ""), "Source:") {
+		t.Error("expected no source line without a source")
+	}
+}
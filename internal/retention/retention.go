@@ -0,0 +1,421 @@
+// Package retention analyzes how stored memories age. It answers "what would
+// forget remove?" and "how long do we keep things?" questions from a snapshot
+// of payloads and the audit log, without touching the store, so a policy can
+// be previewed and reported on before it is applied.
+package retention
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hsk-coder/clawbrain/internal/audit"
+	"github.com/hsk-coder/clawbrain/internal/store"
+)
+
+// Day is a convenience unit for TTLs expressed in days.
+const Day = 24 * time.Hour
+
+// DefaultPersonalTTL is how long forget keeps a personal memory that isn't
+// accessed, unless told otherwise. Personal data shouldn't outlive its use
+// the way project facts do.
+const DefaultPersonalTTL = 7 * Day
+
+// DefaultCurve lists the TTLs plotted by a forget simulation when the caller
+// does not provide its own. The requested TTL is always added to the curve.
+var DefaultCurve = []time.Duration{
+	1 * Day, 7 * Day, 14 * Day, 30 * Day, 60 * Day, 90 * Day, 180 * Day, 365 * Day,
+}
+
+// Labels used when a memory has no type or source in its payload.
+const (
+	untypedLabel  = "untyped"
+	noSourceLabel = "manual"
+)
+
+// CurvePoint is the outcome of forgetting at a single TTL.
+type CurvePoint struct {
+	TTL       string  `json:"ttl"`
+	Days      float64 `json:"days"`
+	Forgotten int     `json:"forgotten"`
+	Remaining int     `json:"remaining"`
+}
+
+// Simulation summarizes what a forget pass at TTL would remove.
+// ByType and BySource only count memories that would be forgotten at TTL.
+type Simulation struct {
+	TTL       string         `json:"ttl"`
+	Total     int            `json:"total"`
+	Pinned    int            `json:"pinned"`
+	Locked    int            `json:"locked"`
+	Forgotten int            `json:"forgotten"`
+	Remaining int            `json:"remaining"`
+	ByType    map[string]int `json:"by_type"`
+	BySource  map[string]int `json:"by_source"`
+	Curve     []CurvePoint   `json:"curve"`
+}
+
+// Simulate computes how many memories a forget pass would delete at ttl,
+// broken down by type and source, plus a decay curve across the given TTLs.
+// It mirrors forget: a memory is forgotten when its last_accessed is older
+// than now minus its TTL (see TTLFor), stretched by how often it was
+// recalled at the given frequency weight (see store.RetentionTTL), and it
+// is neither pinned nor locked.
+func Simulate(memories []store.Result, ttl, personalTTL time.Duration, weight float64, curve []time.Duration, now time.Time) Simulation {
+	sim := Simulation{
+		TTL:      ttl.String(),
+		Total:    len(memories),
+		ByType:   map[string]int{},
+		BySource: map[string]int{},
+		Curve:    []CurvePoint{},
+	}
+
+	for _, m := range memories {
+		if IsPinned(m.Payload) {
+			sim.Pinned++
+			continue
+		}
+		if store.IsLocked(m.Payload) {
+			sim.Locked++
+			continue
+		}
+		if Outlived(m.Payload, TTLFor(m.Payload, ttl, personalTTL), weight, now) {
+			sim.Forgotten++
+			sim.ByType[TypeOf(m.Payload)]++
+			sim.BySource[SourceOf(m.Payload)]++
+		}
+	}
+	sim.Remaining = sim.Total - sim.Forgotten
+
+	for _, d := range curveWith(curve, ttl) {
+		forgotten := 0
+		for _, m := range memories {
+			if !IsPinned(m.Payload) && !store.IsLocked(m.Payload) &&
+				Outlived(m.Payload, TTLFor(m.Payload, d, personalTTL), weight, now) {
+				forgotten++
+			}
+		}
+		sim.Curve = append(sim.Curve, CurvePoint{
+			TTL:       d.String(),
+			Days:      d.Hours() / 24,
+			Forgotten: forgotten,
+			Remaining: len(memories) - forgotten,
+		})
+	}
+
+	return sim
+}
+
+// curveWith returns the curve TTLs plus ttl, sorted and deduplicated.
+func curveWith(curve []time.Duration, ttl time.Duration) []time.Duration {
+	seen := map[time.Duration]bool{}
+	var out []time.Duration
+	for _, d := range append(append([]time.Duration{}, curve...), ttl) {
+		if seen[d] {
+			continue
+		}
+		seen[d] = true
+		out = append(out, d)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
+
+// TTLFor returns the TTL forget applies to a memory: ttl, or personalTTL for
+// a personal memory when that is shorter.
+func TTLFor(payload map[string]any, ttl, personalTTL time.Duration) time.Duration {
+	if store.IsPersonal(payload) && personalTTL < ttl {
+		return personalTTL
+	}
+	return ttl
+}
+
+// Outlived reports whether a memory has gone unaccessed longer than its
+// retention TTL: ttl stretched for its access_count at weight.
+func Outlived(payload map[string]any, ttl time.Duration, weight float64, now time.Time) bool {
+	return WouldForget(payload, now.Add(-store.RetentionTTL(ttl, store.AccessCount(payload), weight)))
+}
+
+// WouldForget reports whether a memory's last_accessed is before cutoff.
+// Memories without a parseable last_accessed are kept, matching the Qdrant
+// datetime filter used by store.Forget, which never matches a missing field.
+func WouldForget(payload map[string]any, cutoff time.Time) bool {
+	la, ok := LastAccessed(payload)
+	if !ok {
+		return false
+	}
+	return la.Before(cutoff)
+}
+
+// LastAccessed parses the last_accessed timestamp from a payload.
+func LastAccessed(payload map[string]any) (time.Time, bool) {
+	return timestamp(payload, "last_accessed")
+}
+
+// CreatedAt parses the created_at timestamp from a payload.
+func CreatedAt(payload map[string]any) (time.Time, bool) {
+	return timestamp(payload, "created_at")
+}
+
+// timestamp parses an RFC3339 payload field.
+func timestamp(payload map[string]any, key string) (time.Time, bool) {
+	s, ok := payload[key].(string)
+	if !ok || s == "" {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// IsPinned reports whether the payload marks the memory as pinned.
+func IsPinned(payload map[string]any) bool {
+	pinned, ok := payload["pinned"].(bool)
+	return ok && pinned
+}
+
+// TypeOf returns the memory's type, or "untyped" when none is set.
+func TypeOf(payload map[string]any) string {
+	if t, ok := payload["type"].(string); ok && t != "" {
+		return t
+	}
+	return untypedLabel
+}
+
+// SourceOf returns the file a memory was synced from, or "manual" for
+// memories stored directly with add.
+func SourceOf(payload map[string]any) string {
+	if s, ok := payload["source"].(string); ok && s != "" {
+		return s
+	}
+	return noSourceLabel
+}
+
+// TypeRetention summarizes how long memories of one type have been kept.
+type TypeRetention struct {
+	Count           int     `json:"count"`
+	Pinned          int     `json:"pinned"`
+	OldestCreatedAt string  `json:"oldest_created_at,omitempty"`
+	NewestCreatedAt string  `json:"newest_created_at,omitempty"`
+	MaxAgeDays      float64 `json:"max_age_days"`
+	AvgAgeDays      float64 `json:"avg_age_days"`
+}
+
+// OldestMemory identifies the oldest memory still held.
+type OldestMemory struct {
+	ID        string  `json:"id"`
+	CreatedAt string  `json:"created_at"`
+	AgeDays   float64 `json:"age_days"`
+	Type      string  `json:"type"`
+	Source    string  `json:"source"`
+}
+
+// DeletionDay counts audited deletions on a single UTC day.
+type DeletionDay struct {
+	Date     string         `json:"date"`
+	Total    int            `json:"total"`
+	ByAction map[string]int `json:"by_action"`
+}
+
+// Deletions summarizes the audit log. When auditing is disabled the history
+// is unknown, which is reported rather than shown as zero deletions.
+type Deletions struct {
+	AuditEnabled bool           `json:"audit_enabled"`
+	Total        int            `json:"total"`
+	ByAction     map[string]int `json:"by_action"`
+	ByDay        []DeletionDay  `json:"by_day"`
+}
+
+// Report is a retention audit of everything currently stored plus the
+// deletion history recorded in the audit log.
+type Report struct {
+	GeneratedAt string                   `json:"generated_at"`
+	Total       int                      `json:"total"`
+	Pinned      int                      `json:"pinned"`
+	Archived    int                      `json:"archived"`
+	Oldest      *OldestMemory            `json:"oldest,omitempty"`
+	ByType      map[string]TypeRetention `json:"by_type"`
+	Deletions   Deletions                `json:"deletions"`
+}
+
+// BuildReport summarizes retention across memories and audited deletions.
+func BuildReport(memories []store.Result, events []audit.Event, auditEnabled bool, now time.Time) Report {
+	r := Report{
+		GeneratedAt: now.UTC().Format(time.RFC3339),
+		Total:       len(memories),
+		ByType:      map[string]TypeRetention{},
+		Deletions: Deletions{
+			AuditEnabled: auditEnabled,
+			ByAction:     map[string]int{},
+			ByDay:        []DeletionDay{},
+		},
+	}
+
+	ageSums := map[string]float64{}
+	aged := map[string]int{}
+	var oldest time.Time
+
+	for _, m := range memories {
+		typ := TypeOf(m.Payload)
+		tr := r.ByType[typ]
+		tr.Count++
+		if IsPinned(m.Payload) {
+			r.Pinned++
+			tr.Pinned++
+		}
+		if archived, ok := m.Payload["archived"].(bool); ok && archived {
+			r.Archived++
+		}
+
+		if ca, ok := CreatedAt(m.Payload); ok {
+			age := now.Sub(ca).Hours() / 24
+			ageSums[typ] += age
+			aged[typ]++
+			if age > tr.MaxAgeDays {
+				tr.MaxAgeDays = age
+			}
+			stamp := ca.UTC().Format(time.RFC3339)
+			if tr.OldestCreatedAt == "" || stamp < tr.OldestCreatedAt {
+				tr.OldestCreatedAt = stamp
+			}
+			if stamp > tr.NewestCreatedAt {
+				tr.NewestCreatedAt = stamp
+			}
+			if r.Oldest == nil || ca.Before(oldest) {
+				oldest = ca
+				r.Oldest = &OldestMemory{
+					ID:        m.ID,
+					CreatedAt: stamp,
+					AgeDays:   age,
+					Type:      typ,
+					Source:    SourceOf(m.Payload),
+				}
+			}
+		}
+		r.ByType[typ] = tr
+	}
+	for typ, tr := range r.ByType {
+		if aged[typ] > 0 {
+			tr.AvgAgeDays = ageSums[typ] / float64(aged[typ])
+		}
+		r.ByType[typ] = tr
+	}
+
+	days := map[string]*DeletionDay{}
+	for _, e := range events {
+		r.Deletions.Total += e.Count
+		r.Deletions.ByAction[e.Action] += e.Count
+
+		date := "unknown"
+		if t, err := time.Parse(time.RFC3339Nano, e.Time); err == nil {
+			date = t.UTC().Format("2006-01-02")
+		}
+		d, ok := days[date]
+		if !ok {
+			d = &DeletionDay{Date: date, ByAction: map[string]int{}}
+			days[date] = d
+		}
+		d.Total += e.Count
+		d.ByAction[e.Action] += e.Count
+	}
+	for _, d := range days {
+		r.Deletions.ByDay = append(r.Deletions.ByDay, *d)
+	}
+	sort.Slice(r.Deletions.ByDay, func(i, j int) bool {
+		return r.Deletions.ByDay[i].Date < r.Deletions.ByDay[j].Date
+	})
+
+	return r
+}
+
+// Markdown renders the report as a markdown document suitable for filing
+// alongside compliance records.
+func (r Report) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# ClawBrain Retention Report\n\n")
+	fmt.Fprintf(&b, "Generated: %s\n\n", r.GeneratedAt)
+
+	fmt.Fprintf(&b, "## Summary\n\n")
+	fmt.Fprintf(&b, "| Metric | Value |\n|---|---|\n")
+	fmt.Fprintf(&b, "| Memories | %d |\n", r.Total)
+	fmt.Fprintf(&b, "| Pinned | %d |\n", r.Pinned)
+	fmt.Fprintf(&b, "| Archived | %d |\n", r.Archived)
+	if r.Oldest != nil {
+		fmt.Fprintf(&b, "| Oldest memory | %s (%s, %.1f days, %s) |\n", r.Oldest.ID, r.Oldest.CreatedAt, r.Oldest.AgeDays, r.Oldest.Source)
+	}
+	b.WriteString("\n")
+
+	fmt.Fprintf(&b, "## Retention by Type\n\n")
+	fmt.Fprintf(&b, "| Type | Count | Pinned | Oldest | Newest | Max age (days) | Avg age (days) |\n")
+	fmt.Fprintf(&b, "|---|---|---|---|---|---|---|\n")
+	types := make([]string, 0, len(r.ByType))
+	for typ := range r.ByType {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	for _, typ := range types {
+		tr := r.ByType[typ]
+		fmt.Fprintf(&b, "| %s | %d | %d | %s | %s | %.1f | %.1f |\n",
+			typ, tr.Count, tr.Pinned, tr.OldestCreatedAt, tr.NewestCreatedAt, tr.MaxAgeDays, tr.AvgAgeDays)
+	}
+	b.WriteString("\n")
+
+	fmt.Fprintf(&b, "## Deletions\n\n")
+	if !r.Deletions.AuditEnabled {
+		b.WriteString("Audit logging is disabled; deletion history is unavailable. Set CLAWBRAIN_AUDIT_LOG to record it.\n")
+		return b.String()
+	}
+	fmt.Fprintf(&b, "Total deleted: %d\n\n", r.Deletions.Total)
+	if len(r.Deletions.ByDay) > 0 {
+		fmt.Fprintf(&b, "| Date | Deleted | Breakdown |\n|---|---|---|\n")
+		for _, d := range r.Deletions.ByDay {
+			actions := make([]string, 0, len(d.ByAction))
+			for a, n := range d.ByAction {
+				actions = append(actions, fmt.Sprintf("%s: %d", a, n))
+			}
+			sort.Strings(actions)
+			fmt.Fprintf(&b, "| %s | %d | %s |\n", d.Date, d.Total, strings.Join(actions, ", "))
+		}
+	}
+	return b.String()
+}
+
+// ParseDuration parses a duration that may use a day suffix ("30d", "1.5d")
+// in addition to everything time.ParseDuration accepts ("720h", "90m").
+// Retention windows are naturally expressed in days, which Go durations lack.
+func ParseDuration(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.ParseFloat(days, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(n * float64(Day)), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return d, nil
+}
+
+// ParseTime parses a point in time given as a duration before now ("24h",
+// "7d"; see ParseDuration), an RFC 3339 timestamp, or a date, which is
+// midnight UTC. Timestamps are returned in UTC, as memories store them.
+func ParseTime(s string, now time.Time) (time.Time, error) {
+	if d, err := ParseDuration(s); err == nil {
+		if d < 0 {
+			return time.Time{}, fmt.Errorf("invalid time %q: a duration ago must not be negative", s)
+		}
+		return now.Add(-d).UTC(), nil
+	}
+	for _, layout := range []string{time.RFC3339Nano, time.DateOnly} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q: want a duration ago (24h, 7d), an RFC 3339 timestamp or a date (2006-01-02)", s)
+}
@@ -0,0 +1,334 @@
+package retention
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hsk-coder/clawbrain/internal/audit"
+	"github.com/hsk-coder/clawbrain/internal/store"
+)
+
+// memory builds a store.Result whose last_accessed is age before now.
+func memory(now time.Time, age time.Duration, extra map[string]any) store.Result {
+	payload := map[string]any{
+		"text":          "memory",
+		"last_accessed": now.Add(-age).Format(time.RFC3339Nano),
+	}
+	for k, v := range extra {
+		payload[k] = v
+	}
+	return store.Result{ID: "id", Payload: payload}
+}
+
+func TestSimulate(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	memories := []store.Result{
+		memory(now, 2*Day, map[string]any{"type": "todo"}),
+		memory(now, 40*Day, map[string]any{"type": "fact", "source": "/ws/MEMORY.md"}),
+		memory(now, 100*Day, nil),
+		memory(now, 400*Day, map[string]any{"pinned": true}),
+	}
+
+	sim := Simulate(memories, 30*Day, DefaultPersonalTTL, 0, DefaultCurve, now)
+
+	if sim.Total != 4 {
+		t.Errorf("expected total 4, got %d", sim.Total)
+	}
+	if sim.Pinned != 1 {
+		t.Errorf("expected 1 pinned, got %d", sim.Pinned)
+	}
+	if sim.Forgotten != 2 {
+		t.Errorf("expected 2 forgotten at 30d, got %d", sim.Forgotten)
+	}
+	if sim.Remaining != 2 {
+		t.Errorf("expected 2 remaining at 30d, got %d", sim.Remaining)
+	}
+	if sim.ByType["fact"] != 1 || sim.ByType["untyped"] != 1 {
+		t.Errorf("unexpected by_type breakdown: %v", sim.ByType)
+	}
+	if _, ok := sim.ByType["todo"]; ok {
+		t.Errorf("recently accessed todo should not be counted: %v", sim.ByType)
+	}
+	if sim.BySource["/ws/MEMORY.md"] != 1 || sim.BySource["manual"] != 1 {
+		t.Errorf("unexpected by_source breakdown: %v", sim.BySource)
+	}
+}
+
+func TestSimulateSkipsLocked(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	memories := []store.Result{
+		memory(now, 400*Day, map[string]any{"locked": true}),
+		memory(now, 400*Day, nil),
+	}
+
+	sim := Simulate(memories, 30*Day, DefaultPersonalTTL, 0, DefaultCurve, now)
+
+	if sim.Locked != 1 || sim.Forgotten != 1 {
+		t.Errorf("expected 1 locked and 1 forgotten, got locked=%d forgotten=%d", sim.Locked, sim.Forgotten)
+	}
+	for _, p := range sim.Curve {
+		if p.Forgotten > 1 {
+			t.Errorf("curve at %vd forgets the locked memory: %+v", p.Days, p)
+		}
+	}
+}
+
+func TestSimulateCurve(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	memories := []store.Result{
+		memory(now, 2*Day, nil),
+		memory(now, 40*Day, nil),
+		memory(now, 100*Day, nil),
+	}
+
+	// 45 days is not on the default curve; it must be inserted in order.
+	sim := Simulate(memories, 45*Day, DefaultPersonalTTL, 0, []time.Duration{1 * Day, 30 * Day, 90 * Day}, now)
+
+	want := []struct {
+		days      float64
+		forgotten int
+	}{
+		{1, 3},
+		{30, 2},
+		{45, 1},
+		{90, 1},
+	}
+	if len(sim.Curve) != len(want) {
+		t.Fatalf("expected %d curve points, got %d: %+v", len(want), len(sim.Curve), sim.Curve)
+	}
+	for i, w := range want {
+		p := sim.Curve[i]
+		if p.Days != w.days || p.Forgotten != w.forgotten {
+			t.Errorf("curve[%d]: expected %vd/%d forgotten, got %vd/%d", i, w.days, w.forgotten, p.Days, p.Forgotten)
+		}
+		if p.Remaining != len(memories)-p.Forgotten {
+			t.Errorf("curve[%d]: remaining %d does not add up", i, p.Remaining)
+		}
+	}
+}
+
+func TestSimulatePersonalTTL(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	personal := map[string]any{"sensitivity": "personal"}
+	memories := []store.Result{
+		memory(now, 10*Day, personal),
+		memory(now, 3*Day, personal),
+		memory(now, 10*Day, nil),
+		memory(now, 40*Day, map[string]any{"sensitivity": "personal", "pinned": true}),
+	}
+
+	sim := Simulate(memories, 30*Day, DefaultPersonalTTL, 0, []time.Duration{1 * Day}, now)
+	if sim.Forgotten != 1 {
+		t.Errorf("expected only the 10-day-old personal memory forgotten at 30d, got %d", sim.Forgotten)
+	}
+	// A TTL shorter than the personal TTL applies to personal memories too.
+	if p := sim.Curve[0]; p.Days != 1 || p.Forgotten != 3 {
+		t.Errorf("expected 3 forgotten at 1d, got %+v", p)
+	}
+}
+
+func TestTTLFor(t *testing.T) {
+	personal := map[string]any{"sensitivity": "personal"}
+	if got := TTLFor(personal, 30*Day, 7*Day); got != 7*Day {
+		t.Errorf("personal at 30d: got %v", got)
+	}
+	if got := TTLFor(personal, 3*Day, 7*Day); got != 3*Day {
+		t.Errorf("personal at 3d: got %v", got)
+	}
+	if got := TTLFor(map[string]any{"sensitivity": "public"}, 30*Day, 7*Day); got != 30*Day {
+		t.Errorf("public at 30d: got %v", got)
+	}
+}
+
+func TestSimulateEmpty(t *testing.T) {
+	sim := Simulate(nil, 30*Day, DefaultPersonalTTL, 0, DefaultCurve, time.Now())
+	if sim.Total != 0 || sim.Forgotten != 0 {
+		t.Errorf("expected empty simulation, got %+v", sim)
+	}
+	// Maps and slices must be non-nil so JSON output has {} and [], not null.
+	if sim.ByType == nil || sim.BySource == nil || sim.Curve == nil {
+		t.Error("expected non-nil maps and curve")
+	}
+	if len(sim.Curve) != len(DefaultCurve) {
+		t.Errorf("expected %d curve points, got %d", len(DefaultCurve), len(sim.Curve))
+	}
+}
+
+func TestWouldForget(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	cutoff := now.Add(-Day)
+
+	tests := []struct {
+		name    string
+		payload map[string]any
+		want    bool
+	}{
+		{"stale", map[string]any{"last_accessed": now.Add(-2 * Day).Format(time.RFC3339Nano)}, true},
+		{"fresh", map[string]any{"last_accessed": now.Format(time.RFC3339Nano)}, false},
+		{"missing timestamp", map[string]any{}, false},
+		{"unparseable timestamp", map[string]any{"last_accessed": "yesterday"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := WouldForget(tt.payload, cutoff); got != tt.want {
+				t.Errorf("WouldForget = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOutlived(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	// Last accessed 45 days ago; forget's TTL is 30 days.
+	payload := func(count int64) map[string]any {
+		return map[string]any{"last_accessed": now.Add(-45 * Day).Format(time.RFC3339Nano), store.AccessCountField: count}
+	}
+
+	if !Outlived(payload(0), 30*Day, 1, now) {
+		t.Error("expected a memory never recalled to be forgotten")
+	}
+	if Outlived(payload(1), 30*Day, 1, now) {
+		t.Error("expected a memory recalled once to be kept for 60 days")
+	}
+	if !Outlived(payload(1), 30*Day, 0, now) {
+		t.Error("expected weight 0 to ignore the access count")
+	}
+	if Outlived(payload(3), 20*Day, 1, now) || !Outlived(payload(3), 20*Day, 0.5, now) {
+		t.Error("expected 3 recalls to stretch a 20-day TTL to 60 days at weight 1 but only 40 at weight 0.5")
+	}
+}
+
+func TestLabels(t *testing.T) {
+	if got := TypeOf(map[string]any{}); got != "untyped" {
+		t.Errorf("TypeOf empty = %q, want untyped", got)
+	}
+	if got := TypeOf(map[string]any{"type": "lesson"}); got != "lesson" {
+		t.Errorf("TypeOf = %q, want lesson", got)
+	}
+	if got := SourceOf(map[string]any{}); got != "manual" {
+		t.Errorf("SourceOf empty = %q, want manual", got)
+	}
+	if got := SourceOf(map[string]any{"source": "/a.md"}); got != "/a.md" {
+		t.Errorf("SourceOf = %q, want /a.md", got)
+	}
+	if IsPinned(map[string]any{"pinned": "yes"}) {
+		t.Error("non-bool pinned should not count as pinned")
+	}
+}
+
+func TestBuildReport(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	created := func(age time.Duration) string { return now.Add(-age).Format(time.RFC3339Nano) }
+	memories := []store.Result{
+		{ID: "a", Payload: map[string]any{"type": "fact", "created_at": created(10 * Day)}},
+		{ID: "b", Payload: map[string]any{"type": "fact", "created_at": created(30 * Day), "pinned": true}},
+		{ID: "c", Payload: map[string]any{"created_at": created(100 * Day), "source": "/ws/old.md"}},
+		{ID: "d", Payload: map[string]any{"archived": true}},
+	}
+	events := []audit.Event{
+		{Time: "2026-02-01T10:00:00Z", Action: "forget", Count: 4},
+		{Time: "2026-02-01T18:00:00Z", Action: "delete", Count: 1},
+		{Time: "2026-01-15T00:00:00Z", Action: "forget", Count: 2},
+	}
+
+	r := BuildReport(memories, events, true, now)
+
+	if r.Total != 4 || r.Pinned != 1 || r.Archived != 1 {
+		t.Errorf("unexpected totals: total=%d pinned=%d archived=%d", r.Total, r.Pinned, r.Archived)
+	}
+	if r.Oldest == nil || r.Oldest.ID != "c" || r.Oldest.Source != "/ws/old.md" {
+		t.Fatalf("expected oldest memory c, got %+v", r.Oldest)
+	}
+	if r.Oldest.AgeDays != 100 {
+		t.Errorf("expected oldest age 100 days, got %v", r.Oldest.AgeDays)
+	}
+
+	fact := r.ByType["fact"]
+	if fact.Count != 2 || fact.Pinned != 1 {
+		t.Errorf("unexpected fact retention: %+v", fact)
+	}
+	if fact.MaxAgeDays != 30 || fact.AvgAgeDays != 20 {
+		t.Errorf("expected fact max 30 / avg 20 days, got %v / %v", fact.MaxAgeDays, fact.AvgAgeDays)
+	}
+	if r.ByType["untyped"].Count != 2 {
+		t.Errorf("expected 2 untyped memories, got %d", r.ByType["untyped"].Count)
+	}
+
+	if r.Deletions.Total != 7 {
+		t.Errorf("expected 7 deletions, got %d", r.Deletions.Total)
+	}
+	if r.Deletions.ByAction["forget"] != 6 || r.Deletions.ByAction["delete"] != 1 {
+		t.Errorf("unexpected by_action: %v", r.Deletions.ByAction)
+	}
+	if len(r.Deletions.ByDay) != 2 || r.Deletions.ByDay[0].Date != "2026-01-15" || r.Deletions.ByDay[1].Total != 5 {
+		t.Errorf("unexpected by_day: %+v", r.Deletions.ByDay)
+	}
+}
+
+func TestReportMarkdown(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	memories := []store.Result{
+		{ID: "a", Payload: map[string]any{"type": "lesson", "created_at": now.Add(-Day).Format(time.RFC3339Nano)}},
+	}
+
+	md := BuildReport(memories, nil, false, now).Markdown()
+	for _, want := range []string{"# ClawBrain Retention Report", "| lesson | 1 |", "Audit logging is disabled"} {
+		if !strings.Contains(md, want) {
+			t.Errorf("markdown missing %q:\n%s", want, md)
+		}
+	}
+
+	md = BuildReport(memories, []audit.Event{{Time: "2026-02-01T00:00:00Z", Action: "forget", Count: 3}}, true, now).Markdown()
+	if !strings.Contains(md, "| 2026-02-01 | 3 | forget: 3 |") {
+		t.Errorf("markdown missing deletion row:\n%s", md)
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"30d", 30 * Day},
+		{"1.5d", 36 * time.Hour},
+		{"720h", 720 * time.Hour},
+		{"90m", 90 * time.Minute},
+		{"0s", 0},
+	}
+	for _, tt := range tests {
+		got, err := ParseDuration(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseDuration(%q) = %v, %v; want %v", tt.in, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"", "d", "30days", "thirty"} {
+		if _, err := ParseDuration(bad); err == nil {
+			t.Errorf("ParseDuration(%q) should fail", bad)
+		}
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"24h", time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)},
+		{"7d", time.Date(2026, 10, 8, 12, 0, 0, 0, time.UTC)},
+		{"2026-10-01", time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)},
+		{"2026-10-01T09:30:00+02:00", time.Date(2026, 10, 1, 7, 30, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := ParseTime(tt.in, now)
+		if err != nil || !got.Equal(tt.want) || got.Location() != time.UTC {
+			t.Errorf("ParseTime(%q) = %v, %v; want %v", tt.in, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"", "-24h", "yesterday", "2026-13-01"} {
+		if _, err := ParseTime(bad, now); err == nil {
+			t.Errorf("ParseTime(%q) should fail", bad)
+		}
+	}
+}
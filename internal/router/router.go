@@ -0,0 +1,137 @@
+// Package router classifies a search query's intent with lightweight
+// heuristics so the CLI can pick a retrieval strategy suited to the question
+// instead of running the same cosine search for everything. Classification is
+// pure string analysis: no model calls, no I/O.
+package router
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Query intents.
+const (
+	IntentEntity   = "entity"
+	IntentTemporal = "temporal"
+	IntentTodo     = "todo_status"
+	IntentGeneral  = "general"
+)
+
+// Retrieval strategies, one per intent.
+const (
+	// StrategyKeyword restricts the search to memories mentioning the entities.
+	StrategyKeyword = "keyword"
+	// StrategyRecency ranks fresh memories above older equivalents.
+	StrategyRecency = "recency"
+	// StrategyFiltered restricts the search to todo memories.
+	StrategyFiltered = "filtered"
+	// StrategyVector is plain cosine similarity.
+	StrategyVector = "vector"
+)
+
+// Route is the outcome of classifying a query.
+type Route struct {
+	Intent   string   `json:"intent"`
+	Strategy string   `json:"strategy"`
+	Entities []string `json:"entities,omitempty"`
+	Reason   string   `json:"reason"`
+}
+
+var (
+	todoPattern = regexp.MustCompile(`(?i)\b(todos?|to-dos?|tasks?|pending|outstanding|open items?|backlog|blocked|in progress|still (?:need|have) to|left to do|unfinished|done yet|finished yet)\b`)
+
+	temporalPattern = regexp.MustCompile(`(?i)\b(yesterday|today|tonight|tomorrow|recent(?:ly)?|latest|newest|last (?:night|week|month|year|time|session)|this (?:morning|afternoon|week|month)|ago|lately|so far|when did|since|(?:mon|tues|wednes|thurs|fri|satur|sun)day)\b|\b\d{4}-\d{2}-\d{2}\b`)
+
+	quotedPattern = regexp.MustCompile("\"([^\"]+)\"|'([^']+)'|`([^`]+)`")
+
+	// identifierPattern matches tokens like deploy-checklist, api.v2 or
+	// user_42 — names rather than prose.
+	identifierPattern = regexp.MustCompile(`\b[A-Za-z0-9]+(?:[-_.][A-Za-z0-9]+)+\b`)
+)
+
+// Classify picks an intent and strategy for the query. Todo-status questions
+// win over temporal ones ("what's still pending from last week?" is about
+// todos), and temporal wins over entity lookups ("what did Alice say
+// yesterday?" is about recency). Entities are reported whatever the intent.
+func Classify(query string) Route {
+	entities := Entities(query)
+
+	switch {
+	case todoPattern.MatchString(query):
+		return Route{Intent: IntentTodo, Strategy: StrategyFiltered, Entities: entities,
+			Reason: "mentions " + strings.ToLower(todoPattern.FindString(query))}
+	case temporalPattern.MatchString(query):
+		return Route{Intent: IntentTemporal, Strategy: StrategyRecency, Entities: entities,
+			Reason: "mentions " + strings.ToLower(temporalPattern.FindString(query))}
+	case len(entities) > 0:
+		return Route{Intent: IntentEntity, Strategy: StrategyKeyword, Entities: entities,
+			Reason: "names " + strings.Join(entities, ", ")}
+	default:
+		return Route{Intent: IntentGeneral, Strategy: StrategyVector, Reason: "no specific intent detected"}
+	}
+}
+
+// Entities extracts the names a query refers to: quoted phrases, identifier
+// tokens (deploy-checklist, api.v2), and runs of capitalized words that don't
+// start the sentence (John Doe, Postgres).
+func Entities(query string) []string {
+	var out []string
+	seen := map[string]bool{}
+	add := func(e string) {
+		e = strings.TrimSpace(e)
+		if e == "" || seen[strings.ToLower(e)] {
+			return
+		}
+		seen[strings.ToLower(e)] = true
+		out = append(out, e)
+	}
+
+	for _, m := range quotedPattern.FindAllStringSubmatch(query, -1) {
+		add(m[1] + m[2] + m[3])
+	}
+	rest := quotedPattern.ReplaceAllString(query, " ")
+
+	for _, id := range identifierPattern.FindAllString(rest, -1) {
+		add(id)
+	}
+
+	words := strings.Fields(rest)
+	var run []string
+	flush := func() {
+		if len(run) > 0 {
+			add(strings.Join(run, " "))
+			run = nil
+		}
+	}
+	for i, w := range words {
+		clean := strings.TrimFunc(w, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+		if i > 0 && isCapitalized(clean) && !stopwords[strings.ToLower(clean)] {
+			run = append(run, clean)
+		} else {
+			flush()
+		}
+		// Punctuation ends a name: "Alice, Bob" is two entities.
+		if clean != w && strings.ContainsAny(w[len(w)-1:], ",;:?!.") {
+			flush()
+		}
+	}
+	flush()
+
+	return out
+}
+
+// isCapitalized reports whether the word starts with an upper-case letter.
+func isCapitalized(w string) bool {
+	for _, r := range w {
+		return unicode.IsUpper(r)
+	}
+	return false
+}
+
+// stopwords are capitalized words that rarely name an entity mid-sentence.
+var stopwords = map[string]bool{
+	"i": true, "i'm": true, "im": true, "the": true, "a": true, "an": true,
+	"what": true, "when": true, "where": true, "who": true, "why": true, "how": true,
+	"is": true, "are": true, "do": true, "does": true, "did": true,
+}
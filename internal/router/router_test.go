@@ -0,0 +1,56 @@
+package router
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		query    string
+		intent   string
+		strategy string
+	}{
+		{"what tasks are still pending?", IntentTodo, StrategyFiltered},
+		{"what's left to do from last week", IntentTodo, StrategyFiltered},
+		{"what happened yesterday", IntentTemporal, StrategyRecency},
+		{"latest decisions about the release", IntentTemporal, StrategyRecency},
+		{"notes from 2026-01-03", IntentTemporal, StrategyRecency},
+		{"what do we know about John Doe", IntentEntity, StrategyKeyword},
+		{"where is the deploy-checklist", IntentEntity, StrategyKeyword},
+		{"how do I configure the database", IntentGeneral, StrategyVector},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			r := Classify(tt.query)
+			if r.Intent != tt.intent || r.Strategy != tt.strategy {
+				t.Errorf("Classify(%q) = %s/%s, want %s/%s (%s)", tt.query, r.Intent, r.Strategy, tt.intent, tt.strategy, r.Reason)
+			}
+			if r.Reason == "" {
+				t.Error("expected a reason")
+			}
+		})
+	}
+}
+
+func TestEntities(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"What does John Doe own", []string{"John Doe"}},
+		{`find "release train" notes`, []string{"release train"}},
+		{"status of api.v2 and deploy-checklist", []string{"api.v2", "deploy-checklist"}},
+		{"did Alice, Bob meet?", []string{"Alice", "Bob"}},
+		{"Postgres tuning", nil},
+		{"tuning Postgres", []string{"Postgres"}},
+		{"what did I do", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			if got := Entities(tt.query); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Entities(%q) = %#v, want %#v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,324 @@
+// Package schedule parses reminder specs like "every friday 09:00" or
+// "in 2h" and computes when a reminder is next due. Calendar times are
+// interpreted in the location of the reference time passed in (the local
+// zone for the CLI); callers store due times in UTC.
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hsk-coder/clawbrain/internal/retention"
+)
+
+// DefaultHour and DefaultMinute are the time of day used when a calendar
+// spec names a day but no time ("every monday", "tomorrow").
+const (
+	DefaultHour   = 9
+	DefaultMinute = 0
+)
+
+// MinInterval is the shortest "every <duration>" a schedule accepts.
+const MinInterval = time.Minute
+
+// Schedule is a parsed reminder spec. Exactly one of interval, days or once
+// describes it.
+type Schedule struct {
+	spec     string
+	interval time.Duration // "every 2h"
+	days     [7]bool       // "every friday", indexed by time.Weekday
+	hour     int
+	minute   int
+	once     time.Time // one-shot: "in 3d", "tomorrow 10:00", "2026-03-01"
+}
+
+var weekdays = map[string]time.Weekday{
+	"sunday": time.Sunday, "sun": time.Sunday,
+	"monday": time.Monday, "mon": time.Monday,
+	"tuesday": time.Tuesday, "tue": time.Tuesday, "tues": time.Tuesday,
+	"wednesday": time.Wednesday, "wed": time.Wednesday,
+	"thursday": time.Thursday, "thu": time.Thursday, "thurs": time.Thursday,
+	"friday": time.Friday, "fri": time.Friday,
+	"saturday": time.Saturday, "sat": time.Saturday,
+}
+
+// Parse parses a reminder spec relative to now. Accepted forms:
+//
+//	every <duration>                 every 2h, every 30m, every 3d
+//	every <days> [at] [time]         every friday 09:00, every mon,thu 5pm,
+//	                                 every day 8:30, every weekday, every weekend
+//	daily [at] [time]                same as "every day"
+//	in <duration>                    in 2h, in 3d (one-shot)
+//	[on] <weekday> [at] [time]       friday 17:00 (one-shot, next occurrence)
+//	today|tomorrow [at] [time]       tomorrow 10:00 (one-shot)
+//	<YYYY-MM-DD> [at] [time]         2026-03-01 14:00 (one-shot)
+//	at <time>                        at 17:30 (one-shot, today or tomorrow)
+//	<RFC3339>                        2026-03-01T14:00:00Z (one-shot)
+//
+// Times are HH:MM (24h) or 9am / 5:30pm; a day without a time means 09:00.
+// One-shot times must be in the future.
+func Parse(spec string, now time.Time) (Schedule, error) {
+	s := Schedule{spec: strings.TrimSpace(spec), hour: DefaultHour, minute: DefaultMinute}
+	fields := strings.Fields(strings.ToLower(strings.ReplaceAll(s.spec, ",", " , ")))
+	if len(fields) == 0 {
+		return Schedule{}, fmt.Errorf("empty reminder")
+	}
+	invalid := func(why string) (Schedule, error) {
+		return Schedule{}, fmt.Errorf("invalid reminder %q: %s", s.spec, why)
+	}
+
+	switch head, rest := fields[0], fields[1:]; head {
+	case "every", "daily":
+		if head == "daily" {
+			rest = append([]string{"day"}, rest...)
+		}
+		if len(rest) == 0 {
+			return invalid("expected a duration or day after \"every\"")
+		}
+		if d, err := retention.ParseDuration(rest[0]); err == nil {
+			if len(rest) > 1 {
+				return invalid("unexpected text after interval")
+			}
+			if d < MinInterval {
+				return invalid(fmt.Sprintf("interval must be at least %s", MinInterval))
+			}
+			s.interval = d
+			return s, nil
+		}
+		rest, err := s.parseDays(rest)
+		if err != nil {
+			return invalid(err.Error())
+		}
+		if s.days == [7]bool{} {
+			return invalid("expected a day after \"every\"")
+		}
+		if err := s.parseTimeOfDay(rest); err != nil {
+			return invalid(err.Error())
+		}
+		return s, nil
+
+	case "in":
+		if len(rest) != 1 {
+			return invalid("expected a single duration after \"in\"")
+		}
+		d, err := retention.ParseDuration(rest[0])
+		if err != nil || d <= 0 {
+			return invalid("expected a positive duration after \"in\"")
+		}
+		s.once = now.Add(d)
+		return s, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, strings.ToUpper(fields[0])); err == nil && len(fields) == 1 {
+		s.once = t
+	} else if err := s.parseOnce(fields, now); err != nil {
+		return invalid(err.Error())
+	}
+	if !s.once.After(now) {
+		return invalid("time is in the past")
+	}
+	return s, nil
+}
+
+// parseDays consumes a list of day names ("friday", "mon , thu", "day",
+// "weekday", "weekend") and returns the remaining fields.
+func (s *Schedule) parseDays(fields []string) ([]string, error) {
+	n := 0
+	for ; n < len(fields); n++ {
+		f := strings.TrimSuffix(fields[n], "s")
+		switch {
+		case f == "," || f == "and":
+			continue
+		case f == "day":
+			s.days = [7]bool{true, true, true, true, true, true, true}
+		case f == "weekday":
+			for d := time.Monday; d <= time.Friday; d++ {
+				s.days[d] = true
+			}
+		case f == "weekend":
+			s.days[time.Saturday], s.days[time.Sunday] = true, true
+		default:
+			d, ok := weekdays[fields[n]]
+			if !ok {
+				d, ok = weekdays[f]
+			}
+			if !ok {
+				if n == 0 {
+					return nil, fmt.Errorf("unknown day %q", fields[n])
+				}
+				return fields[n:], nil
+			}
+			s.days[d] = true
+		}
+	}
+	return fields[n:], nil
+}
+
+// parseOnce parses the one-shot calendar forms into s.once.
+func (s *Schedule) parseOnce(fields []string, now time.Time) error {
+	if fields[0] == "on" || fields[0] == "next" {
+		fields = fields[1:]
+	}
+	if len(fields) == 0 {
+		return fmt.Errorf("expected a day")
+	}
+
+	loc := now.Location()
+	y, m, d := now.Date()
+	var day time.Time
+	switch head := fields[0]; {
+	case head == "today":
+		day = time.Date(y, m, d, 0, 0, 0, 0, loc)
+		fields = fields[1:]
+	case head == "tomorrow":
+		day = time.Date(y, m, d+1, 0, 0, 0, 0, loc)
+		fields = fields[1:]
+	case head == "at":
+		// "at 17:30": the next time the clock reads 17:30.
+		if err := s.parseTimeOfDay(fields); err != nil {
+			return err
+		}
+		s.once = s.nextCalendar(now, [7]bool{true, true, true, true, true, true, true})
+		return nil
+	default:
+		if t, err := time.ParseInLocation("2006-01-02", head, loc); err == nil {
+			day = t
+			fields = fields[1:]
+			break
+		}
+		wd, ok := weekdays[head]
+		if !ok {
+			return fmt.Errorf("unrecognized schedule")
+		}
+		if err := s.parseTimeOfDay(fields[1:]); err != nil {
+			return err
+		}
+		var only [7]bool
+		only[wd] = true
+		s.once = s.nextCalendar(now, only)
+		return nil
+	}
+
+	if err := s.parseTimeOfDay(fields); err != nil {
+		return err
+	}
+	y, m, d = day.Date()
+	s.once = time.Date(y, m, d, s.hour, s.minute, 0, 0, loc)
+	return nil
+}
+
+// parseTimeOfDay parses an optional "[at] <time>" tail.
+func (s *Schedule) parseTimeOfDay(fields []string) error {
+	if len(fields) > 0 && fields[0] == "at" {
+		fields = fields[1:]
+		if len(fields) == 0 {
+			return fmt.Errorf("expected a time after \"at\"")
+		}
+	}
+	switch len(fields) {
+	case 0:
+		return nil
+	case 1:
+		h, m, err := parseClock(fields[0])
+		if err != nil {
+			return err
+		}
+		s.hour, s.minute = h, m
+		return nil
+	default:
+		return fmt.Errorf("unexpected text %q", strings.Join(fields[1:], " "))
+	}
+}
+
+// parseClock parses "09:00", "17:30", "9am" or "5:30pm". A bare "9" is
+// rejected as ambiguous.
+func parseClock(v string) (hour, minute int, err error) {
+	bad := fmt.Errorf("invalid time %q", v)
+	meridiem := ""
+	for _, suffix := range []string{"am", "pm"} {
+		if rest, ok := strings.CutSuffix(v, suffix); ok {
+			v, meridiem = rest, suffix
+		}
+	}
+	hs, ms, hasMinutes := strings.Cut(v, ":")
+	if !hasMinutes && meridiem == "" {
+		return 0, 0, bad
+	}
+	if hour, err = strconv.Atoi(hs); err != nil || len(hs) > 2 {
+		return 0, 0, bad
+	}
+	if hasMinutes {
+		if minute, err = strconv.Atoi(ms); err != nil || len(ms) != 2 || minute < 0 || minute > 59 {
+			return 0, 0, bad
+		}
+	}
+	if meridiem != "" {
+		if hour < 1 || hour > 12 {
+			return 0, 0, bad
+		}
+		hour %= 12
+		if meridiem == "pm" {
+			hour += 12
+		}
+	}
+	if hour < 0 || hour > 23 {
+		return 0, 0, bad
+	}
+	return hour, minute, nil
+}
+
+// String returns the spec the schedule was parsed from.
+func (s Schedule) String() string {
+	return s.spec
+}
+
+// Recurring reports whether the reminder repeats after it fires.
+func (s Schedule) Recurring() bool {
+	return s.once.IsZero()
+}
+
+// First returns when the reminder is first due, counting from now.
+func (s Schedule) First(now time.Time) time.Time {
+	switch {
+	case !s.once.IsZero():
+		return s.once
+	case s.interval > 0:
+		return now.Add(s.interval)
+	default:
+		return s.nextCalendar(now, s.days)
+	}
+}
+
+// Advance returns the next due time strictly after now for a recurring
+// reminder that was due at due. Calendar schedules use now's location. Missed occurrences are skipped rather than
+// replayed. It returns false for one-shot reminders.
+func (s Schedule) Advance(due, now time.Time) (time.Time, bool) {
+	switch {
+	case !s.once.IsZero():
+		return time.Time{}, false
+	case s.interval > 0:
+		if due.After(now) {
+			return due, true
+		}
+		missed := now.Sub(due)/s.interval + 1
+		return due.Add(missed * s.interval), true
+	default:
+		return s.nextCalendar(now, s.days), true
+	}
+}
+
+// nextCalendar returns the first time after `after`, in after's location, on
+// one of days at the schedule's time of day.
+func (s Schedule) nextCalendar(after time.Time, days [7]bool) time.Time {
+	y, m, d := after.Date()
+	for i := 0; i <= 7; i++ {
+		t := time.Date(y, m, d+i, s.hour, s.minute, 0, 0, after.Location())
+		if days[t.Weekday()] && t.After(after) {
+			return t
+		}
+	}
+	// Unreachable with at least one day set; Parse guarantees that.
+	return time.Time{}
+}
@@ -0,0 +1,131 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+// now is Wednesday 2026-10-14 10:00 in a fixed zone, so results don't depend
+// on the machine's local time zone.
+var (
+	zone = time.FixedZone("test", 2*60*60)
+	now  = time.Date(2026, 10, 14, 10, 0, 0, 0, zone)
+)
+
+func at(month time.Month, day, hour, minute int) time.Time {
+	return time.Date(2026, month, day, hour, minute, 0, 0, zone)
+}
+
+func TestParseFirst(t *testing.T) {
+	tests := []struct {
+		spec      string
+		want      time.Time
+		recurring bool
+	}{
+		{"every friday 09:00", at(10, 16, 9, 0), true},
+		{"Every Friday at 5pm", at(10, 16, 17, 0), true},
+		{"every fridays", at(10, 16, 9, 0), true},
+		{"every mon,thu 8:30", at(10, 15, 8, 30), true},
+		{"every monday and thursday", at(10, 15, 9, 0), true},
+		{"every wednesday 11:00", at(10, 14, 11, 0), true},
+		{"every wednesday 09:00", at(10, 21, 9, 0), true},
+		{"every day 08:00", at(10, 15, 8, 0), true},
+		{"daily at 18:15", at(10, 14, 18, 15), true},
+		{"every weekday 9am", at(10, 15, 9, 0), true},
+		{"every weekend", at(10, 17, 9, 0), true},
+		{"every 2h", now.Add(2 * time.Hour), true},
+		{"every 3d", now.Add(72 * time.Hour), true},
+		{"in 90m", now.Add(90 * time.Minute), false},
+		{"in 2d", now.Add(48 * time.Hour), false},
+		{"tomorrow", at(10, 15, 9, 0), false},
+		{"tomorrow at 12:30pm", at(10, 15, 12, 30), false},
+		{"today 17:00", at(10, 14, 17, 0), false},
+		{"friday 17:00", at(10, 16, 17, 0), false},
+		{"on monday", at(10, 19, 9, 0), false},
+		{"next wed 8am", at(10, 21, 8, 0), false},
+		{"at 17:30", at(10, 14, 17, 30), false},
+		{"at 08:00", at(10, 15, 8, 0), false},
+		{"2026-11-01", at(11, 1, 9, 0), false},
+		{"2026-11-01 14:00", at(11, 1, 14, 0), false},
+		{"2026-11-01T14:00:00Z", time.Date(2026, 11, 1, 14, 0, 0, 0, time.UTC), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			s, err := Parse(tt.spec, now)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if got := s.First(now); !got.Equal(tt.want) {
+				t.Errorf("First = %v, want %v", got, tt.want)
+			}
+			if s.Recurring() != tt.recurring {
+				t.Errorf("Recurring = %v, want %v", s.Recurring(), tt.recurring)
+			}
+			if s.String() != tt.spec {
+				t.Errorf("String = %q, want %q", s.String(), tt.spec)
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"every",
+		"every blursday",
+		"every 30s",
+		"every 2h and more",
+		"every friday 25:00",
+		"every friday 9",
+		"every friday 13pm",
+		"every friday at",
+		"every friday 09:00 sharp",
+		"in",
+		"in -2h",
+		"in soon",
+		"someday",
+		"today 08:00",
+		"2020-01-01",
+		"2020-01-01T00:00:00Z",
+	} {
+		if _, err := Parse(spec, now); err == nil {
+			t.Errorf("Parse(%q): expected error", spec)
+		}
+	}
+}
+
+func TestAdvance(t *testing.T) {
+	weekly, err := Parse("every friday 09:00", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	due := weekly.First(now)
+	// Acknowledged shortly after firing: next Friday.
+	next, ok := weekly.Advance(due, due.Add(time.Minute))
+	if !ok || !next.Equal(at(10, 23, 9, 0)) {
+		t.Errorf("weekly Advance = %v, %v", next, ok)
+	}
+	// Acknowledged two weeks late: missed occurrences are skipped.
+	next, _ = weekly.Advance(due, at(10, 31, 12, 0))
+	if !next.Equal(at(11, 6, 9, 0)) {
+		t.Errorf("late weekly Advance = %v", next)
+	}
+
+	hourly, err := Parse("every 1h", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	due = hourly.First(now)
+	next, _ = hourly.Advance(due, due.Add(150*time.Minute))
+	if want := due.Add(3 * time.Hour); !next.Equal(want) {
+		t.Errorf("interval Advance = %v, want %v (stays on the original cadence)", next, want)
+	}
+
+	once, err := Parse("in 1h", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := once.Advance(once.First(now), now.Add(2*time.Hour)); ok {
+		t.Error("expected one-shot reminder not to advance")
+	}
+}
@@ -0,0 +1,204 @@
+// Package seed generates synthetic memories for demos, benchmarks and
+// reproducing ranking bugs. Generation is deterministic: the same scenario,
+// count and seed always give the same IDs, texts, types, tags and ages, so
+// a bug report can name the exact collection it was seen on. Ages are
+// relative to the time passed in, so retention behaves the same whenever
+// the fixture is loaded.
+package seed
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hsk-coder/clawbrain/internal/store"
+)
+
+// Field marks a payload as synthetic, holding the scenario that made it,
+// so seeded memories can be told apart and removed.
+const Field = "seed"
+
+// Memory is one generated memory.
+type Memory struct {
+	ID      string
+	Payload map[string]any
+}
+
+// scenario describes a kind of collection to generate.
+type scenario struct {
+	description string
+	// maxAge is how far back created_at is spread.
+	maxAge time.Duration
+	// generate fills in the text, type and scenario-specific fields.
+	generate func(rng *rand.Rand, payload map[string]any)
+}
+
+var scenarios = map[string]scenario{
+	"orientation": {
+		description: "an agent's working memory across a few projects: lessons, todos, facts and preferences",
+		maxAge:      180 * 24 * time.Hour,
+		generate:    orientation,
+	},
+	"retention": {
+		description: "a long-lived collection for forget and hygiene: mostly stale, some pinned, some personal",
+		maxAge:      720 * 24 * time.Hour,
+		generate:    retention,
+	},
+}
+
+// Scenarios returns the names of the available scenarios, sorted.
+func Scenarios() []string {
+	names := make([]string, 0, len(scenarios))
+	for name := range scenarios {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Describe returns a one-line description of a scenario.
+func Describe(name string) string {
+	return scenarios[name].description
+}
+
+// Generate returns n memories for the scenario, ready to store as they are:
+// each payload has its text, type, tags, created_at, last_accessed and
+// revision, and Field set to the scenario.
+func Generate(name string, n int, seed int64, now time.Time) ([]Memory, error) {
+	sc, ok := scenarios[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown scenario %q (want %s)", name, strings.Join(Scenarios(), ", "))
+	}
+	rng := rand.New(rand.NewSource(seed))
+	out := make([]Memory, n)
+	for i := range out {
+		id, err := uuid.NewRandomFromReader(rng)
+		if err != nil {
+			return nil, err
+		}
+		payload := map[string]any{Field: name, store.RevisionField: int64(1)}
+		sc.generate(rng, payload)
+
+		// Older memories are rarer, like in a collection that's been
+		// pruned along the way.
+		age := time.Duration(math.Pow(rng.Float64(), 2) * float64(sc.maxAge))
+		created := now.Add(-age)
+		accessed := created.Add(time.Duration(rng.Float64() * float64(age)))
+		payload["created_at"] = created.UTC().Format(time.RFC3339Nano)
+		payload["last_accessed"] = accessed.UTC().Format(time.RFC3339Nano)
+		out[i] = Memory{ID: id.String(), Payload: payload}
+	}
+	return out, nil
+}
+
+// Vectors returns n deterministic random unit vectors of the given size,
+// for loading a fixture without an embedding model. Their similarities are
+// meaningless, so they suit load and storage benchmarks, not ranking.
+func Vectors(n, size int, seed int64) [][]float32 {
+	rng := rand.New(rand.NewSource(seed))
+	out := make([][]float32, n)
+	for i := range out {
+		v := make([]float32, size)
+		norm := 0.0
+		for j := range v {
+			x := rng.NormFloat64()
+			v[j] = float32(x)
+			norm += x * x
+		}
+		norm = math.Sqrt(norm)
+		for j := range v {
+			v[j] = float32(float64(v[j]) / norm)
+		}
+		out[i] = v
+	}
+	return out
+}
+
+var (
+	projects = []string{"billing-api", "web-dashboard", "ingest-worker", "mobile-app", "search-service"}
+	people   = []string{"lico", "sam", "priya", "jordan", "mei"}
+	weekdays = []string{"monday", "tuesday", "wednesday", "thursday", "friday"}
+	tools    = []string{"postgres", "redis", "kafka", "terraform", "github actions", "grafana", "docker"}
+)
+
+// templates per type. %[1]s is a project, %[2]s a person, %[3]s a tool and
+// %[4]s a weekday; a template uses whichever it needs.
+var templates = map[string][]string{
+	"lesson": {
+		"%[1]s: retries against %[3]s need jitter or they stampede after an outage",
+		"%[1]s: always run the migration dry-run before touching %[3]s in production",
+		"%[1]s deploys fail silently when the %[3]s health check times out; check the logs first",
+		"%[2]s found that %[1]s caches go stale when %[3]s is restarted; flush them after",
+		"pinning the %[3]s version fixed the flaky %[1]s builds",
+	},
+	"todo": {
+		"%[1]s: rotate the %[3]s credentials before %[4]s",
+		"%[1]s: ask %[2]s to review the %[3]s alerting rules",
+		"%[1]s: write a runbook for restoring %[3]s from backup",
+		"%[1]s: follow up with %[2]s about the on-call handover on %[4]s",
+		"%[1]s: remove the deprecated %[3]s client once everything is migrated",
+	},
+	"fact": {
+		"%[1]s deploys go out on %[4]s afternoons",
+		"%[2]s owns the %[3]s setup for %[1]s",
+		"%[1]s stores its job queue in %[3]s",
+		"the %[1]s standup is on %[4]s at 10:00",
+		"%[1]s staging runs on its own %[3]s instance",
+	},
+	"preference": {
+		"%[2]s prefers squash merges on %[1]s",
+		"%[2]s wants %[3]s changes announced in the team channel first",
+		"%[2]s prefers short status updates on %[4]s",
+		"%[2]s likes code reviews to link the ticket",
+		"%[2]s would rather pair on %[3]s work than review it async",
+	},
+}
+
+// types in a fixed order, so generation doesn't depend on map iteration.
+var types = []string{"lesson", "todo", "fact", "preference"}
+
+// fill picks a template of the type and fills it in.
+func fill(rng *rand.Rand, memType string) (text, project, person string) {
+	project = pick(rng, projects)
+	person = pick(rng, people)
+	tmpl := pick(rng, templates[memType])
+	// Indexed verbs let a template skip slots without fmt complaining.
+	text = fmt.Sprintf(tmpl, project, person, pick(rng, tools), pick(rng, weekdays))
+	return text, project, person
+}
+
+func orientation(rng *rand.Rand, payload map[string]any) {
+	memType := pick(rng, types)
+	text, project, person := fill(rng, memType)
+	payload["text"] = text
+	payload[store.TypeField] = memType
+	payload["tags"] = []any{project}
+	payload[store.AuthorField] = "claw"
+	if memType == "preference" {
+		payload[store.SpeakerField] = person
+	}
+	if rng.Intn(10) == 0 {
+		payload["pinned"] = true
+	}
+}
+
+func retention(rng *rand.Rand, payload map[string]any) {
+	orientation(rng, payload)
+	switch rng.Intn(10) {
+	case 0:
+		payload[store.SensitivityField] = store.SensitivityPersonal
+		payload["text"] = fmt.Sprintf("%s's birthday is in %s", pick(rng, people), pick(rng, []string{"march", "june", "october"}))
+		payload[store.TypeField] = "fact"
+		delete(payload, "pinned")
+	case 1:
+		payload["pinned"] = true
+	}
+}
+
+func pick(rng *rand.Rand, from []string) string {
+	return from[rng.Intn(len(from))]
+}
@@ -0,0 +1,81 @@
+package seed
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGenerateDeterministic(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	a, err := Generate("orientation", 50, 7, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := Generate("orientation", 50, 7, now)
+	if !reflect.DeepEqual(a, b) {
+		t.Error("expected the same seed to generate the same memories")
+	}
+	c, _ := Generate("orientation", 50, 8, now)
+	if reflect.DeepEqual(a, c) {
+		t.Error("expected another seed to generate other memories")
+	}
+
+	for _, m := range a {
+		p := m.Payload
+		text, _ := p["text"].(string)
+		if text == "" || strings.Contains(text, "%!") {
+			t.Errorf("bad text %q", text)
+		}
+		if p[Field] != "orientation" || p["type"] == nil || p["tags"] == nil {
+			t.Errorf("missing fields in %v", p)
+		}
+		created, err := time.Parse(time.RFC3339Nano, p["created_at"].(string))
+		if err != nil || created.After(now) || now.Sub(created) > 180*24*time.Hour {
+			t.Errorf("created_at %v out of range", p["created_at"])
+		}
+		accessed, _ := time.Parse(time.RFC3339Nano, p["last_accessed"].(string))
+		if accessed.Before(created) || accessed.After(now) {
+			t.Errorf("last_accessed %v outside [created_at, now]", p["last_accessed"])
+		}
+	}
+
+	if _, err := Generate("nonsense", 1, 1, now); err == nil {
+		t.Error("expected an unknown scenario to fail")
+	}
+}
+
+func TestRetentionScenario(t *testing.T) {
+	memories, err := Generate("retention", 200, 1, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	personal := 0
+	for _, m := range memories {
+		if m.Payload["sensitivity"] == "personal" {
+			personal++
+		}
+	}
+	if personal == 0 || personal == len(memories) {
+		t.Errorf("expected some personal memories, got %d of %d", personal, len(memories))
+	}
+}
+
+func TestVectors(t *testing.T) {
+	vs := Vectors(3, 8, 1)
+	if len(vs) != 3 || len(vs[0]) != 8 {
+		t.Fatalf("unexpected shape %d x %d", len(vs), len(vs[0]))
+	}
+	norm := 0.0
+	for _, x := range vs[0] {
+		norm += float64(x) * float64(x)
+	}
+	if math.Abs(norm-1) > 1e-5 {
+		t.Errorf("expected a unit vector, got norm %g", norm)
+	}
+	if !reflect.DeepEqual(vs, Vectors(3, 8, 1)) {
+		t.Error("expected vectors to be deterministic")
+	}
+}
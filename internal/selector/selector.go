@@ -0,0 +1,114 @@
+// Package selector projects JSON output down to requested fields, like a
+// jq path list: "id,score,payload.text" keeps those three fields of each
+// result and drops the rest, so less flows into an agent's context and
+// shell pipelines needn't dig for what they want.
+package selector
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Selector is a parsed list of dotted field paths. A nil Selector keeps
+// everything.
+type Selector [][]string
+
+// Parse parses a comma-separated list of dotted paths such as
+// "id,score,payload.text". An empty spec gives a nil Selector.
+func Parse(spec string) (Selector, error) {
+	if strings.TrimSpace(spec) == "" {
+		return nil, nil
+	}
+	var sel Selector
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		path := strings.Split(field, ".")
+		for _, part := range path {
+			if part == "" {
+				return nil, fmt.Errorf("invalid field %q in --select: want dotted names like payload.text", field)
+			}
+		}
+		sel = append(sel, path)
+	}
+	return sel, nil
+}
+
+// Apply returns v with only the selected fields. v is anything that
+// encodes as a JSON object; fields it doesn't have are left out rather
+// than reported, since payloads differ from memory to memory. Nested paths
+// keep their nesting: payload.text gives {"payload": {"text": ...}}.
+func (sel Selector) Apply(v any) (map[string]any, error) {
+	obj, err := object(v)
+	if err != nil {
+		return nil, err
+	}
+	if sel == nil {
+		return obj, nil
+	}
+	out := map[string]any{}
+	for _, path := range sel {
+		copyPath(out, obj, path)
+	}
+	return out, nil
+}
+
+// ApplyAll is Apply over a slice of objects.
+func (sel Selector) ApplyAll(v any) ([]map[string]any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var items []json.RawMessage
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("select: not a list: %w", err)
+	}
+	out := make([]map[string]any, len(items))
+	for i, item := range items {
+		if out[i], err = sel.Apply(item); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// object turns v into a generic JSON object.
+func object(v any) (map[string]any, error) {
+	data, ok := v.(json.RawMessage)
+	if !ok {
+		var err error
+		if data, err = json.Marshal(v); err != nil {
+			return nil, err
+		}
+	}
+	var obj map[string]any
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, fmt.Errorf("select: not an object: %w", err)
+	}
+	return obj, nil
+}
+
+// copyPath copies the value at path from src into dst, creating the
+// intermediate objects.
+func copyPath(dst, src map[string]any, path []string) {
+	value, ok := src[path[0]]
+	if !ok {
+		return
+	}
+	if len(path) == 1 {
+		dst[path[0]] = value
+		return
+	}
+	inner, ok := value.(map[string]any)
+	if !ok {
+		return
+	}
+	next, ok := dst[path[0]].(map[string]any)
+	if !ok {
+		next = map[string]any{}
+	}
+	copyPath(next, inner, path[1:])
+	if len(next) > 0 {
+		dst[path[0]] = next
+	}
+}
@@ -0,0 +1,50 @@
+package selector
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	sel, err := Parse(" id, payload.text ")
+	if err != nil || !reflect.DeepEqual(sel, Selector{{"id"}, {"payload", "text"}}) {
+		t.Errorf("Parse = %v, %v", sel, err)
+	}
+	if sel, err := Parse(""); sel != nil || err != nil {
+		t.Errorf("empty spec should select everything, got %v, %v", sel, err)
+	}
+	for _, spec := range []string{"id,", "payload.", ".text", "id,,score"} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("expected %q to be rejected", spec)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	type result struct {
+		ID      string         `json:"id"`
+		Score   float32        `json:"score"`
+		Payload map[string]any `json:"payload"`
+	}
+	results := []result{
+		{"a", 0.5, map[string]any{"text": "one", "source": "chat", "meta": map[string]any{"lang": "en", "len": 3}}},
+		{"b", 0.25, map[string]any{"source": "chat"}},
+	}
+	sel, _ := Parse("id,payload.text,payload.meta.lang,missing")
+	got, err := sel.ApplyAll(results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []map[string]any{
+		{"id": "a", "payload": map[string]any{"text": "one", "meta": map[string]any{"lang": "en"}}},
+		{"id": "b"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ApplyAll = %v, want %v", got, want)
+	}
+
+	all, err := Selector(nil).Apply(results[1])
+	if err != nil || len(all) != 3 {
+		t.Errorf("nil selector should keep every field, got %v, %v", all, err)
+	}
+}
@@ -0,0 +1,96 @@
+// Package server holds the HTTP plumbing behind clawbrain serve: response
+// writing and conditional requests. The handlers themselves live with the
+// commands they mirror.
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/hsk-coder/clawbrain/internal/backoff"
+	"github.com/hsk-coder/clawbrain/internal/reqid"
+)
+
+// ETag returns a weak entity tag for a response computed from the memories
+// at version for the request identified by key. Weak, because the same
+// results may serialize differently (e.g. timestamps refreshed by reads).
+func ETag(version, key string) string {
+	sum := sha256.Sum256([]byte(version + "\x00" + key))
+	return `W/"` + hex.EncodeToString(sum[:12]) + `"`
+}
+
+// NotModified reports whether an If-None-Match header matches etag, using
+// the weak comparison RFC 9110 prescribes for If-None-Match.
+func NotModified(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	want := strings.TrimPrefix(etag, "W/")
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == want {
+			return true
+		}
+	}
+	return false
+}
+
+// Conditional handles If-None-Match for a response identified by key at
+// version. It sets the ETag header and, if the client already has this
+// response, answers 304 Not Modified and returns true: the caller should
+// not compute the response.
+func Conditional(w http.ResponseWriter, r *http.Request, version, key string) bool {
+	etag := ETag(version, key)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if NotModified(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	return false
+}
+
+// WriteJSON writes v as the JSON response body with the given status. An
+// object answering a request RequestID saw gets its request_id.
+func WriteJSON(w http.ResponseWriter, status int, v any) {
+	if m, ok := v.(map[string]any); ok {
+		if id := w.Header().Get(reqid.Header); id != "" {
+			if _, set := m[reqid.Field]; !set {
+				m[reqid.Field] = id
+			}
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// RequestID gives each request an ID: the caller's, if it sent a valid one
+// in the X-Request-Id header, or a new one. The request's context carries
+// it to Qdrant and Ollama, and the response returns it in the header.
+func RequestID(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(reqid.Header)
+		if !reqid.Valid(id) {
+			id = reqid.New()
+		}
+		w.Header().Set(reqid.Header, id)
+		h.ServeHTTP(w, r.WithContext(reqid.With(r.Context(), id)))
+	})
+}
+
+// WriteError writes an error in the CLI's {"status":"error"} shape.
+func WriteError(w http.ResponseWriter, status int, message string) {
+	WriteJSON(w, status, map[string]any{"status": "error", "message": message})
+}
+
+// WriteBackoff answers 503 Service Unavailable with a Retry-After header and
+// the CLI's {"status":"backoff"} shape, for a backend that is overloaded.
+func WriteBackoff(w http.ResponseWriter, e *backoff.Error) {
+	w.Header().Set("Retry-After", strconv.Itoa(e.Seconds()))
+	WriteJSON(w, http.StatusServiceUnavailable, e.Response())
+}
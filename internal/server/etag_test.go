@@ -0,0 +1,98 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hsk-coder/clawbrain/internal/backoff"
+	"github.com/hsk-coder/clawbrain/internal/reqid"
+)
+
+func TestETag(t *testing.T) {
+	a := ETag("17-3", "/search?query=deploy")
+	if a != ETag("17-3", "/search?query=deploy") {
+		t.Error("expected the same version and key to give the same tag")
+	}
+	if a == ETag("18-3", "/search?query=deploy") || a == ETag("17-3", "/search?query=tls") {
+		t.Error("expected a new version or key to give a new tag")
+	}
+	if a[:3] != `W/"` {
+		t.Errorf("expected a weak tag, got %s", a)
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	etag := ETag("1", "k")
+	strong := etag[2:]
+	for _, header := range []string{etag, strong, `"other", ` + etag, "*"} {
+		if !NotModified(header, etag) {
+			t.Errorf("expected %q to match", header)
+		}
+	}
+	for _, header := range []string{"", `"other"`, `W/"other"`} {
+		if NotModified(header, etag) {
+			t.Errorf("expected %q not to match", header)
+		}
+	}
+}
+
+func TestConditional(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/memories", nil)
+	w := httptest.NewRecorder()
+	if Conditional(w, r, "1", "k") {
+		t.Fatal("expected a request without If-None-Match to be served")
+	}
+	etag := w.Header().Get("ETag")
+
+	r.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	if !Conditional(w, r, "1", "k") || w.Code != http.StatusNotModified {
+		t.Errorf("expected 304 for a matching tag, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	if Conditional(w, r, "2", "k") {
+		t.Error("expected a changed version to be served again")
+	}
+}
+
+func TestWriteBackoff(t *testing.T) {
+	w := httptest.NewRecorder()
+	WriteBackoff(w, &backoff.Error{Backend: "qdrant", RetryAfter: 7 * time.Second, Reason: "rate limited"})
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "7" {
+		t.Errorf("expected 503 with Retry-After 7, got %d %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	if !strings.Contains(w.Body.String(), `"status":"backoff"`) {
+		t.Errorf("expected a backoff body, got %s", w.Body)
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	var seen string
+	h := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = reqid.From(r.Context())
+		WriteJSON(w, http.StatusOK, map[string]any{"status": "ok"})
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/search", nil)
+	r.Header.Set(reqid.Header, "agent-7:turn.12")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if seen != "agent-7:turn.12" || w.Header().Get(reqid.Header) != "agent-7:turn.12" {
+		t.Errorf("expected the caller's ID kept, got %q in context and %q in the header", seen, w.Header().Get(reqid.Header))
+	}
+	if !strings.Contains(w.Body.String(), `"request_id":"agent-7:turn.12"`) {
+		t.Errorf("expected the ID in the body, got %s", w.Body)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/search", nil)
+	r.Header.Set(reqid.Header, "not a valid id")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if id := w.Header().Get(reqid.Header); !reqid.Valid(id) || id != seen {
+		t.Errorf("expected an invalid ID replaced by a new one, got %q (context %q)", id, seen)
+	}
+}
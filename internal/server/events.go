@@ -0,0 +1,165 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+
+	"github.com/hsk-coder/clawbrain/internal/store"
+)
+
+// Memory event types streamed by /ws.
+const (
+	EventAdded   = "added"
+	EventUpdated = "updated"
+	EventDeleted = "deleted"
+)
+
+// Event is one change to the stored memories. Memory is nil for deletions.
+type Event struct {
+	Type   string        `json:"event"`
+	ID     string        `json:"id"`
+	Memory *store.Result `json:"memory,omitempty"`
+}
+
+// Snapshot is the state of every memory at one collection version, keyed by
+// ID, used to tell what changed between two versions.
+type Snapshot map[string]Entry
+
+// Entry is one memory in a snapshot.
+type Entry struct {
+	Memory      store.Result
+	fingerprint string
+}
+
+// NewSnapshot records the given memories.
+func NewSnapshot(memories []store.Result) Snapshot {
+	snap := make(Snapshot, len(memories))
+	for _, m := range memories {
+		snap[m.ID] = Entry{Memory: m, fingerprint: fingerprint(m)}
+	}
+	return snap
+}
+
+// fingerprint identifies a memory's content. last_accessed is left out: a
+// memory that was only read hasn't changed.
+func fingerprint(m store.Result) string {
+	payload := make(map[string]any, len(m.Payload))
+	for k, v := range m.Payload {
+		if k != "last_accessed" {
+			payload[k] = v
+		}
+	}
+	data, _ := json.Marshal(payload) // map keys marshal sorted
+	return string(data)
+}
+
+// Diff returns the events that lead from prev to next: additions and
+// updates, oldest memory first, then deletions.
+func Diff(prev, next Snapshot) []Event {
+	var events []Event
+	for id, e := range next {
+		old, ok := prev[id]
+		switch {
+		case !ok:
+			events = append(events, Event{Type: EventAdded, ID: id, Memory: &e.Memory})
+		case old.fingerprint != e.fingerprint:
+			events = append(events, Event{Type: EventUpdated, ID: id, Memory: &e.Memory})
+		}
+	}
+	for id := range prev {
+		if _, ok := next[id]; !ok {
+			events = append(events, Event{Type: EventDeleted, ID: id})
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		if (events[i].Type == EventDeleted) != (events[j].Type == EventDeleted) {
+			return events[j].Type == EventDeleted
+		}
+		return createdAt(events[i]) < createdAt(events[j]) ||
+			createdAt(events[i]) == createdAt(events[j]) && events[i].ID < events[j].ID
+	})
+	return events
+}
+
+// createdAt returns an event's memory's created_at, "" for deletions. The
+// RFC 3339 timestamps the store writes sort as strings.
+func createdAt(e Event) string {
+	if e.Memory == nil {
+		return ""
+	}
+	s, _ := e.Memory.Payload["created_at"].(string)
+	return s
+}
+
+// eventBuffer is how many change batches a subscriber may fall behind
+// before it is dropped.
+const eventBuffer = 16
+
+// Hub fans change batches out to subscribers. A subscriber that stops
+// reading is dropped rather than allowed to stall everyone else.
+type Hub struct {
+	mu   sync.Mutex
+	subs map[chan []Event]struct{}
+}
+
+// NewHub returns a hub without subscribers.
+func NewHub() *Hub {
+	return &Hub{subs: make(map[chan []Event]struct{})}
+}
+
+// Subscribe returns a channel receiving every published batch, and a
+// function ending the subscription. The channel is closed when the
+// subscription ends or the subscriber falls too far behind.
+func (h *Hub) Subscribe() (<-chan []Event, func()) {
+	ch := make(chan []Event, eventBuffer)
+	h.mu.Lock()
+	h.subs[ch] = struct{}{}
+	h.mu.Unlock()
+	return ch, func() { h.drop(ch) }
+}
+
+// Publish sends a batch of events to every subscriber without blocking.
+func (h *Hub) Publish(events []Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs {
+		select {
+		case ch <- events:
+		default:
+			delete(h.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// Subscribers returns how many subscribers there are.
+func (h *Hub) Subscribers() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subs)
+}
+
+func (h *Hub) drop(ch chan []Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subs[ch]; ok {
+		delete(h.subs, ch)
+		close(ch)
+	}
+}
+
+// SameOrigin accepts WebSocket handshakes from clients that send no Origin
+// (agents, scripts) and from pages served by this server. It refuses other
+// web pages, which could otherwise read memories through the user's
+// browser.
+func SameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || origin == "null" {
+		return origin == ""
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hsk-coder/clawbrain/internal/store"
+)
+
+func TestDiff(t *testing.T) {
+	memory := func(id, text, created, accessed string) store.Result {
+		return store.Result{ID: id, Payload: map[string]any{"text": text, "created_at": created, "last_accessed": accessed}}
+	}
+	prev := NewSnapshot([]store.Result{
+		memory("a", "deploys on tuesdays", "2026-10-01T00:00:00Z", "2026-10-01T00:00:00Z"),
+		memory("b", "tls certs expire in may", "2026-10-02T00:00:00Z", "2026-10-02T00:00:00Z"),
+		memory("c", "old note", "2026-10-03T00:00:00Z", "2026-10-03T00:00:00Z"),
+	})
+	next := NewSnapshot([]store.Result{
+		memory("a", "deploys on tuesdays", "2026-10-01T00:00:00Z", "2026-10-14T00:00:00Z"), // only read
+		memory("b", "tls certs expire in june", "2026-10-02T00:00:00Z", "2026-10-02T00:00:00Z"),
+		memory("d", "rotate the api keys", "2026-10-04T00:00:00Z", "2026-10-04T00:00:00Z"),
+	})
+
+	events := Diff(prev, next)
+	want := []struct{ typ, id string }{{EventUpdated, "b"}, {EventAdded, "d"}, {EventDeleted, "c"}}
+	if len(events) != len(want) {
+		t.Fatalf("expected %d events, got %+v", len(want), events)
+	}
+	for i, w := range want {
+		if events[i].Type != w.typ || events[i].ID != w.id {
+			t.Errorf("event %d: expected %s %s, got %s %s", i, w.typ, w.id, events[i].Type, events[i].ID)
+		}
+	}
+	if events[0].Memory.Payload["text"] != "tls certs expire in june" || events[2].Memory != nil {
+		t.Errorf("expected updates to carry the new memory and deletions none, got %+v", events)
+	}
+	if len(Diff(next, next)) != 0 {
+		t.Error("expected no events between identical snapshots")
+	}
+}
+
+func TestHub(t *testing.T) {
+	h := NewHub()
+	ch, cancel := h.Subscribe()
+	slow, _ := h.Subscribe()
+	if h.Subscribers() != 2 {
+		t.Fatalf("expected 2 subscribers, got %d", h.Subscribers())
+	}
+
+	h.Publish([]Event{{Type: EventDeleted, ID: "a"}})
+	if batch := <-ch; len(batch) != 1 || batch[0].ID != "a" {
+		t.Errorf("expected the published batch, got %+v", batch)
+	}
+
+	// A subscriber that never reads is dropped once its buffer is full.
+	for range eventBuffer {
+		h.Publish(nil)
+		<-ch
+	}
+	for range slow { // ends only once the hub closes it
+	}
+	if h.Subscribers() != 1 {
+		t.Errorf("expected the slow subscriber to be dropped, got %d", h.Subscribers())
+	}
+
+	cancel()
+	cancel()
+	if _, ok := <-ch; ok || h.Subscribers() != 0 {
+		t.Error("expected cancel to close the channel and unsubscribe")
+	}
+}
+
+func TestSameOrigin(t *testing.T) {
+	tests := []struct {
+		origin string
+		ok     bool
+	}{
+		{"", true},
+		{"http://127.0.0.1:7411", true},
+		{"http://evil.example", false},
+		{"null", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "http://127.0.0.1:7411/ws", nil)
+		if tt.origin != "" {
+			r.Header.Set("Origin", tt.origin)
+		}
+		if got := SameOrigin(r); got != tt.ok {
+			t.Errorf("Origin %q: expected %t, got %t", tt.origin, tt.ok, got)
+		}
+	}
+}
@@ -0,0 +1,18 @@
+package server
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+//go:embed ui
+var uiFiles embed.FS
+
+// UI serves the admin dashboard: a single page that lists, searches and
+// inspects memories, pins and deletes them, and charts the stats and sync
+// status, all through the server's own JSON endpoints.
+func UI() http.Handler {
+	root, _ := fs.Sub(uiFiles, "ui")
+	return http.FileServerFS(root)
+}
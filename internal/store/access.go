@@ -0,0 +1,119 @@
+package store
+
+import (
+	"context"
+	"log"
+	"math"
+	"time"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// Timestamps every memory carries, in RFC 3339: when it was stored, and
+// when it was last stored, fetched by ID or returned by a search.
+const (
+	CreatedAtField    = "created_at"
+	LastAccessedField = "last_accessed"
+)
+
+// AccessCountField counts how many times a memory has been recalled: fetched
+// by ID or returned by a search. Memories stored before it was tracked have
+// none and count as never recalled.
+const AccessCountField = "access_count"
+
+// DefaultFrequencyWeight is how much forget stretches a memory's TTL for
+// being recalled often, unless told otherwise (see RetentionTTL).
+const DefaultFrequencyWeight = 1.0
+
+// AccessCount returns how many times the memory has been recalled.
+func AccessCount(payload map[string]any) int64 {
+	switch v := payload[AccessCountField].(type) {
+	case int64:
+		return v
+	case float64:
+		return int64(v)
+	}
+	return 0
+}
+
+// RetentionTTL returns how long forget keeps a memory recalled count times
+// since it was last accessed: ttl stretched by 1 + weight*log2(1+count).
+// At weight 1 a memory never recalled keeps ttl, one recalled once twice
+// that, three times three times that and seven times four times that, so
+// frequently used memories outlive a quiet spell while the stretch grows
+// ever slower. A weight of 0 gives plain ttl.
+func RetentionTTL(ttl time.Duration, count int64, weight float64) time.Duration {
+	if weight <= 0 || count <= 0 {
+		return ttl
+	}
+	return time.Duration(float64(ttl) * (1 + weight*math.Log2(1+float64(count))))
+}
+
+// SetFrequencyWeight makes Forget, ForgetPersonal and Stale weigh how often a
+// memory was recalled as well as when (see RetentionTTL). The default, 0,
+// forgets on last_accessed alone.
+func (s *Store) SetFrequencyWeight(weight float64) {
+	s.frequencyWeight = weight
+}
+
+// outlived reports whether a memory has gone unaccessed past its retention
+// TTL at the store's frequency weight. Memories without a last_accessed are
+// kept, as the Qdrant datetime filter never matches a missing field.
+func (s *Store) outlived(payload map[string]any, ttl time.Duration, now time.Time) bool {
+	la, ok := payload["last_accessed"].(string)
+	if !ok {
+		return false
+	}
+	accessed, err := time.Parse(time.RFC3339Nano, la)
+	if err != nil {
+		return false
+	}
+	return accessed.Before(now.Add(-RetentionTTL(ttl, AccessCount(payload), s.frequencyWeight)))
+}
+
+// Touch marks the given memories as recalled in as few requests as
+// possible: last_accessed is set to now and access_count goes up by one
+// from the count in each result's payload. It is for callers that fetch
+// candidates without touching them (e.g. FindSimilar) and only then decide
+// which were returned. Like markAccessed, failures are logged rather than
+// returned.
+func (s *Store) Touch(ctx context.Context, results []Result) {
+	if len(results) == 0 {
+		return
+	}
+	// One request per distinct new count: searches mostly return memories
+	// recalled a similar number of times.
+	byCount := map[int64][]*qdrant.PointId{}
+	var counts []int64
+	for _, r := range results {
+		next := AccessCount(r.Payload) + 1
+		if _, ok := byCount[next]; !ok {
+			counts = append(counts, next)
+		}
+		byCount[next] = append(byCount[next], qdrant.NewIDUUID(r.ID))
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	for _, count := range counts {
+		s.markAccessed(ctx, now, count, byCount[count]...)
+	}
+}
+
+// markAccessed sets last_accessed and access_count on points. Errors are
+// logged but not propagated — a failed timestamp update should not cause a
+// retrieval to fail.
+func (s *Store) markAccessed(ctx context.Context, timestamp string, count int64, ids ...*qdrant.PointId) {
+	wait := true
+	_, err := s.client.SetPayload(ctx, &qdrant.SetPayloadPoints{
+		CollectionName: s.collection,
+		Wait:           &wait,
+		Payload: qdrant.NewValueMap(map[string]any{
+			"last_accessed":  timestamp, // RFC3339Nano for sub-second precision
+			AccessCountField: count,
+		}),
+		PointsSelector: s.selector(ids...),
+	})
+	if err != nil {
+		log.Printf("warning: failed to update last_accessed on %d memories: %v", len(ids), err)
+	}
+}
@@ -0,0 +1,102 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRetentionTTL(t *testing.T) {
+	day := 24 * time.Hour
+	tests := []struct {
+		count  int64
+		weight float64
+		want   time.Duration
+	}{
+		{0, 1, 30 * day},
+		{1, 1, 60 * day},
+		{3, 1, 90 * day},
+		{7, 1, 120 * day},
+		{3, 0.5, 60 * day},
+		{7, 0, 30 * day},
+	}
+	for _, tt := range tests {
+		if got := RetentionTTL(30*day, tt.count, tt.weight); got != tt.want {
+			t.Errorf("RetentionTTL(30d, %d, %g) = %v, want %v", tt.count, tt.weight, got, tt.want)
+		}
+	}
+}
+
+func TestAccessCount(t *testing.T) {
+	for _, tt := range []struct {
+		payload map[string]any
+		want    int64
+	}{
+		{map[string]any{}, 0},
+		{map[string]any{AccessCountField: int64(4)}, 4},
+		// Payloads decoded from JSON (e.g. cached search results) hold floats.
+		{map[string]any{AccessCountField: float64(4)}, 4},
+	} {
+		if got := AccessCount(tt.payload); got != tt.want {
+			t.Errorf("AccessCount(%v) = %d, want %d", tt.payload, got, tt.want)
+		}
+	}
+}
+
+func TestAccessCountTracked(t *testing.T) {
+	s := testStore(t)
+	defer s.Close()
+	defer cleanupMemories(t, s)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	id, err := s.Add(ctx, "", []float32{0.1, 0.2, 0.3, 0.4}, map[string]any{"text": "deploys go out on tuesdays"})
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if _, err := s.Retrieve(ctx, []float32{0.1, 0.2, 0.3, 0.4}, 0.99, 1); err != nil {
+		t.Fatalf("Retrieve failed: %v", err)
+	}
+	if _, err := s.Get(ctx, id); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	r, err := s.Peek(ctx, id)
+	if err != nil || r == nil {
+		t.Fatalf("Peek failed: %v", err)
+	}
+	if got := AccessCount(r.Payload); got != 2 {
+		t.Errorf("expected 2 recalls after a search and a get, got %d", got)
+	}
+}
+
+func TestForgetKeepsFrequentlyRecalled(t *testing.T) {
+	s := testStore(t)
+	defer s.Close()
+	defer cleanupMemories(t, s)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// Both last accessed 45 days ago; one was recalled often before that.
+	accessed := time.Now().UTC().Add(-45 * 24 * time.Hour).Format(time.RFC3339Nano)
+	err := s.Import(ctx, []Point{
+		{ID: "4f8a7c1e-2b3d-4e5f-8a9b-0c1d2e3f4a5b", Vector: []float32{0.1, 0.2, 0.3, 0.4}, Payload: map[string]any{"text": "recalled often", "last_accessed": accessed, AccessCountField: int64(3)}},
+		{ID: "9e2a7c1e-2b3d-4e5f-8a9b-0c1d2e3f4a5b", Vector: []float32{0.4, 0.3, 0.2, 0.1}, Payload: map[string]any{"text": "never recalled", "last_accessed": accessed}},
+	})
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+
+	s.SetFrequencyWeight(DefaultFrequencyWeight)
+	deleted, err := s.Forget(ctx, 30*24*time.Hour)
+	if err != nil {
+		t.Fatalf("Forget failed: %v", err)
+	}
+	if deleted != 1 {
+		t.Fatalf("expected only the never-recalled memory forgotten, got %d deletions", deleted)
+	}
+	if r, _ := s.Peek(ctx, "4f8a7c1e-2b3d-4e5f-8a9b-0c1d2e3f4a5b"); r == nil {
+		t.Error("expected the often-recalled memory to survive")
+	}
+}
@@ -0,0 +1,102 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/qdrant/go-client/qdrant"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Archive fields. An archived memory is kept, but hidden from search unless
+// asked for, until purge removes it for good.
+const (
+	ArchivedField      = "archived"
+	ArchivedAtField    = "archived_at"
+	ArchiveReasonField = "archive_reason"
+)
+
+// Archive reasons: forget archives stale memories, purge --archive the
+// memories about an entity, and unsync --archive those of a deleted file.
+const (
+	ArchiveReasonForget = "forget"
+	ArchiveReasonPurge  = "purge"
+	ArchiveReasonUnsync = "unsync"
+)
+
+// IsArchived reports whether the payload marks the memory as archived.
+func IsArchived(payload map[string]any) bool {
+	archived, _ := payload[ArchivedField].(bool)
+	return archived
+}
+
+// ArchivePayload is the payload update archiving a memory at the given time
+// (RFC 3339) for reason.
+func ArchivePayload(at, reason string) map[string]any {
+	return map[string]any{ArchivedField: true, ArchivedAtField: at, ArchiveReasonField: reason}
+}
+
+// notArchived matches memories that aren't archived.
+func notArchived() *qdrant.Condition {
+	return qdrant.NewFilterAsCondition(&qdrant.Filter{
+		MustNot: []*qdrant.Condition{qdrant.NewMatchBool(ArchivedField, true)},
+	})
+}
+
+// StaleUnarchived returns the memories Archive would archive with the same
+// TTL: those Stale returns that aren't archived already.
+func (s *Store) StaleUnarchived(ctx context.Context, ttl time.Duration) ([]Result, error) {
+	return s.staleIfExists(ctx, ttl, notArchived())
+}
+
+// Archive marks the memories StaleUnarchived returns as archived by forget,
+// instead of deleting them, and returns them. Memories archived already keep
+// their original archived_at, so the grace period purge gives them doesn't
+// restart.
+func (s *Store) Archive(ctx context.Context, ttl time.Duration) ([]Result, error) {
+	stale, err := s.StaleUnarchived(ctx, ttl)
+	if err != nil || len(stale) == 0 {
+		return stale, err
+	}
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	updates := make(map[string]map[string]any, len(stale))
+	for _, r := range stale {
+		updates[r.ID] = ArchivePayload(now, ArchiveReasonForget)
+	}
+	if err := s.SetPayloads(ctx, updates); err != nil {
+		return nil, err
+	}
+	return stale, nil
+}
+
+// ArchivedBefore returns the unlocked memories archived before cutoff.
+func (s *Store) ArchivedBefore(ctx context.Context, cutoff time.Time) ([]Result, error) {
+	exists, err := s.client.CollectionExists(ctx, s.collection)
+	if err != nil {
+		return nil, fmt.Errorf("check collection: %w", err)
+	}
+	if !exists {
+		return []Result{}, nil
+	}
+	results, err := s.scrollPoints(ctx, &qdrant.Filter{
+		Must: []*qdrant.Condition{
+			qdrant.NewMatchBool(ArchivedField, true),
+			qdrant.NewDatetimeRange(ArchivedAtField, &qdrant.DatetimeRange{
+				Lt: timestamppb.New(cutoff),
+			}),
+		},
+		MustNot: []*qdrant.Condition{
+			qdrant.NewMatchBool("locked", true),
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scroll archived: %w", err)
+	}
+	return results, nil
+}
+
+// Unarchive brings archived memories back: they show up in search again.
+func (s *Store) Unarchive(ctx context.Context, ids []string) error {
+	return s.DeletePayloadKeys(ctx, ids, ArchivedField, ArchivedAtField, ArchiveReasonField)
+}
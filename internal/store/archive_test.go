@@ -0,0 +1,15 @@
+package store
+
+import "testing"
+
+func TestArchivePayload(t *testing.T) {
+	payload := ArchivePayload("2026-10-01T00:00:00Z", ArchiveReasonForget)
+	if !IsArchived(payload) || payload[ArchivedAtField] != "2026-10-01T00:00:00Z" || payload[ArchiveReasonField] != ArchiveReasonForget {
+		t.Errorf("unexpected archive payload %v", payload)
+	}
+	for _, p := range []map[string]any{{}, {ArchivedField: false}, {ArchivedField: "true"}} {
+		if IsArchived(p) {
+			t.Errorf("expected %v not to count as archived", p)
+		}
+	}
+}
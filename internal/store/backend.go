@@ -0,0 +1,46 @@
+package store
+
+import (
+	"context"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// Backend is the vector database a Store keeps its memories in. Its methods
+// are the part of the Qdrant client the Store calls, with the same
+// signatures, so *qdrant.Client is a Backend as it is; FileBackend is a
+// pure-Go one on local disk, for small corpora with no Qdrant to run, and
+// SQLiteBackend keeps them in one SQLite database.
+type Backend interface {
+	Close() error
+	HealthCheck(ctx context.Context) (*qdrant.HealthCheckReply, error)
+
+	CollectionExists(ctx context.Context, collectionName string) (bool, error)
+	ListCollections(ctx context.Context) ([]string, error)
+	CreateCollection(ctx context.Context, request *qdrant.CreateCollection) error
+	UpdateCollection(ctx context.Context, request *qdrant.UpdateCollection) error
+	DeleteCollection(ctx context.Context, collectionName string) error
+	GetCollectionInfo(ctx context.Context, collectionName string) (*qdrant.CollectionInfo, error)
+	CreateFieldIndex(ctx context.Context, request *qdrant.CreateFieldIndexCollection) (*qdrant.UpdateResult, error)
+
+	Upsert(ctx context.Context, request *qdrant.UpsertPoints) (*qdrant.UpdateResult, error)
+	Delete(ctx context.Context, request *qdrant.DeletePoints) (*qdrant.UpdateResult, error)
+	SetPayload(ctx context.Context, request *qdrant.SetPayloadPoints) (*qdrant.UpdateResult, error)
+	DeletePayload(ctx context.Context, request *qdrant.DeletePayloadPoints) (*qdrant.UpdateResult, error)
+	UpdateBatch(ctx context.Context, request *qdrant.UpdateBatchPoints) ([]*qdrant.UpdateResult, error)
+
+	Get(ctx context.Context, request *qdrant.GetPoints) ([]*qdrant.RetrievedPoint, error)
+	Query(ctx context.Context, request *qdrant.QueryPoints) ([]*qdrant.ScoredPoint, error)
+	ScrollAndOffset(ctx context.Context, request *qdrant.ScrollPoints) ([]*qdrant.RetrievedPoint, *qdrant.PointId, error)
+	Count(ctx context.Context, request *qdrant.CountPoints) (uint64, error)
+	Facet(ctx context.Context, request *qdrant.FacetCounts) ([]*qdrant.FacetHit, error)
+}
+
+// Backends accepted by the CLI's --backend flag.
+const (
+	BackendQdrant = "qdrant"
+	BackendFile   = "file"
+	BackendSQLite = "sqlite"
+)
+
+var _ Backend = (*qdrant.Client)(nil)
@@ -0,0 +1,117 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// Collection metadata keys recording the embedding model a collection was
+// created with and the size of its vectors. Vectors from another model
+// can't be compared with them, even when the sizes happen to match.
+const (
+	embeddingModelKey      = "embedding_model"
+	embeddingDimensionsKey = "embedding_dimensions"
+)
+
+// EmbeddedByField names, in the payload of a memory embedded through an
+// embedder chain, the provider that produced its vector. All the providers
+// of a chain serve the collection's model, so it is a record, not a
+// compatibility concern.
+const EmbeddedByField = "embedded_by"
+
+// Embedding describes the vectors a collection holds.
+type Embedding struct {
+	Exists     bool   `json:"exists"`
+	Model      string `json:"model,omitempty"` // "" for collections made before models were recorded
+	Dimensions uint64 `json:"dimensions"`
+}
+
+// SetModel sets the embedding model recorded in the metadata of a
+// collection the store creates.
+func (s *Store) SetModel(model string) {
+	s.model = model
+}
+
+// Embedding reads the model and vector size of the store's collection.
+func (s *Store) Embedding(ctx context.Context) (Embedding, error) {
+	exists, err := s.client.CollectionExists(ctx, s.collection)
+	if err != nil {
+		return Embedding{}, fmt.Errorf("check collection: %w", err)
+	}
+	if !exists {
+		return Embedding{}, nil
+	}
+	info, err := s.client.GetCollectionInfo(ctx, s.collection)
+	if err != nil {
+		return Embedding{}, fmt.Errorf("collection info: %w", err)
+	}
+	return Embedding{
+		Exists:     true,
+		Model:      info.GetConfig().GetMetadata()[embeddingModelKey].GetStringValue(),
+		Dimensions: info.GetConfig().GetParams().GetVectorsConfig().GetParams().GetSize(),
+	}, nil
+}
+
+// EmbeddingError reports vectors that don't fit the collection: another
+// size, or another model.
+type EmbeddingError struct {
+	Collection Embedding
+	Model      string // "" when the vector came from the caller, not a model
+	Dimensions int
+}
+
+func (e *EmbeddingError) Error() string {
+	stored := fmt.Sprintf("%d-dimension vectors", e.Collection.Dimensions)
+	if e.Collection.Model != "" {
+		stored += " from " + e.Collection.Model
+	}
+	given := fmt.Sprintf("a %d-dimension vector", e.Dimensions)
+	if e.Model != "" {
+		given += " from " + e.Model
+	}
+	hint := "re-embed the collection with clawbrain migrate-embeddings"
+	if e.Collection.Model != "" {
+		hint = fmt.Sprintf("use --model %s, or %s", e.Collection.Model, hint)
+	}
+	return fmt.Sprintf("the collection holds %s but this call has %s; %s", stored, given, hint)
+}
+
+// CheckEmbedding returns an *EmbeddingError if a vector of dims made by
+// model doesn't fit the collection. An empty model skips the model check,
+// for vectors the caller brought; so does a collection that never recorded
+// its model. A missing collection fits anything: it will be created to
+// fit. The collection is only looked up once per Store.
+func (s *Store) CheckEmbedding(ctx context.Context, model string, dims int) error {
+	s.mu.Lock()
+	cached := s.embedding
+	s.mu.Unlock()
+	if cached == nil {
+		e, err := s.Embedding(ctx)
+		if err != nil {
+			return err
+		}
+		if !e.Exists {
+			return nil
+		}
+		s.mu.Lock()
+		s.embedding = &e
+		s.mu.Unlock()
+		cached = &e
+	}
+	if uint64(dims) != cached.Dimensions || model != "" && cached.Model != "" && model != cached.Model {
+		return &EmbeddingError{Collection: *cached, Model: model, Dimensions: dims}
+	}
+	return nil
+}
+
+// embeddingMetadata is the metadata recording the model and vector size of
+// a new collection.
+func (s *Store) embeddingMetadata(dims uint64) map[string]*qdrant.Value {
+	meta := map[string]any{embeddingDimensionsKey: int64(dims)}
+	if s.model != "" {
+		meta[embeddingModelKey] = s.model
+	}
+	return qdrant.NewValueMap(meta)
+}
@@ -0,0 +1,22 @@
+package store
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEmbeddingErrorMessage(t *testing.T) {
+	err := &EmbeddingError{Collection: Embedding{Exists: true, Model: "all-minilm", Dimensions: 384}, Model: "nomic-embed-text", Dimensions: 768}
+	msg := err.Error()
+	for _, want := range []string{"384-dimension vectors from all-minilm", "768-dimension vector from nomic-embed-text", "--model all-minilm", "migrate-embeddings"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("expected %q in %q", want, msg)
+		}
+	}
+
+	// Without a recorded model there is no model to suggest.
+	err = &EmbeddingError{Collection: Embedding{Exists: true, Dimensions: 4}, Dimensions: 3}
+	if msg := err.Error(); strings.Contains(msg, "--model") || !strings.Contains(msg, "migrate-embeddings") {
+		t.Errorf("unexpected message %q", msg)
+	}
+}
@@ -0,0 +1,136 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// Point is a memory together with its vector: what export writes and
+// import restores.
+type Point struct {
+	ID      string         `json:"id"`
+	Vector  []float32      `json:"vector"`
+	Payload map[string]any `json:"payload"`
+}
+
+// importBatchSize is how many points Import sends per upsert.
+const importBatchSize = 100
+
+// VectorSize returns the dimension of the stored vectors, or 0 if there is
+// no collection yet.
+func (s *Store) VectorSize(ctx context.Context) (uint64, error) {
+	exists, err := s.client.CollectionExists(ctx, s.collection)
+	if err != nil {
+		return 0, fmt.Errorf("check collection: %w", err)
+	}
+	if !exists {
+		return 0, nil
+	}
+	info, err := s.client.GetCollectionInfo(ctx, s.collection)
+	if err != nil {
+		return 0, fmt.Errorf("collection info: %w", err)
+	}
+	return info.GetConfig().GetParams().GetVectorsConfig().GetParams().GetSize(), nil
+}
+
+// Export calls fn with every memory matching the filter, vector included,
+// one page at a time so the collection never has to fit in memory. It stops
+// at the first error fn returns. Like All, it does NOT update last_accessed.
+func (s *Store) Export(ctx context.Context, filter Filter, fn func(Point) error) error {
+	exists, err := s.client.CollectionExists(ctx, s.collection)
+	if err != nil {
+		return fmt.Errorf("check collection: %w", err)
+	}
+	if !exists {
+		return nil
+	}
+
+	var offset *qdrant.PointId
+	limit := uint32(100)
+	for {
+		points, nextOffset, err := s.client.ScrollAndOffset(ctx, &qdrant.ScrollPoints{
+			CollectionName: s.collection,
+			Filter:         s.scoped(filter.qdrantFilter()),
+			Limit:          &limit,
+			Offset:         offset,
+			WithPayload:    qdrant.NewWithPayload(true),
+			WithVectors:    qdrant.NewWithVectors(true),
+		})
+		if err != nil {
+			return fmt.Errorf("scroll points: %w", err)
+		}
+
+		for _, point := range points {
+			err := fn(Point{
+				ID:      pointIDToString(point.Id),
+				Vector:  denseVector(point.GetVectors().GetVector()),
+				Payload: valueMapToGoMap(point.Payload),
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		if nextOffset == nil {
+			return nil
+		}
+		offset = nextOffset
+	}
+}
+
+// Import writes exported points back as they were: IDs, vectors and
+// payloads, timestamps included, are kept, and a memory with the same ID is
+// overwritten. Unlike Add it doesn't stamp last_accessed, so a restore
+// doesn't make everything look freshly recalled. A store scoped to an agent
+// claims the points for that agent. Every vector must have the same size.
+func (s *Store) Import(ctx context.Context, points []Point) error {
+	if len(points) == 0 {
+		return nil
+	}
+	size := len(points[0].Vector)
+	for _, p := range points {
+		if len(p.Vector) != size {
+			return fmt.Errorf("memory %s has %d dimensions, expected %d", p.ID, len(p.Vector), size)
+		}
+	}
+	if err := s.ensureCollection(ctx, uint64(size)); err != nil {
+		return err
+	}
+
+	wait := true
+	for start := 0; start < len(points); start += importBatchSize {
+		batch := points[start:min(start+importBatchSize, len(points))]
+		structs := make([]*qdrant.PointStruct, len(batch))
+		for i, p := range batch {
+			if s.agent != "" {
+				p.Payload[AgentField] = s.agent
+			}
+			structs[i] = &qdrant.PointStruct{
+				Id:      qdrant.NewIDUUID(p.ID),
+				Vectors: qdrant.NewVectors(p.Vector...),
+				Payload: qdrant.NewValueMap(p.Payload),
+			}
+		}
+		_, err := s.client.Upsert(ctx, &qdrant.UpsertPoints{
+			CollectionName: s.collection,
+			Wait:           &wait,
+			Points:         structs,
+		})
+		if err != nil {
+			return fmt.Errorf("upsert: %w", err)
+		}
+	}
+	s.changed(ctx)
+	return nil
+}
+
+// denseVector returns a scrolled point's vector. Older Qdrant servers send
+// it in the deprecated data field rather than as a dense vector.
+func denseVector(v *qdrant.VectorOutput) []float32 {
+	if dense := v.GetDense(); dense != nil {
+		return dense.GetData()
+	}
+	return v.GetData()
+}
@@ -0,0 +1,369 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qdrant/go-client/qdrant"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Write modes of a replicated Store, accepted by the CLI's
+// --replica-writes flag.
+const (
+	// WritesPrimary sends writes to the primary only: while it is down
+	// they fail, and the replicas never diverge from it. Right for
+	// replicas that copy the primary (a Qdrant snapshot or a standby).
+	WritesPrimary = "primary"
+	// WritesFailover sends writes where reads go, the first healthy
+	// endpoint. Right for a Qdrant cluster, whose nodes all accept writes
+	// and replicate them.
+	WritesFailover = "failover"
+)
+
+// DefaultRecheck is how long a replicated Store skips an endpoint that
+// failed before health-checking it again.
+const DefaultRecheck = 10 * time.Second
+
+// healthCheckTimeout bounds the health check of an endpoint coming back.
+const healthCheckTimeout = 2 * time.Second
+
+// Endpoint is the address of one Qdrant server.
+type Endpoint struct {
+	Host string
+	Port int
+}
+
+func (e Endpoint) String() string {
+	return net.JoinHostPort(e.Host, strconv.Itoa(e.Port))
+}
+
+// ParseEndpoints reads a comma-separated list of HOST or HOST:PORT, the
+// port defaulting to defaultPort.
+func ParseEndpoints(spec string, defaultPort int) ([]Endpoint, error) {
+	var endpoints []Endpoint
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		host, portText, err := net.SplitHostPort(item)
+		if err != nil {
+			// No port: the whole item is the host.
+			endpoints = append(endpoints, Endpoint{Host: strings.Trim(item, "[]"), Port: defaultPort})
+			continue
+		}
+		port, err := strconv.Atoi(portText)
+		if err != nil || port <= 0 || port > 65535 || host == "" {
+			return nil, fmt.Errorf("invalid qdrant endpoint %q: want HOST or HOST:PORT", item)
+		}
+		endpoints = append(endpoints, Endpoint{Host: host, Port: port})
+	}
+	return endpoints, nil
+}
+
+// EndpointStatus is how one endpoint of a replicated Store stands.
+type EndpointStatus struct {
+	Address   string `json:"address"`
+	Primary   bool   `json:"primary"`
+	Healthy   bool   `json:"healthy"`
+	Failovers int64  `json:"failovers"`            // times calls moved off it
+	DownSince string `json:"down_since,omitempty"` // RFC 3339, while unhealthy
+	LastError string `json:"last_error,omitempty"`
+}
+
+// NewReplicated creates a Store that reads from the first healthy of
+// endpoints, the primary first, and writes as writes says (WritesPrimary
+// or WritesFailover). A call that finds its endpoint unavailable moves on
+// to the next; the endpoint it left is skipped for recheck, then
+// health-checked before it serves again. All endpoints share one pool.
+func NewReplicated(endpoints []Endpoint, opts PoolOptions, writes string, recheck time.Duration) (*Store, error) {
+	if len(endpoints) == 0 {
+		return nil, errors.New("no qdrant endpoints")
+	}
+	if writes != WritesPrimary && writes != WritesFailover {
+		return nil, fmt.Errorf("unknown write mode %q (want %s or %s)", writes, WritesPrimary, WritesFailover)
+	}
+	p := newPool(opts)
+	b := &failoverBackend{writes: writes, recheck: recheck}
+	for _, e := range endpoints {
+		client, err := qdrant.NewClient(&qdrant.Config{
+			Host:        e.Host,
+			Port:        e.Port,
+			PoolSize:    p.connections,
+			GrpcOptions: []grpc.DialOption{grpc.WithChainUnaryInterceptor(p.intercept)},
+		})
+		if err != nil {
+			b.Close()
+			return nil, fmt.Errorf("connect to qdrant at %s: %w", e, err)
+		}
+		b.endpoints = append(b.endpoints, &endpoint{address: e.String(), client: client})
+	}
+	return &Store{client: b, collection: DefaultCollection, pool: p}, nil
+}
+
+// Endpoints reports the endpoints of a replicated Store, the primary
+// first, or nil for a Store with one.
+func (s *Store) Endpoints() []EndpointStatus {
+	b := unwrapFailover(s.client)
+	if b == nil {
+		return nil
+	}
+	out := make([]EndpointStatus, len(b.endpoints))
+	for i, e := range b.endpoints {
+		e.mu.Lock()
+		out[i] = EndpointStatus{
+			Address:   e.address,
+			Primary:   i == 0,
+			Healthy:   e.downSince.IsZero(),
+			Failovers: e.failovers,
+			LastError: e.lastError,
+		}
+		if !e.downSince.IsZero() {
+			out[i].DownSince = e.downSince.UTC().Format(time.RFC3339)
+		}
+		e.mu.Unlock()
+	}
+	return out
+}
+
+// CheckEndpoints health-checks every endpoint of a replicated Store now,
+// rather than when calls come to need them, and reports them as Endpoints
+// does. It returns nil for a Store with one.
+func (s *Store) CheckEndpoints(ctx context.Context) []EndpointStatus {
+	b := unwrapFailover(s.client)
+	if b == nil {
+		return nil
+	}
+	for _, e := range b.endpoints {
+		hctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+		_, err := e.client.HealthCheck(hctx)
+		cancel()
+		e.mu.Lock()
+		if err != nil {
+			if e.downSince.IsZero() {
+				e.downSince = time.Now()
+			}
+			e.checked = time.Now()
+			e.lastError = err.Error()
+		} else {
+			e.downSince = time.Time{}
+		}
+		e.mu.Unlock()
+	}
+	return s.Endpoints()
+}
+
+// unwrapFailover finds the failoverBackend under client, if there is one.
+func unwrapFailover(client Backend) *failoverBackend {
+	if t, ok := client.(*timedBackend); ok {
+		client = t.client
+	}
+	b, _ := client.(*failoverBackend)
+	return b
+}
+
+// endpoint is one Qdrant server behind a failoverBackend.
+type endpoint struct {
+	address string
+	client  Backend
+
+	mu        sync.Mutex
+	downSince time.Time // zero while healthy
+	checked   time.Time // last failure or failed health check
+	failovers int64
+	lastError string
+}
+
+// usable reports whether the endpoint may serve a call now: it is healthy,
+// or it has been skipped for recheck and passes a health check.
+func (e *endpoint) usable(ctx context.Context, recheck time.Duration) bool {
+	e.mu.Lock()
+	down, due := !e.downSince.IsZero(), time.Since(e.checked) >= recheck
+	e.mu.Unlock()
+	if !down {
+		return true
+	}
+	if !due {
+		return false
+	}
+	hctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+	_, err := e.client.HealthCheck(hctx)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err != nil {
+		e.checked = time.Now()
+		e.lastError = err.Error()
+		return false
+	}
+	e.downSince = time.Time{}
+	return true
+}
+
+// fail marks the endpoint down after err.
+func (e *endpoint) fail(err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	now := time.Now()
+	if e.downSince.IsZero() {
+		e.downSince = now
+	}
+	e.checked = now
+	e.failovers++
+	e.lastError = err.Error()
+}
+
+// unavailable reports whether err means the endpoint, rather than the
+// call, failed, so another endpoint may serve it.
+func unavailable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	st, ok := status.FromError(err)
+	return ok && st.Code() == codes.Unavailable
+}
+
+// failoverBackend is a Backend over several Qdrant servers holding the
+// same collections: calls go to the first usable one, in order.
+type failoverBackend struct {
+	endpoints []*endpoint
+	writes    string
+	recheck   time.Duration
+}
+
+var _ Backend = (*failoverBackend)(nil)
+
+// do runs fn on the first usable endpoint, moving on while endpoints turn
+// out unavailable. Writes under WritesPrimary only ever go to the primary.
+// If no endpoint is usable, each is tried anyway, since one may be back
+// before its recheck is due.
+func do[T any](ctx context.Context, b *failoverBackend, write bool, fn func(Backend) (T, error)) (T, error) {
+	candidates := b.endpoints
+	if write && b.writes == WritesPrimary {
+		candidates = candidates[:1]
+	}
+	var zero T
+	var err error
+	tried := false
+	for _, anyway := range []bool{false, true} {
+		for _, e := range candidates {
+			if !anyway && !e.usable(ctx, b.recheck) {
+				continue
+			}
+			tried = true
+			var v T
+			if v, err = fn(e.client); err == nil || !unavailable(ctx, err) {
+				return v, err
+			}
+			e.fail(err)
+		}
+		if tried {
+			break
+		}
+	}
+	if len(candidates) < len(b.endpoints) {
+		return zero, fmt.Errorf("writes go to the primary %s only: %w", candidates[0].address, err)
+	}
+	return zero, err
+}
+
+// Close closes every endpoint's connections.
+func (b *failoverBackend) Close() error {
+	var errs []error
+	for _, e := range b.endpoints {
+		errs = append(errs, e.client.Close())
+	}
+	return errors.Join(errs...)
+}
+
+func (b *failoverBackend) HealthCheck(ctx context.Context) (*qdrant.HealthCheckReply, error) {
+	return do(ctx, b, false, func(c Backend) (*qdrant.HealthCheckReply, error) { return c.HealthCheck(ctx) })
+}
+
+func (b *failoverBackend) CollectionExists(ctx context.Context, collectionName string) (bool, error) {
+	return do(ctx, b, false, func(c Backend) (bool, error) { return c.CollectionExists(ctx, collectionName) })
+}
+
+func (b *failoverBackend) ListCollections(ctx context.Context) ([]string, error) {
+	return do(ctx, b, false, func(c Backend) ([]string, error) { return c.ListCollections(ctx) })
+}
+
+func (b *failoverBackend) CreateCollection(ctx context.Context, request *qdrant.CreateCollection) error {
+	_, err := do(ctx, b, true, func(c Backend) (struct{}, error) { return struct{}{}, c.CreateCollection(ctx, request) })
+	return err
+}
+
+func (b *failoverBackend) UpdateCollection(ctx context.Context, request *qdrant.UpdateCollection) error {
+	_, err := do(ctx, b, true, func(c Backend) (struct{}, error) { return struct{}{}, c.UpdateCollection(ctx, request) })
+	return err
+}
+
+func (b *failoverBackend) DeleteCollection(ctx context.Context, collectionName string) error {
+	_, err := do(ctx, b, true, func(c Backend) (struct{}, error) { return struct{}{}, c.DeleteCollection(ctx, collectionName) })
+	return err
+}
+
+func (b *failoverBackend) GetCollectionInfo(ctx context.Context, collectionName string) (*qdrant.CollectionInfo, error) {
+	return do(ctx, b, false, func(c Backend) (*qdrant.CollectionInfo, error) { return c.GetCollectionInfo(ctx, collectionName) })
+}
+
+func (b *failoverBackend) CreateFieldIndex(ctx context.Context, request *qdrant.CreateFieldIndexCollection) (*qdrant.UpdateResult, error) {
+	return do(ctx, b, true, func(c Backend) (*qdrant.UpdateResult, error) { return c.CreateFieldIndex(ctx, request) })
+}
+
+func (b *failoverBackend) Upsert(ctx context.Context, request *qdrant.UpsertPoints) (*qdrant.UpdateResult, error) {
+	return do(ctx, b, true, func(c Backend) (*qdrant.UpdateResult, error) { return c.Upsert(ctx, request) })
+}
+
+func (b *failoverBackend) Delete(ctx context.Context, request *qdrant.DeletePoints) (*qdrant.UpdateResult, error) {
+	return do(ctx, b, true, func(c Backend) (*qdrant.UpdateResult, error) { return c.Delete(ctx, request) })
+}
+
+func (b *failoverBackend) SetPayload(ctx context.Context, request *qdrant.SetPayloadPoints) (*qdrant.UpdateResult, error) {
+	return do(ctx, b, true, func(c Backend) (*qdrant.UpdateResult, error) { return c.SetPayload(ctx, request) })
+}
+
+func (b *failoverBackend) DeletePayload(ctx context.Context, request *qdrant.DeletePayloadPoints) (*qdrant.UpdateResult, error) {
+	return do(ctx, b, true, func(c Backend) (*qdrant.UpdateResult, error) { return c.DeletePayload(ctx, request) })
+}
+
+func (b *failoverBackend) UpdateBatch(ctx context.Context, request *qdrant.UpdateBatchPoints) ([]*qdrant.UpdateResult, error) {
+	return do(ctx, b, true, func(c Backend) ([]*qdrant.UpdateResult, error) { return c.UpdateBatch(ctx, request) })
+}
+
+func (b *failoverBackend) Get(ctx context.Context, request *qdrant.GetPoints) ([]*qdrant.RetrievedPoint, error) {
+	return do(ctx, b, false, func(c Backend) ([]*qdrant.RetrievedPoint, error) { return c.Get(ctx, request) })
+}
+
+func (b *failoverBackend) Query(ctx context.Context, request *qdrant.QueryPoints) ([]*qdrant.ScoredPoint, error) {
+	return do(ctx, b, false, func(c Backend) ([]*qdrant.ScoredPoint, error) { return c.Query(ctx, request) })
+}
+
+func (b *failoverBackend) ScrollAndOffset(ctx context.Context, request *qdrant.ScrollPoints) ([]*qdrant.RetrievedPoint, *qdrant.PointId, error) {
+	type page struct {
+		points []*qdrant.RetrievedPoint
+		next   *qdrant.PointId
+	}
+	p, err := do(ctx, b, false, func(c Backend) (page, error) {
+		points, next, err := c.ScrollAndOffset(ctx, request)
+		return page{points, next}, err
+	})
+	return p.points, p.next, err
+}
+
+func (b *failoverBackend) Count(ctx context.Context, request *qdrant.CountPoints) (uint64, error) {
+	return do(ctx, b, false, func(c Backend) (uint64, error) { return c.Count(ctx, request) })
+}
+
+func (b *failoverBackend) Facet(ctx context.Context, request *qdrant.FacetCounts) ([]*qdrant.FacetHit, error) {
+	return do(ctx, b, false, func(c Backend) ([]*qdrant.FacetHit, error) { return c.Facet(ctx, request) })
+}
@@ -0,0 +1,143 @@
+package store
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/qdrant/go-client/qdrant"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeEndpoint is a Backend that answers Count with its own number and
+// Upsert with nothing, or fails both while down.
+type fakeEndpoint struct {
+	Backend
+	n       uint64
+	down    bool
+	upserts int
+}
+
+func (f *fakeEndpoint) HealthCheck(context.Context) (*qdrant.HealthCheckReply, error) {
+	if f.down {
+		return nil, status.Error(codes.Unavailable, "connection refused")
+	}
+	return &qdrant.HealthCheckReply{}, nil
+}
+
+func (f *fakeEndpoint) Count(context.Context, *qdrant.CountPoints) (uint64, error) {
+	if f.down {
+		return 0, status.Error(codes.Unavailable, "connection refused")
+	}
+	return f.n, nil
+}
+
+func (f *fakeEndpoint) Upsert(context.Context, *qdrant.UpsertPoints) (*qdrant.UpdateResult, error) {
+	if f.down {
+		return nil, status.Error(codes.Unavailable, "connection refused")
+	}
+	f.upserts++
+	return &qdrant.UpdateResult{}, nil
+}
+
+func failover(writes string, recheck time.Duration, fakes ...*fakeEndpoint) *failoverBackend {
+	b := &failoverBackend{writes: writes, recheck: recheck}
+	for i, f := range fakes {
+		b.endpoints = append(b.endpoints, &endpoint{address: string(rune('a' + i)), client: f})
+	}
+	return b
+}
+
+func TestFailoverReads(t *testing.T) {
+	ctx := context.Background()
+	primary, replica := &fakeEndpoint{n: 1}, &fakeEndpoint{n: 2}
+	b := failover(WritesPrimary, time.Hour, primary, replica)
+
+	if n, _ := b.Count(ctx, nil); n != 1 {
+		t.Errorf("expected the primary to serve reads, got %d", n)
+	}
+	primary.down = true
+	if n, err := b.Count(ctx, nil); err != nil || n != 2 {
+		t.Errorf("expected the replica to take over, got %d, %v", n, err)
+	}
+	// Back up, the primary waits out its recheck.
+	primary.down = false
+	if n, _ := b.Count(ctx, nil); n != 2 {
+		t.Errorf("expected the replica to serve until the primary is rechecked, got %d", n)
+	}
+	b.recheck = 0
+	if n, _ := b.Count(ctx, nil); n != 1 {
+		t.Errorf("expected the primary back after a health check, got %d", n)
+	}
+
+	s := &Store{client: b}
+	got := s.Endpoints()
+	if len(got) != 2 || !got[0].Primary || !got[0].Healthy || got[0].Failovers != 1 || !got[1].Healthy {
+		t.Errorf("unexpected endpoints %+v", got)
+	}
+
+	// With every endpoint down, the call fails with the last one's error.
+	primary.down, replica.down = true, true
+	if _, err := b.Count(ctx, nil); status.Code(err) != codes.Unavailable {
+		t.Errorf("expected unavailable, got %v", err)
+	}
+	if got := s.Endpoints(); got[0].Healthy || got[0].DownSince == "" || got[1].LastError == "" {
+		t.Errorf("expected both endpoints down, got %+v", got)
+	}
+	replica.down = false
+	if got := s.CheckEndpoints(ctx); got[0].Healthy || !got[1].Healthy {
+		t.Errorf("expected the check to find the replica back, got %+v", got)
+	}
+}
+
+func TestFailoverWrites(t *testing.T) {
+	ctx := context.Background()
+	primary, replica := &fakeEndpoint{down: true}, &fakeEndpoint{}
+
+	b := failover(WritesPrimary, time.Hour, primary, replica)
+	if _, err := b.Upsert(ctx, nil); err == nil || replica.upserts != 0 {
+		t.Errorf("expected writes kept off the replica, got %v and %d upserts", err, replica.upserts)
+	}
+
+	b = failover(WritesFailover, time.Hour, primary, replica)
+	if _, err := b.Upsert(ctx, nil); err != nil || replica.upserts != 1 {
+		t.Errorf("expected the write failed over, got %v and %d upserts", err, replica.upserts)
+	}
+}
+
+func TestFailoverKeepsCallErrors(t *testing.T) {
+	// An error about the call, not the endpoint, isn't retried elsewhere.
+	bad := &badRequest{}
+	replica := &fakeEndpoint{n: 2}
+	b := &failoverBackend{writes: WritesPrimary, endpoints: []*endpoint{{address: "a", client: bad}, {address: "b", client: replica}}}
+	if _, err := b.Count(context.Background(), nil); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected the bad request's error, got %v", err)
+	}
+}
+
+type badRequest struct{ Backend }
+
+func (badRequest) Count(context.Context, *qdrant.CountPoints) (uint64, error) {
+	return 0, status.Error(codes.InvalidArgument, "wrong vector size")
+}
+
+func TestParseEndpoints(t *testing.T) {
+	got, err := ParseEndpoints("qdrant-a:6334, qdrant-b ,10.0.0.3:7334,[::1]:6334", 6334)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Endpoint{{"qdrant-a", 6334}, {"qdrant-b", 6334}, {"10.0.0.3", 7334}, {"::1", 6334}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	for _, bad := range []string{"host:port", "host:0", ":6334"} {
+		if _, err := ParseEndpoints(bad, 6334); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+	if _, err := NewReplicated(want, PoolOptions{}, "sometimes", DefaultRecheck); err == nil {
+		t.Error("expected an unknown write mode refused")
+	}
+}
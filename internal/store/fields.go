@@ -0,0 +1,222 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// FieldKind is the declared type of a payload field. Declaring a field lets
+// add store its values in the shape Qdrant's index for that type expects
+// (JSON numbers arrive as floats, but an integer index only sees integers)
+// and creates the index, so range and radius filters on it stay fast.
+type FieldKind string
+
+const (
+	FieldKeyword  FieldKind = "keyword"
+	FieldInteger  FieldKind = "integer"
+	FieldFloat    FieldKind = "float"
+	FieldGeo      FieldKind = "geo"
+	FieldDatetime FieldKind = "datetime"
+)
+
+// fieldKinds maps each kind to its Qdrant index type and the payload schema
+// type Qdrant reports for an existing index.
+var fieldKinds = map[FieldKind]struct {
+	index  qdrant.FieldType
+	schema qdrant.PayloadSchemaType
+}{
+	FieldKeyword:  {qdrant.FieldType_FieldTypeKeyword, qdrant.PayloadSchemaType_Keyword},
+	FieldInteger:  {qdrant.FieldType_FieldTypeInteger, qdrant.PayloadSchemaType_Integer},
+	FieldFloat:    {qdrant.FieldType_FieldTypeFloat, qdrant.PayloadSchemaType_Float},
+	FieldGeo:      {qdrant.FieldType_FieldTypeGeo, qdrant.PayloadSchemaType_Geo},
+	FieldDatetime: {qdrant.FieldType_FieldTypeDatetime, qdrant.PayloadSchemaType_Datetime},
+}
+
+// Validate checks that k is a known field kind.
+func (k FieldKind) Validate() error {
+	if _, ok := fieldKinds[k]; !ok {
+		return fmt.Errorf("unknown field type %q (want keyword, integer, float, geo or datetime)", k)
+	}
+	return nil
+}
+
+// Coerce converts a payload value to the kind's stored form: integers as
+// int64, floats as float64, geo points as {"lat", "lon"} objects (a
+// "lat,lon" string is accepted too). Values that can't represent the kind
+// are an error rather than silently becoming unfilterable.
+func (k FieldKind) Coerce(v any) (any, error) {
+	switch k {
+	case FieldInteger:
+		f, ok := number(v)
+		if !ok || f != math.Trunc(f) {
+			return nil, fmt.Errorf("must be an integer, got %v", v)
+		}
+		return int64(f), nil
+	case FieldFloat:
+		f, ok := number(v)
+		if !ok {
+			return nil, fmt.Errorf("must be a number, got %v", v)
+		}
+		return f, nil
+	case FieldGeo:
+		lat, lon, ok := geoPoint(v)
+		if !ok {
+			return nil, fmt.Errorf(`must be a geo point {"lat": ..., "lon": ...}, got %v`, v)
+		}
+		if err := checkLatLon(lat, lon); err != nil {
+			return nil, err
+		}
+		return map[string]any{"lat": lat, "lon": lon}, nil
+	case FieldKeyword, FieldDatetime:
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("must be a string, got %v", v)
+		}
+		return s, nil
+	}
+	return nil, k.Validate()
+}
+
+// CoerceFields coerces every declared field present in payload, in place.
+func CoerceFields(fields map[string]FieldKind, payload map[string]any) error {
+	for name, kind := range fields {
+		v, ok := payload[name]
+		if !ok || v == nil {
+			continue
+		}
+		coerced, err := kind.Coerce(v)
+		if err != nil {
+			return fmt.Errorf("field %q %w", name, err)
+		}
+		payload[name] = coerced
+	}
+	return nil
+}
+
+// EnsureIndexes creates a payload index for each declared field that doesn't
+// have one yet. A field already indexed with a different type is reported
+// and left alone; Qdrant would reject a second index on it. A missing
+// collection is a no-op: it gets indexed after it is created.
+func (s *Store) EnsureIndexes(ctx context.Context, fields map[string]FieldKind) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	exists, err := s.client.CollectionExists(ctx, s.collection)
+	if err != nil {
+		return fmt.Errorf("check collection: %w", err)
+	}
+	if !exists {
+		return nil
+	}
+	info, err := s.client.GetCollectionInfo(ctx, s.collection)
+	if err != nil {
+		return fmt.Errorf("collection info: %w", err)
+	}
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	wait := true
+	for _, name := range names {
+		want := fieldKinds[fields[name]]
+		if have, ok := info.GetPayloadSchema()[name]; ok {
+			if have.GetDataType() != want.schema {
+				log.Printf("warning: field %q is already indexed as %s, not %s", name,
+					strings.ToLower(have.GetDataType().String()), fields[name])
+			}
+			continue
+		}
+		_, err := s.client.CreateFieldIndex(ctx, &qdrant.CreateFieldIndexCollection{
+			CollectionName: s.collection,
+			Wait:           &wait,
+			FieldName:      name,
+			FieldType:      want.index.Enum(),
+		})
+		if err != nil {
+			return fmt.Errorf("create %s index: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// number returns v as a float64 if it is a JSON or payload number, or a
+// string holding one.
+func number(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int64:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// geoPoint reads a {"lat", "lon"} object or a "lat,lon" string.
+func geoPoint(v any) (lat, lon float64, ok bool) {
+	switch p := v.(type) {
+	case map[string]any:
+		lat, okLat := number(p["lat"])
+		lon, okLon := number(p["lon"])
+		return lat, lon, okLat && okLon && len(p) == 2
+	case string:
+		a, b, found := strings.Cut(p, ",")
+		if !found {
+			return 0, 0, false
+		}
+		lat, okLat := number(a)
+		lon, okLon := number(b)
+		return lat, lon, okLat && okLon
+	}
+	return 0, 0, false
+}
+
+func checkLatLon(lat, lon float64) error {
+	if lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return fmt.Errorf("coordinates out of range: lat %g, lon %g", lat, lon)
+	}
+	return nil
+}
+
+// earthRadiusMeters is the mean Earth radius used for distance checks.
+const earthRadiusMeters = 6371008.8
+
+// distanceMeters returns the great-circle distance between two points.
+func distanceMeters(lat1, lon1, lat2, lon2 float64) float64 {
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusMeters * math.Asin(math.Sqrt(a))
+}
+
+// parseRadius parses a distance like "500m", "2.5km" or "800" (meters).
+func parseRadius(v string) (float64, error) {
+	v = strings.TrimSpace(v)
+	scale := 1.0
+	if n, ok := strings.CutSuffix(v, "km"); ok {
+		v, scale = n, 1000
+	} else if n, ok := strings.CutSuffix(v, "m"); ok {
+		v = n
+	}
+	r, err := strconv.ParseFloat(v, 64)
+	if err != nil || r <= 0 {
+		return 0, fmt.Errorf("invalid radius %q", v)
+	}
+	return r * scale, nil
+}
@@ -0,0 +1,231 @@
+package store
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestParseConditionOperators(t *testing.T) {
+	tests := []struct {
+		expr string
+		key  string
+		op   string
+	}{
+		{"priority>=3", "priority", ">="},
+		{"priority > 3", "priority", ">"},
+		{"priority<=1.5", "priority", "<="},
+		{"priority<0", "priority", "<"},
+		{"location~52.52,13.405,5km", "location", "~"},
+		{"type=todo", "type", "="},
+	}
+	for _, tt := range tests {
+		c, err := ParseCondition(tt.expr)
+		if err != nil {
+			t.Errorf("ParseCondition(%q): %v", tt.expr, err)
+			continue
+		}
+		if c.Key != tt.key || c.Op != tt.op {
+			t.Errorf("ParseCondition(%q) = %+v, want key %q op %q", tt.expr, c, tt.key, tt.op)
+		}
+	}
+
+	for _, bad := range []string{
+		"priority>=high",
+		"priority<",
+		">=3",
+		"location~52.52,13.405",
+		"location~north,13.405,5km",
+		"location~95,13.405,5km",
+		"location~52.52,13.405,far",
+		"location~52.52,13.405,-5km",
+	} {
+		if _, err := ParseCondition(bad); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestConditionRangeAndGeoMatches(t *testing.T) {
+	payload := map[string]any{
+		"priority": int64(3),
+		"score":    0.75,
+		"label":    "5",
+		"location": map[string]any{"lat": 52.5200, "lon": 13.4050}, // Berlin
+	}
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"priority>=3", true},
+		{"priority>3", false},
+		{"priority<4", true},
+		{"priority<=2", false},
+		{"score>0.5", true},
+		{"score<0.5", false},
+		{"label>1", false}, // strings never satisfy a range
+		{"missing>0", false},
+		{"location~52.5163,13.3777,5km", true}, // Brandenburg Gate, ~2km away
+		{"location~52.5163,13.3777,1km", false},
+		{"location~48.8566,2.3522,500km", false}, // Paris
+		{"priority~52.52,13.405,5km", false},
+		{"priority=3", true},
+	}
+	for _, tt := range tests {
+		c, err := ParseCondition(tt.expr)
+		if err != nil {
+			t.Fatalf("ParseCondition(%q): %v", tt.expr, err)
+		}
+		if got := c.Matches(payload); got != tt.want {
+			t.Errorf("%s: Matches = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestCheckSearchable(t *testing.T) {
+	for expr, ok := range map[string]bool{
+		"source=/old/notes/": false,
+		"source=/old/a.md":   true,
+		"priority>=3":        true,
+		"location~1,2,3km":   true,
+	} {
+		c, err := ParseCondition(expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := c.CheckSearchable() == nil; got != ok {
+			t.Errorf("%s: searchable = %v, want %v", expr, got, ok)
+		}
+	}
+}
+
+func TestFieldKindCoerce(t *testing.T) {
+	tests := []struct {
+		kind FieldKind
+		in   any
+		want any
+	}{
+		{FieldInteger, 3.0, int64(3)},
+		{FieldInteger, "4", int64(4)},
+		{FieldFloat, 2.5, 2.5},
+		{FieldFloat, "0.25", 0.25},
+		{FieldKeyword, "a", "a"},
+		{FieldDatetime, "2026-01-01T00:00:00Z", "2026-01-01T00:00:00Z"},
+	}
+	for _, tt := range tests {
+		got, err := tt.kind.Coerce(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("%s.Coerce(%v) = %v (%T), %v; want %v (%T)", tt.kind, tt.in, got, got, err, tt.want, tt.want)
+		}
+	}
+
+	for _, in := range []any{map[string]any{"lat": 1.5, "lon": 2.0}, "1.5, 2"} {
+		got, err := FieldGeo.Coerce(in)
+		point, _ := got.(map[string]any)
+		if err != nil || point["lat"] != 1.5 || point["lon"] != 2.0 {
+			t.Errorf("geo Coerce(%v) = %v, %v", in, got, err)
+		}
+	}
+
+	bad := []struct {
+		kind FieldKind
+		in   any
+	}{
+		{FieldInteger, 2.5},
+		{FieldInteger, "high"},
+		{FieldFloat, true},
+		{FieldKeyword, 3.0},
+		{FieldGeo, map[string]any{"lat": 1.0}},
+		{FieldGeo, map[string]any{"lat": 100.0, "lon": 0.0}},
+		{FieldGeo, "somewhere"},
+		{FieldKind("vector"), 1.0},
+	}
+	for _, tt := range bad {
+		if _, err := tt.kind.Coerce(tt.in); err == nil {
+			t.Errorf("%s.Coerce(%v): expected error", tt.kind, tt.in)
+		}
+	}
+}
+
+func TestCoerceFields(t *testing.T) {
+	fields := map[string]FieldKind{"priority": FieldInteger, "location": FieldGeo}
+	payload := map[string]any{"text": "x", "priority": 2.0}
+	if err := CoerceFields(fields, payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload["priority"] != int64(2) {
+		t.Errorf("expected priority coerced to int64, got %T", payload["priority"])
+	}
+	if _, ok := payload["location"]; ok {
+		t.Error("expected absent fields to stay absent")
+	}
+
+	err := CoerceFields(fields, map[string]any{"priority": "urgent"})
+	if err == nil || err.Error() != `field "priority" must be an integer, got urgent` {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestDistanceMeters(t *testing.T) {
+	// Berlin to Paris is about 878 km.
+	d := distanceMeters(52.5200, 13.4050, 48.8566, 2.3522)
+	if math.Abs(d-878e3) > 5e3 {
+		t.Errorf("distance = %.0f m, want ~878 km", d)
+	}
+	if d := distanceMeters(1, 2, 1, 2); d != 0 {
+		t.Errorf("distance to self = %v", d)
+	}
+}
+
+func TestEnsureIndexesAndFilterConditions(t *testing.T) {
+	s := testStore(t)
+	defer s.Close()
+	cleanupMemories(t, s)
+	defer cleanupMemories(t, s)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	fields := map[string]FieldKind{"priority": FieldInteger, "location": FieldGeo}
+	if err := s.EnsureIndexes(ctx, fields); err != nil {
+		t.Fatalf("EnsureIndexes on missing collection: %v", err)
+	}
+
+	add := func(payload map[string]any) string {
+		t.Helper()
+		if err := CoerceFields(fields, payload); err != nil {
+			t.Fatal(err)
+		}
+		id, err := s.Add(ctx, "", []float32{0.1, 0.2, 0.3, 0.4}, payload)
+		if err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+		return id
+	}
+	urgent := add(map[string]any{"text": "urgent", "priority": 5.0, "location": "52.52,13.405"})
+	add(map[string]any{"text": "later", "priority": 1.0, "location": map[string]any{"lat": 48.8566, "lon": 2.3522}})
+	add(map[string]any{"text": "untracked"})
+
+	if err := s.EnsureIndexes(ctx, fields); err != nil {
+		t.Fatalf("EnsureIndexes failed: %v", err)
+	}
+	// Idempotent once the indexes exist.
+	if err := s.EnsureIndexes(ctx, fields); err != nil {
+		t.Fatalf("second EnsureIndexes failed: %v", err)
+	}
+
+	for _, expr := range []string{"priority>=3", "location~52.5163,13.3777,5km", "priority=5"} {
+		c, err := ParseCondition(expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		results, err := s.FindSimilarFiltered(ctx, []float32{0.1, 0.2, 0.3, 0.4}, 0, 10, Filter{Conditions: []Condition{c}})
+		if err != nil {
+			t.Fatalf("%s: FindSimilarFiltered failed: %v", expr, err)
+		}
+		if len(results) != 1 || results[0].ID != urgent {
+			t.Errorf("%s: expected only the urgent memory, got %v", expr, results)
+		}
+	}
+}
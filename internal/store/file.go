@@ -0,0 +1,789 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qdrant/go-client/qdrant"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// FileBackend is a Backend that keeps each collection as a JSON file in a
+// directory and searches it by brute force: every query scores every
+// memory. That is fast enough for the few thousand memories of a laptop
+// agent, with nothing to run besides clawbrain itself.
+//
+// Writes take a lock file next to the collection and replace the file
+// atomically, so several clawbrain processes can share a directory. Reads
+// don't lock; a collection is parsed again only when its file changed.
+type FileBackend struct {
+	dir   string
+	mu    sync.Mutex // guards cache
+	cache map[string]*fileCollection
+}
+
+// fileCollection is a collection as held in memory.
+type fileCollection struct {
+	vectorSize uint64
+	metadata   map[string]*qdrant.Value
+	indexes    map[string]qdrant.PayloadSchemaType
+	points     map[string]*filePoint
+
+	modTime time.Time // of the file it was read from, to tell when to re-read
+	size    int64
+}
+
+// filePoint is one stored memory.
+type filePoint struct {
+	vector  []float32
+	payload map[string]*qdrant.Value
+}
+
+// fileCollectionJSON is a collection's file. Payloads and metadata are
+// protobuf JSON, which keeps integers apart from floats.
+type fileCollectionJSON struct {
+	VectorSize uint64            `json:"vector_size"`
+	Metadata   json.RawMessage   `json:"metadata,omitempty"`
+	Indexes    map[string]string `json:"indexes,omitempty"`
+	Points     []filePointJSON   `json:"points"`
+}
+
+type filePointJSON struct {
+	ID      string          `json:"id"`
+	Vector  []float32       `json:"vector"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// staleLockAge is how old a lock file must be before it is taken to be left
+// over from a crashed process and removed.
+const staleLockAge = 30 * time.Second
+
+// defaultFileLimit is the page size when a request gives no limit, as in
+// Qdrant.
+const defaultFileLimit = 10
+
+// NewFileBackend returns a FileBackend keeping its collections in dir,
+// which is created if needed. Only the owner can read it: memories can be
+// personal.
+func NewFileBackend(dir string) (*FileBackend, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create %s: %w", dir, err)
+	}
+	return &FileBackend{dir: dir, cache: make(map[string]*fileCollection)}, nil
+}
+
+// NewFile creates a Store backed by a FileBackend in dir instead of Qdrant.
+func NewFile(dir string) (*Store, error) {
+	b, err := NewFileBackend(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{client: b, collection: DefaultCollection, pool: newPool(PoolOptions{})}, nil
+}
+
+func (b *FileBackend) path(collection string) string {
+	return filepath.Join(b.dir, collection+".json")
+}
+
+// Close releases nothing; it is there to satisfy Backend.
+func (b *FileBackend) Close() error {
+	return nil
+}
+
+// HealthCheck reports whether the directory is still there.
+func (b *FileBackend) HealthCheck(ctx context.Context) (*qdrant.HealthCheckReply, error) {
+	if _, err := os.Stat(b.dir); err != nil {
+		return nil, err
+	}
+	return &qdrant.HealthCheckReply{Title: "clawbrain file backend"}, nil
+}
+
+// load returns the collection, or nil if it doesn't exist. The result is
+// shared with other callers: don't modify it outside update.
+func (b *FileBackend) load(collection string) (*fileCollection, error) {
+	info, err := os.Stat(b.path(collection))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if c := b.cache[collection]; c != nil && c.modTime.Equal(info.ModTime()) && c.size == info.Size() {
+		return c, nil
+	}
+
+	data, err := os.ReadFile(b.path(collection))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	c, err := decodeCollection(data)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", b.path(collection), err)
+	}
+	c.modTime, c.size = info.ModTime(), info.Size()
+	b.cache[collection] = c
+	return c, nil
+}
+
+// mustLoad is load, failing for a collection that doesn't exist.
+func (b *FileBackend) mustLoad(collection string) (*fileCollection, error) {
+	c, err := b.load(collection)
+	if err == nil && c == nil {
+		err = fmt.Errorf("collection %s doesn't exist", collection)
+	}
+	return c, err
+}
+
+// update runs fn on a private copy of the collection under the lock and
+// writes the result back. fn gets nil for a collection that doesn't exist,
+// and may return nil to leave the file alone.
+func (b *FileBackend) update(ctx context.Context, collection string, fn func(c *fileCollection) (*fileCollection, error)) error {
+	unlock, err := b.lock(ctx, collection)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	c, err := b.load(collection)
+	if err != nil {
+		return err
+	}
+	if c != nil {
+		c = c.clone()
+	}
+	c, err = fn(c)
+	if err != nil || c == nil {
+		return err
+	}
+	return b.save(collection, c)
+}
+
+// lock takes the collection's lock file, waiting while another process
+// holds it. The returned func releases it.
+func (b *FileBackend) lock(ctx context.Context, collection string) (func(), error) {
+	path := filepath.Join(b.dir, collection+".lock")
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, fmt.Errorf("lock %s: %w", collection, err)
+		}
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > staleLockAge {
+			os.Remove(path)
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("wait for lock on %s: %w", collection, ctx.Err())
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+// save writes the collection to a temporary file and renames it into
+// place, so readers never see half a file.
+func (b *FileBackend) save(collection string, c *fileCollection) error {
+	data, err := encodeCollection(c)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(b.dir, collection+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), b.path(collection)); err != nil {
+		return err
+	}
+
+	info, err := os.Stat(b.path(collection))
+	if err != nil {
+		return err
+	}
+	c.modTime, c.size = info.ModTime(), info.Size()
+	b.mu.Lock()
+	b.cache[collection] = c
+	b.mu.Unlock()
+	return nil
+}
+
+// clone copies the collection deeply enough for update to change it: the
+// point map and payload maps are new, vectors are shared (never modified in
+// place).
+func (c *fileCollection) clone() *fileCollection {
+	out := &fileCollection{
+		vectorSize: c.vectorSize,
+		metadata:   maps.Clone(c.metadata),
+		indexes:    maps.Clone(c.indexes),
+		points:     make(map[string]*filePoint, len(c.points)),
+	}
+	for id, p := range c.points {
+		out.points[id] = &filePoint{vector: p.vector, payload: maps.Clone(p.payload)}
+	}
+	return out
+}
+
+func encodeCollection(c *fileCollection) ([]byte, error) {
+	out := fileCollectionJSON{VectorSize: c.vectorSize, Points: make([]filePointJSON, 0, len(c.points))}
+	if len(c.metadata) > 0 {
+		meta, err := protojson.Marshal(&qdrant.Struct{Fields: c.metadata})
+		if err != nil {
+			return nil, err
+		}
+		out.Metadata = meta
+	}
+	if len(c.indexes) > 0 {
+		out.Indexes = make(map[string]string, len(c.indexes))
+		for field, kind := range c.indexes {
+			out.Indexes[field] = kind.String()
+		}
+	}
+	for _, id := range slices.Sorted(maps.Keys(c.points)) {
+		p := c.points[id]
+		payload, err := protojson.Marshal(&qdrant.Struct{Fields: p.payload})
+		if err != nil {
+			return nil, err
+		}
+		out.Points = append(out.Points, filePointJSON{ID: id, Vector: p.vector, Payload: payload})
+	}
+	return json.Marshal(out)
+}
+
+func decodeCollection(data []byte) (*fileCollection, error) {
+	var in fileCollectionJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return nil, err
+	}
+	c := &fileCollection{
+		vectorSize: in.VectorSize,
+		metadata:   map[string]*qdrant.Value{},
+		indexes:    map[string]qdrant.PayloadSchemaType{},
+		points:     make(map[string]*filePoint, len(in.Points)),
+	}
+	if len(in.Metadata) > 0 {
+		var meta qdrant.Struct
+		if err := protojson.Unmarshal(in.Metadata, &meta); err != nil {
+			return nil, err
+		}
+		c.metadata = meta.GetFields()
+	}
+	for field, kind := range in.Indexes {
+		c.indexes[field] = qdrant.PayloadSchemaType(qdrant.PayloadSchemaType_value[kind])
+	}
+	for _, p := range in.Points {
+		var payload qdrant.Struct
+		if err := protojson.Unmarshal(p.Payload, &payload); err != nil {
+			return nil, fmt.Errorf("point %s: %w", p.ID, err)
+		}
+		fields := payload.GetFields()
+		if fields == nil {
+			fields = map[string]*qdrant.Value{}
+		}
+		c.points[p.ID] = &filePoint{vector: p.Vector, payload: fields}
+	}
+	return c, nil
+}
+
+// CollectionExists reports whether the collection's file exists.
+func (b *FileBackend) CollectionExists(ctx context.Context, collectionName string) (bool, error) {
+	c, err := b.load(collectionName)
+	return c != nil, err
+}
+
+// ListCollections lists the collections in the directory.
+func (b *FileBackend) ListCollections(ctx context.Context) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(b.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(matches))
+	for i, m := range matches {
+		names[i] = strings.TrimSuffix(filepath.Base(m), ".json")
+	}
+	return names, nil
+}
+
+// CreateCollection creates an empty collection. Only the vector size and
+// metadata of the request matter; there is no index to configure.
+func (b *FileBackend) CreateCollection(ctx context.Context, request *qdrant.CreateCollection) error {
+	return b.update(ctx, request.GetCollectionName(), func(c *fileCollection) (*fileCollection, error) {
+		if c != nil {
+			return nil, fmt.Errorf("collection %s already exists", request.GetCollectionName())
+		}
+		return &fileCollection{
+			vectorSize: request.GetVectorsConfig().GetParams().GetSize(),
+			metadata:   maps.Clone(request.GetMetadata()),
+			indexes:    map[string]qdrant.PayloadSchemaType{},
+			points:     map[string]*filePoint{},
+		}, nil
+	})
+}
+
+// UpdateCollection merges the request's metadata into the collection's.
+// Other settings have no meaning here and are ignored.
+func (b *FileBackend) UpdateCollection(ctx context.Context, request *qdrant.UpdateCollection) error {
+	return b.update(ctx, request.GetCollectionName(), func(c *fileCollection) (*fileCollection, error) {
+		if c == nil {
+			return nil, fmt.Errorf("collection %s doesn't exist", request.GetCollectionName())
+		}
+		if c.metadata == nil {
+			c.metadata = map[string]*qdrant.Value{}
+		}
+		maps.Copy(c.metadata, request.GetMetadata())
+		return c, nil
+	})
+}
+
+// DeleteCollection removes the collection's file.
+func (b *FileBackend) DeleteCollection(ctx context.Context, collectionName string) error {
+	unlock, err := b.lock(ctx, collectionName)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	b.mu.Lock()
+	delete(b.cache, collectionName)
+	b.mu.Unlock()
+	if err := os.Remove(b.path(collectionName)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// GetCollectionInfo describes the collection: vector size, metadata, the
+// fields indexed with CreateFieldIndex and the number of points. Searches
+// scan every point, so all of them count as indexed.
+func (b *FileBackend) GetCollectionInfo(ctx context.Context, collectionName string) (*qdrant.CollectionInfo, error) {
+	c, err := b.mustLoad(collectionName)
+	if err != nil {
+		return nil, err
+	}
+	schema := make(map[string]*qdrant.PayloadSchemaInfo, len(c.indexes))
+	for field, kind := range c.indexes {
+		schema[field] = &qdrant.PayloadSchemaInfo{DataType: kind}
+	}
+	count := uint64(len(c.points))
+	return &qdrant.CollectionInfo{
+		Status: qdrant.CollectionStatus_Green,
+		Config: &qdrant.CollectionConfig{
+			Params: &qdrant.CollectionParams{
+				VectorsConfig: qdrant.NewVectorsConfig(&qdrant.VectorParams{Size: c.vectorSize, Distance: qdrant.Distance_Cosine}),
+			},
+			Metadata: c.metadata,
+		},
+		PayloadSchema:       schema,
+		PointsCount:         &count,
+		IndexedVectorsCount: &count,
+		OptimizerStatus:     &qdrant.OptimizerStatus{Ok: true},
+	}, nil
+}
+
+// CreateFieldIndex records the index in the collection's payload schema.
+// Every search scans all points anyway, so nothing is built.
+func (b *FileBackend) CreateFieldIndex(ctx context.Context, request *qdrant.CreateFieldIndexCollection) (*qdrant.UpdateResult, error) {
+	err := b.update(ctx, request.GetCollectionName(), func(c *fileCollection) (*fileCollection, error) {
+		if c == nil {
+			return nil, fmt.Errorf("collection %s doesn't exist", request.GetCollectionName())
+		}
+		kind := strings.TrimPrefix(request.GetFieldType().String(), "FieldType")
+		c.indexes[request.GetFieldName()] = qdrant.PayloadSchemaType(qdrant.PayloadSchemaType_value[kind])
+		return c, nil
+	})
+	return completed(err)
+}
+
+// completed is the UpdateResult of a write that finished.
+func completed(err error) (*qdrant.UpdateResult, error) {
+	if err != nil {
+		return nil, err
+	}
+	return &qdrant.UpdateResult{Status: qdrant.UpdateStatus_Completed}, nil
+}
+
+// Upsert writes points, honoring the request's update filter and mode.
+func (b *FileBackend) Upsert(ctx context.Context, request *qdrant.UpsertPoints) (*qdrant.UpdateResult, error) {
+	return completed(b.update(ctx, request.GetCollectionName(), func(c *fileCollection) (*fileCollection, error) {
+		if c == nil {
+			return nil, fmt.Errorf("collection %s doesn't exist", request.GetCollectionName())
+		}
+		return c, c.upsert(request.GetPoints(), request.GetUpdateFilter(), request.GetUpdateMode())
+	}))
+}
+
+func (c *fileCollection) upsert(points []*qdrant.PointStruct, filter *qdrant.Filter, mode qdrant.UpdateMode) error {
+	for _, p := range points {
+		id := pointIDToString(p.GetId())
+		vector := denseInput(p.GetVectors().GetVector())
+		if uint64(len(vector)) != c.vectorSize {
+			return fmt.Errorf("wrong vector dimension: expected %d, got %d", c.vectorSize, len(vector))
+		}
+		existing, exists := c.points[id]
+		switch {
+		case mode == qdrant.UpdateMode_InsertOnly && exists:
+			continue
+		case mode == qdrant.UpdateMode_UpdateOnly && !exists:
+			continue
+		case exists && filter != nil && !matchFilter(filter, id, valueMapToGoMap(existing.payload)):
+			continue
+		}
+		c.points[id] = &filePoint{vector: vector, payload: maps.Clone(p.GetPayload())}
+	}
+	return nil
+}
+
+// denseInput returns the values of a dense vector, in either of the forms
+// the client builds.
+func denseInput(v *qdrant.Vector) []float32 {
+	if dense := v.GetDense(); dense != nil {
+		return dense.GetData()
+	}
+	return v.GetData()
+}
+
+// selected returns the IDs of the points a selector picks.
+func (c *fileCollection) selected(sel *qdrant.PointsSelector) []string {
+	var ids []string
+	switch s := sel.GetPointsSelectorOneOf().(type) {
+	case *qdrant.PointsSelector_Points:
+		for _, pid := range s.Points.GetIds() {
+			if id := pointIDToString(pid); c.points[id] != nil {
+				ids = append(ids, id)
+			}
+		}
+	case *qdrant.PointsSelector_Filter:
+		for id, p := range c.points {
+			if matchFilter(s.Filter, id, valueMapToGoMap(p.payload)) {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
+
+// Delete removes the selected points.
+func (b *FileBackend) Delete(ctx context.Context, request *qdrant.DeletePoints) (*qdrant.UpdateResult, error) {
+	return completed(b.update(ctx, request.GetCollectionName(), func(c *fileCollection) (*fileCollection, error) {
+		if c == nil {
+			return nil, fmt.Errorf("collection %s doesn't exist", request.GetCollectionName())
+		}
+		c.delete(request.GetPoints())
+		return c, nil
+	}))
+}
+
+func (c *fileCollection) delete(sel *qdrant.PointsSelector) {
+	for _, id := range c.selected(sel) {
+		delete(c.points, id)
+	}
+}
+
+// SetPayload merges payload into the selected points' payloads.
+func (b *FileBackend) SetPayload(ctx context.Context, request *qdrant.SetPayloadPoints) (*qdrant.UpdateResult, error) {
+	return completed(b.update(ctx, request.GetCollectionName(), func(c *fileCollection) (*fileCollection, error) {
+		if c == nil {
+			return nil, fmt.Errorf("collection %s doesn't exist", request.GetCollectionName())
+		}
+		c.setPayload(request.GetPointsSelector(), request.GetPayload())
+		return c, nil
+	}))
+}
+
+func (c *fileCollection) setPayload(sel *qdrant.PointsSelector, payload map[string]*qdrant.Value) {
+	for _, id := range c.selected(sel) {
+		maps.Copy(c.points[id].payload, payload)
+	}
+}
+
+// DeletePayload removes keys from the selected points' payloads.
+func (b *FileBackend) DeletePayload(ctx context.Context, request *qdrant.DeletePayloadPoints) (*qdrant.UpdateResult, error) {
+	return completed(b.update(ctx, request.GetCollectionName(), func(c *fileCollection) (*fileCollection, error) {
+		if c == nil {
+			return nil, fmt.Errorf("collection %s doesn't exist", request.GetCollectionName())
+		}
+		c.deletePayload(request.GetPointsSelector(), request.GetKeys())
+		return c, nil
+	}))
+}
+
+func (c *fileCollection) deletePayload(sel *qdrant.PointsSelector, keys []string) {
+	for _, id := range c.selected(sel) {
+		for _, key := range keys {
+			delete(c.points[id].payload, key)
+		}
+	}
+}
+
+// UpdateBatch applies the operations in order, all in one write: either
+// every one lands or none does.
+func (b *FileBackend) UpdateBatch(ctx context.Context, request *qdrant.UpdateBatchPoints) ([]*qdrant.UpdateResult, error) {
+	ops := request.GetOperations()
+	err := b.update(ctx, request.GetCollectionName(), func(c *fileCollection) (*fileCollection, error) {
+		if c == nil {
+			return nil, fmt.Errorf("collection %s doesn't exist", request.GetCollectionName())
+		}
+		for _, op := range ops {
+			switch o := op.GetOperation().(type) {
+			case *qdrant.PointsUpdateOperation_Upsert:
+				if err := c.upsert(o.Upsert.GetPoints(), o.Upsert.GetUpdateFilter(), o.Upsert.GetUpdateMode()); err != nil {
+					return nil, err
+				}
+			case *qdrant.PointsUpdateOperation_SetPayload_:
+				c.setPayload(o.SetPayload.GetPointsSelector(), o.SetPayload.GetPayload())
+			case *qdrant.PointsUpdateOperation_DeletePayload_:
+				c.deletePayload(o.DeletePayload.GetPointsSelector(), o.DeletePayload.GetKeys())
+			case *qdrant.PointsUpdateOperation_DeletePoints_:
+				c.delete(o.DeletePoints.GetPoints())
+			default:
+				return nil, fmt.Errorf("file backend: unsupported batch operation %T", o)
+			}
+		}
+		return c, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*qdrant.UpdateResult, len(ops))
+	for i := range results {
+		results[i] = &qdrant.UpdateResult{Status: qdrant.UpdateStatus_Completed}
+	}
+	return results, nil
+}
+
+// retrieved renders a point as Qdrant returns it.
+func (p *filePoint) retrieved(id string, withPayload *qdrant.WithPayloadSelector, withVectors *qdrant.WithVectorsSelector) *qdrant.RetrievedPoint {
+	out := &qdrant.RetrievedPoint{Id: pointID(id)}
+	if withPayload.GetEnable() {
+		out.Payload = p.payload
+	} else if include := withPayload.GetInclude().GetFields(); len(include) > 0 {
+		out.Payload = make(map[string]*qdrant.Value, len(include))
+		for _, key := range include {
+			if v, ok := p.payload[key]; ok {
+				out.Payload[key] = v
+			}
+		}
+	}
+	if withVectors.GetEnable() {
+		out.Vectors = &qdrant.VectorsOutput{VectorsOptions: &qdrant.VectorsOutput_Vector{
+			Vector: &qdrant.VectorOutput{Vector: &qdrant.VectorOutput_Dense{Dense: &qdrant.DenseVector{Data: p.vector}}},
+		}}
+	}
+	return out
+}
+
+// pointID parses a stored ID back into a PointId: numeric IDs stay
+// numeric.
+func pointID(id string) *qdrant.PointId {
+	if n, err := strconv.ParseUint(id, 10, 64); err == nil {
+		return qdrant.NewIDNum(n)
+	}
+	return qdrant.NewIDUUID(id)
+}
+
+// Get returns the points with the given IDs that exist.
+func (b *FileBackend) Get(ctx context.Context, request *qdrant.GetPoints) ([]*qdrant.RetrievedPoint, error) {
+	c, err := b.mustLoad(request.GetCollectionName())
+	if err != nil {
+		return nil, err
+	}
+	var out []*qdrant.RetrievedPoint
+	for _, pid := range request.GetIds() {
+		id := pointIDToString(pid)
+		if p := c.points[id]; p != nil {
+			out = append(out, p.retrieved(id, request.GetWithPayload(), request.GetWithVectors()))
+		}
+	}
+	return out, nil
+}
+
+// Query scores every point matching the filter against the query vector by
+// cosine similarity and returns the best, highest first.
+func (b *FileBackend) Query(ctx context.Context, request *qdrant.QueryPoints) ([]*qdrant.ScoredPoint, error) {
+	c, err := b.mustLoad(request.GetCollectionName())
+	if err != nil {
+		return nil, err
+	}
+	nearest := request.GetQuery().GetNearest()
+	if nearest == nil {
+		return nil, fmt.Errorf("file backend: only nearest-vector queries are supported")
+	}
+	vector := nearest.GetDense().GetData()
+
+	var scored []*qdrant.ScoredPoint
+	for id, p := range c.points {
+		if !matchFilter(request.GetFilter(), id, valueMapToGoMap(p.payload)) {
+			continue
+		}
+		score, err := Cosine(vector, p.vector)
+		if err != nil {
+			return nil, err
+		}
+		if request.ScoreThreshold != nil && score < request.GetScoreThreshold() {
+			continue
+		}
+		r := p.retrieved(id, request.GetWithPayload(), request.GetWithVectors())
+		scored = append(scored, &qdrant.ScoredPoint{Id: r.Id, Payload: r.Payload, Vectors: r.Vectors, Score: score})
+	}
+	slices.SortFunc(scored, func(a, b *qdrant.ScoredPoint) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		return cmp.Compare(pointIDToString(a.Id), pointIDToString(b.Id))
+	})
+
+	limit := uint64(defaultFileLimit)
+	if request.Limit != nil {
+		limit = request.GetLimit()
+	}
+	return page(scored, request.GetOffset(), limit), nil
+}
+
+// page returns items[offset:offset+limit], clamped to the slice.
+func page[T any](items []T, offset, limit uint64) []T {
+	n := uint64(len(items))
+	start := min(offset, n)
+	return items[start:min(start+limit, n)]
+}
+
+// ScrollAndOffset lists points matching the filter in ID order, a page at
+// a time, returning the ID the next page starts at.
+func (b *FileBackend) ScrollAndOffset(ctx context.Context, request *qdrant.ScrollPoints) ([]*qdrant.RetrievedPoint, *qdrant.PointId, error) {
+	c, err := b.mustLoad(request.GetCollectionName())
+	if err != nil {
+		return nil, nil, err
+	}
+	ids := c.matching(request.GetFilter())
+	if o := request.GetOrderBy(); o != nil {
+		var points []orderedPoint
+		for _, id := range ids {
+			if key, ok := orderKey(valueMapToGoMap(c.points[id].payload), o.GetKey()); ok {
+				points = append(points, orderedPoint{id, c.points[id], key})
+			}
+		}
+		return scrollOrdered(points, request), nil, nil
+	}
+	if request.Offset != nil {
+		start, _ := slices.BinarySearch(ids, pointIDToString(request.GetOffset()))
+		ids = ids[start:]
+	}
+	limit := defaultFileLimit
+	if request.Limit != nil {
+		limit = int(request.GetLimit())
+	}
+
+	var next *qdrant.PointId
+	if len(ids) > limit {
+		next = pointID(ids[limit])
+		ids = ids[:limit]
+	}
+	out := make([]*qdrant.RetrievedPoint, len(ids))
+	for i, id := range ids {
+		out[i] = c.points[id].retrieved(id, request.GetWithPayload(), request.GetWithVectors())
+	}
+	return out, next, nil
+}
+
+// matching returns the sorted IDs of the points matching filter.
+func (c *fileCollection) matching(filter *qdrant.Filter) []string {
+	var ids []string
+	for id, p := range c.points {
+		if matchFilter(filter, id, valueMapToGoMap(p.payload)) {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// Count counts the points matching the filter.
+func (b *FileBackend) Count(ctx context.Context, request *qdrant.CountPoints) (uint64, error) {
+	c, err := b.mustLoad(request.GetCollectionName())
+	if err != nil {
+		return 0, err
+	}
+	return uint64(len(c.matching(request.GetFilter()))), nil
+}
+
+// Facet counts the points matching the filter per value of a field, most
+// common first. A point counts once per distinct value of an array field.
+func (b *FileBackend) Facet(ctx context.Context, request *qdrant.FacetCounts) ([]*qdrant.FacetHit, error) {
+	c, err := b.mustLoad(request.GetCollectionName())
+	if err != nil {
+		return nil, err
+	}
+	ids := c.matching(request.GetFilter())
+	payloads := make([]map[string]any, len(ids))
+	for i, id := range ids {
+		payloads[i] = valueMapToGoMap(c.points[id].payload)
+	}
+	return facetHits(payloads, request.GetKey(), request.Limit), nil
+}
+
+// facetHits counts payloads per value of key, most common first, and
+// returns the first limit (defaultFileLimit if nil). A payload counts once
+// per distinct value of an array field.
+func facetHits(payloads []map[string]any, key string, limit *uint64) []*qdrant.FacetHit {
+	hits := map[string]*qdrant.FacetHit{}
+	for _, payload := range payloads {
+		v, _ := payloadField(payload, key)
+		values := []any{v}
+		if list, isList := v.([]any); isList {
+			values = list
+		}
+		seen := map[string]bool{}
+		for _, v := range values {
+			value, key, ok := facetKey(v)
+			if !ok || seen[key] {
+				continue
+			}
+			seen[key] = true
+			if hits[key] == nil {
+				hits[key] = &qdrant.FacetHit{Value: value}
+			}
+			hits[key].Count++
+		}
+	}
+	keys := slices.SortedFunc(maps.Keys(hits), func(a, b string) int {
+		if c := cmp.Compare(hits[b].Count, hits[a].Count); c != 0 {
+			return c
+		}
+		return cmp.Compare(a, b)
+	})
+	n := uint64(defaultFileLimit)
+	if limit != nil {
+		n = *limit
+	}
+	out := make([]*qdrant.FacetHit, 0, len(keys))
+	for _, key := range page(keys, 0, n) {
+		out = append(out, hits[key])
+	}
+	return out
+}
+
+var _ Backend = (*FileBackend)(nil)
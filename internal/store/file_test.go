@@ -0,0 +1,302 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fileStore creates a Store on a FileBackend in a fresh directory.
+func fileStore(t *testing.T) (*Store, string) {
+	t.Helper()
+	dir := t.TempDir()
+	s, err := NewFile(dir)
+	if err != nil {
+		t.Fatalf("NewFile failed: %v", err)
+	}
+	return s, dir
+}
+
+func TestFileStore(t *testing.T) {
+	s, dir := fileStore(t)
+	defer s.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	deployID, err := s.Add(ctx, "", []float32{1, 0, 0, 0}, map[string]any{
+		"text": "deploys go out on tuesdays", "type": "fact", "tags": []any{"ops"},
+	})
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	catID, err := s.Add(ctx, "", []float32{0, 1, 0, 0}, map[string]any{
+		"text": "the cat is named Miso", "type": "preference",
+	})
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	t.Run("retrieve ranks by cosine similarity", func(t *testing.T) {
+		results, err := s.Retrieve(ctx, []float32{1, 0.2, 0, 0}, 0, 10)
+		if err != nil {
+			t.Fatalf("Retrieve failed: %v", err)
+		}
+		if len(results) != 2 || results[0].ID != deployID || results[1].ID != catID {
+			t.Fatalf("expected the deploy memory first, got %+v", results)
+		}
+		if results[0].Score < 0.98 {
+			t.Errorf("expected a near-identical score, got %f", results[0].Score)
+		}
+
+		results, err = s.Retrieve(ctx, []float32{1, 0.2, 0, 0}, 0.5, 10)
+		if err != nil {
+			t.Fatalf("Retrieve failed: %v", err)
+		}
+		if len(results) != 1 {
+			t.Errorf("expected min score to drop the cat memory, got %d results", len(results))
+		}
+	})
+
+	t.Run("filters apply inside the search", func(t *testing.T) {
+		results, err := s.FindSimilarFiltered(ctx, []float32{1, 0, 0, 0}, 0, 10, Filter{Types: []string{"preference"}})
+		if err != nil {
+			t.Fatalf("FindSimilarFiltered failed: %v", err)
+		}
+		if len(results) != 1 || results[0].ID != catID {
+			t.Errorf("expected only the preference, got %+v", results)
+		}
+		results, err = s.FindSimilarFiltered(ctx, []float32{1, 0, 0, 0}, 0, 10, Filter{TextContains: []string{"Tuesdays"}})
+		if err != nil {
+			t.Fatalf("FindSimilarFiltered failed: %v", err)
+		}
+		if len(results) != 1 || results[0].ID != deployID {
+			t.Errorf("expected the full-text match, got %+v", results)
+		}
+	})
+
+	t.Run("tags are faceted", func(t *testing.T) {
+		tags, err := s.TagCounts(ctx, Filter{})
+		if err != nil {
+			t.Fatalf("TagCounts failed: %v", err)
+		}
+		if len(tags) != 1 || tags[0] != (TagCount{Tag: "ops", Count: 1}) {
+			t.Errorf("expected one ops tag, got %+v", tags)
+		}
+	})
+
+	t.Run("updates honor preconditions", func(t *testing.T) {
+		stale := int64(7)
+		err := s.Update(ctx, catID, []float32{0, 1, 0, 0}, map[string]any{"text": "the cat is named Mochi"}, Precondition{Revision: &stale})
+		var ce *ConflictError
+		if !errors.As(err, &ce) {
+			t.Fatalf("expected a conflict, got %v", err)
+		}
+		if err := s.SetPayloads(ctx, map[string]map[string]any{catID: {"pet": "cat"}}); err != nil {
+			t.Fatalf("SetPayloads failed: %v", err)
+		}
+		got, err := s.Peek(ctx, catID)
+		if err != nil {
+			t.Fatalf("Peek failed: %v", err)
+		}
+		if got.Payload["pet"] != "cat" || got.Payload["text"] != "the cat is named Miso" {
+			t.Errorf("expected the payload merged, got %v", got.Payload)
+		}
+	})
+
+	t.Run("memories persist across stores", func(t *testing.T) {
+		reopened, err := NewFile(dir)
+		if err != nil {
+			t.Fatalf("NewFile failed: %v", err)
+		}
+		defer reopened.Close()
+		n, err := reopened.Count(ctx)
+		if err != nil {
+			t.Fatalf("Count failed: %v", err)
+		}
+		if n != 2 {
+			t.Errorf("expected 2 memories on disk, got %d", n)
+		}
+		if err := reopened.DeleteIDs(ctx, []string{deployID}); err != nil {
+			t.Fatalf("DeleteIDs failed: %v", err)
+		}
+		if n, _ := s.Count(ctx); n != 1 {
+			t.Errorf("expected the first store to see the delete, got %d memories", n)
+		}
+	})
+}
+
+func TestFileStoreForget(t *testing.T) {
+	s, _ := fileStore(t)
+	defer s.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if deleted, err := s.Forget(ctx, time.Hour); err != nil || deleted != 0 {
+		t.Fatalf("expected no error and nothing to forget in a missing collection, got %d, %v", deleted, err)
+	}
+	for i := range 25 {
+		if _, err := s.Add(ctx, "", []float32{1, float32(i), 0, 0}, map[string]any{"text": fmt.Sprint("memory ", i)}); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+	time.Sleep(1100 * time.Millisecond)
+	// 25 memories take several scroll pages.
+	deleted, err := s.Forget(ctx, time.Second)
+	if err != nil {
+		t.Fatalf("Forget failed: %v", err)
+	}
+	if deleted != 25 {
+		t.Errorf("expected 25 deletions, got %d", deleted)
+	}
+}
+
+func TestFileBackendConcurrentWriters(t *testing.T) {
+	dir := t.TempDir()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// Each writer has its own backend, as separate processes would.
+	first, err := NewFile(dir)
+	if err != nil {
+		t.Fatalf("NewFile failed: %v", err)
+	}
+	if _, err := first.Add(ctx, "", []float32{1, 0, 0, 0}, map[string]any{"text": "seed"}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 40)
+	for w := range 4 {
+		s, err := NewFile(dir)
+		if err != nil {
+			t.Fatalf("NewFile failed: %v", err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 10 {
+				_, err := s.Add(ctx, "", []float32{1, float32(w), float32(i), 0}, map[string]any{"text": fmt.Sprint(w, i)})
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+	if n, err := first.Count(ctx); err != nil || n != 41 {
+		t.Errorf("expected every write to land, got %d memories (%v)", n, err)
+	}
+}
+
+func TestFileStoreTimeRange(t *testing.T) {
+	s, _ := fileStore(t)
+	defer s.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	ids := map[string]string{}
+	for _, day := range []string{"2026-10-01", "2026-10-10", "2026-10-14"} {
+		id, err := s.Add(ctx, "", []float32{1, 0, 0, 0}, map[string]any{
+			"text": "standup notes from " + day, CreatedAtField: day + "T09:00:00Z",
+		})
+		if err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+		ids[day] = id
+	}
+	found := func(f Filter) []string {
+		t.Helper()
+		results, err := s.FindSimilarFiltered(ctx, []float32{1, 0, 0, 0}, 0, 10, f)
+		if err != nil {
+			t.Fatalf("FindSimilarFiltered failed: %v", err)
+		}
+		var days []string
+		for day, id := range ids {
+			for _, r := range results {
+				if r.ID == id {
+					days = append(days, day)
+				}
+			}
+		}
+		sort.Strings(days)
+		return days
+	}
+	day := func(s string) time.Time {
+		d, _ := time.Parse(time.DateOnly, s)
+		return d
+	}
+
+	if got := found(Filter{Since: day("2026-10-10")}); !reflect.DeepEqual(got, []string{"2026-10-10", "2026-10-14"}) {
+		t.Errorf("since: got %v", got)
+	}
+	// Until is exclusive.
+	if got := found(Filter{Since: day("2026-10-01"), Until: day("2026-10-14")}); !reflect.DeepEqual(got, []string{"2026-10-01", "2026-10-10"}) {
+		t.Errorf("since and until: got %v", got)
+	}
+	// Adding set last_accessed to now.
+	if got := found(Filter{Since: time.Now().Add(-time.Minute), TimeField: LastAccessedField}); len(got) != 3 {
+		t.Errorf("last_accessed: expected all, got %v", got)
+	}
+}
+
+func TestRecent(t *testing.T) {
+	for name, open := range map[string]func(t *testing.T) *Store{
+		"file": func(t *testing.T) *Store { s, _ := fileStore(t); return s },
+		"sqlite": func(t *testing.T) *Store {
+			s, err := NewSQLite(filepath.Join(t.TempDir(), "memories.db"))
+			if err != nil {
+				t.Fatalf("NewSQLite failed: %v", err)
+			}
+			return s
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := open(t)
+			defer s.Close()
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+
+			ids := map[string]string{}
+			for _, m := range []struct{ day, kind string }{
+				{"2026-10-10", "todo"}, {"2026-10-14", "fact"}, {"2026-10-01", "todo"}, {"2026-10-12", "todo"},
+			} {
+				id, err := s.Add(ctx, "", []float32{1, 0, 0, 0}, map[string]any{
+					"text": "notes from " + m.day, "type": m.kind, CreatedAtField: m.day + "T09:00:00Z",
+				})
+				if err != nil {
+					t.Fatalf("Add failed: %v", err)
+				}
+				ids[id] = m.day
+			}
+			days := func(limit uint32, f Filter) []string {
+				t.Helper()
+				results, err := s.Recent(ctx, limit, f)
+				if err != nil {
+					t.Fatalf("Recent failed: %v", err)
+				}
+				var out []string
+				for _, r := range results {
+					out = append(out, ids[r.ID])
+				}
+				return out
+			}
+
+			if got := days(3, Filter{}); !reflect.DeepEqual(got, []string{"2026-10-14", "2026-10-12", "2026-10-10"}) {
+				t.Errorf("expected the 3 newest, newest first; got %v", got)
+			}
+			if got := days(10, Filter{Types: []string{"todo"}}); !reflect.DeepEqual(got, []string{"2026-10-12", "2026-10-10", "2026-10-01"}) {
+				t.Errorf("expected the todos, newest first; got %v", got)
+			}
+		})
+	}
+}
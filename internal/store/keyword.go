@@ -0,0 +1,141 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/hsk-coder/clawbrain/internal/textnorm"
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// TextField is the payload field holding a memory's original text. It has a
+// full-text index, so keyword conditions on it match whole words rather than
+// scanning every payload for substrings.
+const TextField = "text"
+
+// cjkOverfetch is how many more memories FindKeyword asks for when the
+// terms include Chinese, Japanese or Korean, which the full-text index
+// can't find, so that enough remain once it has picked the matches out
+// itself. maxCJKFetch caps the ask.
+const (
+	cjkOverfetch = 10
+	maxCJKFetch  = 500
+)
+
+// FindKeyword returns memories whose text contains at least one of terms,
+// ranked by similarity to vector. It is the keyword half of a hybrid search:
+// a memory that names the exact thing asked about is found even when its
+// embedding ranks it below looser matches. Terms should be lowercase words,
+// as ranking.Terms returns them.
+//
+// The index splits text on spaces and punctuation, so it sees a sentence of
+// Chinese or Japanese as a single word. When a term is CJK (see
+// textnorm.IsCJK), FindKeyword instead takes the memories most similar to
+// vector and keeps those containing a term, which can miss a match that
+// ranks far down by similarity.
+// Like FindSimilar, it does NOT update last_accessed.
+func (s *Store) FindKeyword(ctx context.Context, vector []float32, terms []string, threshold float32, limit uint64, filter Filter) ([]Result, error) {
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	exists, err := s.client.CollectionExists(ctx, s.collection)
+	if err != nil {
+		return nil, fmt.Errorf("check collection: %w", err)
+	}
+	if !exists {
+		return nil, nil
+	}
+	if err := s.ensureTextIndex(ctx); err != nil {
+		return nil, err
+	}
+
+	cjk := slices.ContainsFunc(terms, textnorm.IsCJK)
+	f := filter.qdrantFilter()
+	if f == nil {
+		f = &qdrant.Filter{}
+	}
+	fetch := limit
+	if cjk {
+		fetch = min(limit*cjkOverfetch, max(limit, maxCJKFetch))
+	} else {
+		either := make([]*qdrant.Condition, 0, len(terms))
+		for _, term := range terms {
+			either = append(either, qdrant.NewMatchText(TextField, term))
+		}
+		f.Must = append(f.Must, qdrant.NewFilterAsCondition(&qdrant.Filter{Should: either}))
+	}
+
+	results, err := s.client.Query(ctx, &qdrant.QueryPoints{
+		CollectionName: s.collection,
+		Query:          qdrant.NewQuery(vector...),
+		Filter:         s.scoped(f),
+		WithPayload:    qdrant.NewWithPayload(true),
+		ScoreThreshold: &threshold,
+		Limit:          &fetch,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	out := make([]Result, 0, len(results))
+	for _, point := range results {
+		r := Result{
+			ID:      pointIDToString(point.Id),
+			Score:   point.Score,
+			Payload: valueMapToGoMap(point.Payload),
+		}
+		if cjk && !containsAnyTerm(r.Payload, terms) {
+			continue
+		}
+		out = append(out, r)
+		if uint64(len(out)) == limit {
+			break
+		}
+	}
+	return filter.mustContain(out), nil
+}
+
+// containsAnyTerm reports whether a memory's text has one of terms: as a
+// word, or for a CJK term anywhere in it.
+func containsAnyTerm(payload map[string]any, terms []string) bool {
+	text, _ := payload[TextField].(string)
+	folded := strings.ToLower(textnorm.Fold(text))
+	words := textnorm.Words(text)
+	for _, t := range terms {
+		if textnorm.IsCJK(t) && strings.Contains(folded, t) || slices.Contains(words, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// ensureTextIndex creates the full-text index on text for collections made
+// before it was part of payloadIndexes. Without it, keyword conditions fall
+// back to case-sensitive substring matching. It checks once per Store.
+func (s *Store) ensureTextIndex(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.textChecked {
+		return nil
+	}
+	info, err := s.client.GetCollectionInfo(ctx, s.collection)
+	if err != nil {
+		return fmt.Errorf("collection info: %w", err)
+	}
+	if _, ok := info.GetPayloadSchema()[TextField]; !ok {
+		wait := true
+		_, err := s.client.CreateFieldIndex(ctx, &qdrant.CreateFieldIndexCollection{
+			CollectionName: s.collection,
+			Wait:           &wait,
+			FieldName:      TextField,
+			FieldType:      qdrant.FieldType_FieldTypeText.Enum(),
+		})
+		if err != nil {
+			return fmt.Errorf("create %s index: %w", TextField, err)
+		}
+	}
+	s.textChecked = true
+	return nil
+}
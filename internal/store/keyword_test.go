@@ -0,0 +1,61 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestFindKeyword_CJK(t *testing.T) {
+	s, _ := fileStore(t)
+	defer s.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	ids := map[string]string{}
+	for name, text := range map[string]string{
+		"tower":  "来週、東京タワーの近くでＡＰＩキーを更新する",
+		"seoul":  "서울에서 분기 회의를 했다",
+		"plain":  "rotate the api key on tuesday",
+		"nearby": "大阪の天気",
+	} {
+		id, err := s.Add(ctx, "", []float32{1, 0, 0, 0}, map[string]any{"text": text})
+		if err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+		ids[id] = name
+	}
+	names := func(results []Result) map[string]bool {
+		out := map[string]bool{}
+		for _, r := range results {
+			out[ids[r.ID]] = true
+		}
+		return out
+	}
+
+	found, err := s.FindKeyword(ctx, []float32{1, 0, 0, 0}, []string{"東京", "서울"}, 0, 10, Filter{})
+	if err != nil {
+		t.Fatalf("FindKeyword failed: %v", err)
+	}
+	if got := names(found); len(got) != 2 || !got["tower"] || !got["seoul"] {
+		t.Errorf("expected the Tokyo and Seoul memories, got %v", got)
+	}
+	if found, _ := s.FindKeyword(ctx, []float32{1, 0, 0, 0}, []string{"東京", "서울"}, 0, 1, Filter{}); len(found) != 1 {
+		t.Errorf("expected the limit to hold, got %d results", len(found))
+	}
+
+	found, err = s.FindSimilarFiltered(ctx, []float32{1, 0, 0, 0}, 0, 10, Filter{MustContain: []string{"ａｐｉキー"}})
+	if err != nil {
+		t.Fatalf("FindSimilarFiltered failed: %v", err)
+	}
+	if got := names(found); len(got) != 1 || !got["tower"] {
+		t.Errorf("expected must-contain to match across widths, got %v", got)
+	}
+	found, err = s.FindSimilarFiltered(ctx, []float32{1, 0, 0, 0}, 0, 10, Filter{MustContain: []string{"분기 회의"}})
+	if err != nil {
+		t.Fatalf("FindSimilarFiltered failed: %v", err)
+	}
+	if got := names(found); len(got) != 1 || !got["seoul"] {
+		t.Errorf("expected must-contain to match Korean, got %v", got)
+	}
+}
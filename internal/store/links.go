@@ -0,0 +1,273 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// Payload fields linking memories to each other.
+const (
+	RelationsField    = "relations"     // [{"id": ..., "kind": ...}], kept on both ends
+	SupersededByField = "superseded_by" // ID of the memory that replaced this one
+	SupersededAtField = "superseded_at"
+)
+
+// DefaultRelationKind is the kind of a relation given without one.
+const DefaultRelationKind = "related"
+
+// relationKind keeps kinds short, lowercase keywords like "caused-by".
+var relationKind = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,31}$`)
+
+// Relation links a memory to another one.
+type Relation struct {
+	ID   string `json:"id"`
+	Kind string `json:"kind"`
+}
+
+// ValidateID checks that id is a memory ID, i.e. a UUID.
+func ValidateID(id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return fmt.Errorf("%q is not a memory ID", id)
+	}
+	return nil
+}
+
+// ParseRelation parses "ID" or "ID:KIND". The ID must be a UUID.
+func ParseRelation(spec string) (Relation, error) {
+	id, kind := spec, DefaultRelationKind
+	if i := strings.LastIndexByte(spec, ':'); i >= 0 {
+		id, kind = spec[:i], spec[i+1:]
+	}
+	if err := ValidateID(id); err != nil {
+		return Relation{}, fmt.Errorf("relation %q: %w", spec, err)
+	}
+	if !relationKind.MatchString(kind) {
+		return Relation{}, fmt.Errorf("relation %q: kind must be a lowercase word like %q", spec, DefaultRelationKind)
+	}
+	return Relation{ID: id, Kind: kind}, nil
+}
+
+// Relations returns the relations stored in a payload.
+func Relations(payload map[string]any) []Relation {
+	list, _ := payload[RelationsField].([]any)
+	out := make([]Relation, 0, len(list))
+	for _, item := range list {
+		m, _ := item.(map[string]any)
+		id, _ := m["id"].(string)
+		kind, _ := m["kind"].(string)
+		if id != "" {
+			out = append(out, Relation{ID: id, Kind: kind})
+		}
+	}
+	return out
+}
+
+// withRelation adds r to the list unless an identical relation is there.
+func withRelation(list []Relation, r Relation) []Relation {
+	for _, have := range list {
+		if have == r {
+			return list
+		}
+	}
+	return append(list, r)
+}
+
+// relationsValue converts relations to the payload's stored form.
+func relationsValue(list []Relation) []any {
+	out := make([]any, len(list))
+	for i, r := range list {
+		out[i] = map[string]any{"id": r.ID, "kind": r.Kind}
+	}
+	return out
+}
+
+// Links are the edges a new memory brings with it: relations to existing
+// memories and the memories it supersedes.
+type Links struct {
+	Relations  []Relation
+	Supersedes []string
+}
+
+// Empty reports whether there is nothing to link.
+func (l Links) Empty() bool {
+	return len(l.Relations) == 0 && len(l.Supersedes) == 0
+}
+
+// Targets returns the IDs of every memory the links touch, without repeats.
+func (l Links) Targets() []string {
+	seen := make(map[string]bool)
+	var ids []string
+	add := func(id string) {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	for _, r := range l.Relations {
+		add(r.ID)
+	}
+	for _, id := range l.Supersedes {
+		add(id)
+	}
+	return ids
+}
+
+// AddLinked stores a new memory together with its links as one unit. Every
+// linked memory must exist, so no link dangles. The new point, the reverse
+// relation on each related memory, and superseded_by on each superseded
+// memory are written in a single Qdrant batch; if the batch fails partway,
+// the writes that landed are undone before the error is returned. Returns
+// an error if id names an existing memory: links are only made on creation.
+// Each superseded memory must satisfy pre, or nothing is written and a
+// *ConflictError is returned.
+func (s *Store) AddLinked(ctx context.Context, id string, vector []float32, payload map[string]any, links Links, pre Precondition) (string, error) {
+	if links.Empty() {
+		return s.Add(ctx, id, vector, payload)
+	}
+	if id == "" {
+		id = uuid.New().String()
+	} else {
+		for _, t := range s.Tiers() {
+			if existing, err := t.Peek(ctx, id); err != nil {
+				return "", err
+			} else if existing != nil {
+				return "", fmt.Errorf("memory %s already exists; links can only be added with a new memory", id)
+			}
+		}
+	}
+
+	// Read every target first: a missing one, or a superseded one that
+	// changed since the caller read it, fails the whole add before anything
+	// is written. A target in the cold tier is moved back to be written to.
+	superseded := make(map[string]bool)
+	for _, tid := range links.Supersedes {
+		superseded[tid] = true
+	}
+	targets := make(map[string]map[string]any)
+	for _, tid := range links.Targets() {
+		if tid == id {
+			return "", fmt.Errorf("memory %s can't link to itself", id)
+		}
+		if _, err := s.Warm(ctx, tid); err != nil {
+			return "", err
+		}
+		r, err := s.Peek(ctx, tid)
+		if err != nil {
+			return "", err
+		}
+		if superseded[tid] {
+			var current map[string]any
+			if r != nil {
+				current = r.Payload
+			}
+			if err := pre.Check(tid, current); err != nil {
+				return "", err
+			}
+		}
+		if r == nil {
+			return "", fmt.Errorf("linked memory %s not found", tid)
+		}
+		targets[tid] = r.Payload
+	}
+
+	if err := s.ensureCollection(ctx, uint64(len(vector))); err != nil {
+		return "", err
+	}
+	s.stamp(payload)
+	if _, exists := payload[RevisionField]; !exists {
+		payload[RevisionField] = int64(1)
+	}
+	own := Relations(payload)
+	for _, r := range links.Relations {
+		own = withRelation(own, r)
+	}
+	payload[RelationsField] = relationsValue(own)
+
+	newID := qdrant.NewIDUUID(id)
+	ops := []*qdrant.PointsUpdateOperation{
+		qdrant.NewPointsUpdateUpsert(&qdrant.PointsUpdateOperation_PointStructList{
+			Points: []*qdrant.PointStruct{{
+				Id:      newID,
+				Vectors: qdrant.NewVectors(vector...),
+				Payload: qdrant.NewValueMap(payload),
+			}},
+		}),
+	}
+	// undo restores each target's fields as they were before the batch.
+	undo := []*qdrant.PointsUpdateOperation{
+		qdrant.NewPointsUpdateDeletePoints(&qdrant.PointsUpdateOperation_DeletePoints{
+			Points: qdrant.NewPointsSelector(newID),
+		}),
+	}
+	set := func(tid string, fields map[string]any) {
+		ops = append(ops, qdrant.NewPointsUpdateSetPayload(&qdrant.PointsUpdateOperation_SetPayload{
+			Payload:        qdrant.NewValueMap(fields),
+			PointsSelector: s.selector(qdrant.NewIDUUID(tid)),
+		}))
+		before := make(map[string]any)
+		var missing []string
+		for key := range fields {
+			if v, ok := targets[tid][key]; ok {
+				before[key] = v
+			} else {
+				missing = append(missing, key)
+			}
+		}
+		if len(before) > 0 {
+			undo = append(undo, qdrant.NewPointsUpdateSetPayload(&qdrant.PointsUpdateOperation_SetPayload{
+				Payload:        qdrant.NewValueMap(before),
+				PointsSelector: s.selector(qdrant.NewIDUUID(tid)),
+			}))
+		}
+		if len(missing) > 0 {
+			undo = append(undo, qdrant.NewPointsUpdateDeletePayload(&qdrant.PointsUpdateOperation_DeletePayload{
+				Keys:           missing,
+				PointsSelector: s.selector(qdrant.NewIDUUID(tid)),
+			}))
+		}
+	}
+
+	// Relations are kept on both ends, so either memory leads to the other.
+	reverse := make(map[string][]Relation)
+	for _, r := range links.Relations {
+		if _, ok := reverse[r.ID]; !ok {
+			reverse[r.ID] = Relations(targets[r.ID])
+		}
+		reverse[r.ID] = withRelation(reverse[r.ID], Relation{ID: id, Kind: r.Kind})
+	}
+	for _, tid := range links.Targets() {
+		if rel, ok := reverse[tid]; ok {
+			set(tid, map[string]any{RelationsField: relationsValue(rel)})
+		}
+	}
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	for _, tid := range links.Supersedes {
+		set(tid, map[string]any{SupersededByField: id, SupersededAtField: now})
+	}
+
+	wait := true
+	_, err := s.client.UpdateBatch(ctx, &qdrant.UpdateBatchPoints{
+		CollectionName: s.collection,
+		Wait:           &wait,
+		Operations:     ops,
+	})
+	if err != nil {
+		_, undoErr := s.client.UpdateBatch(ctx, &qdrant.UpdateBatchPoints{
+			CollectionName: s.collection,
+			Wait:           &wait,
+			Operations:     undo,
+		})
+		if undoErr != nil {
+			return "", fmt.Errorf("add linked memory: %w (rollback also failed: %v)", err, undoErr)
+		}
+		return "", fmt.Errorf("add linked memory: %w (rolled back)", err)
+	}
+	s.changed(ctx)
+	return id, nil
+}
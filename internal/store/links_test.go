@@ -0,0 +1,97 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParseRelation(t *testing.T) {
+	id := "4f8a7c1e-2b3d-4e5f-8a9b-0c1d2e3f4a5b"
+	r, err := ParseRelation(id)
+	if err != nil || r != (Relation{ID: id, Kind: DefaultRelationKind}) {
+		t.Errorf("ParseRelation(%q) = %v, %v", id, r, err)
+	}
+	r, err = ParseRelation(id + ":caused-by")
+	if err != nil || r.Kind != "caused-by" {
+		t.Errorf("expected kind caused-by, got %v, %v", r, err)
+	}
+	for _, bad := range []string{"deploy-notes", id + ":", id + ":Caused By", "x:related"} {
+		if _, err := ParseRelation(bad); err == nil {
+			t.Errorf("ParseRelation(%q): expected error", bad)
+		}
+	}
+}
+
+func TestLinksTargets(t *testing.T) {
+	links := Links{
+		Relations:  []Relation{{ID: "a", Kind: "related"}, {ID: "b", Kind: "related"}, {ID: "a", Kind: "caused-by"}},
+		Supersedes: []string{"b", "c"},
+	}
+	got := links.Targets()
+	if len(got) != 3 || got[0] != "a" || got[1] != "b" || got[2] != "c" {
+		t.Errorf("expected [a b c], got %v", got)
+	}
+	if !(Links{}).Empty() || links.Empty() {
+		t.Error("Empty is wrong")
+	}
+}
+
+func TestExcludeSuperseded(t *testing.T) {
+	f := Filter{ExcludeSuperseded: true}.qdrantFilter()
+	if f == nil || len(f.Must) != 1 || f.Must[0].GetIsEmpty().GetKey() != SupersededByField {
+		t.Errorf("expected superseded_by to be required empty, got %v", f)
+	}
+}
+
+func TestAddLinked(t *testing.T) {
+	s := testStore(t)
+	defer s.Close()
+	defer cleanupMemories(t, s)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	vector := []float32{0.1, 0.2, 0.3, 0.4}
+	old, err := s.Add(ctx, "", vector, map[string]any{"text": "standup is at 09:30"})
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	ticket, err := s.Add(ctx, "", vector, map[string]any{"text": "ticket OPS-12: move standup"})
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	id, err := s.AddLinked(ctx, "", vector, map[string]any{"text": "standup is at 10:00"}, Links{
+		Relations:  []Relation{{ID: ticket, Kind: "caused-by"}},
+		Supersedes: []string{old},
+	}, Precondition{})
+	if err != nil {
+		t.Fatalf("AddLinked failed: %v", err)
+	}
+
+	got, _ := s.Peek(ctx, id)
+	if rel := Relations(got.Payload); len(rel) != 1 || rel[0] != (Relation{ID: ticket, Kind: "caused-by"}) {
+		t.Errorf("expected the new memory to relate to the ticket, got %v", rel)
+	}
+	back, _ := s.Peek(ctx, ticket)
+	if rel := Relations(back.Payload); len(rel) != 1 || rel[0].ID != id {
+		t.Errorf("expected the reverse relation on the ticket, got %v", rel)
+	}
+	superseded, _ := s.Peek(ctx, old)
+	if superseded.Payload[SupersededByField] != id {
+		t.Errorf("expected superseded_by %s, got %v", id, superseded.Payload[SupersededByField])
+	}
+
+	// A missing target fails the whole add; nothing is written.
+	before, _ := s.Count(ctx)
+	_, err = s.AddLinked(ctx, "", vector, map[string]any{"text": "orphan"}, Links{
+		Relations: []Relation{{ID: "4f8a7c1e-2b3d-4e5f-8a9b-0c1d2e3f4a5b", Kind: "related"}},
+	}, Precondition{})
+	if err == nil {
+		t.Fatal("expected an error for a missing linked memory")
+	}
+	if after, _ := s.Count(ctx); after != before {
+		t.Errorf("expected no memory to be stored, count went from %d to %d", before, after)
+	}
+}
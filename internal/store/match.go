@@ -0,0 +1,312 @@
+package store
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// matchFilter evaluates a Qdrant filter against a point the way Qdrant
+// does, for backends that filter in memory. A nil filter matches
+// everything.
+func matchFilter(f *qdrant.Filter, id string, payload map[string]any) bool {
+	if f == nil {
+		return true
+	}
+	for _, c := range f.GetMust() {
+		if !matchCondition(c, id, payload) {
+			return false
+		}
+	}
+	for _, c := range f.GetMustNot() {
+		if matchCondition(c, id, payload) {
+			return false
+		}
+	}
+	if should := f.GetShould(); len(should) > 0 {
+		if !slices.ContainsFunc(should, func(c *qdrant.Condition) bool {
+			return matchCondition(c, id, payload)
+		}) {
+			return false
+		}
+	}
+	if ms := f.GetMinShould(); ms != nil {
+		n := uint64(0)
+		for _, c := range ms.GetConditions() {
+			if matchCondition(c, id, payload) {
+				n++
+			}
+		}
+		if n < ms.GetMinCount() {
+			return false
+		}
+	}
+	return true
+}
+
+// matchCondition evaluates a single condition of a filter.
+func matchCondition(c *qdrant.Condition, id string, payload map[string]any) bool {
+	switch cond := c.GetConditionOneOf().(type) {
+	case *qdrant.Condition_Filter:
+		return matchFilter(cond.Filter, id, payload)
+	case *qdrant.Condition_HasId:
+		return slices.ContainsFunc(cond.HasId.GetHasId(), func(p *qdrant.PointId) bool {
+			return pointIDToString(p) == id
+		})
+	case *qdrant.Condition_IsEmpty:
+		return isEmptyValue(payloadValue(payload, cond.IsEmpty.GetKey()))
+	case *qdrant.Condition_IsNull:
+		v, ok := payloadField(payload, cond.IsNull.GetKey())
+		return ok && v == nil
+	case *qdrant.Condition_Field:
+		return matchField(cond.Field, payload)
+	}
+	return false
+}
+
+// matchField evaluates a field condition. Like Qdrant, a condition on an
+// array field matches if any element matches.
+func matchField(fc *qdrant.FieldCondition, payload map[string]any) bool {
+	v, ok := payloadField(payload, fc.GetKey())
+	if fc.IsEmpty != nil && *fc.IsEmpty != isEmptyValue(v) {
+		return false
+	}
+	if fc.IsNull != nil && *fc.IsNull != (ok && v == nil) {
+		return false
+	}
+	if fc.GetMatch() == nil && fc.GetRange() == nil && fc.GetDatetimeRange() == nil && fc.GetGeoRadius() == nil {
+		return true
+	}
+	if !ok || v == nil {
+		return false
+	}
+	values := []any{v}
+	if list, isList := v.([]any); isList {
+		values = list
+	}
+
+	if m := fc.GetMatch(); m != nil {
+		if except, ok := exceptMatch(m); ok {
+			return !slices.ContainsFunc(values, except)
+		}
+		if !slices.ContainsFunc(values, func(v any) bool { return matchValue(m, v) }) {
+			return false
+		}
+	}
+	if r := fc.GetRange(); r != nil && !slices.ContainsFunc(values, func(v any) bool { return inRange(r, v) }) {
+		return false
+	}
+	if r := fc.GetDatetimeRange(); r != nil && !slices.ContainsFunc(values, func(v any) bool { return inDatetimeRange(r, v) }) {
+		return false
+	}
+	if g := fc.GetGeoRadius(); g != nil && !slices.ContainsFunc(values, func(v any) bool {
+		lat, lon, ok := geoPoint(v)
+		return ok && distanceMeters(g.GetCenter().GetLat(), g.GetCenter().GetLon(), lat, lon) <= float64(g.GetRadius())
+	}) {
+		return false
+	}
+	return true
+}
+
+// matchValue reports whether one payload value satisfies a match.
+func matchValue(m *qdrant.Match, v any) bool {
+	switch mv := m.GetMatchValue().(type) {
+	case *qdrant.Match_Keyword:
+		s, ok := v.(string)
+		return ok && s == mv.Keyword
+	case *qdrant.Match_Keywords:
+		s, ok := v.(string)
+		return ok && slices.Contains(mv.Keywords.GetStrings(), s)
+	case *qdrant.Match_Integer:
+		n, ok := v.(int64)
+		return ok && n == mv.Integer
+	case *qdrant.Match_Integers:
+		n, ok := v.(int64)
+		return ok && slices.Contains(mv.Integers.GetIntegers(), n)
+	case *qdrant.Match_Boolean:
+		b, ok := v.(bool)
+		return ok && b == mv.Boolean
+	case *qdrant.Match_Text:
+		s, ok := v.(string)
+		return ok && containsTokens(s, mv.Text)
+	case *qdrant.Match_Phrase:
+		s, ok := v.(string)
+		return ok && strings.Contains(strings.Join(tokens(s), " "), strings.Join(tokens(mv.Phrase), " "))
+	case *qdrant.Match_TextAny:
+		s, ok := v.(string)
+		if !ok {
+			return false
+		}
+		have := tokens(s)
+		return slices.ContainsFunc(tokens(mv.TextAny), func(t string) bool { return slices.Contains(have, t) })
+	}
+	return false
+}
+
+// exceptMatch returns, for an "except" match, the predicate a value must
+// not satisfy.
+func exceptMatch(m *qdrant.Match) (func(any) bool, bool) {
+	switch mv := m.GetMatchValue().(type) {
+	case *qdrant.Match_ExceptKeywords:
+		return func(v any) bool {
+			s, ok := v.(string)
+			return ok && slices.Contains(mv.ExceptKeywords.GetStrings(), s)
+		}, true
+	case *qdrant.Match_ExceptIntegers:
+		return func(v any) bool {
+			n, ok := v.(int64)
+			return ok && slices.Contains(mv.ExceptIntegers.GetIntegers(), n)
+		}, true
+	}
+	return nil, false
+}
+
+// inRange reports whether a numeric payload value is within r. Strings are
+// never in range, even when they look like numbers.
+func inRange(r *qdrant.Range, v any) bool {
+	if _, isStr := v.(string); isStr {
+		return false
+	}
+	n, ok := number(v)
+	if !ok {
+		return false
+	}
+	return (r.Lt == nil || n < *r.Lt) && (r.Lte == nil || n <= *r.Lte) &&
+		(r.Gt == nil || n > *r.Gt) && (r.Gte == nil || n >= *r.Gte)
+}
+
+// inDatetimeRange reports whether an RFC 3339 payload value is within r.
+func inDatetimeRange(r *qdrant.DatetimeRange, v any) bool {
+	s, ok := v.(string)
+	if !ok {
+		return false
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return false
+	}
+	return (r.Lt == nil || t.Before(r.Lt.AsTime())) && (r.Lte == nil || !t.After(r.Lte.AsTime())) &&
+		(r.Gt == nil || t.After(r.Gt.AsTime())) && (r.Gte == nil || !t.Before(r.Gte.AsTime()))
+}
+
+// orderedPoint is a point of a scroll with order_by and the value it sorts
+// on.
+type orderedPoint struct {
+	id    string
+	point *filePoint
+	key   float64
+}
+
+// orderKey returns the value order_by sorts a point on: a number as it is,
+// an RFC 3339 time in Unix seconds. Like Qdrant, points without one are
+// left out of the scroll (ok is false).
+func orderKey(payload map[string]any, key string) (float64, bool) {
+	switch v := payloadValue(payload, key).(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return 0, false
+		}
+		return float64(t.UnixNano()) / 1e9, true
+	}
+	return 0, false
+}
+
+// scrollOrdered answers a scroll with order_by from the points matching
+// its filter: sorted on the order_by key, ties by ID, and cut to the
+// limit. As in Qdrant, such a scroll has no next page.
+func scrollOrdered(points []orderedPoint, request *qdrant.ScrollPoints) []*qdrant.RetrievedPoint {
+	desc := request.GetOrderBy().GetDirection() == qdrant.Direction_Desc
+	slices.SortStableFunc(points, func(a, b orderedPoint) int {
+		if a.key != b.key {
+			if (a.key < b.key) != desc {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.id, b.id)
+	})
+	limit := defaultFileLimit
+	if request.Limit != nil {
+		limit = int(request.GetLimit())
+	}
+	points = points[:min(limit, len(points))]
+	out := make([]*qdrant.RetrievedPoint, len(points))
+	for i, o := range points {
+		out[i] = o.point.retrieved(o.id, request.GetWithPayload(), request.GetWithVectors())
+	}
+	return out
+}
+
+// payloadField looks up a key, following dots into nested objects.
+func payloadField(payload map[string]any, key string) (any, bool) {
+	if v, ok := payload[key]; ok {
+		return v, true
+	}
+	head, rest, nested := strings.Cut(key, ".")
+	if !nested {
+		return nil, false
+	}
+	inner, ok := payload[head].(map[string]any)
+	if !ok {
+		return nil, false
+	}
+	return payloadField(inner, rest)
+}
+
+// payloadValue is payloadField without the found flag.
+func payloadValue(payload map[string]any, key string) any {
+	v, _ := payloadField(payload, key)
+	return v
+}
+
+// isEmptyValue reports whether Qdrant would consider a field empty: missing,
+// null, or an empty array.
+func isEmptyValue(v any) bool {
+	if v == nil {
+		return true
+	}
+	list, isList := v.([]any)
+	return isList && len(list) == 0
+}
+
+// tokens splits text into lowercase words, as Qdrant's word tokenizer does.
+func tokens(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// containsTokens reports whether every word of query is a word of text:
+// Qdrant's full-text match.
+func containsTokens(text, query string) bool {
+	have := tokens(text)
+	for _, t := range tokens(query) {
+		if !slices.Contains(have, t) {
+			return false
+		}
+	}
+	return true
+}
+
+// facetKey renders a payload value as a facet hit's value, or false for
+// values Qdrant doesn't facet (numbers other than integers, objects).
+func facetKey(v any) (*qdrant.FacetValue, string, bool) {
+	switch x := v.(type) {
+	case string:
+		return &qdrant.FacetValue{Variant: &qdrant.FacetValue_StringValue{StringValue: x}}, "s:" + x, true
+	case int64:
+		return &qdrant.FacetValue{Variant: &qdrant.FacetValue_IntegerValue{IntegerValue: x}}, fmt.Sprint("i:", x), true
+	case bool:
+		return &qdrant.FacetValue{Variant: &qdrant.FacetValue_BoolValue{BoolValue: x}}, fmt.Sprint("b:", x), true
+	}
+	return nil, "", false
+}
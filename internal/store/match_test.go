@@ -0,0 +1,59 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/qdrant/go-client/qdrant"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestMatchFilter(t *testing.T) {
+	payload := map[string]any{
+		"text":       "Deploys go out on Tuesdays",
+		"type":       "fact",
+		"tags":       []any{"ops", "release"},
+		"revision":   int64(3),
+		"score":      0.5,
+		"pinned":     true,
+		"created_at": "2026-03-01T10:00:00Z",
+		"source":     map[string]any{"path": "notes.md"},
+		"empty":      []any{},
+	}
+	after := timestamppb.New(time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC))
+	tests := []struct {
+		name   string
+		filter *qdrant.Filter
+		want   bool
+	}{
+		{"nil filter", nil, true},
+		{"keyword", &qdrant.Filter{Must: []*qdrant.Condition{qdrant.NewMatch("type", "fact")}}, true},
+		{"keyword mismatch", &qdrant.Filter{Must: []*qdrant.Condition{qdrant.NewMatch("type", "lesson")}}, false},
+		{"any array element", &qdrant.Filter{Must: []*qdrant.Condition{qdrant.NewMatch("tags", "release")}}, true},
+		{"keywords", &qdrant.Filter{Must: []*qdrant.Condition{qdrant.NewMatchKeywords("type", "lesson", "fact")}}, true},
+		{"except keywords", &qdrant.Filter{Must: []*qdrant.Condition{qdrant.NewMatchExcept("tags", "ops")}}, false},
+		{"integer", &qdrant.Filter{Must: []*qdrant.Condition{qdrant.NewMatchInt("revision", 3)}}, true},
+		{"bool", &qdrant.Filter{Must: []*qdrant.Condition{qdrant.NewMatchBool("pinned", true)}}, true},
+		{"full text ignores case", &qdrant.Filter{Must: []*qdrant.Condition{qdrant.NewMatchText("text", "tuesdays deploys")}}, true},
+		{"full text needs every word", &qdrant.Filter{Must: []*qdrant.Condition{qdrant.NewMatchText("text", "deploys fridays")}}, false},
+		{"phrase", &qdrant.Filter{Must: []*qdrant.Condition{qdrant.NewMatchPhrase("text", "go out")}}, true},
+		{"range", &qdrant.Filter{Must: []*qdrant.Condition{qdrant.NewRange("score", &qdrant.Range{Gte: qdrant.PtrOf(0.5), Lt: qdrant.PtrOf(1.0)})}}, true},
+		{"range on an integer", &qdrant.Filter{Must: []*qdrant.Condition{qdrant.NewRange("revision", &qdrant.Range{Gt: qdrant.PtrOf(3.0)})}}, false},
+		{"datetime range", &qdrant.Filter{Must: []*qdrant.Condition{qdrant.NewDatetimeRange("created_at", &qdrant.DatetimeRange{Gt: after})}}, true},
+		{"nested field", &qdrant.Filter{Must: []*qdrant.Condition{qdrant.NewMatch("source.path", "notes.md")}}, true},
+		{"is empty", &qdrant.Filter{Must: []*qdrant.Condition{qdrant.NewIsEmpty("empty"), qdrant.NewIsEmpty("missing")}}, true},
+		{"is null", &qdrant.Filter{Must: []*qdrant.Condition{qdrant.NewIsNull("missing")}}, false},
+		{"has id", &qdrant.Filter{Must: []*qdrant.Condition{qdrant.NewHasID(qdrant.NewIDUUID("aaaaaaaa-bbbb-cccc-dddd-eeeeeeeeeeee"))}}, true},
+		{"must not", &qdrant.Filter{MustNot: []*qdrant.Condition{qdrant.NewMatch("type", "fact")}}, false},
+		{"should needs one", &qdrant.Filter{Should: []*qdrant.Condition{qdrant.NewMatch("type", "lesson"), qdrant.NewMatch("tags", "ops")}}, true},
+		{"should with none", &qdrant.Filter{Should: []*qdrant.Condition{qdrant.NewMatch("type", "lesson")}}, false},
+		{"nested filter", &qdrant.Filter{Must: []*qdrant.Condition{qdrant.NewFilterAsCondition(&qdrant.Filter{
+			MustNot: []*qdrant.Condition{qdrant.NewMatch("tags", "personal")},
+		})}}, true},
+	}
+	for _, tt := range tests {
+		if got := matchFilter(tt.filter, "aaaaaaaa-bbbb-cccc-dddd-eeeeeeeeeeee", payload); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
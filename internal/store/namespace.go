@@ -0,0 +1,101 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// namespacePrefix starts the name of every namespace's collection, so
+// namespaces can be told apart from other collections on the same Qdrant.
+const namespacePrefix = DefaultCollection + "-"
+
+// maxNamespaceLength bounds namespace names, keeping collection names short.
+const maxNamespaceLength = 64
+
+// namespaceName allows the characters Qdrant accepts in collection names.
+var namespaceName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+// ValidateNamespace checks that name is usable as a namespace.
+func ValidateNamespace(name string) error {
+	if len(name) > maxNamespaceLength {
+		return fmt.Errorf("namespace %q is longer than %d characters", name, maxNamespaceLength)
+	}
+	if !namespaceName.MatchString(name) {
+		return fmt.Errorf("namespace %q must start with a letter or digit and contain only letters, digits, '_' or '-'", name)
+	}
+	return nil
+}
+
+// CollectionFor returns the Qdrant collection holding a namespace's
+// memories. The empty namespace is the default collection.
+func CollectionFor(namespace string) string {
+	if namespace == "" {
+		return DefaultCollection
+	}
+	return namespacePrefix + namespace
+}
+
+// SetNamespace points the store at a namespace's own collection, created on
+// the first write like the default one. Unlike an agent scope, namespaces
+// share nothing: no search, listing or deletion crosses from one into
+// another. An empty name selects the default collection.
+func (s *Store) SetNamespace(name string) {
+	s.collection = CollectionFor(name)
+}
+
+// Namespace returns the namespace the store is pointed at, or "" for the
+// default collection.
+func (s *Store) Namespace() string {
+	if s.collection == DefaultCollection {
+		return ""
+	}
+	return strings.TrimPrefix(s.collection, namespacePrefix)
+}
+
+// NamespaceInfo describes one namespace.
+type NamespaceInfo struct {
+	Name       string `json:"name"` // "" for the default collection
+	Collection string `json:"collection"`
+	Count      uint64 `json:"count"`
+}
+
+// Namespaces lists every namespace that has a collection, the default one
+// first, with how many memories each holds. With an agent scope only that
+// agent's memories are counted.
+func (s *Store) Namespaces(ctx context.Context) ([]NamespaceInfo, error) {
+	names, err := s.client.ListCollections(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list collections: %w", err)
+	}
+	var out []NamespaceInfo
+	for _, collection := range names {
+		var ns string
+		switch {
+		case collection == DefaultCollection:
+		case strings.HasPrefix(collection, namespacePrefix):
+			ns = strings.TrimPrefix(collection, namespacePrefix)
+			if ValidateNamespace(ns) != nil {
+				continue
+			}
+		default:
+			continue
+		}
+		exact := true
+		count, err := s.client.Count(ctx, &qdrant.CountPoints{
+			CollectionName: collection,
+			Filter:         s.scoped(nil),
+			Exact:          &exact,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("count %s: %w", collection, err)
+		}
+		out = append(out, NamespaceInfo{Name: ns, Collection: collection, Count: count})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
@@ -0,0 +1,80 @@
+package store
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateNamespace(t *testing.T) {
+	for _, ok := range []string{"claw", "agent-7", "team_ops"} {
+		if err := ValidateNamespace(ok); err != nil {
+			t.Errorf("ValidateNamespace(%q): %v", ok, err)
+		}
+	}
+	for _, bad := range []string{"", "-lead", "two words", "a.b", "a/b", strings.Repeat("a", maxNamespaceLength+1)} {
+		if err := ValidateNamespace(bad); err == nil {
+			t.Errorf("ValidateNamespace(%q): expected error", bad)
+		}
+	}
+}
+
+func TestSetNamespace(t *testing.T) {
+	s := Store{collection: DefaultCollection}
+	if s.Namespace() != "" {
+		t.Errorf("expected the default namespace, got %q", s.Namespace())
+	}
+	s.SetNamespace("claw")
+	if s.collection != "memories-claw" || s.Namespace() != "claw" {
+		t.Errorf("unexpected collection %q for namespace %q", s.collection, s.Namespace())
+	}
+	s.SetNamespace("")
+	if s.collection != DefaultCollection {
+		t.Errorf("expected the default collection back, got %q", s.collection)
+	}
+}
+
+func TestNamespaceIsolation(t *testing.T) {
+	s := testStore(t)
+	defer s.Close()
+	defer cleanupMemories(t, s)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	cleanupMemories(t, s)
+
+	vector := []float32{0.1, 0.2, 0.3, 0.4}
+	if _, err := s.Add(ctx, "", vector, map[string]any{"text": "default note"}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	s.SetNamespace("test-ns")
+	defer func() {
+		s.SetNamespace("test-ns")
+		cleanupMemories(t, s)
+		s.SetNamespace("")
+	}()
+	if _, err := s.Add(ctx, "", vector, map[string]any{"text": "namespaced note"}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	all, err := s.All(ctx)
+	if err != nil {
+		t.Fatalf("All failed: %v", err)
+	}
+	if len(all) != 1 || all[0].Payload["text"] != "namespaced note" {
+		t.Errorf("expected only the namespaced memory, got %v", all)
+	}
+
+	namespaces, err := s.Namespaces(ctx)
+	if err != nil {
+		t.Fatalf("Namespaces failed: %v", err)
+	}
+	counts := make(map[string]uint64)
+	for _, ns := range namespaces {
+		counts[ns.Name] = ns.Count
+	}
+	if counts[""] != 1 || counts["test-ns"] != 1 {
+		t.Errorf("expected one memory in each namespace, got %v", namespaces)
+	}
+}
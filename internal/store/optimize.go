@@ -0,0 +1,115 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// IndexStatus is how far Qdrant has got with indexing the collection. Right
+// after a large import, vectors sit in segments without an HNSW index, and
+// searches scan them until the optimizer catches up: correct, but slow.
+type IndexStatus struct {
+	Exists bool `json:"exists"`
+	// Status is Qdrant's collection status: green when optimized, yellow
+	// while optimizing, grey when optimizations wait to be triggered and
+	// red when the optimizer failed.
+	Status string `json:"status,omitempty"`
+	// OptimizerError is why the optimizer failed, when it did.
+	OptimizerError string `json:"optimizer_error,omitempty"`
+	Points         uint64 `json:"points"`
+	IndexedVectors uint64 `json:"indexed_vectors"`
+	Segments       uint64 `json:"segments,omitempty"`
+	// IndexingThresholdKB is the segment size below which Qdrant doesn't
+	// build an index at all, since scanning a small segment is fast.
+	IndexingThresholdKB uint64 `json:"indexing_threshold_kb,omitempty"`
+}
+
+// Optimizing reports whether the optimizer has work in progress or pending.
+func (st IndexStatus) Optimizing() bool {
+	return st.Status == "yellow" || st.Status == "grey"
+}
+
+// Failed reports whether the optimizer stopped on an error.
+func (st IndexStatus) Failed() bool {
+	return st.Status == "red" || st.OptimizerError != ""
+}
+
+// IndexStatus returns the collection's indexing status, with Exists false
+// if there is no collection yet. The file and SQLite backends scan every
+// point on each search, so they always report everything indexed.
+func (s *Store) IndexStatus(ctx context.Context) (IndexStatus, error) {
+	exists, err := s.client.CollectionExists(ctx, s.collection)
+	if err != nil {
+		return IndexStatus{}, fmt.Errorf("check collection: %w", err)
+	}
+	if !exists {
+		return IndexStatus{}, nil
+	}
+	info, err := s.client.GetCollectionInfo(ctx, s.collection)
+	if err != nil {
+		return IndexStatus{}, fmt.Errorf("collection info: %w", err)
+	}
+	st := IndexStatus{
+		Exists:              true,
+		Status:              strings.ToLower(info.GetStatus().String()),
+		Points:              info.GetPointsCount(),
+		IndexedVectors:      info.GetIndexedVectorsCount(),
+		Segments:            info.GetSegmentsCount(),
+		IndexingThresholdKB: info.GetConfig().GetOptimizerConfig().GetIndexingThreshold(),
+	}
+	if o := info.GetOptimizerStatus(); o != nil && !o.GetOk() {
+		st.OptimizerError = o.GetError()
+	}
+	return st, nil
+}
+
+// Optimize asks Qdrant to run the optimizers on the collection, which builds
+// the indexes of segments that lack them. It returns without waiting; use
+// WaitOptimized for that. An update with an empty optimizer config is how
+// Qdrant is told to resume optimizations it left pending (grey).
+func (s *Store) Optimize(ctx context.Context) error {
+	exists, err := s.client.CollectionExists(ctx, s.collection)
+	if err != nil {
+		return fmt.Errorf("check collection: %w", err)
+	}
+	if !exists {
+		return nil
+	}
+	err = s.client.UpdateCollection(ctx, &qdrant.UpdateCollection{
+		CollectionName:   s.collection,
+		OptimizersConfig: &qdrant.OptimizersConfigDiff{},
+	})
+	if err != nil {
+		return fmt.Errorf("trigger optimizers: %w", err)
+	}
+	return nil
+}
+
+// WaitOptimized polls the indexing status every interval until the
+// optimizer is done, and returns the final status. It returns an error if
+// the optimizer fails or ctx ends first.
+func (s *Store) WaitOptimized(ctx context.Context, interval time.Duration) (IndexStatus, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		st, err := s.IndexStatus(ctx)
+		if err != nil {
+			return st, err
+		}
+		if st.Failed() {
+			return st, fmt.Errorf("optimizer failed: %s", st.OptimizerError)
+		}
+		if !st.Optimizing() {
+			return st, nil
+		}
+		select {
+		case <-ctx.Done():
+			return st, fmt.Errorf("collection is still optimizing: %w", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
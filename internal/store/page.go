@@ -0,0 +1,31 @@
+package store
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// cursorPrefix versions the cursor format, so a cursor from a later
+// release isn't misread as an offset.
+const cursorPrefix = "o1:"
+
+// EncodeCursor returns an opaque cursor for the page of results starting at
+// offset. Callers hand it back to resume where the previous page ended.
+func EncodeCursor(offset uint64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursorPrefix + strconv.FormatUint(offset, 10)))
+}
+
+// DecodeCursor returns the offset a cursor from EncodeCursor points at.
+func DecodeCursor(cursor string) (uint64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(raw), cursorPrefix) {
+		return 0, fmt.Errorf("invalid cursor %q", cursor)
+	}
+	offset, err := strconv.ParseUint(strings.TrimPrefix(string(raw), cursorPrefix), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid cursor %q", cursor)
+	}
+	return offset, nil
+}
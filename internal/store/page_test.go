@@ -0,0 +1,20 @@
+package store
+
+import "testing"
+
+func TestCursorRoundTrip(t *testing.T) {
+	for _, offset := range []uint64{0, 5, 1 << 40} {
+		got, err := DecodeCursor(EncodeCursor(offset))
+		if err != nil || got != offset {
+			t.Errorf("round trip of %d gave %d, %v", offset, got, err)
+		}
+	}
+}
+
+func TestDecodeCursorRejects(t *testing.T) {
+	for _, cursor := range []string{"", "5", "!!!", EncodeCursor(3) + "x", "bzE6LTE"} { // the last is "o1:-1"
+		if _, err := DecodeCursor(cursor); err == nil {
+			t.Errorf("expected %q to be rejected", cursor)
+		}
+	}
+}
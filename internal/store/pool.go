@@ -0,0 +1,167 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/hsk-coder/clawbrain/internal/reqid"
+	"github.com/qdrant/go-client/qdrant"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// DefaultConnections is how many gRPC connections a Store spreads its calls
+// over when PoolOptions doesn't say; it is the Qdrant client's own default.
+const DefaultConnections = 3
+
+// PoolOptions configures the Qdrant connections behind a Store. The zero
+// value gives DefaultConnections and no limit on concurrent calls.
+type PoolOptions struct {
+	// Connections is how many gRPC connections calls are spread across,
+	// round-robin. One slow call then only queues behind it the streams of
+	// its own connection instead of every call in the process.
+	Connections uint
+	// MaxConcurrent caps the calls in flight across all connections. A call
+	// beyond it waits for a slot, or fails with its context's error if that
+	// ends first. 0 means no cap.
+	MaxConcurrent int
+}
+
+// PoolStats describes how a Store's calls to Qdrant have fared since it was
+// opened.
+type PoolStats struct {
+	Connections   uint    `json:"connections"`
+	MaxConcurrent int     `json:"max_concurrent"` // 0 when uncapped
+	InFlight      int64   `json:"in_flight"`      // calls running now
+	PeakInFlight  int64   `json:"peak_in_flight"` // most calls ever running at once
+	Calls         int64   `json:"calls"`          // calls finished
+	Failed        int64   `json:"failed"`         // calls that returned an error
+	Waited        int64   `json:"waited"`         // calls that had to wait for a slot
+	Rejected      int64   `json:"rejected"`       // calls whose context ended while waiting
+	WaitMillis    int64   `json:"wait_ms"`        // total time spent waiting for slots
+	CallMillis    int64   `json:"call_ms"`        // total time spent in finished calls
+	AvgCallMillis float64 `json:"avg_call_ms"`    // CallMillis / Calls
+}
+
+// pool checks a slot out for every call to Qdrant and counts how the calls
+// went. It sits in front of the client as a gRPC interceptor, so every
+// Store method is covered without knowing about it.
+type pool struct {
+	connections uint
+	slots       chan struct{} // nil when uncapped
+
+	inFlight, peak       atomic.Int64
+	calls, failed        atomic.Int64
+	waited, rejected     atomic.Int64
+	waitNanos, callNanos atomic.Int64
+}
+
+func newPool(opts PoolOptions) *pool {
+	p := &pool{connections: opts.Connections}
+	if p.connections == 0 {
+		p.connections = DefaultConnections
+	}
+	if opts.MaxConcurrent > 0 {
+		p.slots = make(chan struct{}, opts.MaxConcurrent)
+	}
+	return p
+}
+
+// checkout takes a slot, waiting for one if the pool is full.
+func (p *pool) checkout(ctx context.Context) error {
+	if p.slots == nil {
+		return nil
+	}
+	select {
+	case p.slots <- struct{}{}:
+		return nil
+	default:
+	}
+	p.waited.Add(1)
+	start := time.Now()
+	defer func() { p.waitNanos.Add(int64(time.Since(start))) }()
+	select {
+	case p.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		p.rejected.Add(1)
+		return ctx.Err()
+	}
+}
+
+func (p *pool) release() {
+	if p.slots != nil {
+		<-p.slots
+	}
+}
+
+// intercept is the unary interceptor wrapping every call. It also sends
+// the call's request ID (see package reqid) in its metadata.
+func (p *pool) intercept(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	if err := p.checkout(ctx); err != nil {
+		return fmt.Errorf("wait for a qdrant connection: %w", err)
+	}
+	defer p.release()
+
+	n := p.inFlight.Add(1)
+	for {
+		peak := p.peak.Load()
+		if n <= peak || p.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	if id := reqid.From(ctx); id != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, reqid.MetadataKey, id)
+	}
+	start := time.Now()
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	p.callNanos.Add(int64(time.Since(start)))
+	p.inFlight.Add(-1)
+	p.calls.Add(1)
+	if err != nil {
+		p.failed.Add(1)
+	}
+	return err
+}
+
+func (p *pool) stats() PoolStats {
+	st := PoolStats{
+		Connections:   p.connections,
+		MaxConcurrent: cap(p.slots),
+		InFlight:      p.inFlight.Load(),
+		PeakInFlight:  p.peak.Load(),
+		Calls:         p.calls.Load(),
+		Failed:        p.failed.Load(),
+		Waited:        p.waited.Load(),
+		Rejected:      p.rejected.Load(),
+		WaitMillis:    time.Duration(p.waitNanos.Load()).Milliseconds(),
+		CallMillis:    time.Duration(p.callNanos.Load()).Milliseconds(),
+	}
+	if st.Calls > 0 {
+		st.AvgCallMillis = float64(p.callNanos.Load()) / float64(st.Calls) / float64(time.Millisecond)
+	}
+	return st
+}
+
+// NewWithPool creates a Store connected to Qdrant over a pool of
+// connections, for long-running processes serving many callers at once.
+func NewWithPool(host string, port int, opts PoolOptions) (*Store, error) {
+	p := newPool(opts)
+	client, err := qdrant.NewClient(&qdrant.Config{
+		Host:        host,
+		Port:        port,
+		PoolSize:    p.connections,
+		GrpcOptions: []grpc.DialOption{grpc.WithChainUnaryInterceptor(p.intercept)},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("connect to qdrant: %w", err)
+	}
+	return &Store{client: client, collection: DefaultCollection, pool: p}, nil
+}
+
+// PoolStats reports how the Store's calls to Qdrant have fared so far.
+func (s *Store) PoolStats() PoolStats {
+	return s.pool.stats()
+}
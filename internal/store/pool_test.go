@@ -0,0 +1,82 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+func TestPoolCapsConcurrency(t *testing.T) {
+	p := newPool(PoolOptions{MaxConcurrent: 2})
+
+	release := make(chan struct{})
+	started := make(chan struct{}, 4)
+	invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.intercept(context.Background(), "/qdrant.Points/Query", nil, nil, nil, invoker)
+		}()
+	}
+	<-started
+	<-started
+	select {
+	case <-started:
+		t.Fatal("expected a third call to wait for a slot")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if st := p.stats(); st.InFlight != 2 {
+		t.Errorf("expected 2 calls in flight, got %d", st.InFlight)
+	}
+
+	close(release)
+	wg.Wait()
+	st := p.stats()
+	if st.Calls != 4 || st.PeakInFlight != 2 || st.Waited != 2 || st.InFlight != 0 {
+		t.Errorf("unexpected stats %+v", st)
+	}
+	if st.Connections != DefaultConnections || st.MaxConcurrent != 2 {
+		t.Errorf("unexpected pool size %+v", st)
+	}
+}
+
+func TestPoolRejectsWhenContextEnds(t *testing.T) {
+	p := newPool(PoolOptions{MaxConcurrent: 1})
+	p.slots <- struct{}{} // the only slot is taken
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		t.Fatal("expected the call not to run")
+		return nil
+	}
+	err := p.intercept(ctx, "/qdrant.Points/Query", nil, nil, nil, invoker)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the context's error, got %v", err)
+	}
+	if st := p.stats(); st.Rejected != 1 || st.Calls != 0 {
+		t.Errorf("unexpected stats %+v", st)
+	}
+}
+
+func TestPoolCountsFailures(t *testing.T) {
+	p := newPool(PoolOptions{})
+	invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return errors.New("unavailable")
+	}
+	p.intercept(context.Background(), "/qdrant.Points/Query", nil, nil, nil, invoker)
+	if st := p.stats(); st.Calls != 1 || st.Failed != 1 || st.MaxConcurrent != 0 {
+		t.Errorf("unexpected stats %+v", st)
+	}
+}
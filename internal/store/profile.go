@@ -0,0 +1,79 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// rankingProfilesKey is the collection metadata key holding the ranking
+// profiles, as one JSON object: the collection's own under "" and one per
+// agent. The store keeps them opaque; package ranking gives them meaning.
+const rankingProfilesKey = "ranking_profiles"
+
+// RankingProfiles returns the ranking profiles stored with the collection,
+// keyed by agent with "" for the collection's own. It returns nil if there
+// are none or there is no collection.
+func (s *Store) RankingProfiles(ctx context.Context) (map[string]json.RawMessage, error) {
+	exists, err := s.client.CollectionExists(ctx, s.collection)
+	if err != nil {
+		return nil, fmt.Errorf("check collection: %w", err)
+	}
+	if !exists {
+		return nil, nil
+	}
+	info, err := s.client.GetCollectionInfo(ctx, s.collection)
+	if err != nil {
+		return nil, fmt.Errorf("collection info: %w", err)
+	}
+	raw := info.GetConfig().GetMetadata()[rankingProfilesKey].GetStringValue()
+	if raw == "" {
+		return nil, nil
+	}
+	var profiles map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(raw), &profiles); err != nil {
+		return nil, fmt.Errorf("read ranking profiles: %w", err)
+	}
+	return profiles, nil
+}
+
+// SetRankingProfile stores profile for agent ("" for the whole
+// collection), replacing any it had; a nil profile removes it. The
+// collection must exist. Searches answered before are stale afterwards, so
+// it counts as a change of the collection's Version.
+func (s *Store) SetRankingProfile(ctx context.Context, agent string, profile json.RawMessage) error {
+	profiles, err := s.RankingProfiles(ctx)
+	if err != nil {
+		return err
+	}
+	exists, err := s.client.CollectionExists(ctx, s.collection)
+	if err != nil {
+		return fmt.Errorf("check collection: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("collection %s doesn't exist yet: add a memory first", s.collection)
+	}
+	if profiles == nil {
+		profiles = map[string]json.RawMessage{}
+	}
+	if profile == nil {
+		delete(profiles, agent)
+	} else {
+		profiles[agent] = profile
+	}
+	data, err := json.Marshal(profiles)
+	if err != nil {
+		return err
+	}
+	err = s.client.UpdateCollection(ctx, &qdrant.UpdateCollection{
+		CollectionName: s.collection,
+		Metadata:       qdrant.NewValueMap(map[string]any{rankingProfilesKey: string(data)}),
+	})
+	if err != nil {
+		return fmt.Errorf("store ranking profile: %w", err)
+	}
+	s.changed(ctx)
+	return nil
+}
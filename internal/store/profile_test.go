@@ -0,0 +1,42 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRankingProfiles(t *testing.T) {
+	s, _ := fileStore(t)
+	defer s.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := s.SetRankingProfile(ctx, "", []byte(`{"pinned_bonus":0.1}`)); err == nil {
+		t.Fatal("expected setting a profile without a collection to fail")
+	}
+	if _, err := s.Add(ctx, "", []float32{1, 0, 0, 0}, map[string]any{"text": "seed"}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	before, _ := s.Version(ctx)
+	if err := s.SetRankingProfile(ctx, "", []byte(`{"pinned_bonus":0.1}`)); err != nil {
+		t.Fatalf("SetRankingProfile failed: %v", err)
+	}
+	if err := s.SetRankingProfile(ctx, "alice", []byte(`{"frequency_weight":0.2}`)); err != nil {
+		t.Fatalf("SetRankingProfile failed: %v", err)
+	}
+	if after, _ := s.Version(ctx); after == before {
+		t.Error("expected a profile change to change the collection version")
+	}
+	if err := s.SetRankingProfile(ctx, "", nil); err != nil {
+		t.Fatalf("SetRankingProfile failed: %v", err)
+	}
+
+	profiles, err := s.RankingProfiles(ctx)
+	if err != nil {
+		t.Fatalf("RankingProfiles failed: %v", err)
+	}
+	if len(profiles) != 1 || string(profiles["alice"]) != `{"frequency_weight":0.2}` {
+		t.Errorf("expected only alice's profile left, got %s", profiles)
+	}
+}
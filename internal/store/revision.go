@@ -0,0 +1,223 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/qdrant/go-client/qdrant"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// RevisionField is the payload field counting how many times a memory has
+// been rewritten in place. New memories start at 1; memories stored before
+// revisions were tracked have none and count as revision 0.
+const RevisionField = "revision"
+
+// Revision returns the memory's revision from its payload.
+func Revision(payload map[string]any) int64 {
+	switch v := payload[RevisionField].(type) {
+	case int64:
+		return v
+	case float64:
+		return int64(v)
+	}
+	return 0
+}
+
+// Precondition is what a writer expects of a memory it read earlier. A write
+// with a precondition fails with a *ConflictError instead of clobbering a
+// memory someone else changed in the meantime. The zero Precondition always
+// holds.
+type Precondition struct {
+	Revision           *int64    // the memory is still at this revision
+	LastAccessedBefore time.Time // nobody has touched the memory since then
+}
+
+// Empty reports whether the precondition checks nothing.
+func (p Precondition) Empty() bool {
+	return p.Revision == nil && p.LastAccessedBefore.IsZero()
+}
+
+// ConflictError reports a write refused because its precondition failed.
+// It carries the memory's current state so the caller can re-read and retry.
+type ConflictError struct {
+	ID           string `json:"id"`
+	Reason       string `json:"reason"`
+	Revision     int64  `json:"revision"`
+	LastAccessed string `json:"last_accessed,omitempty"`
+	Missing      bool   `json:"missing,omitempty"`
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("memory %s changed since it was read: %s", e.ID, e.Reason)
+}
+
+// Check returns a *ConflictError if the memory with payload doesn't satisfy
+// the precondition. A nil payload means the memory no longer exists.
+func (p Precondition) Check(id string, payload map[string]any) error {
+	if p.Empty() {
+		return nil
+	}
+	if payload == nil {
+		return &ConflictError{ID: id, Reason: "memory no longer exists", Missing: true}
+	}
+	conflict := func(reason string) error {
+		last, _ := payload["last_accessed"].(string)
+		return &ConflictError{ID: id, Reason: reason, Revision: Revision(payload), LastAccessed: last}
+	}
+	if p.Revision != nil && Revision(payload) != *p.Revision {
+		return conflict(fmt.Sprintf("revision is %d, expected %d", Revision(payload), *p.Revision))
+	}
+	if !p.LastAccessedBefore.IsZero() {
+		last, _ := payload["last_accessed"].(string)
+		t, err := time.Parse(time.RFC3339Nano, last)
+		if err == nil && !t.Before(p.LastAccessedBefore) {
+			return conflict(fmt.Sprintf("last accessed at %s", last))
+		}
+	}
+	return nil
+}
+
+// conditions translates the precondition into Qdrant conditions, so the
+// write itself only applies while the precondition still holds.
+func (p Precondition) conditions() []*qdrant.Condition {
+	var conds []*qdrant.Condition
+	if p.Revision != nil {
+		if *p.Revision == 0 {
+			conds = append(conds, qdrant.NewIsEmpty(RevisionField))
+		} else {
+			conds = append(conds, qdrant.NewMatchInt(RevisionField, *p.Revision))
+		}
+	}
+	if !p.LastAccessedBefore.IsZero() {
+		conds = append(conds, qdrant.NewDatetimeRange("last_accessed", &qdrant.DatetimeRange{
+			Lt: timestamppb.New(p.LastAccessedBefore),
+		}))
+	}
+	return conds
+}
+
+// Update rewrites an existing memory in place and bumps its revision. Like
+// Add, it refreshes last_accessed; created_at and access_count are kept
+// unless the payload sets its own. The precondition is checked when the
+// memory is read and again by Qdrant as part of the write, so a concurrent
+// change between the two is caught too. Returns a *ConflictError if the
+// precondition fails, or if the memory doesn't exist and a precondition was
+// given.
+func (s *Store) Update(ctx context.Context, id string, vector []float32, payload map[string]any, pre Precondition) error {
+	existing, err := s.Peek(ctx, id)
+	if err != nil {
+		return err
+	}
+	var current map[string]any
+	if existing != nil {
+		current = existing.Payload
+	}
+	if err := pre.Check(id, current); err != nil {
+		return err
+	}
+	if existing == nil {
+		return fmt.Errorf("memory %s not found", id)
+	}
+	if _, ok := payload["created_at"]; !ok && current["created_at"] != nil {
+		payload["created_at"] = current["created_at"]
+	}
+	if _, ok := payload[AccessCountField]; !ok && current[AccessCountField] != nil {
+		payload[AccessCountField] = current[AccessCountField]
+	}
+	revision := Revision(current) + 1
+	payload[RevisionField] = revision
+
+	if err := s.ensureCollection(ctx, uint64(len(vector))); err != nil {
+		return err
+	}
+	s.stamp(payload)
+
+	// Only overwrite the point while it still satisfies the precondition.
+	// update_only keeps a point deleted in the meantime from coming back.
+	cond := append([]*qdrant.Condition{qdrant.NewHasID(qdrant.NewIDUUID(id))}, pre.conditions()...)
+	mode := qdrant.UpdateMode_UpdateOnly
+	wait := true
+	_, err = s.client.Upsert(ctx, &qdrant.UpsertPoints{
+		CollectionName: s.collection,
+		Wait:           &wait,
+		Points: []*qdrant.PointStruct{{
+			Id:      qdrant.NewIDUUID(id),
+			Vectors: qdrant.NewVectors(vector...),
+			Payload: qdrant.NewValueMap(payload),
+		}},
+		UpdateFilter: s.scoped(&qdrant.Filter{Must: cond}),
+		UpdateMode:   &mode,
+	})
+	if err != nil {
+		return fmt.Errorf("upsert: %w", err)
+	}
+	s.changed(ctx)
+
+	// The filtered upsert silently skips a point that no longer matches;
+	// read it back to tell whether this write landed. A rival writer may
+	// have reached the same revision, so the nanosecond last_accessed
+	// stamped above has to match as well. A read landing in between is
+	// reported as a conflict, which is safe: the caller re-reads and retries.
+	after, err := s.Peek(ctx, id)
+	if err != nil {
+		return err
+	}
+	if after == nil || Revision(after.Payload) != revision || after.Payload["last_accessed"] != payload["last_accessed"] {
+		var now map[string]any
+		if after != nil {
+			now = after.Payload
+		}
+		if err := pre.Check(id, now); err != nil {
+			return err
+		}
+		return &ConflictError{ID: id, Reason: "changed concurrently", Revision: Revision(now), Missing: after == nil}
+	}
+	return nil
+}
+
+// DeleteIf removes a memory only while it satisfies the precondition. Like
+// Update, the precondition is also part of the delete request itself.
+// Returns a *ConflictError if it fails; a memory that doesn't exist is a
+// conflict too when a precondition is given, and a no-op otherwise.
+func (s *Store) DeleteIf(ctx context.Context, id string, pre Precondition) error {
+	if pre.Empty() {
+		return s.Delete(ctx, id)
+	}
+	existing, err := s.Peek(ctx, id)
+	if err != nil {
+		return err
+	}
+	var current map[string]any
+	if existing != nil {
+		current = existing.Payload
+	}
+	if err := pre.Check(id, current); err != nil {
+		return err
+	}
+
+	cond := append([]*qdrant.Condition{qdrant.NewHasID(qdrant.NewIDUUID(id))}, pre.conditions()...)
+	wait := true
+	_, err = s.client.Delete(ctx, &qdrant.DeletePoints{
+		CollectionName: s.collection,
+		Wait:           &wait,
+		Points:         qdrant.NewPointsSelectorFilter(s.scoped(&qdrant.Filter{Must: cond})),
+	})
+	if err != nil {
+		return fmt.Errorf("delete point: %w", err)
+	}
+	s.changed(ctx)
+
+	after, err := s.Peek(ctx, id)
+	if err != nil {
+		return err
+	}
+	if after != nil {
+		if err := pre.Check(id, after.Payload); err != nil {
+			return err
+		}
+		return &ConflictError{ID: id, Reason: "changed concurrently", Revision: Revision(after.Payload)}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPreconditionCheck(t *testing.T) {
+	rev := func(n int64) *int64 { return &n }
+	read := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	payload := map[string]any{RevisionField: int64(3), "last_accessed": "2026-10-01T11:00:00Z"}
+
+	if err := (Precondition{}).Check("m", nil); err != nil {
+		t.Errorf("empty precondition should always hold, got %v", err)
+	}
+	if err := (Precondition{Revision: rev(3), LastAccessedBefore: read}).Check("m", payload); err != nil {
+		t.Errorf("expected precondition to hold, got %v", err)
+	}
+
+	var conflict *ConflictError
+	err := Precondition{Revision: rev(2)}.Check("m", payload)
+	if !errors.As(err, &conflict) || conflict.Revision != 3 {
+		t.Errorf("expected a conflict at revision 3, got %v", err)
+	}
+	err = Precondition{LastAccessedBefore: read.Add(-2 * time.Hour)}.Check("m", payload)
+	if !errors.As(err, &conflict) || conflict.LastAccessed != "2026-10-01T11:00:00Z" {
+		t.Errorf("expected a last-accessed conflict, got %v", err)
+	}
+	err = Precondition{Revision: rev(3)}.Check("m", nil)
+	if !errors.As(err, &conflict) || !conflict.Missing {
+		t.Errorf("expected a missing-memory conflict, got %v", err)
+	}
+
+	// Memories stored before revisions were tracked are at revision 0.
+	if err := (Precondition{Revision: rev(0)}).Check("m", map[string]any{"text": "old"}); err != nil {
+		t.Errorf("expected legacy memory to be at revision 0, got %v", err)
+	}
+}
+
+func TestUpdateRevisions(t *testing.T) {
+	s := testStore(t)
+	defer s.Close()
+	defer cleanupMemories(t, s)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	vector := []float32{0.1, 0.2, 0.3, 0.4}
+	id, err := s.Add(ctx, "", vector, map[string]any{"text": "deploys go out on tuesdays"})
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	original, _ := s.Peek(ctx, id)
+
+	one := int64(1)
+	if err := s.Update(ctx, id, vector, map[string]any{"text": "deploys go out on wednesdays"}, Precondition{Revision: &one}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	got, _ := s.Peek(ctx, id)
+	if Revision(got.Payload) != 2 || got.Payload["created_at"] != original.Payload["created_at"] {
+		t.Errorf("expected revision 2 with created_at kept, got %v", got.Payload)
+	}
+
+	// A second writer that read revision 1 loses.
+	var conflict *ConflictError
+	err = s.Update(ctx, id, vector, map[string]any{"text": "deploys go out on fridays"}, Precondition{Revision: &one})
+	if !errors.As(err, &conflict) || conflict.Revision != 2 {
+		t.Fatalf("expected a conflict at revision 2, got %v", err)
+	}
+	if err := s.DeleteIf(ctx, id, Precondition{Revision: &one}); !errors.As(err, &conflict) {
+		t.Fatalf("expected DeleteIf to conflict, got %v", err)
+	}
+	if got, _ := s.Peek(ctx, id); got == nil || got.Payload["text"] != "deploys go out on wednesdays" {
+		t.Errorf("expected the memory to be untouched, got %v", got)
+	}
+
+	two := int64(2)
+	if err := s.DeleteIf(ctx, id, Precondition{Revision: &two}); err != nil {
+		t.Fatalf("DeleteIf failed: %v", err)
+	}
+	if got, _ := s.Peek(ctx, id); got != nil {
+		t.Errorf("expected the memory to be deleted, got %v", got)
+	}
+}
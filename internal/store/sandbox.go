@@ -0,0 +1,217 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// sandboxInfix joins a collection's name and the name of one of its
+// sandboxes. Like ColdSuffix it holds a dot, which namespace names can't,
+// so a sandbox is never taken for a namespace.
+const sandboxInfix = ".sandbox-"
+
+// sandboxName allows the same characters as namespace names.
+var sandboxName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+// ValidateSandbox checks that name is usable as a sandbox name.
+func ValidateSandbox(name string) error {
+	if len(name) > maxNamespaceLength {
+		return fmt.Errorf("sandbox %q is longer than %d characters", name, maxNamespaceLength)
+	}
+	if !sandboxName.MatchString(name) {
+		return fmt.Errorf("sandbox %q must start with a letter or digit and contain only letters, digits, '_' or '-'", name)
+	}
+	return nil
+}
+
+// Sandbox returns a Store for the sandbox called name of the store's
+// collection: a separate collection, filled by CopyTo, where destructive
+// commands can be tried out and their effect compared with Diff before
+// running them for real. It shares the store's connection and agent scope;
+// close the store, not the sandbox.
+func (s *Store) Sandbox(name string) *Store {
+	return &Store{
+		client:          s.client,
+		collection:      s.collection + sandboxInfix + name,
+		agent:           s.agent,
+		frequencyWeight: s.frequencyWeight,
+		clientVersion:   s.clientVersion,
+		model:           s.model,
+		pool:            s.pool,
+	}
+}
+
+// SetSandbox points the store at the sandbox called name of the collection
+// it is pointed at now, so every command that follows runs there. Call it
+// after SetNamespace.
+func (s *Store) SetSandbox(name string) {
+	s.collection += sandboxInfix + name
+}
+
+// SandboxInfo describes one sandbox.
+type SandboxInfo struct {
+	Name       string `json:"name"`
+	Collection string `json:"collection"`
+	Count      uint64 `json:"count"`
+}
+
+// Sandboxes lists the sandboxes of the store's collection with how many
+// memories each holds, by name.
+func (s *Store) Sandboxes(ctx context.Context) ([]SandboxInfo, error) {
+	names, err := s.client.ListCollections(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list collections: %w", err)
+	}
+	prefix := s.collection + sandboxInfix
+	var out []SandboxInfo
+	for _, collection := range names {
+		name, ok := strings.CutPrefix(collection, prefix)
+		if !ok || ValidateSandbox(name) != nil {
+			continue
+		}
+		exact := true
+		count, err := s.client.Count(ctx, &qdrant.CountPoints{
+			CollectionName: collection,
+			Filter:         s.scoped(nil),
+			Exact:          &exact,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("count %s: %w", collection, err)
+		}
+		out = append(out, SandboxInfo{Name: name, Collection: collection, Count: count})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+// CopyTo copies every memory of the store into dst, vectors and payloads
+// unchanged, importBatchSize at a time, and returns how many it copied.
+// Memories dst already holds under the same IDs are overwritten.
+func (s *Store) CopyTo(ctx context.Context, dst *Store) (int, error) {
+	copied := 0
+	var batch []Point
+	flush := func() error {
+		if err := dst.Import(ctx, batch); err != nil {
+			return fmt.Errorf("copy to %s: %w", dst.collection, err)
+		}
+		copied += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+	err := s.Export(ctx, Filter{}, func(p Point) error {
+		batch = append(batch, p)
+		if len(batch) == importBatchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return copied, err
+	}
+	if len(batch) > 0 {
+		err = flush()
+	}
+	return copied, err
+}
+
+// Change is a memory that differs between two collections: its text and
+// the payload fields whose values differ.
+type Change struct {
+	ID     string   `json:"id"`
+	Text   string   `json:"text"`
+	Fields []string `json:"fields"`
+}
+
+// StoreDiff is how a collection differs from the one it is compared with.
+type StoreDiff struct {
+	Removed []Result `json:"removed"`
+	Added   []Result `json:"added"`
+	Changed []Change `json:"changed"`
+}
+
+// diffIgnored are payload fields that change on every read or write, and
+// so would make every memory count as changed.
+var diffIgnored = map[string]bool{
+	"last_accessed": true,
+	"access_count":  true,
+	RevisionField:   true,
+}
+
+// Diff compares the store's memories with those of base: which are gone,
+// which are new and which changed, each sorted by ID. Fields that only
+// record reads or revisions are left out of the comparison.
+func (s *Store) Diff(ctx context.Context, base *Store) (StoreDiff, error) {
+	before, err := s.payloads(ctx, base)
+	if err != nil {
+		return StoreDiff{}, err
+	}
+	after, err := s.payloads(ctx, s)
+	if err != nil {
+		return StoreDiff{}, err
+	}
+	diff := StoreDiff{Removed: []Result{}, Added: []Result{}, Changed: []Change{}}
+	for _, id := range sortedKeys(before) {
+		payload, ok := after[id]
+		if !ok {
+			diff.Removed = append(diff.Removed, Result{ID: id, Payload: before[id]})
+			continue
+		}
+		if fields := changedPayloadFields(before[id], payload); len(fields) > 0 {
+			text, _ := payload["text"].(string)
+			diff.Changed = append(diff.Changed, Change{ID: id, Text: text, Fields: fields})
+		}
+	}
+	for _, id := range sortedKeys(after) {
+		if _, ok := before[id]; !ok {
+			diff.Added = append(diff.Added, Result{ID: id, Payload: after[id]})
+		}
+	}
+	return diff, nil
+}
+
+// payloads returns the payload of every memory of c, by ID.
+func (s *Store) payloads(ctx context.Context, c *Store) (map[string]map[string]any, error) {
+	all, err := c.All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]map[string]any, len(all))
+	for _, r := range all {
+		out[r.ID] = r.Payload
+	}
+	return out, nil
+}
+
+// changedPayloadFields returns the sorted fields set in either payload
+// whose values differ, other than those in diffIgnored.
+func changedPayloadFields(before, after map[string]any) []string {
+	keys := maps.Clone(before)
+	maps.Copy(keys, after)
+	var fields []string
+	for key := range keys {
+		if diffIgnored[key] {
+			continue
+		}
+		if !reflect.DeepEqual(before[key], after[key]) {
+			fields = append(fields, key)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func sortedKeys(m map[string]map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
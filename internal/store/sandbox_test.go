@@ -0,0 +1,75 @@
+package store
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSandboxCopyAndDiff(t *testing.T) {
+	s, _ := fileStore(t)
+	defer s.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var ids []string
+	for i, text := range []string{"deploys go out on tuesdays", "the office has a blue door", "the wifi password is on the fridge"} {
+		vector := []float32{0, 0, 0, 0}
+		vector[i] = 1
+		id, err := s.Add(ctx, "", vector, map[string]any{"text": text})
+		if err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+		ids = append(ids, id)
+	}
+
+	sandbox := s.Sandbox("what-if")
+	copied, err := s.CopyTo(ctx, sandbox)
+	if err != nil || copied != 3 {
+		t.Fatalf("expected 3 memories copied, got %d (%v)", copied, err)
+	}
+	if diff, err := sandbox.Diff(ctx, s); err != nil || len(diff.Removed)+len(diff.Added)+len(diff.Changed) != 0 {
+		t.Fatalf("expected a fresh copy to match, got %+v (%v)", diff, err)
+	}
+
+	// Reading in the sandbox touches last_accessed, which isn't a change.
+	if _, err := sandbox.Get(ctx, ids[2]); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if err := sandbox.DeleteIDs(ctx, []string{ids[0]}); err != nil {
+		t.Fatalf("DeleteIDs failed: %v", err)
+	}
+	if err := sandbox.SetPayloads(ctx, map[string]map[string]any{ids[1]: {"pinned": true}}); err != nil {
+		t.Fatalf("SetPayloads failed: %v", err)
+	}
+	added, err := sandbox.Add(ctx, "", []float32{0, 0, 0, 1}, map[string]any{"text": "a summary"})
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	diff, err := sandbox.Diff(ctx, s)
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].ID != ids[0] {
+		t.Errorf("expected the deleted memory removed, got %+v", diff.Removed)
+	}
+	if len(diff.Added) != 1 || diff.Added[0].ID != added {
+		t.Errorf("expected the new memory added, got %+v", diff.Added)
+	}
+	if len(diff.Changed) != 1 || diff.Changed[0].ID != ids[1] || !slices.Contains(diff.Changed[0].Fields, "pinned") {
+		t.Errorf("expected the pinned memory changed, got %+v", diff.Changed)
+	}
+	if n, err := s.Count(ctx); err != nil || n != 3 {
+		t.Errorf("expected the real collection untouched, got %d (%v)", n, err)
+	}
+
+	sandboxes, err := s.Sandboxes(ctx)
+	if err != nil || len(sandboxes) != 1 || sandboxes[0].Name != "what-if" || sandboxes[0].Count != 3 {
+		t.Errorf("expected the sandbox listed with 3 memories, got %+v (%v)", sandboxes, err)
+	}
+	if namespaces, err := s.Namespaces(ctx); err != nil || len(namespaces) != 1 {
+		t.Errorf("expected the sandbox not taken for a namespace, got %+v (%v)", namespaces, err)
+	}
+}
@@ -0,0 +1,197 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// Collection metadata keys recording which schema the stored memories
+// follow and which clawbrain last brought them up to it.
+const (
+	schemaVersionKey    = "schema_version"
+	clawbrainVersionKey = "clawbrain_version"
+)
+
+// Migration brings a collection from the previous schema version to
+// Version. Migrations only add (indexes, backfilled fields), so memories
+// stay readable by both the old and the new code while one runs, and
+// running one twice is harmless.
+type Migration struct {
+	Version     int    `json:"version"`
+	Description string `json:"description"`
+	apply       func(s *Store, ctx context.Context) error
+}
+
+// migrations lists every migration in order. Append to it, never edit or
+// reorder: stored collections remember how far along it they are.
+var migrations = []Migration{
+	{1, "index every field in the payload index list", (*Store).indexPayloadFields},
+	{2, "give memories stored before revisions were tracked revision 1", (*Store).backfillRevision},
+	{3, "index archived_at, for purging archives past their grace period", (*Store).indexPayloadFields},
+	{4, "index created_at and last_accessed, for searches within a time range", (*Store).indexPayloadFields},
+}
+
+// SchemaVersion is the schema this build writes: new collections are
+// created at it, and older ones reach it through Migrate.
+var SchemaVersion = migrations[len(migrations)-1].Version
+
+// Schema describes the schema of the store's collection.
+type Schema struct {
+	Exists    bool        `json:"exists"`
+	Version   int         `json:"schema_version"`              // 0 for collections made before versioning
+	Clawbrain string      `json:"clawbrain_version,omitempty"` // the build that created or last migrated it
+	Pending   []Migration `json:"pending"`                     // migrations still to apply, in order
+}
+
+// SetClientVersion sets the clawbrain version recorded in the collection
+// metadata when the store creates or migrates a collection.
+func (s *Store) SetClientVersion(v string) {
+	s.clientVersion = v
+}
+
+// Schema reads the collection's schema version. A missing collection
+// counts as current, since it will be created at SchemaVersion.
+func (s *Store) Schema(ctx context.Context) (Schema, error) {
+	exists, err := s.client.CollectionExists(ctx, s.collection)
+	if err != nil {
+		return Schema{}, fmt.Errorf("check collection: %w", err)
+	}
+	if !exists {
+		return Schema{Version: SchemaVersion, Pending: []Migration{}}, nil
+	}
+	info, err := s.client.GetCollectionInfo(ctx, s.collection)
+	if err != nil {
+		return Schema{}, fmt.Errorf("collection info: %w", err)
+	}
+	meta := info.GetConfig().GetMetadata()
+	sc := Schema{
+		Exists:    true,
+		Version:   int(meta[schemaVersionKey].GetIntegerValue()),
+		Clawbrain: meta[clawbrainVersionKey].GetStringValue(),
+		Pending:   []Migration{},
+	}
+	for _, m := range migrations {
+		if m.Version > sc.Version {
+			sc.Pending = append(sc.Pending, m)
+		}
+	}
+	return sc, nil
+}
+
+// SchemaError reports a collection written by a newer clawbrain, whose
+// schema this build doesn't know.
+type SchemaError struct {
+	Version   int
+	Clawbrain string
+}
+
+func (e *SchemaError) Error() string {
+	by := "a newer clawbrain"
+	if e.Clawbrain != "" {
+		by = "clawbrain " + e.Clawbrain
+	}
+	return fmt.Sprintf("collection is at schema version %d, written by %s; this build only knows up to %d, upgrade it", e.Version, by, SchemaVersion)
+}
+
+// CheckSchema returns the collection's schema, or a *SchemaError if it is
+// newer than this build understands. An older schema is not an error:
+// Pending lists what Migrate would apply.
+func (s *Store) CheckSchema(ctx context.Context) (Schema, error) {
+	sc, err := s.Schema(ctx)
+	if err != nil {
+		return Schema{}, err
+	}
+	if sc.Version > SchemaVersion {
+		return sc, &SchemaError{Version: sc.Version, Clawbrain: sc.Clawbrain}
+	}
+	return sc, nil
+}
+
+// Migrate applies the pending migrations in order, recording the version
+// after each one, so an interrupted run resumes where it stopped. It
+// returns the migrations applied. There is nothing to do without a
+// collection.
+func (s *Store) Migrate(ctx context.Context) ([]Migration, error) {
+	sc, err := s.CheckSchema(ctx)
+	if err != nil {
+		return nil, err
+	}
+	applied := []Migration{}
+	for _, m := range sc.Pending {
+		if err := m.apply(s, ctx); err != nil {
+			return applied, fmt.Errorf("migration %d (%s): %w", m.Version, m.Description, err)
+		}
+		if err := s.recordSchema(ctx, m.Version); err != nil {
+			return applied, err
+		}
+		applied = append(applied, m)
+	}
+	return applied, nil
+}
+
+// schemaMetadata is the metadata marking a collection as at version.
+func (s *Store) schemaMetadata(version int) map[string]*qdrant.Value {
+	meta := map[string]any{schemaVersionKey: int64(version)}
+	if s.clientVersion != "" {
+		meta[clawbrainVersionKey] = s.clientVersion
+	}
+	return qdrant.NewValueMap(meta)
+}
+
+func (s *Store) recordSchema(ctx context.Context, version int) error {
+	err := s.client.UpdateCollection(ctx, &qdrant.UpdateCollection{
+		CollectionName: s.collection,
+		Metadata:       s.schemaMetadata(version),
+	})
+	if err != nil {
+		return fmt.Errorf("record schema version %d: %w", version, err)
+	}
+	return nil
+}
+
+// indexPayloadFields creates whichever of payloadIndexes a collection made
+// before them is missing.
+func (s *Store) indexPayloadFields(ctx context.Context) error {
+	info, err := s.client.GetCollectionInfo(ctx, s.collection)
+	if err != nil {
+		return fmt.Errorf("collection info: %w", err)
+	}
+	schema := info.GetPayloadSchema()
+	wait := true
+	for _, idx := range payloadIndexes {
+		if _, ok := schema[idx.field]; ok {
+			continue
+		}
+		_, err := s.client.CreateFieldIndex(ctx, &qdrant.CreateFieldIndexCollection{
+			CollectionName: s.collection,
+			Wait:           &wait,
+			FieldName:      idx.field,
+			FieldType:      idx.kind.Enum(),
+		})
+		if err != nil {
+			return fmt.Errorf("create %s index: %w", idx.field, err)
+		}
+	}
+	return nil
+}
+
+// backfillRevision sets revision 1 on memories without one, so every
+// memory has a revision to pass to --if-version. It covers the whole
+// collection, whatever agent the store is scoped to.
+func (s *Store) backfillRevision(ctx context.Context) error {
+	wait := true
+	_, err := s.client.SetPayload(ctx, &qdrant.SetPayloadPoints{
+		CollectionName: s.collection,
+		Wait:           &wait,
+		Payload:        qdrant.NewValueMap(map[string]any{RevisionField: int64(1)}),
+		PointsSelector: qdrant.NewPointsSelectorFilter(&qdrant.Filter{
+			Must: []*qdrant.Condition{qdrant.NewIsEmpty(RevisionField)},
+		}),
+	})
+	if err != nil {
+		return fmt.Errorf("backfill %s: %w", RevisionField, err)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+func TestMigrationsOrdered(t *testing.T) {
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("migration %d has version %d, want %d", i, m.Version, i+1)
+		}
+		if m.apply == nil || m.Description == "" {
+			t.Errorf("migration %d is incomplete", m.Version)
+		}
+	}
+	if SchemaVersion != len(migrations) {
+		t.Errorf("SchemaVersion = %d, want %d", SchemaVersion, len(migrations))
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	s := testStore(t)
+	defer s.Close()
+	defer cleanupMemories(t, s)
+	s.SetClientVersion("test")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	id, err := s.Add(ctx, "", []float32{0.1, 0.2, 0.3, 0.4}, map[string]any{"text": "deploys go out on tuesdays"})
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	sc, err := s.CheckSchema(ctx)
+	if err != nil || sc.Version != SchemaVersion || len(sc.Pending) != 0 || sc.Clawbrain != "test" {
+		t.Fatalf("new collection should be current, got %+v, %v", sc, err)
+	}
+
+	// Pretend the collection and memory predate versioning.
+	if err := s.recordSchema(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+	wait := true
+	if _, err := s.client.DeletePayload(ctx, &qdrant.DeletePayloadPoints{
+		CollectionName: s.collection,
+		Wait:           &wait,
+		Keys:           []string{RevisionField},
+		PointsSelector: qdrant.NewPointsSelector(qdrant.NewIDUUID(id)),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := s.Migrate(ctx)
+	if err != nil || len(applied) != SchemaVersion {
+		t.Fatalf("Migrate applied %v, %v; want all %d", applied, err, SchemaVersion)
+	}
+	if sc, _ := s.Schema(ctx); sc.Version != SchemaVersion || len(sc.Pending) != 0 {
+		t.Errorf("after Migrate got %+v", sc)
+	}
+	got, err := s.Peek(ctx, id)
+	if err != nil || Revision(got.Payload) != 1 {
+		t.Errorf("expected revision backfilled to 1, got %v, %v", got, err)
+	}
+	if applied, err := s.Migrate(ctx); err != nil || len(applied) != 0 {
+		t.Errorf("second Migrate applied %v, %v", applied, err)
+	}
+
+	if err := s.recordSchema(ctx, SchemaVersion+1); err != nil {
+		t.Fatal(err)
+	}
+	var se *SchemaError
+	if _, err := s.CheckSchema(ctx); !errors.As(err, &se) {
+		t.Errorf("expected a SchemaError for a newer schema, got %v", err)
+	}
+}
@@ -0,0 +1,63 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// SensitivityField is the payload field holding a memory's privacy level.
+const SensitivityField = "sensitivity"
+
+// Privacy levels. A memory without a sensitivity field is internal.
+// Personal memories are about people rather than the work: they are forgotten
+// sooner, and hidden from retrieval in shared contexts unless asked for.
+const (
+	SensitivityPublic   = "public"
+	SensitivityInternal = "internal"
+	SensitivityPersonal = "personal"
+)
+
+// NormalizeSensitivity lowercases and validates the sensitivity field of a
+// payload in place. An empty value removes the field.
+func NormalizeSensitivity(payload map[string]any) error {
+	v, ok := payload[SensitivityField]
+	if !ok || v == nil {
+		return nil
+	}
+	level, isStr := v.(string)
+	if !isStr {
+		return fmt.Errorf("field %q must be a string, got %v", SensitivityField, v)
+	}
+	level = strings.ToLower(strings.TrimSpace(level))
+	switch level {
+	case "":
+		delete(payload, SensitivityField)
+	case SensitivityPublic, SensitivityInternal, SensitivityPersonal:
+		payload[SensitivityField] = level
+	default:
+		return fmt.Errorf("unknown sensitivity %q (want public, internal or personal)", level)
+	}
+	return nil
+}
+
+// IsPersonal reports whether the payload marks the memory as personal.
+func IsPersonal(payload map[string]any) bool {
+	level, _ := payload[SensitivityField].(string)
+	return level == SensitivityPersonal
+}
+
+// ForgetPersonal deletes personal memories not accessed within ttl. Like
+// Forget, it never deletes pinned or locked memories.
+func (s *Store) ForgetPersonal(ctx context.Context, ttl time.Duration) (int, error) {
+	return s.forget(ctx, ttl, qdrant.NewMatchKeyword(SensitivityField, SensitivityPersonal))
+}
+
+// StalePersonal returns the memories ForgetPersonal would delete with the
+// same TTL, without deleting anything.
+func (s *Store) StalePersonal(ctx context.Context, ttl time.Duration) ([]Result, error) {
+	return s.staleIfExists(ctx, ttl, qdrant.NewMatchKeyword(SensitivityField, SensitivityPersonal))
+}
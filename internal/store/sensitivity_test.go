@@ -0,0 +1,76 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestNormalizeSensitivity(t *testing.T) {
+	payload := map[string]any{"sensitivity": " Personal "}
+	if err := NormalizeSensitivity(payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload["sensitivity"] != "personal" || !IsPersonal(payload) {
+		t.Errorf("expected personal, got %v", payload["sensitivity"])
+	}
+
+	blank := map[string]any{"sensitivity": ""}
+	if err := NormalizeSensitivity(blank); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := blank["sensitivity"]; ok {
+		t.Error("expected blank sensitivity to be removed")
+	}
+
+	for _, bad := range []any{"secret", 2.0} {
+		if err := NormalizeSensitivity(map[string]any{"sensitivity": bad}); err == nil {
+			t.Errorf("expected error for %v", bad)
+		}
+	}
+	if IsPersonal(map[string]any{"text": "x"}) {
+		t.Error("expected unset sensitivity not to be personal")
+	}
+}
+
+func TestForgetPersonalAndExcludeFilter(t *testing.T) {
+	s := testStore(t)
+	defer s.Close()
+	defer cleanupMemories(t, s)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	vector := []float32{0.1, 0.2, 0.3, 0.4}
+	personal, err := s.Add(ctx, "", vector, map[string]any{"text": "lico's birthday is in may", "sensitivity": "personal"})
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	internal, err := s.Add(ctx, "", vector, map[string]any{"text": "deploys go out on tuesdays"})
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	results, err := s.FindSimilarFiltered(ctx, vector, 0, 10, Filter{ExcludePersonal: true})
+	if err != nil {
+		t.Fatalf("FindSimilarFiltered failed: %v", err)
+	}
+	if len(results) != 1 || results[0].ID != internal {
+		t.Errorf("expected only the internal memory, got %v", results)
+	}
+
+	time.Sleep(1100 * time.Millisecond)
+	deleted, err := s.ForgetPersonal(ctx, time.Second)
+	if err != nil {
+		t.Fatalf("ForgetPersonal failed: %v", err)
+	}
+	if deleted != 1 {
+		t.Fatalf("expected 1 deletion, got %d", deleted)
+	}
+	if r, _ := s.Peek(ctx, personal); r != nil {
+		t.Error("expected the personal memory to be forgotten")
+	}
+	if r, _ := s.Peek(ctx, internal); r == nil {
+		t.Error("expected the internal memory to survive")
+	}
+}
@@ -0,0 +1,25 @@
+package store
+
+import (
+	"fmt"
+	"math"
+)
+
+// Cosine returns the cosine similarity of two vectors: the score Qdrant
+// gives a match in the collection, and the one dedup compares against its
+// threshold. The vectors must be the same size and not all zeros.
+func Cosine(a, b []float32) (float32, error) {
+	if len(a) != len(b) {
+		return 0, fmt.Errorf("vectors have different sizes (%d and %d); were they made by different models?", len(a), len(b))
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0, fmt.Errorf("cosine similarity of a zero vector is undefined")
+	}
+	return float32(dot / math.Sqrt(normA*normB)), nil
+}
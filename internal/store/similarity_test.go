@@ -0,0 +1,34 @@
+package store
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCosine(t *testing.T) {
+	tests := []struct {
+		a, b []float32
+		want float32
+	}{
+		{[]float32{1, 0}, []float32{1, 0}, 1},
+		{[]float32{1, 0}, []float32{0, 1}, 0},
+		{[]float32{1, 0}, []float32{-1, 0}, -1},
+		{[]float32{1, 1}, []float32{2, 2}, 1}, // length doesn't matter
+		{[]float32{3, 4}, []float32{4, 3}, 0.96},
+	}
+	for _, tt := range tests {
+		got, err := Cosine(tt.a, tt.b)
+		if err != nil || math.Abs(float64(got-tt.want)) > 1e-6 {
+			t.Errorf("Cosine(%v, %v) = %v, %v; want %v", tt.a, tt.b, got, err, tt.want)
+		}
+	}
+}
+
+func TestCosineRejects(t *testing.T) {
+	if _, err := Cosine([]float32{1, 0}, []float32{1, 0, 0}); err == nil {
+		t.Error("expected vectors of different sizes to be refused")
+	}
+	if _, err := Cosine([]float32{0, 0}, []float32{1, 0}); err == nil {
+		t.Error("expected a zero vector to be refused")
+	}
+}
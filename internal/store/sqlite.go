@@ -0,0 +1,827 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	vec "github.com/asg017/sqlite-vec-go-bindings/ncruces"
+	_ "github.com/ncruces/go-sqlite3/driver"
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// SQLiteBackend is a Backend that keeps every collection in one SQLite
+// database file, with sqlite-vec to rank memories by cosine distance. SQLite
+// runs in-process (compiled to WebAssembly, so without cgo), and payloads
+// are stored as plain JSON so the file can be queried with any SQLite
+// client:
+//
+//	SELECT id, json_extract(payload, '$.text') FROM points WHERE collection = 'clawbrain'
+//
+// Like FileBackend, filters are evaluated in Go as Qdrant would; vector
+// search scans the collection, which suits up to tens of thousands of
+// memories. Writes are SQLite transactions, so several clawbrain processes
+// can share the file.
+type SQLiteBackend struct {
+	db *sql.DB
+}
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS collections (
+	name        TEXT PRIMARY KEY,
+	vector_size INTEGER NOT NULL,
+	metadata    TEXT NOT NULL DEFAULT '{}',
+	indexes     TEXT NOT NULL DEFAULT '{}'
+);
+CREATE TABLE IF NOT EXISTS points (
+	collection TEXT NOT NULL,
+	id         TEXT NOT NULL,
+	vector     BLOB NOT NULL,
+	payload    TEXT NOT NULL,
+	PRIMARY KEY (collection, id)
+) WITHOUT ROWID;
+`
+
+// NewSQLiteBackend opens the SQLite database at path, creating it and its
+// directory if needed. Only the owner can read a new database: memories can
+// be personal.
+func NewSQLiteBackend(path string) (*SQLiteBackend, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("create %s: %w", filepath.Dir(path), err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("create %s: %w", path, err)
+	}
+	f.Close()
+
+	// Immediate transactions take the write lock up front, so concurrent
+	// writers wait on busy_timeout instead of failing to upgrade a read.
+	dsn := (&url.URL{Scheme: "file", OmitHost: true, Path: path,
+		RawQuery: "_txlock=immediate&_pragma=busy_timeout(10000)&_pragma=journal_mode(wal)"}).String()
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+	return &SQLiteBackend{db: db}, nil
+}
+
+// NewSQLite creates a Store backed by the SQLite database at path instead
+// of Qdrant.
+func NewSQLite(path string) (*Store, error) {
+	b, err := NewSQLiteBackend(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{client: b, collection: DefaultCollection, pool: newPool(PoolOptions{})}, nil
+}
+
+// Close closes the database.
+func (b *SQLiteBackend) Close() error {
+	return b.db.Close()
+}
+
+// HealthCheck reports whether the database answers.
+func (b *SQLiteBackend) HealthCheck(ctx context.Context) (*qdrant.HealthCheckReply, error) {
+	var version string
+	if err := b.db.QueryRowContext(ctx, "SELECT vec_version()").Scan(&version); err != nil {
+		return nil, err
+	}
+	return &qdrant.HealthCheckReply{Title: "clawbrain sqlite backend", Version: version}, nil
+}
+
+// sqliteCollection is a collection's row.
+type sqliteCollection struct {
+	vectorSize uint64
+	metadata   map[string]*qdrant.Value
+	indexes    map[string]qdrant.PayloadSchemaType
+}
+
+// querier is what both *sql.DB and *sql.Tx offer.
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// collection reads a collection's row, or returns nil if it doesn't exist.
+func (b *SQLiteBackend) collection(ctx context.Context, q querier, name string) (*sqliteCollection, error) {
+	var metadata, indexes string
+	c := &sqliteCollection{}
+	err := q.QueryRowContext(ctx, "SELECT vector_size, metadata, indexes FROM collections WHERE name = ?", name).
+		Scan(&c.vectorSize, &metadata, &indexes)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if c.metadata, err = decodePayload(metadata); err != nil {
+		return nil, fmt.Errorf("collection %s metadata: %w", name, err)
+	}
+	var kinds map[string]string
+	if err := json.Unmarshal([]byte(indexes), &kinds); err != nil {
+		return nil, fmt.Errorf("collection %s indexes: %w", name, err)
+	}
+	c.indexes = make(map[string]qdrant.PayloadSchemaType, len(kinds))
+	for field, kind := range kinds {
+		c.indexes[field] = qdrant.PayloadSchemaType(qdrant.PayloadSchemaType_value[kind])
+	}
+	return c, nil
+}
+
+// mustExist is collection, failing for a collection that doesn't exist.
+func (b *SQLiteBackend) mustExist(ctx context.Context, q querier, name string) (*sqliteCollection, error) {
+	c, err := b.collection(ctx, q, name)
+	if err == nil && c == nil {
+		err = fmt.Errorf("collection %s doesn't exist", name)
+	}
+	return c, err
+}
+
+// update runs fn in a transaction on an existing collection, committing if
+// it succeeds.
+func (b *SQLiteBackend) update(ctx context.Context, name string, fn func(tx *sql.Tx, c *sqliteCollection) error) error {
+	tx, err := b.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	c, err := b.mustExist(ctx, tx, name)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx, c); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// CollectionExists reports whether the collection has a row.
+func (b *SQLiteBackend) CollectionExists(ctx context.Context, collectionName string) (bool, error) {
+	c, err := b.collection(ctx, b.db, collectionName)
+	return c != nil, err
+}
+
+// ListCollections lists the collections in the database.
+func (b *SQLiteBackend) ListCollections(ctx context.Context) ([]string, error) {
+	rows, err := b.db.QueryContext(ctx, "SELECT name FROM collections ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// CreateCollection creates an empty collection. Only the vector size and
+// metadata of the request matter; there is no index to configure.
+func (b *SQLiteBackend) CreateCollection(ctx context.Context, request *qdrant.CreateCollection) error {
+	metadata, err := encodePayload(request.GetMetadata())
+	if err != nil {
+		return err
+	}
+	_, err = b.db.ExecContext(ctx, "INSERT INTO collections (name, vector_size, metadata) VALUES (?, ?, ?)",
+		request.GetCollectionName(), request.GetVectorsConfig().GetParams().GetSize(), metadata)
+	if err != nil {
+		if exists, _ := b.CollectionExists(ctx, request.GetCollectionName()); exists {
+			return fmt.Errorf("collection %s already exists", request.GetCollectionName())
+		}
+		return err
+	}
+	return nil
+}
+
+// UpdateCollection merges the request's metadata into the collection's.
+// Other settings have no meaning here and are ignored.
+func (b *SQLiteBackend) UpdateCollection(ctx context.Context, request *qdrant.UpdateCollection) error {
+	return b.update(ctx, request.GetCollectionName(), func(tx *sql.Tx, c *sqliteCollection) error {
+		if c.metadata == nil {
+			c.metadata = map[string]*qdrant.Value{}
+		}
+		maps.Copy(c.metadata, request.GetMetadata())
+		metadata, err := encodePayload(c.metadata)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, "UPDATE collections SET metadata = ? WHERE name = ?", metadata, request.GetCollectionName())
+		return err
+	})
+}
+
+// DeleteCollection removes the collection and its points.
+func (b *SQLiteBackend) DeleteCollection(ctx context.Context, collectionName string) error {
+	tx, err := b.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, "DELETE FROM points WHERE collection = ?", collectionName); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM collections WHERE name = ?", collectionName); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetCollectionInfo describes the collection: vector size, metadata, the
+// fields indexed with CreateFieldIndex and the number of points. Searches
+// scan every point, so all of them count as indexed.
+func (b *SQLiteBackend) GetCollectionInfo(ctx context.Context, collectionName string) (*qdrant.CollectionInfo, error) {
+	c, err := b.mustExist(ctx, b.db, collectionName)
+	if err != nil {
+		return nil, err
+	}
+	var count uint64
+	if err := b.db.QueryRowContext(ctx, "SELECT count(*) FROM points WHERE collection = ?", collectionName).Scan(&count); err != nil {
+		return nil, err
+	}
+	schema := make(map[string]*qdrant.PayloadSchemaInfo, len(c.indexes))
+	for field, kind := range c.indexes {
+		schema[field] = &qdrant.PayloadSchemaInfo{DataType: kind}
+	}
+	return &qdrant.CollectionInfo{
+		Status: qdrant.CollectionStatus_Green,
+		Config: &qdrant.CollectionConfig{
+			Params: &qdrant.CollectionParams{
+				VectorsConfig: qdrant.NewVectorsConfig(&qdrant.VectorParams{Size: c.vectorSize, Distance: qdrant.Distance_Cosine}),
+			},
+			Metadata: c.metadata,
+		},
+		PayloadSchema:       schema,
+		PointsCount:         &count,
+		IndexedVectorsCount: &count,
+		OptimizerStatus:     &qdrant.OptimizerStatus{Ok: true},
+	}, nil
+}
+
+// CreateFieldIndex records the index in the collection's payload schema.
+// Filters are evaluated in Go, so nothing is built.
+func (b *SQLiteBackend) CreateFieldIndex(ctx context.Context, request *qdrant.CreateFieldIndexCollection) (*qdrant.UpdateResult, error) {
+	return completed(b.update(ctx, request.GetCollectionName(), func(tx *sql.Tx, c *sqliteCollection) error {
+		kinds := make(map[string]string, len(c.indexes)+1)
+		for field, kind := range c.indexes {
+			kinds[field] = kind.String()
+		}
+		kinds[request.GetFieldName()] = strings.TrimPrefix(request.GetFieldType().String(), "FieldType")
+		indexes, err := json.Marshal(kinds)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, "UPDATE collections SET indexes = ? WHERE name = ?", string(indexes), request.GetCollectionName())
+		return err
+	}))
+}
+
+// Upsert writes points, honoring the request's update filter and mode.
+func (b *SQLiteBackend) Upsert(ctx context.Context, request *qdrant.UpsertPoints) (*qdrant.UpdateResult, error) {
+	return completed(b.update(ctx, request.GetCollectionName(), func(tx *sql.Tx, c *sqliteCollection) error {
+		return sqliteUpsert(ctx, tx, request.GetCollectionName(), c, request.GetPoints(), request.GetUpdateFilter(), request.GetUpdateMode())
+	}))
+}
+
+func sqliteUpsert(ctx context.Context, tx *sql.Tx, collection string, c *sqliteCollection, points []*qdrant.PointStruct, filter *qdrant.Filter, mode qdrant.UpdateMode) error {
+	for _, p := range points {
+		id := pointIDToString(p.GetId())
+		vector := denseInput(p.GetVectors().GetVector())
+		if uint64(len(vector)) != c.vectorSize {
+			return fmt.Errorf("wrong vector dimension: expected %d, got %d", c.vectorSize, len(vector))
+		}
+		existing, err := sqlitePayload(ctx, tx, collection, id)
+		if err != nil {
+			return err
+		}
+		exists := existing != nil
+		switch {
+		case mode == qdrant.UpdateMode_InsertOnly && exists:
+			continue
+		case mode == qdrant.UpdateMode_UpdateOnly && !exists:
+			continue
+		case exists && filter != nil && !matchFilter(filter, id, valueMapToGoMap(existing)):
+			continue
+		}
+		blob, err := vec.SerializeFloat32(vector)
+		if err != nil {
+			return err
+		}
+		payload, err := encodePayload(p.GetPayload())
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `INSERT INTO points (collection, id, vector, payload) VALUES (?, ?, ?, ?)
+			ON CONFLICT (collection, id) DO UPDATE SET vector = excluded.vector, payload = excluded.payload`,
+			collection, id, blob, payload)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sqlitePayload reads a point's payload, or returns nil if the point
+// doesn't exist.
+func sqlitePayload(ctx context.Context, q querier, collection, id string) (map[string]*qdrant.Value, error) {
+	var raw string
+	err := q.QueryRowContext(ctx, "SELECT payload FROM points WHERE collection = ? AND id = ?", collection, id).Scan(&raw)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	payload, err := decodePayload(raw)
+	if err != nil {
+		return nil, fmt.Errorf("point %s: %w", id, err)
+	}
+	return payload, nil
+}
+
+// sqliteSelected returns the IDs of the points a selector picks, with
+// their payloads.
+func sqliteSelected(ctx context.Context, q querier, collection string, sel *qdrant.PointsSelector) (map[string]map[string]*qdrant.Value, error) {
+	out := map[string]map[string]*qdrant.Value{}
+	switch s := sel.GetPointsSelectorOneOf().(type) {
+	case *qdrant.PointsSelector_Points:
+		for _, pid := range s.Points.GetIds() {
+			id := pointIDToString(pid)
+			payload, err := sqlitePayload(ctx, q, collection, id)
+			if err != nil {
+				return nil, err
+			}
+			if payload != nil {
+				out[id] = payload
+			}
+		}
+	case *qdrant.PointsSelector_Filter:
+		err := sqliteScan(ctx, q, "SELECT id, payload FROM points WHERE collection = ?", []any{collection}, false,
+			func(id string, p *filePoint, _ float64) (bool, error) {
+				if matchFilter(s.Filter, id, valueMapToGoMap(p.payload)) {
+					out[id] = p.payload
+				}
+				return true, nil
+			})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// Delete removes the selected points.
+func (b *SQLiteBackend) Delete(ctx context.Context, request *qdrant.DeletePoints) (*qdrant.UpdateResult, error) {
+	return completed(b.update(ctx, request.GetCollectionName(), func(tx *sql.Tx, c *sqliteCollection) error {
+		return sqliteDelete(ctx, tx, request.GetCollectionName(), request.GetPoints())
+	}))
+}
+
+func sqliteDelete(ctx context.Context, tx *sql.Tx, collection string, sel *qdrant.PointsSelector) error {
+	selected, err := sqliteSelected(ctx, tx, collection, sel)
+	if err != nil {
+		return err
+	}
+	for id := range selected {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM points WHERE collection = ? AND id = ?", collection, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetPayload merges payload into the selected points' payloads.
+func (b *SQLiteBackend) SetPayload(ctx context.Context, request *qdrant.SetPayloadPoints) (*qdrant.UpdateResult, error) {
+	return completed(b.update(ctx, request.GetCollectionName(), func(tx *sql.Tx, c *sqliteCollection) error {
+		return sqliteEditPayloads(ctx, tx, request.GetCollectionName(), request.GetPointsSelector(), func(payload map[string]*qdrant.Value) {
+			maps.Copy(payload, request.GetPayload())
+		})
+	}))
+}
+
+// DeletePayload removes keys from the selected points' payloads.
+func (b *SQLiteBackend) DeletePayload(ctx context.Context, request *qdrant.DeletePayloadPoints) (*qdrant.UpdateResult, error) {
+	return completed(b.update(ctx, request.GetCollectionName(), func(tx *sql.Tx, c *sqliteCollection) error {
+		return sqliteEditPayloads(ctx, tx, request.GetCollectionName(), request.GetPointsSelector(), deleteKeys(request.GetKeys()))
+	}))
+}
+
+func deleteKeys(keys []string) func(map[string]*qdrant.Value) {
+	return func(payload map[string]*qdrant.Value) {
+		for _, key := range keys {
+			delete(payload, key)
+		}
+	}
+}
+
+// sqliteEditPayloads rewrites the payload of each selected point with edit.
+func sqliteEditPayloads(ctx context.Context, tx *sql.Tx, collection string, sel *qdrant.PointsSelector, edit func(map[string]*qdrant.Value)) error {
+	selected, err := sqliteSelected(ctx, tx, collection, sel)
+	if err != nil {
+		return err
+	}
+	for id, payload := range selected {
+		edit(payload)
+		raw, err := encodePayload(payload)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE points SET payload = ? WHERE collection = ? AND id = ?", raw, collection, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UpdateBatch applies the operations in order in one transaction: either
+// every one lands or none does.
+func (b *SQLiteBackend) UpdateBatch(ctx context.Context, request *qdrant.UpdateBatchPoints) ([]*qdrant.UpdateResult, error) {
+	name := request.GetCollectionName()
+	ops := request.GetOperations()
+	err := b.update(ctx, name, func(tx *sql.Tx, c *sqliteCollection) error {
+		for _, op := range ops {
+			var err error
+			switch o := op.GetOperation().(type) {
+			case *qdrant.PointsUpdateOperation_Upsert:
+				err = sqliteUpsert(ctx, tx, name, c, o.Upsert.GetPoints(), o.Upsert.GetUpdateFilter(), o.Upsert.GetUpdateMode())
+			case *qdrant.PointsUpdateOperation_SetPayload_:
+				err = sqliteEditPayloads(ctx, tx, name, o.SetPayload.GetPointsSelector(), func(payload map[string]*qdrant.Value) {
+					maps.Copy(payload, o.SetPayload.GetPayload())
+				})
+			case *qdrant.PointsUpdateOperation_DeletePayload_:
+				err = sqliteEditPayloads(ctx, tx, name, o.DeletePayload.GetPointsSelector(), deleteKeys(o.DeletePayload.GetKeys()))
+			case *qdrant.PointsUpdateOperation_DeletePoints_:
+				err = sqliteDelete(ctx, tx, name, o.DeletePoints.GetPoints())
+			default:
+				err = fmt.Errorf("sqlite backend: unsupported batch operation %T", o)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*qdrant.UpdateResult, len(ops))
+	for i := range results {
+		results[i] = &qdrant.UpdateResult{Status: qdrant.UpdateStatus_Completed}
+	}
+	return results, nil
+}
+
+// sqliteScan runs query, whose columns are id and payload, then the vector
+// if withVector, then a distance if the query selects a fourth column, and
+// calls fn on each row until it returns false.
+func sqliteScan(ctx context.Context, q querier, query string, args []any, withVector bool, fn func(id string, p *filePoint, distance float64) (bool, error)) error {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var (
+			id, raw  string
+			blob     []byte
+			distance float64
+		)
+		dest := []any{&id, &raw}
+		if withVector {
+			dest = append(dest, &blob)
+		}
+		if len(cols) > len(dest) {
+			dest = append(dest, &distance)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		payload, err := decodePayload(raw)
+		if err != nil {
+			return fmt.Errorf("point %s: %w", id, err)
+		}
+		p := &filePoint{payload: payload}
+		if withVector {
+			if p.vector, err = decodeVector(blob); err != nil {
+				return fmt.Errorf("point %s: %w", id, err)
+			}
+		}
+		more, err := fn(id, p, distance)
+		if err != nil || !more {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Get returns the points with the given IDs that exist.
+func (b *SQLiteBackend) Get(ctx context.Context, request *qdrant.GetPoints) ([]*qdrant.RetrievedPoint, error) {
+	if _, err := b.mustExist(ctx, b.db, request.GetCollectionName()); err != nil {
+		return nil, err
+	}
+	var out []*qdrant.RetrievedPoint
+	for _, pid := range request.GetIds() {
+		id := pointIDToString(pid)
+		err := sqliteScan(ctx, b.db, "SELECT id, payload, vector FROM points WHERE collection = ? AND id = ?",
+			[]any{request.GetCollectionName(), id}, true,
+			func(id string, p *filePoint, _ float64) (bool, error) {
+				out = append(out, p.retrieved(id, request.GetWithPayload(), request.GetWithVectors()))
+				return false, nil
+			})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// Query ranks the collection by sqlite-vec's cosine distance to the query
+// vector and returns the best points matching the filter, highest score
+// first.
+func (b *SQLiteBackend) Query(ctx context.Context, request *qdrant.QueryPoints) ([]*qdrant.ScoredPoint, error) {
+	c, err := b.mustExist(ctx, b.db, request.GetCollectionName())
+	if err != nil {
+		return nil, err
+	}
+	nearest := request.GetQuery().GetNearest()
+	if nearest == nil {
+		return nil, fmt.Errorf("sqlite backend: only nearest-vector queries are supported")
+	}
+	vector := nearest.GetDense().GetData()
+	if uint64(len(vector)) != c.vectorSize {
+		return nil, fmt.Errorf("vectors have different sizes (%d and %d); were they made by different models?", len(vector), c.vectorSize)
+	}
+	blob, err := vec.SerializeFloat32(vector)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := uint64(defaultFileLimit)
+	if request.Limit != nil {
+		limit = request.GetLimit()
+	}
+	want := request.GetOffset() + limit
+	var scored []*qdrant.ScoredPoint
+	err = sqliteScan(ctx, b.db, `SELECT id, payload, vector, vec_distance_cosine(vector, ?) AS distance
+		FROM points WHERE collection = ? ORDER BY distance, id`,
+		[]any{blob, request.GetCollectionName()}, true,
+		func(id string, p *filePoint, distance float64) (bool, error) {
+			score := float32(1 - distance)
+			if request.ScoreThreshold != nil && score < request.GetScoreThreshold() {
+				return false, nil
+			}
+			if !matchFilter(request.GetFilter(), id, valueMapToGoMap(p.payload)) {
+				return true, nil
+			}
+			r := p.retrieved(id, request.GetWithPayload(), request.GetWithVectors())
+			scored = append(scored, &qdrant.ScoredPoint{Id: r.Id, Payload: r.Payload, Vectors: r.Vectors, Score: score})
+			return uint64(len(scored)) < want, nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return page(scored, request.GetOffset(), limit), nil
+}
+
+// ScrollAndOffset lists points matching the filter in ID order, a page at
+// a time, returning the ID the next page starts at.
+func (b *SQLiteBackend) ScrollAndOffset(ctx context.Context, request *qdrant.ScrollPoints) ([]*qdrant.RetrievedPoint, *qdrant.PointId, error) {
+	if _, err := b.mustExist(ctx, b.db, request.GetCollectionName()); err != nil {
+		return nil, nil, err
+	}
+	if o := request.GetOrderBy(); o != nil {
+		var points []orderedPoint
+		err := sqliteScan(ctx, b.db, "SELECT id, payload, vector FROM points WHERE collection = ? ORDER BY id",
+			[]any{request.GetCollectionName()}, true,
+			func(id string, p *filePoint, _ float64) (bool, error) {
+				payload := valueMapToGoMap(p.payload)
+				if !matchFilter(request.GetFilter(), id, payload) {
+					return true, nil
+				}
+				if key, ok := orderKey(payload, o.GetKey()); ok {
+					points = append(points, orderedPoint{id, p, key})
+				}
+				return true, nil
+			})
+		if err != nil {
+			return nil, nil, err
+		}
+		return scrollOrdered(points, request), nil, nil
+	}
+	limit := defaultFileLimit
+	if request.Limit != nil {
+		limit = int(request.GetLimit())
+	}
+	var (
+		out  []*qdrant.RetrievedPoint
+		next *qdrant.PointId
+	)
+	err := sqliteScan(ctx, b.db, "SELECT id, payload, vector FROM points WHERE collection = ? AND id >= ? ORDER BY id",
+		[]any{request.GetCollectionName(), pointIDToString(request.GetOffset())}, true,
+		func(id string, p *filePoint, _ float64) (bool, error) {
+			if !matchFilter(request.GetFilter(), id, valueMapToGoMap(p.payload)) {
+				return true, nil
+			}
+			if len(out) == limit {
+				next = pointID(id)
+				return false, nil
+			}
+			out = append(out, p.retrieved(id, request.GetWithPayload(), request.GetWithVectors()))
+			return true, nil
+		})
+	if err != nil {
+		return nil, nil, err
+	}
+	return out, next, nil
+}
+
+// matchingPayloads returns the payloads of the points matching filter, in
+// ID order.
+func (b *SQLiteBackend) matchingPayloads(ctx context.Context, collection string, filter *qdrant.Filter) ([]map[string]any, error) {
+	if _, err := b.mustExist(ctx, b.db, collection); err != nil {
+		return nil, err
+	}
+	var payloads []map[string]any
+	err := sqliteScan(ctx, b.db, "SELECT id, payload FROM points WHERE collection = ? ORDER BY id", []any{collection}, false,
+		func(id string, p *filePoint, _ float64) (bool, error) {
+			if payload := valueMapToGoMap(p.payload); matchFilter(filter, id, payload) {
+				payloads = append(payloads, payload)
+			}
+			return true, nil
+		})
+	return payloads, err
+}
+
+// Count counts the points matching the filter.
+func (b *SQLiteBackend) Count(ctx context.Context, request *qdrant.CountPoints) (uint64, error) {
+	if request.GetFilter() == nil {
+		if _, err := b.mustExist(ctx, b.db, request.GetCollectionName()); err != nil {
+			return 0, err
+		}
+		var count uint64
+		err := b.db.QueryRowContext(ctx, "SELECT count(*) FROM points WHERE collection = ?", request.GetCollectionName()).Scan(&count)
+		return count, err
+	}
+	payloads, err := b.matchingPayloads(ctx, request.GetCollectionName(), request.GetFilter())
+	return uint64(len(payloads)), err
+}
+
+// Facet counts the points matching the filter per value of a field, most
+// common first. A point counts once per distinct value of an array field.
+func (b *SQLiteBackend) Facet(ctx context.Context, request *qdrant.FacetCounts) ([]*qdrant.FacetHit, error) {
+	payloads, err := b.matchingPayloads(ctx, request.GetCollectionName(), request.GetFilter())
+	if err != nil {
+		return nil, err
+	}
+	return facetHits(payloads, request.GetKey(), request.Limit), nil
+}
+
+// decodeVector reads a vector in sqlite-vec's format: little-endian
+// float32s.
+func decodeVector(blob []byte) ([]float32, error) {
+	if len(blob)%4 != 0 {
+		return nil, fmt.Errorf("vector blob of %d bytes", len(blob))
+	}
+	v := make([]float32, len(blob)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(blob[4*i:]))
+	}
+	return v, nil
+}
+
+// encodePayload renders a payload as a plain JSON object for the payload
+// column. Whole doubles keep a ".0" so decodePayload reads them back as
+// doubles rather than integers.
+func encodePayload(payload map[string]*qdrant.Value) (string, error) {
+	var buf bytes.Buffer
+	if err := writeStructJSON(&buf, payload); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func writeStructJSON(buf *bytes.Buffer, fields map[string]*qdrant.Value) error {
+	buf.WriteByte('{')
+	for i, k := range slices.Sorted(maps.Keys(fields)) {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(k)
+		buf.Write(name)
+		buf.WriteByte(':')
+		if err := writeValueJSON(buf, fields[k]); err != nil {
+			return err
+		}
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+func writeValueJSON(buf *bytes.Buffer, v *qdrant.Value) error {
+	switch k := v.GetKind().(type) {
+	case *qdrant.Value_DoubleValue:
+		f := k.DoubleValue
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return fmt.Errorf("payload number %v can't be stored as JSON", f)
+		}
+		s := strconv.FormatFloat(f, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".eE") {
+			s += ".0"
+		}
+		buf.WriteString(s)
+	case *qdrant.Value_IntegerValue:
+		buf.WriteString(strconv.FormatInt(k.IntegerValue, 10))
+	case *qdrant.Value_StringValue:
+		s, _ := json.Marshal(k.StringValue)
+		buf.Write(s)
+	case *qdrant.Value_BoolValue:
+		buf.WriteString(strconv.FormatBool(k.BoolValue))
+	case *qdrant.Value_StructValue:
+		return writeStructJSON(buf, k.StructValue.GetFields())
+	case *qdrant.Value_ListValue:
+		buf.WriteByte('[')
+		for i, item := range k.ListValue.GetValues() {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeValueJSON(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	default:
+		buf.WriteString("null")
+	}
+	return nil
+}
+
+// decodePayload parses a payload column: numbers written with a fraction
+// or exponent are doubles, others integers.
+func decodePayload(raw string) (map[string]*qdrant.Value, error) {
+	dec := json.NewDecoder(strings.NewReader(raw))
+	dec.UseNumber()
+	var m map[string]any
+	if err := dec.Decode(&m); err != nil {
+		return nil, err
+	}
+	payload, err := qdrant.TryValueMap(typedNumbers(m).(map[string]any))
+	if err != nil {
+		return nil, err
+	}
+	if payload == nil {
+		payload = map[string]*qdrant.Value{}
+	}
+	return payload, nil
+}
+
+// typedNumbers replaces the json.Numbers in v with int64s and float64s.
+func typedNumbers(v any) any {
+	switch x := v.(type) {
+	case json.Number:
+		if !strings.ContainsAny(x.String(), ".eE") {
+			if n, err := x.Int64(); err == nil {
+				return n
+			}
+		}
+		f, _ := x.Float64()
+		return f
+	case map[string]any:
+		for k, item := range x {
+			x[k] = typedNumbers(item)
+		}
+		if x == nil {
+			return map[string]any{}
+		}
+	case []any:
+		for i, item := range x {
+			x[i] = typedNumbers(item)
+		}
+	}
+	return v
+}
+
+var _ Backend = (*SQLiteBackend)(nil)
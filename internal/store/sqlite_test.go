@@ -0,0 +1,177 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSQLiteStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "memories.db")
+	s, err := NewSQLite(path)
+	if err != nil {
+		t.Fatalf("NewSQLite failed: %v", err)
+	}
+	defer s.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	deployID, err := s.Add(ctx, "", []float32{1, 0, 0, 0}, map[string]any{
+		"text": "deploys go out on tuesdays", "type": "fact", "tags": []any{"ops"}, "importance": 1.0,
+	})
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	catID, err := s.Add(ctx, "", []float32{0, 1, 0, 0}, map[string]any{
+		"text": "the cat is named Miso", "type": "preference",
+	})
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	t.Run("retrieve ranks by cosine distance", func(t *testing.T) {
+		results, err := s.Retrieve(ctx, []float32{1, 0.2, 0, 0}, 0, 10)
+		if err != nil {
+			t.Fatalf("Retrieve failed: %v", err)
+		}
+		if len(results) != 2 || results[0].ID != deployID || results[1].ID != catID {
+			t.Fatalf("expected the deploy memory first, got %+v", results)
+		}
+		if results[0].Score < 0.98 {
+			t.Errorf("expected a near-identical score, got %f", results[0].Score)
+		}
+		results, err = s.Retrieve(ctx, []float32{1, 0.2, 0, 0}, 0.5, 10)
+		if err != nil {
+			t.Fatalf("Retrieve failed: %v", err)
+		}
+		if len(results) != 1 {
+			t.Errorf("expected min score to drop the cat memory, got %d results", len(results))
+		}
+	})
+
+	t.Run("filters apply inside the search", func(t *testing.T) {
+		results, err := s.FindSimilarFiltered(ctx, []float32{1, 0, 0, 0}, 0, 10, Filter{Types: []string{"preference"}})
+		if err != nil {
+			t.Fatalf("FindSimilarFiltered failed: %v", err)
+		}
+		if len(results) != 1 || results[0].ID != catID {
+			t.Errorf("expected only the preference, got %+v", results)
+		}
+		tags, err := s.TagCounts(ctx, Filter{})
+		if err != nil || len(tags) != 1 || tags[0] != (TagCount{Tag: "ops", Count: 1}) {
+			t.Errorf("expected one ops tag, got %+v (%v)", tags, err)
+		}
+	})
+
+	t.Run("payloads round-trip their number types", func(t *testing.T) {
+		got, err := s.Peek(ctx, deployID)
+		if err != nil {
+			t.Fatalf("Peek failed: %v", err)
+		}
+		if _, ok := got.Payload["importance"].(float64); !ok {
+			t.Errorf("expected importance to stay a float, got %T", got.Payload["importance"])
+		}
+		if _, ok := got.Payload["access_count"].(int64); !ok {
+			t.Errorf("expected access_count to stay an integer, got %T", got.Payload["access_count"])
+		}
+	})
+
+	t.Run("payloads are queryable with SQL", func(t *testing.T) {
+		db, err := sql.Open("sqlite3", "file:"+path)
+		if err != nil {
+			t.Fatalf("open failed: %v", err)
+		}
+		defer db.Close()
+		var text string
+		err = db.QueryRow("SELECT json_extract(payload, '$.text') FROM points WHERE id = ?", catID).Scan(&text)
+		if err != nil || text != "the cat is named Miso" {
+			t.Errorf("expected the cat memory's text, got %q (%v)", text, err)
+		}
+	})
+
+	t.Run("memories persist across stores", func(t *testing.T) {
+		reopened, err := NewSQLite(path)
+		if err != nil {
+			t.Fatalf("NewSQLite failed: %v", err)
+		}
+		defer reopened.Close()
+		if err := reopened.DeleteIDs(ctx, []string{deployID}); err != nil {
+			t.Fatalf("DeleteIDs failed: %v", err)
+		}
+		if n, err := s.Count(ctx); err != nil || n != 1 {
+			t.Errorf("expected the first store to see the delete, got %d memories (%v)", n, err)
+		}
+	})
+}
+
+func TestSQLiteStoreForget(t *testing.T) {
+	s, err := NewSQLite(filepath.Join(t.TempDir(), "memories.db"))
+	if err != nil {
+		t.Fatalf("NewSQLite failed: %v", err)
+	}
+	defer s.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	for i := range 25 {
+		if _, err := s.Add(ctx, "", []float32{1, float32(i), 0, 0}, map[string]any{"text": fmt.Sprint("memory ", i)}); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+	time.Sleep(1100 * time.Millisecond)
+	// 25 memories take several scroll pages.
+	deleted, err := s.Forget(ctx, time.Second)
+	if err != nil {
+		t.Fatalf("Forget failed: %v", err)
+	}
+	if deleted != 25 {
+		t.Errorf("expected 25 deletions, got %d", deleted)
+	}
+}
+
+func TestSQLiteBackendConcurrentWriters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "memories.db")
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	first, err := NewSQLite(path)
+	if err != nil {
+		t.Fatalf("NewSQLite failed: %v", err)
+	}
+	defer first.Close()
+	if _, err := first.Add(ctx, "", []float32{1, 0, 0, 0}, map[string]any{"text": "seed"}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for w := range 2 {
+		s, err := NewSQLite(path)
+		if err != nil {
+			t.Fatalf("NewSQLite failed: %v", err)
+		}
+		defer s.Close()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 10 {
+				_, err := s.Add(ctx, "", []float32{1, float32(w), float32(i), 0}, map[string]any{"text": fmt.Sprint(w, i)})
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+	if n, err := first.Count(ctx); err != nil || n != 21 {
+		t.Errorf("expected every write to land, got %d memories (%v)", n, err)
+	}
+}
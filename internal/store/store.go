@@ -3,21 +3,59 @@ package store
 import (
 	"context"
 	"fmt"
-	"log"
+	"maps"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/hsk-coder/clawbrain/internal/textnorm"
 	"github.com/qdrant/go-client/qdrant"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
-// collectionName is the single Qdrant collection used for all memories.
-const collectionName = "memories"
+// DefaultCollection is the Qdrant collection holding memories outside any
+// namespace.
+const DefaultCollection = "memories"
+
+// payloadIndexes lists payload fields that get an index when the collection
+// is created, so filtered lookups on them (alias resolution, per-type search,
+// due reminders, attribution, privacy, keyword search, tags, time ranges)
+// don't scan every point.
+var payloadIndexes = []struct {
+	field string
+	kind  qdrant.FieldType
+}{
+	{"alias", qdrant.FieldType_FieldTypeKeyword},
+	{TypeField, qdrant.FieldType_FieldTypeKeyword},
+	{RemindNextField, qdrant.FieldType_FieldTypeDatetime},
+	{AuthorField, qdrant.FieldType_FieldTypeKeyword},
+	{SpeakerField, qdrant.FieldType_FieldTypeKeyword},
+	{SensitivityField, qdrant.FieldType_FieldTypeKeyword},
+	{TextField, qdrant.FieldType_FieldTypeText},
+	{TagsField, qdrant.FieldType_FieldTypeKeyword},
+	{ArchivedAtField, qdrant.FieldType_FieldTypeDatetime},
+	{CreatedAtField, qdrant.FieldType_FieldTypeDatetime},
+	{LastAccessedField, qdrant.FieldType_FieldTypeDatetime},
+}
 
 // Store wraps the Qdrant client and provides memory operations.
 type Store struct {
-	client *qdrant.Client
+	client          Backend
+	collection      string     // Qdrant collection; see SetNamespace
+	agent           string     // agent scope; see SetAgent
+	frequencyWeight float64    // see SetFrequencyWeight
+	clientVersion   string     // see SetClientVersion
+	model           string     // see SetModel
+	pool            *pool      // call checkout and metrics; see NewWithPool
+	mu              sync.Mutex // guards the checked flags; a Store may serve concurrent requests
+	tenantChecked   bool       // ensureTenantIndex already ran
+	textChecked     bool       // ensureTextIndex already ran
+	tagsChecked     bool       // ensureTagsIndex already ran
+	embedding       *Embedding // the collection's, once CheckEmbedding looked it up
+	cold            bool       // the cold tier; see Cold
 }
 
 // Result represents a single retrieval result.
@@ -27,16 +65,10 @@ type Result struct {
 	Payload map[string]any `json:"payload"`
 }
 
-// New creates a new Store connected to Qdrant.
+// New creates a new Store connected to Qdrant with the default pool
+// options (see NewWithPool).
 func New(host string, port int) (*Store, error) {
-	client, err := qdrant.NewClient(&qdrant.Config{
-		Host: host,
-		Port: port,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("connect to qdrant: %w", err)
-	}
-	return &Store{client: client}, nil
+	return NewWithPool(host, port, PoolOptions{})
 }
 
 // Close closes the underlying Qdrant connection.
@@ -46,24 +78,55 @@ func (s *Store) Close() error {
 
 // ensureCollection creates the memories collection if it doesn't exist.
 func (s *Store) ensureCollection(ctx context.Context, vectorSize uint64) error {
-	exists, err := s.client.CollectionExists(ctx, collectionName)
+	exists, err := s.client.CollectionExists(ctx, s.collection)
 	if err != nil {
 		return fmt.Errorf("check collection: %w", err)
 	}
 	if exists {
-		return nil
+		return s.ensureTenantIndex(ctx)
 	}
 
-	err = s.client.CreateCollection(ctx, &qdrant.CreateCollection{
-		CollectionName: collectionName,
+	create := &qdrant.CreateCollection{
+		CollectionName: s.collection,
 		VectorsConfig: qdrant.NewVectorsConfig(&qdrant.VectorParams{
 			Size:     vectorSize,
 			Distance: qdrant.Distance_Cosine,
 		}),
-	})
+		Metadata: s.schemaMetadata(SchemaVersion),
+	}
+	maps.Copy(create.Metadata, s.embeddingMetadata(vectorSize))
+	if s.agent != "" {
+		m, payloadM := uint64(0), uint64(tenantPayloadM)
+		create.HnswConfig = &qdrant.HnswConfigDiff{M: &m, PayloadM: &payloadM}
+	}
+	if s.cold {
+		coldSettings(create)
+	}
+	err = s.client.CreateCollection(ctx, create)
 	if err != nil {
 		return fmt.Errorf("create collection: %w", err)
 	}
+
+	wait := true
+	for _, idx := range payloadIndexes {
+		_, err = s.client.CreateFieldIndex(ctx, &qdrant.CreateFieldIndexCollection{
+			CollectionName: s.collection,
+			Wait:           &wait,
+			FieldName:      idx.field,
+			FieldType:      idx.kind.Enum(),
+		})
+		if err != nil {
+			return fmt.Errorf("create %s index: %w", idx.field, err)
+		}
+	}
+	if s.agent != "" {
+		if err := s.createTenantIndex(ctx); err != nil {
+			return err
+		}
+		s.mu.Lock()
+		s.tenantChecked = true
+		s.mu.Unlock()
+	}
 	return nil
 }
 
@@ -75,12 +138,10 @@ func (s *Store) Add(ctx context.Context, id string, vector []float32, payload ma
 		return "", err
 	}
 
-	now := time.Now().UTC().Format(time.RFC3339Nano)
-	// Only set created_at if not already present (e.g. preserved from a merged memory)
-	if _, exists := payload["created_at"]; !exists {
-		payload["created_at"] = now
+	s.stamp(payload)
+	if _, exists := payload[RevisionField]; !exists {
+		payload[RevisionField] = int64(1)
 	}
-	payload["last_accessed"] = now
 
 	if id == "" {
 		id = uuid.New().String()
@@ -89,7 +150,7 @@ func (s *Store) Add(ctx context.Context, id string, vector []float32, payload ma
 
 	wait := true
 	_, err := s.client.Upsert(ctx, &qdrant.UpsertPoints{
-		CollectionName: collectionName,
+		CollectionName: s.collection,
 		Wait:           &wait,
 		Points: []*qdrant.PointStruct{
 			{
@@ -102,18 +163,87 @@ func (s *Store) Add(ctx context.Context, id string, vector []float32, payload ma
 	if err != nil {
 		return "", fmt.Errorf("upsert: %w", err)
 	}
+	s.changed(ctx)
 
 	return id, nil
 }
 
+// AddBatch stores many memories in a single upsert, stamping each payload
+// like Add does. Points without an ID get a generated UUID; the IDs are
+// returned in the order of points. Every vector must have the same size.
+func (s *Store) AddBatch(ctx context.Context, points []Point) ([]string, error) {
+	if len(points) == 0 {
+		return nil, nil
+	}
+	size := len(points[0].Vector)
+	for _, p := range points {
+		if len(p.Vector) != size {
+			return nil, fmt.Errorf("batch mixes vectors of %d and %d dimensions", size, len(p.Vector))
+		}
+	}
+	if err := s.ensureCollection(ctx, uint64(size)); err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(points))
+	structs := make([]*qdrant.PointStruct, len(points))
+	for i, p := range points {
+		s.stamp(p.Payload)
+		if _, exists := p.Payload[RevisionField]; !exists {
+			p.Payload[RevisionField] = int64(1)
+		}
+		ids[i] = p.ID
+		if ids[i] == "" {
+			ids[i] = uuid.New().String()
+		}
+		structs[i] = &qdrant.PointStruct{
+			Id:      qdrant.NewIDUUID(ids[i]),
+			Vectors: qdrant.NewVectors(p.Vector...),
+			Payload: qdrant.NewValueMap(p.Payload),
+		}
+	}
+
+	wait := true
+	_, err := s.client.Upsert(ctx, &qdrant.UpsertPoints{
+		CollectionName: s.collection,
+		Wait:           &wait,
+		Points:         structs,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("upsert: %w", err)
+	}
+	s.changed(ctx)
+	return ids, nil
+}
+
+// stamp sets the fields every write maintains: created_at if not already
+// present (e.g. preserved from a merged memory), last_accessed, and the
+// agent when the store is scoped to one.
+func (s *Store) stamp(payload map[string]any) {
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	if _, exists := payload["created_at"]; !exists {
+		payload["created_at"] = now
+	}
+	payload["last_accessed"] = now
+	if s.agent != "" {
+		payload[AgentField] = s.agent
+	}
+}
+
 // Retrieve queries memories and returns the top matches.
-// It updates last_accessed on all returned points.
+// It updates last_accessed and access_count on all returned points.
 // Ranking is pure cosine similarity.
 func (s *Store) Retrieve(ctx context.Context, vector []float32, minScore float32, limit uint64) ([]Result, error) {
+	return s.RetrievePage(ctx, vector, minScore, limit, 0)
+}
+
+// RetrievePage is Retrieve skipping the offset best matches first, for
+// paging through results. Only the returned page counts as recalled.
+func (s *Store) RetrievePage(ctx context.Context, vector []float32, minScore float32, limit, offset uint64) ([]Result, error) {
 	// Guard: return empty results gracefully when the collection doesn't exist
 	// yet (e.g. no memories have been stored). Matches the behavior of Get,
 	// FindSimilar, and every other read method in this package.
-	exists, err := s.client.CollectionExists(ctx, collectionName)
+	exists, err := s.client.CollectionExists(ctx, s.collection)
 	if err != nil {
 		return nil, fmt.Errorf("check collection exists: %w", err)
 	}
@@ -125,38 +255,67 @@ func (s *Store) Retrieve(ctx context.Context, vector []float32, minScore float32
 	}
 
 	query := &qdrant.QueryPoints{
-		CollectionName: collectionName,
+		CollectionName: s.collection,
 		Query:          qdrant.NewQuery(vector...),
+		Filter:         s.scoped(nil),
 		WithPayload:    qdrant.NewWithPayload(true),
 		ScoreThreshold: &minScore,
 		Limit:          &limit,
 	}
+	if offset > 0 {
+		query.Offset = &offset
+	}
 
 	results, err := s.client.Query(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("query: %w", err)
 	}
 
-	nowStr := time.Now().UTC().Format(time.RFC3339Nano)
 	out := make([]Result, 0, len(results))
-
 	for _, point := range results {
-		s.updateLastAccessed(ctx, point.Id, nowStr)
-
 		out = append(out, Result{
 			ID:      pointIDToString(point.Id),
 			Score:   point.Score,
 			Payload: valueMapToGoMap(point.Payload),
 		})
 	}
+	s.Touch(ctx, out)
 
 	return out, nil
 }
 
 // Get retrieves a single point by its UUID.
-// Returns nil if the point is not found. Updates last_accessed on retrieval.
+// Returns nil if the point is not found. Updates last_accessed and
+// access_count on retrieval.
 func (s *Store) Get(ctx context.Context, id string) (*Result, error) {
-	exists, err := s.client.CollectionExists(ctx, collectionName)
+	result, err := s.Peek(ctx, id)
+	if err != nil || result == nil {
+		return nil, err
+	}
+	s.Touch(ctx, []Result{*result})
+
+	return result, nil
+}
+
+// Peek retrieves a single point by its UUID without updating last_accessed.
+// It is meant for internal checks (e.g. whether a memory is locked) that
+// should not count as a recall. Returns nil if the point is not found.
+func (s *Store) Peek(ctx context.Context, id string) (*Result, error) {
+	p, err := s.peek(ctx, id, false)
+	if p == nil || err != nil {
+		return nil, err
+	}
+	return &Result{ID: p.ID, Payload: p.Payload}, nil
+}
+
+// PeekPoint is Peek with the memory's vector, for rewriting a memory's
+// payload without re-embedding its text.
+func (s *Store) PeekPoint(ctx context.Context, id string) (*Point, error) {
+	return s.peek(ctx, id, true)
+}
+
+func (s *Store) peek(ctx context.Context, id string, withVector bool) (*Point, error) {
+	exists, err := s.client.CollectionExists(ctx, s.collection)
 	if err != nil {
 		return nil, fmt.Errorf("check collection: %w", err)
 	}
@@ -165,10 +324,10 @@ func (s *Store) Get(ctx context.Context, id string) (*Result, error) {
 	}
 
 	points, err := s.client.Get(ctx, &qdrant.GetPoints{
-		CollectionName: collectionName,
+		CollectionName: s.collection,
 		Ids:            []*qdrant.PointId{qdrant.NewIDUUID(id)},
 		WithPayload:    qdrant.NewWithPayload(true),
-		WithVectors:    qdrant.NewWithVectors(false),
+		WithVectors:    qdrant.NewWithVectors(withVector),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("get point: %w", err)
@@ -179,47 +338,110 @@ func (s *Store) Get(ctx context.Context, id string) (*Result, error) {
 	}
 
 	point := points[0]
-
-	// Update last_accessed
-	nowStr := time.Now().UTC().Format(time.RFC3339Nano)
-	s.updateLastAccessed(ctx, point.Id, nowStr)
-
-	return &Result{
+	payload := valueMapToGoMap(point.Payload)
+	if !s.ownedBy(payload) {
+		return nil, nil
+	}
+	return &Point{
 		ID:      pointIDToString(point.Id),
-		Score:   0,
-		Payload: valueMapToGoMap(point.Payload),
+		Vector:  denseVector(point.GetVectors().GetVector()),
+		Payload: payload,
 	}, nil
 }
 
-// Forget deletes memories not accessed within the given TTL.
+// IsLocked reports whether the payload marks the memory as locked. A locked
+// memory must not be updated, merged, or deleted until it is unlocked —
+// stronger than pinned, which only exempts a memory from forgetting.
+func IsLocked(payload map[string]any) bool {
+	locked, ok := payload["locked"].(bool)
+	return ok && locked
+}
+
+// Forget deletes memories not accessed within the given TTL, stretched for
+// frequently recalled memories when a frequency weight is set (see
+// SetFrequencyWeight). Pinned and locked memories are never deleted.
 // Returns the number of memories deleted.
 func (s *Store) Forget(ctx context.Context, ttl time.Duration) (int, error) {
-	// Check if collection exists first
-	exists, err := s.client.CollectionExists(ctx, collectionName)
+	return s.forget(ctx, ttl)
+}
+
+// Stale returns the memories Forget would delete with the same TTL, without
+// deleting anything. Like All, it does NOT update last_accessed.
+func (s *Store) Stale(ctx context.Context, ttl time.Duration) ([]Result, error) {
+	return s.staleIfExists(ctx, ttl)
+}
+
+// staleIfExists is stale, returning nothing when there is no collection yet.
+func (s *Store) staleIfExists(ctx context.Context, ttl time.Duration, extra ...*qdrant.Condition) ([]Result, error) {
+	exists, err := s.client.CollectionExists(ctx, s.collection)
 	if err != nil {
-		return 0, fmt.Errorf("check collection: %w", err)
+		return nil, fmt.Errorf("check collection: %w", err)
 	}
 	if !exists {
-		return 0, nil
+		return nil, nil
 	}
+	return s.stale(ctx, ttl, extra...)
+}
 
-	cutoff := time.Now().UTC().Add(-ttl)
+// stale returns the unpinned, unlocked memories that have outlived ttl and
+// match every extra condition. Qdrant narrows them down to those not
+// accessed within ttl itself, the shortest retention TTL there is; the
+// frequency weight is applied to what it returns.
+func (s *Store) stale(ctx context.Context, ttl time.Duration, extra ...*qdrant.Condition) ([]Result, error) {
+	candidates, err := s.scrollPoints(ctx, staleFilter(ttl, extra...))
+	if err != nil {
+		return nil, fmt.Errorf("scroll stale points: %w", err)
+	}
+	if s.frequencyWeight <= 0 {
+		return candidates, nil
+	}
+	now := time.Now().UTC()
+	out := []Result{}
+	for _, r := range candidates {
+		if s.outlived(r.Payload, ttl, now) {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
 
-	filter := &qdrant.Filter{
-		Must: []*qdrant.Condition{
+// staleFilter matches unpinned, unlocked memories not accessed within ttl
+// that also match every extra condition.
+func staleFilter(ttl time.Duration, extra ...*qdrant.Condition) *qdrant.Filter {
+	cutoff := time.Now().UTC().Add(-ttl)
+	return &qdrant.Filter{
+		Must: append([]*qdrant.Condition{
 			qdrant.NewDatetimeRange("last_accessed", &qdrant.DatetimeRange{
 				Lt: timestamppb.New(cutoff),
 			}),
-		},
+		}, extra...),
 		MustNot: []*qdrant.Condition{
 			qdrant.NewMatchBool("pinned", true),
+			qdrant.NewMatchBool("locked", true),
 		},
 	}
+}
+
+// forget deletes unpinned, unlocked memories that have outlived ttl and
+// also match every extra condition.
+func (s *Store) forget(ctx context.Context, ttl time.Duration, extra ...*qdrant.Condition) (int, error) {
+	// Check if collection exists first
+	exists, err := s.client.CollectionExists(ctx, s.collection)
+	if err != nil {
+		return 0, fmt.Errorf("check collection: %w", err)
+	}
+	if !exists {
+		return 0, nil
+	}
 
 	// Scroll to find all stale points
-	pointIDs, err := s.scrollPointIDs(ctx, filter)
+	stale, err := s.stale(ctx, ttl, extra...)
 	if err != nil {
-		return 0, fmt.Errorf("scroll stale points: %w", err)
+		return 0, err
+	}
+	pointIDs := make([]*qdrant.PointId, len(stale))
+	for i, r := range stale {
+		pointIDs[i] = qdrant.NewIDUUID(r.ID)
 	}
 
 	if len(pointIDs) == 0 {
@@ -229,7 +451,7 @@ func (s *Store) Forget(ctx context.Context, ttl time.Duration) (int, error) {
 	// Delete them
 	wait := true
 	_, err = s.client.Delete(ctx, &qdrant.DeletePoints{
-		CollectionName: collectionName,
+		CollectionName: s.collection,
 		Wait:           &wait,
 		Points: &qdrant.PointsSelector{
 			PointsSelectorOneOf: &qdrant.PointsSelector_Points{
@@ -242,6 +464,7 @@ func (s *Store) Forget(ctx context.Context, ttl time.Duration) (int, error) {
 	if err != nil {
 		return 0, fmt.Errorf("delete stale points: %w", err)
 	}
+	s.changed(ctx)
 
 	return len(pointIDs), nil
 }
@@ -249,7 +472,7 @@ func (s *Store) Forget(ctx context.Context, ttl time.Duration) (int, error) {
 // Delete removes a single memory by its UUID.
 // Returns nil if the point doesn't exist or the collection doesn't exist.
 func (s *Store) Delete(ctx context.Context, id string) error {
-	exists, err := s.client.CollectionExists(ctx, collectionName)
+	exists, err := s.client.CollectionExists(ctx, s.collection)
 	if err != nil {
 		return fmt.Errorf("check collection: %w", err)
 	}
@@ -259,19 +482,46 @@ func (s *Store) Delete(ctx context.Context, id string) error {
 
 	wait := true
 	_, err = s.client.Delete(ctx, &qdrant.DeletePoints{
-		CollectionName: collectionName,
+		CollectionName: s.collection,
 		Wait:           &wait,
-		Points: &qdrant.PointsSelector{
-			PointsSelectorOneOf: &qdrant.PointsSelector_Points{
-				Points: &qdrant.PointsIdsList{
-					Ids: []*qdrant.PointId{qdrant.NewIDUUID(id)},
-				},
-			},
-		},
+		Points:         s.selector(qdrant.NewIDUUID(id)),
 	})
 	if err != nil {
 		return fmt.Errorf("delete point: %w", err)
 	}
+	s.changed(ctx)
+	return nil
+}
+
+// DeleteIDs removes the given memories in a single request.
+// Returns nil if the collection doesn't exist or ids is empty.
+func (s *Store) DeleteIDs(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	exists, err := s.client.CollectionExists(ctx, s.collection)
+	if err != nil {
+		return fmt.Errorf("check collection: %w", err)
+	}
+	if !exists {
+		return nil
+	}
+
+	pointIDs := make([]*qdrant.PointId, len(ids))
+	for i, id := range ids {
+		pointIDs[i] = qdrant.NewIDUUID(id)
+	}
+
+	wait := true
+	_, err = s.client.Delete(ctx, &qdrant.DeletePoints{
+		CollectionName: s.collection,
+		Wait:           &wait,
+		Points:         s.selector(pointIDs...),
+	})
+	if err != nil {
+		return fmt.Errorf("delete points: %w", err)
+	}
+	s.changed(ctx)
 	return nil
 }
 
@@ -279,17 +529,206 @@ func (s *Store) Delete(ctx context.Context, id string) error {
 // Unlike Retrieve, it does NOT update last_accessed on returned points.
 // This is intended for internal dedup checks before insertion.
 func (s *Store) FindSimilar(ctx context.Context, vector []float32, threshold float32, limit uint64) ([]Result, error) {
-	exists, err := s.client.CollectionExists(ctx, collectionName)
+	return s.FindSimilarFiltered(ctx, vector, threshold, limit, Filter{})
+}
+
+// Filter restricts which memories a similarity search considers. The zero
+// Filter matches everything. It is translated into a Qdrant filter so the
+// restriction applies inside the search rather than to its results, and a
+// filtered search still returns up to limit matches.
+type Filter struct {
+	// Types keeps only memories whose type is one of these. The empty string
+	// selects memories without a type.
+	Types []string
+	// ExcludeTypes drops memories whose type is one of these. The empty
+	// string drops memories without a type.
+	ExcludeTypes []string
+	// TextContains keeps only memories whose text contains at least one of
+	// these strings. With the full-text index on text they match whole
+	// words, ignoring case; in older collections without the index they
+	// match as exact substrings.
+	TextContains []string
+	// MustContain keeps only memories whose text contains every one of
+	// these phrases literally, ignoring case. The search itself can only
+	// ask for each phrase's words; FindSimilarFiltered and FindKeyword then
+	// drop results where they don't appear together, so other uses of the
+	// filter match a superset.
+	MustContain []string
+	// Conditions keeps only memories matching every payload condition. They
+	// must pass Condition.CheckSearchable.
+	Conditions []Condition
+	// ExcludePersonal drops memories whose sensitivity is personal.
+	ExcludePersonal bool
+	// ExcludeArchived drops memories marked archived.
+	ExcludeArchived bool
+	// ExcludeSuperseded drops memories another memory has superseded.
+	ExcludeSuperseded bool
+	// Since and Until, unless zero, keep only memories whose TimeField is
+	// at or after Since and before Until. TimeField is CreatedAtField
+	// unless set (LastAccessedField is the other that fits).
+	Since, Until time.Time
+	TimeField    string
+}
+
+// timeRange converts the filter's Since and Until to a Qdrant condition,
+// or nil if neither is set.
+func (f Filter) timeRange() *qdrant.Condition {
+	if f.Since.IsZero() && f.Until.IsZero() {
+		return nil
+	}
+	field := f.TimeField
+	if field == "" {
+		field = CreatedAtField
+	}
+	r := &qdrant.DatetimeRange{}
+	if !f.Since.IsZero() {
+		r.Gte = timestamppb.New(f.Since)
+	}
+	if !f.Until.IsZero() {
+		r.Lt = timestamppb.New(f.Until)
+	}
+	return qdrant.NewDatetimeRange(field, r)
+}
+
+// Empty reports whether the filter matches every memory.
+func (f Filter) Empty() bool {
+	return f.qdrantFilter() == nil
+}
+
+// qdrantFilter converts the filter to a Qdrant filter, or nil if it is empty.
+func (f Filter) qdrantFilter() *qdrant.Filter {
+	var must, mustNot []*qdrant.Condition
+
+	if len(f.Types) > 0 {
+		named, untyped := splitUntyped(f.Types)
+		var either []*qdrant.Condition
+		if len(named) > 0 {
+			either = append(either, qdrant.NewMatchKeywords(TypeField, named...))
+		}
+		if untyped {
+			either = append(either, qdrant.NewIsEmpty(TypeField))
+		}
+		must = append(must, qdrant.NewFilterAsCondition(&qdrant.Filter{Should: either}))
+	}
+
+	if len(f.TextContains) > 0 {
+		either := make([]*qdrant.Condition, 0, len(f.TextContains))
+		for _, text := range f.TextContains {
+			either = append(either, qdrant.NewMatchText(TextField, text))
+		}
+		must = append(must, qdrant.NewFilterAsCondition(&qdrant.Filter{Should: either}))
+	}
+
+	for _, phrase := range f.MustContain {
+		if indexable(phrase) {
+			must = append(must, qdrant.NewMatchText(TextField, phrase))
+		}
+	}
+
+	for _, c := range f.Conditions {
+		must = append(must, c.qdrantCondition())
+	}
+
+	if f.ExcludeSuperseded {
+		must = append(must, qdrant.NewIsEmpty(SupersededByField))
+	}
+
+	if r := f.timeRange(); r != nil {
+		must = append(must, r)
+	}
+
+	if len(f.ExcludeTypes) > 0 {
+		named, untyped := splitUntyped(f.ExcludeTypes)
+		if len(named) > 0 {
+			mustNot = append(mustNot, qdrant.NewMatchKeywords(TypeField, named...))
+		}
+		if untyped {
+			mustNot = append(mustNot, qdrant.NewIsEmpty(TypeField))
+		}
+	}
+
+	if f.ExcludePersonal {
+		mustNot = append(mustNot, qdrant.NewMatchKeyword(SensitivityField, SensitivityPersonal))
+	}
+	if f.ExcludeArchived {
+		mustNot = append(mustNot, qdrant.NewMatchBool(ArchivedField, true))
+	}
+
+	if len(must) == 0 && len(mustNot) == 0 {
+		return nil
+	}
+	return &qdrant.Filter{Must: must, MustNot: mustNot}
+}
+
+// mustContain drops the results whose text is missing one of the
+// filter's MustContain phrases.
+func (f Filter) mustContain(results []Result) []Result {
+	if len(f.MustContain) == 0 {
+		return results
+	}
+	kept := results[:0]
+	for _, r := range results {
+		if ContainsPhrases(r.Payload, f.MustContain) {
+			kept = append(kept, r)
+		}
+	}
+	return kept
+}
+
+// indexable reports whether the full-text index can narrow a search to
+// the memories containing phrase. It can't for Chinese, Japanese or Korean,
+// which it doesn't split into words the way the phrase would be, nor for
+// fullwidth letters the text may hold in either width; such phrases are
+// only checked by mustContain.
+func indexable(phrase string) bool {
+	return !textnorm.HasCJK(phrase) && textnorm.Fold(phrase) == phrase
+}
+
+// ContainsPhrases reports whether a memory's text contains every phrase,
+// ignoring case and width (see textnorm.Fold), so "ＡＰＩ" matches "api".
+func ContainsPhrases(payload map[string]any, phrases []string) bool {
+	text, _ := payload[TextField].(string)
+	text = strings.ToLower(textnorm.Fold(text))
+	for _, phrase := range phrases {
+		if !strings.Contains(text, strings.ToLower(textnorm.Fold(phrase))) {
+			return false
+		}
+	}
+	return true
+}
+
+// splitUntyped separates named types from the "" untyped marker.
+func splitUntyped(types []string) (named []string, untyped bool) {
+	for _, t := range types {
+		if t == "" {
+			untyped = true
+		} else {
+			named = append(named, t)
+		}
+	}
+	return named, untyped
+}
+
+// FindSimilarFiltered is FindSimilar restricted to memories matching the filter.
+// Like FindSimilar, it does NOT update last_accessed.
+func (s *Store) FindSimilarFiltered(ctx context.Context, vector []float32, threshold float32, limit uint64, filter Filter) ([]Result, error) {
+	exists, err := s.client.CollectionExists(ctx, s.collection)
 	if err != nil {
 		return nil, fmt.Errorf("check collection: %w", err)
 	}
 	if !exists {
 		return nil, nil
 	}
+	if len(filter.MustContain) > 0 {
+		if err := s.ensureTextIndex(ctx); err != nil {
+			return nil, err
+		}
+	}
 
 	query := &qdrant.QueryPoints{
-		CollectionName: collectionName,
+		CollectionName: s.collection,
 		Query:          qdrant.NewQuery(vector...),
+		Filter:         s.scoped(filter.qdrantFilter()),
 		WithPayload:    qdrant.NewWithPayload(true),
 		ScoreThreshold: &threshold,
 		Limit:          &limit,
@@ -308,25 +747,520 @@ func (s *Store) FindSimilar(ctx context.Context, vector []float32, threshold flo
 			Payload: valueMapToGoMap(point.Payload),
 		})
 	}
+	return filter.mustContain(out), nil
+}
+
+// All returns every stored memory with its payload.
+// Like FindSimilar, it does NOT update last_accessed — it is meant for
+// reporting and maintenance passes that inspect memories without recalling them.
+func (s *Store) All(ctx context.Context) ([]Result, error) {
+	exists, err := s.client.CollectionExists(ctx, s.collection)
+	if err != nil {
+		return nil, fmt.Errorf("check collection: %w", err)
+	}
+	if !exists {
+		return []Result{}, nil
+	}
+
+	points, err := s.scrollPoints(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("scroll points: %w", err)
+	}
+	return points, nil
+}
+
+// Recent returns up to limit memories matching filter, newest first by
+// created_at, without their vectors. Memories without created_at are left
+// out. Like All, it does NOT update last_accessed.
+func (s *Store) Recent(ctx context.Context, limit uint32, filter Filter) ([]Result, error) {
+	exists, err := s.client.CollectionExists(ctx, s.collection)
+	if err != nil {
+		return nil, fmt.Errorf("check collection: %w", err)
+	}
+	if !exists {
+		return []Result{}, nil
+	}
+
+	points, _, err := s.client.ScrollAndOffset(ctx, &qdrant.ScrollPoints{
+		CollectionName: s.collection,
+		Filter:         s.scoped(filter.qdrantFilter()),
+		Limit:          &limit,
+		OrderBy:        &qdrant.OrderBy{Key: CreatedAtField, Direction: qdrant.Direction_Desc.Enum()},
+		WithPayload:    qdrant.NewWithPayload(true),
+		WithVectors:    qdrant.NewWithVectors(false),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scroll recent points: %w", err)
+	}
+	out := make([]Result, len(points))
+	for i, point := range points {
+		out[i] = Result{ID: pointIDToString(point.Id), Payload: valueMapToGoMap(point.Payload)}
+	}
+	return filter.mustContain(out), nil
+}
+
+// maxAliasLength bounds alias names so they stay short enough to type.
+const maxAliasLength = 64
+
+// ValidateAlias checks that an alias is a short, human-friendly name made of
+// letters, digits, '.', '_' and '-'. UUID-shaped aliases are rejected so an
+// alias can never be confused with a memory ID.
+func ValidateAlias(alias string) error {
+	if alias == "" {
+		return fmt.Errorf("alias must not be empty")
+	}
+	if len(alias) > maxAliasLength {
+		return fmt.Errorf("alias %q is longer than %d characters", alias, maxAliasLength)
+	}
+	for _, r := range alias {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '_', r == '-':
+		default:
+			return fmt.Errorf("alias %q contains invalid character %q (allowed: letters, digits, '.', '_', '-')", alias, r)
+		}
+	}
+	if _, err := uuid.Parse(alias); err == nil {
+		return fmt.Errorf("alias %q looks like a UUID", alias)
+	}
+	return nil
+}
+
+// ResolveAlias returns the ID of the memory holding the alias, or "" if no
+// memory has it. Like FindSimilar, it does NOT update last_accessed.
+func (s *Store) ResolveAlias(ctx context.Context, alias string) (string, error) {
+	ids, err := s.aliasHolders(ctx, alias)
+	if err != nil || len(ids) == 0 {
+		return "", err
+	}
+	return pointIDToString(ids[0]), nil
+}
+
+// ReleaseAlias removes the alias from every memory holding it, so it can be
+// assigned to another memory. Returns the IDs it was removed from.
+func (s *Store) ReleaseAlias(ctx context.Context, alias string) ([]string, error) {
+	ids, err := s.aliasHolders(ctx, alias)
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	wait := true
+	_, err = s.client.DeletePayload(ctx, &qdrant.DeletePayloadPoints{
+		CollectionName: s.collection,
+		Wait:           &wait,
+		Keys:           []string{"alias"},
+		PointsSelector: s.selector(ids...),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("release alias: %w", err)
+	}
+	s.changed(ctx)
+
+	out := make([]string, len(ids))
+	for i, id := range ids {
+		out[i] = pointIDToString(id)
+	}
 	return out, nil
 }
 
+// aliasHolders returns the IDs of all memories whose alias payload field
+// equals alias. Returns nil if the collection doesn't exist.
+func (s *Store) aliasHolders(ctx context.Context, alias string) ([]*qdrant.PointId, error) {
+	exists, err := s.client.CollectionExists(ctx, s.collection)
+	if err != nil {
+		return nil, fmt.Errorf("check collection: %w", err)
+	}
+	if !exists {
+		return nil, nil
+	}
+
+	ids, err := s.scrollPointIDs(ctx, &qdrant.Filter{
+		Must: []*qdrant.Condition{qdrant.NewMatchKeyword("alias", alias)},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scroll alias: %w", err)
+	}
+	return ids, nil
+}
+
+// Condition is a single payload predicate, parsed from a filter expression:
+//
+//	key=value            equality; a value ending in "/" matches as a path
+//	                     prefix, so "source=/old/notes/" selects every chunk
+//	                     synced from that directory
+//	key>N, key>=N,       numeric range
+//	key<N, key<=N
+//	key~LAT,LON,RADIUS   geo point within RADIUS ("500m", "2km") of LAT,LON
+//
+// For array fields (e.g. tags) the condition matches if any element matches.
+type Condition struct {
+	Key   string
+	Op    string // "=" (also the zero value), ">", ">=", "<", "<=" or "~"
+	Value string
+
+	num                 float64 // bound for range operators
+	lat, lon, radiusMtr float64 // center and radius for "~"
+}
+
+// ParseCondition parses a filter expression; see Condition.
+func ParseCondition(expr string) (Condition, error) {
+	i := strings.IndexAny(expr, "<>=~")
+	if i < 0 {
+		return Condition{}, fmt.Errorf("invalid filter %q: expected key=value, key>=N or key~lat,lon,radius", expr)
+	}
+	c := Condition{Key: strings.TrimSpace(expr[:i]), Op: expr[i : i+1]}
+	if (c.Op == "<" || c.Op == ">") && strings.HasPrefix(expr[i+1:], "=") {
+		c.Op += "="
+	}
+	c.Value = expr[i+len(c.Op):]
+	if c.Key == "" {
+		return Condition{}, fmt.Errorf("invalid filter %q: missing key", expr)
+	}
+
+	switch c.Op {
+	case "<", "<=", ">", ">=":
+		n, err := strconv.ParseFloat(strings.TrimSpace(c.Value), 64)
+		if err != nil {
+			return Condition{}, fmt.Errorf("invalid filter %q: %s needs a number", expr, c.Op)
+		}
+		c.num = n
+	case "~":
+		parts := strings.Split(c.Value, ",")
+		if len(parts) != 3 {
+			return Condition{}, fmt.Errorf("invalid filter %q: expected key~lat,lon,radius", expr)
+		}
+		lat, okLat := number(parts[0])
+		lon, okLon := number(parts[1])
+		if !okLat || !okLon {
+			return Condition{}, fmt.Errorf("invalid filter %q: expected key~lat,lon,radius", expr)
+		}
+		if err := checkLatLon(lat, lon); err != nil {
+			return Condition{}, fmt.Errorf("invalid filter %q: %w", expr, err)
+		}
+		r, err := parseRadius(parts[2])
+		if err != nil {
+			return Condition{}, fmt.Errorf("invalid filter %q: %w", expr, err)
+		}
+		c.lat, c.lon, c.radiusMtr = lat, lon, r
+	}
+	return c, nil
+}
+
+// Matches reports whether the payload satisfies the condition.
+func (c Condition) Matches(payload map[string]any) bool {
+	v, ok := payload[c.Key]
+	if !ok {
+		return false
+	}
+	if list, isList := v.([]any); isList {
+		for _, item := range list {
+			if c.matchValue(item) {
+				return true
+			}
+		}
+		return false
+	}
+	return c.matchValue(v)
+}
+
+// matchValue compares a single payload value against the condition value.
+func (c Condition) matchValue(v any) bool {
+	switch c.Op {
+	case "<", "<=", ">", ">=":
+		if _, isStr := v.(string); isStr {
+			return false
+		}
+		n, ok := number(v)
+		if !ok {
+			return false
+		}
+		switch c.Op {
+		case "<":
+			return n < c.num
+		case "<=":
+			return n <= c.num
+		case ">":
+			return n > c.num
+		default:
+			return n >= c.num
+		}
+	case "~":
+		lat, lon, ok := geoPoint(v)
+		return ok && distanceMeters(c.lat, c.lon, lat, lon) <= c.radiusMtr
+	}
+	got := fmt.Sprint(v)
+	if strings.HasSuffix(c.Value, "/") {
+		return strings.HasPrefix(got, c.Value)
+	}
+	return got == c.Value
+}
+
+// CheckSearchable reports whether the condition can be evaluated inside a
+// Qdrant search. Path-prefix matches can't: Qdrant has no prefix match on
+// keyword fields.
+func (c Condition) CheckSearchable() error {
+	if (c.Op == "=" || c.Op == "") && strings.HasSuffix(c.Value, "/") {
+		return fmt.Errorf("filter %s=%s: path-prefix filters are not supported in search", c.Key, c.Value)
+	}
+	return nil
+}
+
+// qdrantCondition translates the condition into a Qdrant condition. Equality
+// matches the value as a keyword, and also as an integer or bool when it
+// parses as one, since payload values keep their JSON types.
+func (c Condition) qdrantCondition() *qdrant.Condition {
+	switch c.Op {
+	case "<":
+		return qdrant.NewRange(c.Key, &qdrant.Range{Lt: &c.num})
+	case "<=":
+		return qdrant.NewRange(c.Key, &qdrant.Range{Lte: &c.num})
+	case ">":
+		return qdrant.NewRange(c.Key, &qdrant.Range{Gt: &c.num})
+	case ">=":
+		return qdrant.NewRange(c.Key, &qdrant.Range{Gte: &c.num})
+	case "~":
+		return qdrant.NewGeoRadius(c.Key, c.lat, c.lon, float32(c.radiusMtr))
+	}
+	either := []*qdrant.Condition{qdrant.NewMatchKeyword(c.Key, c.Value)}
+	if n, err := strconv.ParseInt(c.Value, 10, 64); err == nil {
+		either = append(either, qdrant.NewMatchInt(c.Key, n))
+	}
+	if b, err := strconv.ParseBool(c.Value); err == nil && (c.Value == "true" || c.Value == "false") {
+		either = append(either, qdrant.NewMatchBool(c.Key, b))
+	}
+	if len(either) == 1 {
+		return either[0]
+	}
+	return qdrant.NewFilterAsCondition(&qdrant.Filter{Should: either})
+}
+
+// Find returns every memory whose payload satisfies all conditions.
+// Like All, it does NOT update last_accessed.
+func (s *Store) Find(ctx context.Context, conds []Condition) ([]Result, error) {
+	all, err := s.All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := []Result{}
+	for _, r := range all {
+		if matchesAll(r.Payload, conds) {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+
+// matchesAll reports whether the payload satisfies every condition.
+func matchesAll(payload map[string]any, conds []Condition) bool {
+	for _, c := range conds {
+		if !c.Matches(payload) {
+			return false
+		}
+	}
+	return true
+}
+
+// setPayloadBatchSize caps how many SetPayload operations go into a single
+// UpdateBatch call, keeping individual gRPC messages reasonably small.
+const setPayloadBatchSize = 100
+
+// SetPayloads merges per-point payload updates into existing payloads using
+// batched Qdrant updates — one gRPC call per setPayloadBatchSize points rather
+// than one per point. Keys not present in an update are left untouched.
+func (s *Store) SetPayloads(ctx context.Context, updates map[string]map[string]any) error {
+	if len(updates) == 0 {
+		return nil
+	}
+
+	// Sort IDs so batches are deterministic.
+	ids := make([]string, 0, len(updates))
+	for id := range updates {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	wait := true
+	for start := 0; start < len(ids); start += setPayloadBatchSize {
+		end := min(start+setPayloadBatchSize, len(ids))
+		ops := make([]*qdrant.PointsUpdateOperation, 0, end-start)
+		for _, id := range ids[start:end] {
+			ops = append(ops, qdrant.NewPointsUpdateSetPayload(&qdrant.PointsUpdateOperation_SetPayload{
+				Payload:        qdrant.NewValueMap(updates[id]),
+				PointsSelector: s.selector(qdrant.NewIDUUID(id)),
+			}))
+		}
+		_, err := s.client.UpdateBatch(ctx, &qdrant.UpdateBatchPoints{
+			CollectionName: s.collection,
+			Wait:           &wait,
+			Operations:     ops,
+		})
+		if err != nil {
+			return fmt.Errorf("update batch: %w", err)
+		}
+	}
+	s.changed(ctx)
+	return nil
+}
+
+// Tags returns the tags stored in a payload's "tags" array.
+func Tags(payload map[string]any) []string {
+	list, _ := payload["tags"].([]any)
+	tags := make([]string, 0, len(list))
+	for _, item := range list {
+		if t, ok := item.(string); ok && t != "" {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+// WithTags returns the union of existing and added tags, preserving order.
+func WithTags(existing []string, add ...string) []string {
+	seen := make(map[string]bool, len(existing)+len(add))
+	out := make([]string, 0, len(existing)+len(add))
+	for _, t := range append(append([]string{}, existing...), add...) {
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		out = append(out, t)
+	}
+	return out
+}
+
+// WithoutTags returns existing tags minus the removed ones.
+func WithoutTags(existing []string, remove ...string) []string {
+	drop := make(map[string]bool, len(remove))
+	for _, t := range remove {
+		drop[t] = true
+	}
+	out := make([]string, 0, len(existing))
+	for _, t := range existing {
+		if !drop[t] {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// TagsValue converts tags into the []any form Qdrant payloads require.
+func TagsValue(tags []string) []any {
+	out := make([]any, len(tags))
+	for i, t := range tags {
+		out[i] = t
+	}
+	return out
+}
+
+// Attribution fields record who a memory came from: author is who wrote it
+// down, speaker is who said it (a turn in a transcript). Names are stored
+// normalized so a filter matches regardless of how they were capitalized.
+const (
+	AuthorField  = "author"
+	SpeakerField = "speaker"
+)
+
+// NormalizeName returns the stored form of an author or speaker name:
+// lowercase, with runs of whitespace collapsed to one space.
+func NormalizeName(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// NormalizeAttribution normalizes the author and speaker fields of a payload
+// in place. An empty name removes the field; a non-string one is an error.
+func NormalizeAttribution(payload map[string]any) error {
+	for _, field := range []string{AuthorField, SpeakerField} {
+		v, ok := payload[field]
+		if !ok || v == nil {
+			continue
+		}
+		name, isStr := v.(string)
+		if !isStr {
+			return fmt.Errorf("field %q must be a string, got %v", field, v)
+		}
+		if name = NormalizeName(name); name == "" {
+			delete(payload, field)
+			continue
+		}
+		payload[field] = name
+	}
+	return nil
+}
+
+// RemindNextField is the payload field holding when a memory's reminder is
+// next due, as an RFC 3339 timestamp.
+const RemindNextField = "remind_next"
+
+// Due returns memories whose reminder is due at or before now, soonest first.
+// Reading them does not count as an access.
+func (s *Store) Due(ctx context.Context, now time.Time) ([]Result, error) {
+	exists, err := s.client.CollectionExists(ctx, s.collection)
+	if err != nil {
+		return nil, fmt.Errorf("check collection: %w", err)
+	}
+	if !exists {
+		return []Result{}, nil
+	}
+
+	results, err := s.scrollPoints(ctx, &qdrant.Filter{
+		Must: []*qdrant.Condition{
+			qdrant.NewDatetimeRange(RemindNextField, &qdrant.DatetimeRange{
+				Lte: timestamppb.New(now),
+			}),
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scroll due: %w", err)
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		a, _ := results[i].Payload[RemindNextField].(string)
+		b, _ := results[j].Payload[RemindNextField].(string)
+		return a < b
+	})
+	return results, nil
+}
+
+// DeletePayloadKeys removes keys from the payloads of the given memories.
+func (s *Store) DeletePayloadKeys(ctx context.Context, ids []string, keys ...string) error {
+	if len(ids) == 0 || len(keys) == 0 {
+		return nil
+	}
+	pointIDs := make([]*qdrant.PointId, len(ids))
+	for i, id := range ids {
+		pointIDs[i] = qdrant.NewIDUUID(id)
+	}
+	wait := true
+	_, err := s.client.DeletePayload(ctx, &qdrant.DeletePayloadPoints{
+		CollectionName: s.collection,
+		Wait:           &wait,
+		Keys:           keys,
+		PointsSelector: s.selector(pointIDs...),
+	})
+	if err != nil {
+		return fmt.Errorf("delete payload: %w", err)
+	}
+	s.changed(ctx)
+	return nil
+}
+
 // DeleteCollection deletes the memories collection entirely.
 // Used for testing and full resets. Returns nil if the collection doesn't exist.
 func (s *Store) DeleteCollection(ctx context.Context) error {
-	exists, err := s.client.CollectionExists(ctx, collectionName)
+	exists, err := s.client.CollectionExists(ctx, s.collection)
 	if err != nil {
 		return fmt.Errorf("check collection: %w", err)
 	}
 	if !exists {
 		return nil
 	}
-	return s.client.DeleteCollection(ctx, collectionName)
+	s.mu.Lock()
+	s.embedding = nil
+	s.mu.Unlock()
+	return s.client.DeleteCollection(ctx, s.collection)
 }
 
 // Count returns the approximate number of memories stored.
 func (s *Store) Count(ctx context.Context) (uint64, error) {
-	exists, err := s.client.CollectionExists(ctx, collectionName)
+	exists, err := s.client.CollectionExists(ctx, s.collection)
 	if err != nil {
 		return 0, fmt.Errorf("check collection: %w", err)
 	}
@@ -335,7 +1269,8 @@ func (s *Store) Count(ctx context.Context) (uint64, error) {
 	}
 
 	count, err := s.client.Count(ctx, &qdrant.CountPoints{
-		CollectionName: collectionName,
+		CollectionName: s.collection,
+		Filter:         s.scoped(nil),
 	})
 	if err != nil {
 		return 0, fmt.Errorf("count: %w", err)
@@ -343,6 +1278,25 @@ func (s *Store) Count(ctx context.Context) (uint64, error) {
 	return count, nil
 }
 
+// Ping opens the gRPC connection and asks Qdrant for its health status,
+// without touching any collection.
+func (s *Store) Ping(ctx context.Context) error {
+	if _, err := s.client.HealthCheck(ctx); err != nil {
+		return fmt.Errorf("health check: %w", err)
+	}
+	return nil
+}
+
+// ServerVersion returns the version of the Qdrant server. The file and
+// SQLite backends have none, and return "".
+func (s *Store) ServerVersion(ctx context.Context) (string, error) {
+	reply, err := s.client.HealthCheck(ctx)
+	if err != nil {
+		return "", fmt.Errorf("health check: %w", err)
+	}
+	return reply.GetVersion(), nil
+}
+
 // Check runs an end-to-end connectivity check against Qdrant.
 func (s *Store) Check(ctx context.Context) error {
 	checkCollection := "clawbrain_check"
@@ -408,30 +1362,6 @@ func (s *Store) Check(ctx context.Context) error {
 	return nil
 }
 
-// updateLastAccessed sets the last_accessed payload field on a point.
-// Errors are logged but not propagated — a failed timestamp update should
-// not cause a retrieval to fail.
-func (s *Store) updateLastAccessed(ctx context.Context, id *qdrant.PointId, timestamp string) {
-	wait := true
-	_, err := s.client.SetPayload(ctx, &qdrant.SetPayloadPoints{
-		CollectionName: collectionName,
-		Wait:           &wait,
-		Payload: qdrant.NewValueMap(map[string]any{
-			"last_accessed": timestamp, // RFC3339Nano for sub-second precision
-		}),
-		PointsSelector: &qdrant.PointsSelector{
-			PointsSelectorOneOf: &qdrant.PointsSelector_Points{
-				Points: &qdrant.PointsIdsList{
-					Ids: []*qdrant.PointId{id},
-				},
-			},
-		},
-	})
-	if err != nil {
-		log.Printf("warning: failed to update last_accessed on %v: %v", pointIDToString(id), err)
-	}
-}
-
 // scrollPointIDs scrolls through memories with a filter and returns all matching point IDs.
 func (s *Store) scrollPointIDs(ctx context.Context, filter *qdrant.Filter) ([]*qdrant.PointId, error) {
 	var allIDs []*qdrant.PointId
@@ -440,8 +1370,8 @@ func (s *Store) scrollPointIDs(ctx context.Context, filter *qdrant.Filter) ([]*q
 
 	for {
 		points, nextOffset, err := s.client.ScrollAndOffset(ctx, &qdrant.ScrollPoints{
-			CollectionName: collectionName,
-			Filter:         filter,
+			CollectionName: s.collection,
+			Filter:         s.scoped(filter),
 			Limit:          &limit,
 			Offset:         offset,
 			WithPayload:    qdrant.NewWithPayload(false),
@@ -464,6 +1394,42 @@ func (s *Store) scrollPointIDs(ctx context.Context, filter *qdrant.Filter) ([]*q
 	return allIDs, nil
 }
 
+// scrollPoints scrolls through memories with a filter and returns all matching
+// points with their payloads. A nil filter matches every point.
+func (s *Store) scrollPoints(ctx context.Context, filter *qdrant.Filter) ([]Result, error) {
+	out := []Result{}
+	var offset *qdrant.PointId
+	limit := uint32(100)
+
+	for {
+		points, nextOffset, err := s.client.ScrollAndOffset(ctx, &qdrant.ScrollPoints{
+			CollectionName: s.collection,
+			Filter:         s.scoped(filter),
+			Limit:          &limit,
+			Offset:         offset,
+			WithPayload:    qdrant.NewWithPayload(true),
+			WithVectors:    qdrant.NewWithVectors(false),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, point := range points {
+			out = append(out, Result{
+				ID:      pointIDToString(point.Id),
+				Payload: valueMapToGoMap(point.Payload),
+			})
+		}
+
+		if nextOffset == nil {
+			break
+		}
+		offset = nextOffset
+	}
+
+	return out, nil
+}
+
 // pointIDToString converts a Qdrant PointId to its string representation.
 func pointIDToString(id *qdrant.PointId) string {
 	switch v := id.GetPointIdOptions().(type) {
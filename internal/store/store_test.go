@@ -2,6 +2,7 @@ package store
 
 import (
 	"context"
+	"strings"
 	"testing"
 	"time"
 
@@ -55,6 +56,18 @@ func TestCheck(t *testing.T) {
 	}
 }
 
+func TestPing(t *testing.T) {
+	s := testStore(t)
+	defer s.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := s.Ping(ctx); err != nil {
+		t.Fatalf("Ping failed: %v", err)
+	}
+}
+
 func TestAdd(t *testing.T) {
 	s := testStore(t)
 	defer s.Close()
@@ -441,6 +454,43 @@ func TestAddUpsertBehavior(t *testing.T) {
 	}
 }
 
+func TestAddBatch(t *testing.T) {
+	s := testStore(t)
+	defer s.Close()
+	defer cleanupMemories(t, s)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	fixedID := "11111111-2222-3333-4444-555555555555"
+	ids, err := s.AddBatch(ctx, []Point{
+		{Vector: []float32{0.1, 0.2, 0.3, 0.4}, Payload: map[string]any{"text": "first"}},
+		{ID: fixedID, Vector: []float32{0.4, 0.3, 0.2, 0.1}, Payload: map[string]any{"text": "second"}},
+	})
+	if err != nil {
+		t.Fatalf("AddBatch failed: %v", err)
+	}
+	if len(ids) != 2 || ids[0] == "" || ids[1] != fixedID {
+		t.Fatalf("unexpected IDs %v", ids)
+	}
+
+	got, err := s.Peek(ctx, ids[0])
+	if err != nil || got == nil {
+		t.Fatalf("Peek failed: %v", err)
+	}
+	if got.Payload["text"] != "first" || got.Payload["created_at"] == nil || Revision(got.Payload) != 1 {
+		t.Errorf("expected a stamped payload, got %v", got.Payload)
+	}
+
+	_, err = s.AddBatch(ctx, []Point{
+		{Vector: []float32{0.1, 0.2, 0.3, 0.4}, Payload: map[string]any{"text": "a"}},
+		{Vector: []float32{0.1, 0.2}, Payload: map[string]any{"text": "b"}},
+	})
+	if err == nil {
+		t.Error("expected mixed vector sizes to be rejected")
+	}
+}
+
 func TestForgetIdempotent(t *testing.T) {
 	s := testStore(t)
 	defer s.Close()
@@ -800,6 +850,323 @@ func TestFindSimilar(t *testing.T) {
 
 // --- Unit Tests for helper functions ---
 
+func TestFindSimilarFiltered(t *testing.T) {
+	s := testStore(t)
+	defer s.Close()
+	cleanupMemories(t, s)
+	defer cleanupMemories(t, s)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	vec := []float32{0.1, 0.2, 0.3, 0.4}
+	for _, p := range []map[string]any{
+		{"text": "todo a", "type": "todo"},
+		{"text": "todo b", "type": "todo"},
+		{"text": "lesson", "type": "lesson"},
+		{"text": "untyped"},
+	} {
+		if _, err := s.Add(ctx, "", vec, p); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+
+	texts := func(f Filter) map[string]bool {
+		t.Helper()
+		results, err := s.FindSimilarFiltered(ctx, vec, 0, 10, f)
+		if err != nil {
+			t.Fatalf("FindSimilarFiltered(%+v) failed: %v", f, err)
+		}
+		out := map[string]bool{}
+		for _, r := range results {
+			out[r.Payload["text"].(string)] = true
+		}
+		return out
+	}
+
+	if got := texts(Filter{}); len(got) != 4 {
+		t.Errorf("zero filter should match everything, got %v", got)
+	}
+	if got := texts(Filter{Types: []string{"todo"}}); len(got) != 2 || !got["todo a"] {
+		t.Errorf("expected only todos, got %v", got)
+	}
+	if got := texts(Filter{Types: []string{"lesson", ""}}); len(got) != 2 || !got["lesson"] || !got["untyped"] {
+		t.Errorf("expected lesson and untyped, got %v", got)
+	}
+	if got := texts(Filter{ExcludeTypes: []string{"todo", ""}}); len(got) != 1 || !got["lesson"] {
+		t.Errorf("expected only lesson after exclusions, got %v", got)
+	}
+	if got := texts(Filter{TextContains: []string{"todo b", "less"}}); len(got) != 2 || !got["todo b"] || !got["lesson"] {
+		t.Errorf("expected substring matches todo b and lesson, got %v", got)
+	}
+	if got := texts(Filter{Types: []string{"todo"}, TextContains: []string{"lesson"}}); len(got) != 0 {
+		t.Errorf("filters must be ANDed, got %v", got)
+	}
+	if !(Filter{}).Empty() || (Filter{Types: []string{"todo"}}).Empty() {
+		t.Error("unexpected Empty result")
+	}
+}
+
+func TestAll(t *testing.T) {
+	s := testStore(t)
+	defer s.Close()
+	cleanupMemories(t, s)
+	defer cleanupMemories(t, s)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	t.Run("empty when collection does not exist", func(t *testing.T) {
+		results, err := s.All(ctx)
+		if err != nil {
+			t.Fatalf("All failed: %v", err)
+		}
+		if results == nil || len(results) != 0 {
+			t.Fatalf("expected non-nil empty slice, got %v", results)
+		}
+	})
+
+	id, err := s.Add(ctx, "", []float32{0.1, 0.2, 0.3, 0.4}, map[string]any{"text": "listed"})
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	before, err := s.Get(ctx, id)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+
+	t.Run("returns payloads without touching last_accessed", func(t *testing.T) {
+		time.Sleep(10 * time.Millisecond)
+		results, err := s.All(ctx)
+		if err != nil {
+			t.Fatalf("All failed: %v", err)
+		}
+		if len(results) != 1 || results[0].ID != id {
+			t.Fatalf("expected the single stored memory, got %+v", results)
+		}
+		if results[0].Payload["text"] != "listed" {
+			t.Errorf("expected payload text 'listed', got %v", results[0].Payload["text"])
+		}
+		if results[0].Payload["last_accessed"] != before.Payload["last_accessed"] {
+			t.Errorf("All must not update last_accessed")
+		}
+	})
+}
+
+func TestSetPayloadsAndFind(t *testing.T) {
+	s := testStore(t)
+	defer s.Close()
+	cleanupMemories(t, s)
+	defer cleanupMemories(t, s)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	oldID, err := s.Add(ctx, "", []float32{0.1, 0.2, 0.3, 0.4}, map[string]any{"text": "old", "source": "/old/notes/a.md"})
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	_, err = s.Add(ctx, "", []float32{0.5, 0.6, 0.7, 0.8}, map[string]any{"text": "new", "source": "/new/notes/b.md"})
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	found, err := s.Find(ctx, []Condition{{Key: "source", Value: "/old/notes/"}})
+	if err != nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+	if len(found) != 1 || found[0].ID != oldID {
+		t.Fatalf("expected only the /old/notes/ memory, got %+v", found)
+	}
+
+	err = s.SetPayloads(ctx, map[string]map[string]any{
+		oldID: {"tags": TagsValue([]string{"obsolete"})},
+	})
+	if err != nil {
+		t.Fatalf("SetPayloads failed: %v", err)
+	}
+
+	tagged, err := s.Find(ctx, []Condition{{Key: "tags", Value: "obsolete"}})
+	if err != nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+	if len(tagged) != 1 || tagged[0].ID != oldID {
+		t.Fatalf("expected tagged memory, got %+v", tagged)
+	}
+	// SetPayloads merges — existing fields must survive.
+	if tagged[0].Payload["text"] != "old" {
+		t.Errorf("expected text to survive payload merge, got %v", tagged[0].Payload["text"])
+	}
+}
+
+func TestValidateAlias(t *testing.T) {
+	valid := []string{"deploy-checklist", "team.oncall_v2", "A1"}
+	for _, a := range valid {
+		if err := ValidateAlias(a); err != nil {
+			t.Errorf("ValidateAlias(%q) = %v, want nil", a, err)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"has space",
+		"slash/name",
+		"11111111-2222-3333-4444-555555555555",
+		strings.Repeat("a", maxAliasLength+1),
+	}
+	for _, a := range invalid {
+		if err := ValidateAlias(a); err == nil {
+			t.Errorf("ValidateAlias(%q) = nil, want error", a)
+		}
+	}
+}
+
+func TestNormalizeAttribution(t *testing.T) {
+	payload := map[string]any{"author": "  Lico ", "speaker": "Dr.  Jane\tDoe", "text": "Keep"}
+	if err := NormalizeAttribution(payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload["author"] != "lico" || payload["speaker"] != "dr. jane doe" {
+		t.Errorf("unexpected attribution: %v", payload)
+	}
+	if payload["text"] != "Keep" {
+		t.Error("expected other fields untouched")
+	}
+
+	blank := map[string]any{"author": "   "}
+	if err := NormalizeAttribution(blank); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := blank["author"]; ok {
+		t.Error("expected blank author to be removed")
+	}
+
+	if err := NormalizeAttribution(map[string]any{"speaker": 3.0}); err == nil {
+		t.Error("expected error for non-string speaker")
+	}
+}
+
+func TestResolveAndReleaseAlias(t *testing.T) {
+	s := testStore(t)
+	defer s.Close()
+	cleanupMemories(t, s)
+	defer cleanupMemories(t, s)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// No collection yet: nothing resolves, nothing to release.
+	id, err := s.ResolveAlias(ctx, "deploy-checklist")
+	if err != nil || id != "" {
+		t.Fatalf("expected empty resolve on missing collection, got %q, %v", id, err)
+	}
+
+	holder, err := s.Add(ctx, "", []float32{0.1, 0.2, 0.3, 0.4}, map[string]any{"text": "checklist", "alias": "deploy-checklist"})
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	id, err = s.ResolveAlias(ctx, "deploy-checklist")
+	if err != nil {
+		t.Fatalf("ResolveAlias failed: %v", err)
+	}
+	if id != holder {
+		t.Fatalf("expected alias to resolve to %s, got %q", holder, id)
+	}
+
+	released, err := s.ReleaseAlias(ctx, "deploy-checklist")
+	if err != nil {
+		t.Fatalf("ReleaseAlias failed: %v", err)
+	}
+	if len(released) != 1 || released[0] != holder {
+		t.Fatalf("expected alias released from %s, got %v", holder, released)
+	}
+
+	id, err = s.ResolveAlias(ctx, "deploy-checklist")
+	if err != nil || id != "" {
+		t.Fatalf("expected alias to be gone after release, got %q, %v", id, err)
+	}
+
+	// Releasing only drops the alias; the memory itself stays.
+	r, err := s.Get(ctx, holder)
+	if err != nil || r == nil {
+		t.Fatalf("expected memory to survive alias release, got %v, %v", r, err)
+	}
+	if _, ok := r.Payload["alias"]; ok {
+		t.Errorf("expected alias field removed, got %v", r.Payload["alias"])
+	}
+}
+
+func TestParseCondition(t *testing.T) {
+	c, err := ParseCondition("source=/old/notes/")
+	if err != nil {
+		t.Fatalf("ParseCondition failed: %v", err)
+	}
+	if c.Key != "source" || c.Value != "/old/notes/" {
+		t.Errorf("unexpected condition: %+v", c)
+	}
+
+	// Only the first = separates key from value.
+	c, err = ParseCondition("text=a=b")
+	if err != nil || c.Value != "a=b" {
+		t.Errorf("expected value a=b, got %+v (%v)", c, err)
+	}
+
+	for _, bad := range []string{"source", "=value", ""} {
+		if _, err := ParseCondition(bad); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestConditionMatches(t *testing.T) {
+	payload := map[string]any{
+		"source": "/old/notes/a.md",
+		"pinned": true,
+		"tags":   []any{"project:x", "obsolete"},
+	}
+
+	tests := []struct {
+		cond Condition
+		want bool
+	}{
+		{Condition{Key: "source", Value: "/old/notes/a.md"}, true},
+		{Condition{Key: "source", Value: "/old/notes/"}, true},
+		{Condition{Key: "source", Value: "/old/"}, true},
+		{Condition{Key: "source", Value: "/old/notes"}, false},
+		{Condition{Key: "source", Value: "/new/"}, false},
+		{Condition{Key: "pinned", Value: "true"}, true},
+		{Condition{Key: "tags", Value: "obsolete"}, true},
+		{Condition{Key: "tags", Value: "missing"}, false},
+		{Condition{Key: "type", Value: "todo"}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.cond.Matches(payload); got != tt.want {
+			t.Errorf("%+v.Matches = %v, want %v", tt.cond, got, tt.want)
+		}
+	}
+}
+
+func TestTagHelpers(t *testing.T) {
+	payload := map[string]any{"tags": []any{"a", "b", 3}}
+	if got := Tags(payload); len(got) != 2 || got[0] != "a" || got[1] != "b" {
+		t.Errorf("Tags = %v, want [a b]", got)
+	}
+	if got := Tags(map[string]any{}); got == nil || len(got) != 0 {
+		t.Errorf("Tags of untagged payload = %v, want empty", got)
+	}
+
+	if got := WithTags([]string{"a", "b"}, "b", "c"); len(got) != 3 || got[2] != "c" {
+		t.Errorf("WithTags = %v, want [a b c]", got)
+	}
+	if got := WithoutTags([]string{"a", "b", "c"}, "b", "x"); len(got) != 2 || got[1] != "c" {
+		t.Errorf("WithoutTags = %v, want [a c]", got)
+	}
+	if got := TagsValue([]string{"a"}); len(got) != 1 || got[0] != "a" {
+		t.Errorf("TagsValue = %v, want [a]", got)
+	}
+}
+
 func TestPointIDToString(t *testing.T) {
 	t.Run("UUID", func(t *testing.T) {
 		id := qdrant.NewIDUUID("550e8400-e29b-41d4-a716-446655440000")
@@ -958,3 +1325,101 @@ func TestForgetSkipsPinnedMemories(t *testing.T) {
 		t.Errorf("expected pinned memory text, got %v", result.Payload["text"])
 	}
 }
+
+func TestForgetSkipsLockedMemories(t *testing.T) {
+	s := testStore(t)
+	defer s.Close()
+	defer cleanupMemories(t, s)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	lockedID, err := s.Add(ctx, "", []float32{0.1, 0.2, 0.3, 0.4}, map[string]any{
+		"text":   "never delete production data",
+		"locked": true,
+	})
+	if err != nil {
+		t.Fatalf("Add locked failed: %v", err)
+	}
+
+	time.Sleep(1100 * time.Millisecond)
+
+	deleted, err := s.Forget(ctx, 1*time.Second)
+	if err != nil {
+		t.Fatalf("Forget failed: %v", err)
+	}
+	if deleted != 0 {
+		t.Fatalf("expected locked memory to survive forget, got %d deletions", deleted)
+	}
+
+	// Peek must not count as an access.
+	before, err := s.Peek(ctx, lockedID)
+	if err != nil || before == nil {
+		t.Fatalf("Peek failed: %v, %v", before, err)
+	}
+	after, err := s.Peek(ctx, lockedID)
+	if err != nil || after == nil {
+		t.Fatalf("Peek failed: %v, %v", after, err)
+	}
+	if before.Payload["last_accessed"] != after.Payload["last_accessed"] {
+		t.Errorf("Peek updated last_accessed: %v -> %v", before.Payload["last_accessed"], after.Payload["last_accessed"])
+	}
+	if !IsLocked(after.Payload) {
+		t.Error("expected IsLocked to report the locked payload")
+	}
+}
+
+func TestDueAndDeletePayloadKeys(t *testing.T) {
+	s := testStore(t)
+	defer s.Close()
+	cleanupMemories(t, s)
+	defer cleanupMemories(t, s)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	now := time.Now().UTC()
+	due, err := s.Due(ctx, now)
+	if err != nil || len(due) != 0 {
+		t.Fatalf("expected no due reminders on missing collection, got %v, %v", due, err)
+	}
+
+	add := func(text, next string) string {
+		t.Helper()
+		payload := map[string]any{"text": text}
+		if next != "" {
+			payload[RemindNextField] = next
+		}
+		id, err := s.Add(ctx, "", []float32{0.1, 0.2, 0.3, 0.4}, payload)
+		if err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+		return id
+	}
+	later := add("later", now.Add(time.Hour).Format(time.RFC3339))
+	newer := add("newer", now.Add(-time.Hour).Format(time.RFC3339))
+	older := add("older", now.Add(-48*time.Hour).Format(time.RFC3339))
+	add("no reminder", "")
+
+	due, err = s.Due(ctx, now)
+	if err != nil {
+		t.Fatalf("Due failed: %v", err)
+	}
+	if len(due) != 2 || due[0].ID != older || due[1].ID != newer {
+		t.Fatalf("expected [older newer] due, got %v", due)
+	}
+
+	if err := s.DeletePayloadKeys(ctx, []string{older, newer}, RemindNextField); err != nil {
+		t.Fatalf("DeletePayloadKeys failed: %v", err)
+	}
+	due, err = s.Due(ctx, now.Add(2*time.Hour))
+	if err != nil {
+		t.Fatalf("Due failed: %v", err)
+	}
+	if len(due) != 1 || due[0].ID != later {
+		t.Fatalf("expected only the later reminder due, got %v", due)
+	}
+	if got, _ := s.Peek(ctx, older); got == nil || got.Payload["text"] != "older" || got.Payload[RemindNextField] != nil {
+		t.Errorf("expected reminder key removed and the rest kept, got %v", got)
+	}
+}
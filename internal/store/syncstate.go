@@ -0,0 +1,302 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hsk-coder/clawbrain/internal/redis"
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// SyncStateCollection holds the sync state of every namespace when it is
+// kept in the vector store rather than Redis. Like ColdSuffix, its name
+// holds a dot, so it is never taken for a namespace.
+const SyncStateCollection = DefaultCollection + ".syncstate"
+
+// Payload fields of a sync state entry.
+const (
+	stateKeyField     = "key"
+	stateValueField   = "value"
+	stateMembersField = "members"
+	stateExpiresField = "expires_at"
+)
+
+// syncStateNamespace derives the point ID of a sync state key.
+var syncStateNamespace = uuid.NewSHA1(uuid.NameSpaceURL, []byte("clawbrain:sync-state"))
+
+// SyncState keeps sync's record of the files it ingested as payloads in
+// SyncStateCollection, one point per key, so a setup with a vector store
+// needs nothing else to sync. It answers the Redis commands sync uses
+// (see sync.State); expired keys are skipped when read rather than
+// removed. Its calls run under the context it was made with.
+type SyncState struct {
+	ctx    context.Context
+	client Backend
+}
+
+// SyncState returns the sync state kept in the store's backend, used under
+// ctx. It shares the store's connection; close the store, not the state.
+func (s *Store) SyncState(ctx context.Context) *SyncState {
+	return &SyncState{ctx: ctx, client: s.client}
+}
+
+// stateEntry is one key of a SyncState.
+type stateEntry struct {
+	value   string
+	members []string
+	expires int64 // Unix seconds; 0 for never
+}
+
+func statePointID(key string) *qdrant.PointId {
+	return qdrant.NewIDUUID(uuid.NewSHA1(syncStateNamespace, []byte(key)).String())
+}
+
+// Close releases nothing; it is there to satisfy sync.State.
+func (st *SyncState) Close() error {
+	return nil
+}
+
+// entryFromPayload reads an entry, or reports false for one that expired.
+func entryFromPayload(payload map[string]any) (string, stateEntry, bool) {
+	key, _ := payload[stateKeyField].(string)
+	e := stateEntry{}
+	e.value, _ = payload[stateValueField].(string)
+	if members, ok := payload[stateMembersField].([]any); ok {
+		for _, m := range members {
+			if m, ok := m.(string); ok {
+				e.members = append(e.members, m)
+			}
+		}
+	}
+	if expires, ok := payload[stateExpiresField].(int64); ok {
+		e.expires = expires
+	}
+	if e.expires != 0 && time.Now().Unix() >= e.expires {
+		return key, e, false
+	}
+	return key, e, true
+}
+
+// get returns the entry at key, or false if it isn't set.
+func (st *SyncState) get(key string) (stateEntry, bool, error) {
+	exists, err := st.client.CollectionExists(st.ctx, SyncStateCollection)
+	if err != nil || !exists {
+		return stateEntry{}, false, err
+	}
+	points, err := st.client.Get(st.ctx, &qdrant.GetPoints{
+		CollectionName: SyncStateCollection,
+		Ids:            []*qdrant.PointId{statePointID(key)},
+		WithPayload:    qdrant.NewWithPayload(true),
+	})
+	if err != nil {
+		return stateEntry{}, false, fmt.Errorf("get %s: %w", key, err)
+	}
+	if len(points) == 0 {
+		return stateEntry{}, false, nil
+	}
+	if _, e, ok := entryFromPayload(valueMapToGoMap(points[0].Payload)); ok {
+		return e, true, nil
+	}
+	return stateEntry{}, false, nil
+}
+
+// put writes the entry at key, creating the collection on first use.
+func (st *SyncState) put(key string, e stateEntry) error {
+	exists, err := st.client.CollectionExists(st.ctx, SyncStateCollection)
+	if err != nil {
+		return fmt.Errorf("check collection: %w", err)
+	}
+	if !exists {
+		// The points carry no meaning in their vectors; one dimension is
+		// the least a collection can have.
+		err := st.client.CreateCollection(st.ctx, &qdrant.CreateCollection{
+			CollectionName: SyncStateCollection,
+			VectorsConfig: qdrant.NewVectorsConfig(&qdrant.VectorParams{
+				Size:     1,
+				Distance: qdrant.Distance_Dot,
+			}),
+		})
+		if err != nil {
+			return fmt.Errorf("create collection: %w", err)
+		}
+	}
+	payload := map[string]any{stateKeyField: key, stateValueField: e.value}
+	if len(e.members) > 0 {
+		members := make([]any, len(e.members))
+		for i, m := range e.members {
+			members[i] = m
+		}
+		payload[stateMembersField] = members
+	}
+	if e.expires != 0 {
+		payload[stateExpiresField] = e.expires
+	}
+	wait := true
+	_, err = st.client.Upsert(st.ctx, &qdrant.UpsertPoints{
+		CollectionName: SyncStateCollection,
+		Wait:           &wait,
+		Points: []*qdrant.PointStruct{{
+			Id:      statePointID(key),
+			Vectors: qdrant.NewVectors(1),
+			Payload: qdrant.NewValueMap(payload),
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("set %s: %w", key, err)
+	}
+	return nil
+}
+
+// Get returns the value of key, or false if it isn't set.
+func (st *SyncState) Get(key string) (string, bool, error) {
+	e, ok, err := st.get(key)
+	return e.value, ok, err
+}
+
+// Exists reports whether key is set.
+func (st *SyncState) Exists(key string) (bool, error) {
+	_, ok, err := st.get(key)
+	return ok, err
+}
+
+// Set sets key to value with no expiry.
+func (st *SyncState) Set(key, value string) error {
+	return st.put(key, stateEntry{value: value})
+}
+
+// SetWithTTL sets key to value for ttlSeconds.
+func (st *SyncState) SetWithTTL(key, value string, ttlSeconds int) error {
+	return st.put(key, stateEntry{value: value, expires: time.Now().Unix() + int64(ttlSeconds)})
+}
+
+// SetNX sets key to value for ttlSeconds only if it isn't set, and reports
+// whether it was. Unlike Redis's, it isn't atomic: two processes that both
+// read the key unset before either writes it both think they set it.
+func (st *SyncState) SetNX(key, value string, ttlSeconds int) (bool, error) {
+	if _, ok, err := st.get(key); err != nil || ok {
+		return false, err
+	}
+	err := st.put(key, stateEntry{value: value, expires: time.Now().Unix() + int64(ttlSeconds)})
+	return err == nil, err
+}
+
+// Expire makes key expire in ttlSeconds. A key that isn't set is left so.
+func (st *SyncState) Expire(key string, ttlSeconds int) error {
+	e, ok, err := st.get(key)
+	if err != nil || !ok {
+		return err
+	}
+	e.expires = time.Now().Unix() + int64(ttlSeconds)
+	return st.put(key, e)
+}
+
+// Rename moves key to newKey, keeping its value and expiry. Renaming a key
+// that isn't set is an error, as in Redis.
+func (st *SyncState) Rename(key, newKey string) error {
+	e, ok, err := st.get(key)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("rename %s: no such key", key)
+	}
+	if err := st.put(newKey, e); err != nil {
+		return err
+	}
+	_, err = st.Del(key)
+	return err
+}
+
+// Del deletes keys and returns how many were set.
+func (st *SyncState) Del(keys ...string) (int, error) {
+	var ids []*qdrant.PointId
+	for _, key := range keys {
+		_, ok, err := st.get(key)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			ids = append(ids, statePointID(key))
+		}
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	wait := true
+	_, err := st.client.Delete(st.ctx, &qdrant.DeletePoints{
+		CollectionName: SyncStateCollection,
+		Wait:           &wait,
+		Points:         qdrant.NewPointsSelector(ids...),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("delete: %w", err)
+	}
+	return len(ids), nil
+}
+
+// Scan returns the keys matching a Redis glob pattern (see redis.Match),
+// sorted.
+func (st *SyncState) Scan(pattern string) ([]string, error) {
+	exists, err := st.client.CollectionExists(st.ctx, SyncStateCollection)
+	if err != nil || !exists {
+		return nil, err
+	}
+	var keys []string
+	var offset *qdrant.PointId
+	limit := uint32(100)
+	for {
+		points, next, err := st.client.ScrollAndOffset(st.ctx, &qdrant.ScrollPoints{
+			CollectionName: SyncStateCollection,
+			Limit:          &limit,
+			Offset:         offset,
+			WithPayload:    qdrant.NewWithPayload(true),
+			WithVectors:    qdrant.NewWithVectors(false),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("scroll: %w", err)
+		}
+		for _, point := range points {
+			if key, _, ok := entryFromPayload(valueMapToGoMap(point.Payload)); ok && redis.Match(pattern, key) {
+				keys = append(keys, key)
+			}
+		}
+		if next == nil {
+			break
+		}
+		offset = next
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// SAdd adds members to the set at key.
+func (st *SyncState) SAdd(key string, members ...string) error {
+	if len(members) == 0 {
+		return nil
+	}
+	e, _, err := st.get(key)
+	if err != nil {
+		return err
+	}
+	seen := make(map[string]bool, len(e.members))
+	for _, m := range e.members {
+		seen[m] = true
+	}
+	for _, m := range members {
+		if !seen[m] {
+			seen[m] = true
+			e.members = append(e.members, m)
+		}
+	}
+	return st.put(key, e)
+}
+
+// SMembers returns the members of the set at key; a key that isn't set is
+// an empty set.
+func (st *SyncState) SMembers(key string) ([]string, error) {
+	e, _, err := st.get(key)
+	return e.members, err
+}
@@ -0,0 +1,77 @@
+package store
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSyncState(t *testing.T) {
+	s, _ := fileStore(t)
+	defer s.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	st := s.SyncState(ctx)
+
+	if keys, err := st.Scan("sync:*"); err != nil || keys != nil {
+		t.Fatalf("expected no keys before the first write, got %v (err=%v)", keys, err)
+	}
+	if err := st.Set("sync:/a.md", "1"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := st.SetWithTTL("sync:/old.md", "1", -1); err != nil {
+		t.Fatalf("SetWithTTL failed: %v", err)
+	}
+	if err := st.SetWithTTL("sync@work:/b.md", "hash", 60); err != nil {
+		t.Fatalf("SetWithTTL failed: %v", err)
+	}
+	if value, found, _ := st.Get("sync@work:/b.md"); !found || value != "hash" {
+		t.Errorf("expected the hash back, got %q, %v", value, found)
+	}
+	if exists, _ := st.Exists("sync:/old.md"); exists {
+		t.Error("expected an expired key to be gone")
+	}
+	if keys, _ := st.Scan("sync:*"); !slices.Equal(keys, []string{"sync:/a.md"}) {
+		t.Errorf("expected only the default namespace's live key, got %v", keys)
+	}
+
+	if err := st.Rename("sync:/a.md", "sync:/c.md"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	if keys, _ := st.Scan("sync:*"); !slices.Equal(keys, []string{"sync:/c.md"}) {
+		t.Errorf("expected the renamed key, got %v", keys)
+	}
+
+	if err := st.SAdd("syncrun:done", "/a.md", "/b.md", "/a.md"); err != nil {
+		t.Fatalf("SAdd failed: %v", err)
+	}
+	if members, _ := st.SMembers("syncrun:done"); !slices.Equal(members, []string{"/a.md", "/b.md"}) {
+		t.Errorf("expected two members, got %v", members)
+	}
+	if n, err := st.Del("syncrun:done", "sync:/c.md", "sync:/none.md"); err != nil || n != 2 {
+		t.Errorf("expected 2 keys deleted, got %d (err=%v)", n, err)
+	}
+
+	if set, err := st.SetNX("lock", "a", 60); err != nil || !set {
+		t.Fatalf("expected SetNX to take a free key, got %v (err=%v)", set, err)
+	}
+	if set, _ := st.SetNX("lock", "b", 60); set {
+		t.Error("expected SetNX to leave a held key")
+	}
+	if value, _, _ := st.Get("lock"); value != "a" {
+		t.Errorf("expected the first value kept, got %q", value)
+	}
+	st.Del("lock")
+
+	// The state is no namespace.
+	namespaces, err := s.Namespaces(ctx)
+	if err != nil {
+		t.Fatalf("Namespaces failed: %v", err)
+	}
+	for _, ns := range namespaces {
+		if ns.Name != "" {
+			t.Errorf("expected the sync state not to be listed as a namespace, got %v", namespaces)
+		}
+	}
+}
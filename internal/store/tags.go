@@ -0,0 +1,94 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// TagsField is the payload field holding a memory's tags, an array of
+// strings. It is indexed, so search --tag is a filter inside Qdrant and the
+// tags command can count them without reading every memory.
+const TagsField = "tags"
+
+// tagFacetLimit caps how many distinct tags TagCounts asks Qdrant for. A
+// collection with more has stopped using tags as categories.
+const tagFacetLimit = 10000
+
+// TagCount is a tag and how many memories carry it.
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count uint64 `json:"count"`
+}
+
+// TagCounts returns every tag on the memories matching the filter with the
+// number of memories carrying it, most used first, ties by name. Like All,
+// it does NOT update last_accessed.
+func (s *Store) TagCounts(ctx context.Context, filter Filter) ([]TagCount, error) {
+	exists, err := s.client.CollectionExists(ctx, s.collection)
+	if err != nil {
+		return nil, fmt.Errorf("check collection: %w", err)
+	}
+	if !exists {
+		return nil, nil
+	}
+	if err := s.ensureTagsIndex(ctx); err != nil {
+		return nil, err
+	}
+
+	limit, exact := uint64(tagFacetLimit), true
+	hits, err := s.client.Facet(ctx, &qdrant.FacetCounts{
+		CollectionName: s.collection,
+		Key:            TagsField,
+		Filter:         s.scoped(filter.qdrantFilter()),
+		Limit:          &limit,
+		Exact:          &exact,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("facet tags: %w", err)
+	}
+	out := make([]TagCount, 0, len(hits))
+	for _, hit := range hits {
+		if tag := hit.GetValue().GetStringValue(); tag != "" {
+			out = append(out, TagCount{Tag: tag, Count: hit.GetCount()})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Tag < out[j].Tag
+	})
+	return out, nil
+}
+
+// ensureTagsIndex creates the keyword index on tags for collections made
+// before it was part of payloadIndexes; Qdrant only counts indexed fields.
+// It checks once per Store.
+func (s *Store) ensureTagsIndex(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tagsChecked {
+		return nil
+	}
+	info, err := s.client.GetCollectionInfo(ctx, s.collection)
+	if err != nil {
+		return fmt.Errorf("collection info: %w", err)
+	}
+	if _, ok := info.GetPayloadSchema()[TagsField]; !ok {
+		wait := true
+		_, err := s.client.CreateFieldIndex(ctx, &qdrant.CreateFieldIndexCollection{
+			CollectionName: s.collection,
+			Wait:           &wait,
+			FieldName:      TagsField,
+			FieldType:      qdrant.FieldType_FieldTypeKeyword.Enum(),
+		})
+		if err != nil {
+			return fmt.Errorf("create %s index: %w", TagsField, err)
+		}
+	}
+	s.tagsChecked = true
+	return nil
+}
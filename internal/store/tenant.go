@@ -0,0 +1,135 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// AgentField is the payload field holding the agent a memory belongs to when
+// the store is scoped to an agent.
+const AgentField = "agent"
+
+// maxAgentLength bounds agent names; they are stored on every memory.
+const maxAgentLength = 64
+
+// agentName allows the characters that read well in logs and env vars.
+var agentName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// tenantPayloadM is the HNSW payload_m used for agent-scoped collections.
+// Qdrant's multitenancy pattern sets the global graph's m to 0 and builds a
+// graph per tenant instead, so a filtered search only walks one agent's
+// points however many agents share the collection.
+const tenantPayloadM = 16
+
+// ValidateAgent checks that name is usable as an agent scope.
+func ValidateAgent(name string) error {
+	if len(name) > maxAgentLength {
+		return fmt.Errorf("agent %q is longer than %d characters", name, maxAgentLength)
+	}
+	if !agentName.MatchString(name) {
+		return fmt.Errorf("agent %q must start with a letter or digit and contain only letters, digits, '.', '_' or '-'", name)
+	}
+	return nil
+}
+
+// SetAgent scopes the store to one agent: memories it adds are stamped with
+// the agent, and every read, update and delete only sees that agent's
+// memories. An empty name removes the scope.
+func (s *Store) SetAgent(name string) {
+	s.agent = name
+}
+
+// Agent returns the agent the store is scoped to, or "" if it isn't.
+func (s *Store) Agent() string {
+	return s.agent
+}
+
+// scoped adds the agent condition to filter. Without an agent scope it
+// returns filter unchanged; filter may be nil.
+func (s *Store) scoped(filter *qdrant.Filter) *qdrant.Filter {
+	if s.agent == "" {
+		return filter
+	}
+	cond := qdrant.NewMatchKeyword(AgentField, s.agent)
+	if filter == nil {
+		return &qdrant.Filter{Must: []*qdrant.Condition{cond}}
+	}
+	return &qdrant.Filter{
+		Must:      append([]*qdrant.Condition{cond}, filter.Must...),
+		MustNot:   filter.MustNot,
+		Should:    filter.Should,
+		MinShould: filter.MinShould,
+	}
+}
+
+// selector selects points by ID. With an agent scope, IDs belonging to other
+// agents are not selected, so updates and deletes can't reach across agents.
+func (s *Store) selector(ids ...*qdrant.PointId) *qdrant.PointsSelector {
+	if s.agent == "" {
+		return qdrant.NewPointsSelector(ids...)
+	}
+	return qdrant.NewPointsSelectorFilter(s.scoped(&qdrant.Filter{
+		Must: []*qdrant.Condition{qdrant.NewHasID(ids...)},
+	}))
+}
+
+// ownedBy reports whether payload belongs to the store's agent scope.
+func (s *Store) ownedBy(payload map[string]any) bool {
+	if s.agent == "" {
+		return true
+	}
+	agent, _ := payload[AgentField].(string)
+	return agent == s.agent
+}
+
+// tenantIndexParams returns the params for the agent index: a keyword index
+// marked is_tenant, so Qdrant stores each agent's points together on disk.
+func tenantIndexParams() *qdrant.PayloadIndexParams {
+	isTenant := true
+	return qdrant.NewPayloadIndexParamsKeyword(&qdrant.KeywordIndexParams{IsTenant: &isTenant})
+}
+
+// createTenantIndex creates the agent tenant index.
+func (s *Store) createTenantIndex(ctx context.Context) error {
+	wait := true
+	_, err := s.client.CreateFieldIndex(ctx, &qdrant.CreateFieldIndexCollection{
+		CollectionName:   s.collection,
+		Wait:             &wait,
+		FieldName:        AgentField,
+		FieldType:        qdrant.FieldType_FieldTypeKeyword.Enum(),
+		FieldIndexParams: tenantIndexParams(),
+	})
+	if err != nil {
+		return fmt.Errorf("create %s index: %w", AgentField, err)
+	}
+	return nil
+}
+
+// ensureTenantIndex adds the agent tenant index to a collection created
+// before agent scoping was used. It checks once per Store. The HNSW layout
+// of an existing collection is left alone: changing it rebuilds the index,
+// which is for the operator to schedule, not a side effect of an add.
+func (s *Store) ensureTenantIndex(ctx context.Context) error {
+	if s.agent == "" {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tenantChecked {
+		return nil
+	}
+	info, err := s.client.GetCollectionInfo(ctx, s.collection)
+	if err != nil {
+		return fmt.Errorf("collection info: %w", err)
+	}
+	if _, ok := info.GetPayloadSchema()[AgentField]; !ok {
+		if err := s.createTenantIndex(ctx); err != nil {
+			return err
+		}
+	}
+	s.tenantChecked = true
+	return nil
+}
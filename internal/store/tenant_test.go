@@ -0,0 +1,96 @@
+package store
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateAgent(t *testing.T) {
+	for _, ok := range []string{"claw", "agent-7", "team.ops_bot"} {
+		if err := ValidateAgent(ok); err != nil {
+			t.Errorf("ValidateAgent(%q): %v", ok, err)
+		}
+	}
+	for _, bad := range []string{"", "-lead", "two words", "a/b", strings.Repeat("a", maxAgentLength+1)} {
+		if err := ValidateAgent(bad); err == nil {
+			t.Errorf("ValidateAgent(%q): expected error", bad)
+		}
+	}
+}
+
+func TestScoped(t *testing.T) {
+	var unscoped Store
+	if f := unscoped.scoped(nil); f != nil {
+		t.Errorf("expected nil filter without an agent, got %v", f)
+	}
+
+	s := Store{agent: "claw"}
+	f := s.scoped(Filter{ExcludePersonal: true}.qdrantFilter())
+	if len(f.Must) != 1 || f.Must[0].GetField().GetKey() != AgentField {
+		t.Errorf("expected the agent condition in must, got %v", f.Must)
+	}
+	if len(f.MustNot) != 1 {
+		t.Errorf("expected the personal exclusion to be kept, got %v", f.MustNot)
+	}
+	if !s.ownedBy(map[string]any{AgentField: "claw"}) || s.ownedBy(map[string]any{AgentField: "lico"}) || s.ownedBy(map[string]any{}) {
+		t.Error("ownedBy should only accept the scoped agent's memories")
+	}
+}
+
+func TestAgentIsolation(t *testing.T) {
+	s := testStore(t)
+	defer s.Close()
+	defer cleanupMemories(t, s)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// Start from a fresh collection so it is created with the tenant layout.
+	cleanupMemories(t, s)
+
+	vector := []float32{0.1, 0.2, 0.3, 0.4}
+	s.SetAgent("claw")
+	mine, err := s.Add(ctx, "", vector, map[string]any{"text": "claw's note"})
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	s.SetAgent("lico")
+	theirs, err := s.Add(ctx, "", vector, map[string]any{"text": "lico's note"})
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	info, err := s.client.GetCollectionInfo(ctx, s.collection)
+	if err != nil {
+		t.Fatalf("GetCollectionInfo failed: %v", err)
+	}
+	if !info.GetPayloadSchema()[AgentField].GetParams().GetKeywordIndexParams().GetIsTenant() {
+		t.Error("expected the agent index to be marked is_tenant")
+	}
+
+	s.SetAgent("claw")
+	results, err := s.FindSimilar(ctx, vector, 0, 10)
+	if err != nil {
+		t.Fatalf("FindSimilar failed: %v", err)
+	}
+	if len(results) != 1 || results[0].ID != mine {
+		t.Errorf("expected only claw's memory, got %v", results)
+	}
+	if r, err := s.Get(ctx, theirs); err != nil || r != nil {
+		t.Errorf("expected lico's memory to be invisible to claw, got %v, %v", r, err)
+	}
+	if n, err := s.Count(ctx); err != nil || n != 1 {
+		t.Errorf("expected count 1 for claw, got %d, %v", n, err)
+	}
+
+	// A delete by ID can't reach another agent's memory.
+	if err := s.Delete(ctx, theirs); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	s.SetAgent("")
+	if n, err := s.Count(ctx); err != nil || n != 2 {
+		t.Errorf("expected both memories to remain, got %d, %v", n, err)
+	}
+}
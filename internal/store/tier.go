@@ -0,0 +1,156 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// ColdSuffix ends the name of a collection's cold tier. Namespace names
+// can't contain a dot, so the cold tier of one namespace never collides
+// with another namespace's collection.
+const ColdSuffix = ".cold"
+
+// Cold returns a Store for the cold tier of the store's collection: a
+// second collection, created on first use with its vectors, index and
+// payloads on disk, that holds memories nobody has recalled in a long
+// time. It shares the store's connection and agent scope; close the store,
+// not the cold one.
+func (s *Store) Cold() *Store {
+	return &Store{
+		client:          s.client,
+		collection:      s.collection + ColdSuffix,
+		agent:           s.agent,
+		frequencyWeight: s.frequencyWeight,
+		clientVersion:   s.clientVersion,
+		model:           s.model,
+		pool:            s.pool,
+		cold:            true,
+	}
+}
+
+// IsCold reports whether the store is a cold tier.
+func (s *Store) IsCold() bool {
+	return s.cold
+}
+
+// Tiers returns the store and its cold tier, hot first, for work that must
+// reach a memory wherever tier has moved it. A cold tier is its own only
+// tier. The cold tier copies the store's settings, so set them first.
+func (s *Store) Tiers() []*Store {
+	if s.cold {
+		return []*Store{s}
+	}
+	return []*Store{s, s.Cold()}
+}
+
+// coldSettings makes a collection about to be created cheap to keep:
+// vectors, HNSW graph and payloads live on disk rather than in memory.
+// Searches get slower, which is the trade for memories rarely searched.
+func coldSettings(create *qdrant.CreateCollection) {
+	onDisk := true
+	create.GetVectorsConfig().GetParams().OnDisk = &onDisk
+	create.OnDiskPayload = &onDisk
+	if create.HnswConfig == nil {
+		create.HnswConfig = &qdrant.HnswConfigDiff{}
+	}
+	create.HnswConfig.OnDisk = &onDisk
+}
+
+// Freeze moves the memories Stale returns for ttl -- unpinned, unlocked
+// and not accessed within it -- into the cold tier, vectors and payloads
+// unchanged, and returns them. Each batch is copied before it is deleted,
+// so a failure can leave a memory in both tiers but never in neither.
+func (s *Store) Freeze(ctx context.Context, ttl time.Duration) ([]Result, error) {
+	if s.cold {
+		return nil, fmt.Errorf("the cold tier can't be frozen further")
+	}
+	stale, err := s.Stale(ctx, ttl)
+	if err != nil || len(stale) == 0 {
+		return stale, err
+	}
+	ids := make([]string, len(stale))
+	for i, r := range stale {
+		ids[i] = r.ID
+	}
+	if err := s.moveTo(ctx, s.Cold(), ids); err != nil {
+		return nil, err
+	}
+	return stale, nil
+}
+
+// Thaw moves memories back from the cold tier into the store's collection,
+// as when a search that reached into the cold tier recalls them.
+func (s *Store) Thaw(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return s.Cold().moveTo(ctx, s, ids)
+}
+
+// Warm moves the memory with id back from the cold tier, if tier put it
+// there, so that it can be written to in the collection. It reports whether
+// the memory was cold; one in neither tier is left for the caller to find
+// missing.
+func (s *Store) Warm(ctx context.Context, id string) (bool, error) {
+	if s.cold {
+		return false, nil
+	}
+	r, err := s.Cold().Peek(ctx, id)
+	if err != nil || r == nil {
+		return false, err
+	}
+	return true, s.Thaw(ctx, []string{id})
+}
+
+// moveTo copies the memories with the given IDs into dst and then deletes
+// them here, importBatchSize at a time.
+func (s *Store) moveTo(ctx context.Context, dst *Store, ids []string) error {
+	for start := 0; start < len(ids); start += importBatchSize {
+		batch := ids[start:min(start+importBatchSize, len(ids))]
+		points, err := s.pointsByID(ctx, batch)
+		if err != nil {
+			return err
+		}
+		if err := dst.Import(ctx, points); err != nil {
+			return fmt.Errorf("copy to %s: %w", dst.collection, err)
+		}
+		if err := s.DeleteIDs(ctx, batch); err != nil {
+			return fmt.Errorf("delete from %s: %w", s.collection, err)
+		}
+	}
+	return nil
+}
+
+// pointsByID returns the memories with the given IDs, with their vectors.
+// IDs that don't exist, or belong to another agent, are left out.
+func (s *Store) pointsByID(ctx context.Context, ids []string) ([]Point, error) {
+	pointIDs := make([]*qdrant.PointId, len(ids))
+	for i, id := range ids {
+		pointIDs[i] = qdrant.NewIDUUID(id)
+	}
+	found, err := s.client.Get(ctx, &qdrant.GetPoints{
+		CollectionName: s.collection,
+		Ids:            pointIDs,
+		WithPayload:    qdrant.NewWithPayload(true),
+		WithVectors:    qdrant.NewWithVectors(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get points: %w", err)
+	}
+	points := make([]Point, 0, len(found))
+	for _, p := range found {
+		payload := valueMapToGoMap(p.Payload)
+		if !s.ownedBy(payload) {
+			continue
+		}
+		points = append(points, Point{
+			ID:      pointIDToString(p.Id),
+			Vector:  denseVector(p.GetVectors().GetVector()),
+			Payload: payload,
+		})
+	}
+	return points, nil
+}
@@ -0,0 +1,70 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestFreezeAndThaw(t *testing.T) {
+	s, _ := fileStore(t)
+	defer s.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	old := time.Now().UTC().Add(-200 * 24 * time.Hour).Format(time.RFC3339Nano)
+	coldID, err := s.Add(ctx, "", []float32{1, 0, 0, 0}, map[string]any{"text": "the old office had a blue door"})
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	pinnedID, err := s.Add(ctx, "", []float32{0, 1, 0, 0}, map[string]any{"text": "the wifi password", "pinned": true})
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if _, err := s.Add(ctx, "", []float32{0, 0, 1, 0}, map[string]any{"text": "deploys go out on tuesdays"}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	for _, id := range []string{coldID, pinnedID} {
+		if err := s.SetPayloads(ctx, map[string]map[string]any{id: {"last_accessed": old}}); err != nil {
+			t.Fatalf("SetPayloads failed: %v", err)
+		}
+	}
+
+	frozen, err := s.Freeze(ctx, 90*24*time.Hour)
+	if err != nil {
+		t.Fatalf("Freeze failed: %v", err)
+	}
+	if len(frozen) != 1 || frozen[0].ID != coldID {
+		t.Fatalf("expected only the unpinned old memory to be frozen, got %+v", frozen)
+	}
+	if got, _ := s.Peek(ctx, coldID); got != nil {
+		t.Errorf("expected the frozen memory to leave the hot collection")
+	}
+	cold := s.Cold()
+	point, err := cold.PeekPoint(ctx, coldID)
+	if err != nil || point == nil {
+		t.Fatalf("expected the frozen memory in the cold tier, got %v (%v)", point, err)
+	}
+	if point.Payload["text"] != "the old office had a blue door" || len(point.Vector) != 4 {
+		t.Errorf("expected the payload and vector to move unchanged, got %+v", point)
+	}
+	if n, err := s.Count(ctx); err != nil || n != 2 {
+		t.Errorf("expected the pinned and recent memories to stay hot, got %d (%v)", n, err)
+	}
+
+	if err := s.Thaw(ctx, []string{coldID}); err != nil {
+		t.Fatalf("Thaw failed: %v", err)
+	}
+	if got, _ := s.Peek(ctx, coldID); got == nil {
+		t.Errorf("expected the thawed memory back in the hot collection")
+	}
+	if n, err := cold.Count(ctx); err != nil || n != 0 {
+		t.Errorf("expected the cold tier to be empty again, got %d (%v)", n, err)
+	}
+	if _, err := cold.Freeze(ctx, time.Hour); err == nil {
+		t.Errorf("expected freezing the cold tier to fail")
+	}
+	if tiers := s.Tiers(); len(tiers) != 2 || tiers[0] != s || !tiers[1].IsCold() || len(cold.Tiers()) != 1 {
+		t.Errorf("expected the hot store then its cold tier, got %v", tiers)
+	}
+}
@@ -0,0 +1,139 @@
+package store
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// CallTimer adds up the calls Stores make to their backend and the time
+// those take, for reporting what a command spent waiting on Qdrant (or the
+// file or SQLite backend). One timer can serve several Stores.
+type CallTimer struct {
+	calls, nanos atomic.Int64
+}
+
+// Calls returns how many backend calls finished.
+func (t *CallTimer) Calls() int64 {
+	return t.calls.Load()
+}
+
+// Elapsed returns the total time spent in finished backend calls.
+func (t *CallTimer) Elapsed() time.Duration {
+	return time.Duration(t.nanos.Load())
+}
+
+func (t *CallTimer) since(start time.Time) {
+	t.calls.Add(1)
+	t.nanos.Add(int64(time.Since(start)))
+}
+
+// TimeCalls makes every call s, and the Stores derived from it afterwards
+// (Cold, sandboxes), makes to its backend count on t.
+func (s *Store) TimeCalls(t *CallTimer) {
+	s.client = &timedBackend{client: s.client, timer: t}
+}
+
+// timedBackend is a Backend that times each call on its timer.
+type timedBackend struct {
+	client Backend
+	timer  *CallTimer
+}
+
+var _ Backend = (*timedBackend)(nil)
+
+func (b *timedBackend) Close() error {
+	return b.client.Close()
+}
+
+func (b *timedBackend) HealthCheck(ctx context.Context) (*qdrant.HealthCheckReply, error) {
+	defer b.timer.since(time.Now())
+	return b.client.HealthCheck(ctx)
+}
+
+func (b *timedBackend) CollectionExists(ctx context.Context, collectionName string) (bool, error) {
+	defer b.timer.since(time.Now())
+	return b.client.CollectionExists(ctx, collectionName)
+}
+
+func (b *timedBackend) ListCollections(ctx context.Context) ([]string, error) {
+	defer b.timer.since(time.Now())
+	return b.client.ListCollections(ctx)
+}
+
+func (b *timedBackend) CreateCollection(ctx context.Context, request *qdrant.CreateCollection) error {
+	defer b.timer.since(time.Now())
+	return b.client.CreateCollection(ctx, request)
+}
+
+func (b *timedBackend) UpdateCollection(ctx context.Context, request *qdrant.UpdateCollection) error {
+	defer b.timer.since(time.Now())
+	return b.client.UpdateCollection(ctx, request)
+}
+
+func (b *timedBackend) DeleteCollection(ctx context.Context, collectionName string) error {
+	defer b.timer.since(time.Now())
+	return b.client.DeleteCollection(ctx, collectionName)
+}
+
+func (b *timedBackend) GetCollectionInfo(ctx context.Context, collectionName string) (*qdrant.CollectionInfo, error) {
+	defer b.timer.since(time.Now())
+	return b.client.GetCollectionInfo(ctx, collectionName)
+}
+
+func (b *timedBackend) CreateFieldIndex(ctx context.Context, request *qdrant.CreateFieldIndexCollection) (*qdrant.UpdateResult, error) {
+	defer b.timer.since(time.Now())
+	return b.client.CreateFieldIndex(ctx, request)
+}
+
+func (b *timedBackend) Upsert(ctx context.Context, request *qdrant.UpsertPoints) (*qdrant.UpdateResult, error) {
+	defer b.timer.since(time.Now())
+	return b.client.Upsert(ctx, request)
+}
+
+func (b *timedBackend) Delete(ctx context.Context, request *qdrant.DeletePoints) (*qdrant.UpdateResult, error) {
+	defer b.timer.since(time.Now())
+	return b.client.Delete(ctx, request)
+}
+
+func (b *timedBackend) SetPayload(ctx context.Context, request *qdrant.SetPayloadPoints) (*qdrant.UpdateResult, error) {
+	defer b.timer.since(time.Now())
+	return b.client.SetPayload(ctx, request)
+}
+
+func (b *timedBackend) DeletePayload(ctx context.Context, request *qdrant.DeletePayloadPoints) (*qdrant.UpdateResult, error) {
+	defer b.timer.since(time.Now())
+	return b.client.DeletePayload(ctx, request)
+}
+
+func (b *timedBackend) UpdateBatch(ctx context.Context, request *qdrant.UpdateBatchPoints) ([]*qdrant.UpdateResult, error) {
+	defer b.timer.since(time.Now())
+	return b.client.UpdateBatch(ctx, request)
+}
+
+func (b *timedBackend) Get(ctx context.Context, request *qdrant.GetPoints) ([]*qdrant.RetrievedPoint, error) {
+	defer b.timer.since(time.Now())
+	return b.client.Get(ctx, request)
+}
+
+func (b *timedBackend) Query(ctx context.Context, request *qdrant.QueryPoints) ([]*qdrant.ScoredPoint, error) {
+	defer b.timer.since(time.Now())
+	return b.client.Query(ctx, request)
+}
+
+func (b *timedBackend) ScrollAndOffset(ctx context.Context, request *qdrant.ScrollPoints) ([]*qdrant.RetrievedPoint, *qdrant.PointId, error) {
+	defer b.timer.since(time.Now())
+	return b.client.ScrollAndOffset(ctx, request)
+}
+
+func (b *timedBackend) Count(ctx context.Context, request *qdrant.CountPoints) (uint64, error) {
+	defer b.timer.since(time.Now())
+	return b.client.Count(ctx, request)
+}
+
+func (b *timedBackend) Facet(ctx context.Context, request *qdrant.FacetCounts) ([]*qdrant.FacetHit, error) {
+	defer b.timer.since(time.Now())
+	return b.client.Facet(ctx, request)
+}
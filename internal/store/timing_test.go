@@ -0,0 +1,34 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTimeCalls(t *testing.T) {
+	s, _ := fileStore(t)
+	defer s.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var timer CallTimer
+	s.TimeCalls(&timer)
+	if _, err := s.Add(ctx, "", []float32{1, 0, 0, 0}, map[string]any{"text": "deploys go out on tuesdays"}); err != nil {
+		t.Fatal(err)
+	}
+	added := timer.Calls()
+	if added == 0 {
+		t.Fatal("expected Add's backend calls counted")
+	}
+	// Derived stores share the timer.
+	if _, err := s.Cold().Count(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if timer.Calls() <= added {
+		t.Errorf("expected the cold tier's calls counted too, still %d", timer.Calls())
+	}
+	if timer.Elapsed() <= 0 {
+		t.Errorf("expected time spent, got %v", timer.Elapsed())
+	}
+}
@@ -0,0 +1,84 @@
+package store
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// TypeField is the payload field holding a memory's type. It is indexed, so
+// search --type is a filter inside Qdrant rather than a hope that the
+// embedding ranks the right kind of memory first.
+const TypeField = "type"
+
+// DefaultTypes are the memory types allowed when the config file doesn't
+// list its own.
+var DefaultTypes = []string{"lesson", "todo", "fact", "preference", "decision"}
+
+// UntypedType is the name search uses for memories without a type, so it
+// can't be a type itself.
+const UntypedType = "untyped"
+
+// typeName keeps types short lowercase keywords.
+var typeName = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,31}$`)
+
+// ValidateTypes checks a configured list of memory types.
+func ValidateTypes(types []string) error {
+	seen := make(map[string]bool, len(types))
+	for _, t := range types {
+		if !typeName.MatchString(t) {
+			return fmt.Errorf("type %q must be a short lowercase word", t)
+		}
+		if t == UntypedType {
+			return fmt.Errorf("type %q is reserved for memories without a type", t)
+		}
+		if seen[t] {
+			return fmt.Errorf("type %q is listed twice", t)
+		}
+		seen[t] = true
+	}
+	return nil
+}
+
+// NormalizeType lowercases the type field of a payload in place and checks
+// it against allowed. An empty value removes the field.
+func NormalizeType(payload map[string]any, allowed []string) error {
+	v, ok := payload[TypeField]
+	if !ok || v == nil {
+		return nil
+	}
+	t, isStr := v.(string)
+	if !isStr {
+		return fmt.Errorf("field %q must be a string, got %v", TypeField, v)
+	}
+	t = strings.ToLower(strings.TrimSpace(t))
+	if t == "" {
+		delete(payload, TypeField)
+		return nil
+	}
+	if !slices.Contains(allowed, t) {
+		return fmt.Errorf("unknown type %q (want %s)", t, strings.Join(allowed, ", "))
+	}
+	payload[TypeField] = t
+	return nil
+}
+
+// TypeFilter turns type names given to search into Filter.Types: each must
+// be one of allowed or UntypedType, which selects memories without a type.
+func TypeFilter(names, allowed []string) ([]string, error) {
+	var types []string
+	for _, name := range names {
+		t := strings.ToLower(strings.TrimSpace(name))
+		switch {
+		case t == UntypedType:
+			t = ""
+		case !slices.Contains(allowed, t):
+			return nil, fmt.Errorf("unknown type %q (want %s or %s)", name, strings.Join(allowed, ", "), UntypedType)
+		}
+		if !slices.Contains(types, t) {
+			types = append(types, t)
+		}
+	}
+	return types, nil
+}
@@ -0,0 +1,54 @@
+package store
+
+import "testing"
+
+func TestNormalizeType(t *testing.T) {
+	payload := map[string]any{"type": " TODO "}
+	if err := NormalizeType(payload, DefaultTypes); err != nil {
+		t.Fatal(err)
+	}
+	if payload["type"] != "todo" {
+		t.Errorf("expected todo, got %v", payload["type"])
+	}
+
+	blank := map[string]any{"type": ""}
+	if err := NormalizeType(blank, DefaultTypes); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := blank["type"]; ok {
+		t.Error("expected blank type to be removed")
+	}
+
+	for _, bad := range []any{"meeting", 3.0} {
+		if err := NormalizeType(map[string]any{"type": bad}, DefaultTypes); err == nil {
+			t.Errorf("expected error for %v", bad)
+		}
+	}
+	if err := NormalizeType(map[string]any{"type": "decision"}, []string{"decision"}); err != nil {
+		t.Errorf("expected a configured type to be accepted, got %v", err)
+	}
+}
+
+func TestValidateTypes(t *testing.T) {
+	if err := ValidateTypes(DefaultTypes); err != nil {
+		t.Errorf("default types should be valid: %v", err)
+	}
+	for _, bad := range [][]string{{"Todo"}, {"untyped"}, {"todo", "todo"}, {""}} {
+		if err := ValidateTypes(bad); err == nil {
+			t.Errorf("ValidateTypes(%q): expected error", bad)
+		}
+	}
+}
+
+func TestTypeFilter(t *testing.T) {
+	got, err := TypeFilter([]string{"Todo", "untyped", "todo"}, DefaultTypes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != "todo" || got[1] != "" {
+		t.Errorf("expected [todo \"\"], got %q", got)
+	}
+	if _, err := TypeFilter([]string{"meeting"}, DefaultTypes); err == nil {
+		t.Error("expected an unknown type to be rejected")
+	}
+}
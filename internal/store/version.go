@@ -0,0 +1,53 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// changedAtKey is the collection metadata key holding when memories were
+// last written. Touching last_accessed doesn't count as a write, so a
+// search doesn't change the version it was answered from.
+const changedAtKey = "changed_at"
+
+// changed records a write in the collection metadata. Like Touch, failures
+// are logged rather than returned: the write itself already landed.
+func (s *Store) changed(ctx context.Context) {
+	err := s.client.UpdateCollection(ctx, &qdrant.UpdateCollection{
+		CollectionName: s.collection,
+		Metadata: qdrant.NewValueMap(map[string]any{
+			changedAtKey: strconv.FormatInt(time.Now().UnixNano(), 10),
+		}),
+	})
+	if err != nil {
+		log.Printf("warning: failed to record collection change: %v", err)
+	}
+}
+
+// Version identifies the current state of the stored memories: it changes
+// whenever a memory is added, rewritten or deleted, but not when one is
+// merely read. It is cheap to fetch, so callers can tell whether a result
+// set computed earlier is still current. Returns "" if there is no
+// collection yet.
+func (s *Store) Version(ctx context.Context) (string, error) {
+	exists, err := s.client.CollectionExists(ctx, s.collection)
+	if err != nil {
+		return "", fmt.Errorf("check collection: %w", err)
+	}
+	if !exists {
+		return "", nil
+	}
+	info, err := s.client.GetCollectionInfo(ctx, s.collection)
+	if err != nil {
+		return "", fmt.Errorf("collection info: %w", err)
+	}
+	// The point count also catches writes by clients that don't record
+	// changed_at.
+	changedAt := info.GetConfig().GetMetadata()[changedAtKey].GetStringValue()
+	return fmt.Sprintf("%s-%d", changedAt, info.GetPointsCount()), nil
+}
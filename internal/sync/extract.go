@@ -0,0 +1,165 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/hsk-coder/clawbrain/internal/plugin"
+)
+
+// Extractor turns the content of a file into the text sync chunks. path is
+// the file the content was read from, for extractors that hand it to
+// another program.
+type Extractor interface {
+	Extract(ctx context.Context, path string, content []byte) (string, error)
+}
+
+// ExtractorFunc adapts a function that needs only the content to Extractor.
+type ExtractorFunc func(content []byte) (string, error)
+
+// Extract calls f(content).
+func (f ExtractorFunc) Extract(_ context.Context, _ string, content []byte) (string, error) {
+	return f(content)
+}
+
+// Format is how sync reads the files of one extension.
+type Format struct {
+	Extractor Extractor
+	// Markdown is set when the extracted text is markdown: it is read for
+	// frontmatter and chunked at its headings. Other text is chunked by
+	// size alone, so a line starting with "#" is never taken for a heading.
+	Markdown bool
+}
+
+// Plain reads a file as it is.
+var Plain = ExtractorFunc(func(content []byte) (string, error) {
+	return string(content), nil
+})
+
+// formats are the formats sync reads, by lowercase extension.
+var formats = map[string]Format{
+	".md":       {Extractor: Plain, Markdown: true},
+	".markdown": {Extractor: Plain, Markdown: true},
+	".txt":      {Extractor: Plain},
+	".org":      {Extractor: ExtractorFunc(orgToMarkdown), Markdown: true},
+}
+
+// Register makes sync read the files of extension ext (".html") with f,
+// replacing any format it had. Register before DiscoverFiles, which only
+// finds files of registered extensions in a directory.
+func Register(ext string, f Format) {
+	formats[strings.ToLower(ext)] = f
+}
+
+// FormatFor returns the format of filePath by its extension. A file of an
+// unregistered extension, only ever synced when named with --file, is read
+// as plain text.
+func FormatFor(filePath string) Format {
+	if f, ok := formats[strings.ToLower(filepath.Ext(filePath))]; ok {
+		return f
+	}
+	return Format{Extractor: Plain}
+}
+
+// Extensions returns the registered extensions, sorted.
+func Extensions() []string {
+	exts := make([]string, 0, len(formats))
+	for ext := range formats {
+		exts = append(exts, ext)
+	}
+	slices.Sort(exts)
+	return exts
+}
+
+// ExtractorPrefix starts the name of a plugin (see package plugin) that
+// extracts the text of one file format: clawbrain-extract-pdf on PATH
+// makes sync read PDFs.
+const ExtractorPrefix = plugin.Prefix + extractorCommand
+
+// extractorCommand is the plugin command name an extractor's extension
+// follows.
+const extractorCommand = "extract-"
+
+// RegisterCommands registers a Command for each extractor plugin on PATH,
+// and returns the extensions they read. Built-in formats can be replaced
+// this way too, e.g. to extract .txt files differently.
+func RegisterCommands() []string {
+	var exts []string
+	for _, p := range plugin.List() {
+		name, ok := strings.CutPrefix(p.Name, extractorCommand)
+		if !ok || name == "" || strings.Contains(name, ".") {
+			continue
+		}
+		ext := "." + strings.ToLower(name)
+		Register(ext, Format{Extractor: Command{Path: p.Path}})
+		exts = append(exts, ext)
+	}
+	return exts
+}
+
+// Command extracts text by running an executable with the file's path as
+// its only argument and reading the text from its standard output, as
+// pdftotext FILE - does.
+type Command struct {
+	Path string
+}
+
+// Extract runs the command on path.
+func (c Command) Extract(ctx context.Context, path string, _ []byte) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, c.Path, path)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s: %w: %s", filepath.Base(c.Path), err, msg)
+		}
+		return "", fmt.Errorf("%s: %w", filepath.Base(c.Path), err)
+	}
+	return stdout.String(), nil
+}
+
+// orgHeading matches an org-mode headline, capturing its stars.
+var orgHeading = regexp.MustCompile(`^(\*{1,6})[ \t]+(.*)$`)
+
+// orgToMarkdown rewrites an org-mode document as markdown sync can chunk at
+// its headings: headlines become ATX headings and source blocks fences.
+// Keyword lines ("#+TITLE:"), comments and property drawers are blanked
+// rather than dropped, so every line stays at its line number in the file.
+func orgToMarkdown(content []byte) (string, error) {
+	lines := strings.Split(string(content), "\n")
+	drawer, block := false, false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		upper := strings.ToUpper(trimmed)
+		switch {
+		case block:
+			if strings.HasPrefix(upper, "#+END_SRC") || strings.HasPrefix(upper, "#+END_EXAMPLE") {
+				block = false
+				lines[i] = "```"
+			}
+		case drawer:
+			drawer = upper != ":END:"
+			lines[i] = ""
+		case upper == ":PROPERTIES:" || upper == ":LOGBOOK:":
+			drawer = true
+			lines[i] = ""
+		case strings.HasPrefix(upper, "#+BEGIN_SRC") || strings.HasPrefix(upper, "#+BEGIN_EXAMPLE"):
+			block = true
+			lines[i] = "```"
+		case strings.HasPrefix(trimmed, "#+"), trimmed == "#", strings.HasPrefix(trimmed, "# "):
+			lines[i] = ""
+		default:
+			if m := orgHeading.FindStringSubmatch(line); m != nil {
+				lines[i] = strings.Repeat("#", len(m[1])) + " " + m[2]
+			}
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
@@ -0,0 +1,79 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestFormatFor(t *testing.T) {
+	tests := map[string]bool{
+		"notes.md":       true,
+		"NOTES.MARKDOWN": true,
+		"journal.org":    true,
+		"todo.txt":       false,
+		"README":         false,
+	}
+	for path, markdown := range tests {
+		if f := FormatFor(path); f.Extractor == nil || f.Markdown != markdown {
+			t.Errorf("%s: got %+v, want markdown=%t", path, f, markdown)
+		}
+	}
+}
+
+func TestOrgToMarkdown(t *testing.T) {
+	org := "#+TITLE: Ops\n" +
+		"* Deploys\n" +
+		":PROPERTIES:\n:ID: 42\n:END:\n" +
+		"Ship on tuesdays.\n" +
+		"** Rollbacks\n" +
+		"# a comment\n" +
+		"#+BEGIN_SRC sh\n# not a comment\n#+END_SRC\n" +
+		"*bold* stays text"
+	got, err := orgToMarkdown([]byte(org))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "\n" +
+		"# Deploys\n" +
+		"\n\n\n" +
+		"Ship on tuesdays.\n" +
+		"## Rollbacks\n" +
+		"\n" +
+		"```\n# not a comment\n```\n" +
+		"*bold* stays text"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if strings.Count(got, "\n") != strings.Count(org, "\n") {
+		t.Errorf("expected every line kept at its line number")
+	}
+}
+
+func TestRegisterCommands(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("extractor scripts are shell scripts")
+	}
+	dir := t.TempDir()
+	script := "#!/bin/sh\necho \"text of ${1##*/}\"\n"
+	if err := os.WriteFile(filepath.Join(dir, ExtractorPrefix+"pdfx"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir)
+	t.Cleanup(func() { delete(formats, ".pdfx") })
+
+	if exts := RegisterCommands(); len(exts) != 1 || exts[0] != ".pdfx" {
+		t.Fatalf("expected the .pdfx extractor registered, got %v", exts)
+	}
+	f := FormatFor("/notes/Report.PDFX")
+	if _, ok := f.Extractor.(Command); !ok || f.Markdown {
+		t.Fatalf("expected a plain-text command format, got %+v", f)
+	}
+	text, err := f.Extractor.Extract(context.Background(), "/notes/Report.PDFX", nil)
+	if err != nil || text != "text of Report.PDFX\n" {
+		t.Errorf("Extract = %q, %v", text, err)
+	}
+}
@@ -0,0 +1,187 @@
+package sync
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// IgnoreValue is the value of the frontmatter key "clawbrain" that keeps a
+// file out of sync.
+const IgnoreValue = "ignore"
+
+// Frontmatter is the metadata a markdown file declares in a YAML block at
+// its very top, between two "---" lines, as note-taking apps write it.
+// Only the fields sync maps into memories are kept; other keys are ignored.
+type Frontmatter struct {
+	Title string
+	Tags  []string
+	// Date is when the note was written; the zero time if unset.
+	Date   time.Time
+	Pinned bool
+	// Ignore is set by "clawbrain: ignore".
+	Ignore bool
+}
+
+// dateLayouts are the date formats accepted for the date field. Those
+// without a zone are read in local time.
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// ParseFrontmatter splits text into its frontmatter and the body after it.
+// Text without frontmatter comes back whole, with an empty Frontmatter.
+//
+// It reads the subset of YAML frontmatter is usually written in: "key: value"
+// lines with plain or quoted scalars, and lists either inline ("[a, b]")
+// or as "- item" lines under the key. A field whose value doesn't parse is
+// left unset and reported in the error, with the rest still returned.
+func ParseFrontmatter(text string) (Frontmatter, string, error) {
+	var fm Frontmatter
+	block, body, ok := splitFrontmatter(text)
+	if !ok {
+		return fm, text, nil
+	}
+	var errs []string
+	for key, values := range frontmatterFields(block) {
+		value := strings.Join(values, ", ")
+		switch strings.ToLower(key) {
+		case "title":
+			fm.Title = value
+		case "tags", "tag":
+			for _, v := range values {
+				for t := range strings.SplitSeq(v, ",") {
+					if t = strings.TrimPrefix(strings.TrimSpace(t), "#"); t != "" {
+						fm.Tags = append(fm.Tags, t)
+					}
+				}
+			}
+		case "date":
+			date, err := parseDate(value)
+			if err != nil {
+				errs = append(errs, err.Error())
+				continue
+			}
+			fm.Date = date
+		case "pinned":
+			pinned, err := strconv.ParseBool(value)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("invalid pinned %q: expected true or false", value))
+				continue
+			}
+			fm.Pinned = pinned
+		case "clawbrain":
+			fm.Ignore = strings.EqualFold(value, IgnoreValue)
+		}
+	}
+	if len(errs) > 0 {
+		slices.Sort(errs)
+		return fm, body, fmt.Errorf("frontmatter: %s", strings.Join(errs, "; "))
+	}
+	return fm, body, nil
+}
+
+// splitFrontmatter returns the lines between a leading "---" line and the
+// next "---" (or "...") line, and the text after it. ok is false if text
+// doesn't open with frontmatter or it is never closed.
+func splitFrontmatter(text string) (block []string, body string, ok bool) {
+	text = strings.TrimPrefix(text, "\ufeff")
+	first, rest, found := strings.Cut(text, "\n")
+	if !found || strings.TrimRight(first, " \t\r") != "---" {
+		return nil, text, false
+	}
+	for rest != "" {
+		line, after, _ := strings.Cut(rest, "\n")
+		if trimmed := strings.TrimRight(line, " \t\r"); trimmed == "---" || trimmed == "..." {
+			return block, after, true
+		}
+		block = append(block, strings.TrimRight(line, "\r"))
+		rest = after
+	}
+	return nil, text, false
+}
+
+// frontmatterFields reads the top-level keys of a frontmatter block and
+// their values: one for a scalar, one per item for a list.
+func frontmatterFields(block []string) map[string][]string {
+	fields := map[string][]string{}
+	key := ""
+	for _, line := range block {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		// A "- item" line continues the list of the key above it.
+		if key != "" && (trimmed == "-" || strings.HasPrefix(trimmed, "- ")) {
+			if item := unquote(strings.TrimSpace(trimmed[1:])); item != "" {
+				fields[key] = append(fields[key], item)
+			}
+			continue
+		}
+		if line != strings.TrimLeft(line, " \t") {
+			// Nested mappings aren't fields sync reads.
+			continue
+		}
+		k, v, found := strings.Cut(trimmed, ":")
+		if !found {
+			key = ""
+			continue
+		}
+		key = strings.TrimSpace(k)
+		v = stripComment(strings.TrimSpace(v))
+		switch {
+		case v == "":
+			fields[key] = nil
+		case strings.HasPrefix(v, "[") && strings.HasSuffix(v, "]"):
+			var items []string
+			for item := range strings.SplitSeq(v[1:len(v)-1], ",") {
+				if item = unquote(strings.TrimSpace(item)); item != "" {
+					items = append(items, item)
+				}
+			}
+			fields[key] = items
+		default:
+			fields[key] = []string{unquote(v)}
+		}
+	}
+	return fields
+}
+
+// stripComment drops a trailing " # comment" from an unquoted value.
+func stripComment(v string) string {
+	if strings.HasPrefix(v, `"`) || strings.HasPrefix(v, "'") {
+		return v
+	}
+	if i := strings.Index(v, " #"); i >= 0 {
+		return strings.TrimSpace(v[:i])
+	}
+	return v
+}
+
+// unquote strips matching single or double quotes around a value.
+func unquote(v string) string {
+	if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') && v[len(v)-1] == v[0] {
+		if v[0] == '"' {
+			if s, err := strconv.Unquote(v); err == nil {
+				return s
+			}
+		}
+		return strings.ReplaceAll(v[1:len(v)-1], "''", "'")
+	}
+	return v
+}
+
+func parseDate(value string) (time.Time, error) {
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q: expected YYYY-MM-DD or RFC 3339", value)
+}
@@ -0,0 +1,79 @@
+package sync
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseFrontmatter(t *testing.T) {
+	text := "---\n" +
+		"title: \"Deploys: a history\"\n" +
+		"tags: [ops, '#release']\n" +
+		"date: 2024-03-05\n" +
+		"pinned: true # keep this one\n" +
+		"aliases:\n" +
+		"  - deploy notes\n" +
+		"---\n" +
+		"# Deploys\n\nShip on tuesdays.\n"
+	fm, body, err := ParseFrontmatter(text)
+	if err != nil {
+		t.Fatalf("ParseFrontmatter failed: %v", err)
+	}
+	if fm.Title != "Deploys: a history" {
+		t.Errorf("title = %q", fm.Title)
+	}
+	if !slices.Equal(fm.Tags, []string{"ops", "release"}) {
+		t.Errorf("tags = %q", fm.Tags)
+	}
+	if want := time.Date(2024, 3, 5, 0, 0, 0, 0, time.Local); !fm.Date.Equal(want) {
+		t.Errorf("date = %v, want %v", fm.Date, want)
+	}
+	if !fm.Pinned || fm.Ignore {
+		t.Errorf("expected pinned and not ignored, got %+v", fm)
+	}
+	if body != "# Deploys\n\nShip on tuesdays.\n" {
+		t.Errorf("body = %q", body)
+	}
+}
+
+func TestParseFrontmatter_BlockListAndIgnore(t *testing.T) {
+	text := "---\r\nclawbrain: Ignore\r\ntags:\r\n- journal\r\n- \"travel, 2024\"\r\n...\r\nbody"
+	fm, body, err := ParseFrontmatter(text)
+	if err != nil {
+		t.Fatalf("ParseFrontmatter failed: %v", err)
+	}
+	if !fm.Ignore {
+		t.Errorf("expected clawbrain: ignore to be honored")
+	}
+	if !slices.Equal(fm.Tags, []string{"journal", "travel", "2024"}) {
+		t.Errorf("tags = %q", fm.Tags)
+	}
+	if body != "body" {
+		t.Errorf("body = %q", body)
+	}
+}
+
+func TestParseFrontmatter_None(t *testing.T) {
+	for _, text := range []string{
+		"# Title\n\n---\ntags: [x]\n---\n",
+		"---\ntags: [never closed]\n",
+		"",
+	} {
+		fm, body, err := ParseFrontmatter(text)
+		if err != nil || body != text || fm.Title != "" || fm.Tags != nil {
+			t.Errorf("%q: expected no frontmatter, got %+v, %q, %v", text, fm, body, err)
+		}
+	}
+}
+
+func TestParseFrontmatter_InvalidFields(t *testing.T) {
+	fm, _, err := ParseFrontmatter("---\ntitle: Kept\ndate: last tuesday\npinned: maybe\n---\n")
+	if err == nil || !strings.Contains(err.Error(), "date") || !strings.Contains(err.Error(), "pinned") {
+		t.Errorf("expected errors for date and pinned, got %v", err)
+	}
+	if fm.Title != "Kept" || !fm.Date.IsZero() || fm.Pinned {
+		t.Errorf("expected valid fields kept and invalid ones unset, got %+v", fm)
+	}
+}
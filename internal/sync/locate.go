@@ -0,0 +1,155 @@
+package sync
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Location is where a chunk sits in the file it came from: its first and
+// last line (1-based) and the nearest heading at or above its start, with
+// that heading's anchor. Heading and Anchor are empty for chunks above the
+// first heading; StartLine is 0 when the chunk couldn't be found.
+type Location struct {
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line"`
+	Heading   string `json:"heading,omitempty"`
+	Anchor    string `json:"anchor,omitempty"`
+}
+
+// headingLine matches an ATX markdown heading, capturing its hashes and its
+// text without the closing hashes.
+var headingLine = regexp.MustCompile(`^ {0,3}(#{1,6})[ \t]+(.*?)(?:[ \t]+#+)?[ \t]*$`)
+
+// heading is a markdown heading, its level (1 for "#") and the byte offset
+// of its line.
+type heading struct {
+	offset int
+	level  int
+	text   string
+	anchor string
+}
+
+// Locate finds each chunk in text, the file's content, and returns its
+// Location. chunks must be in the order ChunkTurns returned them: each is
+// looked for after the start of the one before, so repeated passages are
+// attributed to the right occurrence.
+func Locate(text string, chunks []Turn) []Location {
+	headings := findHeadings(text)
+	locs := make([]Location, len(chunks))
+	from := 0
+	for i, c := range chunks {
+		idx := strings.Index(text[from:], c.Text)
+		if idx == -1 {
+			continue
+		}
+		start := from + idx
+		from = start + 1
+		loc := Location{StartLine: 1 + strings.Count(text[:start], "\n")}
+		loc.EndLine = loc.StartLine + strings.Count(c.Text, "\n")
+		loc.Heading, loc.Anchor = nearestHeading(headings, start)
+		locs[i] = loc
+	}
+	return locs
+}
+
+// HeadingAt returns the nearest heading at or above line (1-based) of text,
+// and its anchor: what Locate records for a chunk starting on that line.
+func HeadingAt(text string, line int) (string, string) {
+	return nearestHeading(findHeadings(text), lineOffset(text, line))
+}
+
+// HeadingPathAt returns the heading path of line (1-based) of text, as
+// ChunkMarkdown gives the chunks of a section starting there.
+func HeadingPathAt(text string, line int) string {
+	offset := lineOffset(text, line)
+	var path []heading
+	for _, h := range findHeadings(text) {
+		if h.offset > offset {
+			break
+		}
+		path = nest(path, h)
+	}
+	return headingPath(path)
+}
+
+// lineOffset returns the byte offset of line (1-based) of text, or of its
+// last line if it is shorter.
+func lineOffset(text string, line int) int {
+	offset := 0
+	for l := 1; l < line; l++ {
+		next := strings.IndexByte(text[offset:], '\n')
+		if next == -1 {
+			break
+		}
+		offset += next + 1
+	}
+	return offset
+}
+
+// nearestHeading returns the text and anchor of the last of headings at or
+// before offset.
+func nearestHeading(headings []heading, offset int) (string, string) {
+	var text, anchor string
+	for _, h := range headings {
+		if h.offset > offset {
+			break
+		}
+		text, anchor = h.text, h.anchor
+	}
+	return text, anchor
+}
+
+// findHeadings lists the headings of a markdown document in order, skipping
+// lines inside fenced code blocks. Anchors follow GitHub's rules, including
+// the -1, -2 suffixes that tell repeated headings apart.
+func findHeadings(text string) []heading {
+	var headings []heading
+	seen := make(map[string]int)
+	fence := ""
+	offset := 0
+	for _, line := range strings.SplitAfter(text, "\n") {
+		lineStart := offset
+		offset += len(line)
+		trimmed := strings.TrimSpace(line)
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence = trimmed[:3]
+			continue
+		}
+		m := headingLine.FindStringSubmatch(strings.TrimRight(line, "\r\n"))
+		if m == nil || m[2] == "" {
+			continue
+		}
+		anchor := Anchor(m[2])
+		if n := seen[anchor]; n > 0 {
+			seen[anchor] = n + 1
+			anchor += "-" + strconv.Itoa(n)
+		} else {
+			seen[anchor] = 1
+		}
+		headings = append(headings, heading{offset: lineStart, level: len(m[1]), text: m[2], anchor: anchor})
+	}
+	return headings
+}
+
+// Anchor returns the anchor GitHub gives a heading: lowercased, with
+// punctuation dropped and spaces turned into hyphens.
+func Anchor(heading string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(heading)) {
+		switch {
+		case unicode.IsLetter(r), unicode.IsDigit(r), r == '-', r == '_':
+			b.WriteRune(r)
+		case r == ' ':
+			b.WriteRune('-')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,100 @@
+package sync
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLocate(t *testing.T) {
+	text := "Intro line.\n\n" +
+		"# Deploys\n\nShip on tuesdays.\n\n" +
+		"```\n# not a heading\n```\n\n" +
+		"## Rollbacks & Recovery\n\nRevert the release.\nThen page on-call.\n\n" +
+		"## Deploys\n\nShip on tuesdays.\n"
+	chunks := []Turn{
+		{Text: "Intro line."},
+		{Text: "# Deploys\n\nShip on tuesdays."},
+		{Text: "```\n# not a heading\n```"},
+		{Text: "Revert the release.\nThen page on-call."},
+		{Text: "Ship on tuesdays."},
+	}
+
+	got := Locate(text, chunks)
+	want := []Location{
+		{StartLine: 1, EndLine: 1},
+		{StartLine: 3, EndLine: 5, Heading: "Deploys", Anchor: "deploys"},
+		{StartLine: 7, EndLine: 9, Heading: "Deploys", Anchor: "deploys"},
+		{StartLine: 13, EndLine: 14, Heading: "Rollbacks & Recovery", Anchor: "rollbacks--recovery"},
+		// The repeated passage is found after the previous chunk, under the
+		// second "Deploys" heading.
+		{StartLine: 18, EndLine: 18, Heading: "Deploys", Anchor: "deploys-1"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Locate:\n got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestLocate_OverlappingChunks(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("# Notes\n\n")
+	for i := 0; i < 40; i++ {
+		b.WriteString("A line of notes that goes on for a while.\n")
+	}
+	text := b.String()
+
+	chunks := ChunkTurns(text, 400, 80, true)
+	if len(chunks) < 2 {
+		t.Fatalf("expected several chunks, got %d", len(chunks))
+	}
+	locs := Locate(text, chunks)
+	for i, loc := range locs {
+		if loc.StartLine == 0 || loc.EndLine < loc.StartLine {
+			t.Fatalf("chunk %d: bad location %+v", i, loc)
+		}
+		lines := strings.Split(text, "\n")[loc.StartLine-1 : loc.EndLine]
+		if !strings.Contains(strings.Join(lines, "\n"), chunks[i].Text) {
+			t.Errorf("chunk %d: lines %d-%d don't hold its text", i, loc.StartLine, loc.EndLine)
+		}
+		if loc.Anchor != "notes" {
+			t.Errorf("chunk %d: expected the notes anchor, got %q", i, loc.Anchor)
+		}
+		if i > 0 && loc.StartLine < locs[i-1].StartLine {
+			t.Errorf("chunk %d starts before chunk %d", i, i-1)
+		}
+	}
+}
+
+func TestHeadingAt(t *testing.T) {
+	text := "Intro.\n# Deploys\n\nShip on tuesdays.\n## Deploys\nAgain."
+	tests := []struct {
+		line            int
+		heading, anchor string
+	}{
+		{1, "", ""},
+		{2, "Deploys", "deploys"},
+		{4, "Deploys", "deploys"},
+		{6, "Deploys", "deploys-1"},
+		{99, "Deploys", "deploys-1"},
+	}
+	for _, tt := range tests {
+		if h, a := HeadingAt(text, tt.line); h != tt.heading || a != tt.anchor {
+			t.Errorf("line %d: got %q #%s, want %q #%s", tt.line, h, a, tt.heading, tt.anchor)
+		}
+	}
+}
+
+func TestAnchor(t *testing.T) {
+	tests := map[string]string{
+		"Deploys":              "deploys",
+		"Rollbacks & Recovery": "rollbacks--recovery",
+		"v1.2 Release Notes":   "v12-release-notes",
+		"snake_case-heading":   "snake_case-heading",
+		"Café Menü":            "café-menü",
+	}
+	for in, want := range tests {
+		if got := Anchor(in); got != want {
+			t.Errorf("Anchor(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package sync
+
+import "strings"
+
+// pathSeparator joins the headings of a heading path.
+const pathSeparator = " > "
+
+// ChunkMarkdown chunks a markdown document section by section: it breaks at
+// every heading first, and only splits a section that is longer than size
+// with Chunk. Each chunk carries the path of headings it sits under, such as
+// "## Project > ### Deployment", in Path; the heading lines themselves are
+// left out of Text, since the path carries them. Text without headings, or
+// before the first one, is chunked by Chunk alone.
+func ChunkMarkdown(text string, size, overlap int) []Turn {
+	headings := findHeadings(text)
+	var chunks []Turn
+	add := func(section, path string) {
+		// Leave room for the path, which WithPath puts in front.
+		budget, over := size, overlap
+		if path != "" {
+			budget = max(size-len(path)-2, size/2)
+			over = min(overlap, budget/2)
+		}
+		for _, c := range Chunk(section, budget, over) {
+			chunks = append(chunks, Turn{Path: path, Text: c})
+		}
+	}
+	if len(headings) == 0 {
+		add(text, "")
+		return chunks
+	}
+	add(text[:headings[0].offset], "")
+	var path []heading
+	for i, h := range headings {
+		path = nest(path, h)
+		end := len(text)
+		if i+1 < len(headings) {
+			end = headings[i+1].offset
+		}
+		// A heading followed directly by a subheading has no text of its
+		// own; it lives on in the subheading's path.
+		if nl := strings.IndexByte(text[h.offset:end], '\n'); nl != -1 {
+			add(text[h.offset+nl+1:end], headingPath(path))
+		}
+	}
+	return chunks
+}
+
+// WithPath returns the chunk's text preceded by its heading path, as sync
+// embeds and stores it, so that a chunk reading "Ship on tuesdays." is
+// found by a search about deploys.
+func (t Turn) WithPath() string {
+	if t.Path == "" {
+		return t.Text
+	}
+	return t.Path + "\n\n" + t.Text
+}
+
+// StripPath returns the text of a chunk stored with WithPath, without its
+// heading path: the part that appears in the file.
+func StripPath(text, path string) string {
+	if path == "" {
+		return text
+	}
+	return strings.TrimPrefix(text, NormalizeText(path)+"\n\n")
+}
+
+// nest adds h to a path of headings, first dropping those at its level or
+// deeper: a "##" heading ends the previous "##" section and everything in it.
+func nest(path []heading, h heading) []heading {
+	for len(path) > 0 && path[len(path)-1].level >= h.level {
+		path = path[:len(path)-1]
+	}
+	return append(path, h)
+}
+
+// headingPath formats a path of headings as "## Project > ### Deployment".
+func headingPath(path []heading) string {
+	parts := make([]string, len(path))
+	for i, h := range path {
+		parts[i] = strings.Repeat("#", h.level) + " " + h.text
+	}
+	return strings.Join(parts, pathSeparator)
+}
@@ -0,0 +1,83 @@
+package sync
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestChunkMarkdown(t *testing.T) {
+	text := "Intro line.\n" +
+		"# Project\n" +
+		"## Deployment\n" +
+		"Ship on tuesdays.\n" +
+		"### Rollbacks\n" +
+		"Revert the tag.\n" +
+		"## Billing\n" +
+		"```\n# not a heading\n```\n"
+	got := ChunkMarkdown(text, DefaultChunkSize, DefaultChunkOverlap)
+	want := []Turn{
+		{Text: "Intro line."},
+		{Path: "# Project > ## Deployment", Text: "Ship on tuesdays."},
+		{Path: "# Project > ## Deployment > ### Rollbacks", Text: "Revert the tag."},
+		{Path: "# Project > ## Billing", Text: "```\n# not a heading\n```"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d chunks, want %d: %q", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("chunk %d: got %q, want %q", i, got[i], want[i])
+		}
+	}
+	if s := got[1].WithPath(); s != "# Project > ## Deployment\n\nShip on tuesdays." {
+		t.Errorf("WithPath = %q", s)
+	}
+	if s := StripPath(NormalizeText(got[1].WithPath()), got[1].Path); s != "Ship on tuesdays." {
+		t.Errorf("StripPath = %q", s)
+	}
+}
+
+func TestChunkMarkdown_LongSection(t *testing.T) {
+	text := "## Notes\n" + strings.Repeat("A sentence about deploys. ", 100)
+	chunks := ChunkMarkdown(text, 400, 80)
+	if len(chunks) < 3 {
+		t.Fatalf("expected the long section split, got %d chunks", len(chunks))
+	}
+	for i, c := range chunks {
+		if c.Path != "## Notes" {
+			t.Errorf("chunk %d: expected the section's path, got %q", i, c.Path)
+		}
+		if n := len(c.WithPath()); n > 400 {
+			t.Errorf("chunk %d: %d characters with its path, want at most 400", i, n)
+		}
+	}
+}
+
+func TestChunkMarkdown_PlainText(t *testing.T) {
+	text := strings.Repeat("Plain text without headings. ", 100)
+	got := ChunkMarkdown(text, 400, 80)
+	want := Chunk(text, 400, 80)
+	if len(got) != len(want) {
+		t.Fatalf("expected plain text chunked as Chunk does, got %d chunks, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].Path != "" || got[i].Text != want[i] {
+			t.Errorf("chunk %d: got %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestHeadingPathAt(t *testing.T) {
+	text := "Intro.\n# Project\n## Deploys\nShip.\n# Other\nText."
+	tests := map[int]string{
+		1: "",
+		3: "# Project > ## Deploys",
+		4: "# Project > ## Deploys",
+		6: "# Other",
+	}
+	for line, want := range tests {
+		if got := HeadingPathAt(text, line); got != want {
+			t.Errorf("line %d: got %q, want %q", line, got, want)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package sync
+
+import (
+	"context"
+	gosync "sync"
+	"time"
+)
+
+// DefaultConcurrency is how many chunks sync embeds at once by default.
+// Ollama serves a few requests in parallel (OLLAMA_NUM_PARALLEL); more
+// workers than that only queue up on its side.
+const DefaultConcurrency = 4
+
+// maxEmbedRetries is how many times a chunk is retried after the embedder
+// answered that it is overloaded.
+const maxEmbedRetries = 3
+
+// EmbedFunc embeds one text.
+type EmbedFunc func(ctx context.Context, text string) ([]float32, error)
+
+// Pool embeds the chunks of a sync on a fixed number of workers, so the
+// embedder is kept busy while earlier chunks are stored. Chunks are
+// embedded in the order they are submitted, and each one's vector is
+// collected from its Embedding, so the caller stores them in whatever
+// order it chose no matter which finishes first.
+type Pool struct {
+	ctx     context.Context
+	embed   EmbedFunc
+	wg      gosync.WaitGroup
+	limiter *limiter
+
+	mu     gosync.Mutex
+	ready  *gosync.Cond
+	queue  []*Embedding
+	closed bool
+
+	// Backoff, if set before the first Submit, tells how long to pause every worker after a
+	// failed embed: positive for an embedder that is overloaded, when the
+	// chunk is retried after the pause; zero for any other failure.
+	Backoff func(err error) time.Duration
+}
+
+// Embedding is the vector of a submitted chunk, once Wait returns.
+type Embedding struct {
+	text   string
+	done   chan struct{}
+	vector []float32
+	err    error
+}
+
+// Wait blocks until the chunk is embedded and returns its vector.
+func (e *Embedding) Wait() ([]float32, error) {
+	<-e.done
+	return e.vector, e.err
+}
+
+// NewPool starts workers embedding with embed, at most rate texts a second
+// across them all; a rate of 0 doesn't limit them. Close the pool when
+// done submitting.
+func NewPool(ctx context.Context, workers int, rate float64, embed EmbedFunc) *Pool {
+	p := &Pool{
+		ctx:     ctx,
+		embed:   embed,
+		limiter: newLimiter(rate),
+	}
+	p.ready = gosync.NewCond(&p.mu)
+	for range max(workers, 1) {
+		p.wg.Add(1)
+		go p.work()
+	}
+	return p
+}
+
+// Submit queues text to be embedded and returns without waiting, so a
+// whole workspace can be queued while the first chunks are stored.
+func (p *Pool) Submit(text string) *Embedding {
+	e := &Embedding{text: text, done: make(chan struct{})}
+	p.mu.Lock()
+	p.queue = append(p.queue, e)
+	p.mu.Unlock()
+	p.ready.Signal()
+	return e
+}
+
+// Close stops the workers once the chunks submitted are embedded.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+	p.ready.Broadcast()
+	p.wg.Wait()
+}
+
+// next takes the oldest chunk off the queue, or returns nil once the pool
+// is closed and the queue empty.
+func (p *Pool) next() *Embedding {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for len(p.queue) == 0 && !p.closed {
+		p.ready.Wait()
+	}
+	if len(p.queue) == 0 {
+		return nil
+	}
+	e := p.queue[0]
+	p.queue = p.queue[1:]
+	return e
+}
+
+func (p *Pool) work() {
+	defer p.wg.Done()
+	for e := p.next(); e != nil; e = p.next() {
+		for attempt := 0; ; attempt++ {
+			if e.err = p.limiter.wait(p.ctx); e.err != nil {
+				break
+			}
+			e.vector, e.err = p.embed(p.ctx, e.text)
+			if e.err == nil || p.Backoff == nil || attempt == maxEmbedRetries {
+				break
+			}
+			pause := p.Backoff(e.err)
+			if pause <= 0 {
+				break
+			}
+			p.limiter.pause(pause)
+		}
+		close(e.done)
+	}
+}
+
+// limiter spaces embeds evenly at a rate a second, and holds every worker
+// back while the embedder recovers from overload.
+type limiter struct {
+	mu       gosync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newLimiter(rate float64) *limiter {
+	l := &limiter{}
+	if rate > 0 {
+		l.interval = time.Duration(float64(time.Second) / rate)
+	}
+	return l
+}
+
+// wait blocks until the next embed may start.
+func (l *limiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	start := now
+	if l.next.After(now) {
+		start = l.next
+	}
+	if l.interval > 0 {
+		l.next = start.Add(l.interval)
+	}
+	l.mu.Unlock()
+	if start.Equal(now) {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(start.Sub(now))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// pause lets no embed start for d from now.
+func (l *limiter) pause(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until := time.Now().Add(d); until.After(l.next) {
+		l.next = until
+	}
+}
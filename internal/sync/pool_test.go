@@ -0,0 +1,98 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPool(t *testing.T) {
+	var running, peak atomic.Int64
+	embed := func(ctx context.Context, text string) ([]float32, error) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+		}
+		time.Sleep(5 * time.Millisecond)
+		return []float32{float32(len(text))}, nil
+	}
+	pool := NewPool(context.Background(), 3, 0, embed)
+	var pending []*Embedding
+	for i := range 20 {
+		pending = append(pending, pool.Submit(fmt.Sprint(i)))
+	}
+	for i, e := range pending {
+		vector, err := e.Wait()
+		if err != nil || vector[0] != float32(len(fmt.Sprint(i))) {
+			t.Errorf("chunk %d: got %v, %v", i, vector, err)
+		}
+	}
+	pool.Close()
+	if p := peak.Load(); p < 2 || p > 3 {
+		t.Errorf("expected up to 3 embeds at once, peaked at %d", p)
+	}
+}
+
+func TestPool_Rate(t *testing.T) {
+	pool := NewPool(context.Background(), 4, 50, func(ctx context.Context, text string) ([]float32, error) {
+		return []float32{1}, nil
+	})
+	start := time.Now()
+	var pending []*Embedding
+	for range 6 {
+		pending = append(pending, pool.Submit("x"))
+	}
+	for _, e := range pending {
+		e.Wait()
+	}
+	pool.Close()
+	// Six embeds at 50 a second start 20ms apart.
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("expected the rate to space embeds out, took %v", elapsed)
+	}
+}
+
+func TestPool_Backoff(t *testing.T) {
+	overloaded := errors.New("overloaded")
+	var calls atomic.Int64
+	pool := NewPool(context.Background(), 2, 0, func(ctx context.Context, text string) ([]float32, error) {
+		if text == "busy" && calls.Add(1) < 3 {
+			return nil, overloaded
+		}
+		if text == "bad" {
+			return nil, errors.New("bad input")
+		}
+		return []float32{1}, nil
+	})
+	pool.Backoff = func(err error) time.Duration {
+		if errors.Is(err, overloaded) {
+			return time.Millisecond
+		}
+		return 0
+	}
+	busy, bad := pool.Submit("busy"), pool.Submit("bad")
+	if _, err := busy.Wait(); err != nil || calls.Load() != 3 {
+		t.Errorf("expected an overloaded embed retried until it succeeds, got %v after %d calls", err, calls.Load())
+	}
+	if _, err := bad.Wait(); err == nil {
+		t.Errorf("expected an error that isn't overload to be returned, not retried")
+	}
+	pool.Close()
+}
+
+func TestPool_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	pool := NewPool(ctx, 1, 1, func(ctx context.Context, text string) ([]float32, error) {
+		return []float32{1}, nil
+	})
+	first, second := pool.Submit("a"), pool.Submit("b")
+	first.Wait()
+	cancel()
+	if _, err := second.Wait(); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected a chunk waiting on the rate to fail with the context, got %v", err)
+	}
+	pool.Close()
+}
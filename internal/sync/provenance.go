@@ -0,0 +1,60 @@
+package sync
+
+import "strings"
+
+// Drift statuses reported by Verify.
+const (
+	DriftUnchanged = "unchanged" // the text is still at its recorded lines
+	DriftMoved     = "moved"     // the text is still in the file, at other lines
+	DriftChanged   = "changed"   // the text is no longer in the file
+)
+
+// Provenance is what Verify found of a synced chunk in its source file.
+// StartLine and EndLine are where the text is now; for a changed chunk they
+// are the recorded lines, and Current holds what they say today. They are 0
+// when the chunk had no recorded lines and its text is gone.
+type Provenance struct {
+	Status    string
+	StartLine int
+	EndLine   int
+	Current   string
+}
+
+// Verify checks whether a chunk's text, as stored by sync, is still in
+// content, the source file's current contents. start and end are the lines
+// recorded for the chunk (0 for chunks synced before lines were recorded).
+// Text is compared after NormalizeText, as sync stored it.
+func Verify(content, text string, start, end int) Provenance {
+	lines := strings.Split(content, "\n")
+	text = NormalizeText(text)
+	region := func(from, to int) string {
+		return NormalizeText(strings.Join(lines[from-1:to], "\n"))
+	}
+
+	recorded := start > 0 && end >= start && end <= len(lines)
+	if recorded && region(start, end) == text {
+		return Provenance{Status: DriftUnchanged, StartLine: start, EndLine: end}
+	}
+
+	// Look for the text elsewhere, in a window as tall as it was.
+	span := strings.Count(text, "\n") + 1
+	if start > 0 && end >= start {
+		span = end - start + 1
+	}
+	for from := 1; from+span-1 <= len(lines); from++ {
+		if region(from, from+span-1) == text {
+			status := DriftMoved
+			if start == 0 {
+				status = DriftUnchanged
+			}
+			return Provenance{Status: status, StartLine: from, EndLine: from + span - 1}
+		}
+	}
+
+	p := Provenance{Status: DriftChanged}
+	if start > 0 && start <= len(lines) {
+		p.StartLine, p.EndLine = start, min(max(end, start), len(lines))
+		p.Current = region(p.StartLine, p.EndLine)
+	}
+	return p
+}
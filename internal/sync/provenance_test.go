@@ -0,0 +1,31 @@
+package sync
+
+import "testing"
+
+func TestVerify(t *testing.T) {
+	chunk := "# Deploys\n\nShip on tuesdays."
+	tests := []struct {
+		name       string
+		content    string
+		start, end int
+		want       Provenance
+	}{
+		{"unchanged", "# Deploys\n\nShip on   tuesdays.\n", 1, 3,
+			Provenance{Status: DriftUnchanged, StartLine: 1, EndLine: 3}},
+		{"moved", "# Intro\n\nHello.\n\n# Deploys\n\nShip on tuesdays.\n", 1, 3,
+			Provenance{Status: DriftMoved, StartLine: 5, EndLine: 7}},
+		{"changed", "# Deploys\n\nShip on fridays.\n", 1, 3,
+			Provenance{Status: DriftChanged, StartLine: 1, EndLine: 3, Current: "# Deploys\n\nShip on fridays."}},
+		{"file shrank", "# Deploys", 1, 3,
+			Provenance{Status: DriftChanged, StartLine: 1, EndLine: 1, Current: "# Deploys"}},
+		{"no recorded lines, found", "Intro.\n# Deploys\n\nShip on tuesdays.", 0, 0,
+			Provenance{Status: DriftUnchanged, StartLine: 2, EndLine: 4}},
+		{"no recorded lines, gone", "Nothing here.", 0, 0,
+			Provenance{Status: DriftChanged}},
+	}
+	for _, tt := range tests {
+		if got := Verify(tt.content, chunk, tt.start, tt.end); got != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/hsk-coder/clawbrain/internal/redis"
+	"github.com/hsk-coder/clawbrain/internal/store"
+)
+
+// State is where sync remembers the files it has ingested (RedisKey) and
+// the progress of a run (CheckpointKeys). Its methods are the Redis
+// commands sync uses, with the same signatures, so *redis.Client is a
+// State as it is; FileState keeps the same keys in a JSON file, for setups
+// with no Redis to run, and *store.SyncState in the vector store itself.
+type State interface {
+	Close() error
+	Get(key string) (string, bool, error)
+	Exists(key string) (bool, error)
+	Set(key, value string) error
+	SetWithTTL(key, value string, ttlSeconds int) error
+	SetNX(key, value string, ttlSeconds int) (bool, error)
+	Expire(key string, ttlSeconds int) error
+	Rename(key, newKey string) error
+	Del(keys ...string) (int, error)
+	Scan(pattern string) ([]string, error)
+	SAdd(key string, members ...string) error
+	SMembers(key string) ([]string, error)
+}
+
+// Backends accepted by the CLI's --state-backend flag.
+const (
+	StateRedis  = "redis"
+	StateFile   = "file"
+	StateQdrant = "qdrant"
+)
+
+// StateFileName is the file FileState keeps sync state in, in the
+// directory of the file and sqlite backends.
+const StateFileName = "sync-state.json"
+
+var (
+	_ State = (*redis.Client)(nil)
+	_ State = (*FileState)(nil)
+	_ State = (*store.SyncState)(nil)
+)
+
+// staleStateLock is how old a lock file must be before FileState takes it
+// over from a process that died holding it.
+const staleStateLock = 30 * time.Second
+
+// FileState is a State in a JSON file, for a single machine. Every change
+// rewrites the file under a lock file, so concurrent syncs don't lose each
+// other's records; reads see the last complete write.
+type FileState struct {
+	path string
+}
+
+// stateEntry is one key of a FileState: a string value or a set.
+type stateEntry struct {
+	Value   string    `json:"value,omitempty"`
+	Members []string  `json:"members,omitempty"`
+	Expires time.Time `json:"expires,omitzero"`
+}
+
+func (e stateEntry) expired(now time.Time) bool {
+	return !e.Expires.IsZero() && !now.Before(e.Expires)
+}
+
+// NewFileState returns a FileState kept at path. The file and its
+// directory are created on the first change.
+func NewFileState(path string) *FileState {
+	return &FileState{path: path}
+}
+
+// Close releases nothing; it is there to satisfy State.
+func (f *FileState) Close() error {
+	return nil
+}
+
+// load reads the live keys of the file; a missing file holds none.
+func (f *FileState) load() (map[string]stateEntry, error) {
+	entries := map[string]stateEntry{}
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("read %s: %w", f.path, err)
+	}
+	now := time.Now()
+	for key, e := range entries {
+		if e.expired(now) {
+			delete(entries, key)
+		}
+	}
+	return entries, nil
+}
+
+// update runs fn on the keys under the lock and writes them back.
+func (f *FileState) update(fn func(entries map[string]stateEntry) error) error {
+	unlock, err := f.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	entries, err := f.load()
+	if err != nil {
+		return err
+	}
+	if err := fn(entries); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path)
+}
+
+// lock takes the file's lock file, waiting while another process holds
+// it. The returned func releases it.
+func (f *FileState) lock() (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o700); err != nil {
+		return nil, err
+	}
+	path := f.path + ".lock"
+	ctx, cancel := context.WithTimeout(context.Background(), 2*staleStateLock)
+	defer cancel()
+	for {
+		lf, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			lf.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, fmt.Errorf("lock %s: %w", f.path, err)
+		}
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > staleStateLock {
+			os.Remove(path)
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("wait for lock on %s: %w", f.path, ctx.Err())
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+// Get returns the value of key, or false if it isn't set.
+func (f *FileState) Get(key string) (string, bool, error) {
+	entries, err := f.load()
+	if err != nil {
+		return "", false, err
+	}
+	e, ok := entries[key]
+	return e.Value, ok, nil
+}
+
+// Exists reports whether key is set.
+func (f *FileState) Exists(key string) (bool, error) {
+	entries, err := f.load()
+	if err != nil {
+		return false, err
+	}
+	_, ok := entries[key]
+	return ok, nil
+}
+
+// Set sets key to value with no expiry.
+func (f *FileState) Set(key, value string) error {
+	return f.update(func(entries map[string]stateEntry) error {
+		entries[key] = stateEntry{Value: value}
+		return nil
+	})
+}
+
+// SetWithTTL sets key to value for ttlSeconds.
+func (f *FileState) SetWithTTL(key, value string, ttlSeconds int) error {
+	return f.update(func(entries map[string]stateEntry) error {
+		entries[key] = stateEntry{Value: value, Expires: time.Now().Add(time.Duration(ttlSeconds) * time.Second)}
+		return nil
+	})
+}
+
+// SetNX sets key to value for ttlSeconds only if it isn't set, and reports
+// whether it was. The file's lock makes it atomic across processes.
+func (f *FileState) SetNX(key, value string, ttlSeconds int) (bool, error) {
+	set := false
+	err := f.update(func(entries map[string]stateEntry) error {
+		if _, ok := entries[key]; ok {
+			return nil
+		}
+		entries[key] = stateEntry{Value: value, Expires: time.Now().Add(time.Duration(ttlSeconds) * time.Second)}
+		set = true
+		return nil
+	})
+	return set && err == nil, err
+}
+
+// Expire makes key expire in ttlSeconds. A key that isn't set is left so.
+func (f *FileState) Expire(key string, ttlSeconds int) error {
+	return f.update(func(entries map[string]stateEntry) error {
+		if e, ok := entries[key]; ok {
+			e.Expires = time.Now().Add(time.Duration(ttlSeconds) * time.Second)
+			entries[key] = e
+		}
+		return nil
+	})
+}
+
+// Rename moves key to newKey, keeping its value and expiry. Renaming a key
+// that isn't set is an error, as in Redis.
+func (f *FileState) Rename(key, newKey string) error {
+	return f.update(func(entries map[string]stateEntry) error {
+		e, ok := entries[key]
+		if !ok {
+			return fmt.Errorf("rename %s: no such key", key)
+		}
+		delete(entries, key)
+		entries[newKey] = e
+		return nil
+	})
+}
+
+// Del deletes keys and returns how many were set.
+func (f *FileState) Del(keys ...string) (int, error) {
+	deleted := 0
+	err := f.update(func(entries map[string]stateEntry) error {
+		for _, key := range keys {
+			if _, ok := entries[key]; ok {
+				delete(entries, key)
+				deleted++
+			}
+		}
+		return nil
+	})
+	return deleted, err
+}
+
+// Scan returns the keys matching a Redis glob pattern (see redis.Match),
+// sorted.
+func (f *FileState) Scan(pattern string) ([]string, error) {
+	entries, err := f.load()
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for key := range entries {
+		if redis.Match(pattern, key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// SAdd adds members to the set at key.
+func (f *FileState) SAdd(key string, members ...string) error {
+	if len(members) == 0 {
+		return nil
+	}
+	return f.update(func(entries map[string]stateEntry) error {
+		e := entries[key]
+		for _, m := range members {
+			if !slices.Contains(e.Members, m) {
+				e.Members = append(e.Members, m)
+			}
+		}
+		entries[key] = e
+		return nil
+	})
+}
+
+// SMembers returns the members of the set at key; a key that isn't set is
+// an empty set.
+func (f *FileState) SMembers(key string) ([]string, error) {
+	entries, err := f.load()
+	if err != nil {
+		return nil, err
+	}
+	return slices.Clone(entries[key].Members), nil
+}
@@ -0,0 +1,76 @@
+package sync
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestFileState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", StateFileName)
+	st := NewFileState(path)
+
+	if _, found, err := st.Get("sync:/a.md"); err != nil || found {
+		t.Fatalf("expected an empty state, got found=%v err=%v", found, err)
+	}
+	if err := st.Set("sync:/a.md", "1"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := st.SetWithTTL("sync:/MEMORY.md", "hash", 60); err != nil {
+		t.Fatalf("SetWithTTL failed: %v", err)
+	}
+	if err := st.SetWithTTL("sync:/old.md", "1", -1); err != nil {
+		t.Fatalf("SetWithTTL failed: %v", err)
+	}
+	if err := st.Set("syncrun:run", "{}"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	// A second FileState on the same file sees the first one's writes.
+	st = NewFileState(path)
+	if value, found, _ := st.Get("sync:/MEMORY.md"); !found || value != "hash" {
+		t.Errorf("expected the hash back, got %q, %v", value, found)
+	}
+	if exists, _ := st.Exists("sync:/old.md"); exists {
+		t.Error("expected an expired key to be gone")
+	}
+	keys, err := st.Scan(RedisKeyPattern("", ""))
+	if err != nil || !slices.Equal(keys, []string{"sync:/MEMORY.md", "sync:/a.md"}) {
+		t.Errorf("expected the two live sync keys, got %v (err=%v)", keys, err)
+	}
+
+	if err := st.Rename("sync:/a.md", "sync:/b.md"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	if exists, _ := st.Exists("sync:/a.md"); exists {
+		t.Error("expected the renamed key to be gone")
+	}
+	if err := st.Rename("sync:/missing.md", "sync:/c.md"); err == nil {
+		t.Error("expected renaming a missing key to fail")
+	}
+
+	if err := st.SAdd("syncrun:done", "/a.md", "/b.md", "/a.md"); err != nil {
+		t.Fatalf("SAdd failed: %v", err)
+	}
+	if err := st.Expire("syncrun:done", 60); err != nil {
+		t.Fatalf("Expire failed: %v", err)
+	}
+	if members, _ := st.SMembers("syncrun:done"); !slices.Equal(members, []string{"/a.md", "/b.md"}) {
+		t.Errorf("expected two members, got %v", members)
+	}
+
+	if n, err := st.Del("syncrun:run", "syncrun:done", "syncrun:none"); err != nil || n != 2 {
+		t.Errorf("expected 2 keys deleted, got %d (err=%v)", n, err)
+	}
+
+	if set, err := st.SetNX("lock", "a", 60); err != nil || !set {
+		t.Fatalf("expected SetNX to take a free key, got %v (err=%v)", set, err)
+	}
+	if set, _ := st.SetNX("lock", "b", 60); set {
+		t.Error("expected SetNX to leave a held key")
+	}
+	st.SetWithTTL("lock", "a", -1)
+	if set, _ := st.SetNX("lock", "b", 60); !set {
+		t.Error("expected SetNX to take an expired key")
+	}
+}
@@ -5,12 +5,18 @@ package sync
 
 import (
 	"crypto/sha256"
+	"errors"
 	"fmt"
+	"io/fs"
+	"maps"
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
 	"time"
+
+	"github.com/hsk-coder/clawbrain/internal/textnorm"
 )
 
 // Default chunking parameters (character-based approximation of tokens).
@@ -20,9 +26,20 @@ const (
 	DefaultChunkOverlap = 320
 )
 
-// redisKeyPrefix is prepended to all sync tracking keys in Redis.
+// redisKeyPrefix is prepended to the default namespace's sync tracking keys
+// in Redis.
 const redisKeyPrefix = "sync:"
 
+// keyPrefix returns the prefix of a namespace's sync tracking keys. Each
+// namespace keeps its own, so a file synced into one namespace is still new
+// to the others. The default namespace "" keeps the original prefix.
+func keyPrefix(namespace string) string {
+	if namespace == "" {
+		return redisKeyPrefix
+	}
+	return "sync@" + namespace + ":"
+}
+
 // memoryMDTTL is the TTL for MEMORY.md entries in Redis (7 days).
 const memoryMDTTL = 7 * 24 * 60 * 60 // 604800 seconds
 
@@ -33,6 +50,41 @@ func ContentHash(content []byte) string {
 	return fmt.Sprintf("%x", h)
 }
 
+// ChunkHashField is the payload field holding a synced chunk's ChunkHash,
+// so a later sync of a changed file can tell which chunks it already has.
+const ChunkHashField = "chunk_hash"
+
+// ChunkHash returns the hash a chunk is tracked by: that of its normalized
+// text, so whitespace-only edits don't count as changes.
+func ChunkHash(normalized string) string {
+	return ContentHash([]byte(normalized))
+}
+
+// MatchChunks pairs a file's chunks, given by their hashes, with the
+// memories synced from it before, given as ID to hash. kept maps the index
+// of each chunk that is already stored to the memory holding it; the
+// others must be embedded. stale lists, sorted, the memories no chunk
+// matched: content that disappeared from the file. A chunk repeated in the
+// file pairs with as many stored copies as there are.
+func MatchChunks(hashes []string, existing map[string]string) (kept map[int]string, stale []string) {
+	byHash := make(map[string][]string)
+	for _, id := range slices.Sorted(maps.Keys(existing)) {
+		byHash[existing[id]] = append(byHash[existing[id]], id)
+	}
+	kept = make(map[int]string)
+	for i, h := range hashes {
+		if ids := byHash[h]; len(ids) > 0 {
+			kept[i] = ids[0]
+			byHash[h] = ids[1:]
+		}
+	}
+	for _, ids := range byHash {
+		stale = append(stale, ids...)
+	}
+	slices.Sort(stale)
+	return kept, stale
+}
+
 // datePattern matches filenames containing YYYY-MM-DD.
 var datePattern = regexp.MustCompile(`\d{4}-\d{2}-\d{2}`)
 
@@ -41,7 +93,12 @@ type FileResult struct {
 	File    string `json:"file"`
 	Added   int    `json:"added"`
 	Skipped int    `json:"skipped"`
-	Reason  string `json:"reason,omitempty"`
+	// Unchanged counts chunks already stored from an earlier sync, which
+	// weren't embedded again; Deleted counts stored chunks that are no
+	// longer in the file.
+	Unchanged int    `json:"unchanged,omitempty"`
+	Deleted   int    `json:"deleted,omitempty"`
+	Reason    string `json:"reason,omitempty"`
 }
 
 // Chunk splits text into overlapping chunks of approximately the given size.
@@ -136,9 +193,12 @@ func findSentenceSplit(text string, start, end int) int {
 // (preserving paragraph breaks). Collapses runs of spaces/tabs on
 // the same line into a single space. Newlines are preserved so that
 // markdown structure (headings, paragraphs) is not lost -- this
-// matters for embedding quality.
+// matters for embedding quality. Width and composition are evened out
+// first (see textnorm.Fold), so a note typed with fullwidth letters or
+// saved with decomposed Hangul embeds and matches like any other; the
+// ideographic space becomes a plain one and is collapsed with the rest.
 func NormalizeText(s string) string {
-	s = strings.TrimSpace(s)
+	s = strings.TrimSpace(textnorm.Fold(s))
 	if s == "" {
 		return ""
 	}
@@ -169,9 +229,92 @@ func NormalizeText(s string) string {
 	return strings.TrimSpace(b.String())
 }
 
-// RedisKey returns the Redis key for tracking a file's sync state.
-func RedisKey(filePath string) string {
-	return redisKeyPrefix + filePath
+// checkpointPrefix starts the Redis keys of a namespace's sync checkpoint.
+// It isn't redisKeyPrefix, so scans for tracked files never match them.
+const checkpointPrefix = "syncrun"
+
+// checkpointTTL is how long an interrupted run can be resumed (7 days).
+const checkpointTTL = 7 * 24 * 60 * 60
+
+// Checkpoint is what sync records in Redis when a run starts, so that an
+// interrupted run can be resumed with the same files.
+type Checkpoint struct {
+	StartedAt string   `json:"started_at"`
+	Files     []string `json:"files"`
+}
+
+// CheckpointKeys returns the Redis keys of a namespace's sync checkpoint:
+// run holds the Checkpoint as JSON, and done is the set of its files that
+// are finished.
+func CheckpointKeys(namespace string) (run, done string) {
+	run = checkpointPrefix + ":"
+	if namespace != "" {
+		run = checkpointPrefix + "@" + namespace + ":"
+	}
+	return run + "run", run + "done"
+}
+
+// CheckpointTTLSeconds returns how long a checkpoint is kept in Redis.
+func CheckpointTTLSeconds() int {
+	return checkpointTTL
+}
+
+// RedisKey returns the Redis key for tracking a file's sync state in a
+// namespace.
+func RedisKey(namespace, filePath string) string {
+	return keyPrefix(namespace) + filePath
+}
+
+// RedisKeyPattern returns a Redis SCAN pattern matching a namespace's sync
+// keys of pathPrefix and everything below it. Glob metacharacters in the path are
+// escaped so they match literally. The pattern may over-match siblings that
+// share a name prefix (e.g. "/notes" also matches "/notes-old"); callers
+// should confirm each key with MovePath.
+func RedisKeyPattern(namespace, pathPrefix string) string {
+	var b strings.Builder
+	b.WriteString(keyPrefix(namespace))
+	for _, r := range pathPrefix {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	b.WriteString("*")
+	return b.String()
+}
+
+// PathFromRedisKey returns the file path a namespace's sync tracking key
+// refers to.
+func PathFromRedisKey(namespace, key string) string {
+	return strings.TrimPrefix(key, keyPrefix(namespace))
+}
+
+// MovePath maps path from under the from location to the same place under
+// to. from may name a single file or a directory; path matches if it equals
+// from or lies inside it. Returns false when path is unaffected by the move.
+func MovePath(path, from, to string) (string, bool) {
+	from = filepath.Clean(from)
+	to = filepath.Clean(to)
+	if path == from {
+		return to, true
+	}
+	dir := from
+	if !strings.HasSuffix(dir, string(filepath.Separator)) {
+		dir += string(filepath.Separator)
+	}
+	if !strings.HasPrefix(path, dir) {
+		return "", false
+	}
+	return filepath.Join(to, path[len(dir):]), true
+}
+
+// IsGone reports whether a synced file no longer exists. A file that can't
+// be checked for another reason, such as permissions, isn't gone: its
+// memories stay until it can be.
+func IsGone(filePath string) bool {
+	_, err := os.Stat(filePath)
+	return errors.Is(err, fs.ErrNotExist)
 }
 
 // MemoryMDTTLSeconds returns the TTL in seconds for MEMORY.md entries.
@@ -246,8 +389,10 @@ func IsIgnored(filePath string, patterns []string) bool {
 	return false
 }
 
-// DiscoverFiles finds markdown files to sync based on explicit paths and/or
-// the default agent memory layout. Returns a deduplicated list of absolute paths.
+// DiscoverFiles finds files to sync based on explicit paths and/or the
+// default agent memory layout. Explicit files are taken whatever their
+// extension; in directories, only files of a registered format (see
+// Extensions) are. Returns a deduplicated list of absolute paths.
 func DiscoverFiles(basePath string, files []string, dirs []string) ([]string, error) {
 	seen := make(map[string]bool)
 	var result []string
@@ -281,9 +426,9 @@ func DiscoverFiles(basePath string, files []string, dirs []string) ([]string, er
 		}
 	}
 
-	// Explicit directories: glob for *.md
+	// Explicit directories: every file of a registered format
 	for _, d := range dirs {
-		matches, err := filepath.Glob(filepath.Join(d, "*.md"))
+		matches, err := globFormats(d)
 		if err != nil {
 			return nil, fmt.Errorf("glob %s: %w", d, err)
 		}
@@ -308,10 +453,10 @@ func DiscoverFiles(basePath string, files []string, dirs []string) ([]string, er
 				break // only add the first match
 			}
 		}
-		// Look for memory/*.md
+		// Look for memory/*.md, and the other registered formats
 		memDir := filepath.Join(basePath, "memory")
 		if info, err := os.Stat(memDir); err == nil && info.IsDir() {
-			matches, err := filepath.Glob(filepath.Join(memDir, "*.md"))
+			matches, err := globFormats(memDir)
 			if err != nil {
 				return nil, fmt.Errorf("glob memory dir: %w", err)
 			}
@@ -325,3 +470,22 @@ func DiscoverFiles(basePath string, files []string, dirs []string) ([]string, er
 
 	return result, nil
 }
+
+// globFormats returns the files in dir of every registered format, sorted.
+// Extensions match regardless of case, as FormatFor matches them.
+func globFormats(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	exts := Extensions()
+	var matches []string
+	for _, e := range entries {
+		ext := strings.ToLower(filepath.Ext(e.Name()))
+		if e.IsDir() || ext == "" || !slices.Contains(exts, ext) {
+			continue
+		}
+		matches = append(matches, filepath.Join(dir, e.Name()))
+	}
+	return matches, nil
+}
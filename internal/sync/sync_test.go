@@ -2,6 +2,7 @@ package sync
 
 import (
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 	"testing"
@@ -120,6 +121,8 @@ func TestNormalizeText(t *testing.T) {
 		{"  ", ""},
 		{"# Title\n\nParagraph", "# Title\n\nParagraph"}, // markdown structure preserved
 		{"a\nb\nc", "a\nb\nc"},                           // single newlines preserved
+		{"ＡＰＩ　キー\u3000\u3000ﾃｽﾄ", "API キー テスト"},          // width folded, ideographic spaces collapsed
+		{"\u1100\u1161\u11a8 메모", "각 메모"},                // decomposed Hangul composed
 	}
 	for _, tt := range tests {
 		got := NormalizeText(tt.input)
@@ -175,12 +178,99 @@ func TestIsTodayDailyFile(t *testing.T) {
 	}
 }
 
+func TestMatchChunks(t *testing.T) {
+	a, b, c := ChunkHash("alpha"), ChunkHash("beta"), ChunkHash("gamma")
+	existing := map[string]string{"id-1": a, "id-2": b, "id-3": c, "id-4": a, "legacy": ""}
+
+	// The file now reads alpha, beta, delta, alpha, alpha: gamma is gone,
+	// and one copy of alpha is new.
+	kept, stale := MatchChunks([]string{a, b, ChunkHash("delta"), a, a}, existing)
+	if len(kept) != 3 || kept[0] != "id-1" || kept[1] != "id-2" || kept[3] != "id-4" {
+		t.Errorf("unexpected kept chunks: %v", kept)
+	}
+	if _, ok := kept[2]; ok {
+		t.Errorf("expected the changed chunk to need embedding, got %v", kept)
+	}
+	if _, ok := kept[4]; ok {
+		t.Errorf("expected the third alpha to need embedding, got %v", kept)
+	}
+	if len(stale) != 2 || stale[0] != "id-3" || stale[1] != "legacy" {
+		t.Errorf("expected gamma and the unhashed chunk stale, got %v", stale)
+	}
+}
+
 func TestRedisKey(t *testing.T) {
-	got := RedisKey("/workspace/MEMORY.md")
+	got := RedisKey("", "/workspace/MEMORY.md")
 	want := "sync:/workspace/MEMORY.md"
 	if got != want {
 		t.Errorf("RedisKey() = %q, want %q", got, want)
 	}
+	if got := RedisKey("support-bot", "/workspace/MEMORY.md"); got != "sync@support-bot:/workspace/MEMORY.md" {
+		t.Errorf("RedisKey() in a namespace = %q", got)
+	}
+}
+
+func TestCheckpointKeys(t *testing.T) {
+	run, done := CheckpointKeys("")
+	if run != "syncrun:run" || done != "syncrun:done" {
+		t.Errorf("CheckpointKeys() = %q, %q", run, done)
+	}
+	run, _ = CheckpointKeys("support-bot")
+	if run != "syncrun@support-bot:run" {
+		t.Errorf("CheckpointKeys() in a namespace = %q", run)
+	}
+	for _, key := range []string{run, done} {
+		if matched, _ := path.Match(RedisKeyPattern("", ""), key); matched {
+			t.Errorf("expected %q not to be taken for a synced file", key)
+		}
+	}
+}
+
+func TestRedisKeyPattern(t *testing.T) {
+	got := RedisKeyPattern("", "/notes/[draft]*")
+	want := `sync:/notes/\[draft\]\**`
+	if got != want {
+		t.Errorf("RedisKeyPattern = %q, want %q", got, want)
+	}
+	if got := PathFromRedisKey("", RedisKey("", "/a/b.md")); got != "/a/b.md" {
+		t.Errorf("PathFromRedisKey round trip = %q, want /a/b.md", got)
+	}
+	if got := PathFromRedisKey("ns", RedisKey("ns", "/a/b.md")); got != "/a/b.md" {
+		t.Errorf("PathFromRedisKey round trip in a namespace = %q, want /a/b.md", got)
+	}
+}
+
+func TestMovePath(t *testing.T) {
+	tests := []struct {
+		path, from, to string
+		want           string
+		ok             bool
+	}{
+		{"/old/notes/a.md", "/old/notes", "/new/notes", "/new/notes/a.md", true},
+		{"/old/notes/sub/a.md", "/old/notes/", "/new", "/new/sub/a.md", true},
+		{"/old/notes/a.md", "/old/notes/a.md", "/new/b.md", "/new/b.md", true},
+		{"/old/notes-archive/a.md", "/old/notes", "/new", "", false},
+		{"/other/a.md", "/old", "/new", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := MovePath(tt.path, tt.from, tt.to)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("MovePath(%q, %q, %q) = %q, %v; want %q, %v", tt.path, tt.from, tt.to, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestIsGone(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "notes.md")
+	os.WriteFile(path, []byte("# Notes"), 0o644)
+
+	if IsGone(path) {
+		t.Error("expected an existing file not to be gone")
+	}
+	if !IsGone(filepath.Join(dir, "renamed.md")) {
+		t.Error("expected a missing file to be gone")
+	}
 }
 
 func TestDiscoverFiles_ExplicitFile(t *testing.T) {
@@ -201,14 +291,15 @@ func TestDiscoverFiles_ExplicitDir(t *testing.T) {
 	dir := t.TempDir()
 	os.WriteFile(filepath.Join(dir, "one.md"), []byte("a"), 0644)
 	os.WriteFile(filepath.Join(dir, "two.md"), []byte("b"), 0644)
-	os.WriteFile(filepath.Join(dir, "three.txt"), []byte("c"), 0644) // not .md
+	os.WriteFile(filepath.Join(dir, "three.TXT"), []byte("c"), 0644)
+	os.WriteFile(filepath.Join(dir, "four.log"), []byte("d"), 0644) // not a registered format
 
 	files, err := DiscoverFiles(dir, nil, []string{dir})
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(files) != 2 {
-		t.Fatalf("expected 2 .md files, got %d: %v", len(files), files)
+	if len(files) != 3 {
+		t.Fatalf("expected 2 .md files and the .txt file, got %d: %v", len(files), files)
 	}
 }
 
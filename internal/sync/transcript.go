@@ -0,0 +1,90 @@
+package sync
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Turn is a span of text attributed to one speaker. Speaker is empty for
+// text that isn't part of a transcript, or that precedes its first turn.
+// Path is the heading path of a markdown chunk (see ChunkMarkdown).
+type Turn struct {
+	Speaker string
+	Path    string
+	Text    string
+}
+
+// speakerLine matches a transcript line such as "Lico: text",
+// "**Lico:** text", "- Lico: text" or "[10:32] Lico: text". Names are up to
+// four words and must start with a letter, which keeps URLs and times from
+// being read as speakers.
+var speakerLine = regexp.MustCompile(
+	`^\s*(?:[-*>]\s+)?(?:\[[^\]]*\]\s*)?(?:\*\*|__)?` +
+		`(\p{L}[\p{L}\p{N}._'-]*(?: [\p{L}\p{N}._'-]+){0,3})` +
+		`(?:\*\*|__)?\s*:(?:\*\*|__)?\s+\S`)
+
+// SplitTurns splits a transcript into speaker turns. Consecutive lines by the
+// same speaker, and unprefixed continuation lines, stay in one turn; each
+// turn keeps its lines verbatim, speaker prefix included.
+//
+// Text is treated as a transcript only when it has at least two distinct
+// speakers and speaker lines make up at least half of its content lines
+// (headings and blank lines aside). Otherwise SplitTurns returns nil, so
+// ordinary notes with the odd "Note: ..." line aren't chopped up.
+func SplitTurns(text string) []Turn {
+	var turns []Turn
+	var cur []string
+	speaker := ""
+	flush := func() {
+		if t := strings.TrimSpace(strings.Join(cur, "\n")); t != "" {
+			turns = append(turns, Turn{Speaker: speaker, Text: t})
+		}
+		cur = nil
+	}
+
+	speakers := make(map[string]bool)
+	content, attributed := 0, 0
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			content++
+		}
+		if m := speakerLine.FindStringSubmatch(line); m != nil {
+			attributed++
+			name := m[1]
+			speakers[strings.ToLower(name)] = true
+			if !strings.EqualFold(name, speaker) {
+				flush()
+				speaker = name
+			}
+		}
+		cur = append(cur, line)
+	}
+	flush()
+
+	if len(speakers) < 2 || attributed*2 < content {
+		return nil
+	}
+	return turns
+}
+
+// ChunkTurns chunks text like ChunkMarkdown if it is markdown, or else like
+// Chunk. When text is a transcript (see SplitTurns), each turn is chunked on
+// its own with Chunk so that no chunk mixes two speakers, and every chunk
+// carries its speaker.
+func ChunkTurns(text string, size, overlap int, markdown bool) []Turn {
+	turns := SplitTurns(text)
+	if turns == nil && markdown {
+		return ChunkMarkdown(text, size, overlap)
+	}
+	if turns == nil {
+		turns = []Turn{{Text: text}}
+	}
+	var chunks []Turn
+	for _, turn := range turns {
+		for _, c := range Chunk(turn.Text, size, overlap) {
+			chunks = append(chunks, Turn{Speaker: turn.Speaker, Text: c})
+		}
+	}
+	return chunks
+}
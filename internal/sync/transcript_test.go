@@ -0,0 +1,64 @@
+package sync
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitTurns(t *testing.T) {
+	text := `# Standup 2026-10-12
+
+Lico: The deploy is blocked on the migration.
+It needs a maintenance window.
+**Claw:** I'll draft the window announcement.
+- Claw: And ping the DBA.
+[10:32] Lico: Thanks, schedule it for Friday.`
+
+	got := SplitTurns(text)
+	want := []Turn{
+		{Speaker: "", Text: "# Standup 2026-10-12"},
+		{Speaker: "Lico", Text: "Lico: The deploy is blocked on the migration.\nIt needs a maintenance window."},
+		{Speaker: "Claw", Text: "**Claw:** I'll draft the window announcement.\n- Claw: And ping the DBA."},
+		{Speaker: "Lico", Text: "[10:32] Lico: Thanks, schedule it for Friday."},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SplitTurns:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestSplitTurns_NotATranscript(t *testing.T) {
+	tests := map[string]string{
+		"plain notes":    "Deployed the new release.\nNote: the cache warmed slowly.\nRolled back at 10:30.",
+		"single speaker": "Lico: first thought\nLico: second thought",
+		"links and times": "See https://example.com/a for details.\n" +
+			"Meeting at 10:30: room 4.\nhttp://example.com/b",
+		"mostly prose": "A: one\nB: two\n" + strings.Repeat("prose line\n", 5),
+	}
+	for name, text := range tests {
+		if turns := SplitTurns(text); turns != nil {
+			t.Errorf("%s: expected nil, got %q", name, turns)
+		}
+	}
+}
+
+func TestChunkTurns(t *testing.T) {
+	notes := ChunkTurns("Just a note.", DefaultChunkSize, DefaultChunkOverlap, true)
+	if len(notes) != 1 || notes[0].Speaker != "" || notes[0].Text != "Just a note." {
+		t.Errorf("unexpected chunks for plain text: %q", notes)
+	}
+
+	long := strings.Repeat("word ", 100)
+	chunks := ChunkTurns("Lico: "+long+"\nClaw: short reply", 200, 40, true)
+	if len(chunks) < 3 {
+		t.Fatalf("expected the long turn to be split, got %d chunks", len(chunks))
+	}
+	for _, c := range chunks[:len(chunks)-1] {
+		if c.Speaker != "Lico" {
+			t.Errorf("expected Lico's chunks first, got %q", c)
+		}
+	}
+	if last := chunks[len(chunks)-1]; last.Speaker != "Claw" || last.Text != "Claw: short reply" {
+		t.Errorf("unexpected last chunk: %q", last)
+	}
+}
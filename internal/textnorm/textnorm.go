@@ -0,0 +1,200 @@
+// Package textnorm prepares text in any script for comparison and keyword
+// matching. Fold evens out the forms the same characters are typed in
+// (fullwidth Latin, halfwidth katakana, decomposed Hangul), and Words splits
+// text into the units keyword search matches on: words for scripts that
+// separate them with spaces, character bigrams for Chinese and Japanese,
+// which don't, and for Korean words with their particles trimmed.
+package textnorm
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+	"golang.org/x/text/width"
+)
+
+// Fold returns s with fullwidth ASCII and the ideographic space narrowed,
+// halfwidth katakana widened, and the result composed (NFC), so text typed
+// on a Japanese IME or saved by a macOS app compares equal to the same text
+// typed anywhere else.
+func Fold(s string) string {
+	return norm.NFC.String(width.Fold.String(s))
+}
+
+// script is the kind of run Words splits a character into.
+type script int
+
+const (
+	other    script = iota // letters and digits of space-separated scripts
+	han                    // Chinese characters, kanji
+	hiragana               // Japanese grammar: particles, verb endings
+	katakana               // Japanese loanwords
+	hangul                 // Korean
+)
+
+func scriptOf(r rune) script {
+	switch {
+	case unicode.Is(unicode.Han, r):
+		return han
+	case unicode.Is(unicode.Hiragana, r):
+		return hiragana
+	case unicode.Is(unicode.Katakana, r), r == 'ー':
+		return katakana
+	case unicode.Is(unicode.Hangul, r):
+		return hangul
+	}
+	return other
+}
+
+// IsCJK reports whether a word returned by Words is Chinese, Japanese or
+// Korean. Those can't be matched by a word tokenizer that splits on spaces
+// alone, since it sees a whole sentence as one word.
+func IsCJK(word string) bool {
+	for _, r := range word {
+		return scriptOf(r) != other
+	}
+	return false
+}
+
+// HasCJK reports whether text contains any Chinese, Japanese or Korean.
+func HasCJK(text string) bool {
+	for _, r := range text {
+		if scriptOf(r) != other {
+			return true
+		}
+	}
+	return false
+}
+
+// koreanParticles are the endings trimmed off a Korean word before it is
+// split, longest first, so "서울에서" and "서울은" both match "서울".
+var koreanParticles = []string{
+	"에서는", "으로는", "에게서",
+	"에서", "으로", "에게", "한테", "까지", "부터", "처럼", "보다", "이나",
+	"은", "는", "이", "가", "을", "를", "에", "의", "도", "로", "와", "과", "만", "나",
+}
+
+// Words splits text into lowercase words on anything that isn't a letter
+// or digit, as a word tokenizer does, after Fold. A run of Chinese or
+// Japanese is split where its script changes (kanji, hiragana, katakana)
+// and then into overlapping pairs of characters, "東京タワー" giving 東京,
+// タワ and ワー; a run of one character is kept as it is. Korean words lose
+// a trailing particle and are split into pairs the same way.
+func Words(text string) []string {
+	var out []string
+	var run []rune
+	kind := other
+	flush := func() {
+		if len(run) > 0 {
+			out = append(out, split(run, kind)...)
+			run = run[:0]
+		}
+	}
+	for _, r := range strings.ToLower(Fold(text)) {
+		if !unicode.IsLetter(r) && !unicode.IsNumber(r) {
+			flush()
+			continue
+		}
+		if k := scriptOf(r); k != kind {
+			flush()
+			kind = k
+		}
+		run = append(run, r)
+	}
+	flush()
+	return out
+}
+
+// split turns a run of one script into words.
+func split(run []rune, kind script) []string {
+	if kind == other {
+		return []string{string(run)}
+	}
+	if kind == hangul {
+		word := string(run)
+		for _, p := range koreanParticles {
+			if stem, ok := strings.CutSuffix(word, p); ok && len([]rune(stem)) >= 2 {
+				run = []rune(stem)
+				break
+			}
+		}
+	}
+	if len(run) < 3 {
+		return []string{string(run)}
+	}
+	pairs := make([]string, 0, len(run)-1)
+	for i := 0; i+1 < len(run); i++ {
+		pairs = append(pairs, string(run[i:i+2]))
+	}
+	return pairs
+}
+
+// IsFunctionWord reports whether a word from Words carries grammar rather
+// than meaning in Chinese, Japanese or Korean, and so matches nearly every
+// memory written in it: any run of hiragana, and a few common pronouns and
+// connectives.
+func IsFunctionWord(word string) bool {
+	if cjkStopwords[word] {
+		return true
+	}
+	for _, r := range word {
+		if scriptOf(r) != hiragana {
+			return false
+		}
+	}
+	return word != ""
+}
+
+var cjkStopwords = map[string]bool{
+	// Chinese
+	"我们": true, "你们": true, "他们": true, "这个": true, "那个": true,
+	"什么": true, "怎么": true, "一个": true, "没有": true, "可以": true,
+	"就是": true, "因为": true, "所以": true, "如果": true,
+	"的": true, "了": true, "是": true, "在": true, "和": true,
+	// Japanese
+	"何": true,
+	// Korean
+	"그리": true, "리고": true, "그래": true, "래서": true, "하지": true, "지만": true,
+	"있는": true, "있다": true, "하는": true, "했다": true, "합니": true,
+	"니다": true, "습니": true, "무엇": true, "어떻": true, "어디": true,
+}
+
+// Overlap returns the Jaccard similarity of the Words of a and b: the
+// share of their distinct words they have in common, from 0 for none to 1
+// for the same. Two empty texts overlap fully.
+func Overlap(a, b string) float64 {
+	wa, wb := wordSet(a), wordSet(b)
+	if len(wa) == 0 && len(wb) == 0 {
+		return 1
+	}
+	shared := 0
+	for w := range wa {
+		if wb[w] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(wa)+len(wb)-shared)
+}
+
+// Numbers returns the distinct numbers in text, sorted: the Words made
+// only of digits, "port 8080" giving 8080 and "10:30" giving 10 and 30.
+func Numbers(text string) []string {
+	var out []string
+	for w := range wordSet(text) {
+		if strings.IndexFunc(w, func(r rune) bool { return !unicode.IsDigit(r) }) < 0 {
+			out = append(out, w)
+		}
+	}
+	slices.Sort(out)
+	return out
+}
+
+func wordSet(text string) map[string]bool {
+	set := map[string]bool{}
+	for _, w := range Words(text) {
+		set[w] = true
+	}
+	return set
+}
@@ -0,0 +1,75 @@
+package textnorm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFold(t *testing.T) {
+	for in, want := range map[string]string{
+		"ＡＰＩ　ｋｅｙ１２３":           "API key123",
+		"ﾃﾞｰﾀﾍﾞｰｽ":             "データベース",
+		"각":                  "각",
+		"already plain, 東京 서울": "already plain, 東京 서울",
+	} {
+		if got := Fold(in); got != want {
+			t.Errorf("Fold(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestWords(t *testing.T) {
+	for in, want := range map[string][]string{
+		"Deploy the auth-service": {"deploy", "the", "auth", "service"},
+		"東京タワーに行った":               {"東京", "タワ", "ワー", "に", "行", "った"},
+		"ＡＰＩキーを更新":                {"api", "キー", "を", "更新"},
+		"서울에서 회의를 했다":             {"서울", "회의", "했다"},
+		"데이터베이스는 느리다":             {"데이", "이터", "터베", "베이", "이스", "느리", "리다"},
+		"我们的数据库":                  {"我们", "们的", "的数", "数据", "据库"},
+		"":                        nil,
+	} {
+		if got := Words(in); !reflect.DeepEqual(got, want) {
+			t.Errorf("Words(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestIsFunctionWord(t *testing.T) {
+	for word, want := range map[string]bool{
+		"った": true, "に": true, "我们": true, "습니": true,
+		"東京": false, "タワ": false, "서울": false, "deploy": false,
+	} {
+		if got := IsFunctionWord(word); got != want {
+			t.Errorf("IsFunctionWord(%q) = %v, want %v", word, got, want)
+		}
+	}
+}
+
+func TestOverlap(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want float64
+	}{
+		{"Deploys go out on Thursdays", "deploys go out on thursdays!", 1},
+		{"deploys go out on thursdays", "deploys go out on tuesdays", 4.0 / 6},
+		{"the api listens on port 8080", "backups run nightly", 0},
+		{"", "", 1},
+	} {
+		if got := Overlap(tc.a, tc.b); got != tc.want {
+			t.Errorf("Overlap(%q, %q) = %v, want %v", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestNumbers(t *testing.T) {
+	for in, want := range map[string][]string{
+		"the api listens on port 8080": {"8080"},
+		"standup at 10:30, room 10":    {"10", "30"},
+		"ＰＯＲＴ ８０８０ on v2":              {"8080"},
+		"no numbers here":              nil,
+	} {
+		if got := Numbers(in); !reflect.DeepEqual(got, want) {
+			t.Errorf("Numbers(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
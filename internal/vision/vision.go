@@ -0,0 +1,74 @@
+// Package vision turns images into memories. An image is captioned by a
+// vision model and the caption is embedded like any other text, so a plain
+// text query ("the login error dialog") finds the screenshot. The image
+// itself is not copied into the store; the memory keeps a reference to the
+// file plus a content hash.
+package vision
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MaxBytes caps the size of an image sent to the vision model.
+const MaxBytes = 20 << 20
+
+// CaptionPrompt asks the vision model for a caption that works as search
+// text: concrete nouns and any legible text, rather than impressions.
+const CaptionPrompt = "Describe this image for a searchable memory log. " +
+	"Say what it shows (application, page, diagram, photo subject), transcribe any " +
+	"important visible text such as titles, error messages and labels, and note " +
+	"anything unusual. Answer in plain sentences, at most 120 words."
+
+// Image is an image file read for captioning.
+type Image struct {
+	Path   string // absolute path
+	Type   string // MIME type, e.g. "image/png"
+	SHA256 string // hex digest of the file contents
+	Data   []byte
+}
+
+// Load reads and validates an image file. It rejects files that are empty,
+// larger than MaxBytes, or not recognizably an image.
+func Load(path string) (*Image, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("resolve image path: %w", err)
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return nil, fmt.Errorf("read image: %w", err)
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("read image: %s is a directory", path)
+	}
+	if info.Size() > MaxBytes {
+		return nil, fmt.Errorf("image %s is %d bytes; the limit is %d", path, info.Size(), MaxBytes)
+	}
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		return nil, fmt.Errorf("read image: %w", err)
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("image %s is empty", path)
+	}
+	mime := http.DetectContentType(data)
+	if !strings.HasPrefix(mime, "image/") {
+		return nil, fmt.Errorf("%s is not an image (detected %s)", path, mime)
+	}
+
+	sum := sha256.Sum256(data)
+	return &Image{Path: abs, Type: mime, SHA256: hex.EncodeToString(sum[:]), Data: data}, nil
+}
+
+// Payload adds the image reference fields to a memory payload.
+func (img *Image) Payload(payload map[string]any) {
+	payload["image"] = img.Path
+	payload["image_type"] = img.Type
+	payload["image_sha256"] = img.SHA256
+}
@@ -0,0 +1,71 @@
+package vision
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// pngHeader is enough for content sniffing to report image/png.
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func writeFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	path := writeFile(t, "shot.png", pngHeader)
+
+	img, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if img.Type != "image/png" {
+		t.Errorf("expected image/png, got %q", img.Type)
+	}
+	if !filepath.IsAbs(img.Path) || img.Path != path {
+		t.Errorf("expected absolute path %q, got %q", path, img.Path)
+	}
+	if len(img.SHA256) != 64 {
+		t.Errorf("expected hex sha256, got %q", img.SHA256)
+	}
+
+	payload := map[string]any{}
+	img.Payload(payload)
+	if payload["image"] != path || payload["image_type"] != "image/png" || payload["image_sha256"] != img.SHA256 {
+		t.Errorf("unexpected payload: %v", payload)
+	}
+}
+
+func TestLoadRejects(t *testing.T) {
+	dir := t.TempDir()
+	tests := map[string]string{
+		"missing":   filepath.Join(dir, "nope.png"),
+		"directory": dir,
+		"empty":     writeFile(t, "empty.png", nil),
+		"not image": writeFile(t, "notes.png", []byte("just some text")),
+	}
+	for name, path := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Load(path); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestLoadTooLarge(t *testing.T) {
+	path := writeFile(t, "huge.png", pngHeader)
+	if err := os.Truncate(path, MaxBytes+1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "limit") {
+		t.Errorf("expected size limit error, got %v", err)
+	}
+}
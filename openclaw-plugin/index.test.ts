@@ -17,7 +17,23 @@
 import { describe, it, expect, beforeAll, afterEach } from "vitest";
 import * as net from "node:net";
 import * as fs from "node:fs";
-import { runClawbrain, type PluginConfig } from "./index.js";
+import * as http from "node:http";
+import * as os from "node:os";
+import * as path from "node:path";
+import register, {
+  runClawbrain,
+  textResult,
+  errResult,
+  syncResult,
+  pinArgs,
+  searchParams,
+  ServeClient,
+  GetOutput,
+  UpdateOutput,
+  DeleteOutput,
+  SupersedeOutput,
+  type PluginConfig,
+} from "./index.js";
 
 // ---------------------------------------------------------------------------
 // Helpers
@@ -40,7 +56,7 @@ async function run(args: string[]): Promise<Record<string, any>> {
 /** Cleanup: delete all unpinned memories. */
 async function deleteAll(): Promise<void> {
   try {
-    await runClawbrain(config, ["delete", "-d", "0"]);
+    await runClawbrain(config, ["delete", "-d", "0", "--hard"]);
   } catch {
     // Collection may not exist yet — that's fine.
   }
@@ -85,6 +101,201 @@ function isBinaryAvailable(): boolean {
 // Test suite
 // ---------------------------------------------------------------------------
 
+// --- tool results (no services needed) ------------------------------------
+
+describe("tool results", () => {
+  it("carries the CLI's JSON as text and parsed in details", () => {
+    const stdout = '{"status":"ok","id":"abc","score":0.9}\n';
+    const result = textResult(stdout);
+    expect(result.content[0].text).toBe(stdout);
+    expect(result.details).toEqual({ status: "ok", id: "abc", score: 0.9 });
+  });
+
+  it("takes the last line of streamed output as the result", () => {
+    const result = textResult('{"event":"file"}\n{"status":"ok","synced":2}\n');
+    expect(result.details).toEqual({ status: "ok", synced: 2 });
+  });
+
+  it("leaves details out when the output isn't JSON", () => {
+    expect(textResult("not json").details).toBeUndefined();
+    expect(textResult("[1,2]").details).toBeUndefined();
+  });
+
+  it("gives errors the same shape", () => {
+    const result = errResult("boom");
+    expect(result.details).toEqual({ status: "error", message: "boom" });
+    expect(JSON.parse(result.content[0].text)).toEqual(result.details);
+  });
+});
+
+// --- sync results (no services needed) ------------------------------------
+
+describe("sync results", () => {
+  const start = '{"event":"start","files":2,"resumed":false}';
+  const file = (n: number) => `{"event":"file","index":${n},"files":2,"result":{"file":"/notes/${n}.md","added":1}}`;
+  const chunk = '{"event":"chunk","file":"/notes/1.md","chunk":1,"chunks":1,"status":"added"}';
+
+  it("returns the final result with the files' progress", () => {
+    const result = syncResult([start, chunk, file(1), file(2), '{"status":"ok","files":2,"added":2}'].join("\n"));
+    expect(result.details.status).toBe("ok");
+    expect(result.details.added).toBe(2);
+    expect(result.details.progress).toHaveLength(2);
+    expect(JSON.parse(result.content[0].text)).toEqual(result.details);
+  });
+
+  it("reports a sync cut short as an error with its progress", () => {
+    const result = syncResult([start, file(1)].join("\n"));
+    expect(result.details.status).toBe("error");
+    expect(result.details.message).toContain("resume");
+    expect(result.details.progress).toHaveLength(1);
+  });
+
+  it("passes a failure before any progress through", () => {
+    const result = syncResult('{"status":"error","message":"no files to sync"}');
+    expect(result.details).toEqual({ status: "error", message: "no files to sync", progress: [] });
+  });
+});
+
+// --- serve client (no services needed) ------------------------------------
+
+describe("serve client", () => {
+  /** Start a stand-in for clawbrain serve; returns its URL and request paths. */
+  async function fakeServe(): Promise<{ url: string; paths: string[]; server: http.Server }> {
+    const paths: string[] = [];
+    const server = http.createServer((req, res) => {
+      paths.push(req.url ?? "");
+      res.setHeader("Content-Type", "application/json");
+      if (req.url?.startsWith("/search") && !req.url.includes("query=")) {
+        res.statusCode = 400;
+        res.end('{"status":"error","message":"query is required"}\n');
+        return;
+      }
+      res.end('{"status":"ok"}\n');
+    });
+    await new Promise<void>((resolve) => server.listen(0, "127.0.0.1", resolve));
+    const { port } = server.address() as net.AddressInfo;
+    return { url: `http://127.0.0.1:${port}`, paths, server };
+  }
+
+  it("checks the server once and sends calls to it", async () => {
+    const { url, paths, server } = await fakeServe();
+    try {
+      const client = new ServeClient(url + "/");
+      expect(await client.get("/search", new URLSearchParams({ query: "a" }))).toBe('{"status":"ok"}');
+      expect(await client.get("/search", new URLSearchParams({ query: "b" }))).toBe('{"status":"ok"}');
+      expect(paths).toEqual(["/pool", "/search?query=a", "/search?query=b"]);
+    } finally {
+      server.close();
+    }
+  });
+
+  it("passes error responses through", async () => {
+    const { url, server } = await fakeServe();
+    try {
+      const body = await new ServeClient(url).get("/search", new URLSearchParams());
+      expect(JSON.parse(body!)).toEqual({ status: "error", message: "query is required" });
+    } finally {
+      server.close();
+    }
+  });
+
+  it("sends the plugin's namespace with every search", () => {
+    expect(searchParams("support-bot", { query: "tls", limit: 3 }).toString()).toBe(
+      "query=tls&namespace=support-bot&limit=3",
+    );
+    expect(searchParams(undefined, { query: "tls" }).get("namespace")).toBe("");
+  });
+
+  it("falls back when the server is gone and doesn't recheck it at once", async () => {
+    const { url, paths, server } = await fakeServe();
+    const client = new ServeClient(url);
+    expect(await client.available()).toBe(true);
+    server.closeAllConnections();
+    await new Promise((resolve) => server.close(resolve));
+    expect(await client.get("/search", new URLSearchParams({ query: "a" }))).toBeUndefined();
+    expect(await client.available()).toBe(false);
+    expect(paths).toEqual(["/pool"]);
+  });
+});
+
+// --- tool arguments and output schemas (no services needed) ---------------
+
+describe("memory tools", () => {
+  /**
+   * Register the plugin against a stand-in binary that echoes its
+   * arguments, and return its tools by name.
+   */
+  function registerTools(): Map<string, any> {
+    const dir = fs.mkdtempSync(path.join(os.tmpdir(), "clawbrain-plugin-"));
+    const echo = path.join(dir, "clawbrain");
+    fs.writeFileSync(
+      echo,
+      '#!/bin/sh\nexec node -e "console.log(JSON.stringify({status: \\"ok\\", args: process.argv.slice(1)}))" -- "$@"\n',
+      { mode: 0o755 },
+    );
+    const tools = new Map<string, any>();
+    register({
+      config: { plugins: { entries: { clawbrain: { config: { binaryPath: echo } } } } },
+      registerTool: (tool: any) => tools.set(tool.name, tool),
+    });
+    return tools;
+  }
+
+  it("declares an output schema for every memory-level tool", () => {
+    const tools = registerTools();
+    const schemas: Record<string, unknown> = {
+      memory_get: GetOutput,
+      memory_update: UpdateOutput,
+      memory_delete: DeleteOutput,
+      memory_pin: UpdateOutput,
+      memory_unpin: UpdateOutput,
+      memory_supersede: SupersedeOutput,
+    };
+    for (const [name, schema] of Object.entries(schemas)) {
+      expect(tools.get(name)?.outputSchema, name).toBe(schema);
+    }
+  });
+
+  it("pins and unpins through update", async () => {
+    const tools = registerTools();
+    const pinned = await tools.get("memory_pin").execute("call", { id: "abc" });
+    expect(pinned.details.args).toEqual(["update", "--id", "abc", "--payload", '{"pinned":true}']);
+    const unpinned = await tools.get("memory_unpin").execute("call", { id: "abc" });
+    expect(unpinned.details.args).toEqual(pinArgs("abc", false));
+    expect(pinArgs("abc", false)[4]).toBe('{"pinned":null}');
+  });
+
+  it("supersedes with a new memory", async () => {
+    const tools = registerTools();
+    const result = await tools.get("memory_supersede").execute("call", {
+      ids: ["old1", "old2"],
+      text: "standup moved to 10:00",
+      tags: ["schedule"],
+    });
+    expect(result.details.args).toEqual([
+      "add", "--text", "standup moved to 10:00",
+      "--supersedes", "old1", "--supersedes", "old2",
+      "--tag", "schedule",
+    ]);
+  });
+
+  it("passes supersede preconditions through", async () => {
+    const tools = registerTools();
+    const result = await tools.get("memory_supersede").execute("call", {
+      ids: ["old1"],
+      text: "standup moved to 10:00",
+      if_version: 2,
+      if_last_accessed_before: "2026-10-15T09:00:00Z",
+    });
+    expect(result.details.args).toEqual([
+      "add", "--text", "standup moved to 10:00",
+      "--supersedes", "old1",
+      "--if-version", "2",
+      "--if-last-accessed-before", "2026-10-15T09:00:00Z",
+    ]);
+  });
+});
+
 describe("ClawBrain plugin", () => {
   let skipAll = false;
 
@@ -158,6 +369,22 @@ describe("ClawBrain plugin", () => {
       expect(payload.last_accessed).toBeTruthy();
     });
 
+    it("adds a batch from stdin", async (ctx) => {
+      if (skipAll) { ctx.skip(); return; }
+
+      const lines = [
+        { text: "the staging server lives in frankfurt" },
+        { text: "backups run nightly at 02:00", payload: { source: "runbook" } },
+      ].map((m) => JSON.stringify(m)).join("\n");
+      const result = parseJSON(await runClawbrain(config, ["add", "--batch-file", "-", "--no-merge"], lines));
+      expect(result.status).toBe("ok");
+      expect(result.added).toBe(2);
+      expect(result.ids).toHaveLength(2);
+
+      const fetched = await run(["get", "--id", result.ids[1]]);
+      expect(fetched.payload.source).toBe("runbook");
+    });
+
     it("honors custom ID", async (ctx) => {
       if (skipAll) { ctx.skip(); return; }
 
@@ -227,6 +454,30 @@ describe("ClawBrain plugin", () => {
     });
   });
 
+  // --- update ---------------------------------------------------------------
+
+  describe("memory_update", () => {
+    afterEach(async () => {
+      if (!skipAll) await deleteAll();
+    });
+
+    it("rewrites the text and keeps created_at", async (ctx) => {
+      if (skipAll) { ctx.skip(); return; }
+
+      const added = await run(["add", "--text", "standup is at 09:30", "--no-merge"]);
+      const before = await run(["get", "--id", added.id]);
+
+      const result = await run(["update", "--id", added.id, "--text", "standup is at 10:00", "--if-version", "1"]);
+      expect(result.status).toBe("ok");
+      expect(result.revision).toBe(2);
+      expect(result.reembedded).toBe(true);
+
+      const after = await run(["get", "--id", added.id]);
+      expect(after.payload.text).toBe("standup is at 10:00");
+      expect(after.payload.created_at).toBe(before.payload.created_at);
+    });
+  });
+
   // --- delete ---------------------------------------------------------------
 
   describe("memory_delete", () => {
@@ -237,7 +488,7 @@ describe("ClawBrain plugin", () => {
       expect(addResult.status).toBe("ok");
       const memoryID = addResult.id;
 
-      const result = await run(["delete", "-d", "0"]);
+      const result = await run(["delete", "-d", "0", "--hard"]);
       expect(result.status).toBe("ok");
       expect(result.deleted).toBeGreaterThanOrEqual(1);
 
@@ -262,7 +513,7 @@ describe("ClawBrain plugin", () => {
 
       // Delete with 0 days
       const deleteResult = await run(["delete", "-d", "0"]);
-      expect(deleteResult.deleted).toBeGreaterThanOrEqual(1);
+      expect(deleteResult.archived).toBeGreaterThanOrEqual(1);
 
       // Verify pinned memory still exists
       const getResult = await run(["get", "--id", pinnedID]);
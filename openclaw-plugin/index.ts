@@ -1,5 +1,5 @@
 import { execFile } from "node:child_process";
-import { Type } from "@sinclair/typebox";
+import { Type, type Static } from "@sinclair/typebox";
 
 // ---------------------------------------------------------------------------
 // Execution layer — runs clawbrain CLI via Docker or directly
@@ -9,6 +9,8 @@ interface PluginConfig {
   composePath?: string;
   serviceName?: string;
   binaryPath?: string;
+  namespace?: string;
+  serveUrl?: string;
 }
 
 function resolveConfig(api: any): PluginConfig {
@@ -17,21 +19,34 @@ function resolveConfig(api: any): PluginConfig {
     composePath: cfg.composePath,
     serviceName: cfg.serviceName || "clawbrain",
     binaryPath: cfg.binaryPath,
+    namespace: cfg.namespace,
+    serveUrl: cfg.serveUrl,
   };
 }
 
 /** Default timeout for child process execution (60 seconds). */
 const EXEC_TIMEOUT_MS = 60_000;
 
+/** Timeout of a sync, which embeds every new chunk (10 minutes). */
+const SYNC_TIMEOUT_MS = 600_000;
+
+/**
+ * Timeout of a search a generative model takes part in: a reranked one
+ * grades up to 50 matches, an expanded one paraphrases the query (3 minutes).
+ */
+const MODEL_SEARCH_TIMEOUT_MS = 180_000;
+
 function execPromise(
   cmd: string,
   args: string[],
+  input?: string,
+  timeoutMs: number = EXEC_TIMEOUT_MS,
 ): Promise<{ stdout: string; stderr: string }> {
   return new Promise((resolve, reject) => {
-    execFile(
+    const child = execFile(
       cmd,
       args,
-      { maxBuffer: 10 * 1024 * 1024, timeout: EXEC_TIMEOUT_MS },
+      { maxBuffer: 10 * 1024 * 1024, timeout: timeoutMs },
       (err, stdout, stderr) => {
         if (err) {
           // CLI returns JSON errors on stdout with exit code 0 in some cases,
@@ -42,6 +57,20 @@ function execPromise(
             resolve({ stdout: stdout.trim(), stderr: stderr?.trim() ?? "" });
             return;
           }
+          // Killed by our timeout: the backends are too slow to answer.
+          // Report it like the CLI reports an overloaded backend, so the
+          // agent waits and retries instead of treating it as a failure.
+          if ((err as any).killed) {
+            resolve({
+              stdout: JSON.stringify({
+                status: "backoff",
+                message: `clawbrain did not answer within ${timeoutMs / 1000}s`,
+                retry_after: 30,
+              }),
+              stderr: stderr?.trim() ?? "",
+            });
+            return;
+          }
           // Include stderr context in the error message when available.
           const stderrMsg = stderr?.trim();
           const detail = stderrMsg ? `${err.message} — stderr: ${stderrMsg}` : err.message;
@@ -51,6 +80,10 @@ function execPromise(
         resolve({ stdout: stdout.trim(), stderr: stderr?.trim() ?? "" });
       },
     );
+    // Commands reading a file from "-" get it on stdin.
+    if (input !== undefined) {
+      child.stdin?.end(input);
+    }
   });
 }
 
@@ -60,13 +93,22 @@ function execPromise(
  * Two modes:
  * - Binary mode (binaryPath set): runs the binary directly
  * - Docker mode (default): docker compose exec -T <service> clawbrain ...
+ *
+ * `input`, if given, is written to the command's stdin. A configured
+ * namespace is passed as the global --namespace flag. Commands slower
+ * than EXEC_TIMEOUT_MS pass their own timeoutMs.
  */
 async function runClawbrain(
   config: PluginConfig,
   args: string[],
+  input?: string,
+  timeoutMs?: number,
 ): Promise<string> {
+  if (config.namespace) {
+    args = ["--namespace", config.namespace, ...args];
+  }
   if (config.binaryPath) {
-    const { stdout } = await execPromise(config.binaryPath, args);
+    const { stdout } = await execPromise(config.binaryPath, args, input, timeoutMs);
     return stdout;
   }
 
@@ -76,20 +118,337 @@ async function runClawbrain(
   }
   composeArgs.push("exec", "-T", config.serviceName!, "clawbrain", ...args);
 
-  const { stdout } = await execPromise("docker", ["compose", ...composeArgs]);
+  const { stdout } = await execPromise("docker", ["compose", ...composeArgs], input, timeoutMs);
   return stdout;
 }
 
+// ---------------------------------------------------------------------------
+// Server layer — sends searches to a running `clawbrain serve`
+// ---------------------------------------------------------------------------
+
+/** How long a server that failed is left alone before it is checked again. */
+const SERVE_RETRY_MS = 30_000;
+
+/** Timeout of a health check; a server that slow is treated as down. */
+const SERVE_HEALTH_TIMEOUT_MS = 2_000;
+
+/**
+ * A long-running `clawbrain serve`. Calls sent to it reuse its Qdrant
+ * connections and its warm embedder instead of starting a CLI process,
+ * which connects afresh, for every call.
+ *
+ * The server is checked lazily: on first use, and again on the first use
+ * SERVE_RETRY_MS after it failed. A server that can't be reached makes
+ * get() return undefined, and the caller falls back to the CLI until the
+ * server answers a health check again. Answers with an error status are
+ * the server's to give and are returned like the CLI's.
+ */
+class ServeClient {
+  private healthy = false;
+  private retryAt = 0;
+
+  constructor(private readonly baseUrl: string) {
+    this.baseUrl = baseUrl.replace(/\/+$/, "");
+  }
+
+  /** Whether to send calls to the server now, checking it if it's due. */
+  async available(): Promise<boolean> {
+    if (this.healthy) {
+      return true;
+    }
+    if (Date.now() < this.retryAt) {
+      return false;
+    }
+    try {
+      // /pool is never shed or cached, and doesn't touch Qdrant.
+      const res = await fetch(`${this.baseUrl}/pool`, {
+        signal: AbortSignal.timeout(SERVE_HEALTH_TIMEOUT_MS),
+      });
+      this.healthy = res.ok;
+    } catch {
+      this.healthy = false;
+    }
+    if (!this.healthy) {
+      this.retryAt = Date.now() + SERVE_RETRY_MS;
+    }
+    return this.healthy;
+  }
+
+  /**
+   * GET path with params and return the response body, or undefined if
+   * the server can't be reached and the call should go to the CLI.
+   */
+  async get(path: string, params: URLSearchParams): Promise<string | undefined> {
+    if (!(await this.available())) {
+      return undefined;
+    }
+    try {
+      const res = await fetch(`${this.baseUrl}${path}?${params}`, {
+        signal: AbortSignal.timeout(EXEC_TIMEOUT_MS),
+      });
+      return (await res.text()).trim();
+    } catch {
+      this.healthy = false;
+      this.retryAt = Date.now() + SERVE_RETRY_MS;
+      return undefined;
+    }
+  }
+}
+
+/**
+ * The URL parameters of GET /search for memory_search's params. The
+ * plugin's namespace always goes along, "" for the default one, so a
+ * server started on another namespace refuses the search rather than
+ * answering it from the wrong memories.
+ */
+function searchParams(namespace: string | undefined, params: {
+  query: string;
+  limit?: number;
+  select?: string[];
+  cursor?: string;
+  min_score?: number;
+  tags?: string[];
+  must_contain?: string[];
+  since?: string;
+  until?: string;
+  time_field?: string;
+  recency_boost?: number;
+  recency_scale?: string;
+  boosts?: Record<string, number>;
+  related?: boolean;
+}): URLSearchParams {
+  const q = new URLSearchParams({ query: params.query, namespace: namespace ?? "" });
+  if (params.limit !== undefined) {
+    q.set("limit", String(params.limit));
+  }
+  if (params.select?.length) {
+    q.set("select", params.select.join(","));
+  }
+  if (params.cursor) {
+    q.set("cursor", params.cursor);
+  }
+  if (params.min_score !== undefined) {
+    q.set("min_score", String(params.min_score));
+  }
+  for (const tag of params.tags ?? []) {
+    q.append("tag", tag);
+  }
+  for (const phrase of params.must_contain ?? []) {
+    q.append("must_contain", phrase);
+  }
+  if (params.since) {
+    q.set("since", params.since);
+  }
+  if (params.until) {
+    q.set("until", params.until);
+  }
+  if (params.time_field) {
+    q.set("time_field", params.time_field);
+  }
+  if (params.recency_boost !== undefined) {
+    q.set("recency_boost", String(params.recency_boost));
+  }
+  if (params.recency_scale !== undefined) {
+    q.set("recency_scale", params.recency_scale);
+  }
+  for (const [type, boost] of Object.entries(params.boosts ?? {})) {
+    q.append("boost", `type:${type}=${boost}`);
+  }
+  if (params.related) {
+    q.set("related", "true");
+  }
+  return q;
+}
+
 // ---------------------------------------------------------------------------
 // Tool result helpers
 // ---------------------------------------------------------------------------
 
+/** The JSON every clawbrain command answers with: a status and its fields. */
+interface ToolDetails {
+  status: string;
+  [field: string]: unknown;
+}
+
+/**
+ * Parse the CLI's answer for a tool result's structured details. Commands
+ * print one JSON object; the few that stream events first print one per
+ * line and end with the result, which is the one returned. Anything that
+ * isn't JSON yields undefined, and the agent gets the text alone.
+ */
+function parseDetails(text: string): ToolDetails | undefined {
+  const lines = text.trim().split("\n");
+  for (const candidate of [text, lines[lines.length - 1]]) {
+    try {
+      const parsed = JSON.parse(candidate);
+      if (parsed && typeof parsed === "object" && !Array.isArray(parsed)) {
+        return parsed as ToolDetails;
+      }
+    } catch {
+      // Not JSON; try the last line, then give up.
+    }
+  }
+  return undefined;
+}
+
+/**
+ * A tool result carrying the CLI's JSON twice: as text for the model, and
+ * parsed in details for clients that read results programmatically, so
+ * they needn't parse the text again.
+ */
 function textResult(text: string) {
-  return { content: [{ type: "text" as const, text }] };
+  return { content: [{ type: "text" as const, text }], details: parseDetails(text) };
 }
 
 function errResult(msg: string) {
-  return { content: [{ type: "text" as const, text: JSON.stringify({ status: "error", message: msg }) }] };
+  const details: ToolDetails = { status: "error", message: msg };
+  return { content: [{ type: "text" as const, text: JSON.stringify(details) }], details };
+}
+
+/**
+ * The result of `sync --progress`: its final JSON line, with the file
+ * events before it in `progress`, one per file finished. A sync cut short
+ * -- by the timeout, say -- has no final line; it is reported as an error
+ * carrying the progress made, which `resume` picks up from.
+ */
+function syncResult(stdout: string) {
+  const progress: Record<string, unknown>[] = [];
+  let final: ToolDetails | undefined;
+  for (const line of stdout.split("\n")) {
+    let parsed: any;
+    try {
+      parsed = JSON.parse(line);
+    } catch {
+      continue;
+    }
+    if (!parsed || typeof parsed !== "object" || Array.isArray(parsed)) {
+      continue;
+    }
+    if (parsed.event === undefined) {
+      final = parsed;
+    } else if (parsed.event === "file") {
+      progress.push(parsed);
+    }
+  }
+  // A backoff answer (the timeout) or a failure before any progress.
+  if (final !== undefined && (final.status === "ok" || progress.length === 0)) {
+    const details: ToolDetails = { ...final, progress };
+    return { content: [{ type: "text" as const, text: JSON.stringify(details) }], details };
+  }
+  const details: ToolDetails = {
+    status: "error",
+    message: `sync stopped after finishing ${progress.length} files; call memory_sync again with resume to finish`,
+    ...(final ? { cause: final } : {}),
+    progress,
+  };
+  return { content: [{ type: "text" as const, text: JSON.stringify(details) }], details };
+}
+
+// ---------------------------------------------------------------------------
+// Tool output schemas
+// ---------------------------------------------------------------------------
+
+/**
+ * Fields every result has. status is "ok", or "error" with a message; an
+ * update or supersede can also answer "conflict" and a busy backend
+ * "backoff".
+ */
+const Outcome = {
+  status: Type.String({ description: "\"ok\", or why not: \"error\", \"conflict\" or \"backoff\"" }),
+  message: Type.Optional(Type.String({ description: "What went wrong, when status isn't \"ok\"" })),
+};
+
+const Payload = Type.Record(Type.String(), Type.Unknown(), {
+  description: "The memory's payload: text, created_at, last_accessed, revision, pinned, tags, ...",
+});
+
+const Memory = Type.Object({
+  id: Type.String(),
+  payload: Payload,
+});
+
+/** What memory_get returns: one memory, or with ids several. */
+const GetOutput = Type.Object({
+  ...Outcome,
+  id: Type.Optional(Type.String()),
+  payload: Type.Optional(Payload),
+  card: Type.Optional(Type.String({ description: "A decision rendered for reading" })),
+  summarized: Type.Optional(Type.Boolean({ description: "With summarize, whether the text was summarized" })),
+  summary: Type.Optional(Type.String({ description: "With summarize, the summary standing in for a long text" })),
+  full_text: Type.Optional(
+    Type.Object(
+      { id: Type.String(), field: Type.String(), length: Type.Integer() },
+      { description: "Where the summarized text is, and its length in characters" },
+    ),
+  ),
+  memories: Type.Optional(Type.Array(Memory)),
+  returned: Type.Optional(Type.Integer()),
+  missing: Type.Optional(Type.Array(Type.String(), { description: "Requested IDs that matched nothing" })),
+});
+
+/** What memory_update, memory_pin and memory_unpin return. */
+const UpdateOutput = Type.Object({
+  ...Outcome,
+  id: Type.Optional(Type.String()),
+  revision: Type.Optional(Type.Integer({ description: "The memory's revision after the change" })),
+  reembedded: Type.Optional(Type.Boolean({ description: "Whether new text was embedded" })),
+  conflict: Type.Optional(
+    Type.Record(Type.String(), Type.Unknown(), {
+      description: "On a conflict, the memory's current revision and last_accessed, or missing",
+    }),
+  ),
+});
+
+/** What memory_delete returns. */
+const DeleteOutput = Type.Object({
+  ...Outcome,
+  deleted: Type.Optional(Type.Integer()),
+  archived: Type.Optional(Type.Integer({ description: "Old memories archived rather than deleted" })),
+  would_delete: Type.Optional(Type.Integer({ description: "With dry_run, how many would be deleted" })),
+  would_archive: Type.Optional(Type.Integer({ description: "With dry_run, how many old memories would be archived" })),
+  ids: Type.Optional(Type.Array(Type.String(), { description: "IDs of the deleted memories" })),
+  memories: Type.Optional(
+    Type.Array(Type.Record(Type.String(), Type.Unknown()), {
+      description: "With dry_run, the memories that would be deleted",
+    }),
+  ),
+  matched: Type.Optional(Type.Integer()),
+  missing: Type.Optional(Type.Array(Type.String())),
+  skipped_locked: Type.Optional(Type.Integer()),
+});
+
+/** What memory_supersede returns. */
+const SupersedeOutput = Type.Object({
+  ...Outcome,
+  id: Type.Optional(Type.String({ description: "UUID of the new memory" })),
+  revision: Type.Optional(Type.Integer()),
+  supersedes: Type.Optional(Type.Array(Type.String(), { description: "IDs of the memories marked superseded" })),
+  merged_ids: Type.Optional(Type.Array(Type.String(), { description: "Duplicates the new memory replaced" })),
+  conflict: Type.Optional(
+    Type.Record(Type.String(), Type.Unknown(), {
+      description: "On a conflict, the superseded memory's current revision and last_accessed, or missing",
+    }),
+  ),
+});
+
+type GetDetails = Static<typeof GetOutput>;
+type UpdateDetails = Static<typeof UpdateOutput>;
+type DeleteDetails = Static<typeof DeleteOutput>;
+type SupersedeDetails = Static<typeof SupersedeOutput>;
+
+/**
+ * textResult with details typed as T, for the tools that declare an
+ * outputSchema. The CLI's JSON is what the schema describes; the type
+ * only tells a client in code which fields to expect.
+ */
+function typedResult<T extends ToolDetails>(text: string) {
+  return textResult(text) as { content: { type: "text"; text: string }[]; details: T | undefined };
+}
+
+/** The update that sets a memory's pinned flag. */
+function pinArgs(id: string, pinned: boolean): string[] {
+  // Unpinning removes the field, as if the memory was never pinned.
+  return ["update", "--id", id, "--payload", JSON.stringify({ pinned: pinned ? true : null })];
 }
 
 // ---------------------------------------------------------------------------
@@ -98,6 +457,7 @@ function errResult(msg: string) {
 
 export default function register(api: any) {
   const config = resolveConfig(api);
+  const serve = config.serveUrl ? new ServeClient(config.serveUrl) : undefined;
 
   // --- memory_add -----------------------------------------------------------
   api.registerTool({
@@ -126,8 +486,41 @@ export default function register(api: any) {
           description: "Skip deduplication — store without checking for similar memories",
         }),
       ),
+      dedup_threshold: Type.Optional(
+        Type.Number({
+          description: "Similarity (0-1] at or above which an existing memory is merged as a duplicate (default 0.92)",
+        }),
+      ),
+      tags: Type.Optional(
+        Type.Array(Type.String(), {
+          description: "Tags for the memory, e.g. [\"project:billing\", \"priority:high\"]",
+        }),
+      ),
+      dry_run: Type.Optional(
+        Type.Boolean({
+          description: "Report the payload and the duplicates it would merge without storing anything",
+        }),
+      ),
+      verbose: Type.Optional(
+        Type.Boolean({
+          description: "Explain which similar memories were found and why each was or wasn't merged, and return the stored payload",
+        }),
+      ),
     }),
-    async execute(_id: string, params: { text: string; payload?: string; id?: string; pinned?: boolean; no_merge?: boolean }) {
+    async execute(
+      _id: string,
+      params: {
+        text: string;
+        payload?: string;
+        id?: string;
+        pinned?: boolean;
+        no_merge?: boolean;
+        dedup_threshold?: number;
+        tags?: string[];
+        dry_run?: boolean;
+        verbose?: boolean;
+      },
+    ) {
       try {
         const args = ["add", "--text", params.text];
         if (params.payload) {
@@ -142,6 +535,18 @@ export default function register(api: any) {
         if (params.no_merge) {
           args.push("--no-merge");
         }
+        if (params.dedup_threshold !== undefined) {
+          args.push("--dedup-threshold", String(params.dedup_threshold));
+        }
+        for (const tag of params.tags ?? []) {
+          args.push("--tag", tag);
+        }
+        if (params.dry_run) {
+          args.push("--dry-run");
+        }
+        if (params.verbose) {
+          args.push("--verbose");
+        }
         const stdout = await runClawbrain(config, args);
         return textResult(stdout);
       } catch (e: any) {
@@ -150,11 +555,60 @@ export default function register(api: any) {
     },
   });
 
+  // --- memory_add_batch -----------------------------------------------------
+  api.registerTool({
+    name: "memory_add_batch",
+    description:
+      "Store many memories at once. Texts are embedded in batches and written in a single upsert, which is much faster than calling memory_add for each. Returns the UUIDs in input order.",
+    parameters: Type.Object({
+      memories: Type.Array(
+        Type.Object({
+          text: Type.String({ description: "The text to store as a memory" }),
+          payload: Type.Optional(
+            Type.Record(Type.String(), Type.Unknown(), {
+              description: "Additional metadata (e.g. {\"source\": \"chat\"})",
+            }),
+          ),
+        }),
+        { description: "The memories to store", minItems: 1 },
+      ),
+      pinned: Type.Optional(
+        Type.Boolean({
+          description: "Pin every memory to prevent automatic forgetting",
+        }),
+      ),
+      no_merge: Type.Optional(
+        Type.Boolean({
+          description: "Skip deduplication — store without checking for similar memories",
+        }),
+      ),
+    }),
+    async execute(
+      _id: string,
+      params: { memories: { text: string; payload?: Record<string, unknown> }[]; pinned?: boolean; no_merge?: boolean },
+    ) {
+      try {
+        const args = ["add", "--batch-file", "-"];
+        if (params.pinned) {
+          args.push("--pinned");
+        }
+        if (params.no_merge) {
+          args.push("--no-merge");
+        }
+        const lines = params.memories.map((m) => JSON.stringify(m)).join("\n");
+        const stdout = await runClawbrain(config, args, lines + "\n");
+        return textResult(stdout);
+      } catch (e: any) {
+        return errResult(e.message);
+      }
+    },
+  });
+
   // --- memory_search --------------------------------------------------------
   api.registerTool({
     name: "memory_search",
     description:
-      "Search memories by semantic similarity. Your query is embedded and compared against stored memories. Returns ranked results with similarity scores and a confidence level (high/medium/low/none). Call this multiple times with different or refined queries to deepen recall. If confidence is 'low' or 'none', rephrase your query or try a different angle before giving up. Increase the limit to 3-5 for broader context per search.",
+      "Search memories by semantic similarity. Your query is embedded and compared against stored memories. Returns ranked results with similarity scores and a confidence level (high/medium/low/none). Call this multiple times with different or refined queries to deepen recall. Without a limit, a low-confidence search is retried once with more results and keyword matching, reported in 'widened'. If confidence is still 'low' or 'none', rephrase your query or try a different angle before giving up. Increase the limit to 3-5 for broader context per search. A full page comes with a next_cursor; pass it back as cursor to see the next results.",
     parameters: Type.Object({
       query: Type.String({
         description: "Text to search for (semantic search)",
@@ -165,6 +619,17 @@ export default function register(api: any) {
           minimum: 1,
         }),
       ),
+      select: Type.Optional(
+        Type.Array(Type.String(), {
+          description:
+            "Only return these fields of each result, e.g. [\"id\", \"score\", \"payload.text\"], to keep large result sets small",
+        }),
+      ),
+      cursor: Type.Optional(
+        Type.String({
+          description: "The next_cursor from a previous search with the same query, to get the next page of results",
+        }),
+      ),
       min_score: Type.Optional(
         Type.Number({
           description: "Minimum similarity score threshold (default 0.0)",
@@ -172,17 +637,145 @@ export default function register(api: any) {
           maximum: 1,
         }),
       ),
+      tags: Type.Optional(
+        Type.Array(Type.String(), {
+          description: "Only memories carrying every one of these tags, e.g. [\"project:billing\"]",
+        }),
+      ),
+      must_contain: Type.Optional(
+        Type.Array(Type.String(), {
+          description:
+            "Only memories whose text contains every one of these exact phrases, ignoring case, e.g. [\"link-tracker\"] when searching for a named thing",
+        }),
+      ),
+      since: Type.Optional(
+        Type.String({
+          description:
+            "Only memories stored at or after this time: a duration ago like \"24h\" or \"7d\", an RFC 3339 timestamp or a date (2006-01-02). Use \"24h\" for what you stored in the last day.",
+        }),
+      ),
+      until: Type.Optional(
+        Type.String({
+          description: "Only memories stored before this time (same forms as since)",
+        }),
+      ),
+      time_field: Type.Optional(
+        Type.String({
+          description: "Which time since and until compare: created_at (default) or last_accessed, for memories you used lately",
+        }),
+      ),
+      recency_boost: Type.Optional(
+        Type.Number({
+          description:
+            "Add up to this much to the score of recently accessed memories (e.g. 0.05), so the ones you've used lately win near-ties",
+          minimum: 0,
+        }),
+      ),
+      recency_scale: Type.Optional(
+        Type.String({
+          description: "Half-life of the recency boost, e.g. \"7d\" (default) or \"48h\"",
+        }),
+      ),
+      boosts: Type.Optional(
+        Type.Record(Type.String(), Type.Number({ minimum: 0 }), {
+          description:
+            "Add to the score of memories by type, e.g. {\"todo\": 0.05, \"lesson\": 0.05}, to favor them when orienting",
+        }),
+      ),
+      related: Type.Optional(
+        Type.Boolean({
+          description:
+            "Also return, in 'related', the IDs of each result's 2 nearest neighbors and the memories it links to. Fetch the ones you want with a single memory_get call (ids) instead of searching again.",
+        }),
+      ),
+      rerank: Type.Optional(
+        Type.Boolean({
+          description:
+            "Have the LLM grade the 50 best matches against your query and return the best graded. Slower, but better when the top results share your topic without answering your question. Can't be combined with cursor.",
+        }),
+      ),
+      expand: Type.Optional(
+        Type.Boolean({
+          description:
+            "Also search for 3 paraphrases of your query from the LLM and fuse the results. Finds memories worded differently from your question. Can't be combined with cursor.",
+        }),
+      ),
     }),
-    async execute(_id: string, params: { query: string; limit?: number; min_score?: number }) {
+    async execute(
+      _id: string,
+      params: {
+        query: string;
+        limit?: number;
+        select?: string[];
+        cursor?: string;
+        min_score?: number;
+        tags?: string[];
+        must_contain?: string[];
+        since?: string;
+        until?: string;
+        time_field?: string;
+        recency_boost?: number;
+        recency_scale?: string;
+        boosts?: Record<string, number>;
+        related?: boolean;
+        rerank?: boolean;
+        expand?: boolean;
+      },
+    ) {
       try {
         const args = ["search", "--query", params.query];
         if (params.limit !== undefined) {
           args.push("--limit", String(params.limit));
         }
+        if (params.select?.length) {
+          args.push("--select", params.select.join(","));
+        }
+        if (params.cursor) {
+          args.push("--cursor", params.cursor);
+        }
         if (params.min_score !== undefined) {
           args.push("--min-score", String(params.min_score));
         }
-        const stdout = await runClawbrain(config, args);
+        for (const tag of params.tags ?? []) {
+          args.push("--tag", tag);
+        }
+        for (const phrase of params.must_contain ?? []) {
+          args.push("--must-contain", phrase);
+        }
+        if (params.since) {
+          args.push("--since", params.since);
+        }
+        if (params.until) {
+          args.push("--until", params.until);
+        }
+        if (params.time_field) {
+          args.push("--time-field", params.time_field);
+        }
+        if (params.recency_boost !== undefined) {
+          args.push("--recency-boost", String(params.recency_boost));
+        }
+        if (params.recency_scale !== undefined) {
+          args.push("--recency-scale", params.recency_scale);
+        }
+        for (const [type, boost] of Object.entries(params.boosts ?? {})) {
+          args.push("--boost", `type:${type}=${boost}`);
+        }
+        if (params.related) {
+          args.push("--related");
+        }
+        if (params.rerank) {
+          args.push("--rerank");
+        }
+        if (params.expand) {
+          args.push("--expand");
+        }
+        // Model calls outlast the server's request timeout; they run in the CLI.
+        const usesModel = params.rerank || params.expand;
+        const body = serve && !usesModel ? await serve.get("/search", searchParams(config.namespace, params)) : undefined;
+        if (body !== undefined) {
+          return textResult(body);
+        }
+        const stdout = await runClawbrain(config, args, undefined, usesModel ? MODEL_SEARCH_TIMEOUT_MS : undefined);
         return textResult(stdout);
       } catch (e: any) {
         return errResult(e.message);
@@ -194,14 +787,68 @@ export default function register(api: any) {
   api.registerTool({
     name: "memory_get",
     description:
-      "Fetch a single memory by its UUID. Returns the full payload including text and metadata.",
+      "Fetch memories by UUID: one with id, or several in one call with ids (e.g. the related IDs from memory_search). Returns the full payload including text and metadata; IDs that match nothing are listed in 'missing'. With summarize, a long text (a big synced chunk, say) comes back as a summary, with its length in full_text; fetch it whole later if you need it.",
     parameters: Type.Object({
-      id: Type.String({ description: "UUID of the memory to fetch" }),
+      id: Type.Optional(Type.String({ description: "UUID of the memory to fetch" })),
+      ids: Type.Optional(
+        Type.Array(Type.String(), { description: "UUIDs of several memories to fetch at once" }),
+      ),
+      summarize: Type.Optional(
+        Type.Boolean({
+          description: "Return texts over 1000 characters as a summary instead, to save context",
+        }),
+      ),
     }),
-    async execute(_id: string, params: { id: string }) {
+    outputSchema: GetOutput,
+    async execute(_id: string, params: { id?: string; ids?: string[]; summarize?: boolean }) {
       try {
-        const stdout = await runClawbrain(config, ["get", "--id", params.id]);
-        return textResult(stdout);
+        const args = params.ids?.length ? ["get", "--ids", params.ids.join(",")] : ["get", "--id", params.id ?? ""];
+        if (params.summarize) {
+          args.push("--summarize");
+        }
+        const stdout = await runClawbrain(config, args);
+        return typedResult<GetDetails>(stdout);
+      } catch (e: any) {
+        return errResult(e.message);
+      }
+    },
+  });
+
+  // --- memory_update --------------------------------------------------------
+  api.registerTool({
+    name: "memory_update",
+    description:
+      "Correct a memory in place by its UUID. New text is re-embedded; payload fields are merged into the stored ones (null removes a field). created_at, pinned status and other fields are kept and the revision goes up by one. Pass if_version with the revision you read to avoid overwriting someone else's change; a conflict response means re-read and retry.",
+    parameters: Type.Object({
+      id: Type.String({ description: "UUID of the memory to update" }),
+      text: Type.Optional(Type.String({ description: "New text for the memory" })),
+      payload: Type.Optional(
+        Type.String({
+          description: "JSON object merged into the payload, e.g. '{\"source\": \"meeting\"}'",
+        }),
+      ),
+      if_version: Type.Optional(
+        Type.Integer({
+          description: "Only update if the memory is still at this revision",
+          minimum: 0,
+        }),
+      ),
+    }),
+    outputSchema: UpdateOutput,
+    async execute(_id: string, params: { id: string; text?: string; payload?: string; if_version?: number }) {
+      try {
+        const args = ["update", "--id", params.id];
+        if (params.text) {
+          args.push("--text", params.text);
+        }
+        if (params.payload) {
+          args.push("--payload", params.payload);
+        }
+        if (params.if_version !== undefined) {
+          args.push("--if-version", String(params.if_version));
+        }
+        const stdout = await runClawbrain(config, args);
+        return typedResult<UpdateDetails>(stdout);
       } catch (e: any) {
         return errResult(e.message);
       }
@@ -213,24 +860,57 @@ export default function register(api: any) {
     {
       name: "memory_delete",
       description:
-        "Delete old memories. Removes memories not accessed in the last N days. Pinned memories are never deleted. Returns the count of deleted memories.",
+        "Delete memories. With ids or filters, deletes those memories; otherwise archives memories not accessed in the last N days, or deletes them with hard. Locked memories are never deleted, and pinned ones only when named by ID. Use dry_run first to see what would go. Returns the count and IDs of deleted memories, or the count archived.",
       parameters: Type.Object({
+        ids: Type.Optional(
+          Type.Array(Type.String(), {
+            description: "UUIDs of the memories to delete",
+          }),
+        ),
+        filters: Type.Optional(
+          Type.Array(Type.String(), {
+            description: "Delete memories matching every one of these payload conditions, e.g. [\"source=import\"]",
+          }),
+        ),
         days: Type.Optional(
           Type.Integer({
             description:
-              "Delete memories not accessed in the last N days (default 30).",
+              "Archive memories not accessed in the last N days (default 30); they are only deleted permanently with hard.",
             minimum: 0,
           }),
         ),
+        hard: Type.Optional(
+          Type.Boolean({
+            description: "Delete old memories outright instead of archiving them",
+          }),
+        ),
+        dry_run: Type.Optional(
+          Type.Boolean({
+            description: "List the memories that would be archived, or deleted with hard, ids or filter, without changing anything",
+          }),
+        ),
       }),
-      async execute(_id: string, params: { days?: number }) {
+      outputSchema: DeleteOutput,
+      async execute(_id: string, params: { ids?: string[]; filters?: string[]; days?: number; hard?: boolean; dry_run?: boolean }) {
         try {
           const args = ["delete"];
+          if (params.ids?.length) {
+            args.push("--ids", params.ids.join(","));
+          }
+          for (const filter of params.filters ?? []) {
+            args.push("--filter", filter);
+          }
           if (params.days !== undefined) {
             args.push("-d", String(params.days));
           }
+          if (params.hard) {
+            args.push("--hard");
+          }
+          if (params.dry_run) {
+            args.push("--dry-run");
+          }
           const stdout = await runClawbrain(config, args);
-          return textResult(stdout);
+          return typedResult<DeleteDetails>(stdout);
         } catch (e: any) {
           return errResult(e.message);
         }
@@ -239,6 +919,173 @@ export default function register(api: any) {
     { optional: true },
   );
 
+  // --- memory_pin / memory_unpin --------------------------------------------
+  for (const pinned of [true, false]) {
+    api.registerTool({
+      name: pinned ? "memory_pin" : "memory_unpin",
+      description: pinned
+        ? "Pin a memory by its UUID so forgetting, deletion by age and deduplication never remove it. Use it for what must persist however rarely it's recalled."
+        : "Unpin a memory by its UUID, so it is forgotten like any other once it goes unused.",
+      parameters: Type.Object({
+        id: Type.String({ description: pinned ? "UUID of the memory to pin" : "UUID of the memory to unpin" }),
+      }),
+      outputSchema: UpdateOutput,
+      async execute(_id: string, params: { id: string }) {
+        try {
+          const stdout = await runClawbrain(config, pinArgs(params.id, pinned));
+          return typedResult<UpdateDetails>(stdout);
+        } catch (e: any) {
+          return errResult(e.message);
+        }
+      },
+    });
+  }
+
+  // --- memory_supersede -----------------------------------------------------
+  api.registerTool({
+    name: "memory_supersede",
+    description:
+      "Replace memories that are no longer true with a new one. The new memory is stored and each old one is marked superseded by it: searches stop returning the old ones, but memory_get still can, to trace how a belief changed. Prefer this to memory_delete when correcting a fact. Pass if_version with the revision you read to avoid replacing a memory someone else just changed; a conflict response means re-read and retry.",
+    parameters: Type.Object({
+      ids: Type.Array(Type.String(), {
+        description: "UUIDs of the memories the new one replaces",
+        minItems: 1,
+      }),
+      text: Type.String({ description: "The text of the new memory" }),
+      payload: Type.Optional(
+        Type.String({
+          description: "Additional metadata as a JSON string (e.g. '{\"source\": \"chat\"}')",
+        }),
+      ),
+      tags: Type.Optional(
+        Type.Array(Type.String(), { description: "Tags for the new memory" }),
+      ),
+      if_version: Type.Optional(
+        Type.Integer({
+          description: "Only supersede if every old memory is still at this revision",
+          minimum: 0,
+        }),
+      ),
+      if_last_accessed_before: Type.Optional(
+        Type.String({
+          description: "Only supersede if nobody has touched the old memories since this RFC 3339 time",
+        }),
+      ),
+    }),
+    outputSchema: SupersedeOutput,
+    async execute(
+      _id: string,
+      params: {
+        ids: string[];
+        text: string;
+        payload?: string;
+        tags?: string[];
+        if_version?: number;
+        if_last_accessed_before?: string;
+      },
+    ) {
+      try {
+        const args = ["add", "--text", params.text];
+        for (const id of params.ids) {
+          args.push("--supersedes", id);
+        }
+        if (params.payload) {
+          args.push("--payload", params.payload);
+        }
+        for (const tag of params.tags ?? []) {
+          args.push("--tag", tag);
+        }
+        if (params.if_version !== undefined) {
+          args.push("--if-version", String(params.if_version));
+        }
+        if (params.if_last_accessed_before) {
+          args.push("--if-last-accessed-before", params.if_last_accessed_before);
+        }
+        const stdout = await runClawbrain(config, args);
+        return typedResult<SupersedeDetails>(stdout);
+      } catch (e: any) {
+        return errResult(e.message);
+      }
+    },
+  });
+
+  // --- memory_orient --------------------------------------------------------
+  api.registerTool({
+    name: "memory_orient",
+    description:
+      "Get your bearings at the start of a session: how many memories there are of each type, the most recent ones, the pinned ones, open todos, and when files were last synced. Call it before you know what to search for.",
+    parameters: Type.Object({
+      recent: Type.Optional(
+        Type.Number({ description: "Number of most recent memories to return (default 5)" }),
+      ),
+    }),
+    async execute(_id: string, params: { recent?: number }) {
+      try {
+        const args = ["orient"];
+        if (params.recent !== undefined) {
+          args.push("--recent", String(params.recent));
+        }
+        const stdout = await runClawbrain(config, args);
+        return textResult(stdout);
+      } catch (e: any) {
+        return errResult(e.message);
+      }
+    },
+  });
+
+  // --- memory_sync ----------------------------------------------------------
+  api.registerTool({
+    name: "memory_sync",
+    description:
+      "Ingest files into memory: each is split into chunks that are embedded and stored, and files unchanged since the last sync are skipped. Use it to pick up daily notes or a project's docs. Returns counts of chunks added, unchanged and deleted, and per-file progress; a sync that couldn't finish says so and can be continued with resume.",
+    parameters: Type.Object({
+      files: Type.Optional(
+        Type.Array(Type.String(), { description: "Paths of files to ingest" }),
+      ),
+      dirs: Type.Optional(
+        Type.Array(Type.String(), { description: "Directories whose readable files are all ingested" }),
+      ),
+      excludes: Type.Optional(
+        Type.Array(Type.String(), {
+          description: "Glob patterns of files to leave out, e.g. [\"drafts/*\"]",
+        }),
+      ),
+      prune: Type.Optional(
+        Type.Boolean({ description: "Also delete the memories of synced files that no longer exist" }),
+      ),
+      resume: Type.Optional(
+        Type.Boolean({ description: "Continue the last sync that didn't finish, skipping the files it did" }),
+      ),
+    }),
+    async execute(
+      _id: string,
+      params: { files?: string[]; dirs?: string[]; excludes?: string[]; prune?: boolean; resume?: boolean },
+    ) {
+      try {
+        const args = ["sync", "--progress"];
+        for (const file of params.files ?? []) {
+          args.push("--file", file);
+        }
+        for (const dir of params.dirs ?? []) {
+          args.push("--dir", dir);
+        }
+        for (const pattern of params.excludes ?? []) {
+          args.push("--exclude", pattern);
+        }
+        if (params.prune) {
+          args.push("--prune");
+        }
+        if (params.resume) {
+          args.push("--resume");
+        }
+        const stdout = await runClawbrain(config, args, undefined, SYNC_TIMEOUT_MS);
+        return syncResult(stdout);
+      } catch (e: any) {
+        return errResult(e.message);
+      }
+    },
+  });
+
   // --- memory_check ---------------------------------------------------------
   api.registerTool({
     name: "memory_check",
@@ -259,4 +1106,19 @@ export default function register(api: any) {
 // ---------------------------------------------------------------------------
 // Export internals for testing
 // ---------------------------------------------------------------------------
-export { runClawbrain, resolveConfig, type PluginConfig };
+export {
+  runClawbrain,
+  resolveConfig,
+  textResult,
+  errResult,
+  syncResult,
+  pinArgs,
+  searchParams,
+  ServeClient,
+  GetOutput,
+  UpdateOutput,
+  DeleteOutput,
+  SupersedeOutput,
+  type PluginConfig,
+  type ToolDetails,
+};